@@ -0,0 +1,195 @@
+package broker
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// Broadcast is a broker that implements types.Consumer and sends each message
+// to an array of outputs in parallel. A message is only acknowledged once all
+// outputs have acknowledged it, and if any output fails the error is returned
+// upstream rather than retried by the broker.
+type Broadcast struct {
+	running int32
+
+	logger log.Modular
+	stats  metrics.Type
+
+	transactions <-chan types.Transaction
+
+	outputTsChans  []chan types.Transaction
+	outputResChans []chan types.Response
+	outputs        []types.Output
+
+	closedChan chan struct{}
+	closeChan  chan struct{}
+}
+
+// NewBroadcast creates a new Broadcast type by providing outputs.
+func NewBroadcast(
+	outputs []types.Output, logger log.Modular, stats metrics.Type,
+) (*Broadcast, error) {
+	o := &Broadcast{
+		running:      1,
+		stats:        stats,
+		logger:       logger,
+		transactions: nil,
+		outputs:      outputs,
+		closedChan:   make(chan struct{}),
+		closeChan:    make(chan struct{}),
+	}
+
+	o.outputTsChans = make([]chan types.Transaction, len(o.outputs))
+	o.outputResChans = make([]chan types.Response, len(o.outputs))
+	for i := range o.outputTsChans {
+		o.outputTsChans[i] = make(chan types.Transaction)
+		o.outputResChans[i] = make(chan types.Response)
+		if err := o.outputs[i].Consume(o.outputTsChans[i]); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Consume assigns a new transactions channel for the broker to read.
+func (o *Broadcast) Consume(transactions <-chan types.Transaction) error {
+	if o.transactions != nil {
+		return types.ErrAlreadyStarted
+	}
+	o.transactions = transactions
+
+	go o.loop()
+	return nil
+}
+
+// Connected returns a boolean indicating whether this output is currently
+// connected to its target.
+func (o *Broadcast) Connected() bool {
+	for _, out := range o.outputs {
+		if !out.Connected() {
+			return false
+		}
+	}
+	return true
+}
+
+//------------------------------------------------------------------------------
+
+// dispatch sends a copy of a message to an output and returns the error of its
+// response, or types.ErrTypeClosed if the broker is closed whilst waiting.
+func (o *Broadcast) dispatch(i int, msg types.Message) error {
+	select {
+	case o.outputTsChans[i] <- types.NewTransaction(msg, o.outputResChans[i]):
+	case <-o.closeChan:
+		return types.ErrTypeClosed
+	}
+	select {
+	case res := <-o.outputResChans[i]:
+		return res.Error()
+	case <-o.closeChan:
+		return types.ErrTypeClosed
+	}
+}
+
+// loop is an internal loop that brokers incoming messages to many outputs.
+func (o *Broadcast) loop() {
+	defer func() {
+		for _, c := range o.outputTsChans {
+			close(c)
+		}
+		close(o.closedChan)
+	}()
+
+	var (
+		mMsgsRcvd  = o.stats.GetCounter("messages.received")
+		mOutputErr = o.stats.GetCounter("error")
+		mMsgsSnt   = o.stats.GetCounter("messages.sent")
+	)
+
+	errs := make([]error, len(o.outputs))
+	for atomic.LoadInt32(&o.running) == 1 {
+		var ts types.Transaction
+		var open bool
+
+		select {
+		case ts, open = <-o.transactions:
+			if !open {
+				return
+			}
+		case <-o.closeChan:
+			return
+		}
+		mMsgsRcvd.Incr(1)
+
+		wg := sync.WaitGroup{}
+		wg.Add(len(o.outputs))
+		for i := range o.outputs {
+			go func(i int) {
+				errs[i] = o.dispatch(i, ts.Payload.Copy())
+				wg.Done()
+			}(i)
+		}
+		wg.Wait()
+
+		var firstErr error
+		failed := 0
+		for i, err := range errs {
+			if err == types.ErrTypeClosed {
+				return
+			}
+			if err != nil {
+				o.logger.Errorf("Failed to dispatch broadcast message to output '%v': %v\n", i, err)
+				mOutputErr.Incr(1)
+				if firstErr == nil {
+					firstErr = err
+				}
+				failed++
+			} else {
+				mMsgsSnt.Incr(1)
+			}
+		}
+
+		var res types.Response = response.NewAck()
+		if firstErr != nil {
+			res = response.NewError(fmt.Errorf(
+				"failed to dispatch message to %v of %v outputs: %v",
+				failed, len(o.outputs), firstErr,
+			))
+		}
+		select {
+		case ts.ResponseChan <- res:
+		case <-o.closeChan:
+			return
+		}
+	}
+}
+
+// CloseAsync shuts down the Broadcast broker and stops processing requests.
+func (o *Broadcast) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&o.running, 1, 0) {
+		close(o.closeChan)
+	}
+}
+
+// WaitForClose blocks until the Broadcast broker has closed down.
+func (o *Broadcast) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-o.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,185 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func TestBroadcastInterfaces(t *testing.T) {
+	f := &Broadcast{}
+	if types.Consumer(f) == nil {
+		t.Errorf("Broadcast: nil types.Consumer")
+	}
+	if types.Closable(f) == nil {
+		t.Errorf("Broadcast: nil types.Closable")
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func newTestBroadcast(t *testing.T, nOutputs int) (*Broadcast, []*MockOutputType, chan types.Transaction) {
+	t.Helper()
+
+	outputs := []types.Output{}
+	mockOutputs := []*MockOutputType{}
+	for i := 0; i < nOutputs; i++ {
+		mockOutputs = append(mockOutputs, &MockOutputType{})
+		outputs = append(outputs, mockOutputs[i])
+	}
+
+	readChan := make(chan types.Transaction)
+	b, err := NewBroadcast(outputs, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Consume(readChan); err != nil {
+		t.Fatal(err)
+	}
+	return b, mockOutputs, readChan
+}
+
+func TestBroadcastAllAck(t *testing.T) {
+	nOutputs, nMsgs := 5, 100
+	b, mockOutputs, readChan := newTestBroadcast(t, nOutputs)
+	resChan := make(chan types.Response)
+
+	for i := 0; i < nMsgs; i++ {
+		content := [][]byte{[]byte(fmt.Sprintf("hello world %v", i))}
+		select {
+		case readChan <- types.NewTransaction(message.New(content), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for broker send")
+		}
+
+		// Respond in reverse order to ensure faster outputs do not result in an
+		// early acknowledgement.
+		tranSlice := make([]types.Transaction, nOutputs)
+		for j := 0; j < nOutputs; j++ {
+			select {
+			case tranSlice[j] = <-mockOutputs[j].TChan:
+				if act, exp := string(tranSlice[j].Payload.Get(0).Get()), string(content[0]); act != exp {
+					t.Errorf("Wrong content returned %s != %s", act, exp)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Timed out waiting for broker propagate")
+			}
+		}
+		for j := nOutputs - 1; j >= 0; j-- {
+			select {
+			case tranSlice[j].ResponseChan <- response.NewAck():
+			case <-time.After(time.Second):
+				t.Fatal("Timed out responding to broker")
+			}
+			if j > 0 {
+				select {
+				case <-resChan:
+					t.Fatal("Received response before all outputs acknowledged")
+				default:
+				}
+			}
+		}
+
+		select {
+		case res := <-resChan:
+			if res.Error() != nil {
+				t.Errorf("Received unexpected errors from broker: %v", res.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out responding to broker")
+		}
+	}
+
+	b.CloseAsync()
+	if err := b.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBroadcastOneFails(t *testing.T) {
+	b, mockOutputs, readChan := newTestBroadcast(t, 3)
+	resChan := make(chan types.Response)
+
+	select {
+	case readChan <- types.NewTransaction(message.New([][]byte{[]byte("hello")}), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for broker send")
+	}
+
+	for j, mOut := range mockOutputs {
+		var tran types.Transaction
+		select {
+		case tran = <-mOut.TChan:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for broker propagate")
+		}
+		var res types.Response = response.NewAck()
+		if j == 1 {
+			res = response.NewError(errors.New("nope"))
+		}
+		select {
+		case tran.ResponseChan <- res:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out responding to broker")
+		}
+	}
+
+	select {
+	case res := <-resChan:
+		if res.Error() == nil {
+			t.Error("Expected error from broker")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out responding to broker")
+	}
+
+	b.CloseAsync()
+	if err := b.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBroadcastShutDownWhileWaiting(t *testing.T) {
+	b, mockOutputs, readChan := newTestBroadcast(t, 2)
+	resChan := make(chan types.Response)
+
+	select {
+	case readChan <- types.NewTransaction(message.New([][]byte{[]byte("hello")}), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for broker send")
+	}
+
+	var tran types.Transaction
+	select {
+	case tran = <-mockOutputs[0].TChan:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for broker propagate")
+	}
+	select {
+	case tran.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out responding to broker")
+	}
+
+	b.CloseAsync()
+	if err := b.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+
+	select {
+	case <-resChan:
+		t.Error("Unexpected response after shut down")
+	default:
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
@@ -51,6 +52,7 @@ type DynamicFanOut struct {
 
 	onAdd    func(label string)
 	onRemove func(label string)
+	router   func(msg types.Message) string
 
 	transactions <-chan types.Transaction
 
@@ -140,6 +142,18 @@ func OptDynamicFanOutSetOnRemove(onRemoveFunc func(label string)) func(*DynamicF
 	}
 }
 
+// OptDynamicFanOutSetRouter sets a function that selects the label of the
+// single output that each message is sent to, rather than sending each message
+// to all outputs. The router is called for each message of a batch with a
+// message locked to that part, and the parts of a batch are grouped into a
+// batch for each output selected. Messages that select a label without an
+// output are dropped.
+func OptDynamicFanOutSetRouter(router func(msg types.Message) string) func(*DynamicFanOut) {
+	return func(d *DynamicFanOut) {
+		d.router = router
+	}
+}
+
 //------------------------------------------------------------------------------
 
 // Consume assigns a new transactions channel for the broker to read.
@@ -220,6 +234,7 @@ func (d *DynamicFanOut) loop() {
 		mMsgsRcd    = d.stats.GetCounter("messages.received")
 		mOutputErr  = d.stats.GetCounter("output.error")
 		mMsgsSnt    = d.stats.GetCounter("messages.sent")
+		mMsgsDrop   = d.stats.GetCounter("messages.dropped")
 	)
 
 	for atomic.LoadInt32(&d.running) == 1 {
@@ -274,16 +289,37 @@ func (d *DynamicFanOut) loop() {
 		}
 		mMsgsRcd.Incr(1)
 
-		// If we received a message attempt to send it to each output.
+		// If we received a message attempt to send it to each output, or only
+		// the parts of it selected for each output by the router.
 		remainingTargets := make(map[string]outputWithResChan, len(d.outputs))
-		for k, v := range d.outputs {
-			remainingTargets[k] = v
+		targetMsgs := make(map[string]types.Message, len(d.outputs))
+		if d.router != nil {
+			ts.Payload.Iter(func(i int, p types.Part) error {
+				label := d.router(message.Lock(ts.Payload, i))
+				v, exists := d.outputs[label]
+				if !exists {
+					d.log.Warnf("Dropping message routed to missing dynamic output '%v'\n", label)
+					mMsgsDrop.Incr(1)
+					return nil
+				}
+				if _, exists = targetMsgs[label]; !exists {
+					remainingTargets[label] = v
+					targetMsgs[label] = message.New(nil)
+				}
+				targetMsgs[label].Append(p)
+				return nil
+			})
+		} else {
+			for k, v := range d.outputs {
+				remainingTargets[k] = v
+				targetMsgs[k] = ts.Payload
+			}
 		}
 		for len(remainingTargets) > 0 {
-			for _, v := range remainingTargets {
+			for k, v := range remainingTargets {
 				// Perform a copy here as it could be dangerous to release the
 				// same message to parallel processor pipelines.
-				msgCopy := ts.Payload.Copy()
+				msgCopy := targetMsgs[k].Copy()
 				select {
 				case v.tsChan <- types.NewTransaction(msgCopy, v.resChan):
 				case <-d.closeChan:
@@ -106,6 +106,152 @@ func TestBasicDynamicFanOut(t *testing.T) {
 	}
 }
 
+func TestDynamicFanOutRouter(t *testing.T) {
+	mockOutputs := map[string]*MockOutputType{
+		"foo": {},
+		"bar": {},
+	}
+	outputs := map[string]DynamicOutput{}
+	for k, v := range mockOutputs {
+		outputs[k] = v
+	}
+
+	readChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	oTM, err := NewDynamicFanOut(
+		outputs, log.Noop(), metrics.DudType{},
+		OptDynamicFanOutSetRouter(func(msg types.Message) string {
+			return string(msg.Get(0).Get())
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = oTM.Consume(readChan); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, label := range []string{"foo", "bar", "baz", "foo"} {
+		select {
+		case readChan <- types.NewTransaction(message.New([][]byte{[]byte(label)}), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for broker send")
+		}
+
+		if label != "baz" {
+			var ts types.Transaction
+			select {
+			case ts = <-mockOutputs[label].TChan:
+				if exp, act := label, string(ts.Payload.Get(0).Get()); exp != act {
+					t.Errorf("Wrong content returned %s != %s", act, exp)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Timed out waiting for broker propagate")
+			}
+			select {
+			case ts.ResponseChan <- response.NewAck():
+			case <-time.After(time.Second):
+				t.Fatal("Timed out responding to broker")
+			}
+		}
+
+		select {
+		case res := <-resChan:
+			if res.Error() != nil {
+				t.Errorf("Received unexpected errors from broker: %v", res.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out responding to broker")
+		}
+	}
+
+	oTM.CloseAsync()
+	if err := oTM.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDynamicFanOutRouterBatch(t *testing.T) {
+	mockOutputs := map[string]*MockOutputType{
+		"foo": {},
+		"bar": {},
+	}
+	outputs := map[string]DynamicOutput{}
+	for k, v := range mockOutputs {
+		outputs[k] = v
+	}
+
+	readChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	oTM, err := NewDynamicFanOut(
+		outputs, log.Noop(), metrics.DudType{},
+		OptDynamicFanOutSetRouter(func(msg types.Message) string {
+			return string(msg.Get(0).Get())
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = oTM.Consume(readChan); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case readChan <- types.NewTransaction(message.New([][]byte{
+		[]byte("foo"), []byte("bar"), []byte("baz"), []byte("foo"),
+	}), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for broker send")
+	}
+
+	expParts := map[string][]string{
+		"foo": {"foo", "foo"},
+		"bar": {"bar"},
+	}
+	tsByLabel := map[string]types.Transaction{}
+	for len(tsByLabel) < len(mockOutputs) {
+		select {
+		case ts := <-mockOutputs["foo"].TChan:
+			tsByLabel["foo"] = ts
+		case ts := <-mockOutputs["bar"].TChan:
+			tsByLabel["bar"] = ts
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for broker propagate")
+		}
+	}
+	for label, ts := range tsByLabel {
+		var act []string
+		ts.Payload.Iter(func(i int, p types.Part) error {
+			act = append(act, string(p.Get()))
+			return nil
+		})
+		if exp := expParts[label]; !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong contents sent to output %v: %v != %v", label, act, exp)
+		}
+		select {
+		case ts.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second):
+			t.Fatal("Timed out responding to broker")
+		}
+	}
+
+	select {
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Errorf("Received unexpected errors from broker: %v", res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out responding to broker")
+	}
+
+	oTM.CloseAsync()
+	if err := oTM.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestDynamicFanOutChangeOutputs(t *testing.T) {
 	nOutputs := 10
 
@@ -7,7 +7,10 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
 )
 
@@ -22,6 +25,8 @@ type Try struct {
 	stats         metrics.Type
 	outputsPrefix string
 
+	nonRetriableOnly bool
+
 	transactions <-chan types.Transaction
 
 	outputTsChans []chan types.Transaction
@@ -63,6 +68,14 @@ func (t *Try) WithOutputMetricsPrefix(prefix string) {
 	t.outputsPrefix = prefix
 }
 
+// WithNonRetriableOnly changes the broker so that a message is only sent to the
+// next output when an output fails with a non-retriable error, any other error
+// is returned immediately. When an output fails individual parts of a batch only
+// the parts that failed with a non-retriable error are sent to the next output.
+func (t *Try) WithNonRetriableOnly() {
+	t.nonRetriableOnly = true
+}
+
 // Consume assigns a new messages channel for the broker to read.
 func (t *Try) Consume(ts <-chan types.Transaction) error {
 	if t.transactions != nil {
@@ -132,6 +145,19 @@ func (t *Try) loop() {
 			var res types.Response
 			var lOpen bool
 
+			// When only sending non-retriable failures to the next output and
+			// an output fails only some parts of a batch, only those parts are
+			// sent to the next output, and the indexes of the parts still to
+			// be sent and of those that failed are tracked against the
+			// original payload.
+			pending := make([]int, ts.Payload.Len())
+			for i := range pending {
+				pending[i] = i
+			}
+			failed := map[int]error{}
+			partial := false
+			var lastErr error
+
 		triesLoop:
 			for i := 1; i <= len(t.outputTsChans); i++ {
 				select {
@@ -139,21 +165,56 @@ func (t *Try) loop() {
 					if !lOpen {
 						return
 					}
-					if res.Error() != nil {
-						mErrs[i-1].Incr(1)
+				case <-t.closeChan:
+					return
+				}
+				if res.Error() == nil {
+					break triesLoop
+				}
+				mErrs[i-1].Incr(1)
+				lastErr = res.Error()
+
+				bErr, isBatchErr := res.Error().(*batch.Error)
+				isBatchErr = isBatchErr && t.nonRetriableOnly && bErr.IndexedErrors() > 0
+				partial = partial || isBatchErr
+
+				sent := pending
+				pending = nil
+				for j, index := range sent {
+					err := res.Error()
+					if isBatchErr {
+						if err = bErr.PartError(j); err == nil {
+							continue
+						}
+					}
+					if i == len(t.outputTsChans) || (t.nonRetriableOnly && !types.IsNonRetriable(err)) {
+						failed[index] = err
 					} else {
-						break triesLoop
+						pending = append(pending, index)
 					}
+				}
+				if len(pending) == 0 {
+					break triesLoop
+				}
+
+				select {
+				case t.outputTsChans[i] <- types.NewTransaction(partsOf(ts.Payload, pending), resChan):
 				case <-t.closeChan:
 					return
 				}
+			}
 
-				if i < len(t.outputTsChans) {
-					select {
-					case t.outputTsChans[i] <- types.NewTransaction(ts.Payload, resChan):
-					case <-t.closeChan:
-						return
+			// Without partial failures the whole payload shares the fate of
+			// the last response.
+			if partial {
+				if len(failed) == 0 {
+					res = response.NewAck()
+				} else {
+					bErr := batch.NewError(ts.Payload, lastErr)
+					for index, err := range failed {
+						bErr.Failed(index, err)
 					}
+					res = response.NewError(bErr)
 				}
 			}
 			select {
@@ -165,6 +226,19 @@ func (t *Try) loop() {
 	}
 }
 
+// partsOf returns a message containing the parts of a message at indexes, or
+// the message itself when the indexes cover all of its parts.
+func partsOf(msg types.Message, indexes []int) types.Message {
+	if len(indexes) == msg.Len() {
+		return msg
+	}
+	newMsg := message.New(nil)
+	for _, i := range indexes {
+		newMsg.Append(msg.Get(i))
+	}
+	return newMsg
+}
+
 // CloseAsync shuts down the Try broker and stops processing requests.
 func (t *Try) CloseAsync() {
 	if atomic.CompareAndSwapInt32(&t.running, 1, 0) {
@@ -3,12 +3,14 @@ package broker
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
@@ -288,6 +290,258 @@ func TestTryAllFail(t *testing.T) {
 	}
 }
 
+func TestTryNonRetriableOnly(t *testing.T) {
+	mockOutputs := []*MockOutputType{{}, {}}
+	outputs := []types.Output{mockOutputs[0], mockOutputs[1]}
+
+	readChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	oTM, err := NewTry(outputs, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oTM.WithNonRetriableOnly()
+	if err = oTM.Consume(readChan); err != nil {
+		t.Fatal(err)
+	}
+
+	sendAndRespond := func(index int, resErr error) {
+		t.Helper()
+		select {
+		case ts := <-mockOutputs[index].TChan:
+			select {
+			case ts.ResponseChan <- response.NewError(resErr):
+			case <-time.After(time.Second):
+				t.Fatal("Timed out responding to broker")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for broker propagate")
+		}
+	}
+
+	// A retriable error is returned without attempting the next output.
+	testErr := errors.New("test error")
+	select {
+	case readChan <- types.NewTransaction(message.New([][]byte{[]byte("foo")}), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for broker send")
+	}
+	sendAndRespond(0, testErr)
+	select {
+	case res := <-resChan:
+		if exp, act := testErr, res.Error(); exp != act {
+			t.Errorf("Wrong error returned: %v != %v", act, exp)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out responding to broker")
+	}
+
+	// A non-retriable error moves the message to the next output.
+	select {
+	case readChan <- types.NewTransaction(message.New([][]byte{[]byte("bar")}), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for broker send")
+	}
+	sendAndRespond(0, types.NonRetriableError{Err: testErr})
+	select {
+	case ts := <-mockOutputs[1].TChan:
+		if exp, act := "bar", string(ts.Payload.Get(0).Get()); exp != act {
+			t.Errorf("Wrong content returned %s != %s", act, exp)
+		}
+		select {
+		case ts.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second):
+			t.Fatal("Timed out responding to broker")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for broker propagate")
+	}
+	select {
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out responding to broker")
+	}
+
+	oTM.CloseAsync()
+	if err := oTM.WaitForClose(time.Second * 10); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTryNonRetriableOnlyPartialBatch(t *testing.T) {
+	mockOutputs := []*MockOutputType{{}, {}}
+	outputs := []types.Output{mockOutputs[0], mockOutputs[1]}
+
+	readChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	oTM, err := NewTry(outputs, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oTM.WithNonRetriableOnly()
+	if err = oTM.Consume(readChan); err != nil {
+		t.Fatal(err)
+	}
+
+	receive := func(index int, exp []string) types.Transaction {
+		t.Helper()
+		var ts types.Transaction
+		select {
+		case ts = <-mockOutputs[index].TChan:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for broker propagate")
+		}
+		var act []string
+		ts.Payload.Iter(func(i int, p types.Part) error {
+			act = append(act, string(p.Get()))
+			return nil
+		})
+		if !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong contents sent to output %v: %v != %v", index, act, exp)
+		}
+		return ts
+	}
+
+	respond := func(ts types.Transaction, res types.Response) {
+		t.Helper()
+		select {
+		case ts.ResponseChan <- res:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out responding to broker")
+		}
+	}
+
+	testErr := errors.New("test error")
+	tooLargeErr := types.NonRetriableError{Err: errors.New("too large")}
+
+	// Only the part that failed with a non-retriable error is sent to the next
+	// output, and the part that failed with a retriable error is returned.
+	select {
+	case readChan <- types.NewTransaction(message.New([][]byte{
+		[]byte("foo"), []byte("bar"), []byte("baz"),
+	}), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for broker send")
+	}
+	ts := receive(0, []string{"foo", "bar", "baz"})
+	respond(ts, response.NewError(
+		batch.NewError(ts.Payload, testErr).Failed(1, tooLargeErr).Failed(2, testErr),
+	))
+	respond(receive(1, []string{"bar"}), response.NewAck())
+
+	select {
+	case res := <-resChan:
+		bErr, ok := res.Error().(*batch.Error)
+		if !ok {
+			t.Fatalf("Expected batch error, received: %v", res.Error())
+		}
+		for i, exp := range []error{nil, nil, testErr} {
+			if act := bErr.PartError(i); act != exp {
+				t.Errorf("Wrong error for part %v: %v != %v", i, act, exp)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out responding to broker")
+	}
+
+	// A batch where only non-retriable parts failed is acknowledged once they
+	// are sent to the next output.
+	select {
+	case readChan <- types.NewTransaction(message.New([][]byte{
+		[]byte("foo"), []byte("bar"), []byte("baz"),
+	}), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for broker send")
+	}
+	ts = receive(0, []string{"foo", "bar", "baz"})
+	respond(ts, response.NewError(
+		batch.NewError(ts.Payload, tooLargeErr).Failed(0, tooLargeErr).Failed(2, tooLargeErr),
+	))
+	respond(receive(1, []string{"foo", "baz"}), response.NewAck())
+
+	select {
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out responding to broker")
+	}
+
+	oTM.CloseAsync()
+	if err := oTM.WaitForClose(time.Second * 10); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTryPartialBatch(t *testing.T) {
+	mockOutputs := []*MockOutputType{{}, {}}
+	outputs := []types.Output{mockOutputs[0], mockOutputs[1]}
+
+	readChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	oTM, err := NewTry(outputs, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = oTM.Consume(readChan); err != nil {
+		t.Fatal(err)
+	}
+
+	testErr := errors.New("test error")
+	msg := message.New([][]byte{
+		[]byte("foo"), []byte("bar"), []byte("baz"),
+	})
+
+	select {
+	case readChan <- types.NewTransaction(msg, resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for broker send")
+	}
+
+	// Without non-retriable only the whole batch is sent to the next output,
+	// even when an output reports which parts failed.
+	for i, res := range []types.Response{
+		response.NewError(batch.NewError(msg, testErr).Failed(1, testErr)),
+		response.NewAck(),
+	} {
+		var ts types.Transaction
+		select {
+		case ts = <-mockOutputs[i].TChan:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for broker propagate")
+		}
+		if ts.Payload != msg {
+			t.Errorf("Wrong payload sent to output %v", i)
+		}
+		select {
+		case ts.ResponseChan <- res:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out responding to broker")
+		}
+	}
+
+	select {
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out responding to broker")
+	}
+
+	oTM.CloseAsync()
+	if err := oTM.WaitForClose(time.Second * 10); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestTryAllFailParallel(t *testing.T) {
 	outputs := []types.Output{}
 	mockOutputs := []*MockOutputType{
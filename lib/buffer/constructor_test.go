@@ -90,8 +90,9 @@ func TestSanitise(t *testing.T) {
 	exp = `{` +
 		`"type":"memory",` +
 		`"memory":{` +
-		`"batch_policy":{"byte_size":0,"condition":{"type":"static","static":false},"count":0,"enabled":false,"period":""},` +
-		`"limit":20` +
+		`"batch_policy":{"byte_size":0,"check":"","condition":{"type":"static","static":false},"count":0,"enabled":false,"group_by":"","idle_period":"","max_parts":0,"period":""},` +
+		`"limit":20,` +
+		`"on_too_large":"reject"` +
 		`}` +
 		`}`
 
@@ -25,6 +25,19 @@ amount. Since this calculation is only an estimate, and the real size of
 messages in RAM is always higher, it is recommended to set the limit
 significantly below the amount of RAM available.
 
+### Messages Larger Than the Limit
+
+A message larger than the limit can never fit within the buffer. By default
+(` + "`on_too_large: reject`" + `) such a message is rejected back to the input
+with an error, which for most inputs means that it is retried indefinitely and
+blocks the messages behind it. With ` + "`drop`" + ` the message is
+acknowledged without being buffered and the metric
+` + "`buffer.write.too_large.dropped`" + ` is incremented. With
+` + "`truncate`" + ` the contents of the message are cut short so that its
+total size fits within the limit, where the parts of a batch are truncated in
+order and parts beyond the limit are emptied, and the metric
+` + "`buffer.write.too_large.truncated`" + ` is incremented.
+
 ### Batching
 
 It is possible to batch up messages sent from this buffer using a
@@ -39,6 +52,7 @@ It is possible to batch up messages sent from this buffer using a
 			}
 			return map[string]interface{}{
 				"limit":        conf.Memory.Limit,
+				"on_too_large": conf.Memory.OnTooLarge,
 				"batch_policy": bSanit,
 			}, nil
 		},
@@ -56,13 +70,15 @@ type EnabledBatchPolicyConfig struct {
 // MemoryConfig is config values for a purely memory based ring buffer type.
 type MemoryConfig struct {
 	Limit       int                      `json:"limit" yaml:"limit"`
+	OnTooLarge  string                   `json:"on_too_large" yaml:"on_too_large"`
 	BatchPolicy EnabledBatchPolicyConfig `json:"batch_policy" yaml:"batch_policy"`
 }
 
 // NewMemoryConfig creates a new MemoryConfig with default values.
 func NewMemoryConfig() MemoryConfig {
 	return MemoryConfig{
-		Limit: 1024 * 1024 * 500, // 500MB
+		Limit:      1024 * 1024 * 500, // 500MB
+		OnTooLarge: TooLargeReject,
 		BatchPolicy: EnabledBatchPolicyConfig{
 			Enabled:      false,
 			PolicyConfig: batch.NewPolicyConfig(),
@@ -74,7 +90,11 @@ func NewMemoryConfig() MemoryConfig {
 
 // NewMemory creates a buffer held in memory.
 func NewMemory(config Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
-	wrap := NewParallelWrapper(config, parallel.NewMemory(config.Memory.Limit), log, stats)
+	buf, err := wrapTooLarge(parallel.NewMemory(config.Memory.Limit), config.Memory.OnTooLarge, config.Memory.Limit, stats)
+	if err != nil {
+		return nil, err
+	}
+	wrap := NewParallelWrapper(config, buf, log, stats)
 	if !config.Memory.BatchPolicy.Enabled {
 		return wrap, nil
 	}
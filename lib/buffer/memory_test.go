@@ -1,6 +1,7 @@
 package buffer
 
 import (
+	"reflect"
 	"testing"
 	"time"
 
@@ -79,3 +80,130 @@ func TestMemoryBuffer(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestMemoryBufferTooLarge(t *testing.T) {
+	type testCase struct {
+		behaviour string
+		resErr    error
+		expParts  []string
+		metric    string
+	}
+
+	for _, test := range []testCase{
+		{
+			behaviour: TooLargeReject,
+			resErr:    types.ErrMessageTooLarge,
+		},
+		{
+			behaviour: TooLargeDrop,
+			metric:    "write.too_large.dropped",
+		},
+		{
+			behaviour: TooLargeTruncate,
+			expParts:  []string{"hello", " wo", ""},
+			metric:    "write.too_large.truncated",
+		},
+	} {
+		test := test
+		t.Run(test.behaviour, func(t *testing.T) {
+			conf := NewConfig()
+			conf.Type = "memory"
+			conf.Memory.Limit = 8
+			conf.Memory.OnTooLarge = test.behaviour
+
+			stats := metrics.NewLocal()
+			buf, err := New(conf, nil, log.Noop(), stats)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tChan, resChan := make(chan types.Transaction), make(chan types.Response)
+			if err = buf.Consume(tChan); err != nil {
+				t.Fatal(err)
+			}
+
+			send := func(parts ...string) {
+				t.Helper()
+				var rawParts [][]byte
+				for _, p := range parts {
+					rawParts = append(rawParts, []byte(p))
+				}
+				select {
+				case tChan <- types.NewTransaction(message.New(rawParts), resChan):
+				case <-time.After(time.Second):
+					t.Fatal("Timed out")
+				}
+			}
+
+			expectRes := func(exp error) {
+				t.Helper()
+				select {
+				case res := <-resChan:
+					if act := res.Error(); act != exp {
+						t.Errorf("Wrong response: %v != %v", act, exp)
+					}
+				case <-time.After(time.Second):
+					t.Fatal("Timed out")
+				}
+			}
+
+			expectParts := func(exp []string) {
+				t.Helper()
+				select {
+				case outTr := <-buf.TransactionChan():
+					var act []string
+					outTr.Payload.Iter(func(i int, p types.Part) error {
+						act = append(act, string(p.Get()))
+						return nil
+					})
+					if !reflect.DeepEqual(exp, act) {
+						t.Errorf("Wrong message: %q != %q", act, exp)
+					}
+					select {
+					case outTr.ResponseChan <- response.NewAck():
+					case <-time.After(time.Second):
+						t.Fatal("Timed out")
+					}
+				case <-time.After(time.Second):
+					t.Fatal("Timed out")
+				}
+			}
+
+			send("hello", " world", "!")
+			expectRes(test.resErr)
+			if test.expParts != nil {
+				expectParts(test.expParts)
+			}
+
+			// Messages that fit are buffered as normal regardless.
+			send("small")
+			expectRes(nil)
+			expectParts([]string{"small"})
+
+			buf.CloseAsync()
+			if err := buf.WaitForClose(time.Second * 5); err != nil {
+				t.Error(err)
+			}
+
+			counters := stats.GetCounters()
+			for _, k := range []string{"write.too_large.dropped", "write.too_large.truncated"} {
+				exp := int64(0)
+				if k == test.metric {
+					exp = 1
+				}
+				if act := counters[k]; act != exp {
+					t.Errorf("Wrong count of %v: %v != %v", k, act, exp)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryBufferTooLargeBadBehaviour(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = "memory"
+	conf.Memory.OnTooLarge = "nope"
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad on_too_large")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
 )
@@ -16,6 +17,8 @@ import (
 type Batcher interface {
 	Add(part types.Part) bool
 	Flush() types.Message
+	Chunks(msg types.Message) [][]int
+	Count() int
 	UntilNext() time.Duration
 }
 
@@ -100,6 +103,7 @@ func (m *ParallelBatcher) outputLoop() {
 					}
 				}
 			} else {
+				wasEmpty := m.batcher.Count() == 0
 				tran.Payload.Iter(func(i int, p types.Part) error {
 					if m.batcher.Add(p) {
 						flushBatch = true
@@ -107,10 +111,16 @@ func (m *ParallelBatcher) outputLoop() {
 					return nil
 				})
 				pendingResChans = append(pendingResChans, tran.ResponseChan)
+				if wasEmpty {
+					// An idle period may bring the next timed flush forward.
+					nextTimedBatchChan = nil
+				}
 			}
 		case <-nextTimedBatchChan:
-			flushBatch = true
 			nextTimedBatchChan = nil
+			// Messages added since the timer was set may have pushed an idle
+			// flush back.
+			flushBatch = m.batcher.UntilNext() <= 0
 		case <-m.closeChan:
 			return
 		}
@@ -124,31 +134,53 @@ func (m *ParallelBatcher) outputLoop() {
 			continue
 		}
 
-		resChan := make(chan types.Response)
-		select {
-		case m.messagesOut <- types.NewTransaction(sendMsg, resChan):
-		case <-m.closeChan:
-			return
+		chunks := m.batcher.Chunks(sendMsg)
+		resChans := make([]chan types.Response, len(chunks))
+		for i := range resChans {
+			resChans[i] = make(chan types.Response)
 		}
 
-		go func(rChan chan types.Response, upstreamResChans []chan<- types.Response) {
-			select {
-			case <-m.closeChan:
-				return
-			case res, open := <-rChan:
-				if !open {
+		go func(rChans []chan types.Response, upstreamResChans []chan<- types.Response) {
+			// A batch divided by max_parts is only acknowledged once every
+			// chunk has been, and fails if any chunk failed.
+			var res types.Response
+			for _, rChan := range rChans {
+				select {
+				case <-m.closeChan:
 					return
-				}
-				for _, c := range upstreamResChans {
-					select {
-					case <-m.closeChan:
+				case chunkRes, open := <-rChan:
+					if !open {
 						return
-					case c <- res:
+					}
+					if res == nil || res.Error() == nil {
+						res = chunkRes
 					}
 				}
 			}
-		}(resChan, pendingResChans)
+			for _, c := range upstreamResChans {
+				select {
+				case <-m.closeChan:
+					return
+				case c <- res:
+				}
+			}
+		}(resChans, pendingResChans)
 		pendingResChans = nil
+
+		for i, chunk := range chunks {
+			chunkMsg := sendMsg
+			if len(chunks) > 1 {
+				chunkMsg = message.New(nil)
+				for _, j := range chunk {
+					chunkMsg.Append(sendMsg.Get(j))
+				}
+			}
+			select {
+			case m.messagesOut <- types.NewTransaction(chunkMsg, resChans[i]):
+			case <-m.closeChan:
+				return
+			}
+		}
 	}
 }
 
@@ -0,0 +1,93 @@
+package buffer
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// Behaviours for messages that are larger than the limit of a buffer.
+const (
+	TooLargeReject   = "reject"
+	TooLargeDrop     = "drop"
+	TooLargeTruncate = "truncate"
+)
+
+// tooLargeParallel wraps a Parallel buffer and handles messages that it rejects
+// with types.ErrMessageTooLarge, which would otherwise be rejected back to the
+// input and, since they can never fit, retried indefinitely.
+type tooLargeParallel struct {
+	Parallel
+
+	behaviour string
+	limit     int
+
+	// The last known backlog, reported when a message is dropped.
+	backlog int64
+
+	mDropped   metrics.StatCounter
+	mTruncated metrics.StatCounter
+}
+
+// wrapTooLarge wraps a Parallel buffer with a limit in bytes so that messages
+// too large for it are handled by the behaviour, which must be one of reject,
+// drop or truncate. Buffers are returned unwrapped with reject.
+func wrapTooLarge(p Parallel, behaviour string, limit int, stats metrics.Type) (Parallel, error) {
+	switch behaviour {
+	case TooLargeReject:
+		return p, nil
+	case TooLargeDrop, TooLargeTruncate:
+	default:
+		return nil, fmt.Errorf("on_too_large behaviour not recognised: %v", behaviour)
+	}
+	return &tooLargeParallel{
+		Parallel:   p,
+		behaviour:  behaviour,
+		limit:      limit,
+		mDropped:   stats.GetCounter("write.too_large.dropped"),
+		mTruncated: stats.GetCounter("write.too_large.truncated"),
+	}, nil
+}
+
+// truncateMessage returns a copy of a message with the contents of its parts
+// truncated such that their combined size does not exceed the limit. Parts
+// beyond the limit are kept but emptied, so that the message keeps its length.
+func truncateMessage(msg types.Message, limit int) types.Message {
+	newMsg := message.New(nil)
+	remaining := limit
+	msg.Iter(func(i int, p types.Part) error {
+		part := p.Copy()
+		if b := part.Get(); len(b) > remaining {
+			part.Set(b[:remaining])
+		}
+		remaining -= len(part.Get())
+		newMsg.Append(part)
+		return nil
+	})
+	return newMsg
+}
+
+// PushMessage adds a new message to the buffer, dropping or truncating it when
+// it is too large for the buffer. Returns the backlog in bytes.
+func (t *tooLargeParallel) PushMessage(msg types.Message) (int, error) {
+	backlog, err := t.Parallel.PushMessage(msg)
+	if err == types.ErrMessageTooLarge {
+		if t.behaviour == TooLargeDrop {
+			t.mDropped.Incr(1)
+			return int(atomic.LoadInt64(&t.backlog)), nil
+		}
+		t.mTruncated.Incr(1)
+		backlog, err = t.Parallel.PushMessage(truncateMessage(msg, t.limit))
+	}
+	if err == nil {
+		atomic.StoreInt64(&t.backlog, int64(backlog))
+	}
+	return backlog, err
+}
+
+//------------------------------------------------------------------------------
@@ -19,7 +19,8 @@ import (
 
 // TypeSpec is a constructor and a usage description for each cache type.
 type TypeSpec struct {
-	constructor func(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (types.Cache, error)
+	constructor        func(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (types.Cache, error)
+	sanitiseConfigFunc func(conf Config) (interface{}, error)
 
 	Summary     string
 	Description string
@@ -33,39 +34,55 @@ var Constructors = map[string]TypeSpec{}
 
 // String constants representing each cache type.
 const (
-	TypeDynamoDB  = "dynamodb"
-	TypeFile      = "file"
-	TypeMemcached = "memcached"
-	TypeMemory    = "memory"
-	TypeRedis     = "redis"
-	TypeS3        = "s3"
+	TypeDynamoDB   = "dynamodb"
+	TypeFile       = "file"
+	TypeLRU        = "lru"
+	TypeMemcached  = "memcached"
+	TypeMemory     = "memory"
+	TypeMultilevel = "multilevel"
+	TypeNamespaced = "namespaced"
+	TypeRedis      = "redis"
+	TypeRetry      = "retry"
+	TypeS3         = "s3"
 )
 
 //------------------------------------------------------------------------------
 
 // Config is the all encompassing configuration struct for all cache types.
 type Config struct {
-	Type      string          `json:"type" yaml:"type"`
-	DynamoDB  DynamoDBConfig  `json:"dynamodb" yaml:"dynamodb"`
-	File      FileConfig      `json:"file" yaml:"file"`
-	Memcached MemcachedConfig `json:"memcached" yaml:"memcached"`
-	Memory    MemoryConfig    `json:"memory" yaml:"memory"`
-	Plugin    interface{}     `json:"plugin,omitempty" yaml:"plugin,omitempty"`
-	Redis     RedisConfig     `json:"redis" yaml:"redis"`
-	S3        S3Config        `json:"s3" yaml:"s3"`
+	Type       string           `json:"type" yaml:"type"`
+	DynamoDB   DynamoDBConfig   `json:"dynamodb" yaml:"dynamodb"`
+	File       FileConfig       `json:"file" yaml:"file"`
+	LRU        LRUConfig        `json:"lru" yaml:"lru"`
+	Memcached  MemcachedConfig  `json:"memcached" yaml:"memcached"`
+	Memory     MemoryConfig     `json:"memory" yaml:"memory"`
+	Multilevel MultilevelConfig `json:"multilevel" yaml:"multilevel"`
+	Namespaced NamespacedConfig `json:"namespaced" yaml:"namespaced"`
+	Plugin     interface{}      `json:"plugin,omitempty" yaml:"plugin,omitempty"`
+	Redis      RedisConfig      `json:"redis" yaml:"redis"`
+	Retry      RetryConfig      `json:"retry" yaml:"retry"`
+	S3         S3Config         `json:"s3" yaml:"s3"`
+
+	// transactional is set when a config requests transactional writes from
+	// its cache type, including types without a transactional field.
+	transactional bool `json:"-" yaml:"-"`
 }
 
 // NewConfig returns a configuration struct fully populated with default values.
 func NewConfig() Config {
 	return Config{
-		Type:      "memory",
-		DynamoDB:  NewDynamoDBConfig(),
-		File:      NewFileConfig(),
-		Memcached: NewMemcachedConfig(),
-		Memory:    NewMemoryConfig(),
-		Plugin:    nil,
-		Redis:     NewRedisConfig(),
-		S3:        NewS3Config(),
+		Type:       "memory",
+		DynamoDB:   NewDynamoDBConfig(),
+		File:       NewFileConfig(),
+		LRU:        NewLRUConfig(),
+		Memcached:  NewMemcachedConfig(),
+		Memory:     NewMemoryConfig(),
+		Multilevel: NewMultilevelConfig(),
+		Namespaced: NewNamespacedConfig(),
+		Plugin:     nil,
+		Redis:      NewRedisConfig(),
+		Retry:      NewRetryConfig(),
+		S3:         NewS3Config(),
 	}
 }
 
@@ -86,18 +103,24 @@ func SanitiseConfig(conf Config) (interface{}, error) {
 	outputMap := config.Sanitised{}
 	outputMap["type"] = conf.Type
 
-	if _, exists := hashMap[conf.Type]; exists {
-		outputMap[conf.Type] = hashMap[conf.Type]
-	}
-	if spec, exists := pluginSpecs[conf.Type]; exists {
-		var plugSanit interface{}
-		if spec.confSanitiser != nil {
-			plugSanit = spec.confSanitiser(conf.Plugin)
-		} else {
-			plugSanit = hashMap["plugin"]
+	if sfunc := Constructors[conf.Type].sanitiseConfigFunc; sfunc != nil {
+		if outputMap[conf.Type], err = sfunc(conf); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, exists := hashMap[conf.Type]; exists {
+			outputMap[conf.Type] = hashMap[conf.Type]
 		}
-		if plugSanit != nil {
-			outputMap["plugin"] = plugSanit
+		if spec, exists := pluginSpecs[conf.Type]; exists {
+			var plugSanit interface{}
+			if spec.confSanitiser != nil {
+				plugSanit = spec.confSanitiser(conf.Plugin)
+			} else {
+				plugSanit = hashMap["plugin"]
+			}
+			if plugSanit != nil {
+				outputMap["plugin"] = plugSanit
+			}
 		}
 	}
 
@@ -136,6 +159,12 @@ func (c *Config) UnmarshalYAML(value *yaml.Node) error {
 		aliased.Type = inferredType
 	}
 
+	if rawMap, ok := raw.(map[string]interface{}); ok {
+		if typeConf, ok := rawMap[aliased.Type].(map[string]interface{}); ok {
+			aliased.transactional, _ = typeConf["transactional"].(bool)
+		}
+	}
+
 	if spec, exists := pluginSpecs[aliased.Type]; exists && spec.confConstructor != nil {
 		confBytes, err := yaml.Marshal(aliased.Plugin)
 		if err != nil {
@@ -248,6 +277,12 @@ func New(
 		if err != nil {
 			return nil, fmt.Errorf("failed to create cache '%v': %v", conf.Type, err)
 		}
+		if conf.transactional {
+			if t, ok := cache.(types.TransactionalCache); !ok || !t.Transactional() {
+				cache.CloseAsync()
+				return nil, fmt.Errorf("failed to create cache '%v': transactional writes are not supported", conf.Type)
+			}
+		}
 		return cache, nil
 	}
 	if c, ok := pluginSpecs[conf.Type]; ok {
@@ -93,3 +93,47 @@ func TestConstructorConfigDefaultsYAML(t *testing.T) {
 		t.Errorf("Wrong overridden ttl: %v != %v", act, exp)
 	}
 }
+
+func TestConstructorTransactional(t *testing.T) {
+	tests := map[string]struct {
+		config string
+		errors bool
+	}{
+		"memory": {
+			config: `memory:
+  transactional: true`,
+		},
+		"lru": {
+			config: `lru:
+  transactional: true`,
+		},
+		"memcached": {
+			config: `memcached:
+  transactional: true`,
+			errors: true,
+		},
+		"memcached not transactional": {
+			config: `memcached:
+  transactional: false`,
+		},
+	}
+
+	for name, test := range tests {
+		conf := NewConfig()
+		if err := yaml.Unmarshal([]byte(test.config), &conf); err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		c, err := New(conf, nil, log.Noop(), metrics.Noop())
+		if test.errors {
+			if err == nil {
+				t.Errorf("%v: Expected error from transactional cache", name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+		c.CloseAsync()
+	}
+}
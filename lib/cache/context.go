@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// GetWithContext attempts to locate and return a cached value by its key. When
+// the cache implements types.ContextCache the operation is abandoned once the
+// context is cancelled, otherwise the context is only checked before calling
+// Get.
+func GetWithContext(ctx context.Context, c types.Cache, key string) ([]byte, error) {
+	if cc, ok := c.(types.ContextCache); ok {
+		return cc.GetWithContext(ctx, key)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Get(key)
+}
+
+// SetWithContext attempts to set the value of a key. When the cache implements
+// types.ContextCache the operation is abandoned once the context is cancelled,
+// otherwise the context is only checked before calling Set.
+func SetWithContext(ctx context.Context, c types.Cache, key string, value []byte) error {
+	if cc, ok := c.(types.ContextCache); ok {
+		return cc.SetWithContext(ctx, key, value)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Set(key, value)
+}
+
+// waitWithContext blocks for a period, or returns the error of the context if
+// it is cancelled first.
+func waitWithContext(ctx context.Context, period time.Duration) error {
+	select {
+	case <-time.After(period):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
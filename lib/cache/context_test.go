@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/cenkalti/backoff"
+)
+
+//------------------------------------------------------------------------------
+
+type noContextCache struct {
+	types.Cache
+}
+
+func TestCacheWithContextFallback(t *testing.T) {
+	c := noContextCache{Cache: newTestMemory(t)}
+	if _, ok := interface{}(c).(types.ContextCache); ok {
+		t.Fatal("Expected cache without context support")
+	}
+
+	ctx, done := context.WithCancel(context.Background())
+	if err := SetWithContext(ctx, c, "foo", []byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+	if act, err := GetWithContext(ctx, c, "foo"); err != nil {
+		t.Fatal(err)
+	} else if exp := "bar"; string(act) != exp {
+		t.Errorf("Wrong result: %s != %v", act, exp)
+	}
+
+	done()
+	if err := SetWithContext(ctx, c, "foo", []byte("baz")); err != context.Canceled {
+		t.Errorf("Wrong error returned: %v != %v", err, context.Canceled)
+	}
+	if _, err := GetWithContext(ctx, c, "foo"); err != context.Canceled {
+		t.Errorf("Wrong error returned: %v != %v", err, context.Canceled)
+	}
+	if act, err := c.Get("foo"); err != nil || string(act) != "bar" {
+		t.Errorf("Expected value to be unchanged: %s, %v", act, err)
+	}
+}
+
+func TestCacheWithContextMemory(t *testing.T) {
+	c := newTestMemory(t)
+	if _, ok := c.(types.ContextCache); !ok {
+		t.Fatal("Expected memory cache to support contexts")
+	}
+
+	ctx, done := context.WithCancel(context.Background())
+	done()
+	if err := SetWithContext(ctx, c, "foo", []byte("bar")); err != context.Canceled {
+		t.Errorf("Wrong error returned: %v != %v", err, context.Canceled)
+	}
+	if _, err := c.Get("foo"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+}
+
+func TestCacheWithContextRetry(t *testing.T) {
+	flaky := &flakyCache{Cache: newTestMemory(t), failures: 100}
+	c := newTestRetry(t, flaky, 100)
+	c.backoffCtor = func() backoff.BackOff {
+		return backoff.NewConstantBackOff(time.Hour)
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer done()
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- c.SetWithContext(ctx, "foo", []byte("bar"))
+	}()
+
+	select {
+	case err := <-errChan:
+		if err != context.DeadlineExceeded {
+			t.Errorf("Wrong error returned: %v != %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for retries to be abandoned")
+	}
+	if exp, act := 1, flaky.calls; exp != act {
+		t.Errorf("Wrong count of calls: %v != %v", act, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// Increment attempts to atomically add delta to the value of a key and returns
+// the new value, creating the key with the value of delta if it does not exist.
+// Returns types.ErrCounterNotSupported when the cache does not implement
+// types.CounterCache.
+func Increment(c types.Cache, key string, delta int64) (int64, error) {
+	if cc, ok := c.(types.CounterCache); ok {
+		return cc.Increment(key, delta)
+	}
+	return 0, types.ErrCounterNotSupported
+}
+
+// CompareAndSet attempts to set the value of a key only if its current value
+// matches old, where a nil old value matches a key that does not exist.
+// Returns types.ErrCASNotSupported when the cache does not implement
+// types.CASCache.
+func CompareAndSet(c types.Cache, key string, old, new []byte) error {
+	if cc, ok := c.(types.CASCache); ok {
+		return cc.CompareAndSet(key, old, new)
+	}
+	return types.ErrCASNotSupported
+}
+
+//------------------------------------------------------------------------------
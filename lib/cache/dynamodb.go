@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"sync"
@@ -207,20 +208,29 @@ func NewDynamoDB(conf Config, mgr types.Manager, log log.Modular, stats metrics.
 // Get attempts to locate and return a cached value by its key, returns an error
 // if the key does not exist.
 func (d *DynamoDB) Get(key string) ([]byte, error) {
+	return d.GetWithContext(context.Background(), key)
+}
+
+// GetWithContext attempts to locate and return a cached value by its key,
+// returns an error if the key does not exist, if the operation failed or if the
+// context is cancelled before it succeeds.
+func (d *DynamoDB) GetWithContext(ctx context.Context, key string) ([]byte, error) {
 	d.mGetCount.Incr(1)
 
 	tStarted := time.Now()
 	boff := d.boffPool.Get().(backoff.BackOff)
 
-	result, err := d.get(key)
-	for err != nil && err != types.ErrKeyNotFound {
+	result, err := d.get(ctx, key)
+	for err != nil && err != types.ErrKeyNotFound && ctx.Err() == nil {
 		wait := boff.NextBackOff()
 		if wait == backoff.Stop {
 			break
 		}
-		time.Sleep(wait)
+		if err = waitWithContext(ctx, wait); err != nil {
+			break
+		}
 		d.mGetRetry.Incr(1)
-		result, err = d.get(key)
+		result, err = d.get(ctx, key)
 	}
 	if err == nil {
 		d.mGetSuccess.Incr(1)
@@ -239,8 +249,8 @@ func (d *DynamoDB) Get(key string) ([]byte, error) {
 	return result, err
 }
 
-func (d *DynamoDB) get(key string) ([]byte, error) {
-	res, err := d.client.GetItem(&dynamodb.GetItemInput{
+func (d *DynamoDB) get(ctx context.Context, key string) ([]byte, error) {
+	res, err := d.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
 		Key: map[string]*dynamodb.AttributeValue{
 			d.conf.HashKey: {
 				S: aws.String(key),
@@ -262,20 +272,28 @@ func (d *DynamoDB) get(key string) ([]byte, error) {
 
 // Set attempts to set the value of a key.
 func (d *DynamoDB) Set(key string, value []byte) error {
+	return d.SetWithContext(context.Background(), key, value)
+}
+
+// SetWithContext attempts to set the value of a key, returns an error if the
+// operation failed or if the context is cancelled before it succeeds.
+func (d *DynamoDB) SetWithContext(ctx context.Context, key string, value []byte) error {
 	d.mSetCount.Incr(1)
 
 	tStarted := time.Now()
 	boff := d.boffPool.Get().(backoff.BackOff)
 
-	_, err := d.client.PutItem(d.putItemInput(key, value))
-	for err != nil {
+	_, err := d.client.PutItemWithContext(ctx, d.putItemInput(key, value))
+	for err != nil && ctx.Err() == nil {
 		wait := boff.NextBackOff()
 		if wait == backoff.Stop {
 			break
 		}
-		time.Sleep(wait)
+		if err = waitWithContext(ctx, wait); err != nil {
+			break
+		}
 		d.mSetRetry.Incr(1)
-		_, err = d.client.PutItem(d.putItemInput(key, value))
+		_, err = d.client.PutItemWithContext(ctx, d.putItemInput(key, value))
 	}
 	if err == nil {
 		d.mSetSuccess.Incr(1)
@@ -1,14 +1,22 @@
 package cache
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/x/docs"
 )
 
 //------------------------------------------------------------------------------
@@ -20,8 +28,21 @@ func init() {
 The file cache stores each item in a directory as a file, where an item ID is
 the path relative to the configured directory.
 
-This type currently offers no form of item expiry or garbage collection, and is
-intended to be used for development and debugging purposes only.`,
+Each file begins with a small header containing the expiry time of the item,
+followed by the raw value. When ` + "`default_ttl`" + ` is set items expire
+after that duration has passed since they were last set, and expired items are
+treated as missing until they are next set or deleted. An empty
+` + "`default_ttl`" + ` results in items that never expire.
+
+Items are written to a temporary file before being moved into place, and so an
+unclean shutdown will not result in partially written items. Files that exist
+within the directory without a header are read as values that never expire,
+and any item with a corrupt header is treated as missing and overwritten when
+next set.`,
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("directory", "The directory within which to store items."),
+			docs.FieldCommon("default_ttl", "An optional duration after which items expire, measured from the moment they were last set.", "60s", "24h"),
+		},
 	}
 }
 
@@ -29,52 +50,233 @@ intended to be used for development and debugging purposes only.`,
 
 // FileConfig contains config fields for the File cache type.
 type FileConfig struct {
-	Directory string `json:"directory" yaml:"directory"`
+	Directory  string `json:"directory" yaml:"directory"`
+	DefaultTTL string `json:"default_ttl" yaml:"default_ttl"`
 }
 
 // NewFileConfig creates a FileConfig populated with default values.
 func NewFileConfig() FileConfig {
 	return FileConfig{
-		Directory: "",
+		Directory:  "",
+		DefaultTTL: "",
 	}
 }
 
 //------------------------------------------------------------------------------
 
+const (
+	fileCacheTmpSuffix = ".benthos_tmp"
+	fileHeaderLen      = 16
+)
+
+// fileHeaderMagic prefixes the header of each item, followed by a big endian
+// unix nano timestamp of when the item expires, or zero if it never expires.
+var fileHeaderMagic = []byte("BNTHSFC1")
+
+var errFileCorrupt = errors.New("corrupt file cache header")
+
 // File is a file system based cache implementation.
 type File struct {
 	dir string
+	ttl time.Duration
+
+	log log.Modular
+
+	sync.RWMutex
 }
 
 // NewFile creates a new File cache type.
 func NewFile(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (types.Cache, error) {
-	return &File{
+	var ttl time.Duration
+	if conf.File.DefaultTTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(conf.File.DefaultTTL); err != nil {
+			return nil, fmt.Errorf("failed to parse default_ttl: %v", err)
+		}
+	}
+	f := &File{
 		dir: conf.File.Directory,
+		ttl: ttl,
+		log: log,
+	}
+	f.removeTmpFiles()
+	return f, nil
+}
+
+//------------------------------------------------------------------------------
+
+// removeTmpFiles walks the directory and removes any temporary files left
+// behind by writes that were interrupted by an unclean shutdown.
+func (f *File) removeTmpFiles() {
+	if f.dir == "" {
+		return
+	}
+	filepath.Walk(f.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, fileCacheTmpSuffix) {
+			return nil
+		}
+		if rerr := os.Remove(path); rerr != nil {
+			f.log.Warnf("Failed to remove temporary file '%v': %v\n", path, rerr)
+		}
+		return nil
+	})
+}
+
+// readHeader reads the header of an item and returns whether it has expired.
+// Items without a header are considered to be stored without an expiry.
+func readHeader(r io.Reader) (prefix []byte, expired bool, err error) {
+	header := make([]byte, fileHeaderLen)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, false, err
+	}
+	header = header[:n]
+	if !bytes.HasPrefix(header, fileHeaderMagic) {
+		return header, false, nil
+	}
+	if n < fileHeaderLen {
+		return nil, false, errFileCorrupt
+	}
+	expiry := int64(binary.BigEndian.Uint64(header[len(fileHeaderMagic):]))
+	if expiry > 0 && time.Now().UnixNano() >= expiry {
+		return nil, true, nil
+	}
+	return nil, false, nil
+}
+
+// open returns a reader of an item value, returns types.ErrKeyNotFound if the
+// item does not exist, has expired, or has a corrupt header.
+func (f *File) open(key string) (io.ReadCloser, error) {
+	path := filepath.Join(f.dir, key)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, types.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, expired, err := readHeader(file)
+	if err == nil && expired {
+		err = types.ErrKeyNotFound
+	}
+	if err == errFileCorrupt {
+		f.log.Warnf("Ignoring item '%v' with a corrupt header\n", key)
+		err = types.ErrKeyNotFound
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if len(prefix) == 0 {
+		return file, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: io.MultiReader(bytes.NewReader(prefix), file),
+		Closer: file,
 	}, nil
 }
 
+// write atomically writes an item by writing to a temporary file before moving
+// it into place.
+func (f *File) write(key string, value []byte, ttl time.Duration) error {
+	path := filepath.Join(f.dir, key)
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*"+fileCacheTmpSuffix)
+	if err != nil {
+		return err
+	}
+
+	var expiry int64
+	if ttl > 0 {
+		expiry = time.Now().Add(ttl).UnixNano()
+	}
+	header := make([]byte, fileHeaderLen)
+	copy(header, fileHeaderMagic)
+	binary.BigEndian.PutUint64(header[len(fileHeaderMagic):], uint64(expiry))
+
+	if _, err = tmp.Write(header); err == nil {
+		if _, err = tmp.Write(value); err == nil {
+			err = tmp.Sync()
+		}
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// exists returns whether an item exists and has not expired.
+func (f *File) exists(key string) (bool, error) {
+	r, err := f.open(key)
+	if err == types.ErrKeyNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	r.Close()
+	return true, nil
+}
+
 //------------------------------------------------------------------------------
 
 // Get attempts to locate and return a cached value by its key, returns an error
 // if the key does not exist.
 func (f *File) Get(key string) ([]byte, error) {
-	b, err := ioutil.ReadFile(filepath.Join(f.dir, key))
-	if os.IsNotExist(err) {
-		return nil, types.ErrKeyNotFound
+	f.RLock()
+	defer f.RUnlock()
+
+	r, err := f.open(key)
+	if err != nil {
+		return nil, err
 	}
-	return b, err
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// GetStream attempts to locate a cached value by its key and returns a reader
+// of the underlying file, returns an error if the key does not exist.
+func (f *File) GetStream(key string) (io.ReadCloser, error) {
+	f.RLock()
+	defer f.RUnlock()
+	return f.open(key)
 }
 
 // Set attempts to set the value of a key.
 func (f *File) Set(key string, value []byte) error {
-	return ioutil.WriteFile(filepath.Join(f.dir, key), value, 0644)
+	return f.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL attempts to set the value of a key with a TTL that overrides the
+// default of the cache. A TTL of zero results in the default being used.
+func (f *File) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = f.ttl
+	}
+	f.Lock()
+	defer f.Unlock()
+	return f.write(key, value, ttl)
 }
 
 // SetMulti attempts to set the value of multiple keys, returns an error if any
 // keys fail.
 func (f *File) SetMulti(items map[string][]byte) error {
+	f.Lock()
+	defer f.Unlock()
 	for k, v := range items {
-		if err := f.Set(k, v); err != nil {
+		if err := f.write(k, v, f.ttl); err != nil {
 			return err
 		}
 	}
@@ -84,22 +286,23 @@ func (f *File) SetMulti(items map[string][]byte) error {
 // Add attempts to set the value of a key only if the key does not already exist
 // and returns an error if the key already exists.
 func (f *File) Add(key string, value []byte) error {
-	file, err := os.OpenFile(filepath.Join(f.dir, key), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	f.Lock()
+	defer f.Unlock()
+
+	exists, err := f.exists(key)
 	if err != nil {
-		if os.IsExist(err) {
-			return types.ErrKeyAlreadyExists
-		}
 		return err
 	}
-	if _, err = file.Write(value); err != nil {
-		file.Close()
-		return err
+	if exists {
+		return types.ErrKeyAlreadyExists
 	}
-	return file.Close()
+	return f.write(key, value, f.ttl)
 }
 
 // Delete attempts to remove a key.
 func (f *File) Delete(key string) error {
+	f.Lock()
+	defer f.Unlock()
 	return os.Remove(filepath.Join(f.dir, key))
 }
 
@@ -3,7 +3,11 @@ package cache
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
@@ -79,4 +83,152 @@ func TestFileCache(t *testing.T) {
 	}
 }
 
+func TestFileCacheTTL(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeFile
+	dir, err := ioutil.TempDir("", "benthos_file_cache_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	conf.File.Directory = dir
+	conf.File.DefaultTTL = "10ms"
+
+	c, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.Set("foo", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err = SetWithTTL(c, "bar", []byte("2"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	<-time.After(time.Millisecond * 50)
+
+	if _, err = c.Get("foo"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+	if err = c.Add("foo", []byte("3")); err != nil {
+		t.Errorf("Expected add of expired key to succeed: %v", err)
+	}
+	if act, err := c.Get("bar"); err != nil {
+		t.Error(err)
+	} else if exp := "2"; string(act) != exp {
+		t.Errorf("Wrong result: %v != %v", string(act), exp)
+	}
+}
+
+func TestFileCacheBadTTL(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeFile
+	conf.File.DefaultTTL = "nope"
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad default_ttl")
+	}
+}
+
+func TestFileCacheRecovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_file_cache_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tmpPath := filepath.Join(dir, ".foo.123"+fileCacheTmpSuffix)
+	for path, content := range map[string]string{
+		"raw":     "raw value",
+		"corrupt": string(fileHeaderMagic) + "abc",
+		tmpPath:   "partial",
+	} {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf := NewConfig()
+	conf.Type = TypeFile
+	conf.File.Directory = dir
+
+	c, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = os.Stat(tmpPath); !os.IsNotExist(err) {
+		t.Errorf("Expected temporary file to be removed: %v", err)
+	}
+
+	if act, err := c.Get("raw"); err != nil {
+		t.Error(err)
+	} else if exp := "raw value"; string(act) != exp {
+		t.Errorf("Wrong result: %v != %v", string(act), exp)
+	}
+
+	if _, err = c.Get("corrupt"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+	if err = c.Add("corrupt", []byte("fixed")); err != nil {
+		t.Fatal(err)
+	}
+	if act, err := c.Get("corrupt"); err != nil {
+		t.Error(err)
+	} else if exp := "fixed"; string(act) != exp {
+		t.Errorf("Wrong result: %v != %v", string(act), exp)
+	}
+
+	r, err := c.(*File).GetStream("corrupt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	act, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "fixed"; string(act) != exp {
+		t.Errorf("Wrong stream result: %v != %v", string(act), exp)
+	}
+}
+
+func TestFileCacheConcurrentAdd(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeFile
+	dir, err := ioutil.TempDir("", "benthos_file_cache_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	conf.File.Directory = dir
+
+	c, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var added int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Add("foo", []byte("bar")); err == nil {
+				atomic.AddInt32(&added, 1)
+			} else if err != types.ErrKeyAlreadyExists {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if added != 1 {
+		t.Errorf("Wrong count of successful adds: %v != 1", added)
+	}
+}
+
 //------------------------------------------------------------------------------
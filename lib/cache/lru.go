@@ -0,0 +1,234 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/x/docs"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeLRU] = TypeSpec{
+		constructor: NewLRU,
+		Description: `
+The lru cache stores key/value pairs in memory, bounding the total size of the
+cache in bytes rather than by a number of keys. When a write takes the total
+size of the keys and values stored above ` + "`max_bytes`" + ` the least
+recently used items are evicted until it is back within the limit. This cache
+is therefore suitable for values that vary greatly in size.
+
+` + "``` yaml" + `
+resources:
+  caches:
+    foocache:
+      lru:
+        max_bytes: 67108864 # 64MiB
+        ttl: 300
+` + "```" + `
+
+Items also expire once their TTL has passed since they were last written, after
+which reads treat them as not existing. Writing a single item larger than
+` + "`max_bytes`" + ` fails with an error.`,
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("max_bytes", "The maximum total size in bytes of the keys and values stored in the cache."),
+			docs.FieldCommon("ttl", "The TTL of each item in seconds, measured from when it was last written. Set to zero to disable expiry."),
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// LRUConfig contains config fields for the LRU cache type.
+type LRUConfig struct {
+	MaxBytes int64 `json:"max_bytes" yaml:"max_bytes"`
+	TTL      int   `json:"ttl" yaml:"ttl"`
+}
+
+// NewLRUConfig creates a LRUConfig populated with default values.
+func NewLRUConfig() LRUConfig {
+	return LRUConfig{
+		MaxBytes: 64 * 1024 * 1024, // 64MiB
+		TTL:      300,              // 5 Mins
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// ErrLRUItemTooLarge is returned when an item is larger than the maximum size
+// of an LRU cache.
+var ErrLRUItemTooLarge = errors.New("item exceeds max_bytes of cache")
+
+type lruItem struct {
+	key   string
+	value []byte
+	ts    time.Time
+}
+
+func (i *lruItem) size() int64 {
+	return int64(len(i.key) + len(i.value))
+}
+
+// LRU is a memory based cache implementation that evicts the least recently
+// used items in order to stay within a limit of bytes.
+type LRU struct {
+	maxBytes int64
+	ttl      time.Duration
+
+	items   map[string]*list.Element
+	order   *list.List
+	current int64
+
+	mEvictions metrics.StatCounter
+	mKeys      metrics.StatGauge
+	mBytes     metrics.StatGauge
+
+	sync.Mutex
+}
+
+// NewLRU creates a new LRU cache type.
+func NewLRU(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (types.Cache, error) {
+	if conf.LRU.MaxBytes <= 0 {
+		return nil, fmt.Errorf("max_bytes must be greater than zero, got: %v", conf.LRU.MaxBytes)
+	}
+	if conf.LRU.TTL < 0 {
+		return nil, fmt.Errorf("ttl must not be negative, got: %v", conf.LRU.TTL)
+	}
+	return &LRU{
+		maxBytes:   conf.LRU.MaxBytes,
+		ttl:        time.Second * time.Duration(conf.LRU.TTL),
+		items:      map[string]*list.Element{},
+		order:      list.New(),
+		mEvictions: stats.GetCounter("eviction"),
+		mKeys:      stats.GetGauge("keys"),
+		mBytes:     stats.GetGauge("bytes"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (l *LRU) expired(i *lruItem) bool {
+	return l.ttl > 0 && time.Since(i.ts) >= l.ttl
+}
+
+func (l *LRU) remove(e *list.Element) {
+	i := l.order.Remove(e).(*lruItem)
+	delete(l.items, i.key)
+	l.current -= i.size()
+}
+
+func (l *LRU) updateGauges() {
+	l.mKeys.Set(int64(len(l.items)))
+	l.mBytes.Set(l.current)
+}
+
+// set writes an item and evicts the least recently used items until the cache
+// is within its limit, the lock must be held by the caller.
+func (l *LRU) set(key string, value []byte) error {
+	i := &lruItem{key: key, value: value, ts: time.Now()}
+	if i.size() > l.maxBytes {
+		return ErrLRUItemTooLarge
+	}
+	if e, exists := l.items[key]; exists {
+		l.remove(e)
+	}
+	l.items[key] = l.order.PushFront(i)
+	l.current += i.size()
+	for l.current > l.maxBytes {
+		l.remove(l.order.Back())
+		l.mEvictions.Incr(1)
+	}
+	return nil
+}
+
+// Get attempts to locate and return a cached value by its key, returns an error
+// if the key does not exist or has been evicted.
+func (l *LRU) Get(key string) ([]byte, error) {
+	l.Lock()
+	defer l.Unlock()
+	e, exists := l.items[key]
+	if !exists {
+		return nil, types.ErrKeyNotFound
+	}
+	if i := e.Value.(*lruItem); l.expired(i) {
+		l.remove(e)
+		l.updateGauges()
+		return nil, types.ErrKeyNotFound
+	}
+	l.order.MoveToFront(e)
+	return e.Value.(*lruItem).value, nil
+}
+
+// Set attempts to set the value of a key.
+func (l *LRU) Set(key string, value []byte) error {
+	l.Lock()
+	defer l.Unlock()
+	err := l.set(key, value)
+	l.updateGauges()
+	return err
+}
+
+// SetMulti attempts to set the value of multiple keys, returns an error if any
+// keys fail.
+func (l *LRU) SetMulti(items map[string][]byte) error {
+	l.Lock()
+	defer l.Unlock()
+	var err error
+	for k, v := range items {
+		if sErr := l.set(k, v); sErr != nil {
+			err = sErr
+		}
+	}
+	l.updateGauges()
+	return err
+}
+
+// Transactional returns true as multiple keys are always set under a single
+// lock.
+func (l *LRU) Transactional() bool {
+	return true
+}
+
+// Add attempts to set the value of a key only if the key does not already exist
+// and returns an error if the key already exists.
+func (l *LRU) Add(key string, value []byte) error {
+	l.Lock()
+	defer l.Unlock()
+	if e, exists := l.items[key]; exists {
+		if !l.expired(e.Value.(*lruItem)) {
+			return types.ErrKeyAlreadyExists
+		}
+	}
+	err := l.set(key, value)
+	l.updateGauges()
+	return err
+}
+
+// Delete attempts to remove a key.
+func (l *LRU) Delete(key string) error {
+	l.Lock()
+	defer l.Unlock()
+	if e, exists := l.items[key]; exists {
+		l.remove(e)
+		l.updateGauges()
+	}
+	return nil
+}
+
+// CloseAsync shuts down the cache.
+func (l *LRU) CloseAsync() {
+}
+
+// WaitForClose blocks until the cache has closed down.
+func (l *LRU) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func TestLRUCache(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeLRU
+
+	c, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expErr := types.ErrKeyNotFound
+	if _, act := c.Get("foo"); act != expErr {
+		t.Errorf("Wrong error returned: %v != %v", act, expErr)
+	}
+
+	if err = c.Set("foo", []byte("1")); err != nil {
+		t.Error(err)
+	}
+	if act, err := c.Get("foo"); err != nil {
+		t.Error(err)
+	} else if exp := "1"; string(act) != exp {
+		t.Errorf("Wrong result: %v != %v", string(act), exp)
+	}
+
+	expErr = types.ErrKeyAlreadyExists
+	if act := c.Add("foo", []byte("2")); act != expErr {
+		t.Errorf("Wrong error returned: %v != %v", act, expErr)
+	}
+	if err = c.Add("bar", []byte("2")); err != nil {
+		t.Error(err)
+	}
+
+	if err = c.SetMulti(map[string][]byte{
+		"foo": []byte("3"),
+		"baz": []byte("4"),
+	}); err != nil {
+		t.Error(err)
+	}
+	if act, err := c.Get("foo"); err != nil {
+		t.Error(err)
+	} else if exp := "3"; string(act) != exp {
+		t.Errorf("Wrong result: %v != %v", string(act), exp)
+	}
+
+	if err = c.Delete("foo"); err != nil {
+		t.Error(err)
+	}
+	expErr = types.ErrKeyNotFound
+	if _, act := c.Get("foo"); act != expErr {
+		t.Errorf("Wrong error returned: %v != %v", act, expErr)
+	}
+}
+
+func TestLRUCacheEviction(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeLRU
+	conf.LRU.MaxBytes = 20
+
+	stats := metrics.NewLocal()
+	c, err := New(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each item is ten bytes, key included.
+	if err = c.Set("foo", []byte("1234567")); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Set("bar", []byte("1234567")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reading foo makes bar the least recently used.
+	if _, err = c.Get("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Set("baz", []byte("1234567")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = c.Get("bar"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+	for _, k := range []string{"foo", "baz"} {
+		if _, err = c.Get(k); err != nil {
+			t.Errorf("Key %v: %v", k, err)
+		}
+	}
+
+	// Growing a value evicts others to make room.
+	if err = c.Set("baz", []byte("12345678901234")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Get("foo"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+
+	if exp, act := int64(2), stats.GetCounters()["eviction"]; exp != act {
+		t.Errorf("Wrong count of evictions: %v != %v", act, exp)
+	}
+	if exp, act := int64(17), c.(*LRU).current; exp != act {
+		t.Errorf("Wrong count of bytes: %v != %v", act, exp)
+	}
+
+	if err = c.Set("qux", []byte("123456789012345678")); err != ErrLRUItemTooLarge {
+		t.Errorf("Wrong error returned: %v != %v", err, ErrLRUItemTooLarge)
+	}
+	if _, err = c.Get("baz"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeLRU
+
+	c, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := c.(*LRU)
+	l.ttl = 1
+
+	if err = l.Set("foo", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = l.Get("foo"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+
+	if err = l.Set("foo", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Add("foo", []byte("2")); err != nil {
+		t.Errorf("Expected add over expired key to succeed: %v", err)
+	}
+}
+
+func TestLRUCacheBadConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeLRU
+	conf.LRU.MaxBytes = 0
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from zero max_bytes")
+	}
+}
+
+func TestLRUCacheParallel(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeLRU
+	conf.LRU.MaxBytes = 100
+
+	c, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := strconv.Itoa(i*100 + j)
+				c.Set(key, []byte("value"))
+				c.Get(key)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if act := c.(*LRU).current; act > 100 {
+		t.Errorf("Cache exceeded max bytes: %v", act)
+	}
+}
+
+//------------------------------------------------------------------------------
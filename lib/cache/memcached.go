@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 	"time"
@@ -70,6 +71,11 @@ type Memcached struct {
 	mAddFailedErr  metrics.StatCounter
 	mAddSuccess    metrics.StatCounter
 	mAddLatency    metrics.StatTimer
+	mCASCount      metrics.StatCounter
+	mCASConflict   metrics.StatCounter
+	mCASFailedErr  metrics.StatCounter
+	mCASSuccess    metrics.StatCounter
+	mCASLatency    metrics.StatTimer
 	mDelCount      metrics.StatCounter
 	mDelRetry      metrics.StatCounter
 	mDelFailedErr  metrics.StatCounter
@@ -122,6 +128,11 @@ func NewMemcached(
 		mAddFailedErr:  stats.GetCounter("add.failed.error"),
 		mAddSuccess:    stats.GetCounter("add.success"),
 		mAddLatency:    stats.GetTimer("add.latency"),
+		mCASCount:      stats.GetCounter("cas.count"),
+		mCASConflict:   stats.GetCounter("cas.failed.conflict"),
+		mCASFailedErr:  stats.GetCounter("cas.failed.error"),
+		mCASSuccess:    stats.GetCounter("cas.success"),
+		mCASLatency:    stats.GetTimer("cas.latency"),
 		mDelCount:      stats.GetCounter("delete.count"),
 		mDelRetry:      stats.GetCounter("delete.retry"),
 		mDelFailedErr:  stats.GetCounter("delete.failed.error"),
@@ -251,6 +262,47 @@ func (m *Memcached) Add(key string, value []byte) error {
 	return err
 }
 
+// CompareAndSet attempts to set the value of a key only if its current value
+// matches old, where a nil old value matches a key that does not exist, and
+// returns types.ErrCASConflict otherwise or if the key is modified by another
+// client in the meantime.
+func (m *Memcached) CompareAndSet(key string, old, new []byte) error {
+	m.mCASCount.Incr(1)
+	tStarted := time.Now()
+	defer func() {
+		latency := int64(time.Since(tStarted))
+		m.mCASLatency.Timing(latency)
+		m.mLatency.Timing(latency)
+	}()
+
+	var err error
+	if old == nil {
+		err = m.mc.Add(m.getItemFor(key, new))
+	} else {
+		var item *memcache.Item
+		if item, err = m.mc.Get(m.conf.Memcached.Prefix + key); err == nil {
+			if !bytes.Equal(item.Value, old) {
+				err = types.ErrCASConflict
+			} else {
+				item.Value = new
+				item.Expiration = m.conf.Memcached.TTL
+				err = m.mc.CompareAndSwap(item)
+			}
+		}
+	}
+
+	switch err {
+	case nil:
+		m.mCASSuccess.Incr(1)
+	case types.ErrCASConflict, memcache.ErrCASConflict, memcache.ErrNotStored, memcache.ErrCacheMiss:
+		m.mCASConflict.Incr(1)
+		err = types.ErrCASConflict
+	default:
+		m.mCASFailedErr.Incr(1)
+	}
+	return err
+}
+
 // Delete attempts to remove a key.
 func (m *Memcached) Delete(key string) error {
 	m.mDelCount.Incr(1)
@@ -1,7 +1,12 @@
 package cache
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"strconv"
 	"sync"
 	"time"
 
@@ -37,7 +42,34 @@ memory:
 ` + "```" + `
 
 These values can be overridden during execution, at which point the configured
-TTL is respected as usual.`,
+TTL is respected as usual.
+
+The field ` + "`seed_file`" + ` can be used to warm the cache from a file when
+it is created, which prevents a deduplication pipeline from processing recent
+duplicates again after a restart. The file must contain either a JSON object of
+keys to string values, or a JSON array of keys, which are seeded with empty
+values:
+
+` + "```json" + `
+["id-1", "id-2", "id-3"]
+` + "```" + `
+
+Unlike ` + "`init_values`" + ` seeded keys are subject to the configured TTL,
+which begins when the cache is created. Keys that are also set in
+` + "`init_values`" + ` take the value of ` + "`init_values`" + `. A file that
+cannot be read or parsed, or that contains an empty key, fails the creation of
+the cache rather than seeding it partially.
+
+Components that support it, such as the ` + "`cache`" + ` processor, are able to
+override the configured TTL for individual keys as they are set, and to delete
+keys whilst leaving a tombstone for a grace period, during which attempts to add
+the key fail as if it still existed. Setting a key removes its tombstone.
+
+Keys can also be atomically incremented as counters, where values are stored as
+base 10 integer strings and a key that does not exist is created with the value
+of the increment. Incrementing a key resets its TTL.
+
+Multiple keys that are set together are always written atomically.`,
 	}
 }
 
@@ -48,6 +80,7 @@ type MemoryConfig struct {
 	TTL                int               `json:"ttl" yaml:"ttl"`
 	CompactionInterval string            `json:"compaction_interval" yaml:"compaction_interval"`
 	InitValues         map[string]string `json:"init_values" yaml:"init_values"`
+	SeedFile           string            `json:"seed_file" yaml:"seed_file"`
 }
 
 // NewMemoryConfig creates a MemoryConfig populated with default values.
@@ -56,6 +89,7 @@ func NewMemoryConfig() MemoryConfig {
 		TTL:                300, // 5 Mins
 		CompactionInterval: "60s",
 		InitValues:         map[string]string{},
+		SeedFile:           "",
 	}
 }
 
@@ -64,11 +98,13 @@ func NewMemoryConfig() MemoryConfig {
 type item struct {
 	value []byte
 	ts    time.Time
+	ttl   time.Duration
 }
 
 // Memory is a memory based cache implementation.
 type Memory struct {
 	items          map[string]item
+	tombstones     map[string]time.Time
 	ttl            time.Duration
 	compInterval   time.Duration
 	lastCompaction time.Time
@@ -90,6 +126,17 @@ func NewMemory(conf Config, mgr types.Manager, log log.Modular, stats metrics.Ty
 		}
 	}
 	items := map[string]item{}
+	if len(conf.Memory.SeedFile) > 0 {
+		seeded, err := readSeedFile(conf.Memory.SeedFile)
+		if err != nil {
+			return nil, err
+		}
+		tNow := time.Now()
+		for k, v := range seeded {
+			items[k] = item{value: []byte(v), ts: tNow}
+		}
+		log.Infof("Loaded %v keys from seed file: %v\n", len(seeded), conf.Memory.SeedFile)
+	}
 	for k, v := range conf.Memory.InitValues {
 		items[k] = item{
 			value: []byte(v),
@@ -98,6 +145,7 @@ func NewMemory(conf Config, mgr types.Manager, log log.Modular, stats metrics.Ty
 	}
 	return &Memory{
 		items:          items,
+		tombstones:     map[string]time.Time{},
 		ttl:            time.Second * time.Duration(conf.Memory.TTL),
 		compInterval:   interval,
 		lastCompaction: time.Now(),
@@ -107,6 +155,34 @@ func NewMemory(conf Config, mgr types.Manager, log log.Modular, stats metrics.Ty
 	}, nil
 }
 
+// readSeedFile reads the keys and values of a seed file, which is either a JSON
+// object of keys to string values or a JSON array of keys.
+func readSeedFile(path string) (map[string]string, error) {
+	seedBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed file: %v", err)
+	}
+
+	var seeded map[string]string
+	if trimmed := bytes.TrimSpace(seedBytes); len(trimmed) > 0 && trimmed[0] == '[' {
+		var keys []string
+		if err = json.Unmarshal(trimmed, &keys); err != nil {
+			return nil, fmt.Errorf("failed to parse seed file '%v' as an array of keys: %v", path, err)
+		}
+		seeded = make(map[string]string, len(keys))
+		for _, k := range keys {
+			seeded[k] = ""
+		}
+	} else if err = json.Unmarshal(trimmed, &seeded); err != nil {
+		return nil, fmt.Errorf("failed to parse seed file '%v' as an object of keys to values: %v", path, err)
+	}
+
+	if _, exists := seeded[""]; exists {
+		return nil, fmt.Errorf("seed file '%v' contains an empty key", path)
+	}
+	return seeded, nil
+}
+
 //------------------------------------------------------------------------------
 
 func (m *Memory) compaction() {
@@ -118,10 +194,19 @@ func (m *Memory) compaction() {
 		if v.ts.IsZero() {
 			continue
 		}
-		if time.Since(v.ts) >= m.ttl {
+		ttl := m.ttl
+		if v.ttl > 0 {
+			ttl = v.ttl
+		}
+		if time.Since(v.ts) >= ttl {
 			delete(m.items, k)
 		}
 	}
+	for k, expiry := range m.tombstones {
+		if !time.Now().Before(expiry) {
+			delete(m.tombstones, k)
+		}
+	}
 	m.lastCompaction = time.Now()
 }
 
@@ -137,11 +222,42 @@ func (m *Memory) Get(key string) ([]byte, error) {
 	return k.value, nil
 }
 
+// GetWithContext attempts to locate and return a cached value by its key,
+// returns an error if the key does not exist or if the context is cancelled.
+func (m *Memory) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.Get(key)
+}
+
+// SetWithContext attempts to set the value of a key, returns an error if the
+// context is cancelled.
+func (m *Memory) SetWithContext(ctx context.Context, key string, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.Set(key, value)
+}
+
 // Set attempts to set the value of a key.
 func (m *Memory) Set(key string, value []byte) error {
 	m.Lock()
 	m.compaction()
 	m.items[key] = item{value: value, ts: time.Now()}
+	delete(m.tombstones, key)
+	m.mKeys.Set(int64(len(m.items)))
+	m.Unlock()
+	return nil
+}
+
+// SetWithTTL attempts to set the value of a key with a TTL that overrides the
+// default of the cache. A TTL of zero results in the default being used.
+func (m *Memory) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	m.Lock()
+	m.compaction()
+	m.items[key] = item{value: value, ts: time.Now(), ttl: ttl}
+	delete(m.tombstones, key)
 	m.mKeys.Set(int64(len(m.items)))
 	m.Unlock()
 	return nil
@@ -154,17 +270,24 @@ func (m *Memory) SetMulti(items map[string][]byte) error {
 	m.compaction()
 	for k, v := range items {
 		m.items[k] = item{value: v, ts: time.Now()}
+		delete(m.tombstones, k)
 	}
 	m.mKeys.Set(int64(len(m.items)))
 	m.Unlock()
 	return nil
 }
 
+// Transactional returns true as multiple keys are always set under a single
+// lock.
+func (m *Memory) Transactional() bool {
+	return true
+}
+
 // Add attempts to set the value of a key only if the key does not already exist
 // and returns an error if the key already exists.
 func (m *Memory) Add(key string, value []byte) error {
 	m.Lock()
-	if _, exists := m.items[key]; exists {
+	if _, exists := m.items[key]; exists || m.tombstoned(key) {
 		m.Unlock()
 		return types.ErrKeyAlreadyExists
 	}
@@ -175,6 +298,26 @@ func (m *Memory) Add(key string, value []byte) error {
 	return nil
 }
 
+// CompareAndSet attempts to set the value of a key only if its current value
+// matches old, where a nil old value matches a key that does not exist, and
+// returns types.ErrCASConflict otherwise.
+func (m *Memory) CompareAndSet(key string, old, new []byte) error {
+	m.Lock()
+	defer m.Unlock()
+	current, exists := m.items[key]
+	if exists != (old != nil) || (exists && !bytes.Equal(current.value, old)) {
+		return types.ErrCASConflict
+	}
+	if !exists && m.tombstoned(key) {
+		return types.ErrCASConflict
+	}
+	m.compaction()
+	m.items[key] = item{value: new, ts: time.Now()}
+	delete(m.tombstones, key)
+	m.mKeys.Set(int64(len(m.items)))
+	return nil
+}
+
 // Delete attempts to remove a key.
 func (m *Memory) Delete(key string) error {
 	m.Lock()
@@ -185,6 +328,54 @@ func (m *Memory) Delete(key string) error {
 	return nil
 }
 
+// DeleteWithTTL attempts to remove a key and leaves a tombstone for the
+// duration of the grace period, during which attempts to add the key return
+// types.ErrKeyAlreadyExists.
+func (m *Memory) DeleteWithTTL(key string, grace time.Duration) error {
+	m.Lock()
+	m.compaction()
+	delete(m.items, key)
+	if grace > 0 {
+		m.tombstones[key] = time.Now().Add(grace)
+	}
+	m.mKeys.Set(int64(len(m.items)))
+	m.Unlock()
+	return nil
+}
+
+// Increment atomically adds delta to the value of a key and returns the new
+// value, creating the key with the value of delta if it does not exist. Returns
+// types.ErrCounterNotNumeric if the current value is not a base 10 integer.
+func (m *Memory) Increment(key string, delta int64) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	current, exists := m.items[key]
+	value := delta
+	if exists {
+		prev, err := strconv.ParseInt(string(current.value), 10, 64)
+		if err != nil {
+			return 0, types.ErrCounterNotNumeric
+		}
+		value = prev + delta
+	}
+	m.compaction()
+	m.items[key] = item{
+		value: []byte(strconv.FormatInt(value, 10)),
+		ts:    time.Now(),
+		ttl:   current.ttl,
+	}
+	delete(m.tombstones, key)
+	m.mKeys.Set(int64(len(m.items)))
+	return value, nil
+}
+
+// tombstoned returns whether a key was deleted with a grace period that has not
+// yet passed. Must be called whilst holding the lock.
+func (m *Memory) tombstoned(key string) bool {
+	expiry, exists := m.tombstones[key]
+	return exists && time.Now().Before(expiry)
+}
+
 // CloseAsync shuts down the cache.
 func (m *Memory) CloseAsync() {
 }
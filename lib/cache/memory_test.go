@@ -1,8 +1,13 @@
 package cache
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
@@ -74,6 +79,165 @@ func TestMemoryCache(t *testing.T) {
 	}
 }
 
+func TestMemoryCacheCompareAndSet(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = "memory"
+
+	c, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cas, ok := c.(types.CASCache)
+	if !ok {
+		t.Fatal("Expected memory cache to implement CASCache")
+	}
+
+	if err = cas.CompareAndSet("foo", []byte("1"), []byte("2")); err != types.ErrCASConflict {
+		t.Errorf("Wrong error for missing key: %v", err)
+	}
+	if err = cas.CompareAndSet("foo", nil, []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err = cas.CompareAndSet("foo", nil, []byte("2")); err != types.ErrCASConflict {
+		t.Errorf("Wrong error for existing key: %v", err)
+	}
+	if err = cas.CompareAndSet("foo", []byte("2"), []byte("3")); err != types.ErrCASConflict {
+		t.Errorf("Wrong error for mismatched value: %v", err)
+	}
+	if err = cas.CompareAndSet("foo", []byte("1"), []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if act, err := c.Get("foo"); err != nil {
+		t.Error(err)
+	} else if exp := "2"; string(act) != exp {
+		t.Errorf("Wrong result: %s != %v", act, exp)
+	}
+}
+
+func TestMemoryCacheCompareAndSetParallel(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = "memory"
+
+	c, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cas := c.(types.CASCache)
+	if err = c.Set("counter", []byte("0")); err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for {
+					old, err := c.Get("counter")
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					n, _ := strconv.Atoi(string(old))
+					if err = cas.CompareAndSet("counter", old, []byte(strconv.Itoa(n+1))); err == nil {
+						break
+					} else if err != types.ErrCASConflict {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if act, err := c.Get("counter"); err != nil {
+		t.Error(err)
+	} else if exp := "1000"; string(act) != exp {
+		t.Errorf("Wrong result: %s != %v", act, exp)
+	}
+}
+
+func TestMemoryCacheIncrement(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = "memory"
+
+	c, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := c.(types.CounterCache)
+
+	if act, err := counter.Increment("counter", 5); err != nil {
+		t.Fatal(err)
+	} else if act != 5 {
+		t.Errorf("Wrong result of new key: %v != %v", act, 5)
+	}
+	if act, err := counter.Increment("counter", -7); err != nil {
+		t.Fatal(err)
+	} else if act != -2 {
+		t.Errorf("Wrong result: %v != %v", act, -2)
+	}
+	if act, err := c.Get("counter"); err != nil {
+		t.Error(err)
+	} else if exp := "-2"; string(act) != exp {
+		t.Errorf("Wrong stored value: %s != %v", act, exp)
+	}
+
+	if err = c.Set("counter", []byte("nope")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = counter.Increment("counter", 1); err != types.ErrCounterNotNumeric {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrCounterNotNumeric)
+	}
+	if act, err := c.Get("counter"); err != nil {
+		t.Error(err)
+	} else if exp := "nope"; string(act) != exp {
+		t.Errorf("Wrong stored value: %s != %v", act, exp)
+	}
+
+	if err = c.(types.TombstoneCache).DeleteWithTTL("counter", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if act, err := counter.Increment("counter", 1); err != nil {
+		t.Fatal(err)
+	} else if act != 1 {
+		t.Errorf("Wrong result of deleted key: %v != %v", act, 1)
+	}
+}
+
+func TestMemoryCacheIncrementParallel(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = "memory"
+
+	c, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := Increment(c, "counter", 1); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if act, err := c.Get("counter"); err != nil {
+		t.Error(err)
+	} else if exp := "1000"; string(act) != exp {
+		t.Errorf("Wrong result: %s != %v", act, exp)
+	}
+}
+
 func TestMemoryCacheCompaction(t *testing.T) {
 	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
 
@@ -121,6 +285,111 @@ func TestMemoryCacheCompaction(t *testing.T) {
 	}
 }
 
+func TestMemoryCacheSetWithTTL(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = "memory"
+	conf.Memory.TTL = 0
+	conf.Memory.CompactionInterval = ""
+
+	c, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, ok := c.(types.TTLCache)
+	if !ok {
+		t.Fatal("Expected memory cache to support per-key TTLs")
+	}
+	if err = tc.SetWithTTL("foo", []byte("1"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Set("bar", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+
+	// This should trigger compaction.
+	if err = c.Add("baz", []byte("3")); err != nil {
+		t.Fatal(err)
+	}
+
+	if act, err := c.Get("foo"); err != nil {
+		t.Error(err)
+	} else if exp := "1"; string(act) != exp {
+		t.Errorf("Wrong result: %v != %v", string(act), exp)
+	}
+
+	// This key should have been removed with the default TTL.
+	if _, act := c.Get("bar"); act != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", act, types.ErrKeyNotFound)
+	}
+}
+
+func TestMemoryCacheDeleteWithTTL(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = "memory"
+	conf.Memory.CompactionInterval = ""
+
+	c, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc, ok := c.(types.TombstoneCache)
+	if !ok {
+		t.Fatal("Expected memory cache to support tombstones")
+	}
+
+	for _, k := range []string{"foo", "bar", "baz"} {
+		if err = c.Set(k, []byte("1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = tc.DeleteWithTTL("foo", time.Millisecond*50); err != nil {
+		t.Fatal(err)
+	}
+	if err = tc.DeleteWithTTL("bar", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Delete("baz"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, act := c.Get("foo"); act != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", act, types.ErrKeyNotFound)
+	}
+	if act := c.Add("foo", []byte("2")); act != types.ErrKeyAlreadyExists {
+		t.Errorf("Wrong error returned: %v != %v", act, types.ErrKeyAlreadyExists)
+	}
+	if act := c.(types.CASCache).CompareAndSet("foo", nil, []byte("2")); act != types.ErrCASConflict {
+		t.Errorf("Wrong error returned: %v != %v", act, types.ErrCASConflict)
+	}
+	if err = c.Add("baz", []byte("2")); err != nil {
+		t.Errorf("Expected add after plain delete to succeed: %v", err)
+	}
+
+	// Setting a key removes its tombstone.
+	if err = c.Set("bar", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Delete("bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Add("bar", []byte("3")); err != nil {
+		t.Errorf("Expected add after set to succeed: %v", err)
+	}
+
+	<-time.After(time.Millisecond * 100)
+
+	if err = c.Add("foo", []byte("2")); err != nil {
+		t.Errorf("Expected add after grace period to succeed: %v", err)
+	}
+	if act, err := c.Get("foo"); err != nil {
+		t.Error(err)
+	} else if exp := "2"; string(act) != exp {
+		t.Errorf("Wrong result: %v != %v", string(act), exp)
+	}
+}
+
 func TestMemoryCacheInitValues(t *testing.T) {
 	conf := NewConfig()
 	conf.Type = "memory"
@@ -163,4 +432,103 @@ func TestMemoryCacheInitValues(t *testing.T) {
 	}
 }
 
+func TestMemoryCacheSeedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_cache_seed_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeSeed := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	conf := NewConfig()
+	conf.Type = "memory"
+	conf.Memory.TTL = 1
+	conf.Memory.CompactionInterval = ""
+	conf.Memory.SeedFile = writeSeed("object.json", `{"foo":"bar","baz":"buz"}`)
+	conf.Memory.InitValues = map[string]string{
+		"baz": "init",
+	}
+
+	c, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, exp := range map[string]string{"foo": "bar", "baz": "init"} {
+		if act, err := c.Get(k); err != nil {
+			t.Error(err)
+		} else if string(act) != exp {
+			t.Errorf("Wrong result: %v != %v", string(act), exp)
+		}
+	}
+
+	// Seeded keys expire with the TTL, whereas init values do not.
+	<-time.After(time.Millisecond * 1100)
+	if err = c.Set("trigger", []byte("compaction")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Get("foo"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error: %v != %v", err, types.ErrKeyNotFound)
+	}
+	if _, err = c.Get("baz"); err != nil {
+		t.Error(err)
+	}
+
+	conf.Memory.InitValues = map[string]string{}
+	conf.Memory.SeedFile = writeSeed("array.json", `["foo", "bar"]`)
+	if c, err = New(conf, nil, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Add("foo", []byte("new")); err != types.ErrKeyAlreadyExists {
+		t.Errorf("Wrong error: %v != %v", err, types.ErrKeyAlreadyExists)
+	}
+	if act, err := c.Get("bar"); err != nil {
+		t.Error(err)
+	} else if len(act) != 0 {
+		t.Errorf("Wrong result: %s", act)
+	}
+}
+
+func TestMemoryCacheSeedFileBad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_cache_seed_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		"truncated.json":  `{"foo":"bar","baz":`,
+		"not_string.json": `{"foo":10}`,
+		"bad_array.json":  `["foo", 10]`,
+		"empty_key.json":  `["foo", ""]`,
+		"not_json.txt":    `foo`,
+	} {
+		path := filepath.Join(dir, name)
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		conf := NewConfig()
+		conf.Type = "memory"
+		conf.Memory.SeedFile = path
+		if _, err = New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("Expected error from seed file %v", name)
+		}
+	}
+
+	conf := NewConfig()
+	conf.Type = "memory"
+	conf.Memory.SeedFile = filepath.Join(dir, "does_not_exist.json")
+	if _, err = New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing seed file")
+	}
+}
+
 //------------------------------------------------------------------------------
@@ -0,0 +1,220 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// WithMetrics wraps a cache with counters of the outcome of each operation,
+// namespaced by the label of the cache within stats as
+// `cache.<label>.<operation>.<outcome>`. A get of a key that does not exist is
+// counted as `not_found` and an add of a key that already exists as
+// `already_exists` rather than as errors.
+//
+// The returned cache implements types.CASCache only when the wrapped cache
+// does. Calls to SetWithTTL return types.ErrTTLNotSupported when the wrapped
+// cache does not implement types.TTLCache, and calls to DeleteWithTTL return
+// types.ErrTombstoneNotSupported when it does not implement
+// types.TombstoneCache. Likewise calls to Increment return
+// types.ErrCounterNotSupported when it does not implement types.CounterCache.
+func WithMetrics(label string, c types.Cache, stats metrics.Type) types.Cache {
+	nsStats := metrics.Namespaced(stats, "cache."+label)
+	m := &metricsCache{
+		wrapped: c,
+
+		mGetSuccess:       nsStats.GetCounter("get.success"),
+		mGetNotFound:      nsStats.GetCounter("get.not_found"),
+		mGetErr:           nsStats.GetCounter("get.error"),
+		mSetSuccess:       nsStats.GetCounter("set.success"),
+		mSetErr:           nsStats.GetCounter("set.error"),
+		mSetMultiSuccess:  nsStats.GetCounter("set_multi.success"),
+		mSetMultiErr:      nsStats.GetCounter("set_multi.error"),
+		mAddSuccess:       nsStats.GetCounter("add.success"),
+		mAddAlreadyExists: nsStats.GetCounter("add.already_exists"),
+		mAddErr:           nsStats.GetCounter("add.error"),
+		mDelSuccess:       nsStats.GetCounter("delete.success"),
+		mDelErr:           nsStats.GetCounter("delete.error"),
+		mIncrSuccess:      nsStats.GetCounter("increment.success"),
+		mIncrErr:          nsStats.GetCounter("increment.error"),
+	}
+	if cas, ok := c.(types.CASCache); ok {
+		return &metricsCASCache{
+			metricsCache: m,
+			wrapped:      cas,
+
+			mCASSuccess:  nsStats.GetCounter("compare_and_set.success"),
+			mCASConflict: nsStats.GetCounter("compare_and_set.conflict"),
+			mCASErr:      nsStats.GetCounter("compare_and_set.error"),
+		}
+	}
+	return m
+}
+
+//------------------------------------------------------------------------------
+
+type metricsCache struct {
+	wrapped types.Cache
+
+	mGetSuccess       metrics.StatCounter
+	mGetNotFound      metrics.StatCounter
+	mGetErr           metrics.StatCounter
+	mSetSuccess       metrics.StatCounter
+	mSetErr           metrics.StatCounter
+	mSetMultiSuccess  metrics.StatCounter
+	mSetMultiErr      metrics.StatCounter
+	mAddSuccess       metrics.StatCounter
+	mAddAlreadyExists metrics.StatCounter
+	mAddErr           metrics.StatCounter
+	mDelSuccess       metrics.StatCounter
+	mDelErr           metrics.StatCounter
+	mIncrSuccess      metrics.StatCounter
+	mIncrErr          metrics.StatCounter
+}
+
+func (m *metricsCache) countGet(err error) {
+	switch err {
+	case nil:
+		m.mGetSuccess.Incr(1)
+	case types.ErrKeyNotFound:
+		m.mGetNotFound.Incr(1)
+	default:
+		m.mGetErr.Incr(1)
+	}
+}
+
+func (m *metricsCache) countSet(err error) {
+	if err != nil {
+		m.mSetErr.Incr(1)
+	} else {
+		m.mSetSuccess.Incr(1)
+	}
+}
+
+func (m *metricsCache) Get(key string) ([]byte, error) {
+	value, err := m.wrapped.Get(key)
+	m.countGet(err)
+	return value, err
+}
+
+func (m *metricsCache) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	value, err := GetWithContext(ctx, m.wrapped, key)
+	m.countGet(err)
+	return value, err
+}
+
+func (m *metricsCache) GetStream(key string) (io.ReadCloser, error) {
+	r, err := GetStream(m.wrapped, key)
+	m.countGet(err)
+	return r, err
+}
+
+func (m *metricsCache) Set(key string, value []byte) error {
+	err := m.wrapped.Set(key, value)
+	m.countSet(err)
+	return err
+}
+
+func (m *metricsCache) SetWithContext(ctx context.Context, key string, value []byte) error {
+	err := SetWithContext(ctx, m.wrapped, key, value)
+	m.countSet(err)
+	return err
+}
+
+func (m *metricsCache) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	err := SetWithTTL(m.wrapped, key, value, ttl)
+	m.countSet(err)
+	return err
+}
+
+func (m *metricsCache) SetMulti(items map[string][]byte) error {
+	err := m.wrapped.SetMulti(items)
+	if err != nil {
+		m.mSetMultiErr.Incr(1)
+	} else {
+		m.mSetMultiSuccess.Incr(1)
+	}
+	return err
+}
+
+func (m *metricsCache) Add(key string, value []byte) error {
+	err := m.wrapped.Add(key, value)
+	switch err {
+	case nil:
+		m.mAddSuccess.Incr(1)
+	case types.ErrKeyAlreadyExists:
+		m.mAddAlreadyExists.Incr(1)
+	default:
+		m.mAddErr.Incr(1)
+	}
+	return err
+}
+
+func (m *metricsCache) Delete(key string) error {
+	err := m.wrapped.Delete(key)
+	m.countDelete(err)
+	return err
+}
+
+func (m *metricsCache) DeleteWithTTL(key string, grace time.Duration) error {
+	err := DeleteWithTTL(m.wrapped, key, grace)
+	m.countDelete(err)
+	return err
+}
+
+func (m *metricsCache) Increment(key string, delta int64) (int64, error) {
+	value, err := Increment(m.wrapped, key, delta)
+	if err != nil {
+		m.mIncrErr.Incr(1)
+	} else {
+		m.mIncrSuccess.Incr(1)
+	}
+	return value, err
+}
+
+func (m *metricsCache) countDelete(err error) {
+	if err != nil {
+		m.mDelErr.Incr(1)
+	} else {
+		m.mDelSuccess.Incr(1)
+	}
+}
+
+func (m *metricsCache) CloseAsync() {
+	m.wrapped.CloseAsync()
+}
+
+func (m *metricsCache) WaitForClose(timeout time.Duration) error {
+	return m.wrapped.WaitForClose(timeout)
+}
+
+//------------------------------------------------------------------------------
+
+type metricsCASCache struct {
+	*metricsCache
+	wrapped types.CASCache
+
+	mCASSuccess  metrics.StatCounter
+	mCASConflict metrics.StatCounter
+	mCASErr      metrics.StatCounter
+}
+
+func (m *metricsCASCache) CompareAndSet(key string, old, new []byte) error {
+	err := m.wrapped.CompareAndSet(key, old, new)
+	switch err {
+	case nil:
+		m.mCASSuccess.Incr(1)
+	case types.ErrCASConflict:
+		m.mCASConflict.Incr(1)
+	default:
+		m.mCASErr.Incr(1)
+	}
+	return err
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func TestCacheWithMetrics(t *testing.T) {
+	stats := metrics.NewLocal()
+	flaky := &flakyCache{Cache: newTestMemory(t)}
+	c := WithMetrics("foo", flaky, stats)
+
+	if _, err := c.Get("bar"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+	if err := c.Set("bar", []byte("baz")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Add("bar", []byte("baz")); err != types.ErrKeyAlreadyExists {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyAlreadyExists)
+	}
+	if err := c.SetMulti(map[string][]byte{"qux": []byte("quz")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete("qux"); err != nil {
+		t.Fatal(err)
+	}
+
+	flaky.failures = 3
+	if _, err := c.Get("bar"); err == nil {
+		t.Error("Expected error")
+	}
+	if err := c.Set("bar", []byte("baz")); err == nil {
+		t.Error("Expected error")
+	}
+	if err := c.Add("baz", []byte("baz")); err == nil {
+		t.Error("Expected error")
+	}
+
+	exp := map[string]int64{
+		"cache.foo.get.success":        1,
+		"cache.foo.get.not_found":      1,
+		"cache.foo.get.error":          1,
+		"cache.foo.set.success":        1,
+		"cache.foo.set.error":          1,
+		"cache.foo.set_multi.success":  1,
+		"cache.foo.set_multi.error":    0,
+		"cache.foo.add.success":        0,
+		"cache.foo.add.already_exists": 1,
+		"cache.foo.add.error":          1,
+		"cache.foo.delete.success":     1,
+		"cache.foo.delete.error":       0,
+	}
+	counters := stats.GetCounters()
+	for k, v := range exp {
+		if act := counters[k]; act != v {
+			t.Errorf("Wrong count of %v: %v != %v", k, act, v)
+		}
+	}
+}
+
+func TestCacheWithMetricsCAS(t *testing.T) {
+	stats := metrics.NewLocal()
+	c := WithMetrics("foo", newTestMemory(t), stats)
+
+	cas, ok := c.(types.CASCache)
+	if !ok {
+		t.Fatal("Expected wrapper of memory cache to support compare and set")
+	}
+	if err := cas.CompareAndSet("bar", nil, []byte("baz")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cas.CompareAndSet("bar", []byte("nope"), []byte("buz")); err != types.ErrCASConflict {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrCASConflict)
+	}
+
+	counters := stats.GetCounters()
+	for k, v := range map[string]int64{
+		"cache.foo.compare_and_set.success":  1,
+		"cache.foo.compare_and_set.conflict": 1,
+	} {
+		if act := counters[k]; act != v {
+			t.Errorf("Wrong count of %v: %v != %v", k, act, v)
+		}
+	}
+
+	c = WithMetrics("foo", struct{ types.Cache }{newTestMemory(t)}, metrics.Noop())
+	if _, ok = c.(types.CASCache); ok {
+		t.Error("Expected wrapper to not support compare and set")
+	}
+}
+
+func TestCacheWithMetricsTTL(t *testing.T) {
+	stats := metrics.NewLocal()
+	c := WithMetrics("foo", newTestMemory(t), stats)
+
+	if err := SetWithTTL(c, "bar", []byte("baz"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if act := stats.GetCounters()["cache.foo.set.success"]; act != 1 {
+		t.Errorf("Wrong count of cache.foo.set.success: %v != %v", act, 1)
+	}
+
+	c = WithMetrics("foo", struct{ types.Cache }{newTestMemory(t)}, metrics.Noop())
+	if err := SetWithTTL(c, "bar", []byte("baz"), time.Hour); err != types.ErrTTLNotSupported {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrTTLNotSupported)
+	}
+}
+
+func TestCacheWithMetricsIncrement(t *testing.T) {
+	stats := metrics.NewLocal()
+	c := WithMetrics("foo", newTestMemory(t), stats)
+
+	if act, err := Increment(c, "bar", 2); err != nil {
+		t.Fatal(err)
+	} else if act != 2 {
+		t.Errorf("Wrong result: %v != %v", act, 2)
+	}
+	if act := stats.GetCounters()["cache.foo.increment.success"]; act != 1 {
+		t.Errorf("Wrong count of cache.foo.increment.success: %v != %v", act, 1)
+	}
+
+	c = WithMetrics("foo", struct{ types.Cache }{newTestMemory(t)}, metrics.Noop())
+	if _, err := Increment(c, "bar", 1); err != types.ErrCounterNotSupported {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrCounterNotSupported)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func BenchmarkCacheGet(b *testing.B) {
+	conf := NewConfig()
+	c, err := NewMemory(conf, nil, nil, metrics.Noop())
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err = c.Set("foo", []byte("bar")); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err = c.Get("foo"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCacheGetWithMetrics(b *testing.B) {
+	conf := NewConfig()
+	c, err := NewMemory(conf, nil, nil, metrics.Noop())
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err = c.Set("foo", []byte("bar")); err != nil {
+		b.Fatal(err)
+	}
+	c = WithMetrics("foo", c, metrics.NewLocal())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err = c.Get("foo"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
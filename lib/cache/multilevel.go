@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeMultilevel] = TypeSpec{
+		constructor: NewMultilevel,
+		Description: `
+Combines an ordered list of two or more child caches into tiers, where the first
+cache is typically small and fast and the following caches are slower but more
+authoritative.
+
+A get checks each tier in order and returns the value of the first hit. When a
+value is found in a lower tier it is written back to each of the tiers above it,
+this write back is best effort and a failure does not fail the read. A key is
+only reported as missing once all tiers have missed.
+
+Sets, adds and deletes are applied to all tiers. An add is attempted on the last
+tier first, and only once it succeeds is the value set in the tiers above it.
+
+` + "``` yaml" + `
+resources:
+  caches:
+    foocache:
+      multilevel:
+        - memory:
+            ttl: 60
+        - redis:
+            url: tcp://localhost:6379
+` + "```" + ``,
+		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
+			tiers := []interface{}{}
+			for _, tier := range conf.Multilevel {
+				sanit, err := SanitiseConfig(tier)
+				if err != nil {
+					return nil, err
+				}
+				tiers = append(tiers, sanit)
+			}
+			return tiers, nil
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// MultilevelConfig is a list of cache configs that make up the tiers of a
+// Multilevel cache, from fastest to most authoritative.
+type MultilevelConfig []Config
+
+// NewMultilevelConfig creates a MultilevelConfig with default values.
+func NewMultilevelConfig() MultilevelConfig {
+	return MultilevelConfig{}
+}
+
+//------------------------------------------------------------------------------
+
+// Multilevel is a cache that reads through an ordered list of child caches and
+// writes to all of them.
+type Multilevel struct {
+	tiers []types.Cache
+
+	log log.Modular
+
+	mWriteBackErr metrics.StatCounter
+}
+
+// NewMultilevel creates a new Multilevel cache type.
+func NewMultilevel(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (types.Cache, error) {
+	if len(conf.Multilevel) < 2 {
+		return nil, errors.New("at least two tiers must be specified")
+	}
+
+	tiers := make([]types.Cache, len(conf.Multilevel))
+	for i, tConf := range conf.Multilevel {
+		ns := "multilevel." + strconv.Itoa(i)
+		var err error
+		if tiers[i], err = New(tConf, mgr, log.NewModule("."+ns), metrics.Namespaced(stats, ns)); err != nil {
+			return nil, fmt.Errorf("failed to create tier '%v': %v", i, err)
+		}
+	}
+
+	return &Multilevel{
+		tiers:         tiers,
+		log:           log,
+		mWriteBackErr: stats.GetCounter("multilevel.write_back.error"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Get attempts to locate and return a cached value by its key, checking each
+// tier in order and writing a value found in a lower tier back to the tiers
+// above it.
+func (m *Multilevel) Get(key string) ([]byte, error) {
+	for i, tier := range m.tiers {
+		value, err := tier.Get(key)
+		if err == types.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for j := i - 1; j >= 0; j-- {
+			if serr := m.tiers[j].Set(key, value); serr != nil {
+				m.mWriteBackErr.Incr(1)
+				m.log.Debugf("Failed to write key '%v' back to tier %v: %v\n", key, j, serr)
+			}
+		}
+		return value, nil
+	}
+	return nil, types.ErrKeyNotFound
+}
+
+// Set attempts to set the value of a key in all tiers.
+func (m *Multilevel) Set(key string, value []byte) error {
+	for i, tier := range m.tiers {
+		if err := tier.Set(key, value); err != nil {
+			return fmt.Errorf("tier %v: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// SetMulti attempts to set the value of multiple keys in all tiers, returns an
+// error if any keys fail.
+func (m *Multilevel) SetMulti(items map[string][]byte) error {
+	for i, tier := range m.tiers {
+		if err := tier.SetMulti(items); err != nil {
+			return fmt.Errorf("tier %v: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Add attempts to set the value of a key only if the key does not already exist
+// in the last tier, and returns an error if the key already exists or if the
+// operation fails.
+func (m *Multilevel) Add(key string, value []byte) error {
+	last := len(m.tiers) - 1
+	if err := m.tiers[last].Add(key, value); err != nil {
+		return err
+	}
+	for i := last - 1; i >= 0; i-- {
+		if err := m.tiers[i].Set(key, value); err != nil {
+			return fmt.Errorf("tier %v: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Delete attempts to remove a key from all tiers.
+func (m *Multilevel) Delete(key string) error {
+	for i, tier := range m.tiers {
+		if err := tier.Delete(key); err != nil && err != types.ErrKeyNotFound {
+			return fmt.Errorf("tier %v: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// CloseAsync shuts down the cache.
+func (m *Multilevel) CloseAsync() {
+	for _, tier := range m.tiers {
+		tier.CloseAsync()
+	}
+}
+
+// WaitForClose blocks until the cache has closed down.
+func (m *Multilevel) WaitForClose(timeout time.Duration) error {
+	tStop := time.Now().Add(timeout)
+	for _, tier := range m.tiers {
+		if err := tier.WaitForClose(time.Until(tStop)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
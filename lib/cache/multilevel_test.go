@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+type brokenSetCache struct {
+	types.Cache
+}
+
+func (b brokenSetCache) Set(key string, value []byte) error {
+	return errors.New("nope")
+}
+
+func newTestMultilevel(t *testing.T, tiers ...types.Cache) *Multilevel {
+	t.Helper()
+
+	return &Multilevel{
+		tiers:         tiers,
+		log:           log.Noop(),
+		mWriteBackErr: metrics.DudType{}.GetCounter("foo"),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func TestMultilevelReadThrough(t *testing.T) {
+	fast, slow := newTestMemory(t), newTestMemory(t)
+	c := newTestMultilevel(t, fast, slow)
+
+	if _, err := c.Get("foo"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+
+	if err := slow.Set("foo", []byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+
+	act, err := c.Get("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "bar"; string(act) != exp {
+		t.Errorf("Wrong result: %s != %v", act, exp)
+	}
+
+	if act, err = fast.Get("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if exp := "bar"; string(act) != exp {
+		t.Errorf("Wrong written back result: %s != %v", act, exp)
+	}
+}
+
+func TestMultilevelWriteBackBestEffort(t *testing.T) {
+	slow := newTestMemory(t)
+	c := newTestMultilevel(t, brokenSetCache{Cache: newTestMemory(t)}, slow)
+
+	if err := slow.Set("foo", []byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+
+	act, err := c.Get("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "bar"; string(act) != exp {
+		t.Errorf("Wrong result: %s != %v", act, exp)
+	}
+}
+
+func TestMultilevelFanOut(t *testing.T) {
+	fast, slow := newTestMemory(t), newTestMemory(t)
+	c := newTestMultilevel(t, fast, slow)
+
+	if err := c.Set("foo", []byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetMulti(map[string][]byte{"baz": []byte("qux")}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tier := range []types.Cache{fast, slow} {
+		for k, exp := range map[string]string{"foo": "bar", "baz": "qux"} {
+			if act, err := tier.Get(k); err != nil {
+				t.Error(err)
+			} else if string(act) != exp {
+				t.Errorf("Wrong result: %s != %v", act, exp)
+			}
+		}
+	}
+
+	if err := c.Add("foo", []byte("nope")); err != types.ErrKeyAlreadyExists {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyAlreadyExists)
+	}
+	if err := c.Add("quz", []byte("quack")); err != nil {
+		t.Fatal(err)
+	}
+	if act, err := fast.Get("quz"); err != nil {
+		t.Error(err)
+	} else if exp := "quack"; string(act) != exp {
+		t.Errorf("Wrong result: %s != %v", act, exp)
+	}
+
+	if err := c.Delete("foo"); err != nil {
+		t.Fatal(err)
+	}
+	for _, tier := range []types.Cache{fast, slow} {
+		if _, err := tier.Get("foo"); err != types.ErrKeyNotFound {
+			t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+		}
+	}
+}
+
+func TestMultilevelConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeMultilevel
+
+	tier := NewConfig()
+	tier.Type = TypeMemory
+	conf.Multilevel = append(conf.Multilevel, tier)
+
+	if _, err := New(conf, nil, log.Noop(), metrics.DudType{}); err == nil {
+		t.Error("Expected error from single tier")
+	}
+
+	conf.Multilevel = append(conf.Multilevel, tier)
+	c, err := New(conf, nil, log.Noop(), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Set("foo", []byte("bar")); err != nil {
+		t.Error(err)
+	}
+
+	c.CloseAsync()
+	if err = c.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,221 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/x/docs"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeNamespaced] = TypeSpec{
+		constructor: NewNamespaced,
+		Description: `
+Wraps a child cache and prepends a prefix to every key of its operations, which
+isolates the keys of logically separate pipelines that share the same cache
+backend.
+
+` + "``` yaml" + `
+resources:
+  caches:
+    foocache:
+      namespaced:
+        prefix: "pipeline_a:"
+        cache:
+          redis:
+            url: tcp://localhost:6379
+` + "```" + `
+
+The prefix is added to keys before they reach the child cache and is never
+visible to the components using the cache.`,
+		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
+			var cacheSanit interface{} = struct{}{}
+			if conf.Namespaced.Cache != nil {
+				var err error
+				if cacheSanit, err = SanitiseConfig(*conf.Namespaced.Cache); err != nil {
+					return nil, err
+				}
+			}
+			return map[string]interface{}{
+				"prefix": conf.Namespaced.Prefix,
+				"cache":  cacheSanit,
+			}, nil
+		},
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("prefix", "A string to prepend to all keys."),
+			docs.FieldCommon("cache", "The child cache to wrap."),
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NamespacedConfig contains configuration values for the Namespaced cache
+// type.
+type NamespacedConfig struct {
+	Prefix string  `json:"prefix" yaml:"prefix"`
+	Cache  *Config `json:"cache" yaml:"cache"`
+}
+
+// NewNamespacedConfig creates a new NamespacedConfig with default values.
+func NewNamespacedConfig() NamespacedConfig {
+	return NamespacedConfig{
+		Prefix: "",
+		Cache:  nil,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type dummyNamespacedConfig struct {
+	Prefix string      `json:"prefix" yaml:"prefix"`
+	Cache  interface{} `json:"cache" yaml:"cache"`
+}
+
+// MarshalJSON prints an empty object instead of nil.
+func (n NamespacedConfig) MarshalJSON() ([]byte, error) {
+	dummy := dummyNamespacedConfig{
+		Prefix: n.Prefix,
+		Cache:  n.Cache,
+	}
+	if n.Cache == nil {
+		dummy.Cache = struct{}{}
+	}
+	return json.Marshal(dummy)
+}
+
+// MarshalYAML prints an empty object instead of nil.
+func (n NamespacedConfig) MarshalYAML() (interface{}, error) {
+	dummy := dummyNamespacedConfig{
+		Prefix: n.Prefix,
+		Cache:  n.Cache,
+	}
+	if n.Cache == nil {
+		dummy.Cache = struct{}{}
+	}
+	return dummy, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Namespaced is a cache that wraps a child cache and prepends a prefix to all
+// keys.
+type Namespaced struct {
+	prefix  string
+	wrapped types.Cache
+}
+
+// NewNamespaced creates a new Namespaced cache type.
+func NewNamespaced(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (types.Cache, error) {
+	if conf.Namespaced.Cache == nil {
+		return nil, errors.New("cannot create namespaced cache without a child")
+	}
+	if len(conf.Namespaced.Prefix) == 0 {
+		return nil, errors.New("cannot create namespaced cache without a prefix")
+	}
+
+	wrapped, err := New(*conf.Namespaced.Cache, mgr, log, metrics.Namespaced(stats, "namespaced"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache '%v': %v", conf.Namespaced.Cache.Type, err)
+	}
+	return newNamespaced(conf.Namespaced.Prefix, wrapped), nil
+}
+
+func newNamespaced(prefix string, wrapped types.Cache) *Namespaced {
+	return &Namespaced{
+		prefix:  prefix,
+		wrapped: wrapped,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Get attempts to locate and return a cached value by its key, returns an error
+// if the key does not exist or if the operation failed.
+func (n *Namespaced) Get(key string) ([]byte, error) {
+	return n.wrapped.Get(n.prefix + key)
+}
+
+// GetWithContext attempts to locate and return a cached value by its key,
+// passing the context to the child cache.
+func (n *Namespaced) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	return GetWithContext(ctx, n.wrapped, n.prefix+key)
+}
+
+// Set attempts to set the value of a key.
+func (n *Namespaced) Set(key string, value []byte) error {
+	return n.wrapped.Set(n.prefix+key, value)
+}
+
+// SetWithContext attempts to set the value of a key, passing the context to
+// the child cache.
+func (n *Namespaced) SetWithContext(ctx context.Context, key string, value []byte) error {
+	return SetWithContext(ctx, n.wrapped, n.prefix+key, value)
+}
+
+// Increment attempts to atomically add delta to the value of a key in the
+// child cache and returns the new value.
+func (n *Namespaced) Increment(key string, delta int64) (int64, error) {
+	return Increment(n.wrapped, n.prefix+key, delta)
+}
+
+// CompareAndSet attempts to set the value of a key in the child cache only if
+// its current value matches old.
+func (n *Namespaced) CompareAndSet(key string, old, new []byte) error {
+	return CompareAndSet(n.wrapped, n.prefix+key, old, new)
+}
+
+// SetWithTTL attempts to set the value of a key with a TTL that overrides the
+// default of the child cache.
+func (n *Namespaced) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	return SetWithTTL(n.wrapped, n.prefix+key, value, ttl)
+}
+
+// DeleteWithTTL attempts to remove a key and leave a tombstone in the child
+// cache for the duration of the grace period.
+func (n *Namespaced) DeleteWithTTL(key string, grace time.Duration) error {
+	return DeleteWithTTL(n.wrapped, n.prefix+key, grace)
+}
+
+// SetMulti attempts to set the value of multiple keys, returns an error if any
+// keys fail.
+func (n *Namespaced) SetMulti(items map[string][]byte) error {
+	prefixed := make(map[string][]byte, len(items))
+	for k, v := range items {
+		prefixed[n.prefix+k] = v
+	}
+	return n.wrapped.SetMulti(prefixed)
+}
+
+// Add attempts to set the value of a key only if the key does not already exist
+// and returns an error if the key already exists or if the operation fails.
+func (n *Namespaced) Add(key string, value []byte) error {
+	return n.wrapped.Add(n.prefix+key, value)
+}
+
+// Delete attempts to remove a key.
+func (n *Namespaced) Delete(key string) error {
+	return n.wrapped.Delete(n.prefix + key)
+}
+
+// CloseAsync shuts down the cache.
+func (n *Namespaced) CloseAsync() {
+	n.wrapped.CloseAsync()
+}
+
+// WaitForClose blocks until the cache has closed down.
+func (n *Namespaced) WaitForClose(timeout time.Duration) error {
+	return n.wrapped.WaitForClose(timeout)
+}
+
+//------------------------------------------------------------------------------
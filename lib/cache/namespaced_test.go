@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/config"
+)
+
+//------------------------------------------------------------------------------
+
+func TestNamespacedCacheIsolation(t *testing.T) {
+	memConf := NewConfig()
+	memConf.Type = TypeMemory
+	backing, err := New(memConf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := newNamespaced("a:", backing)
+	b := newNamespaced("b:", backing)
+
+	if err = a.Set("foo", []byte("from a")); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Set("foo", []byte("from b")); err != nil {
+		t.Fatal(err)
+	}
+	if err = a.SetMulti(map[string][]byte{"bar": []byte("bar a")}); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Add("bar", []byte("bar b")); err != nil {
+		t.Errorf("Expected add to be isolated from other namespace: %v", err)
+	}
+
+	for _, test := range []struct {
+		cache types.Cache
+		key   string
+		exp   string
+	}{
+		{a, "foo", "from a"},
+		{b, "foo", "from b"},
+		{a, "bar", "bar a"},
+		{b, "bar", "bar b"},
+		{backing, "a:foo", "from a"},
+		{backing, "b:bar", "bar b"},
+	} {
+		act, err := test.cache.Get(test.key)
+		if err != nil {
+			t.Errorf("Key %v: %v", test.key, err)
+		} else if string(act) != test.exp {
+			t.Errorf("Wrong result of key %v: %s != %v", test.key, act, test.exp)
+		}
+	}
+
+	if _, err = backing.Get("foo"); err != types.ErrKeyNotFound {
+		t.Errorf("Expected unprefixed key to be missing: %v", err)
+	}
+
+	if err = a.Delete("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.Get("foo"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+	if act, err := b.Get("foo"); err != nil || string(act) != "from b" {
+		t.Errorf("Delete leaked into other namespace: %s, %v", act, err)
+	}
+}
+
+func TestNamespacedCacheCompareAndSet(t *testing.T) {
+	memConf := NewConfig()
+	memConf.Type = TypeMemory
+	backing, err := New(memConf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := newNamespaced("a:", backing)
+	b := newNamespaced("b:", backing)
+
+	if err = a.CompareAndSet("foo", nil, []byte("from a")); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.CompareAndSet("foo", nil, []byte("from b")); err != nil {
+		t.Errorf("Expected compare and set to be isolated from other namespace: %v", err)
+	}
+	if err = a.CompareAndSet("foo", []byte("from b"), []byte("bar")); err != types.ErrCASConflict {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrCASConflict)
+	}
+	if err = a.CompareAndSet("foo", []byte("from a"), []byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+	if act, err := backing.Get("a:foo"); err != nil || string(act) != "bar" {
+		t.Errorf("Wrong result of prefixed key: %s, %v", act, err)
+	}
+
+	unsupported := newNamespaced("a:", struct{ types.Cache }{backing})
+	if err = unsupported.CompareAndSet("foo", nil, []byte("bar")); err != types.ErrCASNotSupported {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrCASNotSupported)
+	}
+}
+
+func TestNamespacedCacheConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeNamespaced
+	conf.Namespaced.Prefix = "foo:"
+
+	if _, err := New(conf, nil, log.Noop(), metrics.DudType{}); err == nil {
+		t.Error("Expected error from missing child")
+	}
+
+	child := NewConfig()
+	child.Type = TypeMemory
+	conf.Namespaced.Cache = &child
+
+	c, err := New(conf, nil, log.Noop(), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Set("bar", []byte("baz")); err != nil {
+		t.Error(err)
+	}
+	if act, err := c.(*Namespaced).wrapped.Get("foo:bar"); err != nil || string(act) != "baz" {
+		t.Errorf("Expected prefixed key in child: %s, %v", act, err)
+	}
+
+	conf.Namespaced.Prefix = ""
+	if _, err = New(conf, nil, log.Noop(), metrics.DudType{}); err == nil {
+		t.Error("Expected error from missing prefix")
+	}
+
+	sanit, err := SanitiseConfig(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nsSanit := sanit.(config.Sanitised)["namespaced"].(map[string]interface{})
+	if _, exists := nsSanit["cache"].(config.Sanitised)["memory"]; !exists {
+		t.Errorf("Child cache missing from sanitised config: %v", nsSanit)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -1,13 +1,16 @@
 package cache
 
 import (
+	"context"
 	"fmt"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	bredis "github.com/Jeffail/benthos/v3/lib/util/redis"
 	"github.com/go-redis/redis"
 )
 
@@ -18,7 +21,11 @@ func init() {
 		constructor: NewRedis,
 		Description: `
 Use a Redis instance as a cache. The expiration can be set to zero or an empty
-string in order to set no expiration.`,
+string in order to set no expiration.
+
+When ` + "`transactional`" + ` is set to ` + "`true`" + ` multiple keys that are
+set together are written within a MULTI/EXEC transaction, and therefore either
+all of them are written or none of them are.`,
 	}
 }
 
@@ -31,6 +38,10 @@ type RedisConfig struct {
 	Expiration  string `json:"expiration" yaml:"expiration"`
 	Retries     int    `json:"retries" yaml:"retries"`
 	RetryPeriod string `json:"retry_period" yaml:"retry_period"`
+
+	Transactional bool `json:"transactional" yaml:"transactional"`
+
+	bredis.PoolConfig `json:",inline" yaml:",inline"`
 }
 
 // NewRedisConfig returns a RedisConfig with default values.
@@ -41,6 +52,10 @@ func NewRedisConfig() RedisConfig {
 		Expiration:  "24h",
 		Retries:     3,
 		RetryPeriod: "500ms",
+
+		Transactional: false,
+
+		PoolConfig: bredis.NewPoolConfig(),
 	}
 }
 
@@ -78,6 +93,12 @@ type Redis struct {
 	mDelSuccess    metrics.StatCounter
 	mDelLatency    metrics.StatTimer
 
+	mSetMultiCount   metrics.StatCounter
+	mSetMultiRetry   metrics.StatCounter
+	mSetMultiFailed  metrics.StatCounter
+	mSetMultiSuccess metrics.StatCounter
+	mSetMultiLatency metrics.StatTimer
+
 	client      *redis.Client
 	ttl         time.Duration
 	prefix      string
@@ -109,15 +130,10 @@ func NewRedis(
 		return nil, err
 	}
 
-	var pass string
-	if url.User != nil {
-		pass, _ = url.User.Password()
+	client, err := conf.Redis.PoolConfig.Client(url)
+	if err != nil {
+		return nil, err
 	}
-	client := redis.NewClient(&redis.Options{
-		Addr:     url.Host,
-		Network:  url.Scheme,
-		Password: pass,
-	})
 
 	return &Redis{
 		conf:  conf,
@@ -150,6 +166,12 @@ func NewRedis(
 		mDelSuccess:    stats.GetCounter("delete.success"),
 		mDelLatency:    stats.GetTimer("delete.latency"),
 
+		mSetMultiCount:   stats.GetCounter("set_multi.count"),
+		mSetMultiRetry:   stats.GetCounter("set_multi.retry"),
+		mSetMultiFailed:  stats.GetCounter("set_multi.failed.error"),
+		mSetMultiSuccess: stats.GetCounter("set_multi.success"),
+		mSetMultiLatency: stats.GetTimer("set_multi.latency"),
+
 		retryPeriod: retryPeriod,
 		ttl:         ttl,
 		prefix:      conf.Redis.Prefix,
@@ -162,6 +184,13 @@ func NewRedis(
 // Get attempts to locate and return a cached value by its key, returns an error
 // if the key does not exist or if the operation failed.
 func (r *Redis) Get(key string) ([]byte, error) {
+	return r.GetWithContext(context.Background(), key)
+}
+
+// GetWithContext attempts to locate and return a cached value by its key,
+// returns an error if the key does not exist, if the operation failed or if the
+// context is cancelled before it succeeds.
+func (r *Redis) GetWithContext(ctx context.Context, key string) ([]byte, error) {
 	r.mGetCount.Incr(1)
 	tStarted := time.Now()
 
@@ -175,7 +204,9 @@ func (r *Redis) Get(key string) ([]byte, error) {
 
 	for i := 0; i < r.conf.Redis.Retries && err != nil; i++ {
 		r.log.Errorf("Get command failed: %v\n", err)
-		<-time.After(r.retryPeriod)
+		if err = waitWithContext(ctx, r.retryPeriod); err != nil {
+			break
+		}
 		r.mGetRetry.Incr(1)
 		res, err = r.client.Get(key).Result()
 		if err == redis.Nil {
@@ -199,6 +230,12 @@ func (r *Redis) Get(key string) ([]byte, error) {
 
 // Set attempts to set the value of a key.
 func (r *Redis) Set(key string, value []byte) error {
+	return r.SetWithContext(context.Background(), key, value)
+}
+
+// SetWithContext attempts to set the value of a key, returns an error if the
+// operation failed or if the context is cancelled before it succeeds.
+func (r *Redis) SetWithContext(ctx context.Context, key string, value []byte) error {
 	r.mSetCount.Incr(1)
 	tStarted := time.Now()
 
@@ -207,7 +244,9 @@ func (r *Redis) Set(key string, value []byte) error {
 	err := r.client.Set(key, value, r.ttl).Err()
 	for i := 0; i < r.conf.Redis.Retries && err != nil; i++ {
 		r.log.Errorf("Set command failed: %v\n", err)
-		<-time.After(r.retryPeriod)
+		if err = waitWithContext(ctx, r.retryPeriod); err != nil {
+			break
+		}
 		r.mSetRetry.Incr(1)
 		err = r.client.Set(key, value, r.ttl).Err()
 	}
@@ -224,16 +263,60 @@ func (r *Redis) Set(key string, value []byte) error {
 	return err
 }
 
+// Transactional returns whether multiple keys are set within a MULTI/EXEC
+// transaction.
+func (r *Redis) Transactional() bool {
+	return r.conf.Redis.Transactional
+}
+
 // SetMulti attempts to set the value of multiple keys, returns an error if any
 // keys fail.
 func (r *Redis) SetMulti(items map[string][]byte) error {
-	// TODO: Come back and optimise this.
-	for k, v := range items {
-		if err := r.Set(k, v); err != nil {
-			return err
+	r.mSetMultiCount.Incr(1)
+	tStarted := time.Now()
+
+	setMulti := func() error {
+		var pipe redis.Pipeliner
+		if r.conf.Redis.Transactional {
+			pipe = r.client.TxPipeline()
+		} else {
+			pipe = r.client.Pipeline()
+		}
+		for k, v := range items {
+			pipe.Set(r.prefix+k, v, r.ttl)
 		}
+		_, err := pipe.Exec()
+		if err != nil && r.conf.Redis.Transactional && isTxAborted(err) {
+			r.log.Errorf("Set multi transaction aborted: %v\n", err)
+			return types.ErrTransactionAborted
+		}
+		return err
 	}
-	return nil
+
+	err := setMulti()
+	for i := 0; i < r.conf.Redis.Retries && err != nil && err != types.ErrTransactionAborted; i++ {
+		r.log.Errorf("Set multi command failed: %v\n", err)
+		<-time.After(r.retryPeriod)
+		r.mSetMultiRetry.Incr(1)
+		err = setMulti()
+	}
+	if err != nil {
+		r.mSetMultiFailed.Incr(1)
+	} else {
+		r.mSetMultiSuccess.Incr(1)
+	}
+
+	latency := int64(time.Since(tStarted))
+	r.mSetMultiLatency.Timing(latency)
+	r.mLatency.Timing(latency)
+
+	return err
+}
+
+// isTxAborted returns true if an error indicates that a transaction was
+// discarded by the server rather than failing to reach it.
+func isTxAborted(err error) bool {
+	return err == redis.TxFailedErr || strings.HasPrefix(err.Error(), "EXECABORT")
 }
 
 // Add attempts to set the value of a key only if the key does not already exist
@@ -1,12 +1,15 @@
 package cache
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"testing"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/go-redis/redis"
 	"github.com/ory/dockertest"
 )
 
@@ -15,6 +18,11 @@ func TestRedisIntegration(t *testing.T) {
 		t.Skip("Skipping integration test in short mode")
 	}
 
+	if url := os.Getenv("BENTHOS_TEST_REDIS_URL"); len(url) > 0 {
+		runRedisTests(url, t)
+		return
+	}
+
 	pool, err := dockertest.NewPool("")
 	if err != nil {
 		t.Skipf("Could not connect to docker: %s", err)
@@ -47,12 +55,37 @@ func TestRedisIntegration(t *testing.T) {
 		}
 	}()
 
+	runRedisTests(url, t)
+}
+
+func runRedisTests(url string, t *testing.T) {
 	t.Run("TestRedisAddDuplicate", func(te *testing.T) {
 		testRedisAddDuplicate(url, te)
 	})
 	t.Run("TestRedisGetAndSet", func(te *testing.T) {
 		testRedisGetAndSet(url, te)
 	})
+	t.Run("TestRedisSetMulti", func(te *testing.T) {
+		testRedisSetMulti(url, false, te)
+	})
+	t.Run("TestRedisSetMultiTransactional", func(te *testing.T) {
+		testRedisSetMulti(url, true, te)
+	})
+}
+
+func TestRedisTxAborted(t *testing.T) {
+	for _, test := range []struct {
+		err error
+		exp bool
+	}{
+		{redis.TxFailedErr, true},
+		{errors.New("EXECABORT Transaction discarded because of previous errors."), true},
+		{errors.New("dial tcp: connection refused"), false},
+	} {
+		if act := isTxAborted(test.err); act != test.exp {
+			t.Errorf("Wrong result for %v: %v != %v", test.err, act, test.exp)
+		}
+	}
 }
 
 func testRedisAddDuplicate(url string, t *testing.T) {
@@ -138,3 +171,44 @@ func testRedisGetAndSet(url string, t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func testRedisSetMulti(url string, transactional bool, t *testing.T) {
+	conf := NewConfig()
+	conf.Redis.URL = url
+	conf.Redis.Prefix = "benthos_test_multi_"
+	conf.Redis.Transactional = transactional
+
+	c, err := NewRedis(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.Set("connection", []byte("hello world")); err != nil {
+		t.Skipf("Redis server not available: %v", err)
+	}
+
+	items := map[string][]byte{
+		"foo": []byte("first"),
+		"bar": []byte("second"),
+		"baz": []byte("third"),
+	}
+	if err = c.SetMulti(items); err != nil {
+		t.Fatal(err)
+	}
+
+	for k, exp := range items {
+		act, err := c.Get(k)
+		if err != nil {
+			t.Error(err)
+		} else if string(act) != string(exp) {
+			t.Errorf("Wrong value returned for %v: %s != %s", k, act, exp)
+		}
+		if err = c.Delete(k); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, err = c.Get("foo"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+}
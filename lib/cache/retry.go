@@ -0,0 +1,318 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/retries"
+	"github.com/Jeffail/benthos/v3/lib/x/docs"
+	"github.com/cenkalti/backoff"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeRetry] = TypeSpec{
+		constructor: NewRetry,
+		Description: `
+Wraps a child cache and retries its operations when they fail, which is useful
+for network backed caches that can suffer from transient errors.
+
+Errors that indicate the outcome of an operation, such as a key not existing on
+a read or already existing on an add, are returned immediately. All other errors
+are retried according to the backoff fields until either the retries or max
+elapsed time are reached, at which point the last error is returned.
+
+` + "``` yaml" + `
+resources:
+  caches:
+    foocache:
+      retry:
+        max_retries: 3
+        cache:
+          redis:
+            url: tcp://localhost:6379
+` + "```" + ``,
+		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
+			confBytes, err := json.Marshal(conf.Retry)
+			if err != nil {
+				return nil, err
+			}
+
+			confMap := map[string]interface{}{}
+			if err = json.Unmarshal(confBytes, &confMap); err != nil {
+				return nil, err
+			}
+
+			var cacheSanit interface{} = struct{}{}
+			if conf.Retry.Cache != nil {
+				if cacheSanit, err = SanitiseConfig(*conf.Retry.Cache); err != nil {
+					return nil, err
+				}
+			}
+			confMap["cache"] = cacheSanit
+			return confMap, nil
+		},
+		FieldSpecs: append(docs.FieldSpecs{
+			docs.FieldCommon("cache", "The child cache to wrap."),
+		}, retries.FieldSpecs()...),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// RetryConfig contains configuration values for the Retry cache type.
+type RetryConfig struct {
+	Cache          *Config `json:"cache" yaml:"cache"`
+	retries.Config `json:",inline" yaml:",inline"`
+}
+
+// NewRetryConfig creates a new RetryConfig with default values.
+func NewRetryConfig() RetryConfig {
+	rConf := retries.NewConfig()
+	rConf.MaxRetries = 3
+	rConf.Backoff.InitialInterval = "100ms"
+	rConf.Backoff.MaxInterval = "1s"
+	rConf.Backoff.MaxElapsedTime = "5s"
+	return RetryConfig{
+		Cache:  nil,
+		Config: rConf,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type dummyRetryConfig struct {
+	Cache          interface{} `json:"cache" yaml:"cache"`
+	retries.Config `json:",inline" yaml:",inline"`
+}
+
+// MarshalJSON prints an empty object instead of nil.
+func (r RetryConfig) MarshalJSON() ([]byte, error) {
+	dummy := dummyRetryConfig{
+		Cache:  r.Cache,
+		Config: r.Config,
+	}
+	if r.Cache == nil {
+		dummy.Cache = struct{}{}
+	}
+	return json.Marshal(dummy)
+}
+
+// MarshalYAML prints an empty object instead of nil.
+func (r RetryConfig) MarshalYAML() (interface{}, error) {
+	dummy := dummyRetryConfig{
+		Cache:  r.Cache,
+		Config: r.Config,
+	}
+	if r.Cache == nil {
+		dummy.Cache = struct{}{}
+	}
+	return dummy, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Retry is a cache that wraps a child cache and retries operations that fail.
+type Retry struct {
+	wrapped     types.Cache
+	backoffCtor func() backoff.BackOff
+
+	log log.Modular
+
+	mRetry     metrics.StatCounter
+	mExhausted metrics.StatCounter
+}
+
+// NewRetry creates a new Retry cache type.
+func NewRetry(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (types.Cache, error) {
+	if conf.Retry.Cache == nil {
+		return nil, errors.New("cannot create retry cache without a child")
+	}
+
+	wrapped, err := New(*conf.Retry.Cache, mgr, log, metrics.Namespaced(stats, "retry"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache '%v': %v", conf.Retry.Cache.Type, err)
+	}
+
+	boffCtor, err := conf.Retry.GetCtor()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Retry{
+		wrapped:     wrapped,
+		backoffCtor: boffCtor,
+		log:         log,
+		mRetry:      stats.GetCounter("retry.count"),
+		mExhausted:  stats.GetCounter("retry.exhausted"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// isOutcome returns true if an error is the expected outcome of an operation
+// rather than a failure that might be resolved by retrying.
+func isOutcome(err error) bool {
+	switch err {
+	case types.ErrKeyNotFound, types.ErrKeyAlreadyExists, types.ErrCASConflict, types.ErrCASNotSupported, types.ErrTTLNotSupported, types.ErrTombstoneNotSupported, types.ErrCounterNotSupported, types.ErrCounterNotNumeric:
+		return true
+	}
+	return false
+}
+
+// do attempts an operation until it succeeds, fails with an outcome error, or
+// the backoff is exhausted.
+func (r *Retry) do(op string, fn func() error) error {
+	return r.doWithContext(context.Background(), op, fn)
+}
+
+// doWithContext attempts an operation until it succeeds, fails with an outcome
+// error, the backoff is exhausted or the context is cancelled.
+func (r *Retry) doWithContext(ctx context.Context, op string, fn func() error) error {
+	err := fn()
+	if err == nil || isOutcome(err) {
+		return err
+	}
+
+	boff := r.backoffCtor()
+	for {
+		tNext := retries.NextBackOff(boff, err)
+		if tNext == backoff.Stop {
+			r.mExhausted.Incr(1)
+			return err
+		}
+		r.log.Warnf("%v command failed, retrying: %v\n", op, err)
+		if cErr := waitWithContext(ctx, tNext); cErr != nil {
+			return cErr
+		}
+
+		r.mRetry.Incr(1)
+		if err = fn(); err == nil || isOutcome(err) {
+			return err
+		}
+	}
+}
+
+// Get attempts to locate and return a cached value by its key, returns an error
+// if the key does not exist or if the operation failed.
+func (r *Retry) Get(key string) ([]byte, error) {
+	var value []byte
+	err := r.do("Get", func() error {
+		var err error
+		value, err = r.wrapped.Get(key)
+		return err
+	})
+	return value, err
+}
+
+// GetWithContext attempts to locate and return a cached value by its key,
+// passing the context to the child cache and abandoning retries once it is
+// cancelled.
+func (r *Retry) GetWithContext(ctx context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := r.doWithContext(ctx, "Get", func() error {
+		var err error
+		value, err = GetWithContext(ctx, r.wrapped, key)
+		return err
+	})
+	return value, err
+}
+
+// Set attempts to set the value of a key.
+func (r *Retry) Set(key string, value []byte) error {
+	return r.do("Set", func() error {
+		return r.wrapped.Set(key, value)
+	})
+}
+
+// SetWithContext attempts to set the value of a key, passing the context to
+// the child cache and abandoning retries once it is cancelled.
+func (r *Retry) SetWithContext(ctx context.Context, key string, value []byte) error {
+	return r.doWithContext(ctx, "Set", func() error {
+		return SetWithContext(ctx, r.wrapped, key, value)
+	})
+}
+
+// SetWithTTL attempts to set the value of a key with a TTL that overrides the
+// default of the child cache.
+func (r *Retry) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	return r.do("Set", func() error {
+		return SetWithTTL(r.wrapped, key, value, ttl)
+	})
+}
+
+// DeleteWithTTL attempts to remove a key and leave a tombstone in the child
+// cache for the duration of the grace period.
+func (r *Retry) DeleteWithTTL(key string, grace time.Duration) error {
+	return r.do("Delete", func() error {
+		return DeleteWithTTL(r.wrapped, key, grace)
+	})
+}
+
+// Increment attempts to atomically add delta to the value of a key in the
+// child cache and returns the new value. A failed attempt that was applied by
+// the child cache before failing, such as one that timed out, is counted again
+// when it is retried.
+func (r *Retry) Increment(key string, delta int64) (int64, error) {
+	var value int64
+	err := r.do("Increment", func() error {
+		var err error
+		value, err = Increment(r.wrapped, key, delta)
+		return err
+	})
+	return value, err
+}
+
+// CompareAndSet attempts to set the value of a key in the child cache only if
+// its current value matches old. A failed attempt that was applied by the child
+// cache before failing, such as one that timed out, results in a conflict when
+// it is retried.
+func (r *Retry) CompareAndSet(key string, old, new []byte) error {
+	return r.do("Compare and set", func() error {
+		return CompareAndSet(r.wrapped, key, old, new)
+	})
+}
+
+// SetMulti attempts to set the value of multiple keys, returns an error if any
+// keys fail.
+func (r *Retry) SetMulti(items map[string][]byte) error {
+	return r.do("Set multi", func() error {
+		return r.wrapped.SetMulti(items)
+	})
+}
+
+// Add attempts to set the value of a key only if the key does not already exist
+// and returns an error if the key already exists or if the operation fails.
+func (r *Retry) Add(key string, value []byte) error {
+	return r.do("Add", func() error {
+		return r.wrapped.Add(key, value)
+	})
+}
+
+// Delete attempts to remove a key.
+func (r *Retry) Delete(key string) error {
+	return r.do("Delete", func() error {
+		return r.wrapped.Delete(key)
+	})
+}
+
+// CloseAsync shuts down the cache.
+func (r *Retry) CloseAsync() {
+	r.wrapped.CloseAsync()
+}
+
+// WaitForClose blocks until the cache has closed down.
+func (r *Retry) WaitForClose(timeout time.Duration) error {
+	return r.wrapped.WaitForClose(timeout)
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/config"
+)
+
+//------------------------------------------------------------------------------
+
+type flakyCache struct {
+	types.Cache
+	failures int
+	calls    int
+	closed   bool
+}
+
+func (f *flakyCache) fail() error {
+	f.calls++
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("flaky")
+	}
+	return nil
+}
+
+func (f *flakyCache) Get(key string) ([]byte, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return f.Cache.Get(key)
+}
+
+func (f *flakyCache) Set(key string, value []byte) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.Cache.Set(key, value)
+}
+
+func (f *flakyCache) Add(key string, value []byte) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.Cache.Add(key, value)
+}
+
+func (f *flakyCache) CompareAndSet(key string, old, new []byte) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return CompareAndSet(f.Cache, key, old, new)
+}
+
+func (f *flakyCache) CloseAsync() {
+	f.closed = true
+}
+
+func newTestRetry(t *testing.T, wrapped types.Cache, maxRetries uint64) *Retry {
+	t.Helper()
+
+	conf := NewRetryConfig()
+	conf.MaxRetries = maxRetries
+	conf.Backoff.InitialInterval = "1ms"
+	conf.Backoff.MaxInterval = "1ms"
+
+	ctor, err := conf.GetCtor()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := metrics.NewLocal()
+	return &Retry{
+		wrapped:     wrapped,
+		backoffCtor: ctor,
+		log:         log.New(os.Stdout, log.Config{LogLevel: "NONE"}),
+		mRetry:      stats.GetCounter("retry.count"),
+		mExhausted:  stats.GetCounter("retry.exhausted"),
+	}
+}
+
+func newTestMemory(t *testing.T) types.Cache {
+	t.Helper()
+
+	conf := NewConfig()
+	conf.Type = TypeMemory
+	c, err := New(conf, nil, log.Noop(), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+//------------------------------------------------------------------------------
+
+func TestRetryCacheRecovers(t *testing.T) {
+	flaky := &flakyCache{Cache: newTestMemory(t), failures: 2}
+	c := newTestRetry(t, flaky, 3)
+
+	if err := c.Set("foo", []byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 3, flaky.calls; exp != act {
+		t.Errorf("Wrong count of calls: %v != %v", act, exp)
+	}
+
+	act, err := c.Get("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "bar"; string(act) != exp {
+		t.Errorf("Wrong result: %s != %v", act, exp)
+	}
+}
+
+func TestRetryCacheExhausted(t *testing.T) {
+	flaky := &flakyCache{Cache: newTestMemory(t), failures: 10}
+	c := newTestRetry(t, flaky, 2)
+
+	if err := c.Set("foo", []byte("bar")); err == nil {
+		t.Error("Expected error")
+	}
+	if exp, act := 3, flaky.calls; exp != act {
+		t.Errorf("Wrong count of calls: %v != %v", act, exp)
+	}
+}
+
+func TestRetryCacheOutcomeErrors(t *testing.T) {
+	flaky := &flakyCache{Cache: newTestMemory(t)}
+	c := newTestRetry(t, flaky, 3)
+
+	if _, err := c.Get("foo"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+	if exp, act := 1, flaky.calls; exp != act {
+		t.Errorf("Wrong count of calls: %v != %v", act, exp)
+	}
+
+	if err := c.Add("foo", []byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Add("foo", []byte("bar")); err != types.ErrKeyAlreadyExists {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyAlreadyExists)
+	}
+	if exp, act := 3, flaky.calls; exp != act {
+		t.Errorf("Wrong count of calls: %v != %v", act, exp)
+	}
+}
+
+func TestRetryCacheCompareAndSet(t *testing.T) {
+	flaky := &flakyCache{Cache: newTestMemory(t), failures: 2}
+	c := newTestRetry(t, flaky, 3)
+
+	if err := c.CompareAndSet("foo", nil, []byte("bar")); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 3, flaky.calls; exp != act {
+		t.Errorf("Wrong count of calls: %v != %v", act, exp)
+	}
+
+	if err := c.CompareAndSet("foo", []byte("nope"), []byte("baz")); err != types.ErrCASConflict {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrCASConflict)
+	}
+	if exp, act := 4, flaky.calls; exp != act {
+		t.Errorf("Wrong count of calls: %v != %v", act, exp)
+	}
+
+	if err := c.CompareAndSet("foo", []byte("bar"), []byte("baz")); err != nil {
+		t.Fatal(err)
+	}
+	act, err := c.Get("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "baz"; string(act) != exp {
+		t.Errorf("Wrong result: %s != %v", act, exp)
+	}
+}
+
+func TestRetryCacheCompareAndSetNotSupported(t *testing.T) {
+	flaky := &flakyCache{Cache: newTestMemory(t)}
+	c := newTestRetry(t, struct{ types.Cache }{flaky}, 3)
+
+	if err := c.CompareAndSet("foo", nil, []byte("bar")); err != types.ErrCASNotSupported {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrCASNotSupported)
+	}
+}
+
+func TestRetryCacheClose(t *testing.T) {
+	flaky := &flakyCache{Cache: newTestMemory(t)}
+	c := newTestRetry(t, flaky, 3)
+
+	c.CloseAsync()
+	if !flaky.closed {
+		t.Error("Wrapped cache was not closed")
+	}
+	if err := c.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRetryCacheConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeRetry
+
+	if _, err := New(conf, nil, log.Noop(), metrics.DudType{}); err == nil {
+		t.Error("Expected error from missing child")
+	}
+
+	child := NewConfig()
+	child.Type = TypeMemory
+	conf.Retry.Cache = &child
+
+	c, err := New(conf, nil, log.Noop(), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Set("foo", []byte("bar")); err != nil {
+		t.Error(err)
+	}
+
+	sanit, err := SanitiseConfig(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retrySanit := sanit.(config.Sanitised)["retry"].(map[string]interface{})
+	if _, exists := retrySanit["cache"].(config.Sanitised)["memory"]; !exists {
+		t.Errorf("Child cache missing from sanitised config: %v", retrySanit)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// GetStream attempts to locate a cached value by its key and returns a reader of
+// its contents. When the cache implements types.CacheStreamer the value is read
+// incrementally, otherwise the value is obtained with Get and wrapped in a
+// reader.
+func GetStream(c types.Cache, key string) (io.ReadCloser, error) {
+	if s, ok := c.(types.CacheStreamer); ok {
+		return s.GetStream(key)
+	}
+	b, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func TestGetStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_stream_cache_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileConf := NewConfig()
+	fileConf.Type = TypeFile
+	fileConf.File.Directory = dir
+
+	fileCache, err := New(fileConf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fileCache.(types.CacheStreamer); !ok {
+		t.Error("Expected file cache to implement streaming")
+	}
+
+	memCache, err := New(NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, c := range map[string]types.Cache{
+		"file":   fileCache,
+		"memory": memCache,
+	} {
+		if _, err = GetStream(c, "foo"); err != types.ErrKeyNotFound {
+			t.Errorf("%v: Wrong error returned: %v != %v", name, err, types.ErrKeyNotFound)
+		}
+		if err = c.Set("foo", []byte("hello world")); err != nil {
+			t.Fatal(err)
+		}
+		r, err := GetStream(c, "foo")
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if err = r.Close(); err != nil {
+			t.Errorf("%v: %v", name, err)
+		}
+		if exp, act := "hello world", string(b); exp != act {
+			t.Errorf("%v: Wrong result: %v != %v", name, act, exp)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// SetWithTTL attempts to set the value of a key with a TTL that overrides the
+// default of the cache. Returns types.ErrTTLNotSupported when the cache does
+// not implement types.TTLCache.
+func SetWithTTL(c types.Cache, key string, value []byte, ttl time.Duration) error {
+	if tc, ok := c.(types.TTLCache); ok {
+		return tc.SetWithTTL(key, value, ttl)
+	}
+	return types.ErrTTLNotSupported
+}
+
+// DeleteWithTTL attempts to remove a key and leave a tombstone that prevents it
+// from being added again until the grace period has passed. Returns
+// types.ErrTombstoneNotSupported when the cache does not implement
+// types.TombstoneCache.
+func DeleteWithTTL(c types.Cache, key string, grace time.Duration) error {
+	if tc, ok := c.(types.TombstoneCache); ok {
+		return tc.DeleteWithTTL(key, grace)
+	}
+	return types.ErrTombstoneNotSupported
+}
+
+//------------------------------------------------------------------------------
@@ -45,16 +45,17 @@ func New() Type {
 // SanitisedConfig is a config struct of generic types, this is returned by
 // Sanitised() and is a generic structure containing only fields of relevance.
 type SanitisedConfig struct {
-	HTTP               interface{} `json:"http" yaml:"http"`
-	Input              interface{} `json:"input" yaml:"input"`
-	Buffer             interface{} `json:"buffer" yaml:"buffer"`
-	Pipeline           interface{} `json:"pipeline" yaml:"pipeline"`
-	Output             interface{} `json:"output" yaml:"output"`
-	Manager            interface{} `json:"resources" yaml:"resources"`
-	Logger             interface{} `json:"logger" yaml:"logger"`
-	Metrics            interface{} `json:"metrics" yaml:"metrics"`
-	Tracer             interface{} `json:"tracer" yaml:"tracer"`
-	SystemCloseTimeout interface{} `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+	HTTP                interface{} `json:"http" yaml:"http"`
+	Input               interface{} `json:"input" yaml:"input"`
+	Buffer              interface{} `json:"buffer" yaml:"buffer"`
+	Pipeline            interface{} `json:"pipeline" yaml:"pipeline"`
+	Output              interface{} `json:"output" yaml:"output"`
+	MaxInFlightMessages interface{} `json:"max_in_flight_messages" yaml:"max_in_flight_messages"`
+	Manager             interface{} `json:"resources" yaml:"resources"`
+	Logger              interface{} `json:"logger" yaml:"logger"`
+	Metrics             interface{} `json:"metrics" yaml:"metrics"`
+	Tracer              interface{} `json:"tracer" yaml:"tracer"`
+	SystemCloseTimeout  interface{} `json:"shutdown_timeout" yaml:"shutdown_timeout"`
 }
 
 // Sanitised returns a sanitised copy of the Benthos configuration, meaning
@@ -103,16 +104,17 @@ func (c Type) Sanitised() (*SanitisedConfig, error) {
 	}
 
 	return &SanitisedConfig{
-		HTTP:               c.HTTP,
-		Input:              inConf,
-		Buffer:             bufConf,
-		Pipeline:           pipeConf,
-		Output:             outConf,
-		Manager:            mgrConf,
-		Logger:             c.Logger,
-		Metrics:            metConf,
-		Tracer:             tracConf,
-		SystemCloseTimeout: c.SystemCloseTimeout,
+		HTTP:                c.HTTP,
+		Input:               inConf,
+		Buffer:              bufConf,
+		Pipeline:            pipeConf,
+		Output:              outConf,
+		MaxInFlightMessages: c.MaxInFlightMessages,
+		Manager:             mgrConf,
+		Logger:              c.Logger,
+		Metrics:             metConf,
+		Tracer:              tracConf,
+		SystemCloseTimeout:  c.SystemCloseTimeout,
 	}, nil
 }
 
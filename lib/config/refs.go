@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/Jeffail/benthos/v3/lib/util/secrets"
 	"github.com/Jeffail/benthos/v3/lib/util/text"
 	"gopkg.in/yaml.v3"
 )
@@ -18,6 +19,10 @@ import (
 // ReadWithJSONPointers takes a config file path, reads the contents, performs a
 // generic parse, resolves any JSON Pointers, marshals the result back into
 // bytes and returns it so that it can be unmarshalled into a typed structure.
+//
+// When replaceEnvs is true environment variables are replaced and secret
+// references are resolved, a secret that fails to resolve results in an error
+// naming the field it belongs to.
 func ReadWithJSONPointers(path string, replaceEnvs bool) ([]byte, error) {
 	configBytes, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -37,7 +42,15 @@ func ReadWithJSONPointers(path string, replaceEnvs bool) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	if !refFound {
+
+	var secretFound bool
+	if replaceEnvs {
+		if secretFound, err = secrets.Walk(gen); err != nil {
+			return nil, fmt.Errorf("failed to resolve secret in config '%v': %v", path, err)
+		}
+	}
+
+	if !refFound && !secretFound {
 		return configBytes, nil
 	}
 	if configBytes, err = yaml.Marshal(gen); err != nil {
@@ -47,6 +60,34 @@ func ReadWithJSONPointers(path string, replaceEnvs bool) ([]byte, error) {
 	return configBytes, nil
 }
 
+// ReplaceEnvVariables replaces environment variables within config bytes and
+// resolves any secret references within its values, a secret that fails to
+// resolve results in an error naming the field it belongs to. This is the
+// counterpart of ReadWithJSONPointers for configs that are not read from a
+// file, such as those sent to the streams API.
+func ReplaceEnvVariables(configBytes []byte) ([]byte, error) {
+	configBytes = text.ReplaceEnvVariables(configBytes)
+	if !secrets.ContainsReferences(string(configBytes)) {
+		return configBytes, nil
+	}
+
+	var gen interface{}
+	if err := yaml.Unmarshal(configBytes, &gen); err != nil {
+		return nil, err
+	}
+	secretFound, err := secrets.Walk(gen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve secret in config: %v", err)
+	}
+	if !secretFound {
+		return configBytes, nil
+	}
+	if configBytes, err = yaml.Marshal(gen); err != nil {
+		return nil, fmt.Errorf("failed to marshal secret resolved structure: %v", err)
+	}
+	return configBytes, nil
+}
+
 //------------------------------------------------------------------------------
 
 // JSONPointer parses a JSON pointer path (https://tools.ietf.org/html/rfc6901)
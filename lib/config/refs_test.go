@@ -1,11 +1,14 @@
 package config
 
 import (
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/Jeffail/benthos/v3/lib/util/secrets"
 	"gopkg.in/yaml.v3"
 )
 
@@ -348,4 +351,96 @@ func TestConfigNoRefs(t *testing.T) {
 	}
 }
 
+func TestConfigSecretRefs(t *testing.T) {
+	secrets.RegisterProvider("configtest", secrets.ProviderFunc(func(ref string) (string, error) {
+		if ref == "missing" {
+			return "", errors.New("not found")
+		}
+		return "secret_" + ref, nil
+	}))
+
+	tmpDir, err := ioutil.TempDir("", "benthos_config_ref_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = os.RemoveAll(tmpDir); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	rootPath := filepath.Join(tmpDir, "root.yaml")
+	rootFile := []byte(`{"foo":{"bar":"${secret:configtest:baz}"}}`)
+	if err = ioutil.WriteFile(rootPath, rootFile, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ReadWithJSONPointers(rootPath, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `foo:
+    bar: secret_baz
+`
+	if act := string(res); exp != act {
+		t.Errorf("Wrong config result: %v != %v", act, exp)
+	}
+
+	if res, err = ReadWithJSONPointers(rootPath, false); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := string(rootFile), string(res); exp != act {
+		t.Errorf("Wrong config result: %v != %v", act, exp)
+	}
+
+	badFile := []byte(`{"foo":{"bar":"${secret:configtest:missing}"}}`)
+	if err = ioutil.WriteFile(rootPath, badFile, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ReadWithJSONPointers(rootPath, true); err == nil {
+		t.Error("Expected error")
+	} else if exp := "field 'foo.bar'"; !strings.Contains(err.Error(), exp) {
+		t.Errorf("Expected error '%v' to contain '%v'", err, exp)
+	}
+}
+
+func TestConfigReplaceEnvVariablesSecrets(t *testing.T) {
+	secrets.RegisterProvider("configtest", secrets.ProviderFunc(func(ref string) (string, error) {
+		if ref == "missing" {
+			return "", errors.New("not found")
+		}
+		return "secret_" + ref, nil
+	}))
+	os.Setenv("BENTHOS_TEST_CONFIG_FOO", "foo_value")
+	defer os.Unsetenv("BENTHOS_TEST_CONFIG_FOO")
+
+	res, err := ReplaceEnvVariables([]byte(`{"foo":{"bar":"${secret:configtest:baz}","baz":"${BENTHOS_TEST_CONFIG_FOO}"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `foo:
+    bar: secret_baz
+    baz: foo_value
+`
+	if act := string(res); exp != act {
+		t.Errorf("Wrong config result: %v != %v", act, exp)
+	}
+
+	noSecrets := `{"foo":{"bar":"${BENTHOS_TEST_CONFIG_FOO}"}}`
+	if res, err = ReplaceEnvVariables([]byte(noSecrets)); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := `{"foo":{"bar":"foo_value"}}`, string(res); exp != act {
+		t.Errorf("Wrong config result: %v != %v", act, exp)
+	}
+
+	if _, err = ReplaceEnvVariables([]byte(`{"foo":{"bar":"${secret:configtest:missing}"}}`)); err == nil {
+		t.Error("Expected error")
+	} else if exp := "field 'foo.bar'"; !strings.Contains(err.Error(), exp) {
+		t.Errorf("Expected error '%v' to contain '%v'", err, exp)
+	}
+}
+
 //------------------------------------------------------------------------------
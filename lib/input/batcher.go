@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/message/batch"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
@@ -86,34 +87,56 @@ func (m *Batcher) loop() {
 			return
 		}
 
-		resChan := make(chan types.Response)
-		select {
-		case m.messagesOut <- types.NewTransaction(sendMsg, resChan):
-		case <-m.fullyCloseCtx.Done():
-			return
+		chunks := m.batcher.Chunks(sendMsg)
+		resChans := make([]chan types.Response, len(chunks))
+		for i := range resChans {
+			resChans[i] = make(chan types.Response)
 		}
 
 		pendingAcks.Add(1)
-		go func(rChan <-chan types.Response, aggregatedResChans []chan<- types.Response) {
+		go func(rChans []chan types.Response, aggregatedResChans []chan<- types.Response) {
 			defer pendingAcks.Done()
 
-			select {
-			case <-m.fullyCloseCtx.Done():
-				return
-			case res, open := <-rChan:
-				if !open {
+			// A batch divided by max_parts is only acknowledged once every
+			// chunk has been, and fails if any chunk failed.
+			var res types.Response
+			for _, rChan := range rChans {
+				select {
+				case <-m.fullyCloseCtx.Done():
 					return
-				}
-				for _, c := range aggregatedResChans {
-					select {
-					case <-m.fullyCloseCtx.Done():
+				case chunkRes, open := <-rChan:
+					if !open {
 						return
-					case c <- res:
 					}
+					if res == nil || res.Error() == nil {
+						res = chunkRes
+					}
+				}
+			}
+			for _, c := range aggregatedResChans {
+				select {
+				case <-m.fullyCloseCtx.Done():
+					return
+				case c <- res:
 				}
 			}
-		}(resChan, pendingResChans)
+		}(resChans, pendingResChans)
 		pendingResChans = nil
+
+		for i, chunk := range chunks {
+			chunkMsg := sendMsg
+			if len(chunks) > 1 {
+				chunkMsg = message.New(nil)
+				for _, j := range chunk {
+					chunkMsg.Append(sendMsg.Get(j))
+				}
+			}
+			select {
+			case m.messagesOut <- types.NewTransaction(chunkMsg, resChans[i]):
+			case <-m.fullyCloseCtx.Done():
+				return
+			}
+		}
 	}
 
 	defer func() {
@@ -147,6 +170,7 @@ func (m *Batcher) loop() {
 				}
 				return
 			}
+			wasEmpty := m.batcher.Count() == 0
 			tran.Payload.Iter(func(i int, p types.Part) error {
 				if m.batcher.Add(p) {
 					flushBatch = true
@@ -154,9 +178,15 @@ func (m *Batcher) loop() {
 				return nil
 			})
 			pendingResChans = append(pendingResChans, tran.ResponseChan)
+			if wasEmpty {
+				// An idle period may bring the next timed flush forward.
+				nextTimedBatchChan = nil
+			}
 		case <-nextTimedBatchChan:
-			flushBatch = true
 			nextTimedBatchChan = nil
+			// Messages added since the timer was set may have pushed an idle
+			// flush back.
+			flushBatch = m.batcher.UntilNext() <= 0
 		case <-m.ctx.Done():
 			return
 		}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
 	sess "github.com/Jeffail/benthos/v3/lib/util/aws/session"
@@ -22,10 +23,11 @@ import (
 // AmazonSQSConfig contains configuration values for the input type.
 type AmazonSQSConfig struct {
 	sess.Config         `json:",inline" yaml:",inline"`
-	URL                 string `json:"url" yaml:"url"`
-	Timeout             string `json:"timeout" yaml:"timeout"`
-	MaxNumberOfMessages int64  `json:"max_number_of_messages" yaml:"max_number_of_messages"`
-	DeleteMessage       bool   `json:"delete_message" yaml:"delete_message"`
+	URL                 string             `json:"url" yaml:"url"`
+	Timeout             string             `json:"timeout" yaml:"timeout"`
+	MaxNumberOfMessages int64              `json:"max_number_of_messages" yaml:"max_number_of_messages"`
+	DeleteMessage       bool               `json:"delete_message" yaml:"delete_message"`
+	Batching            batch.PolicyConfig `json:"batching" yaml:"batching"`
 }
 
 // NewAmazonSQSConfig creates a new Config with default values.
@@ -36,6 +38,7 @@ func NewAmazonSQSConfig() AmazonSQSConfig {
 		Timeout:             "5s",
 		MaxNumberOfMessages: 1,
 		DeleteMessage:       true,
+		Batching:            batch.NewPolicyConfig(),
 	}
 }
 
@@ -3,9 +3,11 @@ package reader
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/message/batch"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
@@ -17,9 +19,17 @@ import (
 // batching policy to incoming payloads. Once a batch is created and sent the
 // provided ack function ensures all messages within the batch are acked.
 type AsyncBatcher struct {
-	pendingAcks []AsyncAckFn
-	batcher     *batch.Policy
-	r           Async
+	pendingAcks   []AsyncAckFn
+	pendingChunks []asyncChunk
+	batcher       *batch.Policy
+	r             Async
+}
+
+// asyncChunk is a part of a flushed batch that was divided by max_parts and is
+// yet to be read.
+type asyncChunk struct {
+	msg   types.Message
+	ackFn AsyncAckFn
 }
 
 // NewAsyncBatcher returns a new AsyncBatcher wrapper around a reader.Async.
@@ -78,23 +88,93 @@ func (p *AsyncBatcher) wrapAckFns() AsyncAckFn {
 	}
 }
 
+// flush returns the buffered batch and its ack function, where a batch exceeding
+// max_parts is divided into chunks and the chunks after the first are held for
+// subsequent reads.
+func (p *AsyncBatcher) flush() (types.Message, AsyncAckFn) {
+	msg, ackFn := p.batcher.Flush(), p.wrapAckFns()
+	if msg == nil {
+		return msg, ackFn
+	}
+	chunks := p.batcher.Chunks(msg)
+	if len(chunks) == 1 {
+		return msg, ackFn
+	}
+
+	var ackMut sync.Mutex
+	var ackRes types.Response
+	remaining := len(chunks)
+	chunkAckFn := func(ctx context.Context, res types.Response) error {
+		ackMut.Lock()
+		if ackRes == nil || ackRes.Error() == nil {
+			ackRes = res
+		}
+		remaining--
+		done, res := remaining == 0, ackRes
+		ackMut.Unlock()
+		if !done {
+			return nil
+		}
+		return ackFn(ctx, res)
+	}
+
+	for _, chunk := range chunks {
+		chunkMsg := message.New(nil)
+		for _, j := range chunk {
+			chunkMsg.Append(msg.Get(j))
+		}
+		p.pendingChunks = append(p.pendingChunks, asyncChunk{
+			msg:   chunkMsg,
+			ackFn: chunkAckFn,
+		})
+	}
+	next := p.pendingChunks[0]
+	p.pendingChunks = p.pendingChunks[1:]
+	return next.msg, next.ackFn
+}
+
 // ReadWithContext attempts to read a new message from the source.
 func (p *AsyncBatcher) ReadWithContext(ctx context.Context) (types.Message, AsyncAckFn, error) {
+	if len(p.pendingChunks) > 0 {
+		next := p.pendingChunks[0]
+		p.pendingChunks = p.pendingChunks[1:]
+		return next.msg, next.ackFn, nil
+	}
+
+	parentCtx := ctx
 	var forcedBatchDeadline time.Time
-	if tout := p.batcher.UntilNext(); tout >= 0 {
-		forcedBatchDeadline = time.Now().Add(tout)
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithDeadline(ctx, forcedBatchDeadline)
-		defer cancel()
+	var cancel context.CancelFunc
+	setDeadline := func() {
+		if cancel != nil {
+			cancel()
+			cancel = nil
+		}
+		ctx, forcedBatchDeadline = parentCtx, time.Time{}
+		if tout := p.batcher.UntilNext(); tout >= 0 {
+			forcedBatchDeadline = time.Now().Add(tout)
+			ctx, cancel = context.WithDeadline(parentCtx, forcedBatchDeadline)
+		}
 	}
+	setDeadline()
+	defer func() {
+		if cancel != nil {
+			cancel()
+		}
+	}()
 
 	flushBatch := false
 	for !flushBatch {
 		msg, ackFn, err := p.r.ReadWithContext(ctx)
 		if err != nil {
 			if !forcedBatchDeadline.IsZero() && !time.Now().Before(forcedBatchDeadline) {
-				if batch := p.batcher.Flush(); batch != nil && batch.Len() > 0 {
-					return batch, p.wrapAckFns(), nil
+				if err == types.ErrTimeout && parentCtx.Err() == nil && p.batcher.UntilNext() > 0 {
+					// Messages added since the deadline was set have pushed an
+					// idle flush back.
+					setDeadline()
+					continue
+				}
+				if batch, ackFn := p.flush(); batch != nil && batch.Len() > 0 {
+					return batch, ackFn, nil
 				}
 			}
 			if err == types.ErrTimeout {
@@ -104,8 +184,8 @@ func (p *AsyncBatcher) ReadWithContext(ctx context.Context) (types.Message, Asyn
 				// simply want to try again.
 				select {
 				case <-ctx.Done():
-					if batch := p.batcher.Flush(); batch != nil && batch.Len() > 0 {
-						return batch, p.wrapAckFns(), nil
+					if batch, ackFn := p.flush(); batch != nil && batch.Len() > 0 {
+						return batch, ackFn, nil
 					}
 					return nil, nil, types.ErrTimeout
 				default:
@@ -113,20 +193,26 @@ func (p *AsyncBatcher) ReadWithContext(ctx context.Context) (types.Message, Asyn
 				continue
 			}
 			if err == types.ErrTypeClosed {
-				if batch := p.batcher.Flush(); batch != nil && batch.Len() > 0 {
-					return batch, p.wrapAckFns(), nil
+				if batch, ackFn := p.flush(); batch != nil && batch.Len() > 0 {
+					return batch, ackFn, nil
 				}
 			}
 			return nil, nil, err
 		}
 
 		p.pendingAcks = append(p.pendingAcks, ackFn)
+		wasEmpty := p.batcher.Count() == 0
 		msg.Iter(func(i int, part types.Part) error {
 			flushBatch = p.batcher.Add(part) || flushBatch
 			return nil
 		})
+		if wasEmpty && !flushBatch {
+			// An idle period may bring the deadline forward.
+			setDeadline()
+		}
 	}
-	return p.batcher.Flush(), p.wrapAckFns(), nil
+	msg, ackFn := p.flush()
+	return msg, ackFn, nil
 }
 
 // CloseAsync triggers the asynchronous closing of the reader.
@@ -109,6 +109,9 @@ func NewKafka(
 		closedChan: make(chan struct{}),
 	}
 
+	if err := conf.SASL.Validate(); err != nil {
+		return nil, err
+	}
 	if conf.TLS.Enabled {
 		var err error
 		if k.tlsConf, err = conf.TLS.Get(); err != nil {
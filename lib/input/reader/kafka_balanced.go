@@ -134,6 +134,9 @@ func NewKafkaBalanced(
 	if conf.MaxBatchCount < 1 {
 		return nil, errors.New("max_batch_count must be greater than or equal to 1")
 	}
+	if err := conf.SASL.Validate(); err != nil {
+		return nil, err
+	}
 	if conf.TLS.Enabled {
 		var err error
 		if k.tlsConf, err = conf.TLS.Get(); err != nil {
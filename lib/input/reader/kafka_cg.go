@@ -44,7 +44,8 @@ type KafkaCG struct {
 	session       sarama.ConsumerGroupSession
 	msgChan       chan asyncMessage
 
-	mRebalanced metrics.StatCounter
+	mRebalanced  metrics.StatCounter
+	mRevokedAcks metrics.StatCounter
 
 	conf  KafkaBalancedConfig
 	stats metrics.Type
@@ -66,8 +67,12 @@ func NewKafkaCG(
 		log:           log,
 		mgr:           mgr,
 		mRebalanced:   stats.GetCounter("rebalanced"),
+		mRevokedAcks:  stats.GetCounter("rebalance.revoked_acks"),
 		closedChan:    make(chan struct{}),
 	}
+	if err := conf.SASL.Validate(); err != nil {
+		return nil, err
+	}
 	if conf.TLS.Enabled {
 		var err error
 		if k.tlsConf, err = conf.TLS.Get(); err != nil {
@@ -175,11 +180,15 @@ func (k *KafkaCG) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.Co
 				resErr := res.Error()
 				if resErr == nil {
 					k.cMut.Lock()
-					if k.session != nil {
+					// Offsets are only marked within the session that the
+					// messages were consumed from, as after a rebalance the
+					// partition may belong to another consumer.
+					if k.session != nil && k.session == sess && sess.Context().Err() == nil {
 						k.log.Debugf("Marking offset for topic '%v' partition '%v'.\n", topic, partition)
 						k.session.MarkOffset(topic, partition, offset, "")
 					} else {
-						k.log.Debugf("Unable to mark offset for topic '%v' partition '%v'.\n", topic, partition)
+						k.mRevokedAcks.Incr(1)
+						k.log.Debugf("Unable to mark offset for topic '%v' partition '%v' as the partition was revoked.\n", topic, partition)
 					}
 					k.cMut.Unlock()
 				}
@@ -213,6 +222,12 @@ func (k *KafkaCG) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.Co
 		}
 		select {
 		case <-nextTimedBatchChan:
+			// Messages added since the timer was set may have pushed an idle
+			// flush back.
+			if batchPolicy.UntilNext() > 0 {
+				nextTimedBatchChan = nil
+				continue
+			}
 			if !flushBatch(claim.Topic(), claim.Partition(), latestOffset+1) {
 				return nil
 			}
@@ -240,6 +255,10 @@ func (k *KafkaCG) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.Co
 			meta.Set("kafka_lag", strconv.FormatInt(lag, 10))
 			meta.Set("kafka_timestamp_unix", strconv.FormatInt(data.Timestamp.Unix(), 10))
 
+			if batchPolicy.Count() == 0 {
+				// An idle period may bring the next timed flush forward.
+				nextTimedBatchChan = nil
+			}
 			if batchPolicy.Add(part) {
 				if !flushBatch(claim.Topic(), claim.Partition(), latestOffset+1) {
 					return nil
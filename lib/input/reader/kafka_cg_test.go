@@ -0,0 +1,136 @@
+package reader
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Shopify/sarama"
+)
+
+//------------------------------------------------------------------------------
+
+type mockCGSession struct {
+	ctx context.Context
+
+	sync.Mutex
+	marked []int64
+}
+
+func (m *mockCGSession) Claims() map[string][]int32 { return nil }
+func (m *mockCGSession) MemberID() string           { return "" }
+func (m *mockCGSession) GenerationID() int32        { return 0 }
+func (m *mockCGSession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+	m.Lock()
+	m.marked = append(m.marked, offset)
+	m.Unlock()
+}
+func (m *mockCGSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {}
+func (m *mockCGSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string)                 {}
+func (m *mockCGSession) Context() context.Context                                                 { return m.ctx }
+
+func (m *mockCGSession) markedOffsets() []int64 {
+	m.Lock()
+	defer m.Unlock()
+	return append([]int64(nil), m.marked...)
+}
+
+type mockCGClaim struct {
+	msgs chan *sarama.ConsumerMessage
+}
+
+func (m *mockCGClaim) Topic() string                            { return "foo" }
+func (m *mockCGClaim) Partition() int32                         { return 0 }
+func (m *mockCGClaim) InitialOffset() int64                     { return 0 }
+func (m *mockCGClaim) HighWaterMarkOffset() int64               { return 10 }
+func (m *mockCGClaim) Messages() <-chan *sarama.ConsumerMessage { return m.msgs }
+
+//------------------------------------------------------------------------------
+
+func TestKafkaCGRebalanceNoStaleCommits(t *testing.T) {
+	conf := NewKafkaBalancedConfig()
+	conf.Batching.Count = 1
+
+	k, err := NewKafkaCG(conf, types.NoopMgr(), log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.msgChan = make(chan asyncMessage)
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	sess1 := &mockCGSession{ctx: ctx1}
+	claim1 := &mockCGClaim{msgs: make(chan *sarama.ConsumerMessage)}
+
+	if err = k.Setup(sess1); err != nil {
+		t.Fatal(err)
+	}
+
+	consumeDone := make(chan struct{})
+	go func() {
+		k.ConsumeClaim(sess1, claim1)
+		close(consumeDone)
+	}()
+
+	readMsg := func(claim *mockCGClaim, offset int64) asyncMessage {
+		t.Helper()
+		select {
+		case claim.msgs <- &sarama.ConsumerMessage{Topic: "foo", Offset: offset, Value: []byte("hello")}:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out sending message")
+		}
+		select {
+		case m := <-k.msgChan:
+			return m
+		case <-time.After(time.Second):
+			t.Fatal("Timed out reading message")
+		}
+		return asyncMessage{}
+	}
+
+	// Acknowledged within the owning session.
+	m := readMsg(claim1, 1)
+	if err = m.ackFn(context.Background(), response.NewAck()); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := []int64{2}, sess1.markedOffsets(); len(act) != 1 || act[0] != exp[0] {
+		t.Fatalf("Wrong marked offsets: %v != %v", act, exp)
+	}
+
+	// In flight during a rebalance.
+	m = readMsg(claim1, 2)
+
+	cancel1()
+	select {
+	case <-consumeDone:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for claim to end")
+	}
+	if err = k.Cleanup(sess1); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	sess2 := &mockCGSession{ctx: ctx2}
+	if err = k.Setup(sess2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = m.ackFn(context.Background(), response.NewAck()); err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := 1, len(sess1.markedOffsets()); exp != act {
+		t.Errorf("Revoked session marked offsets after rebalance: %v", sess1.markedOffsets())
+	}
+	if act := sess2.markedOffsets(); len(act) > 0 {
+		t.Errorf("New session marked offsets of a revoked claim: %v", act)
+	}
+}
+
+//------------------------------------------------------------------------------
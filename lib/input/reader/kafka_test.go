@@ -0,0 +1,60 @@
+package reader
+
+import (
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/kafka/sasl"
+	"github.com/Shopify/sarama"
+)
+
+//------------------------------------------------------------------------------
+
+func TestKafkaInputsSASLMechanism(t *testing.T) {
+	ctors := map[string]func(sasl.Config) error{
+		"kafka": func(s sasl.Config) error {
+			conf := NewKafkaConfig()
+			conf.SASL = s
+			_, err := NewKafka(conf, types.NoopMgr(), log.Noop(), metrics.Noop())
+			return err
+		},
+		"kafka_balanced": func(s sasl.Config) error {
+			conf := NewKafkaBalancedConfig()
+			conf.SASL = s
+			_, err := NewKafkaBalanced(conf, types.NoopMgr(), log.Noop(), metrics.Noop())
+			return err
+		},
+		"kafka_cg": func(s sasl.Config) error {
+			conf := NewKafkaBalancedConfig()
+			conf.SASL = s
+			_, err := NewKafkaCG(conf, types.NoopMgr(), log.Noop(), metrics.Noop())
+			return err
+		},
+	}
+
+	for name, ctor := range ctors {
+		for _, mechanism := range []string{
+			"",
+			sarama.SASLTypePlaintext,
+			sarama.SASLTypeOAuth,
+			sarama.SASLTypeSCRAMSHA256,
+			sarama.SASLTypeSCRAMSHA512,
+		} {
+			s := sasl.NewConfig()
+			s.Mechanism = mechanism
+			if err := ctor(s); err != nil {
+				t.Errorf("%v: unexpected error for mechanism '%v': %v", name, mechanism, err)
+			}
+		}
+
+		s := sasl.NewConfig()
+		s.Mechanism = "SCRAM-SHA-1"
+		if err := ctor(s); err == nil {
+			t.Errorf("%v: expected error from unsupported mechanism", name)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
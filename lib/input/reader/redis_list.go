@@ -11,6 +11,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	bredis "github.com/Jeffail/benthos/v3/lib/util/redis"
 	"github.com/go-redis/redis"
 )
 
@@ -21,6 +22,8 @@ type RedisListConfig struct {
 	URL     string `json:"url" yaml:"url"`
 	Key     string `json:"key" yaml:"key"`
 	Timeout string `json:"timeout" yaml:"timeout"`
+
+	bredis.PoolConfig `json:",inline" yaml:",inline"`
 }
 
 // NewRedisListConfig creates a new RedisListConfig with default values.
@@ -29,6 +32,8 @@ func NewRedisListConfig() RedisListConfig {
 		URL:     "tcp://localhost:6379",
 		Key:     "benthos_list",
 		Timeout: "5s",
+
+		PoolConfig: bredis.NewPoolConfig(),
 	}
 }
 
@@ -69,6 +74,9 @@ func NewRedisList(
 	if err != nil {
 		return nil, err
 	}
+	if err = r.conf.PoolConfig.Validate(); err != nil {
+		return nil, err
+	}
 
 	return r, nil
 }
@@ -89,15 +97,10 @@ func (r *RedisList) ConnectWithContext(ctx context.Context) error {
 		return nil
 	}
 
-	var pass string
-	if r.url.User != nil {
-		pass, _ = r.url.User.Password()
+	client, err := r.conf.PoolConfig.Client(r.url)
+	if err != nil {
+		return err
 	}
-	client := redis.NewClient(&redis.Options{
-		Addr:     r.url.Host,
-		Network:  r.url.Scheme,
-		Password: pass,
-	})
 
 	if _, err := client.Ping().Result(); err != nil {
 		return err
@@ -10,6 +10,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	bredis "github.com/Jeffail/benthos/v3/lib/util/redis"
 	"github.com/go-redis/redis"
 )
 
@@ -21,6 +22,8 @@ type RedisPubSubConfig struct {
 	URL         string   `json:"url" yaml:"url"`
 	Channels    []string `json:"channels" yaml:"channels"`
 	UsePatterns bool     `json:"use_patterns" yaml:"use_patterns"`
+
+	bredis.PoolConfig `json:",inline" yaml:",inline"`
 }
 
 // NewRedisPubSubConfig creates a new RedisPubSubConfig with default values.
@@ -29,6 +32,8 @@ func NewRedisPubSubConfig() RedisPubSubConfig {
 		URL:         "tcp://localhost:6379",
 		Channels:    []string{"benthos_chan"},
 		UsePatterns: false,
+
+		PoolConfig: bredis.NewPoolConfig(),
 	}
 }
 
@@ -62,6 +67,9 @@ func NewRedisPubSub(
 	if err != nil {
 		return nil, err
 	}
+	if err = r.conf.PoolConfig.Validate(); err != nil {
+		return nil, err
+	}
 
 	return r, nil
 }
@@ -82,15 +90,10 @@ func (r *RedisPubSub) ConnectWithContext(ctx context.Context) error {
 		return nil
 	}
 
-	var pass string
-	if r.url.User != nil {
-		pass, _ = r.url.User.Password()
+	client, err := r.conf.PoolConfig.Client(r.url)
+	if err != nil {
+		return err
 	}
-	client := redis.NewClient(&redis.Options{
-		Addr:     r.url.Host,
-		Network:  r.url.Scheme,
-		Password: pass,
-	})
 
 	if _, err := client.Ping().Result(); err != nil {
 		return err
@@ -13,6 +13,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/message/batch"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	bredis "github.com/Jeffail/benthos/v3/lib/util/redis"
 	"github.com/go-redis/redis"
 )
 
@@ -33,6 +34,8 @@ type RedisStreamsConfig struct {
 
 	// TODO: V4 remove this.
 	Batching batch.PolicyConfig `json:"batching" yaml:"batching"`
+
+	bredis.PoolConfig `json:",inline" yaml:",inline"`
 }
 
 // NewRedisStreamsConfig creates a new RedisStreamsConfig with default values.
@@ -50,6 +53,8 @@ func NewRedisStreamsConfig() RedisStreamsConfig {
 		StartFromOldest: true,
 		CommitPeriod:    "1s",
 		Timeout:         "5s",
+
+		PoolConfig: bredis.NewPoolConfig(),
 	}
 }
 
@@ -104,6 +109,9 @@ func NewRedisStreams(
 	if err != nil {
 		return nil, err
 	}
+	if err = r.conf.PoolConfig.Validate(); err != nil {
+		return nil, err
+	}
 
 	if tout := conf.Timeout; len(tout) > 0 {
 		var err error
@@ -226,15 +234,10 @@ func (r *RedisStreams) ConnectWithContext(ctx context.Context) error {
 		return nil
 	}
 
-	var pass string
-	if r.url.User != nil {
-		pass, _ = r.url.User.Password()
+	client, err := r.conf.PoolConfig.Client(r.url)
+	if err != nil {
+		return err
 	}
-	client := redis.NewClient(&redis.Options{
-		Addr:     r.url.Host,
-		Network:  r.url.Scheme,
-		Password: pass,
-	})
 
 	if _, err := client.Ping().Result(); err != nil {
 		return err
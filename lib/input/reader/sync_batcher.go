@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/message/batch"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
@@ -21,6 +22,11 @@ type SyncBatcher struct {
 	r       Sync
 	ctx     context.Context
 	close   func()
+
+	// Chunks of a flushed batch divided by max_parts that are yet to be read,
+	// and the first error acknowledged for the chunks already read.
+	pendingChunks []types.Message
+	chunkErr      error
 }
 
 // NewSyncBatcher returns a new SyncBatcher wrapper around a reader.Async.
@@ -65,22 +71,71 @@ func (p *SyncBatcher) Read() (types.Message, error) {
 	return p.ReadNextWithContext(p.ctx)
 }
 
+// flush returns the buffered batch, where a batch exceeding max_parts is
+// divided into chunks and the chunks after the first are held for subsequent
+// reads.
+func (p *SyncBatcher) flush() types.Message {
+	msg := p.batcher.Flush()
+	if msg == nil {
+		return msg
+	}
+	chunks := p.batcher.Chunks(msg)
+	if len(chunks) == 1 {
+		return msg
+	}
+	for _, chunk := range chunks {
+		chunkMsg := message.New(nil)
+		for _, j := range chunk {
+			chunkMsg.Append(msg.Get(j))
+		}
+		p.pendingChunks = append(p.pendingChunks, chunkMsg)
+	}
+	next := p.pendingChunks[0]
+	p.pendingChunks = p.pendingChunks[1:]
+	return next
+}
+
 // ReadNextWithContext attempts to read a new message from the source.
 func (p *SyncBatcher) ReadNextWithContext(ctx context.Context) (types.Message, error) {
+	if len(p.pendingChunks) > 0 {
+		next := p.pendingChunks[0]
+		p.pendingChunks = p.pendingChunks[1:]
+		return next, nil
+	}
+
+	parentCtx := ctx
 	var forcedBatchDeadline time.Time
-	if tout := p.batcher.UntilNext(); tout >= 0 {
-		forcedBatchDeadline = time.Now().Add(tout)
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithDeadline(ctx, forcedBatchDeadline)
-		defer cancel()
+	var cancel context.CancelFunc
+	setDeadline := func() {
+		if cancel != nil {
+			cancel()
+			cancel = nil
+		}
+		ctx, forcedBatchDeadline = parentCtx, time.Time{}
+		if tout := p.batcher.UntilNext(); tout >= 0 {
+			forcedBatchDeadline = time.Now().Add(tout)
+			ctx, cancel = context.WithDeadline(parentCtx, forcedBatchDeadline)
+		}
 	}
+	setDeadline()
+	defer func() {
+		if cancel != nil {
+			cancel()
+		}
+	}()
 
 	flushBatch := false
 	for !flushBatch {
 		msg, err := p.r.ReadNextWithContext(ctx)
 		if err != nil {
 			if !forcedBatchDeadline.IsZero() && !time.Now().Before(forcedBatchDeadline) {
-				if batch := p.batcher.Flush(); batch != nil && batch.Len() > 0 {
+				if err == types.ErrTimeout && parentCtx.Err() == nil && p.batcher.UntilNext() > 0 {
+					// Messages added since the deadline was set have pushed an
+					// idle flush back.
+					setDeadline()
+					continue
+				}
+				if batch := p.flush(); batch != nil && batch.Len() > 0 {
 					return batch, nil
 				}
 			}
@@ -91,7 +146,7 @@ func (p *SyncBatcher) ReadNextWithContext(ctx context.Context) (types.Message, e
 				// simply want to try again.
 				select {
 				case <-ctx.Done():
-					if batch := p.batcher.Flush(); batch != nil && batch.Len() > 0 {
+					if batch := p.flush(); batch != nil && batch.Len() > 0 {
 						return batch, nil
 					}
 					return nil, types.ErrTimeout
@@ -100,20 +155,25 @@ func (p *SyncBatcher) ReadNextWithContext(ctx context.Context) (types.Message, e
 				continue
 			}
 			if err == types.ErrTypeClosed {
-				if batch := p.batcher.Flush(); batch != nil && batch.Len() > 0 {
+				if batch := p.flush(); batch != nil && batch.Len() > 0 {
 					return batch, nil
 				}
 			}
 			return nil, err
 		}
 
+		wasEmpty := p.batcher.Count() == 0
 		msg.Iter(func(i int, part types.Part) error {
 			flushBatch = p.batcher.Add(part) || flushBatch
 			return nil
 		})
+		if wasEmpty && !flushBatch {
+			// An idle period may bring the deadline forward.
+			setDeadline()
+		}
 	}
 
-	msg := p.batcher.Flush()
+	msg := p.flush()
 	if msg == nil || msg.Len() == 0 {
 		return nil, types.ErrTimeout
 	}
@@ -129,6 +189,15 @@ func (p *SyncBatcher) Acknowledge(err error) error {
 // AcknowledgeWithContext confirms whether or not our unacknowledged messages
 // have been successfully propagated or not.
 func (p *SyncBatcher) AcknowledgeWithContext(ctx context.Context, err error) error {
+	if err != nil && p.chunkErr == nil {
+		p.chunkErr = err
+	}
+	if len(p.pendingChunks) > 0 {
+		// The underlying acknowledgement applies to the whole batch and
+		// therefore waits until all of its chunks have been read.
+		return nil
+	}
+	err, p.chunkErr = p.chunkErr, nil
 	return p.r.AcknowledgeWithContext(ctx, err)
 }
 
@@ -162,6 +162,65 @@ func TestSyncBatcherHappy(t *testing.T) {
 	}
 }
 
+func TestSyncBatcherMaxParts(t *testing.T) {
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	rdr := newMockSyncReader()
+	rdr.msgsToSnd = append(rdr.msgsToSnd, message.New([][]byte{
+		[]byte("test 0"), []byte("test 1"), []byte("test 2"),
+	}))
+
+	conf := batch.NewPolicyConfig()
+	conf.MaxParts = 2
+	batcher, err := NewSyncBatcher(conf, rdr, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		batcher.CloseAsync()
+		deadline, _ := ctx.Deadline()
+		if err = batcher.WaitForClose(time.Until(deadline)); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	go func() {
+		rdr.connChan <- nil
+		rdr.readChan <- nil
+		rdr.ackChan <- nil
+		rdr.closeAsyncChan <- struct{}{}
+		rdr.waitForCloseChan <- nil
+	}()
+
+	if err = batcher.ConnectWithContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	chunkErr := errors.New("chunk failed")
+	for _, exp := range []struct {
+		parts int
+		err   error
+	}{
+		{parts: 2, err: chunkErr},
+		{parts: 1},
+	} {
+		msg, err := batcher.ReadNextWithContext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Len() != exp.parts {
+			t.Errorf("Wrong batch count: %v != %v", msg.Len(), exp.parts)
+		}
+		if err = batcher.AcknowledgeWithContext(ctx, exp.err); err != nil {
+			t.Error(err)
+		}
+	}
+	if rdr.ackRcvd != chunkErr {
+		t.Errorf("Expected '%v', received: %v", chunkErr, rdr.ackRcvd)
+	}
+}
+
 func TestSyncBatcherSadThenHappy(t *testing.T) {
 	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
 	defer done()
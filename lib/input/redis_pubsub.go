@@ -5,6 +5,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	bredis "github.com/Jeffail/benthos/v3/lib/util/redis"
 	"github.com/Jeffail/benthos/v3/lib/x/docs"
 )
 
@@ -27,11 +28,11 @@ patterns in your channel names. For example:
 
 Use ` + "`\\`" + ` to escape special characters if you want to match them
 verbatim.`,
-		FieldSpecs: docs.FieldSpecs{
+		FieldSpecs: append(docs.FieldSpecs{
 			docs.FieldCommon("url", "The URL of a Redis server to connect to.", "tcp://localhost:6379"),
 			docs.FieldCommon("channels", "A list of channels to consume from."),
 			docs.FieldCommon("use_patterns", "Whether to use the PSUBSCRIBE command."),
-		},
+		}, bredis.FieldSpecs()...),
 	}
 }
 
@@ -5,6 +5,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	bredis "github.com/Jeffail/benthos/v3/lib/util/redis"
 	"github.com/Jeffail/benthos/v3/lib/x/docs"
 )
 
@@ -27,7 +28,7 @@ as metadata fields.`,
 		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
 			return sanitiseWithBatch(conf.RedisStreams, conf.RedisStreams.Batching)
 		},
-		FieldSpecs: docs.FieldSpecs{
+		FieldSpecs: append(docs.FieldSpecs{
 			docs.FieldDeprecated("batching"),
 			docs.FieldCommon("url", "The URL of a Redis server to connect to."),
 			docs.FieldCommon("body_key", "The field key to extract the raw message from. All other keys will be stored in the message as metadata."),
@@ -38,7 +39,7 @@ as metadata fields.`,
 			docs.FieldAdvanced("start_from_oldest", "If an offset is not found for a stream, determines whether to consume from the oldest available offset, otherwise messages are consumed from the latest offset."),
 			docs.FieldAdvanced("commit_period", "The period of time between each commit of the current offset. Offsets are always committed during shutdown."),
 			docs.FieldAdvanced("timeout", "The length of time to poll for new messages before reattempting."),
-		},
+		}, bredis.FieldSpecs()...),
 	}
 }
 
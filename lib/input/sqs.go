@@ -3,6 +3,7 @@ package input
 import (
 	"github.com/Jeffail/benthos/v3/lib/input/reader"
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
 	"github.com/Jeffail/benthos/v3/lib/util/aws/session"
@@ -36,7 +37,20 @@ This input adds the following metadata fields to each message:
 ` + "```" + `
 
 You can access these metadata fields using
-[function interpolation](/docs/configuration/interpolation#metadata).`,
+[function interpolation](/docs/configuration/interpolation#metadata).
+
+### Batching
+
+Each request returns at most ` + "`max_number_of_messages`" + ` messages, which
+are passed through the pipeline as a single batch. Use the ` + "`batching`" + `
+fields to configure an optional
+[batching policy](/docs/configuration/batching#batch-policy) in order to combine
+the messages of multiple requests into larger batches. The messages of a batch
+are acknowledged (or made visible again on failure) together once the batch is
+resolved.`,
+		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
+			return sanitiseWithBatch(conf.SQS, conf.SQS.Batching)
+		},
 		FieldSpecs: append(
 			append(docs.FieldSpecs{
 				docs.FieldCommon("url", "The SQS URL to consume from."),
@@ -44,6 +58,7 @@ You can access these metadata fields using
 			}, session.FieldSpecs()...),
 			docs.FieldAdvanced("timeout", "The period of time to wait before abandoning a request and trying again."),
 			docs.FieldAdvanced("max_number_of_messages", "The maximum number of messages to consume from each request."),
+			batch.FieldSpec(),
 		),
 	}
 }
@@ -52,10 +67,16 @@ You can access these metadata fields using
 
 // NewAmazonSQS creates a new AWS SQS input type.
 func NewAmazonSQS(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
-	s, err := reader.NewAmazonSQS(conf.SQS, log, stats)
-	if err != nil {
+	var s reader.Async
+	var err error
+	if s, err = reader.NewAmazonSQS(conf.SQS, log, stats); err != nil {
 		return nil, err
 	}
+	if !conf.SQS.Batching.IsNoop() {
+		if s, err = reader.NewAsyncBatcher(conf.SQS.Batching, s, mgr, log, stats); err != nil {
+			return nil, err
+		}
+	}
 	return NewAsyncReader(TypeSQS, true, reader.NewAsyncBundleUnacks(s), log, stats)
 }
 
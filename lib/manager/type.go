@@ -2,6 +2,7 @@ package manager
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"sync"
 	"time"
@@ -138,6 +139,17 @@ type Type struct {
 
 	pipes    map[string]<-chan types.Transaction
 	pipeLock sync.RWMutex
+
+	shared      map[string]*sharedResource
+	sharedStale map[string][]*sharedResource
+	sharedLock  sync.Mutex
+}
+
+// sharedResource is a resource shared between components along with the count
+// of components currently holding it.
+type sharedResource struct {
+	res     io.Closer
+	holders int
 }
 
 // New returns an instance of manager.Type, which can be shared amongst
@@ -149,13 +161,15 @@ func New(
 	stats metrics.Type,
 ) (*Type, error) {
 	t := &Type{
-		apiReg:     apiReg,
-		caches:     map[string]types.Cache{},
-		conditions: map[string]types.Condition{},
-		processors: map[string]types.Processor{},
-		rateLimits: map[string]types.RateLimit{},
-		plugins:    map[string]interface{}{},
-		pipes:      map[string]<-chan types.Transaction{},
+		apiReg:      apiReg,
+		caches:      map[string]types.Cache{},
+		conditions:  map[string]types.Condition{},
+		processors:  map[string]types.Processor{},
+		rateLimits:  map[string]types.RateLimit{},
+		plugins:     map[string]interface{}{},
+		pipes:       map[string]<-chan types.Transaction{},
+		shared:      map[string]*sharedResource{},
+		sharedStale: map[string][]*sharedResource{},
 	}
 
 	for k, conf := range conf.Caches {
@@ -166,7 +180,7 @@ func New(
 				k, conf.Type, err,
 			)
 		}
-		t.caches[k] = newCache
+		t.caches[k] = cache.WithMetrics(k, newCache, stats)
 	}
 
 	// Sometimes condition resources might refer to other condition resources.
@@ -320,6 +334,75 @@ func (t *Type) GetPlugin(name string) (interface{}, error) {
 	return nil, types.ErrPluginNotFound
 }
 
+// AcquireShared returns a resource shared between components under a key,
+// creating it with ctor when it does not yet exist. Each successful call must be
+// paired with a call to ReleaseShared with the same key and resource.
+func (t *Type) AcquireShared(key string, ctor func() (io.Closer, error)) (io.Closer, error) {
+	t.sharedLock.Lock()
+	defer t.sharedLock.Unlock()
+	if s, exists := t.shared[key]; exists {
+		s.holders++
+		return s.res, nil
+	}
+	res, err := ctor()
+	if err != nil {
+		return nil, err
+	}
+	t.shared[key] = &sharedResource{res: res, holders: 1}
+	return res, nil
+}
+
+// ReleaseShared gives up a hold of a resource shared under a key, the resource
+// is closed once its last holder releases it.
+func (t *Type) ReleaseShared(key string, res io.Closer) error {
+	t.sharedLock.Lock()
+	defer t.sharedLock.Unlock()
+	if s, exists := t.shared[key]; exists && s.res == res {
+		if s.holders--; s.holders > 0 {
+			return nil
+		}
+		delete(t.shared, key)
+		return s.res.Close()
+	}
+	stale := t.sharedStale[key]
+	for i, s := range stale {
+		if s.res != res {
+			continue
+		}
+		if s.holders--; s.holders > 0 {
+			return nil
+		}
+		if stale = append(stale[:i], stale[i+1:]...); len(stale) > 0 {
+			t.sharedStale[key] = stale
+		} else {
+			delete(t.sharedStale, key)
+		}
+		return s.res.Close()
+	}
+	return types.ErrSharedResourceNotFound
+}
+
+// InvalidateShared stops sharing a resource under a key, such that the next
+// call to AcquireShared creates a fresh resource. Holders of the invalidated
+// resource keep it until they release it, and it is closed once the last of
+// them does. Invalidating a resource that has already been invalidated has no
+// effect.
+func (t *Type) InvalidateShared(key string, res io.Closer) error {
+	t.sharedLock.Lock()
+	defer t.sharedLock.Unlock()
+	if s, exists := t.shared[key]; exists && s.res == res {
+		delete(t.shared, key)
+		t.sharedStale[key] = append(t.sharedStale[key], s)
+		return nil
+	}
+	for _, s := range t.sharedStale[key] {
+		if s.res == res {
+			return nil
+		}
+	}
+	return types.ErrSharedResourceNotFound
+}
+
 //------------------------------------------------------------------------------
 
 // CloseAsync triggers the shut down of all resource types that implement the
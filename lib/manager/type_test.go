@@ -1,6 +1,8 @@
 package manager
 
 import (
+	"errors"
+	"io"
 	"os"
 	"testing"
 
@@ -38,6 +40,42 @@ func TestManagerCache(t *testing.T) {
 	}
 }
 
+func TestManagerCacheMetrics(t *testing.T) {
+	conf := NewConfig()
+	conf.Caches["foo"] = cache.NewConfig()
+
+	stats := metrics.NewLocal()
+	mgr, err := New(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := mgr.GetCache("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Get("bar"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+	if err = c.Set("bar", []byte("baz")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Get("bar"); err != nil {
+		t.Fatal(err)
+	}
+
+	counters := stats.GetCounters()
+	for k, v := range map[string]int64{
+		"cache.foo.get.not_found": 1,
+		"cache.foo.get.success":   1,
+		"cache.foo.set.success":   1,
+	} {
+		if act := counters[k]; act != v {
+			t.Errorf("Wrong count of %v: %v != %v", k, act, v)
+		}
+	}
+}
+
 func TestManagerBadCache(t *testing.T) {
 	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
 
@@ -162,6 +200,135 @@ func TestManagerBadCondition(t *testing.T) {
 	}
 }
 
+type closeCounter struct {
+	closed int
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestManagerShared(t *testing.T) {
+	conf := NewConfig()
+	mgr, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := 0
+	res := &closeCounter{}
+	ctor := func() (io.Closer, error) {
+		created++
+		return res, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		r, err := mgr.AcquireShared("foo", ctor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r != res {
+			t.Error("Wrong shared resource returned")
+		}
+	}
+	if created != 1 {
+		t.Errorf("Wrong count of created resources: %v != %v", created, 1)
+	}
+
+	if _, err = mgr.AcquireShared("bar", func() (io.Closer, error) {
+		return nil, errors.New("nope")
+	}); err == nil {
+		t.Error("Expected error from failed constructor")
+	}
+	if err = mgr.ReleaseShared("bar", res); err != types.ErrSharedResourceNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrSharedResourceNotFound)
+	}
+
+	if err = mgr.ReleaseShared("foo", res); err != nil {
+		t.Fatal(err)
+	}
+	if res.closed != 0 {
+		t.Error("Shared resource closed with remaining holders")
+	}
+	if err = mgr.ReleaseShared("foo", res); err != nil {
+		t.Fatal(err)
+	}
+	if res.closed != 1 {
+		t.Errorf("Wrong count of closes: %v != %v", res.closed, 1)
+	}
+	if err = mgr.ReleaseShared("foo", res); err != types.ErrSharedResourceNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrSharedResourceNotFound)
+	}
+
+	if _, err = mgr.AcquireShared("foo", ctor); err != nil {
+		t.Fatal(err)
+	}
+	if created != 2 {
+		t.Errorf("Wrong count of created resources: %v != %v", created, 2)
+	}
+}
+
+func TestManagerSharedInvalidate(t *testing.T) {
+	conf := NewConfig()
+	mgr, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var created []*closeCounter
+	ctor := func() (io.Closer, error) {
+		res := &closeCounter{}
+		created = append(created, res)
+		return res, nil
+	}
+
+	var stale io.Closer
+	for i := 0; i < 2; i++ {
+		if stale, err = mgr.AcquireShared("foo", ctor); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = mgr.InvalidateShared("foo", stale); err != nil {
+		t.Fatal(err)
+	}
+	if err = mgr.InvalidateShared("foo", stale); err != nil {
+		t.Errorf("Unexpected error from invalidating twice: %v", err)
+	}
+
+	fresh, err := mgr.AcquireShared("foo", ctor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh == stale {
+		t.Fatal("Expected a fresh resource after invalidation")
+	}
+
+	// Releasing the stale resource does not affect the fresh one.
+	for i := 0; i < 2; i++ {
+		if err = mgr.ReleaseShared("foo", stale); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if exp, act := 1, created[0].closed; exp != act {
+		t.Errorf("Wrong count of stale resource closes: %v != %v", act, exp)
+	}
+	if err = mgr.ReleaseShared("foo", stale); err != types.ErrSharedResourceNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrSharedResourceNotFound)
+	}
+	if exp, act := 0, created[1].closed; exp != act {
+		t.Errorf("Wrong count of fresh resource closes: %v != %v", act, exp)
+	}
+
+	if err = mgr.ReleaseShared("foo", fresh); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 1, created[1].closed; exp != act {
+		t.Errorf("Wrong count of fresh resource closes: %v != %v", act, exp)
+	}
+}
+
 func TestManagerPipeErrors(t *testing.T) {
 	conf := NewConfig()
 	mgr, err := New(conf, nil, log.Noop(), metrics.Noop())
@@ -29,9 +29,13 @@ Allows you to configure a [batching policy](/docs/configuration/batching).`,
 		},
 		Children: docs.FieldSpecs{
 			docs.FieldCommon("count", "A number of messages at which the batch should be flushed. If `0` disables count based batching."),
-			docs.FieldCommon("byte_size", "An amount of bytes at which the batch should be flushed. If `0` disables size based batching."),
+			docs.FieldCommon("byte_size", "An amount of bytes at which the batch should be flushed, counting both the contents and metadata of messages. If `0` disables size based batching."),
 			docs.FieldCommon("period", "A period in which an incomplete batch should be flushed regardless of its size.", "1s", "1m", "500ms"),
+			docs.FieldAdvanced("idle_period", "A period of time without new messages after which a non-empty batch should be flushed early, reducing the latency of messages during low traffic while still batching bursts. If empty an idle period is not applied.", "10ms", "100ms"),
+			docs.FieldAdvanced("max_parts", "A hard limit of messages at which the batch is flushed regardless of any other rules, intended as a safety net against unexpectedly large batches rather than a batching trigger, and a warning is logged whenever it is reached. Messages arriving as a batch are added in full before the limit is checked, and the flushed batch is then divided into batches of at most this many messages. If `0` no limit is applied."),
+			docs.FieldAdvanced("group_by", "An optional interpolated key that divides each flushed batch into separate batches of messages that share the same key, such that a batch written to an output never mixes keys. This is only supported by the batching policies of outputs.", "${!metadata:kafka_partition}", "${!json_field:user.id}").SupportsInterpolation(false),
 			docs.FieldAdvanced("condition", "A [`condition`](/docs/components/conditions/about) to test against each message entering the batch, if this condition resolves to `true` then the batch is flushed."),
+			docs.FieldAdvanced("check", "An optional interpolated string resolved for each message entering the batch, if the result is `true` then the batch is flushed. The message that triggers the flush is included in the flushed batch.", "${!metadata:flush}").SupportsInterpolation(false),
 		},
 	}
 }
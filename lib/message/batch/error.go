@@ -0,0 +1,79 @@
+package batch
+
+import (
+	"errors"
+
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// Error is an error that occurred whilst sending a batch of messages, and
+// optionally records which individual parts of the batch failed. When no parts
+// are recorded as failed the whole batch is considered to have failed.
+type Error struct {
+	err        error
+	source     types.Message
+	partErrors map[int]error
+}
+
+// NewError creates a batch error from the message that failed to send and the
+// overall error.
+func NewError(msg types.Message, err error) *Error {
+	return &Error{
+		err:    err,
+		source: msg,
+	}
+}
+
+// Failed records that the part at an index of the batch failed with an error.
+func (e *Error) Failed(i int, err error) *Error {
+	if e.partErrors == nil {
+		e.partErrors = map[int]error{}
+	}
+	e.partErrors[i] = err
+	return e
+}
+
+// IndexedErrors returns the number of parts recorded as failed.
+func (e *Error) IndexedErrors() int {
+	return len(e.partErrors)
+}
+
+// PartError returns the error of the part at an index of the batch, or nil if
+// the part succeeded. When no parts are recorded as failed the overall error is
+// returned for all parts.
+func (e *Error) PartError(i int) error {
+	if len(e.partErrors) == 0 {
+		return e.err
+	}
+	return e.partErrors[i]
+}
+
+// WalkParts calls a closure for each part of the batch along with its error,
+// which is nil if the part succeeded. Walking stops early if the closure
+// returns false.
+func (e *Error) WalkParts(fn func(int, types.Part, error) bool) {
+	e.source.Iter(func(i int, p types.Part) error {
+		if !fn(i, p, e.PartError(i)) {
+			return errWalkStop
+		}
+		return nil
+	})
+}
+
+// Error returns the overall error message.
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the overall error.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+//------------------------------------------------------------------------------
+
+var errWalkStop = errors.New("walk stopped")
+
+//------------------------------------------------------------------------------
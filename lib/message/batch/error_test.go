@@ -0,0 +1,60 @@
+package batch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+func TestErrorWholeBatch(t *testing.T) {
+	errFoo := errors.New("foo")
+	bErr := NewError(message.New([][]byte{[]byte("a"), []byte("b")}), errFoo)
+
+	if exp, act := "foo", bErr.Error(); exp != act {
+		t.Errorf("Wrong error message: %v != %v", act, exp)
+	}
+	if exp, act := 0, bErr.IndexedErrors(); exp != act {
+		t.Errorf("Wrong count of indexed errors: %v != %v", act, exp)
+	}
+
+	walked := 0
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		walked++
+		if err != errFoo {
+			t.Errorf("Wrong error of part %v: %v", i, err)
+		}
+		return true
+	})
+	if exp, act := 2, walked; exp != act {
+		t.Errorf("Wrong count of walked parts: %v != %v", act, exp)
+	}
+}
+
+func TestErrorIndexed(t *testing.T) {
+	errFoo, errBar := errors.New("foo"), errors.New("bar")
+	bErr := NewError(message.New([][]byte{[]byte("a"), []byte("b"), []byte("c")}), errFoo).
+		Failed(1, errBar)
+
+	if exp, act := 1, bErr.IndexedErrors(); exp != act {
+		t.Errorf("Wrong count of indexed errors: %v != %v", act, exp)
+	}
+
+	exp := []error{nil, errBar, nil}
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if exp[i] != err {
+			t.Errorf("Wrong error of part %v: %v != %v", i, err, exp[i])
+		}
+		return true
+	})
+
+	walked := 0
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		walked++
+		return err == nil
+	})
+	if exp, act := 2, walked; exp != act {
+		t.Errorf("Walk did not stop early: %v != %v", act, exp)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
 )
 
 // SanitisePolicyConfig returns a policy config structure ready to be marshalled
@@ -20,10 +21,14 @@ func SanitisePolicyConfig(policy PolicyConfig) (interface{}, error) {
 		return nil, err
 	}
 	return map[string]interface{}{
-		"byte_size": policy.ByteSize,
-		"count":     policy.Count,
-		"condition": condSanit,
-		"period":    policy.Period,
+		"byte_size":   policy.ByteSize,
+		"count":       policy.Count,
+		"condition":   condSanit,
+		"period":      policy.Period,
+		"idle_period": policy.IdlePeriod,
+		"max_parts":   policy.MaxParts,
+		"group_by":    policy.GroupBy,
+		"check":       policy.Check,
 	}, nil
 }
 
@@ -31,10 +36,14 @@ func SanitisePolicyConfig(policy PolicyConfig) (interface{}, error) {
 
 // PolicyConfig contains configuration parameters for a batch policy.
 type PolicyConfig struct {
-	ByteSize  int              `json:"byte_size" yaml:"byte_size"`
-	Count     int              `json:"count" yaml:"count"`
-	Condition condition.Config `json:"condition" yaml:"condition"`
-	Period    string           `json:"period" yaml:"period"`
+	ByteSize   int              `json:"byte_size" yaml:"byte_size"`
+	Count      int              `json:"count" yaml:"count"`
+	Condition  condition.Config `json:"condition" yaml:"condition"`
+	Period     string           `json:"period" yaml:"period"`
+	IdlePeriod string           `json:"idle_period" yaml:"idle_period"`
+	MaxParts   int              `json:"max_parts" yaml:"max_parts"`
+	GroupBy    string           `json:"group_by" yaml:"group_by"`
+	Check      string           `json:"check" yaml:"check"`
 }
 
 // NewPolicyConfig creates a default PolicyConfig.
@@ -43,10 +52,14 @@ func NewPolicyConfig() PolicyConfig {
 	cond.Type = "static"
 	cond.Static = false
 	return PolicyConfig{
-		ByteSize:  0,
-		Count:     0,
-		Condition: cond,
-		Period:    "",
+		ByteSize:   0,
+		Count:      0,
+		Condition:  cond,
+		Period:     "",
+		IdlePeriod: "",
+		MaxParts:   0,
+		GroupBy:    "",
+		Check:      "",
 	}
 }
 
@@ -61,9 +74,18 @@ func (p PolicyConfig) IsNoop() bool {
 	if p.Condition.Type != condition.TypeStatic {
 		return false
 	}
+	if len(p.Check) > 0 {
+		return false
+	}
 	if len(p.Period) > 0 {
 		return false
 	}
+	if len(p.IdlePeriod) > 0 {
+		return false
+	}
+	if p.MaxParts > 0 {
+		return false
+	}
 	return true
 }
 
@@ -76,19 +98,27 @@ type Policy struct {
 
 	byteSize  int
 	count     int
+	maxParts  int
 	period    time.Duration
+	idle      time.Duration
 	cond      condition.Type
+	groupBy   *text.InterpolatedString
+	check     *text.InterpolatedString
 	sizeTally int
 	parts     []types.Part
 
 	triggered bool
 	lastBatch time.Time
+	lastAdd   time.Time
 	mut       sync.Mutex
 
 	mSizeBatch   metrics.StatCounter
 	mCountBatch  metrics.StatCounter
 	mPeriodBatch metrics.StatCounter
 	mCondBatch   metrics.StatCounter
+	mCheckBatch  metrics.StatCounter
+	mIdleBatch   metrics.StatCounter
+	mMaxBatch    metrics.StatCounter
 }
 
 // NewPolicy creates an empty policy with default rules.
@@ -108,13 +138,32 @@ func NewPolicy(
 			return nil, fmt.Errorf("failed to parse duration string: %v", err)
 		}
 	}
+	var idle time.Duration
+	if len(conf.IdlePeriod) > 0 {
+		if idle, err = time.ParseDuration(conf.IdlePeriod); err != nil {
+			return nil, fmt.Errorf("failed to parse idle period duration string: %v", err)
+		}
+	}
+	var groupBy *text.InterpolatedString
+	if len(conf.GroupBy) > 0 {
+		groupBy = text.NewInterpolatedString(conf.GroupBy)
+	}
+	var check *text.InterpolatedString
+	if len(conf.Check) > 0 {
+		check = text.NewInterpolatedString(conf.Check)
+	}
+
 	return &Policy{
 		log: log,
 
 		byteSize: conf.ByteSize,
 		count:    conf.Count,
+		maxParts: conf.MaxParts,
 		period:   period,
+		idle:     idle,
 		cond:     cond,
+		groupBy:  groupBy,
+		check:    check,
 
 		lastBatch: time.Now(),
 
@@ -122,22 +171,42 @@ func NewPolicy(
 		mCountBatch:  stats.GetCounter("on_count"),
 		mPeriodBatch: stats.GetCounter("on_period"),
 		mCondBatch:   stats.GetCounter("on_condition"),
+		mCheckBatch:  stats.GetCounter("on_check"),
+		mIdleBatch:   stats.GetCounter("on_idle"),
+		mMaxBatch:    stats.GetCounter("on_max_parts"),
 	}, nil
 }
 
 //------------------------------------------------------------------------------
 
+// partSize returns the size of a message part in bytes, including the keys and
+// values of its metadata.
+func partSize(part types.Part) int {
+	size := len(part.Get())
+	part.Metadata().Iter(func(k, v string) error {
+		size += len(k) + len(v)
+		return nil
+	})
+	return size
+}
+
 // Add a new message part to this batch policy. Returns true if this part
 // triggers the conditions of the policy.
 func (p *Policy) Add(part types.Part) bool {
-	p.sizeTally += len(part.Get())
+	p.sizeTally += partSize(part)
 	p.parts = append(p.parts, part)
+	p.lastAdd = time.Now()
 
 	if !p.triggered && p.count > 0 && len(p.parts) >= p.count {
 		p.triggered = true
 		p.mCountBatch.Incr(1)
 		p.log.Traceln("Batching based on count")
 	}
+	if !p.triggered && p.maxParts > 0 && len(p.parts) >= p.maxParts {
+		p.triggered = true
+		p.mMaxBatch.Incr(1)
+		p.log.Warnf("Batch reached the maximum of %v parts before any other trigger, forcing a flush\n", p.maxParts)
+	}
 	if !p.triggered && p.byteSize > 0 && p.sizeTally >= p.byteSize {
 		p.triggered = true
 		p.mSizeBatch.Incr(1)
@@ -150,6 +219,11 @@ func (p *Policy) Add(part types.Part) bool {
 		p.mCondBatch.Incr(1)
 		p.log.Traceln("Batching based on condition")
 	}
+	if !p.triggered && p.check != nil && p.check.Get(tmpMsg) == "true" {
+		p.triggered = true
+		p.mCheckBatch.Incr(1)
+		p.log.Traceln("Batching based on check")
+	}
 
 	return p.triggered || (p.period > 0 && time.Since(p.lastBatch) > p.period)
 }
@@ -162,6 +236,9 @@ func (p *Policy) Flush() types.Message {
 		if !p.triggered && p.period > 0 && time.Since(p.lastBatch) > p.period {
 			p.mPeriodBatch.Incr(1)
 			p.log.Traceln("Batching based on period")
+		} else if !p.triggered && p.idle > 0 && time.Since(p.lastAdd) >= p.idle {
+			p.mIdleBatch.Incr(1)
+			p.log.Traceln("Batching based on idle period")
 		}
 		newMsg = message.New(nil)
 		newMsg.Append(p.parts...)
@@ -173,20 +250,95 @@ func (p *Policy) Flush() types.Message {
 	return newMsg
 }
 
+// Chunks returns the indexes of the parts of a flushed batch divided into
+// consecutive groups of at most max_parts parts. When max_parts is not
+// configured all parts belong to a single group.
+func (p *Policy) Chunks(msg types.Message) [][]int {
+	indexes := make([]int, msg.Len())
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return p.chunk(indexes)
+}
+
+// chunk divides a group of part indexes into groups of at most max_parts.
+func (p *Policy) chunk(indexes []int) [][]int {
+	if p.maxParts <= 0 || len(indexes) <= p.maxParts {
+		return [][]int{indexes}
+	}
+	var chunks [][]int
+	for len(indexes) > p.maxParts {
+		chunks = append(chunks, indexes[:p.maxParts])
+		indexes = indexes[p.maxParts:]
+	}
+	return append(chunks, indexes)
+}
+
+// Groups returns the indexes of the parts of a flushed batch divided into
+// groups that share the same group_by key, in the order that each key first
+// appears, where groups larger than max_parts are further divided. When
+// group_by is not configured all parts belong to a single group.
+func (p *Policy) Groups(msg types.Message) [][]int {
+	if p.groupBy == nil {
+		return p.Chunks(msg)
+	}
+
+	var groups [][]int
+	groupIndexes := map[string]int{}
+	for i := 0; i < msg.Len(); i++ {
+		key := p.groupBy.Get(message.Lock(msg, i))
+		g, exists := groupIndexes[key]
+		if !exists {
+			g = len(groups)
+			groupIndexes[key] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	var chunks [][]int
+	for _, g := range groups {
+		chunks = append(chunks, p.chunk(g)...)
+	}
+	return chunks
+}
+
 // Count returns the number of currently buffered message parts within this
 // policy.
 func (p *Policy) Count() int {
 	return len(p.parts)
 }
 
+// Timed returns true if the policy flushes batches based on a period or an idle
+// period, in which case UntilNext returns the time until the next flush.
+func (p *Policy) Timed() bool {
+	return p.period > 0 || p.idle > 0
+}
+
 // UntilNext returns a duration indicating how long until the current batch
-// should be flushed due to a configured period. A negative duration indicates
-// a period has not been set.
+// should be flushed due to a configured period, or due to a configured idle
+// period when the batch is not empty. A negative duration indicates that
+// neither applies.
+//
+// Since the idle deadline moves with each message added callers should
+// recalculate this duration after adding a message to an empty batch, and
+// should check it again before flushing once it has elapsed.
 func (p *Policy) UntilNext() time.Duration {
-	if p.period <= 0 {
-		return -1
+	if p.idle <= 0 || len(p.parts) == 0 {
+		if p.period <= 0 {
+			return -1
+		}
+		return time.Until(p.lastBatch.Add(p.period))
+	}
+	until := time.Until(p.lastAdd.Add(p.idle))
+	if p.period > 0 {
+		if periodUntil := time.Until(p.lastBatch.Add(p.period)); periodUntil < until {
+			until = periodUntil
+		}
+	}
+	if until < 0 {
+		until = 0
 	}
-	return time.Until(p.lastBatch.Add(p.period))
+	return until
 }
 
 //------------------------------------------------------------------------------
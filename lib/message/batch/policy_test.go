@@ -9,6 +9,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
 )
 
 func TestPolicyBasic(t *testing.T) {
@@ -87,6 +88,158 @@ func TestPolicyPeriod(t *testing.T) {
 	}
 }
 
+func TestPolicyIdlePeriod(t *testing.T) {
+	conf := NewPolicyConfig()
+	conf.Count = 3
+	conf.Period = "1h"
+	conf.IdlePeriod = "200ms"
+
+	stats := metrics.NewLocal()
+	pol, err := NewPolicy(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := pol.UntilNext(); v < (time.Minute * 59) {
+		t.Errorf("Wrong period of empty batch: %v", v)
+	}
+
+	if pol.Add(message.NewPart([]byte("foo"))) {
+		t.Error("Unexpected batch ready")
+	}
+	if v := pol.UntilNext(); v > (time.Millisecond*200) || v < (time.Millisecond*100) {
+		t.Errorf("Wrong idle period: %v", v)
+	}
+
+	<-time.After(time.Millisecond * 100)
+	if pol.Add(message.NewPart([]byte("bar"))) {
+		t.Error("Unexpected batch ready")
+	}
+	if v := pol.UntilNext(); v > (time.Millisecond*200) || v < (time.Millisecond*150) {
+		t.Errorf("Idle period not extended by new message: %v", v)
+	}
+
+	<-time.After(time.Millisecond * 300)
+	if v := pol.UntilNext(); v != 0 {
+		t.Errorf("Wrong elapsed idle period: %v", v)
+	}
+
+	msg := pol.Flush()
+	if exp, act := [][]byte{[]byte("foo"), []byte("bar")}, message.GetAllBytes(msg); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	if exp, act := int64(1), stats.GetCounters()["on_idle"]; exp != act {
+		t.Errorf("Wrong count of idle batches: %v != %v", act, exp)
+	}
+	if v := pol.UntilNext(); v < (time.Minute * 59) {
+		t.Errorf("Wrong period of empty batch: %v", v)
+	}
+
+	for i := 0; i < 2; i++ {
+		if pol.Add(message.NewPart([]byte("foo"))) {
+			t.Error("Unexpected batch ready")
+		}
+	}
+	if !pol.Add(message.NewPart([]byte("foo"))) {
+		t.Error("Expected batch ready on count")
+	}
+	if msg = pol.Flush(); msg.Len() != 3 {
+		t.Errorf("Wrong count of flushed batch: %v", msg.Len())
+	}
+	counters := stats.GetCounters()
+	if exp, act := int64(1), counters["on_count"]; exp != act {
+		t.Errorf("Wrong count of count batches: %v != %v", act, exp)
+	}
+	if exp, act := int64(1), counters["on_idle"]; exp != act {
+		t.Errorf("Wrong count of idle batches: %v != %v", act, exp)
+	}
+}
+
+func TestPolicyMaxParts(t *testing.T) {
+	conf := NewPolicyConfig()
+	conf.Count = 10
+	conf.MaxParts = 3
+
+	stats := metrics.NewLocal()
+	pol, err := NewPolicy(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for j := 0; j < 2; j++ {
+		for i := 0; i < 2; i++ {
+			if pol.Add(message.NewPart([]byte("foo"))) {
+				t.Error("Unexpected batch ready")
+			}
+		}
+		if !pol.Add(message.NewPart([]byte("foo"))) {
+			t.Error("Expected batch ready on max parts")
+		}
+		if msg := pol.Flush(); msg.Len() != 3 {
+			t.Errorf("Wrong count of flushed batch: %v", msg.Len())
+		}
+	}
+
+	counters := stats.GetCounters()
+	if exp, act := int64(2), counters["on_max_parts"]; exp != act {
+		t.Errorf("Wrong count of max parts batches: %v != %v", act, exp)
+	}
+	if exp, act := int64(0), counters["on_count"]; exp != act {
+		t.Errorf("Wrong count of count batches: %v != %v", act, exp)
+	}
+}
+
+func TestPolicyMaxPartsNoop(t *testing.T) {
+	conf := NewPolicyConfig()
+	if !conf.IsNoop() {
+		t.Error("Expected default policy to be a noop")
+	}
+	conf.MaxParts = 3
+	if conf.IsNoop() {
+		t.Error("Expected policy with max parts to not be a noop")
+	}
+}
+
+func TestPolicyMaxPartsChunks(t *testing.T) {
+	conf := NewPolicyConfig()
+	conf.MaxParts = 2
+
+	pol, err := NewPolicy(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{
+		[]byte(`{"id":"a"}`),
+		[]byte(`{"id":"b"}`),
+		[]byte(`{"id":"a"}`),
+		[]byte(`{"id":"a"}`),
+		[]byte(`{"id":"b"}`),
+	})
+	msg.Iter(func(i int, p types.Part) error {
+		pol.Add(p)
+		return nil
+	})
+	flushed := pol.Flush()
+	if exp, act := 5, flushed.Len(); exp != act {
+		t.Fatalf("Wrong count of flushed batch: %v != %v", act, exp)
+	}
+	if exp, act := [][]int{{0, 1}, {2, 3}, {4}}, pol.Chunks(flushed); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong chunks: %v != %v", act, exp)
+	}
+	if exp, act := [][]int{{0, 1}, {2, 3}, {4}}, pol.Groups(flushed); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong groups: %v != %v", act, exp)
+	}
+
+	conf.GroupBy = "${!json_field:id}"
+	if pol, err = NewPolicy(conf, nil, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := [][]int{{0, 2}, {3}, {1, 4}}, pol.Groups(msg); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong groups: %v != %v", act, exp)
+	}
+}
+
 func TestPolicySize(t *testing.T) {
 	conf := NewPolicyConfig()
 	conf.ByteSize = 10
@@ -115,6 +268,98 @@ func TestPolicySize(t *testing.T) {
 	}
 }
 
+func TestPolicySizeMetadata(t *testing.T) {
+	conf := NewPolicyConfig()
+	conf.ByteSize = 10
+
+	pol, err := NewPolicy(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	part := message.NewPart([]byte("foo"))
+	part.Metadata().Set("bar", "baz")
+	if pol.Add(part) {
+		t.Error("Unexpected batch")
+	}
+
+	part = message.NewPart([]byte("q"))
+	part.Metadata().Set("u", "x")
+	if !pol.Add(part) {
+		t.Error("Expected batch")
+	}
+}
+
+func TestPolicySizeBeforeCount(t *testing.T) {
+	conf := NewPolicyConfig()
+	conf.Count = 10
+	conf.ByteSize = 10
+	conf.Period = "1h"
+
+	stats := metrics.NewLocal()
+	pol, err := NewPolicy(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pol.Add(message.NewPart([]byte("foo bar"))) {
+		t.Error("Unexpected batch")
+	}
+	if !pol.Add(message.NewPart([]byte("baz qux"))) {
+		t.Error("Expected batch")
+	}
+	if msg := pol.Flush(); msg == nil || msg.Len() != 2 {
+		t.Errorf("Wrong batch: %v", msg)
+	}
+
+	counters := stats.GetCounters()
+	if exp, act := int64(1), counters["on_size"]; exp != act {
+		t.Errorf("Wrong count of size batches: %v != %v", act, exp)
+	}
+	if exp, act := int64(0), counters["on_count"]; exp != act {
+		t.Errorf("Wrong count of count batches: %v != %v", act, exp)
+	}
+}
+
+func TestPolicyPeriodBeforeCount(t *testing.T) {
+	conf := NewPolicyConfig()
+	conf.Count = 10
+	conf.ByteSize = 1000
+	conf.Period = "100ms"
+
+	stats := metrics.NewLocal()
+	pol, err := NewPolicy(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pol.Add(message.NewPart([]byte("foo"))) {
+		t.Error("Unexpected batch")
+	}
+
+	<-time.After(time.Millisecond * 150)
+	if v := pol.UntilNext(); v > 0 {
+		t.Errorf("Expected elapsed period: %v", v)
+	}
+	if !pol.Add(message.NewPart([]byte("bar"))) {
+		t.Error("Expected batch")
+	}
+	if msg := pol.Flush(); msg == nil || msg.Len() != 2 {
+		t.Errorf("Wrong batch: %v", msg)
+	}
+
+	counters := stats.GetCounters()
+	if exp, act := int64(1), counters["on_period"]; exp != act {
+		t.Errorf("Wrong count of period batches: %v != %v", act, exp)
+	}
+	if exp, act := int64(0), counters["on_count"]; exp != act {
+		t.Errorf("Wrong count of count batches: %v != %v", act, exp)
+	}
+	if exp, act := int64(0), counters["on_size"]; exp != act {
+		t.Errorf("Wrong count of size batches: %v != %v", act, exp)
+	}
+}
+
 func TestPolicyCondition(t *testing.T) {
 	cond := condition.NewConfig()
 	cond.Type = condition.TypeText
@@ -147,3 +392,84 @@ func TestPolicyCondition(t *testing.T) {
 		t.Error("Non-nil empty flush")
 	}
 }
+
+func TestPolicyCheck(t *testing.T) {
+	conf := NewPolicyConfig()
+	conf.Count = 3
+	conf.Check = "${!metadata:flush}"
+
+	if conf.IsNoop() {
+		t.Error("Expected policy with check to not be a noop")
+	}
+
+	stats := metrics.NewLocal()
+	pol, err := NewPolicy(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pol.Add(message.NewPart([]byte("foo"))) {
+		t.Error("Unexpected batch")
+	}
+	part := message.NewPart([]byte("bar"))
+	part.Metadata().Set("flush", "true")
+	if !pol.Add(part) {
+		t.Error("Expected batch")
+	}
+
+	exp := [][]byte{[]byte("foo"), []byte("bar")}
+	if msg := pol.Flush(); !reflect.DeepEqual(exp, message.GetAllBytes(msg)) {
+		t.Errorf("Wrong result: %s != %s", message.GetAllBytes(msg), exp)
+	}
+
+	part = message.NewPart([]byte("baz"))
+	part.Metadata().Set("flush", "false")
+	for i := 0; i < 2; i++ {
+		if pol.Add(part) {
+			t.Error("Unexpected batch")
+		}
+	}
+	if !pol.Add(part) {
+		t.Error("Expected batch")
+	}
+	if msg := pol.Flush(); msg == nil || msg.Len() != 3 {
+		t.Errorf("Wrong batch: %v", msg)
+	}
+
+	counters := stats.GetCounters()
+	if exp, act := int64(1), counters["on_check"]; exp != act {
+		t.Errorf("Wrong count of check batches: %v != %v", act, exp)
+	}
+	if exp, act := int64(1), counters["on_count"]; exp != act {
+		t.Errorf("Wrong count of count batches: %v != %v", act, exp)
+	}
+}
+
+func TestPolicyGroups(t *testing.T) {
+	conf := NewPolicyConfig()
+	conf.Count = 5
+
+	pol, err := NewPolicy(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{
+		[]byte(`{"id":"a"}`),
+		[]byte(`{"id":"b"}`),
+		[]byte(`{"id":"a"}`),
+		[]byte(`{"id":"c"}`),
+		[]byte(`{"id":"b"}`),
+	})
+	if exp, act := [][]int{{0, 1, 2, 3, 4}}, pol.Groups(msg); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong groups: %v != %v", act, exp)
+	}
+
+	conf.GroupBy = "${!json_field:id}"
+	if pol, err = NewPolicy(conf, nil, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := [][]int{{0, 2}, {1, 4}, {3}}, pol.Groups(msg); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong groups: %v != %v", act, exp)
+	}
+}
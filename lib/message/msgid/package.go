@@ -0,0 +1,3 @@
+// Package msgid implements the assignment of unique identifiers to messages,
+// stored within the metadata of each message part.
+package msgid
@@ -0,0 +1,154 @@
+package msgid
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/gofrs/uuid"
+)
+
+//------------------------------------------------------------------------------
+
+// DefaultKey is the metadata key that message IDs are stored under by default.
+const DefaultKey = "benthos_message_id"
+
+// Config contains configuration fields for assigning IDs to messages.
+type Config struct {
+	Enabled   bool   `json:"enabled" yaml:"enabled"`
+	Key       string `json:"key" yaml:"key"`
+	Generator string `json:"generator" yaml:"generator"`
+}
+
+// NewConfig returns a Config with default values.
+func NewConfig() Config {
+	return Config{
+		Enabled:   false,
+		Key:       DefaultKey,
+		Generator: "uuid",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Assigner adds a unique ID to the metadata of message parts that do not
+// already have one.
+type Assigner struct {
+	key      string
+	generate func() string
+}
+
+// New creates an Assigner from a config.
+func New(conf Config) (*Assigner, error) {
+	if len(conf.Key) == 0 {
+		return nil, errors.New("a message ID key must be specified")
+	}
+	a := &Assigner{key: conf.Key}
+	switch conf.Generator {
+	case "uuid":
+		a.generate = generateUUID
+	case "snowflake":
+		a.generate = newSnowflake(nodeID()).Next
+	default:
+		return nil, fmt.Errorf("message ID generator not recognised: %v", conf.Generator)
+	}
+	return a, nil
+}
+
+// Key returns the metadata key that IDs are stored under.
+func (a *Assigner) Key() string {
+	return a.key
+}
+
+// Assign adds an ID to each part of a message that does not already have one.
+func (a *Assigner) Assign(msg types.Message) {
+	msg.Iter(func(i int, p types.Part) error {
+		if len(p.Metadata().Get(a.key)) == 0 {
+			p.Metadata().Set(a.key, a.generate())
+		}
+		return nil
+	})
+}
+
+//------------------------------------------------------------------------------
+
+func generateUUID() string {
+	u4, err := uuid.NewV4()
+	if err != nil {
+		panic(err)
+	}
+	return u4.String()
+}
+
+// nodeID derives the node segment of snowflake IDs from the hostname of the
+// instance, such that instances sharing a clock produce distinct IDs.
+func nodeID() int64 {
+	hostname, _ := os.Hostname()
+	h := fnv.New32a()
+	h.Write([]byte(hostname))
+	h.Write([]byte(strconv.Itoa(os.Getpid())))
+	return int64(h.Sum32()) & snowflakeNodeMask
+}
+
+//------------------------------------------------------------------------------
+
+const (
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 12
+	snowflakeNodeMask = (1 << snowflakeNodeBits) - 1
+	snowflakeSeqMask  = (1 << snowflakeSeqBits) - 1
+)
+
+// snowflakeEpoch is the time from which the millisecond timestamps of
+// snowflake IDs are measured, 2020-01-01T00:00:00Z.
+var snowflakeEpoch = time.Unix(1577836800, 0)
+
+// snowflake generates roughly time ordered 63 bit IDs composed of a
+// millisecond timestamp, a node ID and a sequence number.
+type snowflake struct {
+	mut    sync.Mutex
+	node   int64
+	lastMS int64
+	seq    int64
+	now    func() time.Time
+}
+
+func newSnowflake(node int64) *snowflake {
+	return &snowflake{
+		node: node & snowflakeNodeMask,
+		now:  time.Now,
+	}
+}
+
+// Next returns the next ID as a decimal string.
+func (s *snowflake) Next() string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	ms := s.now().Sub(snowflakeEpoch).Nanoseconds() / int64(time.Millisecond)
+	if ms < s.lastMS {
+		// Never move backwards when the clock does.
+		ms = s.lastMS
+	}
+	if ms == s.lastMS {
+		s.seq = (s.seq + 1) & snowflakeSeqMask
+		if s.seq == 0 {
+			// The sequence is exhausted for this millisecond, borrow the
+			// next one.
+			ms++
+		}
+	} else {
+		s.seq = 0
+	}
+	s.lastMS = ms
+
+	id := ms<<(snowflakeNodeBits+snowflakeSeqBits) | s.node<<snowflakeSeqBits | s.seq
+	return strconv.FormatInt(id, 10)
+}
+
+//------------------------------------------------------------------------------
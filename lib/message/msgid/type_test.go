@@ -0,0 +1,106 @@
+package msgid
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/gofrs/uuid"
+)
+
+func TestAssignUUID(t *testing.T) {
+	a, err := New(NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar"), []byte("baz")})
+	msg.Get(2).Metadata().Set(DefaultKey, "existing")
+	a.Assign(msg)
+
+	first, second := msg.Get(0).Metadata().Get(DefaultKey), msg.Get(1).Metadata().Get(DefaultKey)
+	for _, id := range []string{first, second} {
+		if _, err := uuid.FromString(id); err != nil {
+			t.Errorf("Expected UUID message ID: %v", err)
+		}
+	}
+	if first == second {
+		t.Errorf("Expected unique message IDs: %v == %v", first, second)
+	}
+	if exp, act := "existing", msg.Get(2).Metadata().Get(DefaultKey); exp != act {
+		t.Errorf("Existing message ID was replaced: %v != %v", act, exp)
+	}
+}
+
+func TestAssignCustomKey(t *testing.T) {
+	conf := NewConfig()
+	conf.Key = "foo"
+	conf.Generator = "snowflake"
+
+	a, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{[]byte("foo")})
+	a.Assign(msg)
+
+	if _, err := strconv.ParseInt(msg.Get(0).Metadata().Get("foo"), 10, 64); err != nil {
+		t.Errorf("Expected snowflake message ID: %v", err)
+	}
+	if id := msg.Get(0).Metadata().Get(DefaultKey); len(id) > 0 {
+		t.Errorf("Unexpected ID under default key: %v", id)
+	}
+}
+
+func TestBadConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.Generator = "nope"
+	if _, err := New(conf); err == nil {
+		t.Error("Expected error from bad generator")
+	}
+
+	conf = NewConfig()
+	conf.Key = ""
+	if _, err := New(conf); err == nil {
+		t.Error("Expected error from empty key")
+	}
+}
+
+func TestSnowflake(t *testing.T) {
+	now := snowflakeEpoch.Add(time.Second)
+	s := newSnowflake(5)
+	s.now = func() time.Time { return now }
+
+	seen := map[int64]struct{}{}
+	var last int64
+	for i := 0; i < 5000; i++ {
+		id, err := strconv.ParseInt(s.Next(), 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, exists := seen[id]; exists {
+			t.Fatalf("Duplicate ID %v at iteration %v", id, i)
+		}
+		if id <= last {
+			t.Fatalf("ID %v not greater than previous %v", id, last)
+		}
+		seen[id] = struct{}{}
+		last = id
+	}
+
+	if exp, act := int64(5), (last>>snowflakeSeqBits)&snowflakeNodeMask; exp != act {
+		t.Errorf("Wrong node of ID: %v != %v", act, exp)
+	}
+
+	// The clock moving backwards must not produce smaller IDs.
+	now = now.Add(-time.Minute)
+	id, err := strconv.ParseInt(s.Next(), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id <= last {
+		t.Errorf("ID %v not greater than previous %v after clock moved back", id, last)
+	}
+}
@@ -160,9 +160,12 @@ func (c *combinedWrapper) SetLogger(log log.Modular) {
 }
 
 func (c *combinedWrapper) Close() error {
-	c.t1.Close()
-	c.t2.Close()
-	return nil
+	err1 := c.t1.Close()
+	err2 := c.t2.Close()
+	if err1 != nil {
+		return err1
+	}
+	return err2
 }
 
 //------------------------------------------------------------------------------
@@ -17,6 +17,10 @@ func init() {
 Pushes metrics using the [StatsD protocol](https://github.com/statsd/statsd).
 Supported tagging formats are 'legacy', 'none', 'datadog' and 'influxdb'.
 
+Metrics are buffered and pushed every ` + "`flush_period`" + `. Any pending
+metrics are flushed when Benthos shuts down, and it waits for the flush to
+complete within the ` + "`shutdown_timeout`" + ` before exiting.
+
 The underlying client library has recently been updated in order to support
 tagging. The tag format 'legacy' is default and causes Benthos to continue using
 the old library in order to preserve backwards compatibility.
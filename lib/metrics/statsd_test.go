@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readStatsdPackets(t *testing.T, conn net.PacketConn) string {
+	t.Helper()
+
+	var received strings.Builder
+	buf := make([]byte, 4096)
+	for {
+		conn.SetReadDeadline(time.Now().Add(time.Millisecond * 200))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return received.String()
+		}
+		received.Write(buf[:n])
+	}
+}
+
+func TestStatsdFlushOnClose(t *testing.T) {
+	for _, format := range []string{TagFormatLegacy, TagFormatNone} {
+		format := format
+		t.Run(format, func(t *testing.T) {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			conf := NewConfig()
+			conf.Type = TypeStatsd
+			conf.Statsd.Address = conn.LocalAddr().String()
+			conf.Statsd.FlushPeriod = "1h"
+			conf.Statsd.TagFormat = format
+
+			s, err := New(conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s.GetCounter("final.count").Incr(3)
+			if err = WaitForClose(s, time.Second*5); err != nil {
+				t.Fatal(err)
+			}
+
+			if received := readStatsdPackets(t, conn); !strings.Contains(received, "benthos.final.count:3|c") {
+				t.Errorf("Counter was not flushed on close: %q", received)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
 )
@@ -86,7 +87,9 @@ type Type interface {
 	// SetLogger sets the logging mechanism of the metrics type.
 	SetLogger(log log.Modular)
 
-	// Close stops aggregating stats and cleans up resources.
+	// Close stops aggregating stats and cleans up resources. Types that buffer
+	// stats before pushing them to a target must flush any pending stats
+	// before returning.
 	Close() error
 }
 
@@ -100,3 +103,26 @@ type WithHandlerFunc interface {
 }
 
 //------------------------------------------------------------------------------
+
+// WaitForClose closes a metrics type, which flushes any stats that are pending
+// to be pushed, and blocks until it has finished closing or the timeout period
+// is reached. A timeout of zero waits indefinitely.
+func WaitForClose(t Type, timeout time.Duration) error {
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- t.Close()
+	}()
+
+	var timeoutChan <-chan time.Time
+	if timeout > 0 {
+		timeoutChan = time.After(timeout)
+	}
+	select {
+	case err := <-errChan:
+		return err
+	case <-timeoutChan:
+		return ErrTimedOut
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -10,6 +10,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/message/tracing"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
 	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
 	"github.com/Jeffail/benthos/v3/lib/util/throttle"
@@ -34,6 +35,24 @@ type AsyncSink interface {
 	types.Closable
 }
 
+// AsyncSinkTargets is an optional interface for an AsyncSink that writes the
+// messages of a batch to named targets, such as topics, where the records that
+// were written are then used for the summaries of sent batches.
+type AsyncSinkTargets interface {
+	// WriteWithTargets behaves the same as WriteWithContext and also returns
+	// the count, size and unique targets of the records that were written.
+	WriteWithTargets(ctx context.Context, msg types.Message) (writer.SentBatch, error)
+}
+
+// BatchSummary describes a batch of messages that was successfully sent by an
+// AsyncWriter.
+type BatchSummary struct {
+	Count    int
+	Bytes    int
+	Targets  []string
+	Duration time.Duration
+}
+
 // AsyncWriter is an output type that writes messages to a writer.Type.
 type AsyncWriter struct {
 	running     int32
@@ -46,6 +65,9 @@ type AsyncWriter struct {
 	log   log.Modular
 	stats metrics.Type
 
+	onBatchSent func(BatchSummary)
+	orderKeys   func(types.Message) []string
+
 	transactions <-chan types.Transaction
 
 	ctx           context.Context
@@ -63,6 +85,7 @@ func NewAsyncWriter(
 	w AsyncSink,
 	log log.Modular,
 	stats metrics.Type,
+	opts ...func(*AsyncWriter),
 ) (Type, error) {
 	aWriter := &AsyncWriter{
 		running:      1,
@@ -78,16 +101,89 @@ func NewAsyncWriter(
 	aWriter.ctx, aWriter.close = context.WithCancel(context.Background())
 	aWriter.fullyCloseCtx, aWriter.fullyClose = context.WithCancel(context.Background())
 
+	for _, opt := range opts {
+		opt(aWriter)
+	}
 	return aWriter, nil
 }
 
+// OptAsyncWriterSetOnBatchSent sets a function that is called with a summary of
+// each batch after it is successfully sent. The function is called from
+// parallel goroutines when more than one batch is in flight.
+func OptAsyncWriterSetOnBatchSent(fn func(BatchSummary)) func(*AsyncWriter) {
+	return func(w *AsyncWriter) {
+		w.onBatchSent = fn
+	}
+}
+
+// OptAsyncWriterOrderBy sets a function that returns the ordering keys of a
+// batch. Batches that share an ordering key are written in the order that they
+// were received, even when more than one batch is in flight, whereas batches
+// that share no keys are written in parallel.
+func OptAsyncWriterOrderBy(fn func(types.Message) []string) func(*AsyncWriter) {
+	return func(w *AsyncWriter) {
+		w.orderKeys = fn
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// keySequencer tracks the most recently received in-flight batch of each
+// ordering key so that later batches sharing a key can wait for it.
+type keySequencer struct {
+	mut   sync.Mutex
+	tails map[string]chan struct{}
+}
+
+func newKeySequencer() *keySequencer {
+	return &keySequencer{
+		tails: map[string]chan struct{}{},
+	}
+}
+
+// register records a batch as the latest of each of its keys and returns the
+// batches that must complete before it is written, along with a channel to
+// release once it has completed. Batches must be registered in the order they
+// were received.
+func (s *keySequencer) register(keys []string) (waitFor []chan struct{}, done chan struct{}) {
+	done = make(chan struct{})
+	s.mut.Lock()
+	for _, k := range keys {
+		if prev, exists := s.tails[k]; exists && prev != done {
+			waitFor = append(waitFor, prev)
+		}
+		s.tails[k] = done
+	}
+	s.mut.Unlock()
+	return
+}
+
+// release marks a registered batch as complete.
+func (s *keySequencer) release(keys []string, done chan struct{}) {
+	s.mut.Lock()
+	for _, k := range keys {
+		if s.tails[k] == done {
+			delete(s.tails, k)
+		}
+	}
+	s.mut.Unlock()
+	close(done)
+}
+
 //------------------------------------------------------------------------------
 
-func (w *AsyncWriter) latencyMeasuringWrite(msg types.Message) (latencyNs int64, err error) {
+func (w *AsyncWriter) latencyMeasuringWrite(msg types.Message) (latencyNs int64, sent *writer.SentBatch, err error) {
 	t0 := time.Now()
-	err = w.writer.WriteWithContext(w.ctx, msg)
+	if t, ok := w.writer.(AsyncSinkTargets); ok && w.onBatchSent != nil {
+		var s writer.SentBatch
+		if s, err = t.WriteWithTargets(w.ctx, msg); err == nil {
+			sent = &s
+		}
+	} else {
+		err = w.writer.WriteWithContext(w.ctx, msg)
+	}
 	latencyNs = time.Since(t0).Nanoseconds()
-	return latencyNs, err
+	return latencyNs, sent, err
 }
 
 // loop is an internal loop that brokers incoming messages to output pipe.
@@ -105,6 +201,7 @@ func (w *AsyncWriter) loop() {
 	)
 
 	defer func() {
+		w.writer.CloseAsync()
 		err := w.writer.WaitForClose(time.Second)
 		for ; err != nil; err = w.writer.WaitForClose(time.Second) {
 		}
@@ -137,7 +234,7 @@ func (w *AsyncWriter) loop() {
 	wg.Add(w.maxInflight)
 
 	connectMut := sync.Mutex{}
-	connectLoop := func(msg types.Message) (latency int64, err error) {
+	connectLoop := func(msg types.Message) (latency int64, sent *writer.SentBatch, err error) {
 		atomic.StoreInt32(&w.isConnected, 0)
 
 		connectMut.Lock()
@@ -146,7 +243,7 @@ func (w *AsyncWriter) loop() {
 		// If another goroutine got here first and we're able to send over the
 		// connection, then we gracefully accept defeat.
 		if atomic.LoadInt32(&w.isConnected) == 1 {
-			if latency, err = w.latencyMeasuringWrite(msg); err != types.ErrNotConnected {
+			if latency, sent, err = w.latencyMeasuringWrite(msg); err != types.ErrNotConnected {
 				return
 			}
 		}
@@ -165,7 +262,7 @@ func (w *AsyncWriter) loop() {
 				if !throt.Retry() {
 					return
 				}
-			} else if latency, err = w.latencyMeasuringWrite(msg); err != types.ErrNotConnected {
+			} else if latency, sent, err = w.latencyMeasuringWrite(msg); err != types.ErrNotConnected {
 				atomic.StoreInt32(&w.isConnected, 1)
 				mConn.Incr(1)
 				break
@@ -176,29 +273,81 @@ func (w *AsyncWriter) loop() {
 		return
 	}
 
+	batchSent := func(msg types.Message, bytes int, latency int64, sent *writer.SentBatch) {
+		summary := BatchSummary{
+			Count:    msg.Len(),
+			Bytes:    bytes,
+			Duration: time.Duration(latency),
+		}
+		if sent != nil {
+			summary.Count = sent.Count
+			summary.Bytes = sent.Bytes
+			summary.Targets = sent.Targets
+		}
+		w.onBatchSent(summary)
+	}
+
+	var seq *keySequencer
+	var readMut sync.Mutex
+	if w.orderKeys != nil {
+		seq = newKeySequencer()
+	}
+
+	// read obtains the next transaction and, when ordering keys are
+	// configured, registers it with the sequencer. Reading and registering
+	// happen under a single lock so that the registered order matches the
+	// received order.
+	read := func() (ts types.Transaction, keys []string, waitFor []chan struct{}, done chan struct{}, open bool) {
+		if seq != nil {
+			readMut.Lock()
+			defer readMut.Unlock()
+		}
+		select {
+		case ts, open = <-w.transactions:
+			if !open {
+				return
+			}
+			mCount.Incr(1)
+		case <-w.ctx.Done():
+			return
+		}
+		if seq != nil {
+			keys = w.orderKeys(ts.Payload)
+			waitFor, done = seq.register(keys)
+		}
+		return
+	}
+
 	writerLoop := func() {
 		defer wg.Done()
 
 		for atomic.LoadInt32(&w.running) == 1 {
-			var ts types.Transaction
-			var open bool
-			select {
-			case ts, open = <-w.transactions:
-				if !open {
-					return
-				}
-				mCount.Incr(1)
-			case <-w.ctx.Done():
+			ts, keys, waitFor, done, open := read()
+			if !open {
 				return
 			}
+			if done != nil {
+				for _, prev := range waitFor {
+					select {
+					case <-prev:
+					case <-w.ctx.Done():
+						seq.release(keys, done)
+						return
+					}
+				}
+			}
 
 			w.log.Tracef("Attempting to write %v messages to '%v'.\n", ts.Payload.Len(), w.typeStr)
 			spans := tracing.CreateChildSpans("output_"+w.typeStr, ts.Payload)
-			latency, err := w.latencyMeasuringWrite(ts.Payload)
+			latency, sent, err := w.latencyMeasuringWrite(ts.Payload)
 
 			// If our writer says it is not connected.
 			if err == types.ErrNotConnected {
-				latency, err = connectLoop(ts.Payload)
+				latency, sent, err = connectLoop(ts.Payload)
+			}
+
+			if done != nil {
+				seq.release(keys, done)
 			}
 
 			// Close immediately if our writer is closed.
@@ -212,10 +361,14 @@ func (w *AsyncWriter) loop() {
 					return
 				}
 			} else {
+				bytes := message.GetAllBytesLen(ts.Payload)
 				mSent.Incr(1)
 				mPartsSent.Incr(int64(ts.Payload.Len()))
-				mBytesSent.Incr(int64(message.GetAllBytesLen(ts.Payload)))
+				mBytesSent.Incr(int64(bytes))
 				mLatency.Timing(latency)
+				if w.onBatchSent != nil {
+					batchSent(ts.Payload, bytes, latency, sent)
+				}
 				w.log.Tracef("Successfully wrote %v messages to '%v'.\n", ts.Payload.Len(), w.typeStr)
 				throt.Reset() // TODO BAD PAYLOAD NAUGHTY RESETS
 			}
@@ -3,13 +3,19 @@ package output
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math/rand"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
 	"github.com/Jeffail/benthos/v3/lib/types"
 )
 
@@ -634,3 +640,208 @@ func TestAsyncWriterSadPath(t *testing.T) {
 }
 
 //------------------------------------------------------------------------------
+
+type mockAsyncTargetsWriter struct {
+	*mockAsyncWriter
+}
+
+func (w mockAsyncTargetsWriter) WriteWithTargets(ctx context.Context, msg types.Message) (writer.SentBatch, error) {
+	if err := w.WriteWithContext(ctx, msg); err != nil {
+		return writer.SentBatch{}, err
+	}
+	return writer.SentBatch{
+		Count:   msg.Len() - 1,
+		Bytes:   5,
+		Targets: []string{"foo", "bar"},
+	}, nil
+}
+
+func TestAsyncWriterOnBatchSent(t *testing.T) {
+	t.Parallel()
+
+	writerImpl := mockAsyncTargetsWriter{newAsyncMockWriter()}
+	summaries := make(chan BatchSummary, 1)
+
+	w, err := NewAsyncWriter(
+		"foo", 1, writerImpl,
+		log.Noop(), metrics.Noop(),
+		OptAsyncWriterSetOnBatchSent(func(s BatchSummary) {
+			summaries <- s
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+	if err = w.Consume(msgChan); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case writerImpl.connChan <- nil:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	for _, writeErr := range []error{errors.New("test err"), nil} {
+		select {
+		case msgChan <- types.NewTransaction(message.New([][]byte{[]byte("hello"), []byte("world!")}), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out")
+		}
+		select {
+		case writerImpl.writeChan <- writeErr:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out")
+		}
+		select {
+		case <-resChan:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out")
+		}
+	}
+
+	select {
+	case s := <-summaries:
+		if exp, act := 1, s.Count; exp != act {
+			t.Errorf("Wrong count: %v != %v", act, exp)
+		}
+		if exp, act := 5, s.Bytes; exp != act {
+			t.Errorf("Wrong bytes: %v != %v", act, exp)
+		}
+		if exp, act := []string{"foo", "bar"}, s.Targets; !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong targets: %v != %v", act, exp)
+		}
+		if s.Duration <= 0 {
+			t.Errorf("Expected a positive duration: %v", s.Duration)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+	select {
+	case s := <-summaries:
+		t.Errorf("Unexpected summary of a failed batch: %v", s)
+	default:
+	}
+
+	w.CloseAsync()
+	if err = w.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type orderRecordingWriter struct {
+	mut         sync.Mutex
+	written     map[string][]int
+	inFlight    int
+	maxInFlight int
+}
+
+func (w *orderRecordingWriter) ConnectWithContext(ctx context.Context) error {
+	return nil
+}
+
+func (w *orderRecordingWriter) WriteWithContext(ctx context.Context, msg types.Message) error {
+	w.mut.Lock()
+	w.inFlight++
+	if w.inFlight > w.maxInFlight {
+		w.maxInFlight = w.inFlight
+	}
+	w.mut.Unlock()
+
+	<-time.After(time.Duration(rand.Intn(5)) * time.Millisecond)
+
+	parts := strings.Split(string(msg.Get(0).Get()), ":")
+	seq, _ := strconv.Atoi(parts[1])
+
+	w.mut.Lock()
+	w.inFlight--
+	w.written[parts[0]] = append(w.written[parts[0]], seq)
+	w.mut.Unlock()
+	return nil
+}
+
+func (w *orderRecordingWriter) CloseAsync() {}
+
+func (w *orderRecordingWriter) WaitForClose(time.Duration) error {
+	return nil
+}
+
+func TestAsyncWriterOrderBy(t *testing.T) {
+	t.Parallel()
+
+	writerImpl := &orderRecordingWriter{
+		written: map[string][]int{},
+	}
+	orderKeys := func(msg types.Message) []string {
+		return []string{strings.Split(string(msg.Get(0).Get()), ":")[0]}
+	}
+
+	w, err := NewAsyncWriter(
+		"foo", 8, writerImpl, log.Noop(), metrics.Noop(),
+		OptAsyncWriterOrderBy(orderKeys),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgChan := make(chan types.Transaction)
+	if err = w.Consume(msgChan); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{"a", "b", "c", "d"}
+	nPerKey := 20
+
+	resChan := make(chan types.Response, len(keys)*nPerKey)
+	for i := 0; i < nPerKey; i++ {
+		for _, k := range keys {
+			content := []byte(fmt.Sprintf("%v:%v", k, i))
+			select {
+			case msgChan <- types.NewTransaction(message.New([][]byte{content}), resChan):
+			case <-time.After(time.Second):
+				t.Fatal("Timed out")
+			}
+		}
+	}
+	for i := 0; i < len(keys)*nPerKey; i++ {
+		select {
+		case res := <-resChan:
+			if res.Error() != nil {
+				t.Error(res.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out")
+		}
+	}
+
+	writerImpl.mut.Lock()
+	for _, k := range keys {
+		seqs := writerImpl.written[k]
+		if len(seqs) != nPerKey {
+			t.Errorf("Wrong count of writes for key %v: %v != %v", k, len(seqs), nPerKey)
+		}
+		for i, seq := range seqs {
+			if seq != i {
+				t.Errorf("Wrong order of writes for key %v: %v", k, seqs)
+				break
+			}
+		}
+	}
+	if writerImpl.maxInFlight < 2 {
+		t.Errorf("Expected batches of different keys to be written in parallel, max in flight: %v", writerImpl.maxInFlight)
+	}
+	if writerImpl.maxInFlight > len(keys) {
+		t.Errorf("Expected batches of the same key to be written sequentially, max in flight: %v", writerImpl.maxInFlight)
+	}
+	writerImpl.mut.Unlock()
+
+	w.CloseAsync()
+	if err = w.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/message/batch"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
 )
 
@@ -74,6 +76,7 @@ func (m *Batcher) loop() {
 	}
 
 	var pendingResChans []chan<- types.Response
+	var pendingPartCounts []int
 	for atomic.LoadInt32(&m.running) == 1 {
 		if nextTimedBatchChan == nil {
 			if tNext := m.batcher.UntilNext(); tNext >= 0 {
@@ -97,6 +100,7 @@ func (m *Batcher) loop() {
 					}
 				}
 			} else {
+				wasEmpty := m.batcher.Count() == 0
 				tran.Payload.Iter(func(i int, p types.Part) error {
 					if m.batcher.Add(p) {
 						flushBatch = true
@@ -104,10 +108,17 @@ func (m *Batcher) loop() {
 					return nil
 				})
 				pendingResChans = append(pendingResChans, tran.ResponseChan)
+				pendingPartCounts = append(pendingPartCounts, tran.Payload.Len())
+				if wasEmpty {
+					// An idle period may bring the next timed flush forward.
+					nextTimedBatchChan = nil
+				}
 			}
 		case <-nextTimedBatchChan:
-			flushBatch = true
 			nextTimedBatchChan = nil
+			// Messages added since the timer was set may have pushed an idle
+			// flush back.
+			flushBatch = m.batcher.UntilNext() <= 0
 		case <-m.closeChan:
 			atomic.StoreInt32(&m.running, 0)
 			flushBatch = true
@@ -122,31 +133,132 @@ func (m *Batcher) loop() {
 			continue
 		}
 
-		resChan := make(chan types.Response)
-		select {
-		case m.messagesOut <- types.NewTransaction(sendMsg, resChan):
-		case <-m.fullyCloseChan:
-			return
+		groups := m.batcher.Groups(sendMsg)
+		resChans := make([]chan types.Response, len(groups))
+		for i := range resChans {
+			resChans[i] = make(chan types.Response)
 		}
 
-		go func(rChan chan types.Response, upstreamResChans []chan<- types.Response) {
-			select {
-			case <-m.fullyCloseChan:
-				return
-			case res, open := <-rChan:
-				if !open {
+		go func(rChans []chan types.Response, upstreamResChans []chan<- types.Response, upstreamPartCounts []int) {
+			results := make([]types.Response, len(rChans))
+			for i, rChan := range rChans {
+				select {
+				case <-m.fullyCloseChan:
 					return
-				}
-				for _, c := range upstreamResChans {
-					select {
-					case <-m.fullyCloseChan:
+				case res, open := <-rChan:
+					if !open {
 						return
-					case c <- res:
 					}
+					results[i] = res
+				}
+			}
+			res := results[0]
+			if len(results) > 1 {
+				res = groupedResponse(sendMsg, groups, results)
+			}
+			resFor := func(int) types.Response { return res }
+			if bErr, ok := res.Error().(*batch.Error); ok && bErr.IndexedErrors() > 0 {
+				resFor = partialResponses(bErr, upstreamPartCounts)
+			}
+			for i, c := range upstreamResChans {
+				select {
+				case <-m.fullyCloseChan:
+					return
+				case c <- resFor(i):
 				}
 			}
-		}(resChan, pendingResChans)
+		}(resChans, pendingResChans, pendingPartCounts)
 		pendingResChans = nil
+		pendingPartCounts = nil
+
+		// Each group is sent as its own batch, responses are read as they
+		// arrive in order to avoid blocking a child with a single transaction
+		// in flight.
+		for i, g := range groups {
+			groupMsg := sendMsg
+			if len(groups) > 1 {
+				groupMsg = message.New(nil)
+				for _, j := range g {
+					groupMsg.Append(sendMsg.Get(j))
+				}
+			}
+			select {
+			case m.messagesOut <- types.NewTransaction(groupMsg, resChans[i]):
+			case <-m.fullyCloseChan:
+				return
+			}
+		}
+	}
+}
+
+// groupedResponse combines the responses of the groups of a batch that were
+// sent separately into a single response for the whole batch, where a batch
+// error records the parts of each group that failed.
+func groupedResponse(msg types.Message, groups [][]int, results []types.Response) types.Response {
+	var bErr *batch.Error
+	for i, res := range results {
+		err := res.Error()
+		if err == nil {
+			continue
+		}
+		if bErr == nil {
+			bErr = batch.NewError(msg, err)
+		}
+		gErr, isBatch := err.(*batch.Error)
+		for j, index := range groups[i] {
+			if isBatch {
+				if pErr := gErr.PartError(j); pErr != nil {
+					bErr.Failed(index, pErr)
+				}
+				continue
+			}
+			bErr.Failed(index, err)
+		}
+	}
+	if bErr == nil {
+		return response.NewAck()
+	}
+	return response.NewError(bErr)
+}
+
+// partialResponses returns a function providing the response of each upstream
+// transaction of a batch from the per part errors of a batch error, where only
+// transactions containing failed parts receive the error.
+func partialResponses(bErr *batch.Error, partCounts []int) func(int) types.Response {
+	var partErrs []error
+	bErr.WalkParts(func(_ int, _ types.Part, err error) bool {
+		partErrs = append(partErrs, err)
+		return true
+	})
+
+	total := 0
+	for _, c := range partCounts {
+		total += c
+	}
+
+	failed := make([]bool, len(partCounts))
+	offset := 0
+	for i, c := range partCounts {
+		if total != len(partErrs) {
+			// The batch error does not correspond to the batch we sent, and
+			// so we cannot know which transactions succeeded.
+			failed[i] = true
+			continue
+		}
+		for _, err := range partErrs[offset : offset+c] {
+			if err != nil {
+				failed[i] = true
+				break
+			}
+		}
+		offset += c
+	}
+
+	return func(i int) types.Response {
+		if failed[i] {
+			return response.NewError(bErr)
+		}
+		return response.NewAck()
 	}
 }
 
@@ -244,4 +244,324 @@ func TestBatcherTimed(t *testing.T) {
 	close(tInChan)
 }
 
+func TestBatcherIdlePeriod(t *testing.T) {
+	tInChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	policyConf := batch.NewPolicyConfig()
+	policyConf.Count = 3
+	policyConf.Period = "1h"
+	policyConf.IdlePeriod = "100ms"
+	batcher, err := batch.NewPolicy(policyConf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &mockOutput{}
+
+	b := NewBatcher(batcher, out, log.Noop(), metrics.Noop())
+	if err := b.Consume(tInChan); err != nil {
+		t.Fatal(err)
+	}
+
+	tOutChan := out.ts
+
+	expectBatch := func(exp [][]byte) {
+		t.Helper()
+		var outTr types.Transaction
+		select {
+		case outTr = <-tOutChan:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for message read")
+		}
+		if act := message.GetAllBytes(outTr.Payload); !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong result from batch: %s != %s", act, exp)
+		}
+		select {
+		case outTr.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for response read")
+		}
+	}
+
+	send := func(parts ...string) {
+		t.Helper()
+		msg := message.New(nil)
+		for _, p := range parts {
+			msg.Append(message.NewPart([]byte(p)))
+		}
+		select {
+		case tInChan <- types.NewTransaction(msg, resChan):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for message send")
+		}
+	}
+
+	awaitRes := func() {
+		t.Helper()
+		select {
+		case res := <-resChan:
+			if res.Error() != nil {
+				t.Error(res.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for response")
+		}
+	}
+
+	// A trickle of messages is flushed once idle rather than after the period.
+	send("foo1")
+	<-time.After(time.Millisecond * 50)
+	send("foo2")
+	expectBatch([][]byte{[]byte("foo1"), []byte("foo2")})
+	awaitRes()
+	awaitRes()
+
+	// A burst is still flushed on count.
+	send("bar1", "bar2", "bar3")
+	expectBatch([][]byte{[]byte("bar1"), []byte("bar2"), []byte("bar3")})
+	awaitRes()
+
+	b.CloseAsync()
+	if err = b.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+
+	close(resChan)
+	close(tInChan)
+}
+
+func TestBatcherPartialErrors(t *testing.T) {
+	tInChan := make(chan types.Transaction)
+
+	policyConf := batch.NewPolicyConfig()
+	policyConf.Count = 4
+	batcher, err := batch.NewPolicy(policyConf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &mockOutput{}
+
+	b := NewBatcher(batcher, out, log.Noop(), metrics.Noop())
+	if err := b.Consume(tInChan); err != nil {
+		t.Fatal(err)
+	}
+
+	tOutChan := out.ts
+
+	// Three transactions make up the batch, the second contains two parts.
+	inputs := [][][]byte{
+		{[]byte("foo")},
+		{[]byte("bar"), []byte("baz")},
+		{[]byte("qux")},
+	}
+	resChans := make([]chan types.Response, len(inputs))
+	for i, parts := range inputs {
+		resChans[i] = make(chan types.Response, 1)
+		select {
+		case tInChan <- types.NewTransaction(message.New(parts), resChans[i]):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for message send")
+		}
+	}
+
+	var outTr types.Transaction
+	select {
+	case outTr = <-tOutChan:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for message read")
+	}
+	if exp, act := 4, outTr.Payload.Len(); exp != act {
+		t.Fatalf("Wrong batch size: %v != %v", act, exp)
+	}
+
+	errPart := errors.New("part failed")
+	bErr := batch.NewError(outTr.Payload, errors.New("batch failed")).Failed(2, errPart)
+	select {
+	case outTr.ResponseChan <- response.NewError(bErr):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for response read")
+	}
+
+	for i, expFailed := range []bool{false, true, false} {
+		select {
+		case res := <-resChans[i]:
+			if expFailed && res.Error() != bErr {
+				t.Errorf("Expected transaction %v to fail, got: %v", i, res.Error())
+			}
+			if !expFailed && res.Error() != nil {
+				t.Errorf("Expected transaction %v to succeed, got: %v", i, res.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for response")
+		}
+	}
+
+	b.CloseAsync()
+	if err = b.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+
+	close(tInChan)
+}
+
+func TestBatcherGroupBy(t *testing.T) {
+	tInChan := make(chan types.Transaction)
+
+	policyConf := batch.NewPolicyConfig()
+	policyConf.Count = 4
+	policyConf.GroupBy = "${!metadata:key}"
+	batcher, err := batch.NewPolicy(policyConf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &mockOutput{}
+
+	b := NewBatcher(batcher, out, log.Noop(), metrics.Noop())
+	if err := b.Consume(tInChan); err != nil {
+		t.Fatal(err)
+	}
+
+	tOutChan := out.ts
+
+	keys := []string{"a", "b", "a", "b"}
+	resChans := make([]chan types.Response, len(keys))
+	for i, key := range keys {
+		resChans[i] = make(chan types.Response, 1)
+		msg := message.New([][]byte{[]byte(fmt.Sprintf("foo %v", i))})
+		msg.Get(0).Metadata().Set("key", key)
+		select {
+		case tInChan <- types.NewTransaction(msg, resChans[i]):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for message send")
+		}
+	}
+
+	errGroup := errors.New("group failed")
+	for _, exp := range []struct {
+		key   string
+		parts []string
+		err   error
+	}{
+		{key: "a", parts: []string{"foo 0", "foo 2"}},
+		{key: "b", parts: []string{"foo 1", "foo 3"}, err: errGroup},
+	} {
+		var outTr types.Transaction
+		select {
+		case outTr = <-tOutChan:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for message read")
+		}
+		var act []string
+		outTr.Payload.Iter(func(i int, p types.Part) error {
+			if key := p.Metadata().Get("key"); key != exp.key {
+				t.Errorf("Wrong key of part %v: %v != %v", i, key, exp.key)
+			}
+			act = append(act, string(p.Get()))
+			return nil
+		})
+		if !reflect.DeepEqual(exp.parts, act) {
+			t.Errorf("Wrong group contents: %v != %v", act, exp.parts)
+		}
+		select {
+		case outTr.ResponseChan <- response.NewError(exp.err):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for response read")
+		}
+	}
+
+	for i, expFailed := range []bool{false, true, false, true} {
+		select {
+		case res := <-resChans[i]:
+			if expFailed && res.Error() == nil {
+				t.Errorf("Expected transaction %v to fail", i)
+			}
+			if !expFailed && res.Error() != nil {
+				t.Errorf("Expected transaction %v to succeed, got: %v", i, res.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for response")
+		}
+	}
+
+	b.CloseAsync()
+	if err = b.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+
+	close(tInChan)
+}
+
+func TestBatcherMaxParts(t *testing.T) {
+	tInChan := make(chan types.Transaction)
+
+	policyConf := batch.NewPolicyConfig()
+	policyConf.Count = 10
+	policyConf.MaxParts = 2
+	batcher, err := batch.NewPolicy(policyConf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &mockOutput{}
+
+	b := NewBatcher(batcher, out, log.Noop(), metrics.Noop())
+	if err := b.Consume(tInChan); err != nil {
+		t.Fatal(err)
+	}
+
+	tOutChan := out.ts
+
+	resChan := make(chan types.Response, 1)
+	select {
+	case tInChan <- types.NewTransaction(message.New([][]byte{
+		[]byte("foo 0"), []byte("foo 1"), []byte("foo 2"),
+	}), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for message send")
+	}
+
+	errChunk := errors.New("chunk failed")
+	for _, exp := range []struct {
+		parts [][]byte
+		err   error
+	}{
+		{parts: [][]byte{[]byte("foo 0"), []byte("foo 1")}},
+		{parts: [][]byte{[]byte("foo 2")}, err: errChunk},
+	} {
+		var outTr types.Transaction
+		select {
+		case outTr = <-tOutChan:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for message read")
+		}
+		if act := message.GetAllBytes(outTr.Payload); !reflect.DeepEqual(exp.parts, act) {
+			t.Errorf("Wrong chunk contents: %s != %s", act, exp.parts)
+		}
+		select {
+		case outTr.ResponseChan <- response.NewError(exp.err):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for response read")
+		}
+	}
+
+	select {
+	case res := <-resChan:
+		if res.Error() == nil {
+			t.Error("Expected transaction to fail")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for response")
+	}
+
+	b.CloseAsync()
+	if err = b.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+
+	close(tInChan)
+}
+
 //------------------------------------------------------------------------------
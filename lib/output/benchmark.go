@@ -0,0 +1,61 @@
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/x/docs"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeBenchmark] = TypeSpec{
+		constructor: NewBenchmark,
+		Description: `
+Discards all messages whilst recording their throughput, which is useful for
+measuring the maximum throughput of the inputs and processors of a config
+without a real sink.
+
+Messages are acknowledged once each write completes, optionally after a
+simulated ` + "`latency`" + `, and with ` + "`max_in_flight`" + ` set above one
+writes are acknowledged asynchronously in parallel as with real outputs, and
+therefore the measured backpressure is realistic.
+
+### Metrics
+
+The counters ` + "`benchmark.messages` and `benchmark.bytes`" + ` are
+incremented with the number of messages and bytes of each write, the gauges
+` + "`benchmark.messages_per_sec` and `benchmark.bytes_per_sec`" + ` are set to
+the throughput rates observed over the last interval, and the timer
+` + "`benchmark.latency`" + ` records the duration from each message being
+created, which is when it was received by the input or, when batching is
+configured, when its batch was formed, until it is acknowledged.
+
+When ` + "`summary_interval`" + ` is set a summary of the throughput and latency
+of each interval is logged at the ` + "`INFO`" + ` level, and the rate gauges
+are updated at the same interval rather than each second.`,
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("max_in_flight", "The maximum number of writes to have in flight at any given time."),
+			docs.FieldAdvanced("latency", "A fixed duration to wait before each write is acknowledged.", "10ms"),
+			docs.FieldCommon("summary_interval", "An optional interval at which to log a throughput summary.", "5s", "1m"),
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewBenchmark creates a new Benchmark output type.
+func NewBenchmark(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	b, err := writer.NewBenchmark(conf.Benchmark, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Benchmark.MaxInFlight == 1 {
+		return NewWriter(TypeBenchmark, b, log, stats)
+	}
+	return NewAsyncWriter(TypeBenchmark, conf.Benchmark.MaxInFlight, b, log, stats)
+}
+
+//------------------------------------------------------------------------------
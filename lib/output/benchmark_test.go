@@ -0,0 +1,61 @@
+package output
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+func TestBenchmarkParallelAcks(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeBenchmark
+	conf.Benchmark.MaxInFlight = 4
+	conf.Benchmark.Latency = "200ms"
+
+	stats := metrics.NewLocal()
+	output, err := New(conf, types.NoopMgr(), log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+	if err = output.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	tStarted := time.Now()
+	for i := 0; i < 4; i++ {
+		select {
+		case tChan <- types.NewTransaction(message.New([][]byte{[]byte("foo")}), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+	for i := 0; i < 4; i++ {
+		select {
+		case res := <-resChan:
+			if res.Error() != nil {
+				t.Error(res.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+	if elapsed := time.Since(tStarted); elapsed >= time.Millisecond*600 {
+		t.Errorf("Expected writes to be acknowledged in parallel, took %v", elapsed)
+	}
+
+	output.CloseAsync()
+	if err = output.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+
+	if exp, act := int64(4), stats.GetCounters()["benchmark.messages"]; exp != act {
+		t.Errorf("Wrong count of messages: %v != %v", act, exp)
+	}
+}
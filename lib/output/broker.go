@@ -67,6 +67,15 @@ Similar to the fan out pattern except outputs are written to sequentially,
 meaning an output is only written to once the preceding output has confirmed
 receipt of the same message.
 
+` + "`broadcast`" + `
+
+With the broadcast pattern all outputs will be sent every message in parallel,
+and the message is only acknowledged once every output has acknowledged it. If
+any output fails to send the message then it is not retried by the broker,
+instead the error is returned upstream so that the message can be retried (or
+rejected) by the input. Since the whole message is retried, outputs that had
+already succeeded will receive it again.
+
 ` + "`round_robin`" + `
 
 With the round robin pattern each message will be assigned a single output
@@ -226,6 +235,8 @@ func NewBroker(
 		b, err = broker.NewFanOut(outputs, log, stats)
 	case "fan_out_sequential":
 		b, err = broker.NewFanOutSequential(outputs, log, stats)
+	case "broadcast":
+		b, err = broker.NewBroadcast(outputs, log, stats)
 	case "round_robin":
 		b, err = broker.NewRoundRobin(outputs, stats)
 	case "greedy":
@@ -0,0 +1,371 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/x/docs"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeCircuitBreaker] = TypeSpec{
+		constructor: NewCircuitBreaker,
+		Description: `
+Wraps a child output and stops sending messages to it after a number of
+consecutive failures, rejecting messages immediately with a not connected error
+instead of waiting on an output that is persistently failing.
+
+` + "``` yaml" + `
+output:
+  circuit_breaker:
+    threshold: 5
+    cooldown: 30s
+    output:
+      http_client:
+        url: http://localhost:4195/post
+` + "```" + `
+
+The breaker starts closed, where messages are sent to the child output as
+normal. Once ` + "`threshold`" + ` consecutive sends have failed the breaker
+opens and all messages are rejected until the ` + "`cooldown`" + ` period has
+passed, at which point the breaker is half-open and a single message is sent to
+the child output as a trial, with other messages rejected until it completes.
+If the trial succeeds the breaker closes again, otherwise it reopens for another
+cooldown period.
+
+Messages are sent to the child output in parallel while the breaker is closed,
+and therefore the ` + "`max_in_flight`" + ` of the child output is honoured.
+
+### Metrics
+
+The counters ` + "`circuit_breaker.opened`, `circuit_breaker.half_opened` and `circuit_breaker.closed`" + `
+are incremented on each transition to the respective state, and
+` + "`circuit_breaker.rejected`" + ` is incremented for each message rejected
+while the breaker is open or a trial message is in flight.`,
+		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
+			var outputSanit interface{} = struct{}{}
+			if conf.CircuitBreaker.Output != nil {
+				var err error
+				if outputSanit, err = SanitiseConfig(*conf.CircuitBreaker.Output); err != nil {
+					return nil, err
+				}
+			}
+			return map[string]interface{}{
+				"threshold": conf.CircuitBreaker.Threshold,
+				"cooldown":  conf.CircuitBreaker.Cooldown,
+				"output":    outputSanit,
+			}, nil
+		},
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("threshold", "The number of consecutive failed sends after which the breaker opens."),
+			docs.FieldCommon("cooldown", "The period of time to reject messages for once the breaker opens, after which a single trial message is sent."),
+			docs.FieldCommon("output", "The child output to wrap."),
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// CircuitBreakerConfig contains configuration values for the CircuitBreaker
+// output type.
+type CircuitBreakerConfig struct {
+	Threshold int     `json:"threshold" yaml:"threshold"`
+	Cooldown  string  `json:"cooldown" yaml:"cooldown"`
+	Output    *Config `json:"output" yaml:"output"`
+}
+
+// NewCircuitBreakerConfig creates a new CircuitBreakerConfig with default
+// values.
+func NewCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		Threshold: 5,
+		Cooldown:  "30s",
+		Output:    nil,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type dummyCircuitBreakerConfig struct {
+	Threshold int         `json:"threshold" yaml:"threshold"`
+	Cooldown  string      `json:"cooldown" yaml:"cooldown"`
+	Output    interface{} `json:"output" yaml:"output"`
+}
+
+// MarshalJSON prints an empty object instead of nil.
+func (c CircuitBreakerConfig) MarshalJSON() ([]byte, error) {
+	dummy := dummyCircuitBreakerConfig{
+		Threshold: c.Threshold,
+		Cooldown:  c.Cooldown,
+		Output:    c.Output,
+	}
+	if c.Output == nil {
+		dummy.Output = struct{}{}
+	}
+	return json.Marshal(dummy)
+}
+
+// MarshalYAML prints an empty object instead of nil.
+func (c CircuitBreakerConfig) MarshalYAML() (interface{}, error) {
+	dummy := dummyCircuitBreakerConfig{
+		Threshold: c.Threshold,
+		Cooldown:  c.Cooldown,
+		Output:    c.Output,
+	}
+	if c.Output == nil {
+		dummy.Output = struct{}{}
+	}
+	return dummy, nil
+}
+
+//------------------------------------------------------------------------------
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// CircuitBreaker is an output type that stops sending messages to a child
+// output after a number of consecutive failures.
+type CircuitBreaker struct {
+	running int32
+
+	threshold int
+	cooldown  time.Duration
+
+	stateMut sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+
+	wrapped Type
+
+	stats metrics.Type
+	log   log.Modular
+
+	transactionsIn  <-chan types.Transaction
+	transactionsOut chan types.Transaction
+
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+// NewCircuitBreaker creates a new CircuitBreaker output type.
+func NewCircuitBreaker(
+	conf Config,
+	mgr types.Manager,
+	log log.Modular,
+	stats metrics.Type,
+) (Type, error) {
+	if conf.CircuitBreaker.Output == nil {
+		return nil, errors.New("cannot create a circuit_breaker output without a child")
+	}
+	if conf.CircuitBreaker.Threshold < 1 {
+		return nil, errors.New("threshold must be at least 1")
+	}
+	cooldown, err := time.ParseDuration(conf.CircuitBreaker.Cooldown)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cooldown: %v", err)
+	}
+
+	wrapped, err := New(*conf.CircuitBreaker.Output, mgr, log, stats)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output '%v': %v", conf.CircuitBreaker.Output.Type, err)
+	}
+
+	return &CircuitBreaker{
+		running: 1,
+
+		threshold: conf.CircuitBreaker.Threshold,
+		cooldown:  cooldown,
+		state:     breakerClosed,
+
+		log:             log,
+		stats:           stats,
+		wrapped:         wrapped,
+		transactionsOut: make(chan types.Transaction),
+
+		closeChan:  make(chan struct{}),
+		closedChan: make(chan struct{}),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// admit returns whether a transaction may be sent to the child output, and
+// whether it is the trial send of a half-open breaker.
+func (c *CircuitBreaker) admit(mHalfOpened metrics.StatCounter) (admitted, trial bool) {
+	c.stateMut.Lock()
+	defer c.stateMut.Unlock()
+
+	switch c.state {
+	case breakerHalfOpen:
+		// Only a single trial is in flight at a time.
+		return false, false
+	case breakerOpen:
+		if time.Since(c.openedAt) < c.cooldown {
+			return false, false
+		}
+		c.state = breakerHalfOpen
+		mHalfOpened.Incr(1)
+		c.log.Infoln("Circuit breaker half-open, sending trial message")
+		return true, true
+	}
+	return true, false
+}
+
+// record updates the state of the breaker with the result of a send.
+func (c *CircuitBreaker) record(err error, trial bool, mOpened, mClosed metrics.StatCounter) {
+	c.stateMut.Lock()
+	defer c.stateMut.Unlock()
+
+	if err == nil {
+		if trial {
+			c.state = breakerClosed
+			mClosed.Incr(1)
+			c.log.Infoln("Circuit breaker closed")
+		}
+		if c.state == breakerClosed {
+			c.failures = 0
+		}
+		return
+	}
+
+	// Results of sends made before the breaker opened are ignored once it has.
+	if !trial && c.state != breakerClosed {
+		return
+	}
+	c.failures++
+	if trial || c.failures >= c.threshold {
+		c.state = breakerOpen
+		c.openedAt = time.Now()
+		mOpened.Incr(1)
+		c.log.Warnf("Circuit breaker opened after %v consecutive failures: %v\n", c.failures, err)
+	}
+}
+
+func (c *CircuitBreaker) loop() {
+	// Metrics paths
+	var (
+		mOpened     = c.stats.GetCounter("circuit_breaker.opened")
+		mHalfOpened = c.stats.GetCounter("circuit_breaker.half_opened")
+		mClosed     = c.stats.GetCounter("circuit_breaker.closed")
+		mRejected   = c.stats.GetCounter("circuit_breaker.rejected")
+	)
+
+	wg := sync.WaitGroup{}
+
+	defer func() {
+		wg.Wait()
+		close(c.transactionsOut)
+		c.wrapped.CloseAsync()
+		err := c.wrapped.WaitForClose(time.Second)
+		for ; err != nil; err = c.wrapped.WaitForClose(time.Second) {
+		}
+		close(c.closedChan)
+	}()
+
+	for atomic.LoadInt32(&c.running) == 1 {
+		var ts types.Transaction
+		var open bool
+		select {
+		case ts, open = <-c.transactionsIn:
+			if !open {
+				return
+			}
+		case <-c.closeChan:
+			return
+		}
+
+		admitted, trial := c.admit(mHalfOpened)
+		if !admitted {
+			mRejected.Incr(1)
+			select {
+			case ts.ResponseChan <- response.NewError(types.ErrNotConnected):
+			case <-c.closeChan:
+				return
+			}
+			continue
+		}
+
+		resChan := make(chan types.Response)
+		select {
+		case c.transactionsOut <- types.NewTransaction(ts.Payload, resChan):
+		case <-c.closeChan:
+			return
+		}
+
+		// Responses are awaited asynchronously so that the child is able to
+		// have multiple transactions in flight.
+		wg.Add(1)
+		go func(ts types.Transaction, resChan chan types.Response, trial bool) {
+			defer wg.Done()
+
+			var res types.Response
+			select {
+			case res = <-resChan:
+			case <-c.closeChan:
+				return
+			}
+
+			c.record(res.Error(), trial, mOpened, mClosed)
+
+			select {
+			case ts.ResponseChan <- res:
+			case <-c.closeChan:
+			}
+		}(ts, resChan, trial)
+	}
+}
+
+// Consume assigns a messages channel for the output to read.
+func (c *CircuitBreaker) Consume(ts <-chan types.Transaction) error {
+	if c.transactionsIn != nil {
+		return types.ErrAlreadyStarted
+	}
+	if err := c.wrapped.Consume(c.transactionsOut); err != nil {
+		return err
+	}
+	c.transactionsIn = ts
+	go c.loop()
+	return nil
+}
+
+// Connected returns a boolean indicating whether this output is currently
+// connected to its target.
+func (c *CircuitBreaker) Connected() bool {
+	return c.wrapped.Connected()
+}
+
+// CloseAsync shuts down the CircuitBreaker output and stops processing
+// requests.
+func (c *CircuitBreaker) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&c.running, 1, 0) {
+		close(c.closeChan)
+	}
+}
+
+// WaitForClose blocks until the CircuitBreaker output has closed down.
+func (c *CircuitBreaker) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-c.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
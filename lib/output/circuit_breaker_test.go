@@ -0,0 +1,257 @@
+package output
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+func TestCircuitBreakerConfigErrs(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeCircuitBreaker
+	if _, err := New(conf, types.NoopMgr(), log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing child")
+	}
+
+	childConf := NewConfig()
+	conf.CircuitBreaker.Output = &childConf
+	conf.CircuitBreaker.Threshold = 0
+	if _, err := New(conf, types.NoopMgr(), log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from zero threshold")
+	}
+
+	conf.CircuitBreaker.Threshold = 1
+	conf.CircuitBreaker.Cooldown = "nope"
+	if _, err := New(conf, types.NoopMgr(), log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad cooldown")
+	}
+}
+
+func TestCircuitBreakerStates(t *testing.T) {
+	conf := NewConfig()
+	childConf := NewConfig()
+	conf.CircuitBreaker.Output = &childConf
+	conf.CircuitBreaker.Threshold = 2
+	conf.CircuitBreaker.Cooldown = "100ms"
+
+	stats := metrics.NewLocal()
+	output, err := NewCircuitBreaker(conf, types.NoopMgr(), log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mOut := &mockOutput{}
+	output.(*CircuitBreaker).wrapped = mOut
+
+	tChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+	if err = output.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func() {
+		t.Helper()
+		select {
+		case tChan <- types.NewTransaction(message.New([][]byte{[]byte("foo")}), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	respond := func(sendErr error) {
+		t.Helper()
+		var tran types.Transaction
+		select {
+		case tran = <-mOut.ts:
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		select {
+		case tran.ResponseChan <- response.NewError(sendErr):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	expectRes := func(exp error) {
+		t.Helper()
+		select {
+		case res := <-resChan:
+			if act := res.Error(); act != exp {
+				t.Errorf("Wrong response error: %v != %v", act, exp)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	expectRejected := func() {
+		t.Helper()
+		send()
+		expectRes(types.ErrNotConnected)
+		select {
+		case <-mOut.ts:
+			t.Error("Unexpected message sent to child while open")
+		default:
+		}
+	}
+
+	sendErr := errors.New("failed")
+
+	// Failures below the threshold keep the breaker closed.
+	send()
+	respond(sendErr)
+	expectRes(sendErr)
+	send()
+	respond(nil)
+	expectRes(nil)
+	send()
+	respond(sendErr)
+	expectRes(sendErr)
+
+	// Reaching the threshold opens the breaker.
+	send()
+	respond(sendErr)
+	expectRes(sendErr)
+	expectRejected()
+	expectRejected()
+
+	// A failed trial after the cooldown reopens the breaker.
+	<-time.After(time.Millisecond * 150)
+	send()
+	respond(sendErr)
+	expectRes(sendErr)
+	expectRejected()
+
+	// A successful trial after the cooldown closes the breaker.
+	<-time.After(time.Millisecond * 150)
+	send()
+	respond(nil)
+	expectRes(nil)
+	send()
+	respond(nil)
+	expectRes(nil)
+
+	output.CloseAsync()
+	if err = output.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+
+	counters := stats.GetCounters()
+	for k, v := range map[string]int64{
+		"circuit_breaker.opened":      2,
+		"circuit_breaker.half_opened": 2,
+		"circuit_breaker.closed":      1,
+		"circuit_breaker.rejected":    3,
+	} {
+		if act := counters[k]; act != v {
+			t.Errorf("Wrong count of %v: %v != %v", k, act, v)
+		}
+	}
+}
+
+func TestCircuitBreakerParallel(t *testing.T) {
+	conf := NewConfig()
+	childConf := NewConfig()
+	conf.CircuitBreaker.Output = &childConf
+	conf.CircuitBreaker.Threshold = 1
+	conf.CircuitBreaker.Cooldown = "50ms"
+
+	output, err := NewCircuitBreaker(conf, types.NoopMgr(), log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mOut := &mockOutput{}
+	output.(*CircuitBreaker).wrapped = mOut
+
+	tChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+	if err = output.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func() {
+		t.Helper()
+		select {
+		case tChan <- types.NewTransaction(message.New([][]byte{[]byte("foo")}), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	receive := func() types.Transaction {
+		t.Helper()
+		select {
+		case tran := <-mOut.ts:
+			return tran
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		return types.Transaction{}
+	}
+
+	respond := func(tran types.Transaction, sendErr error) {
+		t.Helper()
+		select {
+		case tran.ResponseChan <- response.NewError(sendErr):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		select {
+		case res := <-resChan:
+			if act := res.Error(); act != sendErr {
+				t.Errorf("Wrong response error: %v != %v", act, sendErr)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	// Multiple transactions are in flight while the breaker is closed.
+	var trans []types.Transaction
+	for i := 0; i < 3; i++ {
+		send()
+		trans = append(trans, receive())
+	}
+	for _, tran := range trans {
+		respond(tran, nil)
+	}
+
+	// Open the breaker and wait for the cooldown.
+	send()
+	sendErr := errors.New("failed")
+	respond(receive(), sendErr)
+	<-time.After(time.Millisecond * 100)
+
+	// Only the trial is sent while the breaker is half-open.
+	send()
+	trial := receive()
+	send()
+	select {
+	case res := <-resChan:
+		if act := res.Error(); act != types.ErrNotConnected {
+			t.Errorf("Wrong response error: %v != %v", act, types.ErrNotConnected)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	select {
+	case <-mOut.ts:
+		t.Error("Unexpected message sent to child while half-open")
+	default:
+	}
+	respond(trial, nil)
+
+	send()
+	respond(receive(), nil)
+
+	output.CloseAsync()
+	if err = output.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
@@ -54,13 +54,17 @@ var Constructors = map[string]TypeSpec{}
 const (
 	TypeAMQP            = "amqp"
 	TypeAMQP09          = "amqp_0_9"
+	TypeBenchmark       = "benchmark"
 	TypeBroker          = "broker"
 	TypeCache           = "cache"
+	TypeCircuitBreaker  = "circuit_breaker"
+	TypeDedupe          = "dedupe"
 	TypeDrop            = "drop"
 	TypeDropOnError     = "drop_on_error"
 	TypeDynamic         = "dynamic"
 	TypeDynamoDB        = "dynamodb"
 	TypeElasticsearch   = "elasticsearch"
+	TypeFallback        = "fallback"
 	TypeFile            = "file"
 	TypeFiles           = "files"
 	TypeGCPPubSub       = "gcp_pubsub"
@@ -76,12 +80,14 @@ const (
 	TypeNATS            = "nats"
 	TypeNATSStream      = "nats_stream"
 	TypeNSQ             = "nsq"
+	TypeRateLimit       = "rate_limit"
 	TypeRedisHash       = "redis_hash"
 	TypeRedisList       = "redis_list"
 	TypeRedisPubSub     = "redis_pubsub"
 	TypeRedisStreams    = "redis_streams"
 	TypeRetry           = "retry"
 	TypeS3              = "s3"
+	TypeSnowflake       = "snowflake"
 	TypeSNS             = "sns"
 	TypeSQS             = "sqs"
 	TypeSTDOUT          = "stdout"
@@ -102,13 +108,17 @@ type Config struct {
 	Type            string                       `json:"type" yaml:"type"`
 	AMQP            writer.AMQPConfig            `json:"amqp" yaml:"amqp"`
 	AMQP09          writer.AMQPConfig            `json:"amqp_0_9" yaml:"amqp_0_9"`
+	Benchmark       writer.BenchmarkConfig       `json:"benchmark" yaml:"benchmark"`
 	Broker          BrokerConfig                 `json:"broker" yaml:"broker"`
 	Cache           writer.CacheConfig           `json:"cache" yaml:"cache"`
+	CircuitBreaker  CircuitBreakerConfig         `json:"circuit_breaker" yaml:"circuit_breaker"`
+	Dedupe          DedupeConfig                 `json:"dedupe" yaml:"dedupe"`
 	Drop            writer.DropConfig            `json:"drop" yaml:"drop"`
 	DropOnError     DropOnErrorConfig            `json:"drop_on_error" yaml:"drop_on_error"`
 	Dynamic         DynamicConfig                `json:"dynamic" yaml:"dynamic"`
 	DynamoDB        writer.DynamoDBConfig        `json:"dynamodb" yaml:"dynamodb"`
 	Elasticsearch   writer.ElasticsearchConfig   `json:"elasticsearch" yaml:"elasticsearch"`
+	Fallback        FallbackConfig               `json:"fallback" yaml:"fallback"`
 	File            FileConfig                   `json:"file" yaml:"file"`
 	Files           writer.FilesConfig           `json:"files" yaml:"files"`
 	GCPPubSub       writer.GCPPubSubConfig       `json:"gcp_pubsub" yaml:"gcp_pubsub"`
@@ -125,12 +135,14 @@ type Config struct {
 	NATSStream      writer.NATSStreamConfig      `json:"nats_stream" yaml:"nats_stream"`
 	NSQ             writer.NSQConfig             `json:"nsq" yaml:"nsq"`
 	Plugin          interface{}                  `json:"plugin,omitempty" yaml:"plugin,omitempty"`
+	RateLimit       RateLimitConfig              `json:"rate_limit" yaml:"rate_limit"`
 	RedisHash       writer.RedisHashConfig       `json:"redis_hash" yaml:"redis_hash"`
 	RedisList       writer.RedisListConfig       `json:"redis_list" yaml:"redis_list"`
 	RedisPubSub     writer.RedisPubSubConfig     `json:"redis_pubsub" yaml:"redis_pubsub"`
 	RedisStreams    writer.RedisStreamsConfig    `json:"redis_streams" yaml:"redis_streams"`
 	Retry           RetryConfig                  `json:"retry" yaml:"retry"`
 	S3              writer.AmazonS3Config        `json:"s3" yaml:"s3"`
+	Snowflake       *writer.SnowflakeConfig      `json:"snowflake,omitempty" yaml:"snowflake,omitempty"`
 	SNS             writer.SNSConfig             `json:"sns" yaml:"sns"`
 	SQS             writer.AmazonSQSConfig       `json:"sqs" yaml:"sqs"`
 	STDOUT          STDOUTConfig                 `json:"stdout" yaml:"stdout"`
@@ -151,13 +163,17 @@ func NewConfig() Config {
 		Type:            "stdout",
 		AMQP:            writer.NewAMQPConfig(),
 		AMQP09:          writer.NewAMQPConfig(),
+		Benchmark:       writer.NewBenchmarkConfig(),
 		Broker:          NewBrokerConfig(),
 		Cache:           writer.NewCacheConfig(),
+		CircuitBreaker:  NewCircuitBreakerConfig(),
+		Dedupe:          NewDedupeConfig(),
 		Drop:            writer.NewDropConfig(),
 		DropOnError:     NewDropOnErrorConfig(),
 		Dynamic:         NewDynamicConfig(),
 		DynamoDB:        writer.NewDynamoDBConfig(),
 		Elasticsearch:   writer.NewElasticsearchConfig(),
+		Fallback:        NewFallbackConfig(),
 		File:            NewFileConfig(),
 		Files:           writer.NewFilesConfig(),
 		GCPPubSub:       writer.NewGCPPubSubConfig(),
@@ -174,12 +190,14 @@ func NewConfig() Config {
 		NATSStream:      writer.NewNATSStreamConfig(),
 		NSQ:             writer.NewNSQConfig(),
 		Plugin:          nil,
+		RateLimit:       NewRateLimitConfig(),
 		RedisHash:       writer.NewRedisHashConfig(),
 		RedisList:       writer.NewRedisListConfig(),
 		RedisPubSub:     writer.NewRedisPubSubConfig(),
 		RedisStreams:    writer.NewRedisStreamsConfig(),
 		Retry:           NewRetryConfig(),
 		S3:              writer.NewAmazonS3Config(),
+		Snowflake:       writer.NewSnowflakeConfig(),
 		SNS:             writer.NewSNSConfig(),
 		SQS:             writer.NewAmazonSQSConfig(),
 		STDOUT:          NewSTDOUTConfig(),
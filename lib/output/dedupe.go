@@ -0,0 +1,368 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+	"github.com/Jeffail/benthos/v3/lib/x/docs"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeDedupe] = TypeSpec{
+		constructor: NewDedupe,
+		Description: `
+Deduplicates messages at the output boundary by recording an interpolated key of
+each message in a cache before it is written to a child output. Messages with a
+key that has already been recorded within the configured ` + "`window`" + ` are
+acknowledged without being sent.
+
+Keys are recorded using the cache ` + "`add`" + ` operation along with the time
+they were recorded, and a message with a recorded key older than the window
+replaces the record and is sent. When the child output fails to send a message
+its key is removed so that the retried message is not mistaken for a duplicate.
+
+In order to deduplicate messages across restarts of Benthos use a persistent
+cache, and configure its TTL to be at least as long as the window in order to
+prevent the cache from growing indefinitely.
+
+` + "``` yaml" + `
+output:
+  dedupe:
+    cache: foocache
+    key: ${!metadata:kafka_key}-${!json_field:id}
+    window: 1h
+    output:
+      kafka:
+        addresses: [ localhost:9092 ]
+        topic: foo
+` + "```" + ``,
+		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
+			confBytes, err := json.Marshal(conf.Dedupe)
+			if err != nil {
+				return nil, err
+			}
+
+			confMap := map[string]interface{}{}
+			if err = json.Unmarshal(confBytes, &confMap); err != nil {
+				return nil, err
+			}
+
+			var outputSanit interface{} = struct{}{}
+			if conf.Dedupe.Output != nil {
+				if outputSanit, err = SanitiseConfig(*conf.Dedupe.Output); err != nil {
+					return nil, err
+				}
+			}
+			confMap["output"] = outputSanit
+			return confMap, nil
+		},
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("cache", "The [`cache` resource](/docs/components/caches/about) to record keys in."),
+			docs.FieldCommon("key", "An interpolated key to deduplicate messages by.", "${!metadata:kafka_key}").SupportsInterpolation(false),
+			docs.FieldCommon("window", "The period of time within which messages sharing a key are considered duplicates. If empty keys are considered duplicates for as long as they remain in the cache.", "1h", "10m"),
+			docs.FieldCommon("output", "The child output to write messages to."),
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// DedupeConfig contains configuration values for the Dedupe output type.
+type DedupeConfig struct {
+	Cache  string  `json:"cache" yaml:"cache"`
+	Key    string  `json:"key" yaml:"key"`
+	Window string  `json:"window" yaml:"window"`
+	Output *Config `json:"output" yaml:"output"`
+}
+
+// NewDedupeConfig creates a new DedupeConfig with default values.
+func NewDedupeConfig() DedupeConfig {
+	return DedupeConfig{
+		Cache:  "",
+		Key:    "",
+		Window: "",
+		Output: nil,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type dummyDedupeConfig struct {
+	Cache  string      `json:"cache" yaml:"cache"`
+	Key    string      `json:"key" yaml:"key"`
+	Window string      `json:"window" yaml:"window"`
+	Output interface{} `json:"output" yaml:"output"`
+}
+
+// MarshalJSON prints an empty object instead of nil.
+func (d DedupeConfig) MarshalJSON() ([]byte, error) {
+	dummy := dummyDedupeConfig{
+		Cache:  d.Cache,
+		Key:    d.Key,
+		Window: d.Window,
+		Output: d.Output,
+	}
+	if d.Output == nil {
+		dummy.Output = struct{}{}
+	}
+	return json.Marshal(dummy)
+}
+
+// MarshalYAML prints an empty object instead of nil.
+func (d DedupeConfig) MarshalYAML() (interface{}, error) {
+	dummy := dummyDedupeConfig{
+		Cache:  d.Cache,
+		Key:    d.Key,
+		Window: d.Window,
+		Output: d.Output,
+	}
+	if d.Output == nil {
+		dummy.Output = struct{}{}
+	}
+	return dummy, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Dedupe is an output type that drops messages with keys that have already
+// been recorded in a cache within a window of time, and writes all other
+// messages to a child output.
+type Dedupe struct {
+	running int32
+
+	key    *text.InterpolatedString
+	window time.Duration
+	cache  types.Cache
+
+	wrapped Type
+
+	stats metrics.Type
+	log   log.Modular
+
+	transactionsIn  <-chan types.Transaction
+	transactionsOut chan types.Transaction
+
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+// NewDedupe creates a new Dedupe output type.
+func NewDedupe(
+	conf Config,
+	mgr types.Manager,
+	log log.Modular,
+	stats metrics.Type,
+) (Type, error) {
+	if conf.Dedupe.Output == nil {
+		return nil, errors.New("cannot create a dedupe output without a child")
+	}
+	if len(conf.Dedupe.Key) == 0 {
+		return nil, errors.New("a key must be specified")
+	}
+
+	var window time.Duration
+	if wStr := conf.Dedupe.Window; len(wStr) > 0 {
+		var err error
+		if window, err = time.ParseDuration(wStr); err != nil {
+			return nil, fmt.Errorf("failed to parse window duration string: %v", err)
+		}
+	}
+
+	c, err := mgr.GetCache(conf.Dedupe.Cache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain cache resource '%v': %v", conf.Dedupe.Cache, err)
+	}
+
+	wrapped, err := New(*conf.Dedupe.Output, mgr, log, stats)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output '%v': %v", conf.Dedupe.Output.Type, err)
+	}
+
+	return &Dedupe{
+		running: 1,
+
+		key:    text.NewInterpolatedString(conf.Dedupe.Key),
+		window: window,
+		cache:  c,
+
+		log:             log,
+		stats:           stats,
+		wrapped:         wrapped,
+		transactionsOut: make(chan types.Transaction),
+
+		closeChan:  make(chan struct{}),
+		closedChan: make(chan struct{}),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// record attempts to record a key in the cache and returns true if the key is a
+// duplicate within the window.
+func (d *Dedupe) record(key string) (bool, error) {
+	now := time.Now()
+	nowBytes := []byte(strconv.FormatInt(now.UnixNano(), 10))
+
+	err := d.cache.Add(key, nowBytes)
+	if err == nil {
+		return false, nil
+	}
+	if err != types.ErrKeyAlreadyExists {
+		return false, err
+	}
+	if d.window <= 0 {
+		return true, nil
+	}
+
+	tsBytes, err := d.cache.Get(key)
+	if err != nil {
+		return false, err
+	}
+	if tsNanos, perr := strconv.ParseInt(string(tsBytes), 10, 64); perr == nil {
+		if now.Sub(time.Unix(0, tsNanos)) < d.window {
+			return true, nil
+		}
+	}
+	return false, d.cache.Set(key, nowBytes)
+}
+
+func (d *Dedupe) loop() {
+	// Metrics paths
+	var (
+		mDropped      = d.stats.GetCounter("dedupe.dropped")
+		mDroppedBatch = d.stats.GetCounter("dedupe.batch.dropped")
+		mErrCache     = d.stats.GetCounter("dedupe.error.cache")
+	)
+
+	defer func() {
+		close(d.transactionsOut)
+		d.wrapped.CloseAsync()
+		err := d.wrapped.WaitForClose(time.Second)
+		for ; err != nil; err = d.wrapped.WaitForClose(time.Second) {
+		}
+		close(d.closedChan)
+	}()
+
+	resChan := make(chan types.Response)
+
+	for atomic.LoadInt32(&d.running) == 1 {
+		var ts types.Transaction
+		var open bool
+		select {
+		case ts, open = <-d.transactionsIn:
+			if !open {
+				return
+			}
+		case <-d.closeChan:
+			return
+		}
+
+		var res types.Response
+
+		keys := []string{}
+		msg := message.New(nil)
+		ts.Payload.Iter(func(i int, p types.Part) error {
+			if res != nil {
+				return nil
+			}
+			key := d.key.Get(message.Lock(ts.Payload, i))
+			dupe, err := d.record(key)
+			if err != nil {
+				mErrCache.Incr(1)
+				d.log.Errorf("Failed to record dedupe key: %v\n", err)
+				res = response.NewError(err)
+				return nil
+			}
+			if dupe {
+				mDropped.Incr(1)
+				return nil
+			}
+			keys = append(keys, key)
+			msg.Append(p)
+			return nil
+		})
+
+		if res == nil && msg.Len() == 0 {
+			mDroppedBatch.Incr(1)
+			res = response.NewAck()
+		}
+
+		if res == nil {
+			select {
+			case d.transactionsOut <- types.NewTransaction(msg, resChan):
+			case <-d.closeChan:
+				return
+			}
+			select {
+			case res = <-resChan:
+			case <-d.closeChan:
+				return
+			}
+		}
+
+		if res.Error() != nil {
+			for _, k := range keys {
+				if err := d.cache.Delete(k); err != nil {
+					mErrCache.Incr(1)
+					d.log.Errorf("Failed to remove dedupe key after failed send: %v\n", err)
+				}
+			}
+		}
+
+		select {
+		case ts.ResponseChan <- res:
+		case <-d.closeChan:
+			return
+		}
+	}
+}
+
+// Consume assigns a messages channel for the output to read.
+func (d *Dedupe) Consume(ts <-chan types.Transaction) error {
+	if d.transactionsIn != nil {
+		return types.ErrAlreadyStarted
+	}
+	if err := d.wrapped.Consume(d.transactionsOut); err != nil {
+		return err
+	}
+	d.transactionsIn = ts
+	go d.loop()
+	return nil
+}
+
+// Connected returns a boolean indicating whether this output is currently
+// connected to its target.
+func (d *Dedupe) Connected() bool {
+	return d.wrapped.Connected()
+}
+
+// CloseAsync shuts down the Dedupe output and stops processing requests.
+func (d *Dedupe) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&d.running, 1, 0) {
+		close(d.closeChan)
+	}
+}
+
+// WaitForClose blocks until the Dedupe output has closed down.
+func (d *Dedupe) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-d.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,178 @@
+package output
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/cache"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+type cacheMgr struct {
+	types.DudMgr
+	caches map[string]types.Cache
+}
+
+func (c *cacheMgr) GetCache(name string) (types.Cache, error) {
+	if cache, exists := c.caches[name]; exists {
+		return cache, nil
+	}
+	return nil, types.ErrCacheNotFound
+}
+
+func TestDedupeConfigErrs(t *testing.T) {
+	mgr := &cacheMgr{caches: map[string]types.Cache{}}
+
+	conf := NewConfig()
+	conf.Type = TypeDedupe
+	if _, err := New(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing child")
+	}
+
+	childConf := NewConfig()
+	conf.Dedupe.Output = &childConf
+	conf.Dedupe.Key = "${!content}"
+	conf.Dedupe.Cache = "foo"
+	if _, err := New(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing cache")
+	}
+}
+
+func TestDedupeBasic(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &cacheMgr{caches: map[string]types.Cache{"foo": memCache}}
+
+	conf := NewConfig()
+	childConf := NewConfig()
+	conf.Dedupe.Output = &childConf
+	conf.Dedupe.Cache = "foo"
+	conf.Dedupe.Key = "${!content}"
+	conf.Dedupe.Window = "1h"
+
+	output, err := NewDedupe(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, ok := output.(*Dedupe)
+	if !ok {
+		t.Fatal("Failed to cast")
+	}
+
+	mOut := &mockOutput{}
+	d.wrapped = mOut
+
+	tChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	if err = d.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	sendAndExpect := func(input, expSent []string, sendErr error) {
+		t.Helper()
+
+		inMsg := message.New(nil)
+		for _, in := range input {
+			inMsg.Append(message.NewPart([]byte(in)))
+		}
+
+		select {
+		case tChan <- types.NewTransaction(inMsg, resChan):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+
+		if len(expSent) > 0 {
+			var tran types.Transaction
+			select {
+			case tran = <-mOut.ts:
+			case <-time.After(time.Second):
+				t.Fatal("timed out")
+			}
+
+			actSent := []string{}
+			for _, b := range message.GetAllBytes(tran.Payload) {
+				actSent = append(actSent, string(b))
+			}
+			if !reflect.DeepEqual(expSent, actSent) {
+				t.Errorf("Wrong messages sent: %v != %v", actSent, expSent)
+			}
+
+			select {
+			case tran.ResponseChan <- response.NewError(sendErr):
+			case <-time.After(time.Second):
+				t.Fatal("timed out")
+			}
+		}
+
+		select {
+		case res := <-resChan:
+			if res.Error() != sendErr {
+				t.Errorf("Unexpected response error: %v != %v", res.Error(), sendErr)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	sendAndExpect([]string{"foo", "bar"}, []string{"foo", "bar"}, nil)
+	sendAndExpect([]string{"foo", "baz"}, []string{"baz"}, nil)
+	sendAndExpect([]string{"foo", "bar"}, nil, nil)
+
+	sendErr := errors.New("failed")
+	sendAndExpect([]string{"buz"}, []string{"buz"}, sendErr)
+	sendAndExpect([]string{"buz"}, []string{"buz"}, nil)
+
+	output.CloseAsync()
+	if err = output.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDedupeWindowExpired(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &cacheMgr{caches: map[string]types.Cache{"foo": memCache}}
+
+	conf := NewConfig()
+	childConf := NewConfig()
+	conf.Dedupe.Output = &childConf
+	conf.Dedupe.Cache = "foo"
+	conf.Dedupe.Key = "${!content}"
+	conf.Dedupe.Window = "50ms"
+
+	output, err := NewDedupe(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := output.(*Dedupe)
+
+	if dupe, err := d.record("foo"); err != nil {
+		t.Fatal(err)
+	} else if dupe {
+		t.Error("Unexpected duplicate")
+	}
+	<-time.After(time.Millisecond * 100)
+	if dupe, err := d.record("foo"); err != nil {
+		t.Fatal(err)
+	} else if dupe {
+		t.Error("Unexpected duplicate after window")
+	}
+	if dupe, err := d.record("foo"); err != nil {
+		t.Fatal(err)
+	} else if !dupe {
+		t.Error("Expected duplicate within window")
+	}
+}
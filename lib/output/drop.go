@@ -5,6 +5,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/output/writer"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/x/docs"
 )
 
 //------------------------------------------------------------------------------
@@ -13,7 +14,19 @@ func init() {
 	Constructors[TypeDrop] = TypeSpec{
 		constructor: NewDrop,
 		Description: `
-Drops all messages.`,
+Drops all messages.
+
+The drop output can also simulate a flaky sink for load testing by delaying each
+write by ` + "`latency`" + ` plus a random duration up to
+` + "`latency_jitter`" + `, and by failing a proportion of writes according to
+` + "`error_rate`" + `, which is a value between 0 and 1. This makes it possible
+to observe how batching, retries and other error handling within a pipeline
+behave without a real broker.`,
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldAdvanced("latency", "A fixed duration to wait before each write completes.", "10ms", "1s"),
+			docs.FieldAdvanced("latency_jitter", "A maximum random duration to add to the latency of each write.", "5ms"),
+			docs.FieldAdvanced("error_rate", "The proportion of writes, between 0 and 1, that should fail.", 0.1),
+		},
 	}
 }
 
@@ -21,9 +34,11 @@ Drops all messages.`,
 
 // NewDrop creates a new Drop output type.
 func NewDrop(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
-	return NewWriter(
-		TypeDrop, writer.NewDrop(conf.Drop, log, stats), log, stats,
-	)
+	d, err := writer.NewDrop(conf.Drop, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter(TypeDrop, d, log, stats)
 }
 
 //------------------------------------------------------------------------------
@@ -12,6 +12,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
 	"gopkg.in/yaml.v3"
 )
 
@@ -23,8 +24,23 @@ func init() {
 		Description: `
 The dynamic type is a special broker type where the outputs are identified by
 unique labels and can be created, changed and removed during runtime via a REST
-HTTP interface. The broker pattern used is always ` + "`fan_out`" + `, meaning
-each message will be delivered to each dynamic output.
+HTTP interface. By default the broker pattern used is ` + "`fan_out`" + `,
+meaning each message will be delivered to each dynamic output.
+
+When the field ` + "`output_key`" + ` is set each message is instead delivered
+only to the output with the label that it resolves to, which allows routes to
+be added and removed during runtime like a mutable
+` + "[`switch`](/docs/components/outputs/switch)" + `. The key supports
+[interpolation functions](/docs/configuration/interpolation#functions) and is
+resolved for each message of a batch, with the batch split into a batch for each
+output. Messages that resolve to a label without an output are dropped and a
+warning is logged.
+
+` + "``` yaml" + `
+output:
+  dynamic:
+    output_key: ${!metadata:tenant}
+` + "```" + `
 
 To GET a JSON map of output identifiers with their current uptimes use the
 '/outputs' endpoint.
@@ -44,9 +60,10 @@ already exists it will be changed.`,
 				outMap[k] = sanOutput
 			}
 			return map[string]interface{}{
-				"outputs": outMap,
-				"prefix":  conf.Dynamic.Prefix,
-				"timeout": conf.Dynamic.Timeout,
+				"outputs":    outMap,
+				"output_key": conf.Dynamic.OutputKey,
+				"prefix":     conf.Dynamic.Prefix,
+				"timeout":    conf.Dynamic.Timeout,
 			}, nil
 		},
 	}
@@ -56,17 +73,19 @@ already exists it will be changed.`,
 
 // DynamicConfig contains configuration fields for the Dynamic output type.
 type DynamicConfig struct {
-	Outputs map[string]Config `json:"outputs" yaml:"outputs"`
-	Prefix  string            `json:"prefix" yaml:"prefix"`
-	Timeout string            `json:"timeout" yaml:"timeout"`
+	Outputs   map[string]Config `json:"outputs" yaml:"outputs"`
+	OutputKey string            `json:"output_key" yaml:"output_key"`
+	Prefix    string            `json:"prefix" yaml:"prefix"`
+	Timeout   string            `json:"timeout" yaml:"timeout"`
 }
 
 // NewDynamicConfig creates a new DynamicConfig with default values.
 func NewDynamicConfig() DynamicConfig {
 	return DynamicConfig{
-		Outputs: map[string]Config{},
-		Prefix:  "",
-		Timeout: "5s",
+		Outputs:   map[string]Config{},
+		OutputKey: "",
+		Prefix:    "",
+		Timeout:   "5s",
 	}
 }
 
@@ -101,8 +120,7 @@ func NewDynamic(
 	outputConfigs := conf.Dynamic.Outputs
 	outputConfigsMut := sync.RWMutex{}
 
-	fanOut, err := broker.NewDynamicFanOut(
-		outputs, log, stats,
+	options := []func(*broker.DynamicFanOut){
 		broker.OptDynamicFanOutSetOnAdd(func(l string) {
 			outputConfigsMut.Lock()
 			defer outputConfigsMut.Unlock()
@@ -123,7 +141,15 @@ func NewDynamic(
 		broker.OptDynamicFanOutSetOnRemove(func(l string) {
 			dynAPI.Stopped(l)
 		}),
-	)
+	}
+	if len(conf.Dynamic.OutputKey) > 0 {
+		outputKey := text.NewInterpolatedString(conf.Dynamic.OutputKey)
+		options = append(options, broker.OptDynamicFanOutSetRouter(func(msg types.Message) string {
+			return outputKey.Get(msg)
+		}))
+	}
+
+	fanOut, err := broker.NewDynamicFanOut(outputs, log, stats, options...)
 	if err != nil {
 		return nil, err
 	}
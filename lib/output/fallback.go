@@ -0,0 +1,115 @@
+package output
+
+import (
+	"fmt"
+
+	"github.com/Jeffail/benthos/v3/lib/broker"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeFallback] = TypeSpec{
+		brokerConstructor: NewFallback,
+		Description: `
+Attempts to send each message to only one output, starting from the first output
+on the list. If an output fails with a non-retriable error then the next output
+in the list is attempted, and so on. Any other error is returned to the input
+without attempting the next output, so that the message is retried from the
+first output.
+
+This differs from the ` + "[`try`](/docs/components/outputs/try)" + ` output,
+which moves on to the next output for any error, and is intended for routing
+messages that can never be delivered to a dead letter queue whilst transient
+failures are retried against the primary output:
+
+` + "``` yaml" + `
+output:
+  fallback:
+  - kafka:
+      addresses: [ localhost:9092 ]
+      topic: foo
+  - file:
+      path: /usr/local/benthos/dead_letters.jsonl
+` + "```" + `
+
+Errors are non-retriable when an output knows that retrying the message would
+fail again, for example the ` + "[`kafka`](/docs/components/outputs/kafka)" + `
+output rejects records that exceed ` + "`max_msg_bytes`" + ` with a
+non-retriable error.
+
+When an output reports which messages of a batch failed, as the
+` + "[`kafka`](/docs/components/outputs/kafka)" + ` output does, only the
+messages that failed with a non-retriable error are sent to the next output,
+and messages of the batch that were delivered are not sent again.
+
+A message is acknowledged once any output succeeds, and is only rejected with
+the error of the last output when all outputs fail.`,
+		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
+			outSlice := []interface{}{}
+			for _, output := range conf.Fallback {
+				sanOutput, err := SanitiseConfig(output)
+				if err != nil {
+					return nil, err
+				}
+				outSlice = append(outSlice, sanOutput)
+			}
+			return outSlice, nil
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// FallbackConfig contains configuration fields for the Fallback output type.
+type FallbackConfig brokerOutputList
+
+// NewFallbackConfig creates a new FallbackConfig with default values.
+func NewFallbackConfig() FallbackConfig {
+	return FallbackConfig{}
+}
+
+//------------------------------------------------------------------------------
+
+// NewFallback creates a new fallback broker output type.
+func NewFallback(
+	conf Config,
+	mgr types.Manager,
+	log log.Modular,
+	stats metrics.Type,
+	pipelines ...types.PipelineConstructorFunc,
+) (Type, error) {
+	outputConfs := conf.Fallback
+
+	if len(outputConfs) == 0 {
+		return nil, ErrBrokerNoOutputs
+	}
+	outputs := make([]types.Output, len(outputConfs))
+
+	var err error
+	for i, oConf := range outputConfs {
+		ns := fmt.Sprintf("fallback.%v", i)
+		outputs[i], err = New(
+			oConf, mgr,
+			log.NewModule("."+ns),
+			metrics.Combine(stats, metrics.Namespaced(stats, ns)),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create output '%v' type '%v': %v", i, oConf.Type, err)
+		}
+	}
+
+	var t *broker.Try
+	if t, err = broker.NewTry(outputs, stats); err != nil {
+		return nil, err
+	}
+
+	t.WithOutputMetricsPrefix("fallback.outputs")
+	t.WithNonRetriableOnly()
+	return WrapWithPipelines(t, pipelines...)
+}
+
+//------------------------------------------------------------------------------
@@ -1,11 +1,13 @@
 package output
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/x/docs"
 )
 
 //------------------------------------------------------------------------------
@@ -22,7 +24,21 @@ would be written as:
 
 foo\n
 bar\n
-baz\n\n`,
+baz\n\n
+
+### Metadata
+
+The field ` + "`metadata_mode`" + ` determines whether the metadata of each
+message part is retained. When set to ` + "`sidecar`" + ` the metadata of each
+part is written as a JSON object line to a file at ` + "`<path>.meta.json`" + `,
+in the same order as the parts are written to the main file. When set to
+` + "`header`" + ` the metadata JSON object of each part is written as a line
+immediately preceding the part itself.`,
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("path", "The file to write to, if the file does not yet exist it will be created."),
+			docs.FieldCommon("delimiter", "A custom delimiter to separate messages with. If left empty defaults to a line break."),
+			docs.FieldAdvanced("metadata_mode", "Whether and how the metadata of messages should be written.").HasOptions("none", "sidecar", "header"),
+		},
 	}
 }
 
@@ -30,15 +46,17 @@ baz\n\n`,
 
 // FileConfig contains configuration fields for the file based output type.
 type FileConfig struct {
-	Path  string `json:"path" yaml:"path"`
-	Delim string `json:"delimiter" yaml:"delimiter"`
+	Path         string `json:"path" yaml:"path"`
+	Delim        string `json:"delimiter" yaml:"delimiter"`
+	MetadataMode string `json:"metadata_mode" yaml:"metadata_mode"`
 }
 
 // NewFileConfig creates a new FileConfig with default values.
 func NewFileConfig() FileConfig {
 	return FileConfig{
-		Path:  "",
-		Delim: "",
+		Path:         "",
+		Delim:        "",
+		MetadataMode: "none",
 	}
 }
 
@@ -46,11 +64,31 @@ func NewFileConfig() FileConfig {
 
 // NewFile creates a new File output type.
 func NewFile(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	var opts []func(*LineWriter)
+	var metaFile *os.File
+
+	switch conf.File.MetadataMode {
+	case "none", "":
+	case "header":
+		opts = append(opts, OptLineWriterSetMetadataHeader(true))
+	case "sidecar":
+		var err error
+		if metaFile, err = os.OpenFile(conf.File.Path+".meta.json", os.O_CREATE|os.O_RDWR|os.O_APPEND, os.FileMode(0666)); err != nil {
+			return nil, err
+		}
+		opts = append(opts, OptLineWriterSetMetadataSidecar(metaFile))
+	default:
+		return nil, fmt.Errorf("metadata_mode not recognised: %v", conf.File.MetadataMode)
+	}
+
 	file, err := os.OpenFile(conf.File.Path, os.O_CREATE|os.O_RDWR|os.O_APPEND, os.FileMode(0666))
 	if err != nil {
+		if metaFile != nil {
+			metaFile.Close()
+		}
 		return nil, err
 	}
-	return NewLineWriter(file, true, []byte(conf.File.Delim), "file", log, stats)
+	return NewLineWriter(file, true, []byte(conf.File.Delim), "file", log, stats, opts...)
 }
 
 //------------------------------------------------------------------------------
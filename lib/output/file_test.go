@@ -0,0 +1,103 @@
+package output
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+func TestFileMetadataSidecar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_file_output_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conf := NewConfig()
+	conf.Type = TypeFile
+	conf.File.Path = filepath.Join(dir, "out.txt")
+	conf.File.MetadataMode = "sidecar"
+
+	out, err := NewFile(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+	if err = out.Consume(msgChan); err != nil {
+		t.Fatal(err)
+	}
+
+	inputMeta := []map[string]string{
+		{"kafka_topic": "foo", "kafka_timestamp": "1"},
+		{"kafka_topic": "bar", "kafka_timestamp": "2"},
+	}
+	for i, meta := range inputMeta {
+		msg := message.New([][]byte{[]byte("msg")})
+		for k, v := range meta {
+			msg.Get(0).Metadata().Set(k, v)
+		}
+		select {
+		case msgChan <- types.NewTransaction(msg, resChan):
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out sending message %v", i)
+		}
+		select {
+		case res := <-resChan:
+			if res.Error() != nil {
+				t.Fatal(res.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for response %v", i)
+		}
+	}
+
+	out.CloseAsync()
+	if err = out.WaitForClose(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	metaBytes, err := ioutil.ReadFile(conf.File.Path + ".meta.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(metaBytes)), "\n")
+	if exp, act := len(inputMeta), len(lines); exp != act {
+		t.Fatalf("Wrong count of metadata lines: %v != %v", act, exp)
+	}
+	for i, line := range lines {
+		var actMeta map[string]string
+		if err = json.Unmarshal([]byte(line), &actMeta); err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range inputMeta[i] {
+			if act := actMeta[k]; act != v {
+				t.Errorf("Wrong metadata value for %v at index %v: %v != %v", k, i, act, v)
+			}
+		}
+		if exp, act := len(inputMeta[i]), len(actMeta); exp != act {
+			t.Errorf("Wrong count of metadata keys at index %v: %v != %v", i, act, exp)
+		}
+	}
+}
+
+func TestFileBadMetadataMode(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeFile
+	conf.File.Path = "/tmp/benthos_not_used"
+	conf.File.MetadataMode = "nope"
+
+	if _, err := NewFile(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad metadata mode")
+	}
+}
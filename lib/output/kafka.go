@@ -2,6 +2,8 @@ package output
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message/batch"
@@ -16,27 +18,133 @@ import (
 
 //------------------------------------------------------------------------------
 
-func saslFieldSpec() docs.FieldSpec {
-	return docs.FieldAdvanced("sasl", "Enables SASL authentication.").WithChildren(
-		docs.FieldCommon("enabled", "Whether SASL authentication is enabled."),
-		docs.FieldCommon("user", "A plain text username. It is recommended that you use environment variables to populate this field.", "${USER}"),
-		docs.FieldCommon("password", "A plain text password. It is recommended that you use environment variables to populate this field.", "${PASSWORD}"),
-	)
-}
-
 func init() {
 	Constructors[TypeKafka] = TypeSpec{
 		constructor: NewKafka,
 		Description: `
 The kafka output type writes a batch of messages to Kafka brokers and waits for
 acknowledgement before propagating it back to the input. The config field
-` + "`ack_replicas`" + ` determines whether we wait for acknowledgement from all
-replicas or just a single broker.
+` + "`required_acks`" + ` determines whether we wait for acknowledgement from all
+replicas, just the leader broker, or not at all.
 
-Both the ` + "`key` and `topic`" + ` fields can be dynamically set using
+The ` + "`key`, `topic` and `headers`" + ` fields can be dynamically set using
 function interpolations described [here](/docs/configuration/interpolation#functions).
 When sending batched messages these interpolations are performed per message
-part.`,
+part.
+
+The metadata of each message is sent as record headers, and additional headers
+can be added with the ` + "`headers`" + ` field, which maps header names to
+interpolated values:
+
+` + "``` yaml" + `
+output:
+  kafka:
+    addresses: [ localhost:9092 ]
+    topic: foo
+    headers:
+      trace_id: ${!metadata:trace_id}
+      route: ${!json_field:route}
+` + "```" + `
+
+### Reconnects
+
+Failed connection attempts are retried using the ` + "`backoff`" + ` fields,
+where each wait period is chosen at random between zero and the exponential
+period of the attempt. This prevents many outputs from reconnecting in lockstep
+when a broker restarts. If no connection is established within
+` + "`max_elapsed_time`" + ` the attempts are reported as timed out before
+starting again.
+
+A send that fails due to a SASL authentication error closes the connection
+once the sends in flight on it have completed and reconnects, which reads the
+` + "`sasl.user_file`" + ` and ` + "`sasl.password_file`" + ` files again so
+that rotated credentials are picked up without a restart. When
+` + "`share_connection`" + ` is enabled a new shared producer is created, which
+other outputs switch to as they encounter the same error.
+
+During shutdown batches that are waiting to be retried are rejected, and the
+connection is closed once batches that are in flight have been acknowledged by
+the brokers or have failed.
+
+### Partial Failures
+
+When some records of a batch fail to send after all retries are exhausted the
+error identifies which messages of the batch failed. When the batch was formed
+by the ` + "`batching`" + ` policy of this output only the messages that were
+received alongside a failed record are rejected back to the input, and the rest
+are acknowledged.
+
+Records larger than ` + "`max_msg_bytes`" + ` are never retried, since they
+would be rejected again, and neither are records of a topic that does not exist
+or that the client is not authorized to write to. The error of such a batch is non-retriable, which
+means that a wrapping ` + "[`retry`](/docs/components/outputs/retry)" + `
+output gives up on it immediately and a
+` + "[`fallback`](/docs/components/outputs/fallback)" + ` output moves it to the
+next output, which can be used as a dead letter queue.
+
+### Interpolation Errors
+
+The ` + "`key`" + ` and ` + "`topic`" + ` fields can fail to resolve for a
+message, for example when a ` + "`json_field`" + ` function targets a message
+that is not valid JSON. The field ` + "`on_interpolation_error`" + ` determines
+what happens to such a message. When set to ` + "`fallback`" + ` (the default)
+the message is sent with the functions that failed replaced by ` + "`null`" + `,
+when set to ` + "`drop`" + ` the message is acknowledged without being sent,
+and when set to ` + "`error`" + ` the message fails with a non-retriable error
+while the rest of the batch is sent.
+
+### Ordering
+
+With ` + "`max_in_flight`" + ` set to one each batch is sent only once the
+previous batch has been acknowledged, and therefore records are written in the
+order they were received. With ` + "`max_in_flight`" + ` greater than one
+batches are sent in parallel, and a batch can be written before a batch that
+was received earlier, including batches that target the same partition.
+
+When ` + "`preserve_order`" + ` is enabled batches are still sent in parallel,
+but a batch is only sent once all earlier batches that share a record key with
+it, or an explicit ` + "`partition`" + ` when one is set, have finished
+sending. Records of the same key are therefore written in the order they were
+received, and since the hash based partitioners assign records of the same key
+to the same partition, so is each partition for those records. Records without
+a key share a single ordering key per topic. Batches that have no keys in
+common are sent in parallel as normal.
+
+Reordering can still occur when a batch fails and is retried by an upstream
+component, such as a ` + "[`retry`](/docs/components/outputs/retry)" + `
+output or an input that redelivers the batch, since later batches are not held
+back whilst it is retried. Records sent by the ` + "`random`, `round_robin`" + `
+and ` + "`sticky`" + ` partitioners are ordered by key but are spread across
+partitions regardless.
+
+### Per Topic Batching
+
+By default the ` + "`batching`" + ` policy forms batches from messages of all
+topics together, and therefore when the ` + "`topic`" + ` field is dynamic a
+batch is flushed by the combined volume of all of its topics. When
+` + "`batch_per_topic`" + ` is enabled each resolved topic is batched
+separately with its own copy of the policy, so that a busy topic flushes by
+count or size whilst a quiet topic is flushed by the ` + "`period`" + ` of its
+own batch, which begins with its first message.
+
+The number of topics batched at once is limited by ` + "`max_topic_batches`" + `.
+When a message of a new topic arrives at the limit the batch of the topic that
+least recently received a message is flushed early to make room for it, which
+increments the metric ` + "`batching.evicted`" + `. A message is acknowledged
+once the batches of all topics it was split across have been sent. This field
+cannot be combined with ` + "`batching.group_by`" + `.
+
+### Metrics
+
+Each kafka output emits the counters ` + "`kafka.batch.sent`" + ` and
+` + "`kafka.batch.bytes`" + `, counting the batches with records that were
+acknowledged and the bytes of the keys, values and headers of those records,
+` + "`kafka.send.error`" + ` and
+` + "`kafka.send.retry`" + `, counting failed send attempts and retries, and
+the timing ` + "`kafka.ack.latency`" + ` measuring the time taken for brokers
+to acknowledge a send. These metrics are namespaced by the path of the output
+within the config, and therefore do not collide when multiple kafka outputs
+are configured.`,
 		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
 			return sanitiseWithBatch(conf.Kafka, conf.Kafka.Batching)
 		},
@@ -44,19 +152,47 @@ part.`,
 		Batches: true,
 		FieldSpecs: append(docs.FieldSpecs{
 			docs.FieldDeprecated("round_robin_partitions"),
-			docs.FieldCommon("addresses", "A list of broker addresses to connect to. If an item of the list contains commas it will be expanded into multiple addresses.", []string{"localhost:9092"}, []string{"localhost:9041,localhost:9042"}, []string{"localhost:9041", "localhost:9042"}),
+			docs.FieldCommon("addresses", "A list of broker addresses to connect to. If an item of the list contains commas it will be expanded into multiple addresses, with surrounding whitespace removed. Each address must be of the form `host:port`.", []string{"localhost:9092"}, []string{"localhost:9041,localhost:9042"}, []string{"localhost:9041", "localhost:9042"}),
 			tls.FieldSpec(),
 			sasl.FieldSpec(),
 			docs.FieldCommon("topic", "The topic to publish messages to.").SupportsInterpolation(false),
 			docs.FieldCommon("client_id", "An identifier for the client connection."),
 			docs.FieldCommon("key", "The key to publish messages with.").SupportsInterpolation(false),
-			docs.FieldCommon("partitioner", "The partitioning algorithm to use.").HasOptions("fnv1a_hash", "murmur2_hash", "random", "round_robin"),
-			docs.FieldCommon("compression", "The compression algorithm to use.").HasOptions("none", "snappy", "lz4", "gzip"),
+			docs.FieldAdvanced("key_from_field", "A [dot path](/docs/configuration/field_paths) of a JSON field within messages to use as the key. The field must be a string, number or boolean, and when it is absent or the message is not JSON the `key` field is used instead.", "id", "user.id"),
+			docs.FieldAdvanced("key_from_metadata", "The name of a metadata field whose value is used as the key without interpolation, preserving its exact bytes. When the field is absent or empty the `key` field is used instead. Cannot be combined with `key_from_field` or `key_from_content`."),
+			docs.FieldAdvanced("key_from_content", "Whether to use the raw contents of each message as its key, preserving its exact bytes. Cannot be combined with `key_from_field` or `key_from_metadata`."),
+			docs.FieldAdvanced("timestamp", "An optional timestamp to set on each record, which must resolve to either an RFC 3339 time or an integer of milliseconds since the unix epoch. When empty the time of the send is used. Messages that resolve to an invalid timestamp fail with a non-retriable error while the rest of the batch is sent. Requires a `target_version` of at least 0.10.0.0.", "${!metadata:event_time}").SupportsInterpolation(false),
+			docs.FieldAdvanced("headers", "A map of header names to values to add to each record. Requires a `target_version` of at least 0.11.0.0.", map[string]string{"trace_id": "${!metadata:trace_id}"}).SupportsInterpolation(false),
+			docs.FieldCommon("partitioner", "The partitioning algorithm to use. The `murmur2_hash` partitioner assigns records with a key to the same partitions as the default partitioner of the Java client, records without a key are assigned randomly. The `sticky` partitioner assigns all records of a batch without a key to the same partition, choosing the next partition in turn for each batch, and records with a key by the `fnv1a_hash` of the key. The `expression` partitioner assigns each record to the partition resolved by `partition_expression`. The `consistent_hash` partitioner assigns records with a key to partitions using a hash ring of `partitioner_replicas` virtual nodes per partition, which spreads keys more evenly than `fnv1a_hash` and minimises the keys that are reassigned when the number of partitions of a topic grows, records without a key are assigned randomly.").HasOptions("fnv1a_hash", "murmur2_hash", "random", "round_robin", "sticky", "expression", "consistent_hash"),
+			docs.FieldAdvanced("partitioner_replicas", "The number of virtual nodes placed on the hash ring for each partition when the `partitioner` is `consistent_hash`. Higher values result in a more even distribution of keys at the cost of memory."),
+			docs.FieldAdvanced("partition", "An optional explicit partition to write messages to, which must resolve to a non-negative integer. When set the `partitioner` field is ignored, and a message that resolves to an invalid integer or to a partition that does not exist on the topic fails with a non-retriable error while the rest of the batch is sent.", "${!metadata:partition}").SupportsInterpolation(false),
+			docs.FieldAdvanced("partition_expression", "The partition to write each message to when the `partitioner` is `expression`, which must resolve to a non-negative integer. A message that resolves to an invalid integer or to a partition that does not exist on the topic fails with a non-retriable error while the rest of the batch is sent.", `${!metadata:customer_partition}`).SupportsInterpolation(false),
+			docs.FieldCommon("compression", "The compression algorithm to use. The `zstd` algorithm requires a `target_version` of at least 2.1.0.").HasOptions("none", "snappy", "lz4", "gzip", "zstd"),
+			docs.FieldAdvanced("compression_level", "The level to compress messages with, where `-1` uses the default level of the algorithm. Only supported by the `gzip` algorithm, where levels range from `1` (fastest) to `9` (smallest), `0` disables compression and `-2` uses Huffman encoding only.", -1, 1, 9),
 			docs.FieldCommon("max_in_flight", "The maximum number of parallel message batches to have in flight at any given time."),
-			docs.FieldAdvanced("ack_replicas", "Ensure that messages have been copied across all replicas before acknowledging receipt."),
+			docs.FieldDeprecated("ack_replicas"),
+			docs.FieldAdvanced("required_acks", "The level of acknowledgement to wait for before a send is considered successful. With `all` the send waits until the records have been copied to all in-sync replicas, with `local` it waits until the leader broker has written them, and with `none` it does not wait for a response at all, which gives the highest throughput but means that records lost by the broker are never reported as failed. The offsets of records are unknown with `none`, and therefore it cannot be combined with `offset_metadata`.").HasOptions("none", "local", "all"),
+			docs.FieldAdvanced("idempotent_write", "Enable the idempotent producer, which ensures that retried sends do not write duplicate records. Requires `required_acks` to be `all`, `max_in_flight` to be `1` and a `target_version` of at least 0.11.0.0, and limits the producer to a single open request per broker. The producer ID that records are deduplicated by is assigned by the brokers when a connection is established, and therefore a send that is retried after reconnecting may still be duplicated."),
 			docs.FieldAdvanced("max_msg_bytes", "The maximum size in bytes of messages sent to the target topic."),
+			docs.FieldAdvanced("max_batch_bytes", "An optional maximum total size in bytes of the records of a single send, where batches that exceed it are split into multiple sends in order. The batch is only acknowledged once all of its records have been sent, and records that fail are retried or rejected individually. A record larger than this size is sent by itself. Set to `0` to disable splitting."),
 			docs.FieldAdvanced("timeout", "The maximum period of time to wait for message sends before abandoning the request and retrying."),
 			docs.FieldAdvanced("target_version", "The version of the Kafka protocol to use."),
+			docs.FieldAdvanced("create_topics", "Whether topics that do not yet exist should be created. When the `topic` field is static it is created during connection, otherwise each resolved topic is created before its first send. Intended for development and testing environments."),
+			docs.FieldAdvanced("create_topics_partitions", "The number of partitions to create topics with when `create_topics` is enabled."),
+			docs.FieldAdvanced("create_topics_replication_factor", "The replication factor to create topics with when `create_topics` is enabled."),
+			docs.FieldAdvanced("provenance_headers", "Whether to add the headers `benthos_instance`, `benthos_pipeline` and `benthos_produced_at` to each record, containing the hostname of the instance, the configured `pipeline_name` and the RFC 3339 time the record was sent. Requires a `target_version` of at least 0.11.0.0."),
+			docs.FieldAdvanced("pipeline_name", "A name identifying the pipeline, added as the `benthos_pipeline` header when `provenance_headers` is enabled."),
+			docs.FieldAdvanced("compression_metrics", "Whether to emit the metrics `bytes_uncompressed` and `bytes_sent`, labelled by topic, counting the bytes of record keys, values and headers before compression and an estimate of the bytes sent after compression respectively. The estimate is derived from the mean compression ratio of recent record batches of each topic as observed by the producer, and is therefore only an approximation that is equal to the uncompressed count until a ratio has been observed, and does not include protocol overhead."),
+			docs.FieldAdvanced("on_interpolation_error", "What to do with a message when its `key` or `topic` fails to resolve.").HasOptions("error", "drop", "fallback"),
+			docs.FieldAdvanced("validate_utf8", "Whether to check that the resolved key and topic of each message are valid UTF-8 without control characters before sending it, which would otherwise be rejected by brokers."),
+			docs.FieldAdvanced("on_invalid", "What to do with a message when `validate_utf8` is enabled and its key or topic is invalid. When set to `error` the message fails with a non-retriable error while the rest of the batch is sent, `drop` acknowledges the message without sending it, and `sanitize` removes invalid sequences and control characters before sending it.").HasOptions("error", "drop", "sanitize"),
+			docs.FieldAdvanced("audit_log", "Whether to log a summary of each batch after it is sent at the `INFO` level, with the fields `count`, `bytes`, `topics` and `duration`. When enabled batches are sent in the same way as when `max_in_flight` is greater than one."),
+			docs.FieldAdvanced("offset_metadata", "Whether to add the partition and offset of each successfully produced record to the metadata of its message as `kafka_partition` and `kafka_offset`. Brokers send copies of messages to each of their outputs, so the metadata is only added to the copy sent by this output. The partition and offset of each record are also logged at the `DEBUG` level regardless of this field."),
+			docs.FieldAdvanced("validate_on_start", "Whether to connect to the brokers when the output is created, failing with an error when none are reachable or when the `topic` field is static and the topic does not exist. Topics that are going to be created by `create_topics` are not checked. This is disabled by default as it prevents Benthos from starting before Kafka is available."),
+			docs.FieldAdvanced("preserve_order", "Whether to send batches that share a record key, or partition when one is set explicitly, in the order they were received when `max_in_flight` is greater than one. Batches without keys in common are still sent in parallel. Read the [ordering section](#ordering) for more details."),
+			docs.FieldAdvanced("batch_per_topic", "Whether to batch the messages of each resolved topic separately, with each topic flushed by its own triggers of the `batching` policy. Read the [per topic batching section](#per-topic-batching) for more details."),
+			docs.FieldAdvanced("max_topic_batches", "The maximum number of topics batched at once when `batch_per_topic` is enabled, where the batch of the topic that least recently received a message is flushed in order to make room for a new topic."),
+			docs.FieldAdvanced("share_connection", "Whether to share a single producer, and therefore its connections to the brokers, with other `kafka` outputs that have this field enabled and identical connection and producer settings, which reduces the number of connections opened by configs with many outputs targeting the same cluster. The topic, key and other message level fields do not need to match. Outputs with differing producer settings such as `compression` or `required_acks` are given separate producers and connections, as producer settings are bound to the client. The shared producer is closed once the last output using it closes. This field cannot be used with `compression_metrics`."),
 			batch.FieldSpec(),
 		}, retries.FieldSpecs()...),
 	}
@@ -64,6 +200,19 @@ part.`,
 
 //------------------------------------------------------------------------------
 
+// kafkaAuditLogger returns a function that logs a structured summary of each
+// sent batch.
+func kafkaAuditLogger(log log.Modular) func(BatchSummary) {
+	return func(s BatchSummary) {
+		log.WithFields(map[string]string{
+			"count":    strconv.Itoa(s.Count),
+			"bytes":    strconv.Itoa(s.Bytes),
+			"topics":   strings.Join(s.Targets, ","),
+			"duration": s.Duration.String(),
+		}).Infoln("Sent batch")
+	}
+}
+
 // NewKafka creates a new Kafka output type.
 func NewKafka(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
 	k, err := writer.NewKafka(conf.Kafka, mgr, log, stats)
@@ -71,16 +220,31 @@ func NewKafka(conf Config, mgr types.Manager, log log.Modular, stats metrics.Typ
 		return nil, err
 	}
 	var w Type
-	if conf.Kafka.MaxInFlight == 1 {
+	if conf.Kafka.MaxInFlight == 1 && !conf.Kafka.AuditLog {
 		w, err = NewWriter(
 			TypeKafka, k, log, stats,
 		)
 	} else {
+		var opts []func(*AsyncWriter)
+		if conf.Kafka.AuditLog {
+			opts = append(opts, OptAsyncWriterSetOnBatchSent(kafkaAuditLogger(log)))
+		}
+		if conf.Kafka.PreserveOrder && conf.Kafka.MaxInFlight > 1 {
+			opts = append(opts, OptAsyncWriterOrderBy(k.OrderingKeys))
+		}
 		w, err = NewAsyncWriter(
-			TypeKafka, conf.Kafka.MaxInFlight, k, log, stats,
+			TypeKafka, conf.Kafka.MaxInFlight, k, log, stats, opts...,
 		)
 	}
-	if bconf := conf.Kafka.Batching; err == nil && !bconf.IsNoop() {
+	if bconf := conf.Kafka.Batching; err == nil && !bconf.IsNoop() && conf.Kafka.BatchPerTopic {
+		newPolicy := func() (*batch.Policy, error) {
+			return batch.NewPolicy(bconf, mgr, log.NewModule(".batching"), metrics.Namespaced(stats, "batching"))
+		}
+		if _, err = newPolicy(); err != nil {
+			return nil, fmt.Errorf("failed to construct batch policy: %v", err)
+		}
+		w = NewKeyedBatcher(newPolicy, k.BatchTopic, conf.Kafka.MaxTopicBatches, w, log, stats)
+	} else if err == nil && !bconf.IsNoop() {
 		policy, err := batch.NewPolicy(bconf, mgr, log.NewModule(".batching"), metrics.Namespaced(stats, "batching"))
 		if err != nil {
 			return nil, fmt.Errorf("failed to construct batch policy: %v", err)
@@ -0,0 +1,375 @@
+package output
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// keyedBatchTracker tracks the buffers holding the parts of an upstream
+// transaction, which is responded to once all of them have been sent.
+type keyedBatchTracker struct {
+	resChan   chan<- types.Response
+	remaining int
+	err       error
+}
+
+// keyedBatchBuffer is the batch policy of a single key along with the upstream
+// transactions of its buffered parts.
+type keyedBatchBuffer struct {
+	policy     *batch.Policy
+	trackers   []*keyedBatchTracker
+	partCounts []int
+	lastAdd    time.Time
+}
+
+// KeyedBatcher wraps an output with a separate batching policy for each key
+// resolved from the parts of messages, where each key buffers its own parts and
+// is flushed by its own triggers. The number of buffered keys is bounded, and
+// the buffer of the least recently added to key is flushed and removed in order
+// to make room for a new key.
+type KeyedBatcher struct {
+	stats metrics.Type
+	log   log.Modular
+
+	child     Type
+	keyFn     func(types.Part) string
+	newPolicy func() (*batch.Policy, error)
+	maxKeys   int
+
+	trackerMut sync.Mutex
+
+	messagesIn  <-chan types.Transaction
+	messagesOut chan types.Transaction
+
+	running int32
+
+	closeChan      chan struct{}
+	fullyCloseChan chan struct{}
+	fullyCloseOnce sync.Once
+
+	closedChan chan struct{}
+
+	mEvicted metrics.StatCounter
+}
+
+// NewKeyedBatcher creates a new Producer/Consumer that batches the parts of
+// each key resolved by keyFn with a policy created by newPolicy, buffering at
+// most maxKeys keys at once.
+func NewKeyedBatcher(
+	newPolicy func() (*batch.Policy, error),
+	keyFn func(types.Part) string,
+	maxKeys int,
+	child Type,
+	log log.Modular,
+	stats metrics.Type,
+) Type {
+	return &KeyedBatcher{
+		stats:          stats,
+		log:            log,
+		child:          child,
+		keyFn:          keyFn,
+		newPolicy:      newPolicy,
+		maxKeys:        maxKeys,
+		messagesOut:    make(chan types.Transaction),
+		running:        1,
+		closeChan:      make(chan struct{}),
+		fullyCloseChan: make(chan struct{}),
+		closedChan:     make(chan struct{}),
+		mEvicted:       stats.GetCounter("batching.evicted"),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// release marks a buffer holding parts of a transaction as sent, and responds
+// to the transaction once all of its buffers have been sent.
+func (m *KeyedBatcher) release(t *keyedBatchTracker, err error) {
+	m.trackerMut.Lock()
+	if err != nil && t.err == nil {
+		t.err = err
+	}
+	t.remaining--
+	done := t.remaining == 0
+	err = t.err
+	m.trackerMut.Unlock()
+
+	if !done {
+		return
+	}
+	var res types.Response = response.NewAck()
+	if err != nil {
+		res = response.NewError(err)
+	}
+	go func() {
+		select {
+		case t.resChan <- res:
+		case <-m.fullyCloseChan:
+		}
+	}()
+}
+
+// flush sends the buffered parts of a key as a batch, returns false if the
+// batcher was closed before the batch could be sent.
+func (m *KeyedBatcher) flush(buf *keyedBatchBuffer) bool {
+	sendMsg := buf.policy.Flush()
+	trackers, partCounts := buf.trackers, buf.partCounts
+	buf.trackers, buf.partCounts = nil, nil
+	if sendMsg == nil {
+		return true
+	}
+
+	chunks := buf.policy.Chunks(sendMsg)
+	resChans := make([]chan types.Response, len(chunks))
+	for i := range resChans {
+		resChans[i] = make(chan types.Response)
+	}
+	go func() {
+		results := make([]types.Response, len(resChans))
+		for i, resChan := range resChans {
+			select {
+			case <-m.fullyCloseChan:
+				return
+			case results[i] = <-resChan:
+			}
+		}
+		res := results[0]
+		if len(results) > 1 {
+			res = groupedResponse(sendMsg, chunks, results)
+		}
+		resFor := func(int) types.Response { return res }
+		if bErr, ok := res.Error().(*batch.Error); ok && bErr.IndexedErrors() > 0 {
+			resFor = partialResponses(bErr, partCounts)
+		}
+		for i, t := range trackers {
+			m.release(t, resFor(i).Error())
+		}
+	}()
+
+	for i, chunk := range chunks {
+		chunkMsg := sendMsg
+		if len(chunks) > 1 {
+			chunkMsg = message.New(nil)
+			for _, j := range chunk {
+				chunkMsg.Append(sendMsg.Get(j))
+			}
+		}
+		select {
+		case m.messagesOut <- types.NewTransaction(chunkMsg, resChans[i]):
+		case <-m.fullyCloseChan:
+			return false
+		}
+	}
+	return true
+}
+
+func (m *KeyedBatcher) loop() {
+	defer func() {
+		close(m.messagesOut)
+		m.child.CloseAsync()
+		err := m.child.WaitForClose(time.Second)
+		for err != nil {
+			err = m.child.WaitForClose(time.Second)
+		}
+		close(m.closedChan)
+	}()
+
+	buffers := map[string]*keyedBatchBuffer{}
+
+	flushAll := func() {
+		for _, buf := range buffers {
+			if !m.flush(buf) {
+				return
+			}
+		}
+	}
+
+	// getBuffer returns the buffer of a key, creating it and evicting the least
+	// recently added to buffer when at capacity.
+	getBuffer := func(key string) (*keyedBatchBuffer, bool) {
+		if buf, exists := buffers[key]; exists {
+			return buf, true
+		}
+		if len(buffers) >= m.maxKeys {
+			var evictKey string
+			var evict *keyedBatchBuffer
+			for k, buf := range buffers {
+				if evict == nil || buf.lastAdd.Before(evict.lastAdd) {
+					evictKey, evict = k, buf
+				}
+			}
+			m.mEvicted.Incr(1)
+			delete(buffers, evictKey)
+			if !m.flush(evict) {
+				return nil, false
+			}
+		}
+		policy, err := m.newPolicy()
+		if err != nil {
+			// The policy config is validated at construction and therefore
+			// this should never happen.
+			m.log.Errorf("Failed to create batch policy: %v\n", err)
+			return nil, false
+		}
+		buf := &keyedBatchBuffer{policy: policy}
+		buffers[key] = buf
+		return buf, true
+	}
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for atomic.LoadInt32(&m.running) == 1 {
+		// Schedule the earliest timed flush of all non-empty buffers.
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		var timerChan <-chan time.Time
+		var next time.Duration
+		found := false
+		for _, buf := range buffers {
+			if buf.policy.Count() == 0 || !buf.policy.Timed() {
+				continue
+			}
+			// An overdue buffer has a negative duration until its flush.
+			until := buf.policy.UntilNext()
+			if until < 0 {
+				until = 0
+			}
+			if !found || until < next {
+				next, found = until, true
+			}
+		}
+		if found {
+			timer.Reset(next)
+			timerChan = timer.C
+		}
+
+		select {
+		case tran, open := <-m.messagesIn:
+			if !open {
+				atomic.StoreInt32(&m.running, 0)
+				flushAll()
+				return
+			}
+
+			// The tracker is held until all parts have been added, as buffers
+			// may be flushed whilst parts of the transaction are added.
+			tracker := &keyedBatchTracker{
+				resChan:   tran.ResponseChan,
+				remaining: 1,
+			}
+			triggered := map[*keyedBatchBuffer]struct{}{}
+			var buf *keyedBatchBuffer
+			var lastKey string
+			ok := true
+			tran.Payload.Iter(func(i int, p types.Part) error {
+				if !ok {
+					return nil
+				}
+				key := m.keyFn(p)
+				if buf == nil || key != lastKey || buffers[key] != buf {
+					if buf, ok = getBuffer(key); !ok {
+						return nil
+					}
+					lastKey = key
+					if n := len(buf.trackers); n == 0 || buf.trackers[n-1] != tracker {
+						if buf.policy.Count() == 0 {
+							// Begin the period of the batch with its first
+							// part rather than the last flush.
+							buf.policy.Flush()
+						}
+						m.trackerMut.Lock()
+						tracker.remaining++
+						m.trackerMut.Unlock()
+						buf.trackers = append(buf.trackers, tracker)
+						buf.partCounts = append(buf.partCounts, 0)
+					}
+				}
+				buf.partCounts[len(buf.partCounts)-1]++
+				buf.lastAdd = time.Now()
+				if buf.policy.Add(p) {
+					triggered[buf] = struct{}{}
+				}
+				return nil
+			})
+			if !ok {
+				return
+			}
+			for tBuf := range triggered {
+				if !m.flush(tBuf) {
+					return
+				}
+			}
+			m.release(tracker, nil)
+		case <-timerChan:
+			for _, buf := range buffers {
+				if buf.policy.Count() > 0 && buf.policy.Timed() && buf.policy.UntilNext() <= 0 {
+					if !m.flush(buf) {
+						return
+					}
+				}
+			}
+		case <-m.closeChan:
+			atomic.StoreInt32(&m.running, 0)
+			flushAll()
+			return
+		}
+	}
+}
+
+// Connected returns a boolean indicating whether this output is currently
+// connected to its target.
+func (m *KeyedBatcher) Connected() bool {
+	return m.child.Connected()
+}
+
+// Consume assigns a messages channel for the output to read.
+func (m *KeyedBatcher) Consume(msgs <-chan types.Transaction) error {
+	if m.messagesIn != nil {
+		return types.ErrAlreadyStarted
+	}
+	if err := m.child.Consume(m.messagesOut); err != nil {
+		return err
+	}
+	m.messagesIn = msgs
+	go m.loop()
+	return nil
+}
+
+// CloseAsync shuts down the KeyedBatcher and stops processing messages.
+func (m *KeyedBatcher) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&m.running, 1, 0) {
+		close(m.closeChan)
+	}
+}
+
+// WaitForClose blocks until the KeyedBatcher output has closed down.
+func (m *KeyedBatcher) WaitForClose(timeout time.Duration) error {
+	if atomic.LoadInt32(&m.running) == 0 {
+		go m.fullyCloseOnce.Do(func() {
+			<-time.After(timeout - time.Second)
+			close(m.fullyCloseChan)
+		})
+	}
+	select {
+	case <-m.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,354 @@
+package output
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func newKeyedBatcherForTest(t *testing.T, conf batch.PolicyConfig, maxKeys int, stats metrics.Type) (Type, *mockOutput) {
+	t.Helper()
+	newPolicy := func() (*batch.Policy, error) {
+		return batch.NewPolicy(conf, nil, log.Noop(), metrics.Noop())
+	}
+	keyFn := func(p types.Part) string {
+		return p.Metadata().Get("topic")
+	}
+	out := &mockOutput{}
+	return NewKeyedBatcher(newPolicy, keyFn, maxKeys, out, log.Noop(), stats), out
+}
+
+func topicMsg(contents ...string) types.Message {
+	msg := message.New(nil)
+	for _, c := range contents {
+		part := message.NewPart([]byte(c))
+		part.Metadata().Set("topic", c[:1])
+		msg.Append(part)
+	}
+	return msg
+}
+
+func msgContents(msg types.Message) []string {
+	var contents []string
+	msg.Iter(func(i int, p types.Part) error {
+		contents = append(contents, string(p.Get()))
+		return nil
+	})
+	return contents
+}
+
+func TestKeyedBatcherTopics(t *testing.T) {
+	tInChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	conf := batch.NewPolicyConfig()
+	conf.Count = 3
+	conf.Period = "100ms"
+
+	b, out := newKeyedBatcherForTest(t, conf, 10, metrics.Noop())
+	if err := b.Consume(tInChan); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(contents string) {
+		t.Helper()
+		select {
+		case tInChan <- types.NewTransaction(topicMsg(contents), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	expectBatch := func(exp []string, sendErr error) {
+		t.Helper()
+		var tran types.Transaction
+		select {
+		case tran = <-out.ts:
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		if act := msgContents(tran.Payload); !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong batch: %v != %v", act, exp)
+		}
+		select {
+		case tran.ResponseChan <- response.NewError(sendErr):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		for range exp {
+			select {
+			case res := <-resChan:
+				if act := res.Error(); act != sendErr {
+					t.Errorf("Wrong response: %v != %v", act, sendErr)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("timed out")
+			}
+		}
+	}
+
+	for _, c := range []string{"a0", "b0", "a1", "c0", "a2"} {
+		send(c)
+	}
+	expectBatch([]string{"a0", "a1", "a2"}, nil)
+
+	<-time.After(time.Millisecond * 50)
+	send("b1")
+	send("a3")
+	send("b2")
+	bErr := errors.New("b failed")
+	expectBatch([]string{"b0", "b1", "b2"}, bErr)
+
+	// The quiet topics are flushed by the period of their own batches.
+	tStarted := time.Now()
+	expectBatch([]string{"c0"}, nil)
+	expectBatch([]string{"a3"}, nil)
+	if dur := time.Since(tStarted); dur < time.Millisecond*25 || dur > time.Millisecond*500 {
+		t.Errorf("Unexpected period flush duration: %v", dur)
+	}
+
+	b.CloseAsync()
+	if err := b.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestKeyedBatcherEviction(t *testing.T) {
+	tInChan := make(chan types.Transaction)
+	resChan := make(chan types.Response, 10)
+
+	conf := batch.NewPolicyConfig()
+	conf.Count = 10
+
+	stats := metrics.NewLocal()
+	b, out := newKeyedBatcherForTest(t, conf, 2, stats)
+	if err := b.Consume(tInChan); err != nil {
+		t.Fatal(err)
+	}
+
+	readBatch := func() []string {
+		t.Helper()
+		var tran types.Transaction
+		select {
+		case tran = <-out.ts:
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		select {
+		case tran.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		return msgContents(tran.Payload)
+	}
+
+	for _, c := range []string{"a0", "b0", "a1"} {
+		select {
+		case tInChan <- types.NewTransaction(topicMsg(c), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	go func() {
+		select {
+		case tInChan <- types.NewTransaction(topicMsg("c0"), resChan):
+		case <-time.After(time.Second):
+			t.Error("timed out")
+		}
+		close(tInChan)
+	}()
+
+	// The topic b least recently received a message and is evicted.
+	if exp, act := []string{"b0"}, readBatch(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong evicted batch: %v != %v", act, exp)
+	}
+
+	// Closing the input flushes the remaining batches.
+	remaining := [][]string{readBatch(), readBatch()}
+	sort.Slice(remaining, func(i, j int) bool {
+		return remaining[i][0] < remaining[j][0]
+	})
+	if exp := [][]string{{"a0", "a1"}, {"c0"}}; !reflect.DeepEqual(exp, remaining) {
+		t.Errorf("Wrong remaining batches: %v != %v", remaining, exp)
+	}
+
+	for i := 0; i < 4; i++ {
+		select {
+		case res := <-resChan:
+			if err := res.Error(); err != nil {
+				t.Error(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	if err := b.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+	if exp, act := int64(1), stats.GetCounters()["batching.evicted"]; exp != act {
+		t.Errorf("Wrong evicted count: %v != %v", act, exp)
+	}
+}
+
+func TestKeyedBatcherSplitTransaction(t *testing.T) {
+	tInChan := make(chan types.Transaction)
+
+	conf := batch.NewPolicyConfig()
+	conf.Count = 2
+
+	b, out := newKeyedBatcherForTest(t, conf, 10, metrics.Noop())
+	if err := b.Consume(tInChan); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(msg types.Message) <-chan types.Response {
+		t.Helper()
+		resChan := make(chan types.Response)
+		select {
+		case tInChan <- types.NewTransaction(msg, resChan):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		return resChan
+	}
+
+	respond := func(exp []string, res func(types.Message) types.Response) {
+		t.Helper()
+		var tran types.Transaction
+		select {
+		case tran = <-out.ts:
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		if act := msgContents(tran.Payload); !reflect.DeepEqual(exp, act) {
+			t.Errorf("Wrong batch: %v != %v", act, exp)
+		}
+		select {
+		case tran.ResponseChan <- res(tran.Payload):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	expectRes := func(resChan <-chan types.Response, failed bool) {
+		t.Helper()
+		select {
+		case res := <-resChan:
+			if act := res.Error() != nil; act != failed {
+				t.Errorf("Wrong response failed: %v != %v: %v", act, failed, res.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	firstRes := send(topicMsg("a0", "b0"))
+	secondRes := send(topicMsg("a1"))
+
+	// Only the second message of the batch of topic a fails.
+	respond([]string{"a0", "a1"}, func(msg types.Message) types.Response {
+		return response.NewError(batch.NewError(msg, errors.New("a failed")).Failed(1, errors.New("a1 failed")))
+	})
+	expectRes(secondRes, true)
+
+	// The first message is only acknowledged once its part of topic b is sent.
+	select {
+	case res := <-firstRes:
+		t.Fatalf("Unexpected early response: %v", res.Error())
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	thirdRes := send(topicMsg("b1"))
+	respond([]string{"b0", "b1"}, func(types.Message) types.Response {
+		return response.NewAck()
+	})
+	expectRes(firstRes, false)
+	expectRes(thirdRes, false)
+
+	b.CloseAsync()
+	if err := b.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestKeyedBatcherOverduePeriod(t *testing.T) {
+	tInChan := make(chan types.Transaction)
+	resChan := make(chan types.Response, 10)
+
+	conf := batch.NewPolicyConfig()
+	conf.Count = 2
+	conf.Period = "400ms"
+
+	b, out := newKeyedBatcherForTest(t, conf, 10, metrics.Noop())
+	if err := b.Consume(tInChan); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(msg types.Message) {
+		t.Helper()
+		select {
+		case tInChan <- types.NewTransaction(msg, resChan):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	readBatch := func() []string {
+		t.Helper()
+		var tran types.Transaction
+		select {
+		case tran = <-out.ts:
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		select {
+		case tran.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		return msgContents(tran.Payload)
+	}
+
+	tStarted := time.Now()
+	send(topicMsg("a0"))
+	<-time.After(time.Millisecond * 200)
+
+	// The batch of topic b is flushed but not read until the period of topic a
+	// has passed, leaving topic a overdue whilst topic c is not yet due.
+	send(topicMsg("b0", "b1", "c0"))
+	<-time.After(time.Millisecond*450 - time.Since(tStarted))
+	if exp, act := []string{"b0", "b1"}, readBatch(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong batch: %v != %v", act, exp)
+	}
+
+	tFlushed := time.Now()
+	if exp, act := []string{"a0"}, readBatch(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong batch: %v != %v", act, exp)
+	}
+	if dur := time.Since(tFlushed); dur > time.Millisecond*100 {
+		t.Errorf("Overdue batch flushed late: %v", dur)
+	}
+	if exp, act := []string{"c0"}, readBatch(); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong batch: %v != %v", act, exp)
+	}
+
+	b.CloseAsync()
+	if err := b.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+//------------------------------------------------------------------------------
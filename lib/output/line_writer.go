@@ -2,6 +2,7 @@ package output
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"sync/atomic"
@@ -33,6 +34,9 @@ type LineWriter struct {
 	handle      io.WriteCloser
 	closeOnExit bool
 
+	metaHandle io.WriteCloser
+	metaHeader bool
+
 	closeChan  chan struct{}
 	closedChan chan struct{}
 }
@@ -45,8 +49,9 @@ func NewLineWriter(
 	typeStr string,
 	log log.Modular,
 	stats metrics.Type,
+	opts ...func(*LineWriter),
 ) (Type, error) {
-	return &LineWriter{
+	w := &LineWriter{
 		running:     1,
 		typeStr:     typeStr,
 		log:         log,
@@ -56,7 +61,81 @@ func NewLineWriter(
 		closeOnExit: closeOnExit,
 		closeChan:   make(chan struct{}),
 		closedChan:  make(chan struct{}),
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w, nil
+}
+
+//------------------------------------------------------------------------------
+
+// OptLineWriterSetMetadataSidecar sets a handle that the metadata of each
+// message part is written to as a JSON object followed by a newline. The handle
+// is closed along with the main handle when closeOnExit is true.
+func OptLineWriterSetMetadataSidecar(handle io.WriteCloser) func(*LineWriter) {
+	return func(w *LineWriter) {
+		w.metaHandle = handle
+	}
+}
+
+// OptLineWriterSetMetadataHeader sets whether the metadata of each message part
+// should be written as a JSON object line immediately preceding the part.
+func OptLineWriterSetMetadataHeader(enabled bool) func(*LineWriter) {
+	return func(w *LineWriter) {
+		w.metaHeader = enabled
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func partMetadataJSON(p types.Part) ([]byte, error) {
+	meta := map[string]string{}
+	p.Metadata().Iter(func(k, v string) error {
+		meta[k] = v
+		return nil
+	})
+	return json.Marshal(meta)
+}
+
+func (w *LineWriter) writeMsg(msg types.Message, delim []byte) error {
+	var buf bytes.Buffer
+	var metaBuf bytes.Buffer
+
+	if err := msg.Iter(func(i int, p types.Part) error {
+		if w.metaHeader || w.metaHandle != nil {
+			metaBytes, err := partMetadataJSON(p)
+			if err != nil {
+				return err
+			}
+			if w.metaHeader {
+				buf.Write(metaBytes)
+				buf.Write(delim)
+			}
+			if w.metaHandle != nil {
+				metaBuf.Write(metaBytes)
+				metaBuf.WriteByte('\n')
+			}
+		}
+		buf.Write(p.Get())
+		buf.Write(delim)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if msg.Len() > 1 {
+		buf.Write(delim)
+	}
+
+	if _, err := w.handle.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if w.metaHandle != nil {
+		if _, err := w.metaHandle.Write(metaBuf.Bytes()); err != nil {
+			return fmt.Errorf("failed to write metadata sidecar: %v", err)
+		}
+	}
+	return nil
 }
 
 //------------------------------------------------------------------------------
@@ -75,6 +154,9 @@ func (w *LineWriter) loop() {
 	defer func() {
 		if w.closeOnExit {
 			w.handle.Close()
+			if w.metaHandle != nil {
+				w.metaHandle.Close()
+			}
 		}
 		close(w.closedChan)
 	}()
@@ -100,13 +182,8 @@ func (w *LineWriter) loop() {
 		w.log.Tracef("Attempting to write %v messages to '%v'.\n", ts.Payload.Len(), w.typeStr)
 		spans := tracing.CreateChildSpans("output_"+w.typeStr, ts.Payload)
 
-		var err error
 		t0 := time.Now()
-		if ts.Payload.Len() == 1 {
-			_, err = fmt.Fprintf(w.handle, "%s%s", ts.Payload.Get(0).Get(), delim)
-		} else {
-			_, err = fmt.Fprintf(w.handle, "%s%s%s", bytes.Join(message.GetAllBytes(ts.Payload), delim), delim, delim)
-		}
+		err := w.writeMsg(ts.Payload, delim)
 		latency := time.Since(t0).Nanoseconds()
 		if err == nil {
 			mSent.Incr(1)
@@ -170,3 +170,102 @@ func TestLineWriterCustomDelim(t *testing.T) {
 		t.Error("Buffer was not closed by writer")
 	}
 }
+
+func TestLineWriterMetadataHeader(t *testing.T) {
+	var buf testBuffer
+
+	msgChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	writer, err := NewLineWriter(
+		&buf, true, []byte{}, "foo", log.New(os.Stdout, logConfig), metrics.DudType{},
+		OptLineWriterSetMetadataHeader(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Consume(msgChan); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{[]byte("hello world"), []byte("part 2")})
+	msg.Get(0).Metadata().Set("foo", "bar")
+
+	select {
+	case msgChan <- types.NewTransaction(msg, resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out sending message")
+	}
+
+	select {
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for response")
+	}
+
+	exp := "{\"foo\":\"bar\"}\nhello world\n{}\npart 2\n\n"
+	if act := buf.String(); exp != act {
+		t.Errorf("Unexpected output from writer: %v != %v", exp, act)
+	}
+
+	writer.CloseAsync()
+	if err = writer.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLineWriterMetadataSidecar(t *testing.T) {
+	var buf, metaBuf testBuffer
+
+	msgChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	writer, err := NewLineWriter(
+		&buf, true, []byte{}, "foo", log.New(os.Stdout, logConfig), metrics.DudType{},
+		OptLineWriterSetMetadataSidecar(&metaBuf),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Consume(msgChan); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{[]byte("hello world"), []byte("part 2")})
+	msg.Get(0).Metadata().Set("foo", "bar")
+	msg.Get(1).Metadata().Set("baz", "qux")
+
+	select {
+	case msgChan <- types.NewTransaction(msg, resChan):
+	case <-time.After(time.Second):
+		t.Fatal("Timed out sending message")
+	}
+
+	select {
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for response")
+	}
+
+	if exp, act := "hello world\npart 2\n\n", buf.String(); exp != act {
+		t.Errorf("Unexpected output from writer: %v != %v", exp, act)
+	}
+	if exp, act := "{\"foo\":\"bar\"}\n{\"baz\":\"qux\"}\n", metaBuf.String(); exp != act {
+		t.Errorf("Unexpected metadata output from writer: %v != %v", exp, act)
+	}
+
+	writer.CloseAsync()
+	if err = writer.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+
+	if !metaBuf.closed {
+		t.Error("Metadata buffer was not closed by writer")
+	}
+}
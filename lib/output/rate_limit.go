@@ -0,0 +1,287 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/x/docs"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeRateLimit] = TypeSpec{
+		constructor: NewRateLimit,
+		Description: `
+Throttles the throughput of a child output by accessing a
+[` + "`rate_limit`" + ` resource](/docs/components/rate_limits/about) before
+each batch of messages is written to it. When the rate limit is reached the
+output waits until it is accessible again rather than failing the batch.
+
+` + "``` yaml" + `
+output:
+  rate_limit:
+    resource: foolimit
+    output:
+      http_client:
+        url: http://localhost:4195/post
+` + "```" + `
+
+Each batch consumes a single access of the rate limit regardless of the number
+of messages within it.`,
+		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
+			var outputSanit interface{} = struct{}{}
+			if conf.RateLimit.Output != nil {
+				var err error
+				if outputSanit, err = SanitiseConfig(*conf.RateLimit.Output); err != nil {
+					return nil, err
+				}
+			}
+			return map[string]interface{}{
+				"resource": conf.RateLimit.Resource,
+				"output":   outputSanit,
+			}, nil
+		},
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("resource", "The [`rate_limit` resource](/docs/components/rate_limits/about) to access before each batch is written."),
+			docs.FieldCommon("output", "The child output to write messages to."),
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// RateLimitConfig contains configuration values for the RateLimit output type.
+type RateLimitConfig struct {
+	Resource string  `json:"resource" yaml:"resource"`
+	Output   *Config `json:"output" yaml:"output"`
+}
+
+// NewRateLimitConfig creates a new RateLimitConfig with default values.
+func NewRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Resource: "",
+		Output:   nil,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type dummyRateLimitConfig struct {
+	Resource string      `json:"resource" yaml:"resource"`
+	Output   interface{} `json:"output" yaml:"output"`
+}
+
+// MarshalJSON prints an empty object instead of nil.
+func (r RateLimitConfig) MarshalJSON() ([]byte, error) {
+	dummy := dummyRateLimitConfig{
+		Resource: r.Resource,
+		Output:   r.Output,
+	}
+	if r.Output == nil {
+		dummy.Output = struct{}{}
+	}
+	return json.Marshal(dummy)
+}
+
+// MarshalYAML prints an empty object instead of nil.
+func (r RateLimitConfig) MarshalYAML() (interface{}, error) {
+	dummy := dummyRateLimitConfig{
+		Resource: r.Resource,
+		Output:   r.Output,
+	}
+	if r.Output == nil {
+		dummy.Output = struct{}{}
+	}
+	return dummy, nil
+}
+
+//------------------------------------------------------------------------------
+
+// RateLimit is an output type that accesses a rate limit resource before each
+// batch of messages is written to a child output.
+type RateLimit struct {
+	running int32
+
+	rl      types.RateLimit
+	wrapped Type
+
+	stats metrics.Type
+	log   log.Modular
+
+	transactionsIn  <-chan types.Transaction
+	transactionsOut chan types.Transaction
+
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+// NewRateLimit creates a new RateLimit output type.
+func NewRateLimit(
+	conf Config,
+	mgr types.Manager,
+	log log.Modular,
+	stats metrics.Type,
+) (Type, error) {
+	if conf.RateLimit.Output == nil {
+		return nil, errors.New("cannot create a rate_limit output without a child")
+	}
+
+	rl, err := mgr.GetRateLimit(conf.RateLimit.Resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain rate limit resource '%v': %v", conf.RateLimit.Resource, err)
+	}
+
+	wrapped, err := New(*conf.RateLimit.Output, mgr, log, stats)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output '%v': %v", conf.RateLimit.Output.Type, err)
+	}
+
+	return &RateLimit{
+		running: 1,
+
+		rl: rl,
+
+		log:             log,
+		stats:           stats,
+		wrapped:         wrapped,
+		transactionsOut: make(chan types.Transaction),
+
+		closeChan:  make(chan struct{}),
+		closedChan: make(chan struct{}),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// access blocks until the rate limit is accessible, returning false if the
+// output is closed while waiting.
+func (r *RateLimit) access(mLimited, mErr metrics.StatCounter) bool {
+	for {
+		period, err := r.rl.Access()
+		if err == nil && period <= 0 {
+			return true
+		}
+		if err != nil {
+			mErr.Incr(1)
+			r.log.Errorf("Failed to access rate limit: %v\n", err)
+			period = time.Second
+		} else {
+			mLimited.Incr(1)
+		}
+		select {
+		case <-time.After(period):
+		case <-r.closeChan:
+			return false
+		}
+	}
+}
+
+func (r *RateLimit) loop() {
+	// Metrics paths
+	var (
+		mLimited = r.stats.GetCounter("rate_limit.limited")
+		mErr     = r.stats.GetCounter("rate_limit.error")
+	)
+
+	wg := sync.WaitGroup{}
+
+	defer func() {
+		wg.Wait()
+		close(r.transactionsOut)
+		r.wrapped.CloseAsync()
+		err := r.wrapped.WaitForClose(time.Second)
+		for ; err != nil; err = r.wrapped.WaitForClose(time.Second) {
+		}
+		close(r.closedChan)
+	}()
+
+	for atomic.LoadInt32(&r.running) == 1 {
+		var ts types.Transaction
+		var open bool
+		select {
+		case ts, open = <-r.transactionsIn:
+			if !open {
+				return
+			}
+		case <-r.closeChan:
+			return
+		}
+
+		if !r.access(mLimited, mErr) {
+			return
+		}
+
+		resChan := make(chan types.Response)
+		select {
+		case r.transactionsOut <- types.NewTransaction(ts.Payload, resChan):
+		case <-r.closeChan:
+			return
+		}
+
+		// Responses are relayed asynchronously so that the child is able to
+		// have multiple transactions in flight and the rate limit is the only
+		// throttle.
+		wg.Add(1)
+		go func(ts types.Transaction, resChan chan types.Response) {
+			defer wg.Done()
+
+			var res types.Response
+			select {
+			case res = <-resChan:
+			case <-r.closeChan:
+				return
+			}
+
+			select {
+			case ts.ResponseChan <- res:
+			case <-r.closeChan:
+			}
+		}(ts, resChan)
+	}
+}
+
+// Consume assigns a messages channel for the output to read.
+func (r *RateLimit) Consume(ts <-chan types.Transaction) error {
+	if r.transactionsIn != nil {
+		return types.ErrAlreadyStarted
+	}
+	if err := r.wrapped.Consume(r.transactionsOut); err != nil {
+		return err
+	}
+	r.transactionsIn = ts
+	go r.loop()
+	return nil
+}
+
+// Connected returns a boolean indicating whether this output is currently
+// connected to its target.
+func (r *RateLimit) Connected() bool {
+	return r.wrapped.Connected()
+}
+
+// CloseAsync shuts down the RateLimit output and stops processing requests.
+func (r *RateLimit) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&r.running, 1, 0) {
+		close(r.closeChan)
+	}
+}
+
+// WaitForClose blocks until the RateLimit output has closed down.
+func (r *RateLimit) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-r.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,240 @@
+package output
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+type rateLimitMgr struct {
+	types.DudMgr
+	rls map[string]types.RateLimit
+}
+
+func (r *rateLimitMgr) GetRateLimit(name string) (types.RateLimit, error) {
+	if rl, exists := r.rls[name]; exists {
+		return rl, nil
+	}
+	return nil, types.ErrRateLimitNotFound
+}
+
+type mockRateLimit struct {
+	mut      sync.Mutex
+	periods  []time.Duration
+	accessed int
+}
+
+func (m *mockRateLimit) Access() (time.Duration, error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.accessed++
+	if len(m.periods) == 0 {
+		return 0, nil
+	}
+	p := m.periods[0]
+	m.periods = m.periods[1:]
+	return p, nil
+}
+
+func (m *mockRateLimit) CloseAsync() {}
+
+func (m *mockRateLimit) WaitForClose(time.Duration) error {
+	return nil
+}
+
+func TestRateLimitConfigErrs(t *testing.T) {
+	mgr := &rateLimitMgr{rls: map[string]types.RateLimit{}}
+
+	conf := NewConfig()
+	conf.Type = TypeRateLimit
+	if _, err := New(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing child")
+	}
+
+	childConf := NewConfig()
+	conf.RateLimit.Output = &childConf
+	conf.RateLimit.Resource = "foo"
+	if _, err := New(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing rate limit")
+	}
+}
+
+func TestRateLimitBasic(t *testing.T) {
+	rl := &mockRateLimit{
+		periods: []time.Duration{0, time.Millisecond * 50, time.Millisecond * 50},
+	}
+	mgr := &rateLimitMgr{rls: map[string]types.RateLimit{"foo": rl}}
+
+	conf := NewConfig()
+	childConf := NewConfig()
+	conf.RateLimit.Output = &childConf
+	conf.RateLimit.Resource = "foo"
+
+	output, err := NewRateLimit(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mOut := &mockOutput{}
+	output.(*RateLimit).wrapped = mOut
+
+	tChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+	if err = output.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	sendAndExpect := func(content string, minWait time.Duration) {
+		t.Helper()
+
+		start := time.Now()
+		select {
+		case tChan <- types.NewTransaction(message.New([][]byte{[]byte(content)}), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+
+		var tran types.Transaction
+		select {
+		case tran = <-mOut.ts:
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		if waited := time.Since(start); waited < minWait {
+			t.Errorf("Expected send to block for at least %v, waited %v", minWait, waited)
+		}
+		if act := string(tran.Payload.Get(0).Get()); act != content {
+			t.Errorf("Wrong message sent: %v != %v", act, content)
+		}
+
+		select {
+		case tran.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		select {
+		case res := <-resChan:
+			if res.Error() != nil {
+				t.Error(res.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	sendAndExpect("foo", 0)
+	sendAndExpect("bar", time.Millisecond*100)
+
+	rl.mut.Lock()
+	if exp, act := 4, rl.accessed; exp != act {
+		t.Errorf("Wrong count of rate limit accesses: %v != %v", act, exp)
+	}
+	rl.mut.Unlock()
+
+	output.CloseAsync()
+	if err = output.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRateLimitCloseWhileLimited(t *testing.T) {
+	rl := &mockRateLimit{
+		periods: []time.Duration{time.Hour},
+	}
+	mgr := &rateLimitMgr{rls: map[string]types.RateLimit{"foo": rl}}
+
+	conf := NewConfig()
+	childConf := NewConfig()
+	conf.RateLimit.Output = &childConf
+	conf.RateLimit.Resource = "foo"
+
+	output, err := NewRateLimit(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mOut := &mockOutput{}
+	output.(*RateLimit).wrapped = mOut
+
+	tChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+	if err = output.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case tChan <- types.NewTransaction(message.New([][]byte{[]byte("foo")}), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	output.CloseAsync()
+	if err = output.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRateLimitSlowChild(t *testing.T) {
+	rl := &mockRateLimit{}
+	mgr := &rateLimitMgr{rls: map[string]types.RateLimit{"foo": rl}}
+
+	conf := NewConfig()
+	childConf := NewConfig()
+	conf.RateLimit.Output = &childConf
+	conf.RateLimit.Resource = "foo"
+
+	output, err := NewRateLimit(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mOut := &mockOutput{}
+	output.(*RateLimit).wrapped = mOut
+
+	tChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+	if err = output.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	// The child takes 100ms to respond to each transaction but handles them
+	// in parallel.
+	go func() {
+		for tran := range mOut.ts {
+			go func(tran types.Transaction) {
+				<-time.After(time.Millisecond * 100)
+				tran.ResponseChan <- response.NewAck()
+			}(tran)
+		}
+	}()
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		select {
+		case tChan <- types.NewTransaction(message.New([][]byte{[]byte("foo")}), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+	for i := 0; i < 10; i++ {
+		select {
+		case res := <-resChan:
+			if res.Error() != nil {
+				t.Error(res.Error())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Millisecond*500 {
+		t.Errorf("Expected transactions to be sent in parallel, took %v", elapsed)
+	}
+
+	output.CloseAsync()
+	if err = output.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
@@ -24,7 +24,9 @@ func init() {
 		Description: `
 Attempts to write messages to a child output and if the write fails for any
 reason the message is retried either until success or, if the retries or max
-elapsed time fields are non-zero, either is reached.
+elapsed time fields are non-zero, either is reached. Errors that the child
+output reports as non-retriable, such as a message exceeding the size limit of
+its target, are returned immediately without retrying.
 
 All messages in Benthos are always retried on an output error, but this would
 usually involve propagating the error back to the source of the message, whereby
@@ -180,6 +182,7 @@ func (r *Retry) loop() {
 		mPartsSuccess = r.stats.GetCounter("retry.parts.send.success")
 		mError        = r.stats.GetCounter("retry.send.error")
 		mEndOfRetries = r.stats.GetCounter("retry.end_of_retries")
+		mNonRetriable = r.stats.GetCounter("retry.non_retriable")
 	)
 
 	wg := sync.WaitGroup{}
@@ -272,10 +275,15 @@ func (r *Retry) loop() {
 						backOff = r.backoffCtor()
 					}
 
-					nextBackoff := backOff.NextBackOff()
+					nextBackoff := retries.NextBackOff(backOff, res.Error())
 					if nextBackoff == backoff.Stop {
-						mEndOfRetries.Incr(1)
-						resOut = response.NewNoack()
+						if types.IsNonRetriable(res.Error()) {
+							mNonRetriable.Incr(1)
+							resOut = res
+						} else {
+							mEndOfRetries.Incr(1)
+							resOut = response.NewNoack()
+						}
 						break retryLoop
 					}
 					select {
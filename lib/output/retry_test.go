@@ -1,6 +1,7 @@
 package output
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -191,6 +192,77 @@ func TestRetrySadPath(t *testing.T) {
 	}
 }
 
+func TestRetryNonRetriable(t *testing.T) {
+	conf := NewConfig()
+
+	childConf := NewConfig()
+	conf.Retry.Output = &childConf
+	conf.Retry.Backoff.InitialInterval = "10us"
+	conf.Retry.Backoff.MaxInterval = "10us"
+
+	output, err := NewRetry(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ret, ok := output.(*Retry)
+	if !ok {
+		t.Fatal("Failed to cast")
+	}
+
+	mOut := &mockOutput{
+		ts: make(chan types.Transaction),
+	}
+	ret.wrapped = mOut
+
+	tChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	if err = ret.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	testMsg := message.New(nil)
+	tran := types.NewTransaction(testMsg, resChan)
+
+	go func() {
+		select {
+		case tChan <- tran:
+		case <-time.After(time.Second):
+			t.Error("timed out")
+		}
+	}()
+
+	select {
+	case tran = <-mOut.ts:
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	sendErr := types.NonRetriableError{Err: errors.New("message too large")}
+	select {
+	case tran.ResponseChan <- response.NewError(sendErr):
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	select {
+	case <-mOut.ts:
+		t.Fatal("Received retry of non-retriable message")
+	case res := <-resChan:
+		if err = res.Error(); err != sendErr {
+			t.Errorf("Wrong error returned: %v != %v", err, sendErr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	output.CloseAsync()
+	if err = output.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
 func expectFromRetry(
 	resReturn types.Response,
 	tChan <-chan types.Transaction,
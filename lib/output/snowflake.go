@@ -0,0 +1,134 @@
+//go:build SNOWFLAKE
+// +build SNOWFLAKE
+
+package output
+
+import (
+	"fmt"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/aws/session"
+	"github.com/Jeffail/benthos/v3/lib/x/docs"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeSnowflake] = TypeSpec{
+		constructor: NewSnowflake,
+		Description: `
+Loads messages into a Snowflake table by writing each batch as a file to an
+external stage backed by Amazon S3 and then running a ` + "`COPY INTO`" + `
+statement for that file.
+
+The messages of a batch are written as a single file with each message followed
+by a newline, and therefore the ` + "`batching`" + ` policy determines the size of
+the files that are staged. The path of each file relative to the stage is
+determined by the field ` + "`path`" + `, which is
+[function interpolated](/docs/configuration/interpolation#functions) per batch.
+The fields ` + "`stage_bucket`" + ` and ` + "`stage_prefix`" + ` must match
+the URL of the stage, for example a stage created with the URL
+` + "`s3://foo/bar/`" + ` requires a bucket ` + "`foo`" + ` and a prefix
+` + "`bar`" + `.
+
+The field ` + "`file_format`" + ` contains the options of the
+` + "`FILE_FORMAT`" + ` clause of the statement, which can also reference a named
+file format with ` + "`FORMAT_NAME = 'foo'`" + `.
+
+Only external stages are supported, since the Snowflake driver that is used is
+unable to upload files to internal stages.
+
+Since the Snowflake driver performs work when it is loaded this output is not
+compiled by default. In order to add it to your build use the tag
+` + "`SNOWFLAKE`" + `:
+
+` + "```sh" + `
+go install -tags "SNOWFLAKE" github.com/Jeffail/benthos/v3/cmd/...
+` + "```" + `
+
+### Authentication
+
+Either a ` + "`password`" + ` or a ` + "`private_key_file`" + ` must be
+specified. The private key must be an unencrypted RSA key in PEM format, and is
+used for [key pair authentication](https://docs.snowflake.com/en/user-guide/key-pair-auth.html).
+
+### Load Errors
+
+Files are loaded with ` + "`ON_ERROR = SKIP_FILE`" + `. When a file fails to
+load, for example because a message is not valid for the file format, the
+batch fails with a non-retriable error containing the first error reported by
+Snowflake. This means a wrapping ` + "[`retry`](/docs/components/outputs/retry)" + `
+output gives up on the batch immediately and a
+` + "[`try`](/docs/components/outputs/try)" + ` output moves it straight to the
+next output, which can be used as a dead letter queue. Failed files remain in
+the stage.
+
+### Credentials
+
+The fields ` + "`region`" + `, ` + "`endpoint`" + ` and ` + "`credentials`" + `
+are used when writing files to the bucket of the stage. By default Benthos will
+use a shared credentials file when connecting to AWS services. It's also
+possible to set them explicitly at the component level, allowing you to transfer
+data across accounts. You can find out more
+[in this document](/docs/guides/aws).`,
+		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
+			return sanitiseWithBatch(conf.Snowflake, conf.Snowflake.Batching)
+		},
+		Async:   true,
+		Batches: true,
+		FieldSpecs: append(append(docs.FieldSpecs{
+			docs.FieldCommon("account", "The Snowflake account identifier to connect to.", "xy12345.eu-west-1"),
+			docs.FieldCommon("user", "The user to connect as."),
+			docs.FieldCommon("password", "A password to authenticate with. It is recommended that you use environment variables to populate this field.", "${SNOWFLAKE_PASSWORD}"),
+			docs.FieldCommon("private_key_file", "The path of an RSA private key file to authenticate with."),
+			docs.FieldAdvanced("role", "An optional role to assume."),
+			docs.FieldCommon("warehouse", "The warehouse to run statements with."),
+			docs.FieldCommon("database", "The database of the table."),
+			docs.FieldCommon("schema", "The schema of the table."),
+			docs.FieldCommon("table", "The table to load messages into."),
+			docs.FieldCommon("stage", "The name of an external stage to write files to."),
+			docs.FieldCommon("stage_bucket", "The S3 bucket of the stage URL."),
+			docs.FieldCommon("stage_prefix", "The path of the stage URL within the bucket."),
+		}, session.FieldSpecs()...), docs.FieldSpecs{
+			docs.FieldAdvanced("path", "The path of each staged file relative to the stage.").SupportsInterpolation(true),
+			docs.FieldAdvanced("file_format", "The options of the file format used to load files.", "TYPE = JSON", "FORMAT_NAME = 'foo'"),
+			docs.FieldAdvanced("timeout", "The maximum period of time to wait for a batch to be staged and loaded, which must be greater than zero."),
+			docs.FieldCommon("max_in_flight", "The maximum number of batches to have in flight at a given time. Increase this to improve throughput."),
+			batch.FieldSpec(),
+		}...),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewSnowflake creates a new Snowflake output type.
+func NewSnowflake(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	s, err := writer.NewSnowflake(conf.Snowflake, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	var w Type
+	if conf.Snowflake.MaxInFlight == 1 {
+		w, err = NewWriter(
+			TypeSnowflake, s, log, stats,
+		)
+	} else {
+		w, err = NewAsyncWriter(
+			TypeSnowflake, conf.Snowflake.MaxInFlight, s, log, stats,
+		)
+	}
+	if bconf := conf.Snowflake.Batching; err == nil && !bconf.IsNoop() {
+		policy, err := batch.NewPolicy(bconf, mgr, log.NewModule(".batching"), metrics.Namespaced(stats, "batching"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to construct batch policy: %v", err)
+		}
+		w = NewBatcher(policy, w, log, stats)
+	}
+	return w, err
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,235 @@
+package writer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// BenchmarkConfig contains configuration fields for the benchmark output type.
+type BenchmarkConfig struct {
+	MaxInFlight     int    `json:"max_in_flight" yaml:"max_in_flight"`
+	Latency         string `json:"latency" yaml:"latency"`
+	SummaryInterval string `json:"summary_interval" yaml:"summary_interval"`
+}
+
+// NewBenchmarkConfig creates a new BenchmarkConfig with default values.
+func NewBenchmarkConfig() BenchmarkConfig {
+	return BenchmarkConfig{
+		MaxInFlight:     1,
+		Latency:         "",
+		SummaryInterval: "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// benchmarkRateInterval is the period over which throughput rates are
+// calculated when a summary interval is not configured.
+const benchmarkRateInterval = time.Second
+
+// Benchmark is a benthos writer.Type implementation that discards messages
+// whilst recording their throughput and the latency of each write.
+type Benchmark struct {
+	log log.Modular
+
+	latency         time.Duration
+	summaryInterval time.Duration
+
+	statsMut      sync.Mutex
+	windowStart   time.Time
+	windowMsgs    int64
+	windowBytes   int64
+	windowWrites  int64
+	windowLatency time.Duration
+	windowMaxLat  time.Duration
+
+	mMessages    metrics.StatCounter
+	mBytes       metrics.StatCounter
+	mLatency     metrics.StatTimer
+	mMessageRate metrics.StatGauge
+	mByteRate    metrics.StatGauge
+
+	closeOnce  sync.Once
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+// NewBenchmark creates a new benchmark based writer.Type.
+func NewBenchmark(
+	conf BenchmarkConfig,
+	log log.Modular,
+	stats metrics.Type,
+) (*Benchmark, error) {
+	b := &Benchmark{
+		log:          log,
+		windowStart:  time.Now(),
+		mMessages:    stats.GetCounter("benchmark.messages"),
+		mBytes:       stats.GetCounter("benchmark.bytes"),
+		mLatency:     stats.GetTimer("benchmark.latency"),
+		mMessageRate: stats.GetGauge("benchmark.messages_per_sec"),
+		mByteRate:    stats.GetGauge("benchmark.bytes_per_sec"),
+		closeChan:    make(chan struct{}),
+		closedChan:   make(chan struct{}),
+	}
+	if conf.MaxInFlight < 1 {
+		return nil, fmt.Errorf("max_in_flight must be at least 1, got %v", conf.MaxInFlight)
+	}
+	if len(conf.Latency) > 0 {
+		var err error
+		if b.latency, err = time.ParseDuration(conf.Latency); err != nil {
+			return nil, fmt.Errorf("failed to parse latency duration string: %v", err)
+		}
+	}
+	if len(conf.SummaryInterval) > 0 {
+		var err error
+		if b.summaryInterval, err = time.ParseDuration(conf.SummaryInterval); err != nil {
+			return nil, fmt.Errorf("failed to parse summary_interval duration string: %v", err)
+		}
+		if b.summaryInterval <= 0 {
+			return nil, fmt.Errorf("summary_interval must be positive, got %v", conf.SummaryInterval)
+		}
+	}
+	go b.loop()
+	return b, nil
+}
+
+//------------------------------------------------------------------------------
+
+// loop periodically updates the throughput rate gauges and, when a summary
+// interval is configured, logs a summary of the throughput of the interval.
+func (b *Benchmark) loop() {
+	defer close(b.closedChan)
+
+	interval := benchmarkRateInterval
+	if b.summaryInterval > 0 {
+		interval = b.summaryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.flushWindow()
+		case <-b.closeChan:
+			return
+		}
+	}
+}
+
+// flushWindow calculates the throughput rates of the current window, updates
+// the rate gauges, optionally logs a summary and begins a new window.
+func (b *Benchmark) flushWindow() {
+	b.statsMut.Lock()
+	elapsed := time.Since(b.windowStart)
+	msgs, bytes := b.windowMsgs, b.windowBytes
+	writes, latency, maxLat := b.windowWrites, b.windowLatency, b.windowMaxLat
+	b.windowStart = time.Now()
+	b.windowMsgs, b.windowBytes = 0, 0
+	b.windowWrites, b.windowLatency, b.windowMaxLat = 0, 0, 0
+	b.statsMut.Unlock()
+
+	if elapsed <= 0 {
+		return
+	}
+	msgRate := float64(msgs) / elapsed.Seconds()
+	byteRate := float64(bytes) / elapsed.Seconds()
+	b.mMessageRate.Set(int64(math.Round(msgRate)))
+	b.mByteRate.Set(int64(math.Round(byteRate)))
+
+	if b.summaryInterval <= 0 {
+		return
+	}
+	var meanLat time.Duration
+	if writes > 0 {
+		meanLat = latency / time.Duration(writes)
+	}
+	b.log.Infof(
+		"Throughput: %.1f msg/sec, %.1f bytes/sec, %v writes, mean latency %v, max latency %v\n",
+		msgRate, byteRate, writes, meanLat, maxLat,
+	)
+}
+
+//------------------------------------------------------------------------------
+
+// ConnectWithContext is a noop.
+func (b *Benchmark) ConnectWithContext(ctx context.Context) error {
+	return b.Connect()
+}
+
+// Connect is a noop.
+func (b *Benchmark) Connect() error {
+	b.log.Infoln("Discarding messages for benchmarking.")
+	return nil
+}
+
+// WriteWithContext waits for the configured latency, discards the message and
+// records its size and the latency from its creation until the write completes.
+func (b *Benchmark) WriteWithContext(ctx context.Context, msg types.Message) error {
+	if b.latency > 0 {
+		select {
+		case <-time.After(b.latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.closeChan:
+			return types.ErrTypeClosed
+		}
+	}
+
+	var bytes int64
+	msg.Iter(func(i int, p types.Part) error {
+		bytes += int64(len(p.Get()))
+		return nil
+	})
+	latency := time.Since(msg.CreatedAt())
+
+	b.mMessages.Incr(int64(msg.Len()))
+	b.mBytes.Incr(bytes)
+	b.mLatency.Timing(latency.Nanoseconds())
+
+	b.statsMut.Lock()
+	b.windowMsgs += int64(msg.Len())
+	b.windowBytes += bytes
+	b.windowWrites++
+	b.windowLatency += latency
+	if latency > b.windowMaxLat {
+		b.windowMaxLat = latency
+	}
+	b.statsMut.Unlock()
+	return nil
+}
+
+// Write waits for the configured latency, discards the message and records its
+// size and the latency from its creation until the write completes.
+func (b *Benchmark) Write(msg types.Message) error {
+	return b.WriteWithContext(context.Background(), msg)
+}
+
+// CloseAsync begins cleaning up resources used by this writer asynchronously.
+func (b *Benchmark) CloseAsync() {
+	b.closeOnce.Do(func() {
+		close(b.closeChan)
+	})
+}
+
+// WaitForClose will block until either the writer is closed or a specified
+// timeout occurs.
+func (b *Benchmark) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-b.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,133 @@
+package writer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+)
+
+//------------------------------------------------------------------------------
+
+func TestBenchmarkBadConfig(t *testing.T) {
+	tests := map[string]func(*BenchmarkConfig){
+		"zero max in flight":        func(c *BenchmarkConfig) { c.MaxInFlight = 0 },
+		"bad latency":               func(c *BenchmarkConfig) { c.Latency = "nope" },
+		"bad summary interval":      func(c *BenchmarkConfig) { c.SummaryInterval = "nope" },
+		"negative summary interval": func(c *BenchmarkConfig) { c.SummaryInterval = "-1s" },
+	}
+	for name, mod := range tests {
+		conf := NewBenchmarkConfig()
+		mod(&conf)
+		if _, err := NewBenchmark(conf, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("%v: Expected error", name)
+		}
+	}
+}
+
+func TestBenchmarkMetrics(t *testing.T) {
+	conf := NewBenchmarkConfig()
+	conf.Latency = "10ms"
+
+	stats := metrics.NewLocal()
+	b, err := NewBenchmark(conf, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		b.CloseAsync()
+		if err := b.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for i := 0; i < 5; i++ {
+		if err = b.Write(message.New([][]byte{[]byte("foo"), []byte("barbaz")})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counters := stats.GetCounters()
+	if exp, act := int64(10), counters["benchmark.messages"]; exp != act {
+		t.Errorf("Wrong count of messages: %v != %v", act, exp)
+	}
+	if exp, act := int64(45), counters["benchmark.bytes"]; exp != act {
+		t.Errorf("Wrong count of bytes: %v != %v", act, exp)
+	}
+	if act := stats.GetTimings()["benchmark.latency"]; act < int64(10*time.Millisecond) {
+		t.Errorf("Expected latency of at least 10ms, got %v", time.Duration(act))
+	}
+
+	b.statsMut.Lock()
+	b.windowStart = time.Now().Add(-time.Second * 5)
+	b.statsMut.Unlock()
+	b.flushWindow()
+
+	counters = stats.GetCounters()
+	if exp, act := int64(2), counters["benchmark.messages_per_sec"]; exp != act {
+		t.Errorf("Wrong message rate: %v != %v", act, exp)
+	}
+	if exp, act := int64(9), counters["benchmark.bytes_per_sec"]; exp != act {
+		t.Errorf("Wrong byte rate: %v != %v", act, exp)
+	}
+	if b.windowMsgs != 0 || b.windowWrites != 0 {
+		t.Error("Expected window to be reset")
+	}
+}
+
+func TestBenchmarkLatencyFromCreation(t *testing.T) {
+	stats := metrics.NewLocal()
+	b, err := NewBenchmark(NewBenchmarkConfig(), log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		b.CloseAsync()
+		if err := b.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// The message waits before being written, as it would in a pipeline.
+	msg := message.New([][]byte{[]byte("foo")})
+	<-time.After(20 * time.Millisecond)
+	if err = b.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if act := stats.GetTimings()["benchmark.latency"]; act < int64(20*time.Millisecond) {
+		t.Errorf("Expected latency of at least 20ms, got %v", time.Duration(act))
+	}
+}
+
+func TestBenchmarkCloseDuringLatency(t *testing.T) {
+	conf := NewBenchmarkConfig()
+	conf.Latency = "1h"
+
+	b, err := NewBenchmark(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errChan := make(chan error)
+	go func() {
+		errChan <- b.Write(message.New([][]byte{[]byte("foo")}))
+	}()
+
+	b.CloseAsync()
+	select {
+	case err := <-errChan:
+		if err == nil {
+			t.Error("Expected error from write during close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for write to return")
+	}
+	if err = b.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Jeffail/benthos/v3/lib/cache"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
@@ -75,8 +76,15 @@ func (c *Cache) Connect() error {
 	return nil
 }
 
-// WriteWithContext attempts to write message contents to a target Cache.
+// WriteWithContext attempts to write message contents to a target Cache,
+// abandoning the write when the context is cancelled.
 func (c *Cache) WriteWithContext(ctx context.Context, msg types.Message) error {
+	if msg.Len() == 1 {
+		return cache.SetWithContext(ctx, c.cache, c.key.Get(msg), msg.Get(0).Get())
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return c.Write(msg)
 }
 
@@ -1,6 +1,10 @@
 package writer
 
 import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
@@ -10,31 +14,81 @@ import (
 
 //------------------------------------------------------------------------------
 
+// ErrDropSimulated is returned by the drop output when a write fails due to the
+// configured error rate.
+var ErrDropSimulated = errors.New("simulated write error")
+
 // DropConfig contains configuration fields for the drop output type.
-type DropConfig struct{}
+type DropConfig struct {
+	Latency       string  `json:"latency" yaml:"latency"`
+	LatencyJitter string  `json:"latency_jitter" yaml:"latency_jitter"`
+	ErrorRate     float64 `json:"error_rate" yaml:"error_rate"`
+}
 
 // NewDropConfig creates a new DropConfig with default values.
 func NewDropConfig() DropConfig {
-	return DropConfig{}
+	return DropConfig{
+		Latency:       "",
+		LatencyJitter: "",
+		ErrorRate:     0,
+	}
 }
 
 //------------------------------------------------------------------------------
 
 // Drop is a benthos writer.Type implementation that writes message parts to no
-// where.
+// where, optionally simulating the latency and errors of a real sink.
 type Drop struct {
 	log log.Modular
+
+	latency   time.Duration
+	jitter    time.Duration
+	errorRate float64
+
+	randMut sync.Mutex
+	rand    *rand.Rand
+
+	mErrSimulated metrics.StatCounter
+
+	closeOnce sync.Once
+	closeChan chan struct{}
 }
 
-// NewDrop creates a new file based writer.Type.
+// NewDrop creates a new drop based writer.Type and returns an error if the
+// config is invalid.
 func NewDrop(
 	conf DropConfig,
 	log log.Modular,
 	stats metrics.Type,
-) *Drop {
-	return &Drop{
-		log: log,
+) (*Drop, error) {
+	d := &Drop{
+		log:           log,
+		errorRate:     conf.ErrorRate,
+		rand:          rand.New(rand.NewSource(time.Now().UnixNano())),
+		mErrSimulated: stats.GetCounter("error.simulated"),
+		closeChan:     make(chan struct{}),
 	}
+	if d.errorRate < 0 || d.errorRate > 1 {
+		return nil, fmt.Errorf("error_rate must be between 0 and 1, got %v", d.errorRate)
+	}
+	var err error
+	if len(conf.Latency) > 0 {
+		if d.latency, err = time.ParseDuration(conf.Latency); err != nil {
+			return nil, fmt.Errorf("failed to parse latency duration string: %v", err)
+		}
+		if d.latency < 0 {
+			return nil, fmt.Errorf("latency must not be negative, got %v", conf.Latency)
+		}
+	}
+	if len(conf.LatencyJitter) > 0 {
+		if d.jitter, err = time.ParseDuration(conf.LatencyJitter); err != nil {
+			return nil, fmt.Errorf("failed to parse latency_jitter duration string: %v", err)
+		}
+		if d.jitter < 0 {
+			return nil, fmt.Errorf("latency_jitter must not be negative, got %v", conf.LatencyJitter)
+		}
+	}
+	return d, nil
 }
 
 // Connect is a noop.
@@ -43,13 +97,36 @@ func (d *Drop) Connect() error {
 	return nil
 }
 
-// Write does nothing.
+// Write waits for the configured latency and then either does nothing or
+// returns a simulated error according to the configured error rate.
 func (d *Drop) Write(msg types.Message) error {
+	d.randMut.Lock()
+	delay := d.latency
+	if d.jitter > 0 {
+		delay += time.Duration(d.rand.Int63n(int64(d.jitter)))
+	}
+	failed := d.errorRate > 0 && d.rand.Float64() < d.errorRate
+	d.randMut.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-d.closeChan:
+			return types.ErrTypeClosed
+		}
+	}
+	if failed {
+		d.mErrSimulated.Incr(1)
+		return ErrDropSimulated
+	}
 	return nil
 }
 
 // CloseAsync begins cleaning up resources used by this reader asynchronously.
 func (d *Drop) CloseAsync() {
+	d.closeOnce.Do(func() {
+		close(d.closeChan)
+	})
 }
 
 // WaitForClose will block until either the reader is closed or a specified
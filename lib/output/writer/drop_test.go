@@ -0,0 +1,87 @@
+package writer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func TestDropBadConfig(t *testing.T) {
+	tests := map[string]func(*DropConfig){
+		"negative error rate": func(c *DropConfig) { c.ErrorRate = -0.1 },
+		"large error rate":    func(c *DropConfig) { c.ErrorRate = 1.1 },
+		"bad latency":         func(c *DropConfig) { c.Latency = "nope" },
+		"bad jitter":          func(c *DropConfig) { c.LatencyJitter = "nope" },
+		"negative latency":    func(c *DropConfig) { c.Latency = "-1s" },
+		"negative jitter":     func(c *DropConfig) { c.LatencyJitter = "-1s" },
+	}
+	for name, mod := range tests {
+		conf := NewDropConfig()
+		mod(&conf)
+		if _, err := NewDrop(conf, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("%v: Expected error", name)
+		}
+	}
+}
+
+func TestDropErrorRate(t *testing.T) {
+	conf := NewDropConfig()
+	d, err := NewDrop(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err = d.Write(message.New([][]byte{[]byte("foo")})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf.ErrorRate = 1
+	if d, err = NewDrop(conf, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err = d.Write(message.New([][]byte{[]byte("foo")})); err != ErrDropSimulated {
+			t.Fatalf("Wrong error returned: %v != %v", err, ErrDropSimulated)
+		}
+	}
+}
+
+func TestDropLatency(t *testing.T) {
+	conf := NewDropConfig()
+	conf.Latency = "20ms"
+	conf.LatencyJitter = "10ms"
+
+	d, err := NewDrop(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t0 := time.Now()
+	if err = d.Write(message.New([][]byte{[]byte("foo")})); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(t0); elapsed < 20*time.Millisecond {
+		t.Errorf("Write returned too early: %v", elapsed)
+	}
+
+	conf.Latency = "1h"
+	if d, err = NewDrop(conf, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		<-time.After(10 * time.Millisecond)
+		d.CloseAsync()
+	}()
+	if err = d.Write(message.New([][]byte{[]byte("foo")})); err != types.ErrTypeClosed {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrTypeClosed)
+	}
+}
+
+//------------------------------------------------------------------------------
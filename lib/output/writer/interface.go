@@ -20,3 +20,11 @@ type Type interface {
 
 	types.Closable
 }
+
+// SentBatch describes the records written by a Type for a batch of messages,
+// which can differ from the batch itself when messages are dropped.
+type SentBatch struct {
+	Count   int
+	Bytes   int
+	Targets []string
+}
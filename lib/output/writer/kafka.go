@@ -1,12 +1,27 @@
 package writer
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
@@ -18,27 +33,73 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/util/retries"
 	"github.com/Jeffail/benthos/v3/lib/util/text"
 	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+	"github.com/Jeffail/gabs/v2"
 	"github.com/Shopify/sarama"
 	"github.com/cenkalti/backoff"
+	gometrics "github.com/rcrowley/go-metrics"
 )
 
 //------------------------------------------------------------------------------
 
 // KafkaConfig contains configuration fields for the Kafka output type.
 type KafkaConfig struct {
-	Addresses      []string    `json:"addresses" yaml:"addresses"`
-	ClientID       string      `json:"client_id" yaml:"client_id"`
-	Key            string      `json:"key" yaml:"key"`
-	Partitioner    string      `json:"partitioner" yaml:"partitioner"`
-	Topic          string      `json:"topic" yaml:"topic"`
-	Compression    string      `json:"compression" yaml:"compression"`
-	MaxMsgBytes    int         `json:"max_msg_bytes" yaml:"max_msg_bytes"`
-	Timeout        string      `json:"timeout" yaml:"timeout"`
-	AckReplicas    bool        `json:"ack_replicas" yaml:"ack_replicas"`
-	TargetVersion  string      `json:"target_version" yaml:"target_version"`
-	TLS            btls.Config `json:"tls" yaml:"tls"`
-	SASL           sasl.Config `json:"sasl" yaml:"sasl"`
-	MaxInFlight    int         `json:"max_in_flight" yaml:"max_in_flight"`
+	Addresses     []string          `json:"addresses" yaml:"addresses"`
+	ClientID      string            `json:"client_id" yaml:"client_id"`
+	Key           string            `json:"key" yaml:"key"`
+	KeyFromField  string            `json:"key_from_field" yaml:"key_from_field"`
+	Partitioner   string            `json:"partitioner" yaml:"partitioner"`
+	Partition     string            `json:"partition" yaml:"partition"`
+	PartitionExpr string            `json:"partition_expression" yaml:"partition_expression"`
+	Topic         string            `json:"topic" yaml:"topic"`
+	Headers       map[string]string `json:"headers" yaml:"headers"`
+	Compression   string            `json:"compression" yaml:"compression"`
+	MaxMsgBytes   int               `json:"max_msg_bytes" yaml:"max_msg_bytes"`
+	MaxBatchBytes int               `json:"max_batch_bytes" yaml:"max_batch_bytes"`
+	Timeout       string            `json:"timeout" yaml:"timeout"`
+	AckReplicas   bool              `json:"ack_replicas" yaml:"ack_replicas"`
+	TargetVersion string            `json:"target_version" yaml:"target_version"`
+	TLS           btls.Config       `json:"tls" yaml:"tls"`
+	SASL          sasl.Config       `json:"sasl" yaml:"sasl"`
+	MaxInFlight   int               `json:"max_in_flight" yaml:"max_in_flight"`
+
+	KeyFromMetadata string `json:"key_from_metadata" yaml:"key_from_metadata"`
+	KeyFromContent  bool   `json:"key_from_content" yaml:"key_from_content"`
+
+	Timestamp string `json:"timestamp" yaml:"timestamp"`
+
+	PartitionerReplicas int `json:"partitioner_replicas" yaml:"partitioner_replicas"`
+
+	CreateTopics                  bool  `json:"create_topics" yaml:"create_topics"`
+	CreateTopicsPartitions        int32 `json:"create_topics_partitions" yaml:"create_topics_partitions"`
+	CreateTopicsReplicationFactor int16 `json:"create_topics_replication_factor" yaml:"create_topics_replication_factor"`
+
+	ProvenanceHeaders bool   `json:"provenance_headers" yaml:"provenance_headers"`
+	PipelineName      string `json:"pipeline_name" yaml:"pipeline_name"`
+
+	CompressionLevel   int  `json:"compression_level" yaml:"compression_level"`
+	CompressionMetrics bool `json:"compression_metrics" yaml:"compression_metrics"`
+
+	IdempotentWrite bool `json:"idempotent_write" yaml:"idempotent_write"`
+
+	OnInterpolationError string `json:"on_interpolation_error" yaml:"on_interpolation_error"`
+
+	ValidateUTF8 bool   `json:"validate_utf8" yaml:"validate_utf8"`
+	OnInvalid    string `json:"on_invalid" yaml:"on_invalid"`
+
+	AuditLog       bool `json:"audit_log" yaml:"audit_log"`
+	OffsetMetadata bool `json:"offset_metadata" yaml:"offset_metadata"`
+
+	ValidateOnStart bool `json:"validate_on_start" yaml:"validate_on_start"`
+
+	ShareConnection bool `json:"share_connection" yaml:"share_connection"`
+
+	PreserveOrder bool `json:"preserve_order" yaml:"preserve_order"`
+
+	RequiredAcks string `json:"required_acks" yaml:"required_acks"`
+
+	BatchPerTopic   bool `json:"batch_per_topic" yaml:"batch_per_topic"`
+	MaxTopicBatches int  `json:"max_topic_batches" yaml:"max_topic_batches"`
+
 	retries.Config `json:",inline" yaml:",inline"`
 	Batching       batch.PolicyConfig `json:"batching" yaml:"batching"`
 
@@ -58,19 +119,63 @@ func NewKafkaConfig() KafkaConfig {
 		Addresses:            []string{"localhost:9092"},
 		ClientID:             "benthos_kafka_output",
 		Key:                  "",
+		KeyFromField:         "",
 		RoundRobinPartitions: false,
 		Partitioner:          "fnv1a_hash",
+		Partition:            "",
+		PartitionExpr:        "",
 		Topic:                "benthos_stream",
+		Headers:              map[string]string{},
 		Compression:          "none",
 		MaxMsgBytes:          1000000,
+		MaxBatchBytes:        0,
 		Timeout:              "5s",
 		AckReplicas:          false,
 		TargetVersion:        sarama.V1_0_0_0.String(),
 		TLS:                  btls.NewConfig(),
 		SASL:                 sasl.NewConfig(),
 		MaxInFlight:          1,
-		Config:               rConf,
-		Batching:             batching,
+
+		KeyFromMetadata: "",
+		KeyFromContent:  false,
+
+		Timestamp: "",
+
+		PartitionerReplicas: consistentHashDefaultReplicas,
+
+		CreateTopics:                  false,
+		CreateTopicsPartitions:        1,
+		CreateTopicsReplicationFactor: 1,
+
+		ProvenanceHeaders: false,
+		PipelineName:      "",
+
+		CompressionLevel:   -1,
+		CompressionMetrics: false,
+
+		IdempotentWrite: false,
+
+		OnInterpolationError: "fallback",
+
+		ValidateUTF8: false,
+		OnInvalid:    "error",
+
+		AuditLog:       false,
+		OffsetMetadata: false,
+
+		ValidateOnStart: false,
+
+		ShareConnection: false,
+
+		PreserveOrder: false,
+
+		RequiredAcks: "local",
+
+		BatchPerTopic:   false,
+		MaxTopicBatches: 64,
+
+		Config:   rConf,
+		Batching: batching,
 	}
 }
 
@@ -82,7 +187,9 @@ type Kafka struct {
 	mgr   types.Manager
 	stats metrics.Type
 
-	backoff backoff.BackOff
+	backoffCtor  func() backoff.BackOff
+	connBackoff  backoff.BackOff
+	reconnecting bool
 
 	tlsConf *tls.Config
 	timeout time.Duration
@@ -91,16 +198,69 @@ type Kafka struct {
 	version   sarama.KafkaVersion
 	conf      KafkaConfig
 
-	mDroppedMaxBytes metrics.StatCounter
+	mDroppedMaxBytes   metrics.StatCounter
+	mErrInterp         metrics.StatCounter
+	mDroppedInterp     metrics.StatCounter
+	mErrInvalid        metrics.StatCounter
+	mDroppedInvalid    metrics.StatCounter
+	mBytesUncompressed metrics.StatCounterVec
+	mBytesSent         metrics.StatCounterVec
+
+	mBatchSent  metrics.StatCounter
+	mBatchBytes metrics.StatCounter
+	mSendErr    metrics.StatCounter
+	mSendRetry  metrics.StatCounter
+	mAckLatency metrics.StatTimer
+
+	saramaMetrics gometrics.Registry
+
+	key       *text.InterpolatedBytes
+	topic     *text.InterpolatedString
+	partition *text.InterpolatedString
+	timestamp *text.InterpolatedString
+
+	partitionExpr *text.InterpolatedString
+
+	headerKeys []string
+	headers    map[string]*text.InterpolatedString
 
-	key   *text.InterpolatedBytes
-	topic *text.InterpolatedString
+	// When none of the interpolated fields contain functions their values are
+	// resolved once and message parts are not locked for each send.
+	static      bool
+	staticKey   []byte
+	staticTopic string
 
-	producer    sarama.SyncProducer
-	compression sarama.CompressionCodec
-	partitioner sarama.PartitionerConstructor
+	// When the connection is shared the producer is obtained from the manager
+	// under sharedKey and released rather than closed.
+	shared    sharedProvider
+	sharedKey string
+
+	producer     sarama.SyncProducer
+	admin        sarama.ClusterAdmin
+	compression  sarama.CompressionCodec
+	partitioner  sarama.PartitionerConstructor
+	requiredAcks sarama.RequiredAcks
+
+	topicsMut     sync.Mutex
+	createdTopics map[string]struct{}
+
+	hostname string
+
+	stickySeq uint64
+
+	// Writes in flight are tracked so that shutdown, and reconnecting after an
+	// authentication failure, can wait for them to complete before closing the
+	// producer.
+	inFlight   sync.WaitGroup
+	authFailed int32
+	closeChan  chan struct{}
+	closedChan chan struct{}
 
 	connMut sync.RWMutex
+
+	// Serialises calls to Connect, which releases connMut whilst waiting for
+	// writes to drain after an authentication failure.
+	connectMut sync.Mutex
 }
 
 // NewKafka creates a new Kafka writer type.
@@ -110,26 +270,106 @@ func NewKafka(conf KafkaConfig, mgr types.Manager, log log.Modular, stats metric
 		return nil, err
 	}
 
+	if err = conf.SASL.Validate(); err != nil {
+		return nil, err
+	}
+
 	// for backward compatitility
 	if conf.RoundRobinPartitions {
 		conf.Partitioner = "round_robin"
 		log.Warnln("The field 'round_robin_partitions' is deprecated, please use the 'partitioner' field (set to 'round_robin') instead.")
 	}
+	if conf.AckReplicas {
+		if conf.RequiredAcks != "none" {
+			conf.RequiredAcks = "all"
+		}
+		log.Warnln("The field 'ack_replicas' is deprecated, please use the 'required_acks' field (set to 'all') instead.")
+	}
+	requiredAcks, err := strToRequiredAcks(conf.RequiredAcks)
+	if err != nil {
+		return nil, err
+	}
 	partitioner, err := strToPartitioner(conf.Partitioner)
 	if err != nil {
 		return nil, err
 	}
+	if conf.Partitioner == "consistent_hash" {
+		if conf.PartitionerReplicas < 1 {
+			return nil, errors.New("partitioner_replicas must be at least 1")
+		}
+		partitioner = newConsistentHashPartitionerCtor(conf.PartitionerReplicas)
+	}
+	if len(conf.Partition) > 0 {
+		partitioner = sarama.NewManualPartitioner
+	}
 
+	kStats := metrics.Namespaced(stats, "kafka")
 	k := Kafka{
 		log:   log,
 		mgr:   mgr,
 		stats: stats,
 
-		conf:        conf,
-		key:         text.NewInterpolatedBytes([]byte(conf.Key)),
-		topic:       text.NewInterpolatedString(conf.Topic),
-		compression: compression,
-		partitioner: partitioner,
+		conf:         conf,
+		key:          text.NewInterpolatedBytes([]byte(conf.Key)),
+		topic:        text.NewInterpolatedString(conf.Topic),
+		compression:  compression,
+		partitioner:  partitioner,
+		requiredAcks: requiredAcks,
+
+		headers:       map[string]*text.InterpolatedString{},
+		createdTopics: map[string]struct{}{},
+
+		closeChan:  make(chan struct{}),
+		closedChan: make(chan struct{}),
+
+		mDroppedMaxBytes: stats.GetCounter("send.dropped.max_msg_bytes"),
+		mErrInterp:       stats.GetCounter("send.error.interpolation"),
+		mDroppedInterp:   stats.GetCounter("send.dropped.interpolation"),
+		mErrInvalid:      stats.GetCounter("send.error.invalid_utf8"),
+		mDroppedInvalid:  stats.GetCounter("send.dropped.invalid_utf8"),
+
+		mBatchSent:  kStats.GetCounter("batch.sent"),
+		mBatchBytes: kStats.GetCounter("batch.bytes"),
+		mSendErr:    kStats.GetCounter("send.error"),
+		mSendRetry:  kStats.GetCounter("send.retry"),
+		mAckLatency: kStats.GetTimer("ack.latency"),
+	}
+	keySources := 0
+	for _, set := range []bool{len(conf.KeyFromField) > 0, len(conf.KeyFromMetadata) > 0, conf.KeyFromContent} {
+		if set {
+			keySources++
+		}
+	}
+	if keySources > 1 {
+		return nil, errors.New("only one of key_from_field, key_from_metadata and key_from_content can be set")
+	}
+
+	switch conf.OnInterpolationError {
+	case "error", "drop", "fallback":
+	default:
+		return nil, fmt.Errorf("on_interpolation_error policy not recognised: %v", conf.OnInterpolationError)
+	}
+	switch conf.OnInvalid {
+	case "error", "drop", "sanitize":
+	default:
+		return nil, fmt.Errorf("on_invalid policy not recognised: %v", conf.OnInvalid)
+	}
+	if len(conf.Partition) > 0 {
+		k.partition = text.NewInterpolatedString(conf.Partition)
+	}
+	if len(conf.Timestamp) > 0 {
+		k.timestamp = text.NewInterpolatedString(conf.Timestamp)
+	}
+	if conf.Partitioner == "expression" {
+		if len(conf.PartitionExpr) == 0 {
+			return nil, errors.New("partition_expression must be set when the partitioner is expression")
+		}
+		if len(conf.Partition) > 0 {
+			return nil, errors.New("partition cannot be set when the partitioner is expression")
+		}
+		k.partitionExpr = text.NewInterpolatedString(conf.PartitionExpr)
+	} else if len(conf.PartitionExpr) > 0 {
+		return nil, errors.New("partition_expression requires the partitioner to be expression")
 	}
 
 	if tout := conf.Timeout; len(tout) > 0 {
@@ -150,20 +390,217 @@ func NewKafka(conf KafkaConfig, mgr types.Manager, log log.Modular, stats metric
 		return nil, err
 	}
 
-	for _, addr := range conf.Addresses {
-		for _, splitAddr := range strings.Split(addr, ",") {
-			if trimmed := strings.TrimSpace(splitAddr); len(trimmed) > 0 {
-				k.addresses = append(k.addresses, trimmed)
-			}
+	if compression == sarama.CompressionZSTD && !k.version.IsAtLeast(sarama.V2_1_0_0) {
+		return nil, fmt.Errorf("zstd compression requires a target_version of at least %v", sarama.V2_1_0_0)
+	}
+	if conf.CompressionLevel != -1 {
+		// The zstd codec of the client does not currently support levels.
+		if compression != sarama.CompressionGZIP {
+			return nil, fmt.Errorf("compression_level is not supported by compression codec: %v", conf.Compression)
+		}
+		if _, err = gzip.NewWriterLevel(ioutil.Discard, conf.CompressionLevel); err != nil {
+			return nil, fmt.Errorf("invalid compression_level: %v", err)
 		}
 	}
 
-	if k.backoff, err = conf.Config.Get(); err != nil {
+	if conf.MaxBatchBytes < 0 {
+		return nil, errors.New("max_batch_bytes must not be negative")
+	}
+
+	if err = validateKafkaAcks(conf, k.version); err != nil {
 		return nil, err
 	}
+
+	if conf.BatchPerTopic {
+		if len(conf.Batching.GroupBy) > 0 {
+			return nil, errors.New("batch_per_topic cannot be combined with batching.group_by")
+		}
+		if conf.MaxTopicBatches < 1 {
+			return nil, errors.New("max_topic_batches must be at least 1")
+		}
+	}
+
+	if conf.CreateTopics {
+		if !k.version.IsAtLeast(sarama.V0_10_1_0) {
+			return nil, fmt.Errorf("create_topics requires a target_version of at least %v", sarama.V0_10_1_0)
+		}
+		if conf.CreateTopicsPartitions < 1 {
+			return nil, errors.New("create_topics_partitions must be at least 1")
+		}
+		if conf.CreateTopicsReplicationFactor < 1 {
+			return nil, errors.New("create_topics_replication_factor must be at least 1")
+		}
+	}
+
+	if conf.CompressionMetrics {
+		k.saramaMetrics = gometrics.NewRegistry()
+		k.mBytesUncompressed = stats.GetCounterVec("bytes_uncompressed", []string{"topic"})
+		k.mBytesSent = stats.GetCounterVec("bytes_sent", []string{"topic"})
+	}
+
+	if len(conf.Headers) > 0 {
+		if !k.version.IsAtLeast(sarama.V0_11_0_0) {
+			return nil, fmt.Errorf("headers require a target_version of at least %v", sarama.V0_11_0_0)
+		}
+		for name, value := range conf.Headers {
+			k.headerKeys = append(k.headerKeys, name)
+			k.headers[name] = text.NewInterpolatedString(value)
+		}
+		sort.Strings(k.headerKeys)
+	}
+
+	if k.timestamp != nil && !k.version.IsAtLeast(sarama.V0_10_0_0) {
+		return nil, fmt.Errorf("timestamp requires a target_version of at least %v", sarama.V0_10_0_0)
+	}
+
+	if conf.ProvenanceHeaders {
+		if !k.version.IsAtLeast(sarama.V0_11_0_0) {
+			return nil, fmt.Errorf("provenance_headers requires a target_version of at least %v", sarama.V0_11_0_0)
+		}
+		if k.hostname, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("failed to obtain hostname for provenance headers: %v", err)
+		}
+	}
+
+	k.static = !text.ContainsFunctionVariables([]byte(conf.Key)) &&
+		!text.ContainsFunctionVariables([]byte(conf.Topic)) &&
+		!text.ContainsFunctionVariables([]byte(conf.Partition)) &&
+		!text.ContainsFunctionVariables([]byte(conf.PartitionExpr)) &&
+		!text.ContainsFunctionVariables([]byte(conf.Timestamp))
+	for _, value := range conf.Headers {
+		if text.ContainsFunctionVariables([]byte(value)) {
+			k.static = false
+		}
+	}
+	if k.static {
+		k.staticKey = k.key.Get(nil)
+		k.staticTopic = k.topic.Get(nil)
+	}
+
+	if k.addresses, err = parseAddresses(conf.Addresses); err != nil {
+		return nil, err
+	}
+
+	if k.backoffCtor, err = conf.Config.GetCtor(); err != nil {
+		return nil, err
+	}
+	connBackoffCtor, err := conf.Config.GetFullJitterCtor()
+	if err != nil {
+		return nil, err
+	}
+	k.connBackoff = connBackoffCtor()
+
+	// Start rotating sticky partitions from a random point so that instances
+	// do not begin on the same partition.
+	k.stickySeq = rand.Uint64()
+
+	if conf.ShareConnection {
+		if conf.CompressionMetrics {
+			return nil, errors.New("compression_metrics cannot be used with share_connection")
+		}
+		var ok bool
+		if k.shared, ok = mgr.(sharedProvider); !ok {
+			return nil, errors.New("manager does not support shared connections")
+		}
+		if k.sharedKey, err = k.connectionKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.ValidateOnStart {
+		if err = k.validateOnStart(); err != nil {
+			return nil, err
+		}
+	}
 	return &k, nil
 }
 
+// sharedProvider is implemented by managers that are able to share resources
+// between components.
+// TODO: V4 Add this to types.Manager
+type sharedProvider interface {
+	AcquireShared(key string, ctor func() (io.Closer, error)) (io.Closer, error)
+	ReleaseShared(key string, res io.Closer) error
+	InvalidateShared(key string, res io.Closer) error
+}
+
+// connectionKey returns a key that is identical for writers that would create
+// identical producers. Producer settings are part of the key as well as the
+// connection settings since sarama derives them from the config of the client.
+func (k *Kafka) connectionKey() (string, error) {
+	b, err := json.Marshal(struct {
+		Addresses           []string
+		ClientID            string
+		TargetVersion       string
+		TLS                 btls.Config
+		SASL                sasl.Config
+		Compression         string
+		CompressionLevel    int
+		Partitioner         string
+		PartitionerReplicas int
+		ManualPartition     bool
+		MaxMsgBytes         int
+		Timeout             string
+		RequiredAcks        string
+		IdempotentWrite     bool
+	}{
+		Addresses:           k.addresses,
+		ClientID:            k.conf.ClientID,
+		TargetVersion:       k.version.String(),
+		TLS:                 k.conf.TLS,
+		SASL:                k.conf.SASL,
+		Compression:         k.conf.Compression,
+		CompressionLevel:    k.conf.CompressionLevel,
+		Partitioner:         k.conf.Partitioner,
+		PartitionerReplicas: k.conf.PartitionerReplicas,
+		ManualPartition:     len(k.conf.Partition) > 0,
+		MaxMsgBytes:         k.conf.MaxMsgBytes,
+		Timeout:             k.timeout.String(),
+		RequiredAcks:        k.conf.RequiredAcks,
+		IdempotentWrite:     k.conf.IdempotentWrite,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create shared connection key: %v", err)
+	}
+	return "kafka_output:" + string(b), nil
+}
+
+//------------------------------------------------------------------------------
+
+// parseAddresses expands comma separated broker addresses, trimming whitespace
+// around each, and returns an error listing any that are not of the form
+// host:port.
+func parseAddresses(addresses []string) ([]string, error) {
+	var parsed, bad []string
+	for _, addr := range addresses {
+		for _, splitAddr := range strings.Split(addr, ",") {
+			trimmed := strings.TrimSpace(splitAddr)
+			if len(trimmed) == 0 {
+				continue
+			}
+			host, port, err := net.SplitHostPort(trimmed)
+			if err == nil {
+				var portNum uint64
+				if portNum, err = strconv.ParseUint(port, 10, 16); err == nil && (len(host) == 0 || portNum == 0) {
+					err = errors.New("missing host or port")
+				}
+			}
+			if err != nil {
+				bad = append(bad, fmt.Sprintf("'%v'", trimmed))
+				continue
+			}
+			parsed = append(parsed, trimmed)
+		}
+	}
+	if len(bad) > 0 {
+		return nil, fmt.Errorf("addresses must be of the form host:port, invalid addresses: %v", strings.Join(bad, ", "))
+	}
+	if len(parsed) == 0 {
+		return nil, errors.New("at least one address must be specified")
+	}
+	return parsed, nil
+}
+
 //------------------------------------------------------------------------------
 
 func strToCompressionCodec(str string) (sarama.CompressionCodec, error) {
@@ -176,12 +613,28 @@ func strToCompressionCodec(str string) (sarama.CompressionCodec, error) {
 		return sarama.CompressionLZ4, nil
 	case "gzip":
 		return sarama.CompressionGZIP, nil
+	case "zstd":
+		return sarama.CompressionZSTD, nil
 	}
 	return sarama.CompressionNone, fmt.Errorf("compression codec not recognised: %v", str)
 }
 
 //------------------------------------------------------------------------------
 
+func strToRequiredAcks(str string) (sarama.RequiredAcks, error) {
+	switch str {
+	case "none":
+		return sarama.NoResponse, nil
+	case "local":
+		return sarama.WaitForLocal, nil
+	case "all":
+		return sarama.WaitForAll, nil
+	}
+	return sarama.WaitForLocal, fmt.Errorf("required_acks not recognised: %v", str)
+}
+
+//------------------------------------------------------------------------------
+
 func strToPartitioner(str string) (sarama.PartitionerConstructor, error) {
 	switch str {
 	case "fnv1a_hash":
@@ -195,11 +648,114 @@ func strToPartitioner(str string) (sarama.PartitionerConstructor, error) {
 		return sarama.NewRandomPartitioner, nil
 	case "round_robin":
 		return sarama.NewRoundRobinPartitioner, nil
+	case "sticky":
+		return newStickyPartitioner, nil
+	case "expression":
+		// Partitions are resolved by the partition_expression of each message.
+		return sarama.NewManualPartitioner, nil
+	case "consistent_hash":
+		return newConsistentHashPartitionerCtor(consistentHashDefaultReplicas), nil
 	default:
 	}
 	return nil, fmt.Errorf("partitioner not recognised: %v", str)
 }
 
+// stickyPartitioner assigns records without a key to a partition chosen by the
+// sequence number of the batch that they were sent in, which is carried in the
+// metadata of the record, and records with a key by the fnv1a hash of the key.
+type stickyPartitioner struct {
+	hash sarama.Partitioner
+}
+
+func newStickyPartitioner(topic string) sarama.Partitioner {
+	return &stickyPartitioner{
+		hash: sarama.NewHashPartitioner(topic),
+	}
+}
+
+func (s *stickyPartitioner) Partition(msg *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	if seq, ok := msg.Metadata.(uint64); ok && msg.Key == nil {
+		return int32(seq % uint64(numPartitions)), nil
+	}
+	return s.hash.Partition(msg, numPartitions)
+}
+
+func (s *stickyPartitioner) RequiresConsistency() bool {
+	return true
+}
+
+const consistentHashDefaultReplicas = 100
+
+// consistentHashPartitioner assigns records with a key to partitions by placing
+// a number of virtual nodes (replicas) of each partition on a hash ring and
+// choosing the first node that follows the murmur2 hash of the key. When the
+// number of partitions grows only the keys claimed by the nodes of the new
+// partitions are reassigned. Records without a key are assigned randomly.
+type consistentHashPartitioner struct {
+	replicas int
+	random   sarama.Partitioner
+
+	numPartitions int32
+	ring          []uint32
+	owners        map[uint32]int32
+}
+
+func newConsistentHashPartitionerCtor(replicas int) sarama.PartitionerConstructor {
+	return func(topic string) sarama.Partitioner {
+		return &consistentHashPartitioner{
+			replicas: replicas,
+			random:   sarama.NewRandomPartitioner(topic),
+		}
+	}
+}
+
+func consistentHash(b []byte) uint32 {
+	h := murmur2.New32()
+	h.Write(b)
+	return h.Sum32()
+}
+
+// build places the virtual nodes of each partition on the ring.
+func (c *consistentHashPartitioner) build(numPartitions int32) {
+	c.numPartitions = numPartitions
+	c.ring = make([]uint32, 0, int(numPartitions)*c.replicas)
+	c.owners = make(map[uint32]int32, int(numPartitions)*c.replicas)
+	for p := int32(0); p < numPartitions; p++ {
+		for r := 0; r < c.replicas; r++ {
+			h := consistentHash([]byte(strconv.Itoa(int(p)) + "-" + strconv.Itoa(r)))
+			if _, exists := c.owners[h]; exists {
+				continue
+			}
+			c.owners[h] = p
+			c.ring = append(c.ring, h)
+		}
+	}
+	sort.Slice(c.ring, func(i, j int) bool { return c.ring[i] < c.ring[j] })
+}
+
+func (c *consistentHashPartitioner) Partition(msg *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	if msg.Key == nil {
+		return c.random.Partition(msg, numPartitions)
+	}
+	key, err := msg.Key.Encode()
+	if err != nil {
+		return -1, err
+	}
+	if c.numPartitions != numPartitions {
+		c.build(numPartitions)
+	}
+	h := consistentHash(key)
+	i := sort.Search(len(c.ring), func(i int) bool { return c.ring[i] >= h })
+	if i == len(c.ring) {
+		i = 0
+	}
+	return c.owners[c.ring[i]], nil
+}
+
+func (c *consistentHashPartitioner) RequiresConsistency() bool {
+	return true
+}
+
 //------------------------------------------------------------------------------
 
 func buildHeaders(version sarama.KafkaVersion, part types.Part) []sarama.RecordHeader {
@@ -220,54 +776,524 @@ func buildHeaders(version sarama.KafkaVersion, part types.Part) []sarama.RecordH
 	return nil
 }
 
+// provenanceHeaders returns the headers that identify the origin of a record,
+// which are always the same for a batch of messages sent together.
+func (k *Kafka) provenanceHeaders(producedAt time.Time) []sarama.RecordHeader {
+	return []sarama.RecordHeader{
+		{Key: []byte("benthos_instance"), Value: []byte(k.hostname)},
+		{Key: []byte("benthos_pipeline"), Value: []byte(k.conf.PipelineName)},
+		{Key: []byte("benthos_produced_at"), Value: []byte(producedAt.Format(time.RFC3339Nano))},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// resolveKey returns the key of a message part. When key_from_field is set the
+// key is the scalar value of that JSON field, and the key interpolation is used
+// as a fallback when the field is absent. An error from the key interpolation
+// is returned as interpErr along with the fallback value of the key.
+func (k *Kafka) resolveKey(lMsg types.Message, p types.Part) (key []byte, interpErr, err error) {
+	if k.conf.KeyFromContent {
+		return p.Get(), nil, nil
+	}
+	if len(k.conf.KeyFromMetadata) > 0 {
+		if v := p.Metadata().Get(k.conf.KeyFromMetadata); len(v) > 0 {
+			return []byte(v), nil, nil
+		}
+	}
+	if len(k.conf.KeyFromField) > 0 {
+		if jObj, err := p.JSON(); err == nil {
+			switch t := gabs.Wrap(jObj).Path(k.conf.KeyFromField).Data().(type) {
+			case nil:
+			case string:
+				return []byte(t), nil, nil
+			case float64, bool:
+				return []byte(gabs.Wrap(t).String()), nil, nil
+			default:
+				return nil, nil, fmt.Errorf("key_from_field '%v' resolved to a non-scalar value", k.conf.KeyFromField)
+			}
+		}
+	}
+	if k.static {
+		return k.staticKey, nil, nil
+	}
+	key, interpErr = k.key.GetChecked(lMsg)
+	return key, interpErr, nil
+}
+
+// resolveTarget returns the key and topic of a message part, and whether the
+// part should be sent at all according to the on_interpolation_error policy.
+// When the policy is error the interpolation error is returned as interpErr.
+func (k *Kafka) resolveTarget(lMsg types.Message, p types.Part) (key []byte, topic string, send bool, interpErr, err error) {
+	if key, interpErr, err = k.resolveKey(lMsg, p); err != nil {
+		return nil, "", false, nil, err
+	}
+	if k.static {
+		return key, k.staticTopic, true, nil, nil
+	}
+	var topicErr error
+	if topic, topicErr = k.topic.GetChecked(lMsg); interpErr == nil {
+		interpErr = topicErr
+	}
+	if interpErr == nil {
+		return key, topic, true, nil, nil
+	}
+
+	k.mErrInterp.Incr(1)
+	switch k.conf.OnInterpolationError {
+	case "error":
+		k.log.Errorf("Failed to resolve key or topic of message: %v\n", interpErr)
+		return nil, "", false, interpErr, nil
+	case "drop":
+		k.mDroppedInterp.Incr(1)
+		k.log.Warnf("Dropping message due to key or topic interpolation error: %v\n", interpErr)
+		return nil, "", false, nil, nil
+	}
+	k.log.Warnf("Sending message with fallback key and topic due to interpolation error: %v\n", interpErr)
+	return key, topic, true, nil, nil
+}
+
+// parseTimestamp parses the timestamp of a record from either an RFC 3339
+// string or an integer of milliseconds since the unix epoch.
+func parseTimestamp(str string) (time.Time, error) {
+	str = strings.TrimSpace(str)
+	if millis, err := strconv.ParseInt(str, 10, 64); err == nil {
+		return time.Unix(0, millis*int64(time.Millisecond)), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, str)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp '%v' is neither an RFC 3339 time nor unix milliseconds", str)
+	}
+	return t, nil
+}
+
+// validUTF8 returns true if a byte slice is valid UTF-8 without control
+// characters.
+func validUTF8(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	return bytes.IndexFunc(b, unicode.IsControl) == -1
+}
+
+// sanitizeUTF8 removes invalid UTF-8 sequences and control characters from a
+// byte slice.
+func sanitizeUTF8(b []byte) []byte {
+	return bytes.Map(func(r rune) rune {
+		if r == utf8.RuneError || unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, b)
+}
+
+// validateTarget checks that the key and topic of a message part are valid
+// UTF-8 without control characters, and returns whether the part should be sent
+// according to the on_invalid policy. When the policy is error the validation
+// error is returned as invalidErr.
+func (k *Kafka) validateTarget(key []byte, topic string) (vKey []byte, vTopic string, send bool, invalidErr error) {
+	if validUTF8(key) && validUTF8([]byte(topic)) {
+		return key, topic, true, nil
+	}
+
+	k.mErrInvalid.Incr(1)
+	switch k.conf.OnInvalid {
+	case "drop":
+		k.mDroppedInvalid.Incr(1)
+		k.log.Warnln("Dropping message due to a key or topic that is not valid UTF-8")
+		return nil, "", false, nil
+	case "sanitize":
+		if vTopic = string(sanitizeUTF8([]byte(topic))); len(vTopic) > 0 {
+			return sanitizeUTF8(key), vTopic, true, nil
+		}
+	}
+	k.log.Errorln("Key or topic of message is not valid UTF-8")
+	return nil, "", false, errors.New("key or topic is not valid UTF-8")
+}
+
+// topicError returns a non-retriable error for a producer error caused by a
+// topic that the client is not authorized to write to or that does not exist,
+// or nil for any other error.
+func topicError(pErr *sarama.ProducerError) error {
+	var sentinel error
+	switch pErr.Err {
+	case sarama.ErrTopicAuthorizationFailed:
+		sentinel = types.ErrTopicAuthorization
+	case sarama.ErrUnknownTopicOrPartition:
+		sentinel = types.ErrTopicNotFound
+	default:
+		return nil
+	}
+	return types.NonRetriableError{
+		Err: fmt.Errorf("%w '%v': %v", sentinel, pErr.Msg.Topic, pErr.Err),
+	}
+}
+
+// producerBatchError creates a batch error from sarama producer errors that
+// records which parts of the batch failed to send.
+func producerBatchError(msg types.Message, indexes map[*sarama.ProducerMessage]int, pErrs sarama.ProducerErrors) error {
+	var err error = pErrs
+	nonRetriable := true
+	for _, pErr := range pErrs {
+		if !types.IsNonRetriable(pErr.Err) {
+			nonRetriable = false
+			break
+		}
+	}
+	if nonRetriable {
+		err = types.NonRetriableError{Err: pErrs}
+	}
+
+	bErr := batch.NewError(msg, err)
+	for _, pErr := range pErrs {
+		if i, exists := indexes[pErr.Msg]; exists {
+			bErr.Failed(i, pErr.Err)
+		}
+	}
+	return bErr
+}
+
+// messageSize returns the size in bytes of the key, value and headers of a
+// record.
+func messageSize(m *sarama.ProducerMessage) int {
+	size := 0
+	if m.Key != nil {
+		size += m.Key.Length()
+	}
+	if m.Value != nil {
+		size += m.Value.Length()
+	}
+	for _, h := range m.Headers {
+		size += len(h.Key) + len(h.Value)
+	}
+	return size
+}
+
+// splitBatch splits a batch of records into chunks where the total size of the
+// records of each chunk does not exceed maxBytes, preserving their order. A
+// record that exceeds maxBytes by itself is placed in its own chunk. A maxBytes
+// of zero or less results in a single chunk.
+func splitBatch(msgs []*sarama.ProducerMessage, maxBytes int) [][]*sarama.ProducerMessage {
+	if maxBytes <= 0 {
+		return [][]*sarama.ProducerMessage{msgs}
+	}
+	var chunks [][]*sarama.ProducerMessage
+	var chunk []*sarama.ProducerMessage
+	chunkBytes := 0
+	for _, m := range msgs {
+		size := messageSize(m)
+		if len(chunk) > 0 && chunkBytes+size > maxBytes {
+			chunks = append(chunks, chunk)
+			chunk, chunkBytes = nil, 0
+		}
+		chunk = append(chunk, m)
+		chunkBytes += size
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// sendMessages sends a batch of records with a producer, split into multiple
+// sends when the batch exceeds max_batch_bytes. The errors of records that fail
+// to send are combined into a single sarama.ProducerErrors so that they can be
+// retried or rejected individually.
+func (k *Kafka) sendMessages(producer sarama.SyncProducer, msgs []*sarama.ProducerMessage) error {
+	chunks := splitBatch(msgs, k.conf.MaxBatchBytes)
+	if len(chunks) == 1 {
+		return k.sendChunk(producer, chunks[0])
+	}
+
+	var pErrs sarama.ProducerErrors
+	for i, chunk := range chunks {
+		err := k.sendChunk(producer, chunk)
+		if err == nil {
+			continue
+		}
+		cErrs, ok := err.(sarama.ProducerErrors)
+		if !ok {
+			// The records of this and all remaining chunks were not sent.
+			for _, c := range chunks[i:] {
+				for _, m := range c {
+					pErrs = append(pErrs, &sarama.ProducerError{Msg: m, Err: err})
+				}
+			}
+			break
+		}
+		pErrs = append(pErrs, cErrs...)
+	}
+	if len(pErrs) > 0 {
+		return pErrs
+	}
+	return nil
+}
+
+// sendChunk sends records with a producer and records the latency of the
+// broker acknowledgement, or the failure of the send.
+func (k *Kafka) sendChunk(producer sarama.SyncProducer, msgs []*sarama.ProducerMessage) error {
+	tStarted := time.Now()
+	err := producer.SendMessages(msgs)
+	if err != nil {
+		k.mSendErr.Incr(1)
+		return err
+	}
+	k.mAckLatency.Timing(time.Since(tStarted).Nanoseconds())
+	return nil
+}
+
+// compressionRatio returns the recent mean ratio of uncompressed to compressed
+// record batch sizes of a topic as observed by the producer, or 1 when this is
+// not yet known.
+func (k *Kafka) compressionRatio(topic string) float64 {
+	if k.compression == sarama.CompressionNone {
+		return 1
+	}
+	name := "compression-ratio-for-topic-" + strings.Replace(topic, ".", "_", -1)
+	if h, ok := k.saramaMetrics.Get(name).(gometrics.Histogram); ok {
+		if snap := h.Snapshot(); snap.Count() > 0 && snap.Mean() > 0 {
+			return snap.Mean() / 100
+		}
+	}
+	return 1
+}
+
+// recordSizeMetrics increments the uncompressed and estimated compressed size
+// metrics of each topic of a sent batch of messages.
+func (k *Kafka) recordSizeMetrics(msgs []*sarama.ProducerMessage) {
+	uncompressed := map[string]int{}
+	for _, m := range msgs {
+		uncompressed[m.Topic] += messageSize(m)
+	}
+	for topic, size := range uncompressed {
+		k.mBytesUncompressed.With(topic).Incr(int64(size))
+		k.mBytesSent.With(topic).Incr(int64(float64(size) / k.compressionRatio(topic)))
+	}
+}
+
+// createTopic attempts to create a topic with the configured partitions and
+// replication factor, a topic that already exists is not considered an error.
+func (k *Kafka) createTopic(admin sarama.ClusterAdmin, topic string) error {
+	k.topicsMut.Lock()
+	defer k.topicsMut.Unlock()
+
+	if _, exists := k.createdTopics[topic]; exists {
+		return nil
+	}
+
+	err := admin.CreateTopic(topic, &sarama.TopicDetail{
+		NumPartitions:     k.conf.CreateTopicsPartitions,
+		ReplicationFactor: k.conf.CreateTopicsReplicationFactor,
+	}, false)
+	if err != nil {
+		if tErr, ok := err.(*sarama.TopicError); !ok || tErr.Err != sarama.ErrTopicAlreadyExists {
+			return fmt.Errorf("failed to create topic '%v': %v", topic, err)
+		}
+	} else {
+		k.log.Infof("Created Kafka topic: %v\n", topic)
+	}
+
+	k.createdTopics[topic] = struct{}{}
+	return nil
+}
+
 //------------------------------------------------------------------------------
 
+// validateKafkaAcks checks that the combination of required_acks,
+// ack_replicas, max_in_flight, idempotent_write and offset_metadata is valid,
+// and returns an error listing each conflict found.
+func validateKafkaAcks(conf KafkaConfig, version sarama.KafkaVersion) error {
+	var conflicts []string
+	if conf.MaxInFlight < 1 {
+		conflicts = append(conflicts, "max_in_flight must be at least 1")
+	}
+	if conf.AckReplicas && conf.RequiredAcks == "none" {
+		conflicts = append(conflicts, "ack_replicas cannot be combined with required_acks set to none")
+	}
+	if conf.OffsetMetadata && conf.RequiredAcks == "none" {
+		conflicts = append(conflicts, "offset_metadata requires required_acks to not be none")
+	}
+	if conf.IdempotentWrite {
+		if !version.IsAtLeast(sarama.V0_11_0_0) {
+			conflicts = append(conflicts, fmt.Sprintf("idempotent_write requires a target_version of at least %v", sarama.V0_11_0_0))
+		}
+		if conf.RequiredAcks != "all" {
+			conflicts = append(conflicts, "idempotent_write requires required_acks to be all")
+		}
+		if conf.MaxInFlight > 1 {
+			conflicts = append(conflicts, "idempotent_write requires max_in_flight to be 1")
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("invalid ack settings: %v", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
 // ConnectWithContext attempts to establish a connection to a Kafka broker.
 func (k *Kafka) ConnectWithContext(ctx context.Context) error {
-	return k.Connect()
+	err := k.Connect()
+	if err == nil {
+		k.reconnecting = false
+		return nil
+	}
+	if !k.reconnecting {
+		k.reconnecting = true
+		k.connBackoff.Reset()
+	}
+
+	// Wait for a jittered period before the next attempt in order to avoid
+	// many writers reconnecting to a restarted broker in lockstep.
+	tNext := k.connBackoff.NextBackOff()
+	if tNext == backoff.Stop {
+		k.log.Errorf("Giving up on connection attempts after max_elapsed_time: %v\n", err)
+		k.reconnecting = false
+		return types.ErrTimeout
+	}
+	select {
+	case <-time.After(tNext):
+	case <-ctx.Done():
+	}
+	return err
 }
 
 // Connect attempts to establish a connection to a Kafka broker.
 func (k *Kafka) Connect() error {
+	k.connectMut.Lock()
+	defer k.connectMut.Unlock()
+
+	if atomic.LoadInt32(&k.authFailed) == 1 {
+		// New writes are rejected whilst authFailed is set, and those in
+		// flight must complete before the producer they are using is closed.
+		// The connection lock is not held whilst waiting as writes that are
+		// retrying acquire it.
+		k.connMut.Lock()
+		producer, admin := k.producer, k.admin
+		k.producer, k.admin = nil, nil
+		k.connMut.Unlock()
+
+		k.inFlight.Wait()
+		k.closeConn(producer, admin, true)
+		atomic.StoreInt32(&k.authFailed, 0)
+	}
+
 	k.connMut.Lock()
 	defer k.connMut.Unlock()
 
+	select {
+	case <-k.closeChan:
+		return types.ErrTypeClosed
+	default:
+	}
 	if k.producer != nil {
 		return nil
 	}
 
+	config, err := k.saramaConfig()
+	if err != nil {
+		return err
+	}
+
+	var admin sarama.ClusterAdmin
+	if k.conf.CreateTopics {
+		if admin, err = sarama.NewClusterAdmin(k.addresses, config); err != nil {
+			return fmt.Errorf("failed to connect to cluster admin API: %v", err)
+		}
+		if !text.ContainsFunctionVariables([]byte(k.conf.Topic)) {
+			if err = k.createTopic(admin, k.conf.Topic); err != nil {
+				admin.Close()
+				return err
+			}
+		}
+	}
+
+	if k.shared != nil {
+		var res io.Closer
+		if res, err = k.shared.AcquireShared(k.sharedKey, func() (io.Closer, error) {
+			return sarama.NewSyncProducer(k.addresses, config)
+		}); err == nil {
+			k.producer = res.(sarama.SyncProducer)
+		}
+	} else {
+		k.producer, err = sarama.NewSyncProducer(k.addresses, config)
+	}
+
+	if err == nil {
+		k.admin = admin
+		k.log.Infof("Sending Kafka messages to addresses: %s\n", k.addresses)
+	} else if admin != nil {
+		admin.Close()
+	}
+	return err
+}
+
+// saramaConfig creates a client config from the config of the output.
+func (k *Kafka) saramaConfig() (*sarama.Config, error) {
 	config := sarama.NewConfig()
 	config.ClientID = k.conf.ClientID
 
 	config.Version = k.version
 
 	config.Producer.Compression = k.compression
+	if k.conf.CompressionLevel != -1 {
+		config.Producer.CompressionLevel = k.conf.CompressionLevel
+	}
 	config.Producer.Partitioner = k.partitioner
 	config.Producer.MaxMessageBytes = k.conf.MaxMsgBytes
 	config.Producer.Timeout = k.timeout
 	config.Producer.Return.Errors = true
 	config.Producer.Return.Successes = true
+	if k.saramaMetrics != nil {
+		config.MetricRegistry = k.saramaMetrics
+	}
 	config.Net.TLS.Enable = k.conf.TLS.Enabled
 	if k.conf.TLS.Enabled {
 		config.Net.TLS.Config = k.tlsConf
 	}
 	if err := k.conf.SASL.Apply(k.mgr, config); err != nil {
+		return nil, err
+	}
+
+	config.Producer.RequiredAcks = k.requiredAcks
+	if k.conf.IdempotentWrite {
+		// Ordering of sequence numbers is only guaranteed with a single open
+		// request per broker.
+		config.Producer.Idempotent = true
+		config.Net.MaxOpenRequests = 1
+	}
+	return config, nil
+}
+
+// validateOnStart checks that the brokers are reachable and, when the topic is
+// static, that it exists or is going to be created.
+func (k *Kafka) validateOnStart() error {
+	config, err := k.saramaConfig()
+	if err != nil {
 		return err
 	}
 
-	if k.conf.AckReplicas {
-		config.Producer.RequiredAcks = sarama.WaitForAll
-	} else {
-		config.Producer.RequiredAcks = sarama.WaitForLocal
+	client, err := sarama.NewClient(k.addresses, config)
+	if err != nil {
+		return fmt.Errorf("failed to connect to kafka brokers %v: %v", k.addresses, err)
 	}
+	defer client.Close()
 
-	var err error
-	k.producer, err = sarama.NewSyncProducer(k.addresses, config)
+	if k.conf.CreateTopics || text.ContainsFunctionVariables([]byte(k.conf.Topic)) {
+		return nil
+	}
 
-	if err == nil {
-		k.log.Infof("Sending Kafka messages to addresses: %s\n", k.addresses)
+	// Listing topics avoids requesting metadata for the topic directly, which
+	// brokers that auto create topics would respond to by creating it.
+	topics, err := client.Topics()
+	if err != nil {
+		return fmt.Errorf("failed to fetch topics from kafka brokers %v: %v", k.addresses, err)
 	}
-	return err
+	for _, t := range topics {
+		if t == k.conf.Topic {
+			return nil
+		}
+	}
+	return fmt.Errorf("topic '%v' does not exist on kafka brokers %v", k.conf.Topic, k.addresses)
 }
 
 // WriteWithContext will attempt to write a message to Kafka, wait for
@@ -276,36 +1302,179 @@ func (k *Kafka) WriteWithContext(ctx context.Context, msg types.Message) error {
 	return k.Write(msg)
 }
 
+// WriteWithTargets will attempt to write a message to Kafka, wait for
+// acknowledgement, and returns the records that were written along with an
+// error if applicable.
+func (k *Kafka) WriteWithTargets(ctx context.Context, msg types.Message) (SentBatch, error) {
+	sent, err := k.write(msg)
+	if err != nil {
+		return SentBatch{}, err
+	}
+	unique := map[string]struct{}{}
+	summary := SentBatch{Count: len(sent)}
+	for _, m := range sent {
+		summary.Bytes += messageSize(m)
+		unique[m.Topic] = struct{}{}
+	}
+	summary.Targets = make([]string, 0, len(unique))
+	for topic := range unique {
+		summary.Targets = append(summary.Targets, topic)
+	}
+	sort.Strings(summary.Targets)
+	return summary, nil
+}
+
 // Write will attempt to write a message to Kafka, wait for acknowledgement, and
 // returns an error if applicable.
 func (k *Kafka) Write(msg types.Message) error {
+	_, err := k.write(msg)
+	return err
+}
+
+// write attempts to write a message to Kafka and returns the records that were
+// acknowledged when the whole batch is sent.
+func (k *Kafka) write(msg types.Message) ([]*sarama.ProducerMessage, error) {
 	k.connMut.RLock()
+	select {
+	case <-k.closeChan:
+		k.connMut.RUnlock()
+		return nil, types.ErrTypeClosed
+	default:
+	}
+	if atomic.LoadInt32(&k.authFailed) == 1 {
+		k.connMut.RUnlock()
+		return nil, types.ErrNotConnected
+	}
 	producer := k.producer
+	admin := k.admin
 	version := k.version
+	k.inFlight.Add(1)
 	k.connMut.RUnlock()
+	defer k.inFlight.Done()
 
 	if producer == nil {
-		return types.ErrNotConnected
+		return nil, types.ErrNotConnected
+	}
+
+	var provenance []sarama.RecordHeader
+	if k.conf.ProvenanceHeaders {
+		provenance = k.provenanceHeaders(time.Now())
+	}
+
+	var stickySeq interface{}
+	if k.conf.Partitioner == "sticky" {
+		stickySeq = atomic.AddUint64(&k.stickySeq, 1)
 	}
 
 	msgs := []*sarama.ProducerMessage{}
-	msg.Iter(func(i int, p types.Part) error {
-		lMsg := message.Lock(msg, i)
+	indexes := map[*sarama.ProducerMessage]int{}
+	var rejected sarama.ProducerErrors
+	reject := func(i int, m *sarama.ProducerMessage, err error) {
+		indexes[m] = i
+		rejected = append(rejected, &sarama.ProducerError{
+			Msg: m,
+			Err: types.NonRetriableError{Err: err},
+		})
+	}
+	if err := msg.Iter(func(i int, p types.Part) error {
+		lMsg := msg
+		if !k.static {
+			lMsg = message.Lock(msg, i)
+		}
 
-		key := k.key.Get(lMsg)
+		key, topic, send, interpErr, err := k.resolveTarget(lMsg, p)
+		if err != nil {
+			return err
+		}
+		if send && k.conf.ValidateUTF8 {
+			key, topic, send, interpErr = k.validateTarget(key, topic)
+		}
+		if interpErr != nil {
+			// Retrying a message that cannot be interpolated or is invalid is
+			// futile.
+			reject(i, &sarama.ProducerMessage{}, interpErr)
+			return nil
+		}
+		if !send {
+			return nil
+		}
 		nextMsg := &sarama.ProducerMessage{
-			Topic:   k.topic.Get(lMsg),
+			Topic:   topic,
 			Value:   sarama.ByteEncoder(p.Get()),
 			Headers: buildHeaders(version, p),
+			// Carries the batch sequence to the sticky partitioner.
+			Metadata: stickySeq,
+		}
+		for _, name := range k.headerKeys {
+			nextMsg.Headers = append(nextMsg.Headers, sarama.RecordHeader{
+				Key:   []byte(name),
+				Value: []byte(k.headers[name].Get(lMsg)),
+			})
+		}
+		if len(provenance) > 0 {
+			nextMsg.Headers = append(nextMsg.Headers, provenance...)
 		}
 		if len(key) > 0 {
 			nextMsg.Key = sarama.ByteEncoder(key)
 		}
+		if k.timestamp != nil {
+			ts, err := parseTimestamp(k.timestamp.Get(lMsg))
+			if err != nil {
+				// Retrying a message with an invalid timestamp is futile.
+				k.log.Errorf("Failed to resolve timestamp of message: %v\n", err)
+				reject(i, nextMsg, err)
+				return nil
+			}
+			nextMsg.Timestamp = ts
+		}
+		if k.partition != nil {
+			partStr := k.partition.Get(lMsg)
+			partition, err := strconv.ParseInt(partStr, 10, 32)
+			if err != nil || partition < 0 {
+				err = fmt.Errorf("partition resolved to '%v', which is not a valid non-negative integer", partStr)
+				k.log.Errorf("Failed to resolve partition of message: %v\n", err)
+				reject(i, nextMsg, err)
+				return nil
+			}
+			nextMsg.Partition = int32(partition)
+		}
+		if k.partitionExpr != nil {
+			partStr := k.partitionExpr.Get(lMsg)
+			partition, err := strconv.ParseInt(strings.TrimSpace(partStr), 10, 32)
+			if err != nil || partition < 0 {
+				err = fmt.Errorf("partition_expression resolved to '%v', which is not a valid non-negative integer", partStr)
+				k.log.Errorf("Failed to resolve partition of message: %v\n", err)
+				reject(i, nextMsg, err)
+				return nil
+			}
+			nextMsg.Partition = int32(partition)
+		}
 		msgs = append(msgs, nextMsg)
+		indexes[nextMsg] = i
 		return nil
-	})
+	}); err != nil {
+		return nil, err
+	}
 
-	err := producer.SendMessages(msgs)
+	if admin != nil {
+		for _, m := range msgs {
+			if err := k.createTopic(admin, m.Topic); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sent := msgs
+	var err error
+	if len(msgs) > 0 {
+		err = k.sendMessages(producer, msgs)
+	}
+
+	// Each write has its own backoff as writes may be in flight in parallel.
+	var boff backoff.BackOff
+	if err != nil {
+		boff = k.backoffCtor()
+	}
 	for err != nil {
 		pErrs, ok := err.(sarama.ProducerErrors)
 		if !ok {
@@ -314,49 +1483,255 @@ func (k *Kafka) Write(msg types.Message) error {
 			if len(pErrs) == 0 {
 				break
 			}
+			k.log.Errorf("Failed to send '%v' messages: %v\n", len(pErrs), pErrs[0].Err)
 			msgs = nil
+			loggedTopics := map[string]struct{}{}
 			for _, pErr := range pErrs {
+				if tErr := topicError(pErr); tErr != nil {
+					// Retrying a message that the topic ACLs or config reject
+					// is futile.
+					if _, exists := loggedTopics[pErr.Msg.Topic]; !exists {
+						loggedTopics[pErr.Msg.Topic] = struct{}{}
+						k.log.Errorf("Rejecting messages for topic '%v' due to a permission or configuration issue rather than a transient failure: %v\n", pErr.Msg.Topic, tErr)
+					}
+					pErr.Err = tErr
+					rejected = append(rejected, pErr)
+					continue
+				}
+				if pErr.Err == sarama.ErrMessageSizeTooLarge {
+					// Retrying a message that exceeds max_msg_bytes is futile.
+					k.mDroppedMaxBytes.Incr(1)
+					pErr.Err = types.NonRetriableError{Err: pErr.Err}
+					rejected = append(rejected, pErr)
+					continue
+				}
+				if pErr.Err == sarama.ErrInvalidPartition && (k.partition != nil || k.partitionExpr != nil) {
+					// The explicit partition does not exist on the topic.
+					pErr.Err = types.NonRetriableError{Err: fmt.Errorf(
+						"partition %v is out of range for topic '%v': %v",
+						pErr.Msg.Partition, pErr.Msg.Topic, pErr.Err,
+					)}
+					rejected = append(rejected, pErr)
+					continue
+				}
 				msgs = append(msgs, pErr.Msg)
 			}
-			k.log.Errorf("Failed to send '%v' messages: %v\n", len(pErrs), pErrs[0].Err)
+			if len(msgs) == 0 {
+				k.recordSent(msg, sent, indexes, rejected)
+				return nil, producerBatchError(msg, indexes, rejected)
+			}
+		}
+
+		if isAuthError(err) {
+			// Reconnecting applies the SASL config again, which reloads any
+			// rotated credential files. Other writes may still be using the
+			// producer and therefore it is only closed by Connect once they
+			// have completed.
+			if atomic.CompareAndSwapInt32(&k.authFailed, 0, 1) {
+				k.log.Errorf("Authentication failed, reconnecting: %v\n", err)
+			}
+			return nil, types.ErrNotConnected
 		}
 
-		tNext := k.backoff.NextBackOff()
+		tNext := retries.NextBackOff(boff, err)
+		if tNext != backoff.Stop {
+			select {
+			case <-time.After(tNext):
+			case <-k.closeChan:
+				// Rejecting the batch rather than retrying it prevents a
+				// batch that cannot be sent from blocking shutdown.
+				tNext = backoff.Stop
+			}
+		}
 		if tNext == backoff.Stop {
-			k.backoff.Reset()
-			return err
+			if ok {
+				rejected = append(rejected, pErrs...)
+				k.recordSent(msg, sent, indexes, rejected)
+				return nil, producerBatchError(msg, indexes, rejected)
+			}
+			return nil, err
 		}
-		<-time.After(tNext)
 
-		// Recheck connection is alive
+		// Recheck connection is alive, a write that failed authentication
+		// means the producer is to be replaced.
+		if atomic.LoadInt32(&k.authFailed) == 1 {
+			return nil, types.ErrNotConnected
+		}
 		k.connMut.RLock()
 		producer = k.producer
 		k.connMut.RUnlock()
 
 		if producer == nil {
-			return types.ErrNotConnected
+			return nil, types.ErrNotConnected
 		}
-		err = producer.SendMessages(msgs)
+		k.mSendRetry.Incr(1)
+		err = k.sendMessages(producer, msgs)
 	}
 
-	k.backoff.Reset()
-	return nil
+	acked := k.recordSent(msg, sent, indexes, rejected)
+	if len(rejected) > 0 {
+		return nil, producerBatchError(msg, indexes, rejected)
+	}
+	return acked, nil
 }
 
-// CloseAsync shuts down the Kafka writer and stops processing messages.
-func (k *Kafka) CloseAsync() {
-	go func() {
-		k.connMut.Lock()
-		if nil != k.producer {
-			k.producer.Close()
-			k.producer = nil
+// recordSent updates the metrics of the records of a batch that were
+// acknowledged, which excludes those that were rejected, and returns them. The
+// partition and offset of each record are logged and, when offset_metadata is
+// enabled, added to the metadata of the part that the record was created from.
+func (k *Kafka) recordSent(msg types.Message, sent []*sarama.ProducerMessage, indexes map[*sarama.ProducerMessage]int, rejected sarama.ProducerErrors) []*sarama.ProducerMessage {
+	failed := make(map[*sarama.ProducerMessage]struct{}, len(rejected))
+	for _, pErr := range rejected {
+		failed[pErr.Msg] = struct{}{}
+	}
+	acked := make([]*sarama.ProducerMessage, 0, len(sent))
+	for _, m := range sent {
+		if _, exists := failed[m]; !exists {
+			acked = append(acked, m)
 		}
-		k.connMut.Unlock()
-	}()
+	}
+
+	if len(acked) > 0 {
+		bytes := 0
+		for _, m := range acked {
+			bytes += messageSize(m)
+		}
+		k.mBatchSent.Incr(1)
+		k.mBatchBytes.Incr(int64(bytes))
+	}
+	if k.saramaMetrics != nil {
+		k.recordSizeMetrics(acked)
+	}
+	for _, m := range acked {
+		k.log.Debugf("Produced message to topic '%v' partition %v offset %v\n", m.Topic, m.Partition, m.Offset)
+		if k.conf.OffsetMetadata {
+			meta := msg.Get(indexes[m]).Metadata()
+			meta.Set("kafka_partition", strconv.Itoa(int(m.Partition)))
+			meta.Set("kafka_offset", strconv.FormatInt(m.Offset, 10))
+		}
+	}
+	return acked
 }
 
-// WaitForClose blocks until the Kafka writer has closed down.
+// BatchTopic returns the topic that a message part resolves to, which is used
+// to batch the messages of each topic separately when batch_per_topic is
+// enabled.
+func (k *Kafka) BatchTopic(p types.Part) string {
+	if k.static {
+		return k.staticTopic
+	}
+	lMsg := message.New(nil)
+	lMsg.Append(p)
+	return k.topic.Get(lMsg)
+}
+
+// OrderingKeys returns the unique ordering keys of the records of a batch,
+// which identify a record by its topic and either its explicit partition, when
+// partition or partition_expression is set, or a hash of its key. Records
+// without a key share the ordering key of their topic.
+func (k *Kafka) OrderingKeys(msg types.Message) []string {
+	unique := map[string]struct{}{}
+	for i := 0; i < msg.Len(); i++ {
+		lMsg := msg
+		if !k.static {
+			lMsg = message.Lock(msg, i)
+		}
+		topic := k.staticTopic
+		if !k.static {
+			topic = k.topic.Get(lMsg)
+		}
+		switch {
+		case k.partition != nil:
+			unique[topic+"#"+k.partition.Get(lMsg)] = struct{}{}
+		case k.partitionExpr != nil:
+			unique[topic+"#"+strings.TrimSpace(k.partitionExpr.Get(lMsg))] = struct{}{}
+		default:
+			key, _, _ := k.resolveKey(lMsg, msg.Get(i))
+			h := fnv.New64a()
+			h.Write(key)
+			unique[topic+"/"+strconv.FormatUint(h.Sum64(), 16)] = struct{}{}
+		}
+	}
+	keys := make([]string, 0, len(unique))
+	for key := range unique {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isAuthError returns true if an error, or any error of a batch of producer
+// errors, is a SASL authentication failure.
+func isAuthError(err error) bool {
+	if pErrs, ok := err.(sarama.ProducerErrors); ok {
+		for _, pErr := range pErrs {
+			if pErr.Err == sarama.ErrSASLAuthenticationFailed {
+				return true
+			}
+		}
+		return false
+	}
+	return err == sarama.ErrSASLAuthenticationFailed
+}
+
+// disconnect closes the producer and admin client, if any.
+func (k *Kafka) disconnect() {
+	k.connMut.Lock()
+	producer, admin := k.producer, k.admin
+	k.producer, k.admin = nil, nil
+	k.connMut.Unlock()
+	k.closeConn(producer, admin, false)
+}
+
+// closeConn closes a producer and admin client, either of which may be nil.
+// When invalidate is true a shared producer is no longer shared, so that the
+// next writer to connect creates a fresh one.
+func (k *Kafka) closeConn(producer sarama.SyncProducer, admin sarama.ClusterAdmin, invalidate bool) {
+	if nil != producer {
+		if k.shared != nil {
+			if invalidate {
+				if err := k.shared.InvalidateShared(k.sharedKey, producer); err != nil {
+					k.log.Errorf("Failed to invalidate shared connection: %v\n", err)
+				}
+			}
+			if err := k.shared.ReleaseShared(k.sharedKey, producer); err != nil {
+				k.log.Errorf("Failed to release shared connection: %v\n", err)
+			}
+		} else {
+			producer.Close()
+		}
+	}
+	if nil != admin {
+		admin.Close()
+	}
+}
+
+// CloseAsync shuts down the Kafka writer and stops processing messages. New
+// writes are rejected, writes in flight are not retried any further, and the
+// producer is closed once they have been acknowledged or have failed.
+func (k *Kafka) CloseAsync() {
+	k.connMut.Lock()
+	select {
+	case <-k.closeChan:
+	default:
+		close(k.closeChan)
+		go func() {
+			k.inFlight.Wait()
+			k.disconnect()
+			close(k.closedChan)
+		}()
+	}
+	k.connMut.Unlock()
+}
+
+// WaitForClose blocks until the Kafka writer has closed down, returning
+// types.ErrTimeout if writes in flight have not completed within the timeout.
 func (k *Kafka) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-k.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
 	return nil
 }
 
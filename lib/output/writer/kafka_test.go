@@ -0,0 +1,2628 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Shopify/sarama"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// newMockKafkaBroker creates a mock broker that serves the metadata of topics.
+// The broker accepts TLS connections only, since SASL config enables TLS for
+// all connections, and therefore configs connecting to it must be passed to
+// mockKafkaTLS.
+func newMockKafkaBroker(t *testing.T, topics ...string) *sarama.MockBroker {
+	t.Helper()
+
+	// Borrow the self-signed certificate of an httptest server.
+	srv := httptest.NewUnstartedServer(nil)
+	srv.StartTLS()
+	tlsConf := srv.TLS.Clone()
+	srv.Close()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", tlsConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := sarama.NewMockBrokerListener(t, 1, listener)
+
+	metaResponse := sarama.NewMockMetadataResponse(t).
+		SetController(broker.BrokerID()).
+		SetBroker(broker.Addr(), broker.BrokerID())
+	for _, topic := range topics {
+		metaResponse = metaResponse.SetLeader(topic, 0, broker.BrokerID())
+	}
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest":     metaResponse,
+		"CreateTopicsRequest": sarama.NewMockCreateTopicsResponse(t),
+		"ProduceRequest":      sarama.NewMockProduceResponse(t).SetVersion(3),
+	})
+	return broker
+}
+
+// mockKafkaTLS configures a config to connect to a broker created with
+// newMockKafkaBroker.
+func mockKafkaTLS(conf *KafkaConfig) {
+	conf.TLS.Enabled = true
+	conf.TLS.InsecureSkipVerify = true
+}
+
+func createdTopics(broker *sarama.MockBroker) map[string]*sarama.TopicDetail {
+	topics := map[string]*sarama.TopicDetail{}
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*sarama.CreateTopicsRequest); ok {
+			for k, v := range req.TopicDetails {
+				topics[k] = v
+			}
+		}
+	}
+	return topics
+}
+
+func TestKafkaCreateTopicsStatic(t *testing.T) {
+	broker := newMockKafkaBroker(t, "foo")
+	defer broker.Close()
+
+	conf := NewKafkaConfig()
+	conf.Addresses = []string{broker.Addr()}
+	mockKafkaTLS(&conf)
+	conf.Topic = "foo"
+	conf.CreateTopics = true
+	conf.CreateTopicsPartitions = 3
+	conf.CreateTopicsReplicationFactor = 2
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = k.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer k.CloseAsync()
+
+	topics := createdTopics(broker)
+	detail, exists := topics["foo"]
+	if !exists {
+		t.Fatalf("Topic was not created: %v", topics)
+	}
+	if exp, act := int32(3), detail.NumPartitions; exp != act {
+		t.Errorf("Wrong partitions: %v != %v", act, exp)
+	}
+	if exp, act := int16(2), detail.ReplicationFactor; exp != act {
+		t.Errorf("Wrong replication factor: %v != %v", act, exp)
+	}
+
+	if err = k.Write(message.New([][]byte{[]byte("hello world")})); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 1, len(createdTopics(broker)); exp != act {
+		t.Errorf("Wrong count of created topics: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaCreateTopicsInterpolated(t *testing.T) {
+	broker := newMockKafkaBroker(t, "foo", "bar")
+	defer broker.Close()
+
+	conf := NewKafkaConfig()
+	conf.Addresses = []string{broker.Addr()}
+	mockKafkaTLS(&conf)
+	conf.Topic = "${!content}"
+	conf.CreateTopics = true
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = k.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer k.CloseAsync()
+
+	if exp, act := 0, len(createdTopics(broker)); exp != act {
+		t.Errorf("Wrong count of created topics: %v != %v", act, exp)
+	}
+
+	if err = k.Write(message.New([][]byte{[]byte("foo"), []byte("bar")})); err != nil {
+		t.Fatal(err)
+	}
+
+	topics := createdTopics(broker)
+	for _, topic := range []string{"foo", "bar"} {
+		if _, exists := topics[topic]; !exists {
+			t.Errorf("Topic %v was not created: %v", topic, topics)
+		}
+	}
+}
+
+func TestKafkaCreateTopicsBadConfig(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.CreateTopics = true
+	conf.TargetVersion = sarama.V0_10_0_0.String()
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from old target version")
+	}
+
+	conf = NewKafkaConfig()
+	conf.CreateTopics = true
+	conf.CreateTopicsPartitions = 0
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from zero partitions")
+	}
+}
+
+type fakeSyncProducer struct {
+	msgs  []*sarama.ProducerMessage
+	sends int
+	fail  func(*sarama.ProducerMessage) error
+}
+
+func (f *fakeSyncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	f.msgs = append(f.msgs, msg)
+	return 0, 0, nil
+}
+
+func (f *fakeSyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	f.sends++
+	var pErrs sarama.ProducerErrors
+	for _, msg := range msgs {
+		if f.fail != nil {
+			if err := f.fail(msg); err != nil {
+				pErrs = append(pErrs, &sarama.ProducerError{Msg: msg, Err: err})
+				continue
+			}
+		}
+		f.msgs = append(f.msgs, msg)
+	}
+	if len(pErrs) > 0 {
+		return pErrs
+	}
+	return nil
+}
+
+func (f *fakeSyncProducer) Close() error {
+	return nil
+}
+
+type sharedMgr struct {
+	types.DudMgr
+	producer *fakeSyncProducer
+	holders  map[string]int
+}
+
+func (s *sharedMgr) AcquireShared(key string, ctor func() (io.Closer, error)) (io.Closer, error) {
+	s.holders[key]++
+	return s.producer, nil
+}
+
+func (s *sharedMgr) ReleaseShared(key string, res io.Closer) error {
+	s.holders[key]--
+	return nil
+}
+
+func (s *sharedMgr) InvalidateShared(key string, res io.Closer) error {
+	return nil
+}
+
+func TestKafkaShareConnection(t *testing.T) {
+	mgr := &sharedMgr{
+		producer: &fakeSyncProducer{},
+		holders:  map[string]int{},
+	}
+
+	conf := NewKafkaConfig()
+	conf.ShareConnection = true
+
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from manager without shared resources")
+	}
+
+	conf.CompressionMetrics = true
+	if _, err := NewKafka(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from compression_metrics")
+	}
+	conf.CompressionMetrics = false
+
+	connect := func(conf KafkaConfig) *Kafka {
+		t.Helper()
+		k, err := NewKafka(conf, mgr, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = k.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	conf.Topic = "foo"
+	k1 := connect(conf)
+	conf.Topic = "bar"
+	k2 := connect(conf)
+	conf.Compression = "snappy"
+	k3 := connect(conf)
+
+	if k1.sharedKey != k2.sharedKey {
+		t.Error("Expected outputs with differing topics to share a connection")
+	}
+	if k1.sharedKey == k3.sharedKey {
+		t.Error("Expected outputs with differing compression to not share a connection")
+	}
+	if exp, act := 2, mgr.holders[k1.sharedKey]; exp != act {
+		t.Errorf("Wrong count of holders: %v != %v", act, exp)
+	}
+
+	if err := k1.Write(message.New([][]byte{[]byte("hello")})); err != nil {
+		t.Fatal(err)
+	}
+	if err := k2.Write(message.New([][]byte{[]byte("world")})); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 2, len(mgr.producer.msgs); exp != act {
+		t.Errorf("Wrong count of messages sent with shared producer: %v != %v", act, exp)
+	}
+
+	for _, k := range []*Kafka{k1, k2, k3} {
+		k.CloseAsync()
+		if err := k.WaitForClose(time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for key, holders := range mgr.holders {
+		if holders != 0 {
+			t.Errorf("Shared connection '%v' not released: %v", key, holders)
+		}
+	}
+}
+
+func TestKafkaProvenanceHeaders(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.ProvenanceHeaders = true
+	conf.PipelineName = "foopipeline"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	msg.Get(0).Metadata().Set("baz", "qux")
+
+	tBefore := time.Now()
+	if err = k.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := 2, len(producer.msgs); exp != act {
+		t.Fatalf("Wrong count of sent messages: %v != %v", act, exp)
+	}
+	for i, m := range producer.msgs {
+		headers := map[string]string{}
+		for _, h := range m.Headers {
+			headers[string(h.Key)] = string(h.Value)
+		}
+		if exp, act := hostname, headers["benthos_instance"]; exp != act {
+			t.Errorf("Wrong benthos_instance header of message %v: %v != %v", i, act, exp)
+		}
+		if exp, act := "foopipeline", headers["benthos_pipeline"]; exp != act {
+			t.Errorf("Wrong benthos_pipeline header of message %v: %v != %v", i, act, exp)
+		}
+		producedAt, err := time.Parse(time.RFC3339Nano, headers["benthos_produced_at"])
+		if err != nil {
+			t.Errorf("Failed to parse benthos_produced_at header of message %v: %v", i, err)
+		} else if producedAt.Before(tBefore.Truncate(time.Second)) {
+			t.Errorf("Header benthos_produced_at of message %v is too early: %v", i, producedAt)
+		}
+	}
+	if exp, act := 4, len(producer.msgs[0].Headers); exp != act {
+		t.Errorf("Wrong count of headers: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaProvenanceHeadersBadVersion(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.ProvenanceHeaders = true
+	conf.TargetVersion = sarama.V0_10_2_0.String()
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from old target version")
+	}
+}
+
+func TestKafkaBadSASLMechanism(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.SASL.Mechanism = "SCRAM-SHA-1"
+	_, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err == nil {
+		t.Fatal("Expected error from bad SASL mechanism")
+	}
+	if exp := "unsupported SASL mechanism 'SCRAM-SHA-1'"; !strings.Contains(err.Error(), exp) {
+		t.Errorf("Expected error '%v' to contain '%v'", err, exp)
+	}
+}
+
+func TestKafkaHeaders(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Headers = map[string]string{
+		"foo":   "${!metadata:foo}",
+		"bar":   "static",
+		"empty": "${!metadata:nope}",
+	}
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	msg := message.New([][]byte{[]byte("first"), []byte("second")})
+	msg.Get(0).Metadata().Set("foo", "foo1")
+	msg.Get(1).Metadata().Set("foo", "foo2")
+
+	if err = k.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 2, len(producer.msgs); exp != act {
+		t.Fatalf("Wrong count of sent messages: %v != %v", act, exp)
+	}
+
+	exp := [][]sarama.RecordHeader{
+		{
+			{Key: []byte("foo"), Value: []byte("foo1")},
+			{Key: []byte("bar"), Value: []byte("static")},
+			{Key: []byte("empty"), Value: []byte{}},
+			{Key: []byte("foo"), Value: []byte("foo1")},
+		},
+		{
+			{Key: []byte("foo"), Value: []byte("foo2")},
+			{Key: []byte("bar"), Value: []byte("static")},
+			{Key: []byte("empty"), Value: []byte{}},
+			{Key: []byte("foo"), Value: []byte("foo2")},
+		},
+	}
+	for i, m := range producer.msgs {
+		if !reflect.DeepEqual(exp[i], m.Headers) {
+			t.Errorf("Wrong headers of message %v: %s != %s", i, m.Headers, exp[i])
+		}
+	}
+}
+
+func TestKafkaHeadersBadVersion(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Headers = map[string]string{"foo": "bar"}
+	conf.TargetVersion = sarama.V0_10_2_0.String()
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from old target version")
+	}
+}
+
+func TestKafkaTimestamp(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Timestamp = "${!metadata:ts}"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	msg := message.New([][]byte{[]byte("first"), []byte("second"), []byte("third")})
+	msg.Get(0).Metadata().Set("ts", "2020-03-04T05:06:07.5Z")
+	msg.Get(1).Metadata().Set("ts", "1583298367500")
+	msg.Get(2).Metadata().Set("ts", "yesterday")
+
+	err = k.Write(msg)
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, got: %v", err)
+	}
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if i == 2 {
+			if !types.IsNonRetriable(err) {
+				t.Errorf("Expected non-retriable error of part %v: %v", i, err)
+			}
+		} else if err != nil {
+			t.Errorf("Unexpected error of part %v: %v", i, err)
+		}
+		return true
+	})
+
+	if exp, act := 2, len(producer.msgs); exp != act {
+		t.Fatalf("Wrong count of sent messages: %v != %v", act, exp)
+	}
+	exp := time.Date(2020, 3, 4, 5, 6, 7, 500000000, time.UTC)
+	for i, m := range producer.msgs {
+		if !exp.Equal(m.Timestamp) {
+			t.Errorf("Wrong timestamp of message %v: %v != %v", i, m.Timestamp, exp)
+		}
+	}
+}
+
+func TestKafkaOffsetMetadata(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		conf := NewKafkaConfig()
+		conf.Timestamp = "${!metadata:ts}"
+		conf.OffsetMetadata = enabled
+
+		k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		offset := int64(10)
+		k.producer = &fakeSyncProducer{
+			fail: func(m *sarama.ProducerMessage) error {
+				m.Partition = 3
+				m.Offset = offset
+				offset++
+				return nil
+			},
+		}
+
+		msg := message.New([][]byte{[]byte("first"), []byte("second"), []byte("third")})
+		msg.Get(0).Metadata().Set("ts", "1583298367500")
+		msg.Get(1).Metadata().Set("ts", "yesterday")
+		msg.Get(2).Metadata().Set("ts", "1583298367500")
+
+		if err = k.Write(msg); err == nil {
+			t.Fatal("Expected error from invalid timestamp")
+		}
+
+		exp := [][2]string{{"3", "10"}, {"", ""}, {"3", "11"}}
+		for i, e := range exp {
+			if !enabled {
+				e = [2]string{"", ""}
+			}
+			meta := msg.Get(i).Metadata()
+			if act := meta.Get("kafka_partition"); act != e[0] {
+				t.Errorf("Wrong partition metadata of part %v: %v != %v", i, act, e[0])
+			}
+			if act := meta.Get("kafka_offset"); act != e[1] {
+				t.Errorf("Wrong offset metadata of part %v: %v != %v", i, act, e[1])
+			}
+		}
+	}
+}
+
+func TestKafkaTimestampBadVersion(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Timestamp = "${!metadata:ts}"
+	conf.TargetVersion = sarama.V0_9_0_0.String()
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from old target version")
+	}
+}
+
+func TestKafkaMurmur2Partitioner(t *testing.T) {
+	// Expected partitions are toPositive(murmur2(key)) % partitions as
+	// calculated by the DefaultPartitioner of the Java client, using the hash
+	// vectors of the Java client utils tests. Several keys hash to negative
+	// values, where the masking of the Java client differs from taking the
+	// absolute value of the remainder.
+	tests := []struct {
+		key        string
+		partitions int32
+		expected   int32
+	}{
+		{"21", 3, 0},
+		{"21", 10, 0},
+		{"21", 64, 44},
+		{"foobar", 3, 0},
+		{"foobar", 10, 6},
+		{"foobar", 64, 62},
+		{"a-little-bit-long-string", 3, 2},
+		{"a-little-bit-long-string", 10, 2},
+		{"a-little-bit-long-string", 64, 32},
+		{"a-little-bit-longer-string", 3, 2},
+		{"a-little-bit-longer-string", 10, 9},
+		{"a-little-bit-longer-string", 64, 3},
+		{"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8", 3, 2},
+		{"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8", 10, 7},
+		{"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8", 64, 13},
+		{"abc", 3, 0},
+		{"abc", 10, 7},
+		{"abc", 64, 27},
+		{"hello world", 3, 1},
+		{"hello world", 10, 9},
+		{"hello world", 64, 35},
+	}
+
+	ctor, err := strToPartitioner("murmur2_hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	partitioner := ctor("foo")
+	if !partitioner.RequiresConsistency() {
+		t.Error("Expected partitioner to require consistency")
+	}
+
+	for _, test := range tests {
+		partition, err := partitioner.Partition(&sarama.ProducerMessage{
+			Key: sarama.StringEncoder(test.key),
+		}, test.partitions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if partition != test.expected {
+			t.Errorf("Wrong partition of key '%v' with %v partitions: %v != %v", test.key, test.partitions, partition, test.expected)
+		}
+	}
+}
+
+func TestKafkaConsistentHashPartitioner(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Partitioner = "consistent_hash"
+	conf.PartitionerReplicas = 0
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from zero replicas")
+	}
+
+	partitioner := newConsistentHashPartitionerCtor(consistentHashDefaultReplicas)("foo")
+	if !partitioner.RequiresConsistency() {
+		t.Error("Expected partitioner to require consistency")
+	}
+
+	// Keys of uneven cardinality, similar to user identifiers where a subset
+	// of users are far more active than the rest.
+	keys := []string{}
+	for i := 0; i < 20000; i++ {
+		keys = append(keys, "user-"+strconv.Itoa(i%5000))
+	}
+
+	assign := func(numPartitions int32) map[string]int32 {
+		assigned := map[string]int32{}
+		for _, k := range keys {
+			p, err := partitioner.Partition(&sarama.ProducerMessage{
+				Key: sarama.StringEncoder(k),
+			}, numPartitions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p < 0 || p >= numPartitions {
+				t.Fatalf("Partition out of range: %v", p)
+			}
+			if prev, exists := assigned[k]; exists && prev != p {
+				t.Fatalf("Key '%v' assigned to multiple partitions: %v and %v", k, prev, p)
+			}
+			assigned[k] = p
+		}
+		return assigned
+	}
+
+	numPartitions := int32(12)
+	before := assign(numPartitions)
+
+	counts := make([]int, numPartitions)
+	for _, p := range before {
+		counts[p]++
+	}
+	mean := float64(len(before)) / float64(numPartitions)
+	for p, c := range counts {
+		if skew := float64(c) / mean; skew > 1.3 || skew < 0.7 {
+			t.Errorf("Partition %v has skewed count of keys: %v (mean %v)", p, c, mean)
+		}
+	}
+
+	after := assign(numPartitions + 1)
+	moved := 0
+	for k, p := range before {
+		if after[k] == p {
+			continue
+		}
+		moved++
+		if after[k] != numPartitions {
+			t.Errorf("Key '%v' moved between existing partitions: %v to %v", k, p, after[k])
+		}
+	}
+	if maxMoved := 2 * len(before) / int(numPartitions+1); moved > maxMoved {
+		t.Errorf("Too many keys reassigned after adding a partition: %v > %v", moved, maxMoved)
+	}
+}
+
+func TestKafkaStickyPartitioner(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Partitioner = "sticky"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	ctor, err := strToPartitioner("sticky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	partitioner := ctor("foo")
+	if !partitioner.RequiresConsistency() {
+		t.Error("Expected partitioner to require consistency")
+	}
+
+	partitionsOf := func(msgs []*sarama.ProducerMessage) []int32 {
+		var parts []int32
+		for _, m := range msgs {
+			p, err := partitioner.Partition(m, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parts = append(parts, p)
+		}
+		return parts
+	}
+
+	batch := [][]byte{[]byte("foo"), []byte("bar"), []byte("baz")}
+	if err = k.Write(message.New(batch)); err != nil {
+		t.Fatal(err)
+	}
+	first := partitionsOf(producer.msgs)
+	producer.msgs = nil
+
+	if err = k.Write(message.New(batch)); err != nil {
+		t.Fatal(err)
+	}
+	second := partitionsOf(producer.msgs)
+
+	for i, parts := range [][]int32{first, second} {
+		for _, p := range parts[1:] {
+			if p != parts[0] {
+				t.Errorf("Batch %v spread across partitions: %v", i, parts)
+			}
+		}
+	}
+	if first[0] == second[0] {
+		t.Errorf("Consecutive batches sent to the same partition: %v", first[0])
+	}
+
+	keyed := &sarama.ProducerMessage{Key: sarama.StringEncoder("foo"), Metadata: uint64(1)}
+	exp, err := sarama.NewHashPartitioner("foo").Partition(keyed, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, seq := range []uint64{1, 2, 3} {
+		keyed.Metadata = seq
+		if act := partitionsOf([]*sarama.ProducerMessage{keyed})[0]; act != exp {
+			t.Errorf("Wrong partition of keyed record: %v != %v", act, exp)
+		}
+	}
+}
+
+func TestKafkaPartitionExpression(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Partitioner = "expression"
+	conf.PartitionExpr = "${!metadata:partition}"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := 0
+	producer := &fakeSyncProducer{
+		fail: func(msg *sarama.ProducerMessage) error {
+			attempts++
+			if msg.Partition >= 3 {
+				return sarama.ErrInvalidPartition
+			}
+			return nil
+		},
+	}
+	k.producer = producer
+
+	msg := message.New([][]byte{[]byte("first"), []byte("second"), []byte("third"), []byte("fourth")})
+	msg.Get(0).Metadata().Set("partition", "2")
+	msg.Get(1).Metadata().Set("partition", "5")
+	msg.Get(2).Metadata().Set("partition", "nope")
+	msg.Get(3).Metadata().Set("partition", "0")
+
+	err = k.Write(msg)
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, got: %v", err)
+	}
+	var failed []int
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if err != nil {
+			if !types.IsNonRetriable(err) {
+				t.Errorf("Expected non-retriable error of part %v: %v", i, err)
+			}
+			failed = append(failed, i)
+		}
+		return true
+	})
+	if exp := []int{1, 2}; !reflect.DeepEqual(exp, failed) {
+		t.Errorf("Wrong failed parts: %v != %v", failed, exp)
+	}
+	if exp, act := 3, attempts; exp != act {
+		t.Errorf("Wrong count of send attempts: %v != %v", act, exp)
+	}
+
+	var partitions []int32
+	for _, m := range producer.msgs {
+		partitions = append(partitions, m.Partition)
+	}
+	if exp := []int32{2, 0}; !reflect.DeepEqual(exp, partitions) {
+		t.Errorf("Wrong partitions: %v != %v", partitions, exp)
+	}
+}
+
+func TestKafkaPartitionExpressionBadConfig(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Partitioner = "expression"
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing partition_expression")
+	}
+
+	conf.PartitionExpr = "${!metadata:partition}"
+	conf.Partition = "1"
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from partition and partition_expression")
+	}
+
+	conf = NewKafkaConfig()
+	conf.PartitionExpr = "${!metadata:partition}"
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from partition_expression without expression partitioner")
+	}
+}
+
+func TestKafkaStaticPartition(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Partition = "${!metadata:partition}"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	msg := message.New([][]byte{[]byte("first"), []byte("second")})
+	msg.Get(0).Metadata().Set("partition", "3")
+	msg.Get(1).Metadata().Set("partition", "0")
+
+	if err = k.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 2, len(producer.msgs); exp != act {
+		t.Fatalf("Wrong count of sent messages: %v != %v", act, exp)
+	}
+	for i, exp := range []int32{3, 0} {
+		if act := producer.msgs[i].Partition; exp != act {
+			t.Errorf("Wrong partition of message %v: %v != %v", i, act, exp)
+		}
+	}
+
+	partitioner := k.partitioner("foo")
+	if act, err := partitioner.Partition(&sarama.ProducerMessage{Partition: 5}, 10); err != nil || act != 5 {
+		t.Errorf("Expected manual partitioner: %v, %v", act, err)
+	}
+}
+
+func TestKafkaStaticPartitionInvalid(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Partition = "${!metadata:partition}"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := 0
+	producer := &fakeSyncProducer{
+		fail: func(msg *sarama.ProducerMessage) error {
+			attempts++
+			if msg.Partition >= 3 {
+				return sarama.ErrInvalidPartition
+			}
+			return nil
+		},
+	}
+	k.producer = producer
+
+	msg := message.New([][]byte{[]byte("first")})
+	for _, v := range []string{"", "nope", "-1", "1.5", "5"} {
+		msg.Append(message.NewPart([]byte(v)))
+	}
+	msg.Iter(func(i int, p types.Part) error {
+		if i == 0 {
+			p.Metadata().Set("partition", "1")
+		} else {
+			p.Metadata().Set("partition", string(p.Get()))
+		}
+		return nil
+	})
+
+	err = k.Write(msg)
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, got: %v", err)
+	}
+	var failed []int
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if err != nil {
+			if !types.IsNonRetriable(err) {
+				t.Errorf("Expected non-retriable error of part %v: %v", i, err)
+			}
+			failed = append(failed, i)
+		}
+		return true
+	})
+	if exp := []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(exp, failed) {
+		t.Errorf("Wrong failed parts: %v != %v", failed, exp)
+	}
+	if exp, act := 2, attempts; exp != act {
+		t.Errorf("Wrong count of send attempts: %v != %v", act, exp)
+	}
+	if exp, act := 1, len(producer.msgs); exp != act {
+		t.Errorf("Wrong count of sent messages: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaOrderingKeys(t *testing.T) {
+	newKafka := func(topic, key, partition string) *Kafka {
+		t.Helper()
+		conf := NewKafkaConfig()
+		conf.Topic = topic
+		conf.Key = key
+		conf.Partition = partition
+		k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	msg := message.New([][]byte{[]byte("a"), []byte("b"), []byte("a")})
+	msg.Get(0).Metadata().Set("key", "foo").Set("topic", "t1").Set("partition", "1")
+	msg.Get(1).Metadata().Set("key", "bar").Set("topic", "t2").Set("partition", "2")
+	msg.Get(2).Metadata().Set("key", "foo").Set("topic", "t1").Set("partition", "1")
+
+	if keys := newKafka("foo", "static", "").OrderingKeys(msg); len(keys) != 1 {
+		t.Errorf("Expected a single key from a static key: %v", keys)
+	}
+	keyless := newKafka("foo", "", "").OrderingKeys(msg)
+	if len(keyless) != 1 {
+		t.Errorf("Expected keyless records to share a key: %v", keyless)
+	}
+	if keys := newKafka("foo", "static", "").OrderingKeys(msg); reflect.DeepEqual(keys, keyless) {
+		t.Errorf("Expected different keys for keyed and keyless records: %v", keys)
+	}
+
+	keys := newKafka("foo", "${!metadata:key}", "").OrderingKeys(msg)
+	if len(keys) != 2 {
+		t.Errorf("Expected a key per unique record key: %v", keys)
+	}
+	singleMsg := message.New([][]byte{[]byte("c")})
+	singleMsg.Get(0).Metadata().Set("key", "bar")
+	single := newKafka("foo", "${!metadata:key}", "").OrderingKeys(singleMsg)
+	if len(single) != 1 || (single[0] != keys[0] && single[0] != keys[1]) {
+		t.Errorf("Expected keys to be stable across batches: %v, %v", single, keys)
+	}
+
+	keys = newKafka("${!metadata:topic}", "static", "").OrderingKeys(msg)
+	if len(keys) != 2 {
+		t.Errorf("Expected a key per topic: %v", keys)
+	}
+
+	keys = newKafka("foo", "${!metadata:key}", "${!metadata:partition}").OrderingKeys(msg)
+	if exp := []string{"foo#1", "foo#2"}; !reflect.DeepEqual(exp, keys) {
+		t.Errorf("Wrong partition keys: %v != %v", keys, exp)
+	}
+}
+
+func TestKafkaPartialBatchErrors(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.MaxRetries = 1
+	conf.Backoff.InitialInterval = "1ms"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{
+		fail: func(msg *sarama.ProducerMessage) error {
+			if b, _ := msg.Value.Encode(); string(b) == "bad" {
+				return sarama.ErrNotEnoughReplicas
+			}
+			return nil
+		},
+	}
+	k.producer = producer
+
+	msg := message.New([][]byte{[]byte("good"), []byte("bad"), []byte("good"), []byte("bad")})
+	err = k.Write(msg)
+
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, got: %v", err)
+	}
+	if exp, act := 2, bErr.IndexedErrors(); exp != act {
+		t.Errorf("Wrong count of failed parts: %v != %v", act, exp)
+	}
+	var failed []int
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if err != nil {
+			if err != sarama.ErrNotEnoughReplicas {
+				t.Errorf("Wrong error of part %v: %v", i, err)
+			}
+			failed = append(failed, i)
+		}
+		return true
+	})
+	if exp := []int{1, 3}; !reflect.DeepEqual(exp, failed) {
+		t.Errorf("Wrong failed parts: %v != %v", failed, exp)
+	}
+	if exp, act := 2, len(producer.msgs); exp != act {
+		t.Errorf("Wrong count of sent messages: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaMaxMsgBytesNonRetriable(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.MaxRetries = 0
+	conf.Backoff.InitialInterval = "1ms"
+
+	stats := metrics.NewLocal()
+	k, err := NewKafka(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := 0
+	producer := &fakeSyncProducer{
+		fail: func(msg *sarama.ProducerMessage) error {
+			if b, _ := msg.Value.Encode(); string(b) == "too big" {
+				attempts++
+				return sarama.ErrMessageSizeTooLarge
+			}
+			return nil
+		},
+	}
+	k.producer = producer
+
+	msg := message.New([][]byte{[]byte("good"), []byte("too big")})
+	err = k.Write(msg)
+	if !types.IsNonRetriable(err) {
+		t.Fatalf("Expected non-retriable error, got: %v", err)
+	}
+	if exp, act := 1, attempts; exp != act {
+		t.Errorf("Wrong count of attempts: %v != %v", act, exp)
+	}
+
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, got: %v", err)
+	}
+	if bErr.PartError(0) != nil {
+		t.Errorf("Unexpected error of part 0: %v", bErr.PartError(0))
+	}
+	if pErr := bErr.PartError(1); !types.IsNonRetriable(pErr) {
+		t.Errorf("Expected non-retriable error of part 1, got: %v", pErr)
+	}
+
+	if exp, act := int64(1), stats.GetCounters()["send.dropped.max_msg_bytes"]; exp != act {
+		t.Errorf("Wrong count of dropped messages: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaMaxBatchBytes(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.MaxBatchBytes = 11
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	msg := message.New([][]byte{
+		[]byte("first"),
+		[]byte("second"),
+		[]byte("third"),
+	})
+	if err = k.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 2, producer.sends; exp != act {
+		t.Errorf("Wrong count of sends: %v != %v", act, exp)
+	}
+
+	var act []string
+	for _, m := range producer.msgs {
+		b, _ := m.Value.Encode()
+		act = append(act, string(b))
+	}
+	if exp := []string{"first", "second", "third"}; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong messages sent: %v != %v", act, exp)
+	}
+
+	// A failure within the second send must only fail and retry its own parts.
+	conf.MaxRetries = 1
+	conf.Backoff.InitialInterval = "1ms"
+	conf.Backoff.MaxInterval = "1ms"
+	if k, err = NewKafka(conf, nil, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+	producer = &fakeSyncProducer{
+		fail: func(m *sarama.ProducerMessage) error {
+			if b, _ := m.Value.Encode(); string(b) == "third" {
+				return sarama.ErrOutOfBrokers
+			}
+			return nil
+		},
+	}
+	k.producer = producer
+
+	msg = message.New([][]byte{
+		[]byte("first"),
+		[]byte("second"),
+		[]byte("third"),
+	})
+	err = k.Write(msg)
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, got: %v", err)
+	}
+	for i, expFailed := range []bool{false, false, true} {
+		if actFailed := bErr.PartError(i) != nil; actFailed != expFailed {
+			t.Errorf("Wrong failed state of part %v: %v != %v", i, actFailed, expFailed)
+		}
+	}
+	if exp, act := 3, producer.sends; exp != act {
+		t.Errorf("Wrong count of sends: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaMaxBatchBytesBadConfig(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.MaxBatchBytes = -1
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from negative max_batch_bytes")
+	}
+}
+
+func TestKafkaBatchTopic(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Topic = "${!metadata:topic}"
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	part := message.NewPart([]byte("foo"))
+	part.Metadata().Set("topic", "bar")
+	if exp, act := "bar", k.BatchTopic(part); exp != act {
+		t.Errorf("Wrong topic: %v != %v", act, exp)
+	}
+
+	conf.Topic = "baz"
+	if k, err = NewKafka(conf, nil, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "baz", k.BatchTopic(part); exp != act {
+		t.Errorf("Wrong topic: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaBatchPerTopicBadConfig(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.BatchPerTopic = true
+	conf.MaxTopicBatches = 0
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from zero max_topic_batches")
+	}
+
+	conf.MaxTopicBatches = 10
+	conf.Batching.GroupBy = "${!metadata:foo}"
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from batch_per_topic with group_by")
+	}
+}
+
+func TestKafkaCompressionMetrics(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Topic = "foo.bar"
+	conf.Key = "key"
+	conf.Compression = "gzip"
+	conf.CompressionMetrics = true
+
+	stats := metrics.NewLocal()
+	k, err := NewKafka(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	msg := message.New([][]byte{[]byte("first"), []byte("second")})
+	if err = k.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	counters := stats.GetCountersWithLabels()
+	if exp, act := int64(17), *counters["bytes_uncompressed"].Value; exp != act {
+		t.Errorf("Wrong uncompressed bytes: %v != %v", act, exp)
+	}
+	if stat := counters["bytes_uncompressed"]; !stat.HasLabelWithValue("topic", "foo.bar") {
+		t.Error("Expected topic label on uncompressed bytes")
+	}
+	if exp, act := int64(17), *counters["bytes_sent"].Value; exp != act {
+		t.Errorf("Wrong sent bytes without an observed ratio: %v != %v", act, exp)
+	}
+
+	hist := gometrics.GetOrRegisterHistogram(
+		"compression-ratio-for-topic-foo_bar", k.saramaMetrics,
+		gometrics.NewUniformSample(10),
+	)
+	hist.Update(200)
+
+	if err = k.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	counters = stats.GetCountersWithLabels()
+	if exp, act := int64(34), *counters["bytes_uncompressed"].Value; exp != act {
+		t.Errorf("Wrong uncompressed bytes: %v != %v", act, exp)
+	}
+	if exp, act := int64(25), *counters["bytes_sent"].Value; exp != act {
+		t.Errorf("Wrong sent bytes: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaProducerMetrics(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Topic = "foo"
+	conf.Key = "key"
+	conf.MaxRetries = 1
+	conf.Backoff.InitialInterval = "1ms"
+
+	stats := metrics.NewLocal()
+	k, err := NewKafka(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := false
+	producer := &fakeSyncProducer{
+		fail: func(msg *sarama.ProducerMessage) error {
+			if !failed {
+				failed = true
+				return sarama.ErrNotLeaderForPartition
+			}
+			return nil
+		},
+	}
+	k.producer = producer
+
+	msg := message.New([][]byte{[]byte("first"), []byte("second")})
+	if err = k.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	counters := stats.GetCounters()
+	if exp, act := int64(1), counters["kafka.batch.sent"]; exp != act {
+		t.Errorf("Wrong count of sent batches: %v != %v", act, exp)
+	}
+	if exp, act := int64(17), counters["kafka.batch.bytes"]; exp != act {
+		t.Errorf("Wrong count of sent bytes: %v != %v", act, exp)
+	}
+	if exp, act := int64(1), counters["kafka.send.error"]; exp != act {
+		t.Errorf("Wrong count of send errors: %v != %v", act, exp)
+	}
+	if exp, act := int64(1), counters["kafka.send.retry"]; exp != act {
+		t.Errorf("Wrong count of send retries: %v != %v", act, exp)
+	}
+	if _, exists := stats.GetTimings()["kafka.ack.latency"]; !exists {
+		t.Error("Expected ack latency timing")
+	}
+}
+
+func TestKafkaProducerMetricsRejected(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Topic = "foo"
+	conf.Key = "key"
+
+	stats := metrics.NewLocal()
+	k, err := NewKafka(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.producer = &fakeSyncProducer{
+		fail: func(msg *sarama.ProducerMessage) error {
+			if string(msg.Value.(sarama.ByteEncoder)) != "first" {
+				return sarama.ErrMessageSizeTooLarge
+			}
+			return nil
+		},
+	}
+
+	// Only the records that were acknowledged are counted.
+	msg := message.New([][]byte{[]byte("first"), []byte("second")})
+	if err = k.Write(msg); err == nil {
+		t.Fatal("Expected error from rejected message")
+	}
+	counters := stats.GetCounters()
+	if exp, act := int64(1), counters["kafka.batch.sent"]; exp != act {
+		t.Errorf("Wrong count of sent batches: %v != %v", act, exp)
+	}
+	if exp, act := int64(8), counters["kafka.batch.bytes"]; exp != act {
+		t.Errorf("Wrong count of sent bytes: %v != %v", act, exp)
+	}
+
+	// A batch without any acknowledged records is not counted.
+	msg = message.New([][]byte{[]byte("second")})
+	if err = k.Write(msg); err == nil {
+		t.Fatal("Expected error from rejected message")
+	}
+	counters = stats.GetCounters()
+	if exp, act := int64(1), counters["kafka.batch.sent"]; exp != act {
+		t.Errorf("Wrong count of sent batches: %v != %v", act, exp)
+	}
+	if exp, act := int64(8), counters["kafka.batch.bytes"]; exp != act {
+		t.Errorf("Wrong count of sent bytes: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaWriteWithTargets(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Topic = "${!metadata:topic}-${!count:kafka_write_with_targets}"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	msg := message.New([][]byte{[]byte("first"), []byte("second"), []byte("third")})
+	msg.Get(0).Metadata().Set("topic", "foo")
+	msg.Get(1).Metadata().Set("topic", "bar")
+	msg.Get(2).Metadata().Set("topic", "foo")
+
+	sent, err := k.WriteWithTargets(context.Background(), msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The topic of each message is only resolved once.
+	if exp, act := []string{"bar-2", "foo-1", "foo-3"}, sent.Targets; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong targets: %v != %v", act, exp)
+	}
+	var topics []string
+	bytes := 0
+	for _, m := range producer.msgs {
+		topics = append(topics, m.Topic)
+		bytes += messageSize(m)
+	}
+	if exp := []string{"foo-1", "bar-2", "foo-3"}; !reflect.DeepEqual(exp, topics) {
+		t.Errorf("Wrong topics: %v != %v", topics, exp)
+	}
+	if exp, act := 3, sent.Count; exp != act {
+		t.Errorf("Wrong count: %v != %v", act, exp)
+	}
+	if exp, act := bytes, sent.Bytes; exp != act {
+		t.Errorf("Wrong bytes: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaIdempotentWrite(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.IdempotentWrite = true
+	conf.AckReplicas = true
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k.conf.IdempotentWrite {
+		t.Error("Expected idempotent write to be enabled")
+	}
+}
+
+func TestKafkaAckSettingsBadConfig(t *testing.T) {
+	tests := map[string]struct {
+		fn        func(*KafkaConfig)
+		conflicts []string
+	}{
+		"idempotent no ack replicas": {
+			fn: func(c *KafkaConfig) {
+				c.IdempotentWrite = true
+			},
+			conflicts: []string{"required_acks to be all"},
+		},
+		"idempotent max in flight": {
+			fn: func(c *KafkaConfig) {
+				c.IdempotentWrite = true
+				c.AckReplicas = true
+				c.MaxInFlight = 2
+			},
+			conflicts: []string{"max_in_flight to be 1"},
+		},
+		"idempotent old version": {
+			fn: func(c *KafkaConfig) {
+				c.IdempotentWrite = true
+				c.AckReplicas = true
+				c.TargetVersion = sarama.V0_10_2_0.String()
+			},
+			conflicts: []string{"target_version"},
+		},
+		"idempotent all conflicts": {
+			fn: func(c *KafkaConfig) {
+				c.IdempotentWrite = true
+				c.MaxInFlight = 10
+				c.TargetVersion = sarama.V0_10_2_0.String()
+			},
+			conflicts: []string{"required_acks to be all", "max_in_flight to be 1", "target_version"},
+		},
+		"idempotent local acks": {
+			fn: func(c *KafkaConfig) {
+				c.IdempotentWrite = true
+				c.RequiredAcks = "local"
+			},
+			conflicts: []string{"required_acks to be all"},
+		},
+		"idempotent no acks": {
+			fn: func(c *KafkaConfig) {
+				c.IdempotentWrite = true
+				c.RequiredAcks = "none"
+			},
+			conflicts: []string{"required_acks to be all"},
+		},
+		"ack replicas no acks": {
+			fn: func(c *KafkaConfig) {
+				c.AckReplicas = true
+				c.RequiredAcks = "none"
+			},
+			conflicts: []string{"ack_replicas cannot be combined"},
+		},
+		"offset metadata no acks": {
+			fn: func(c *KafkaConfig) {
+				c.OffsetMetadata = true
+				c.RequiredAcks = "none"
+			},
+			conflicts: []string{"offset_metadata"},
+		},
+		"unknown required acks": {
+			fn: func(c *KafkaConfig) {
+				c.RequiredAcks = "some"
+			},
+			conflicts: []string{"required_acks not recognised"},
+		},
+		"zero max in flight": {
+			fn: func(c *KafkaConfig) {
+				c.MaxInFlight = 0
+			},
+			conflicts: []string{"max_in_flight must be at least 1"},
+		},
+		"zero max in flight with ack replicas": {
+			fn: func(c *KafkaConfig) {
+				c.AckReplicas = true
+				c.MaxInFlight = 0
+			},
+			conflicts: []string{"max_in_flight must be at least 1"},
+		},
+	}
+
+	for name, test := range tests {
+		conf := NewKafkaConfig()
+		test.fn(&conf)
+		_, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+		if err == nil {
+			t.Errorf("%v: Expected error from bad config", name)
+			continue
+		}
+		for _, c := range test.conflicts {
+			if !strings.Contains(err.Error(), c) {
+				t.Errorf("%v: Expected error to contain '%v': %v", name, c, err)
+			}
+		}
+	}
+}
+
+func TestKafkaAckSettingsValid(t *testing.T) {
+	for _, ackReplicas := range []bool{false, true} {
+		for _, maxInFlight := range []int{1, 2, 64} {
+			for _, idempotent := range []bool{false, true} {
+				if idempotent && (!ackReplicas || maxInFlight > 1) {
+					continue
+				}
+				conf := NewKafkaConfig()
+				conf.AckReplicas = ackReplicas
+				conf.MaxInFlight = maxInFlight
+				conf.IdempotentWrite = idempotent
+				if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err != nil {
+					t.Errorf("ack_replicas: %v, max_in_flight: %v, idempotent_write: %v: %v", ackReplicas, maxInFlight, idempotent, err)
+				}
+			}
+		}
+	}
+}
+
+func TestKafkaRequiredAcks(t *testing.T) {
+	tests := []struct {
+		requiredAcks string
+		ackReplicas  bool
+		exp          sarama.RequiredAcks
+	}{
+		{requiredAcks: "none", exp: sarama.NoResponse},
+		{requiredAcks: "local", exp: sarama.WaitForLocal},
+		{requiredAcks: "all", exp: sarama.WaitForAll},
+		{requiredAcks: "local", ackReplicas: true, exp: sarama.WaitForAll},
+		{requiredAcks: "all", ackReplicas: true, exp: sarama.WaitForAll},
+	}
+
+	for _, test := range tests {
+		conf := NewKafkaConfig()
+		conf.RequiredAcks = test.requiredAcks
+		conf.AckReplicas = test.ackReplicas
+
+		k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Errorf("required_acks: %v, ack_replicas: %v: %v", test.requiredAcks, test.ackReplicas, err)
+			continue
+		}
+		config, err := k.saramaConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if act := config.Producer.RequiredAcks; act != test.exp {
+			t.Errorf("required_acks: %v, ack_replicas: %v: wrong acks: %v != %v", test.requiredAcks, test.ackReplicas, act, test.exp)
+		}
+	}
+}
+
+func TestKafkaCompressionLevel(t *testing.T) {
+	for _, level := range []int{-2, -1, 0, 1, 9} {
+		conf := NewKafkaConfig()
+		conf.Compression = "gzip"
+		conf.CompressionLevel = level
+		if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err != nil {
+			t.Errorf("Level %v: %v", level, err)
+		}
+	}
+
+	conf := NewKafkaConfig()
+	conf.Compression = "zstd"
+	conf.TargetVersion = sarama.V2_1_0_0.String()
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := sarama.CompressionZSTD, k.compression; exp != act {
+		t.Errorf("Wrong compression codec: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaCompressionLevelBadConfig(t *testing.T) {
+	tests := map[string]func(*KafkaConfig){
+		"gzip level out of range": func(c *KafkaConfig) {
+			c.Compression = "gzip"
+			c.CompressionLevel = 10
+		},
+		"level without support": func(c *KafkaConfig) {
+			c.Compression = "snappy"
+			c.CompressionLevel = 1
+		},
+		"zstd level": func(c *KafkaConfig) {
+			c.Compression = "zstd"
+			c.TargetVersion = sarama.V2_1_0_0.String()
+			c.CompressionLevel = 1
+		},
+		"zstd old version": func(c *KafkaConfig) {
+			c.Compression = "zstd"
+			c.TargetVersion = sarama.V2_0_0_0.String()
+		},
+	}
+
+	for name, fn := range tests {
+		conf := NewKafkaConfig()
+		fn(&conf)
+		if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("%v: Expected error from bad config", name)
+		}
+	}
+}
+
+func TestKafkaValidateOnStart(t *testing.T) {
+	broker := newMockKafkaBroker(t, "foo")
+	defer broker.Close()
+
+	conf := NewKafkaConfig()
+	conf.Addresses = []string{broker.Addr()}
+	mockKafkaTLS(&conf)
+	conf.Topic = "foo"
+	conf.ValidateOnStart = true
+
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+
+	conf.Topic = "${!content}"
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+
+	conf.Topic = "bar"
+	_, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err == nil {
+		t.Fatal("Expected error from missing topic")
+	}
+	if !strings.Contains(err.Error(), "'bar'") || !strings.Contains(err.Error(), broker.Addr()) {
+		t.Errorf("Expected topic and addresses in error: %v", err)
+	}
+
+	conf.CreateTopics = true
+	if _, err = NewKafka(conf, nil, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKafkaValidateOnStartUnreachable(t *testing.T) {
+	broker := newMockKafkaBroker(t, "foo")
+	addr := broker.Addr()
+	broker.Close()
+
+	conf := NewKafkaConfig()
+	conf.Addresses = []string{addr}
+	conf.Topic = "foo"
+
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+
+	conf.ValidateOnStart = true
+	_, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err == nil {
+		t.Fatal("Expected error from unreachable brokers")
+	}
+	if !strings.Contains(err.Error(), addr) {
+		t.Errorf("Expected addresses in error: %v", err)
+	}
+}
+
+func TestKafkaAddressExpansion(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Addresses = []string{"foo:9092, bar:9093", " baz:9094 ", "[::1]:9095,"}
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{"foo:9092", "bar:9093", "baz:9094", "[::1]:9095"}
+	if act := k.addresses; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong addresses: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaAddressValidation(t *testing.T) {
+	tests := map[string]struct {
+		addresses []string
+		errStr    string
+	}{
+		"missing port": {
+			addresses: []string{"foo:9092,bar"},
+			errStr:    "addresses must be of the form host:port, invalid addresses: 'bar'",
+		},
+		"bad ports": {
+			addresses: []string{"foo:nope", "bar:9092, baz:99999"},
+			errStr:    "addresses must be of the form host:port, invalid addresses: 'foo:nope', 'baz:99999'",
+		},
+		"missing host": {
+			addresses: []string{":9092"},
+			errStr:    "addresses must be of the form host:port, invalid addresses: ':9092'",
+		},
+		"empty": {
+			addresses: []string{" , "},
+			errStr:    "at least one address must be specified",
+		},
+	}
+
+	for name, test := range tests {
+		conf := NewKafkaConfig()
+		conf.Addresses = test.addresses
+		_, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+		if err == nil {
+			t.Errorf("%v: Expected error", name)
+			continue
+		}
+		if exp, act := test.errStr, err.Error(); exp != act {
+			t.Errorf("%v: Wrong error: %v != %v", name, act, exp)
+		}
+	}
+}
+
+func TestKafkaKeyFromField(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Key = "${!metadata:fallback}"
+	conf.KeyFromField = "user.id"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	msg := message.New([][]byte{
+		[]byte(`{"user":{"id":"foo"}}`),
+		[]byte(`{"user":{"id":10}}`),
+		[]byte(`{"user":{"id":true}}`),
+		[]byte(`{"user":{"name":"bar"}}`),
+		[]byte(`not json`),
+	})
+	msg.Iter(func(i int, p types.Part) error {
+		p.Metadata().Set("fallback", "fallback"+strconv.Itoa(i))
+		return nil
+	})
+
+	if err = k.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{"foo", "10", "true", "fallback3", "fallback4"}
+	if exp, act := len(exp), len(producer.msgs); exp != act {
+		t.Fatalf("Wrong count of sent messages: %v != %v", act, exp)
+	}
+	for i, m := range producer.msgs {
+		key, err := m.Key.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if act := string(key); exp[i] != act {
+			t.Errorf("Wrong key of message %v: %v != %v", i, act, exp[i])
+		}
+	}
+}
+
+func TestKafkaKeyFromMetadata(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Key = "fallback"
+	conf.KeyFromMetadata = "key"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	binKey := string([]byte{0x00, 0xff, 0xfe, '$', '{', '!'})
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	msg.Get(0).Metadata().Set("key", binKey)
+
+	if err = k.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := [][]byte{[]byte(binKey), []byte("fallback")}
+	if exp, act := len(exp), len(producer.msgs); exp != act {
+		t.Fatalf("Wrong count of sent messages: %v != %v", act, exp)
+	}
+	for i, m := range producer.msgs {
+		key, err := m.Key.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(exp[i], key) {
+			t.Errorf("Wrong key of message %v: %v != %v", i, key, exp[i])
+		}
+	}
+}
+
+func TestKafkaKeyFromContent(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.KeyFromContent = true
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	binKey := []byte{0x00, 0xff, 0xfe}
+	if err = k.Write(message.New([][]byte{binKey})); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 1, len(producer.msgs); exp != act {
+		t.Fatalf("Wrong count of sent messages: %v != %v", act, exp)
+	}
+	key, err := producer.msgs[0].Key.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(binKey, key) {
+		t.Errorf("Wrong key: %v != %v", key, binKey)
+	}
+
+	conf.KeyFromMetadata = "foo"
+	if _, err = NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from multiple key sources")
+	}
+}
+
+func TestKafkaKeyFromFieldNonScalar(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.KeyFromField = "user"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	err = k.Write(message.New([][]byte{[]byte(`{"user":{"id":"foo"}}`)}))
+	if err == nil {
+		t.Fatal("Expected error from non-scalar field")
+	}
+	if len(producer.msgs) > 0 {
+		t.Errorf("Messages were sent: %v", producer.msgs)
+	}
+}
+
+func TestKafkaReconnectTimeout(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Addresses = []string{"127.0.0.1:1"}
+	conf.Backoff.InitialInterval = "1ms"
+	conf.Backoff.MaxElapsedTime = "1ns"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = k.ConnectWithContext(context.Background()); err != types.ErrTimeout {
+		t.Errorf("Expected timeout error, got: %v", err)
+	}
+}
+
+func TestKafkaReconnectBackoff(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Addresses = []string{"127.0.0.1:1"}
+	conf.Backoff.InitialInterval = "1ms"
+	conf.Backoff.MaxElapsedTime = "1m"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = k.ConnectWithContext(context.Background()); err == nil || err == types.ErrTimeout {
+		t.Errorf("Expected connection error, got: %v", err)
+	}
+}
+
+func newInterpolationErrorMsg() types.Message {
+	return message.New([][]byte{
+		[]byte(`{"topic":"foo","id":"a"}`),
+		[]byte(`not json`),
+		[]byte(`{"topic":"bar","id":"b"}`),
+	})
+}
+
+func TestKafkaInterpolationErrorFallback(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Topic = "${!json_field:topic}"
+	conf.Key = "${!json_field:id}"
+
+	stats := metrics.NewLocal()
+	k, err := NewKafka(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	if err = k.Write(newInterpolationErrorMsg()); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{"foo", "null", "bar"}
+	if exp, act := len(exp), len(producer.msgs); exp != act {
+		t.Fatalf("Wrong count of sent messages: %v != %v", act, exp)
+	}
+	for i, m := range producer.msgs {
+		if act := m.Topic; exp[i] != act {
+			t.Errorf("Wrong topic of message %v: %v != %v", i, act, exp[i])
+		}
+	}
+	if exp, act := int64(1), stats.GetCounters()["send.error.interpolation"]; exp != act {
+		t.Errorf("Wrong count of interpolation errors: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaInterpolationErrorDrop(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Topic = "${!json_field:topic}"
+	conf.OnInterpolationError = "drop"
+
+	stats := metrics.NewLocal()
+	k, err := NewKafka(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	if err = k.Write(newInterpolationErrorMsg()); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{"foo", "bar"}
+	if exp, act := len(exp), len(producer.msgs); exp != act {
+		t.Fatalf("Wrong count of sent messages: %v != %v", act, exp)
+	}
+	for i, m := range producer.msgs {
+		if act := m.Topic; exp[i] != act {
+			t.Errorf("Wrong topic of message %v: %v != %v", i, act, exp[i])
+		}
+	}
+	if exp, act := int64(1), stats.GetCounters()["send.dropped.interpolation"]; exp != act {
+		t.Errorf("Wrong count of dropped messages: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaInterpolationErrorError(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Key = "${!json_field:id}"
+	conf.OnInterpolationError = "error"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	err = k.Write(newInterpolationErrorMsg())
+	if !types.IsNonRetriable(err) {
+		t.Fatalf("Expected non-retriable error, got: %v", err)
+	}
+	if exp, act := 2, len(producer.msgs); exp != act {
+		t.Errorf("Wrong count of sent messages: %v != %v", act, exp)
+	}
+
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, got: %v", err)
+	}
+	for i, expFailed := range []bool{false, true, false} {
+		if actFailed := bErr.PartError(i) != nil; expFailed != actFailed {
+			t.Errorf("Wrong failed state of part %v: %v != %v", i, actFailed, expFailed)
+		}
+	}
+}
+
+func TestKafkaInterpolationErrorBadPolicy(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.OnInterpolationError = "nope"
+
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad policy")
+	}
+}
+
+func newInvalidUTF8Msg() types.Message {
+	msg := message.New([][]byte{[]byte("first"), []byte("second"), []byte("third")})
+	msg.Get(0).Metadata().Set("topic", "foo")
+	msg.Get(1).Metadata().Set("topic", "b\xffa\x01r")
+	msg.Get(2).Metadata().Set("topic", "baz")
+	msg.Get(2).Metadata().Set("key", "k\xc3\x28ey")
+	return msg
+}
+
+func TestKafkaValidateUTF8Error(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Topic = "${!metadata:topic}"
+	conf.Key = "${!metadata:key}"
+	conf.ValidateUTF8 = true
+	conf.OnInvalid = "error"
+
+	stats := metrics.NewLocal()
+	k, err := NewKafka(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	err = k.Write(newInvalidUTF8Msg())
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, got: %v", err)
+	}
+	if bErr.PartError(0) != nil {
+		t.Errorf("Unexpected error of part 0: %v", bErr.PartError(0))
+	}
+	for _, i := range []int{1, 2} {
+		if pErr := bErr.PartError(i); !types.IsNonRetriable(pErr) {
+			t.Errorf("Expected non-retriable error of part %v, got: %v", i, pErr)
+		}
+	}
+	if exp, act := 1, len(producer.msgs); exp != act {
+		t.Errorf("Wrong count of sent messages: %v != %v", act, exp)
+	}
+	if exp, act := int64(2), stats.GetCounters()["send.error.invalid_utf8"]; exp != act {
+		t.Errorf("Wrong count of invalid messages: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaValidateUTF8Drop(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Topic = "${!metadata:topic}"
+	conf.Key = "${!metadata:key}"
+	conf.ValidateUTF8 = true
+	conf.OnInvalid = "drop"
+
+	stats := metrics.NewLocal()
+	k, err := NewKafka(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	if err = k.Write(newInvalidUTF8Msg()); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 1, len(producer.msgs); exp != act {
+		t.Fatalf("Wrong count of sent messages: %v != %v", act, exp)
+	}
+	if exp, act := "foo", producer.msgs[0].Topic; exp != act {
+		t.Errorf("Wrong topic: %v != %v", act, exp)
+	}
+	if exp, act := int64(2), stats.GetCounters()["send.dropped.invalid_utf8"]; exp != act {
+		t.Errorf("Wrong count of dropped messages: %v != %v", act, exp)
+	}
+}
+
+func TestKafkaValidateUTF8Sanitize(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Topic = "${!metadata:topic}"
+	conf.Key = "${!metadata:key}"
+	conf.ValidateUTF8 = true
+	conf.OnInvalid = "sanitize"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	if err = k.Write(newInvalidUTF8Msg()); err != nil {
+		t.Fatal(err)
+	}
+
+	expTopics := []string{"foo", "bar", "baz"}
+	expKeys := []string{"", "", "k(ey"}
+	if exp, act := len(expTopics), len(producer.msgs); exp != act {
+		t.Fatalf("Wrong count of sent messages: %v != %v", act, exp)
+	}
+	for i, m := range producer.msgs {
+		if act := m.Topic; expTopics[i] != act {
+			t.Errorf("Wrong topic of message %v: %v != %v", i, act, expTopics[i])
+		}
+		var key []byte
+		if m.Key != nil {
+			key, _ = m.Key.Encode()
+		}
+		if act := string(key); expKeys[i] != act {
+			t.Errorf("Wrong key of message %v: %q != %q", i, act, expKeys[i])
+		}
+	}
+}
+
+func TestKafkaValidateUTF8BadPolicy(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.OnInvalid = "nope"
+	if _, err := NewKafka(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad policy")
+	}
+}
+
+func TestKafkaTopicErrorsNonRetriable(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Topic = "${!metadata:topic}"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := map[string]int{}
+	producer := &fakeSyncProducer{
+		fail: func(msg *sarama.ProducerMessage) error {
+			attempts[msg.Topic]++
+			switch msg.Topic {
+			case "secret":
+				return sarama.ErrTopicAuthorizationFailed
+			case "missing":
+				return sarama.ErrUnknownTopicOrPartition
+			}
+			return nil
+		},
+	}
+	k.producer = producer
+
+	msg := message.New([][]byte{[]byte("first"), []byte("second"), []byte("third")})
+	msg.Get(0).Metadata().Set("topic", "secret")
+	msg.Get(1).Metadata().Set("topic", "missing")
+	msg.Get(2).Metadata().Set("topic", "public")
+
+	err = k.Write(msg)
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, got: %v", err)
+	}
+	if !types.IsNonRetriable(err) {
+		t.Errorf("Expected non-retriable error: %v", err)
+	}
+	expErrs := []error{types.ErrTopicAuthorization, types.ErrTopicNotFound, nil}
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if expErrs[i] == nil {
+			if err != nil {
+				t.Errorf("Unexpected error of part %v: %v", i, err)
+			}
+		} else if !errors.Is(err, expErrs[i]) {
+			t.Errorf("Wrong error of part %v: %v != %v", i, err, expErrs[i])
+		}
+		return true
+	})
+	if exp := map[string]int{"secret": 1, "missing": 1, "public": 1}; !reflect.DeepEqual(exp, attempts) {
+		t.Errorf("Wrong send attempts: %v != %v", attempts, exp)
+	}
+}
+
+func TestKafkaAuthErrorDisconnects(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.MaxRetries = 3
+	conf.Backoff.InitialInterval = "1ms"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := 0
+	k.producer = &fakeSyncProducer{
+		fail: func(msg *sarama.ProducerMessage) error {
+			attempts++
+			return sarama.ErrSASLAuthenticationFailed
+		},
+	}
+
+	if err = k.Write(message.New([][]byte{[]byte("foo")})); err != types.ErrNotConnected {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrNotConnected)
+	}
+	if exp, act := 1, attempts; exp != act {
+		t.Errorf("Wrong count of attempts: %v != %v", act, exp)
+	}
+
+	// The producer is left for Connect to close, and further writes are
+	// rejected until then.
+	if k.producer == nil {
+		t.Error("Expected producer to be left open until reconnecting")
+	}
+	if err = k.Write(message.New([][]byte{[]byte("bar")})); err != types.ErrNotConnected {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrNotConnected)
+	}
+	if exp, act := 1, attempts; exp != act {
+		t.Errorf("Wrong count of attempts: %v != %v", act, exp)
+	}
+}
+
+// authSyncProducer fails sends with an authentication error when failAuth is
+// set, and reports sends made after it was closed or whilst it is closed.
+type authSyncProducer struct {
+	t        *testing.T
+	failAuth bool
+
+	mut    sync.Mutex
+	active int
+	sent   int
+	closed int
+}
+
+func (a *authSyncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	return 0, 0, a.SendMessages([]*sarama.ProducerMessage{msg})
+}
+
+func (a *authSyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	a.mut.Lock()
+	if a.closed > 0 {
+		a.t.Error("Send on closed producer")
+	}
+	a.active++
+	a.mut.Unlock()
+
+	// Give other writes the chance to be in flight at the same time.
+	<-time.After(time.Millisecond * 10)
+
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	a.active--
+	if a.failAuth {
+		var pErrs sarama.ProducerErrors
+		for _, msg := range msgs {
+			pErrs = append(pErrs, &sarama.ProducerError{Msg: msg, Err: sarama.ErrSASLAuthenticationFailed})
+		}
+		return pErrs
+	}
+	a.sent += len(msgs)
+	return nil
+}
+
+func (a *authSyncProducer) Close() error {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	if a.active > 0 {
+		a.t.Errorf("Producer closed with %v sends in flight", a.active)
+	}
+	a.closed++
+	return nil
+}
+
+// rebuildMgr shares a single producer at a time, creating a fresh one with
+// newProducer once the current one is invalidated.
+type rebuildMgr struct {
+	types.DudMgr
+	newProducer func() *authSyncProducer
+
+	mut         sync.Mutex
+	current     *authSyncProducer
+	holders     map[io.Closer]int
+	invalidated int
+}
+
+func (r *rebuildMgr) AcquireShared(key string, ctor func() (io.Closer, error)) (io.Closer, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if r.current == nil {
+		r.current = r.newProducer()
+	}
+	r.holders[r.current]++
+	return r.current, nil
+}
+
+func (r *rebuildMgr) ReleaseShared(key string, res io.Closer) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if r.holders[res]--; r.holders[res] > 0 {
+		return nil
+	}
+	if res == io.Closer(r.current) {
+		r.current = nil
+	}
+	return res.Close()
+}
+
+func (r *rebuildMgr) InvalidateShared(key string, res io.Closer) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if res == io.Closer(r.current) {
+		r.current = nil
+		r.invalidated++
+	}
+	return nil
+}
+
+func TestKafkaAuthErrorConcurrentWrites(t *testing.T) {
+	var producers []*authSyncProducer
+	mgr := &rebuildMgr{
+		newProducer: func() *authSyncProducer {
+			// Only the first producer has a stale credential.
+			p := &authSyncProducer{t: t, failAuth: len(producers) == 0}
+			producers = append(producers, p)
+			return p
+		},
+		holders: map[io.Closer]int{},
+	}
+
+	conf := NewKafkaConfig()
+	conf.ShareConnection = true
+
+	var writers []*Kafka
+	for i := 0; i < 2; i++ {
+		k, err := NewKafka(conf, mgr, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = k.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		writers = append(writers, k)
+	}
+
+	// Writes are retried after reconnecting on ErrNotConnected, as the async
+	// writer does.
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(k *Kafka) {
+			defer wg.Done()
+			for attempt := 0; attempt < 10; attempt++ {
+				err := k.Write(message.New([][]byte{[]byte("foo")}))
+				if err != types.ErrNotConnected {
+					if err != nil {
+						t.Error(err)
+					}
+					return
+				}
+				if err = k.Connect(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+			t.Error("Write did not succeed after reconnecting")
+		}(writers[i%2])
+	}
+	wg.Wait()
+
+	if exp, act := 2, len(producers); exp != act {
+		t.Fatalf("Wrong count of producers: %v != %v", act, exp)
+	}
+	if exp, act := 1, mgr.invalidated; exp != act {
+		t.Errorf("Wrong count of invalidations: %v != %v", act, exp)
+	}
+	if exp, act := 1, producers[0].closed; exp != act {
+		t.Errorf("Wrong count of stale producer closes: %v != %v", act, exp)
+	}
+	if exp, act := 8, producers[1].sent; exp != act {
+		t.Errorf("Wrong count of messages sent: %v != %v", act, exp)
+	}
+
+	for _, k := range writers {
+		k.CloseAsync()
+		if err := k.WaitForClose(time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if exp, act := 1, producers[1].closed; exp != act {
+		t.Errorf("Wrong count of fresh producer closes: %v != %v", act, exp)
+	}
+}
+
+// retryAuthSyncProducer fails sends of records with the value "retry" with a
+// retriable error and of records with the value "auth" with an authentication
+// error.
+type retryAuthSyncProducer struct {
+	mut     sync.Mutex
+	retries int
+	closed  bool
+}
+
+func (r *retryAuthSyncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	return 0, 0, r.SendMessages([]*sarama.ProducerMessage{msg})
+}
+
+func (r *retryAuthSyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	var pErrs sarama.ProducerErrors
+	for _, msg := range msgs {
+		value, _ := msg.Value.Encode()
+		switch string(value) {
+		case "retry":
+			r.mut.Lock()
+			r.retries++
+			r.mut.Unlock()
+			pErrs = append(pErrs, &sarama.ProducerError{Msg: msg, Err: sarama.ErrNotEnoughReplicas})
+		case "auth":
+			pErrs = append(pErrs, &sarama.ProducerError{Msg: msg, Err: sarama.ErrSASLAuthenticationFailed})
+		}
+	}
+	if len(pErrs) > 0 {
+		return pErrs
+	}
+	return nil
+}
+
+func (r *retryAuthSyncProducer) Close() error {
+	r.mut.Lock()
+	r.closed = true
+	r.mut.Unlock()
+	return nil
+}
+
+func TestKafkaAuthErrorDuringRetry(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.MaxRetries = 0
+	conf.Backoff.InitialInterval = "50ms"
+	conf.Backoff.MaxInterval = "50ms"
+	conf.Backoff.MaxElapsedTime = "0s"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &retryAuthSyncProducer{}
+	k.producer = producer
+
+	retryErr := make(chan error, 1)
+	go func() {
+		retryErr <- k.Write(message.New([][]byte{[]byte("retry")}))
+	}()
+
+	// Wait for the retrying write to begin its backoff.
+	<-time.After(time.Millisecond * 20)
+	if err = k.Write(message.New([][]byte{[]byte("auth")})); err != types.ErrNotConnected {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrNotConnected)
+	}
+
+	// Connect waits for the retrying write without blocking it. The new
+	// producer cannot connect to a broker, and therefore only the closing of
+	// the old producer is checked.
+	connectErr := make(chan error, 1)
+	go func() {
+		connectErr <- k.Connect()
+	}()
+
+	select {
+	case err = <-retryErr:
+		if err != types.ErrNotConnected {
+			t.Errorf("Wrong error returned: %v != %v", err, types.ErrNotConnected)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for retrying write")
+	}
+	select {
+	case <-connectErr:
+	case <-time.After(time.Second * 30):
+		t.Fatal("Timed out waiting for connect")
+	}
+
+	producer.mut.Lock()
+	defer producer.mut.Unlock()
+	if !producer.closed {
+		t.Error("Expected old producer to be closed")
+	}
+	if exp, act := 1, producer.retries; exp != act {
+		t.Errorf("Wrong count of sends of retrying write: %v != %v", act, exp)
+	}
+}
+
+// gatedSyncProducer blocks sends of records with the value "gated" until the
+// gate is closed and fails sends of records with the value "bad".
+type gatedSyncProducer struct {
+	gate chan struct{}
+
+	mut    sync.Mutex
+	msgs   []*sarama.ProducerMessage
+	closed bool
+}
+
+func (g *gatedSyncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	return 0, 0, g.SendMessages([]*sarama.ProducerMessage{msg})
+}
+
+func (g *gatedSyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	var pErrs sarama.ProducerErrors
+	for _, msg := range msgs {
+		value, _ := msg.Value.Encode()
+		switch string(value) {
+		case "gated":
+			<-g.gate
+		case "bad":
+			pErrs = append(pErrs, &sarama.ProducerError{Msg: msg, Err: sarama.ErrNotEnoughReplicas})
+			continue
+		}
+		g.mut.Lock()
+		g.msgs = append(g.msgs, msg)
+		g.mut.Unlock()
+	}
+	if len(pErrs) > 0 {
+		return pErrs
+	}
+	return nil
+}
+
+func (g *gatedSyncProducer) Close() error {
+	g.mut.Lock()
+	g.closed = true
+	g.mut.Unlock()
+	return nil
+}
+
+func TestKafkaCloseDrainsInFlight(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.Backoff.InitialInterval = "1h"
+	conf.Backoff.MaxInterval = "1h"
+	conf.Backoff.MaxElapsedTime = "0s"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &gatedSyncProducer{gate: make(chan struct{})}
+	k.producer = producer
+
+	gatedErr := make(chan error, 1)
+	go func() {
+		gatedErr <- k.Write(message.New([][]byte{[]byte("gated")}))
+	}()
+	badErr := make(chan error, 1)
+	go func() {
+		badErr <- k.Write(message.New([][]byte{[]byte("bad")}))
+	}()
+	if err = k.Write(message.New([][]byte{[]byte("good")})); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for the failed batch to begin its backoff.
+	<-time.After(time.Millisecond * 50)
+	k.CloseAsync()
+
+	select {
+	case err = <-badErr:
+		if _, ok := err.(*batch.Error); !ok {
+			t.Errorf("Expected batch error from retrying batch, got: %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out waiting for retrying batch to be rejected")
+	}
+	if err = k.Write(message.New([][]byte{[]byte("late")})); err != types.ErrTypeClosed {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrTypeClosed)
+	}
+
+	if err = k.WaitForClose(time.Millisecond * 50); err != types.ErrTimeout {
+		t.Errorf("Expected timeout while batch is in flight: %v", err)
+	}
+	producer.mut.Lock()
+	closed := producer.closed
+	producer.mut.Unlock()
+	if closed {
+		t.Error("Producer closed while batch is in flight")
+	}
+
+	close(producer.gate)
+	if err = <-gatedErr; err != nil {
+		t.Errorf("Expected in flight batch to be sent: %v", err)
+	}
+	if err = k.WaitForClose(time.Second * 5); err != nil {
+		t.Fatal(err)
+	}
+
+	producer.mut.Lock()
+	defer producer.mut.Unlock()
+	if !producer.closed {
+		t.Error("Expected producer to be closed")
+	}
+	var sent []string
+	for _, m := range producer.msgs {
+		value, _ := m.Value.Encode()
+		sent = append(sent, string(value))
+	}
+	if exp := []string{"good", "gated"}; !reflect.DeepEqual(exp, sent) {
+		t.Errorf("Wrong sent messages: %v != %v", sent, exp)
+	}
+}
+
+func TestKafkaMaxRetriesStops(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.MaxRetries = 3
+	conf.Backoff.InitialInterval = "1ms"
+	conf.Backoff.MaxInterval = "1ms"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := 0
+	k.producer = &fakeSyncProducer{
+		fail: func(msg *sarama.ProducerMessage) error {
+			attempts++
+			return errors.New("attempt " + strconv.Itoa(attempts))
+		},
+	}
+
+	err = k.Write(message.New([][]byte{[]byte("foo")}))
+	bErr, ok := err.(*batch.Error)
+	if !ok {
+		t.Fatalf("Expected batch error, got: %v", err)
+	}
+	if exp, act := 4, attempts; exp != act {
+		t.Errorf("Wrong count of attempts: %v != %v", act, exp)
+	}
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if exp := "attempt 4"; err == nil || err.Error() != exp {
+			t.Errorf("Wrong error of part %v: %v != %v", i, err, exp)
+		}
+		return true
+	})
+}
+
+func TestKafkaMaxRetriesZeroRetriesForever(t *testing.T) {
+	conf := NewKafkaConfig()
+	conf.MaxRetries = 0
+	conf.Backoff.InitialInterval = "1us"
+	conf.Backoff.MaxInterval = "1us"
+	conf.Backoff.MaxElapsedTime = "0s"
+
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := 0
+	producer := &fakeSyncProducer{
+		fail: func(msg *sarama.ProducerMessage) error {
+			if attempts++; attempts <= 1000 {
+				return sarama.ErrNotLeaderForPartition
+			}
+			return nil
+		},
+	}
+	k.producer = producer
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- k.Write(message.New([][]byte{[]byte("foo")}))
+	}()
+	select {
+	case err = <-errChan:
+	case <-time.After(time.Second * 10):
+		t.Fatal("Timed out waiting for retries")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 1, len(producer.msgs); exp != act {
+		t.Errorf("Wrong count of sent messages: %v != %v", act, exp)
+	}
+}
+
+func benchmarkKafkaWrite(b *testing.B, conf KafkaConfig) {
+	k, err := NewKafka(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		b.Fatal(err)
+	}
+	producer := &fakeSyncProducer{}
+	k.producer = producer
+
+	parts := make([][]byte, 100)
+	for i := range parts {
+		parts[i] = []byte(`{"id":"foo","content":"hello world"}`)
+	}
+	msg := message.New(parts)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		producer.msgs = nil
+		if err = k.Write(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkKafkaWriteStaticKey(b *testing.B) {
+	conf := NewKafkaConfig()
+	conf.Key = "foo"
+	conf.Topic = "bar"
+	benchmarkKafkaWrite(b, conf)
+}
+
+func BenchmarkKafkaWriteDynamicKey(b *testing.B) {
+	conf := NewKafkaConfig()
+	conf.Key = "${!json_field:id}"
+	conf.Topic = "bar"
+	benchmarkKafkaWrite(b, conf)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	bredis "github.com/Jeffail/benthos/v3/lib/util/redis"
 	"github.com/Jeffail/benthos/v3/lib/util/text"
 	"github.com/go-redis/redis"
 )
@@ -26,6 +27,8 @@ type RedisHashConfig struct {
 	WalkJSONObject bool              `json:"walk_json_object" yaml:"walk_json_object"`
 	Fields         map[string]string `json:"fields" yaml:"fields"`
 	MaxInFlight    int               `json:"max_in_flight" yaml:"max_in_flight"`
+
+	bredis.PoolConfig `json:",inline" yaml:",inline"`
 }
 
 // NewRedisHashConfig creates a new RedisHashConfig with default values.
@@ -37,6 +40,8 @@ func NewRedisHashConfig() RedisHashConfig {
 		WalkJSONObject: false,
 		Fields:         map[string]string{},
 		MaxInFlight:    1,
+
+		PoolConfig: bredis.NewPoolConfig(),
 	}
 }
 
@@ -85,6 +90,9 @@ func NewRedisHash(
 	if err != nil {
 		return nil, err
 	}
+	if err = conf.PoolConfig.Validate(); err != nil {
+		return nil, err
+	}
 
 	return r, nil
 }
@@ -101,15 +109,10 @@ func (r *RedisHash) Connect() error {
 	r.connMut.Lock()
 	defer r.connMut.Unlock()
 
-	var pass string
-	if r.url.User != nil {
-		pass, _ = r.url.User.Password()
+	client, err := r.conf.PoolConfig.Client(r.url)
+	if err != nil {
+		return err
 	}
-	client := redis.NewClient(&redis.Options{
-		Addr:     r.url.Host,
-		Network:  r.url.Scheme,
-		Password: pass,
-	})
 
 	if _, err := client.Ping().Result(); err != nil {
 		return err
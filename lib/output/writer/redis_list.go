@@ -9,6 +9,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	bredis "github.com/Jeffail/benthos/v3/lib/util/redis"
 	"github.com/go-redis/redis"
 )
 
@@ -19,6 +20,8 @@ type RedisListConfig struct {
 	URL         string `json:"url" yaml:"url"`
 	Key         string `json:"key" yaml:"key"`
 	MaxInFlight int    `json:"max_in_flight" yaml:"max_in_flight"`
+
+	bredis.PoolConfig `json:",inline" yaml:",inline"`
 }
 
 // NewRedisListConfig creates a new RedisListConfig with default values.
@@ -27,6 +30,8 @@ func NewRedisListConfig() RedisListConfig {
 		URL:         "tcp://localhost:6379",
 		Key:         "benthos_list",
 		MaxInFlight: 1,
+
+		PoolConfig: bredis.NewPoolConfig(),
 	}
 }
 
@@ -62,6 +67,9 @@ func NewRedisList(
 	if err != nil {
 		return nil, err
 	}
+	if err = conf.PoolConfig.Validate(); err != nil {
+		return nil, err
+	}
 
 	return r, nil
 }
@@ -78,15 +86,10 @@ func (r *RedisList) Connect() error {
 	r.connMut.Lock()
 	defer r.connMut.Unlock()
 
-	var pass string
-	if r.url.User != nil {
-		pass, _ = r.url.User.Password()
+	client, err := r.conf.PoolConfig.Client(r.url)
+	if err != nil {
+		return err
 	}
-	client := redis.NewClient(&redis.Options{
-		Addr:     r.url.Host,
-		Network:  r.url.Scheme,
-		Password: pass,
-	})
 
 	if _, err := client.Ping().Result(); err != nil {
 		return err
@@ -10,6 +10,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	bredis "github.com/Jeffail/benthos/v3/lib/util/redis"
 	"github.com/Jeffail/benthos/v3/lib/util/text"
 	"github.com/go-redis/redis"
 )
@@ -22,6 +23,8 @@ type RedisPubSubConfig struct {
 	URL         string `json:"url" yaml:"url"`
 	Channel     string `json:"channel" yaml:"channel"`
 	MaxInFlight int    `json:"max_in_flight" yaml:"max_in_flight"`
+
+	bredis.PoolConfig `json:",inline" yaml:",inline"`
 }
 
 // NewRedisPubSubConfig creates a new RedisPubSubConfig with default values.
@@ -30,6 +33,8 @@ func NewRedisPubSubConfig() RedisPubSubConfig {
 		URL:         "tcp://localhost:6379",
 		Channel:     "benthos_chan",
 		MaxInFlight: 1,
+
+		PoolConfig: bredis.NewPoolConfig(),
 	}
 }
 
@@ -66,6 +71,9 @@ func NewRedisPubSub(
 	if err != nil {
 		return nil, err
 	}
+	if err = conf.PoolConfig.Validate(); err != nil {
+		return nil, err
+	}
 
 	return r, nil
 }
@@ -82,15 +90,10 @@ func (r *RedisPubSub) Connect() error {
 	r.connMut.Lock()
 	defer r.connMut.Unlock()
 
-	var pass string
-	if r.url.User != nil {
-		pass, _ = r.url.User.Password()
+	client, err := r.conf.PoolConfig.Client(r.url)
+	if err != nil {
+		return err
 	}
-	client := redis.NewClient(&redis.Options{
-		Addr:     r.url.Host,
-		Network:  r.url.Scheme,
-		Password: pass,
-	})
 
 	if _, err := client.Ping().Result(); err != nil {
 		return err
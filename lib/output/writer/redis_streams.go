@@ -9,6 +9,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	bredis "github.com/Jeffail/benthos/v3/lib/util/redis"
 	"github.com/go-redis/redis"
 )
 
@@ -21,6 +22,8 @@ type RedisStreamsConfig struct {
 	BodyKey      string `json:"body_key" yaml:"body_key"`
 	MaxLenApprox int64  `json:"max_length" yaml:"max_length"`
 	MaxInFlight  int    `json:"max_in_flight" yaml:"max_in_flight"`
+
+	bredis.PoolConfig `json:",inline" yaml:",inline"`
 }
 
 // NewRedisStreamsConfig creates a new RedisStreamsConfig with default values.
@@ -31,6 +34,8 @@ func NewRedisStreamsConfig() RedisStreamsConfig {
 		BodyKey:      "body",
 		MaxLenApprox: 0,
 		MaxInFlight:  1,
+
+		PoolConfig: bredis.NewPoolConfig(),
 	}
 }
 
@@ -66,6 +71,9 @@ func NewRedisStreams(
 	if err != nil {
 		return nil, err
 	}
+	if err = conf.PoolConfig.Validate(); err != nil {
+		return nil, err
+	}
 
 	return r, nil
 }
@@ -82,15 +90,10 @@ func (r *RedisStreams) Connect() error {
 	r.connMut.Lock()
 	defer r.connMut.Unlock()
 
-	var pass string
-	if r.url.User != nil {
-		pass, _ = r.url.User.Password()
+	client, err := r.conf.PoolConfig.Client(r.url)
+	if err != nil {
+		return err
 	}
-	client := redis.NewClient(&redis.Options{
-		Addr:     r.url.Host,
-		Network:  r.url.Scheme,
-		Password: pass,
-	})
 
 	if _, err := client.Ping().Result(); err != nil {
 		return err
@@ -0,0 +1,357 @@
+//go:build SNOWFLAKE
+// +build SNOWFLAKE
+
+package writer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message/batch"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	sess "github.com/Jeffail/benthos/v3/lib/util/aws/session"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/snowflakedb/gosnowflake"
+)
+
+//------------------------------------------------------------------------------
+
+// SnowflakeConfig contains configuration fields for the Snowflake output type.
+type SnowflakeConfig struct {
+	Account        string `json:"account" yaml:"account"`
+	User           string `json:"user" yaml:"user"`
+	Password       string `json:"password" yaml:"password"`
+	PrivateKeyFile string `json:"private_key_file" yaml:"private_key_file"`
+	Role           string `json:"role" yaml:"role"`
+	Warehouse      string `json:"warehouse" yaml:"warehouse"`
+	Database       string `json:"database" yaml:"database"`
+	Schema         string `json:"schema" yaml:"schema"`
+	Table          string `json:"table" yaml:"table"`
+	Stage          string `json:"stage" yaml:"stage"`
+	StageBucket    string `json:"stage_bucket" yaml:"stage_bucket"`
+	StagePrefix    string `json:"stage_prefix" yaml:"stage_prefix"`
+	sess.Config    `json:",inline" yaml:",inline"`
+	Path           string             `json:"path" yaml:"path"`
+	FileFormat     string             `json:"file_format" yaml:"file_format"`
+	Timeout        string             `json:"timeout" yaml:"timeout"`
+	MaxInFlight    int                `json:"max_in_flight" yaml:"max_in_flight"`
+	Batching       batch.PolicyConfig `json:"batching" yaml:"batching"`
+}
+
+// NewSnowflakeConfig creates a new SnowflakeConfig with default values.
+func NewSnowflakeConfig() *SnowflakeConfig {
+	return &SnowflakeConfig{
+		Account:        "",
+		User:           "",
+		Password:       "",
+		PrivateKeyFile: "",
+		Role:           "",
+		Warehouse:      "",
+		Database:       "",
+		Schema:         "",
+		Table:          "",
+		Stage:          "",
+		StageBucket:    "",
+		StagePrefix:    "",
+		Config:         sess.NewConfig(),
+		Path:           "${!count:files}-${!timestamp_unix_nano}.json",
+		FileFormat:     "TYPE = JSON",
+		Timeout:        "30s",
+		MaxInFlight:    1,
+		Batching:       batch.NewPolicyConfig(),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Snowflake is a benthos writer.Type implementation that writes each batch of
+// messages as a file to an external Snowflake stage and then loads it into a
+// table with a COPY INTO statement.
+type Snowflake struct {
+	conf *SnowflakeConfig
+
+	path       *text.InterpolatedString
+	privateKey *rsa.PrivateKey
+	timeout    time.Duration
+
+	db       *sql.DB
+	uploader s3manageriface.UploaderAPI
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewSnowflake creates a new Snowflake writer.Type.
+func NewSnowflake(
+	conf *SnowflakeConfig,
+	log log.Modular,
+	stats metrics.Type,
+) (*Snowflake, error) {
+	if len(conf.Account) == 0 {
+		return nil, errors.New("an account must be specified")
+	}
+	if len(conf.User) == 0 {
+		return nil, errors.New("a user must be specified")
+	}
+	if len(conf.Password) > 0 && len(conf.PrivateKeyFile) > 0 {
+		return nil, errors.New("only one of password and private_key_file can be specified")
+	}
+	if len(conf.Password) == 0 && len(conf.PrivateKeyFile) == 0 {
+		return nil, errors.New("either a password or a private_key_file must be specified")
+	}
+	if len(conf.Table) == 0 {
+		return nil, errors.New("a table must be specified")
+	}
+	if len(conf.Stage) == 0 {
+		return nil, errors.New("a stage must be specified")
+	}
+	if len(conf.StageBucket) == 0 {
+		return nil, errors.New("a stage_bucket must be specified")
+	}
+
+	s := &Snowflake{
+		conf:  conf,
+		path:  text.NewInterpolatedString(conf.Path),
+		log:   log,
+		stats: stats,
+	}
+	if len(conf.PrivateKeyFile) > 0 {
+		var err error
+		if s.privateKey, err = readSnowflakePrivateKey(conf.PrivateKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if s.timeout, err = time.ParseDuration(conf.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout period string: %v", err)
+	}
+	if s.timeout <= 0 {
+		return nil, errors.New("timeout must be greater than zero")
+	}
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
+
+// readSnowflakePrivateKey reads an unencrypted RSA private key from a PEM file
+// in either PKCS#1 or PKCS#8 form.
+func readSnowflakePrivateKey(path string) (*rsa.PrivateKey, error) {
+	keyBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key file: %v", err)
+	}
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, errors.New("failed to decode private key file: no PEM data found")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		if x509.IsEncryptedPEMBlock(block) {
+			return nil, errors.New("encrypted private keys are not supported")
+		}
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %v", err)
+		}
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("private key is not an RSA key")
+		}
+		return rsaKey, nil
+	}
+	return nil, fmt.Errorf("unsupported private key type: %v", block.Type)
+}
+
+// stageKey returns the object key within the stage bucket of a file path
+// relative to the stage.
+func (s *Snowflake) stageKey(path string) string {
+	prefix := strings.TrimSuffix(s.conf.StagePrefix, "/")
+	if len(prefix) == 0 {
+		return path
+	}
+	return prefix + "/" + path
+}
+
+// copyStatement returns a COPY INTO statement that loads a single file from
+// the stage into the target table.
+func (s *Snowflake) copyStatement(path string) string {
+	stage := s.conf.Stage
+	if !strings.HasPrefix(stage, "@") {
+		stage = "@" + stage
+	}
+	return fmt.Sprintf(
+		"COPY INTO %v FROM %v FILES = ('%v') FILE_FORMAT = (%v) ON_ERROR = SKIP_FILE",
+		s.conf.Table, stage, strings.Replace(path, "'", "''", -1), s.conf.FileFormat,
+	)
+}
+
+// copyResultError returns an error if a row of the results of a COPY INTO
+// statement reports that a file could not be loaded. The error is
+// non-retriable since the contents of the file will not change.
+func copyResultError(columns []string, values []sql.NullString) error {
+	var file, status, firstError string
+	for i, c := range columns {
+		if i >= len(values) {
+			break
+		}
+		switch strings.ToLower(c) {
+		case "file":
+			file = values[i].String
+		case "status":
+			status = values[i].String
+		case "first_error":
+			firstError = values[i].String
+		}
+	}
+	if len(file) == 0 || status == "LOADED" {
+		// Files that have already been loaded are skipped with a status row
+		// that does not identify a file.
+		return nil
+	}
+	return types.NonRetriableError{
+		Err: fmt.Errorf("failed to load file '%v' with status %v: %v", file, status, firstError),
+	}
+}
+
+// load executes a COPY INTO statement for a staged file and checks the load
+// status of the file.
+func (s *Snowflake) load(ctx context.Context, path string) error {
+	rows, err := s.db.QueryContext(ctx, s.copyStatement(path))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dests := make([]interface{}, len(columns))
+		for i := range values {
+			dests[i] = &values[i]
+		}
+		if err = rows.Scan(dests...); err != nil {
+			return err
+		}
+		if err = copyResultError(columns, values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+//------------------------------------------------------------------------------
+
+// ConnectWithContext attempts to establish a connection to Snowflake and the
+// S3 bucket of the stage.
+func (s *Snowflake) ConnectWithContext(ctx context.Context) error {
+	if s.db != nil {
+		return nil
+	}
+
+	awsSess, err := s.conf.GetSession()
+	if err != nil {
+		return err
+	}
+
+	sfConf := gosnowflake.Config{
+		Account:   s.conf.Account,
+		User:      s.conf.User,
+		Password:  s.conf.Password,
+		Database:  s.conf.Database,
+		Schema:    s.conf.Schema,
+		Warehouse: s.conf.Warehouse,
+		Role:      s.conf.Role,
+	}
+	if s.privateKey != nil {
+		sfConf.Authenticator = gosnowflake.AuthTypeJwt
+		sfConf.PrivateKey = s.privateKey
+	}
+	db := sql.OpenDB(gosnowflake.NewConnector(gosnowflake.SnowflakeDriver{}, sfConf))
+
+	pctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	if err = db.PingContext(pctx); err != nil {
+		db.Close()
+		return err
+	}
+
+	s.db = db
+	s.uploader = s3manager.NewUploader(awsSess)
+
+	s.log.Infof("Loading messages into Snowflake table '%v' via stage '%v'\n", s.conf.Table, s.conf.Stage)
+	return nil
+}
+
+// Connect attempts to establish a connection to Snowflake and the S3 bucket
+// of the stage.
+func (s *Snowflake) Connect() error {
+	return s.ConnectWithContext(context.Background())
+}
+
+// WriteWithContext attempts to write a batch of messages as a file to the
+// stage and load it into the target table.
+func (s *Snowflake) WriteWithContext(wctx context.Context, msg types.Message) error {
+	if s.db == nil {
+		return types.ErrNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(wctx, s.timeout)
+	defer cancel()
+
+	var buf bytes.Buffer
+	msg.Iter(func(i int, p types.Part) error {
+		buf.Write(p.Get())
+		buf.WriteByte('\n')
+		return nil
+	})
+
+	path := s.path.Get(msg)
+	if _, err := s.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(s.conf.StageBucket),
+		Key:    aws.String(s.stageKey(path)),
+		Body:   bytes.NewReader(buf.Bytes()),
+	}); err != nil {
+		return fmt.Errorf("failed to stage file: %v", err)
+	}
+	return s.load(ctx, path)
+}
+
+// Write attempts to write a batch of messages as a file to the stage and load
+// it into the target table.
+func (s *Snowflake) Write(msg types.Message) error {
+	return s.WriteWithContext(context.Background(), msg)
+}
+
+// CloseAsync begins cleaning up resources used by this writer asynchronously.
+func (s *Snowflake) CloseAsync() {
+	if s.db != nil {
+		s.db.Close()
+	}
+}
+
+// WaitForClose will block until either the writer is closed or a specified
+// timeout occurs.
+func (s *Snowflake) WaitForClose(time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,16 @@
+//go:build !SNOWFLAKE
+// +build !SNOWFLAKE
+
+package writer
+
+//------------------------------------------------------------------------------
+
+// SnowflakeConfig empty stub for when Snowflake is not compiled.
+type SnowflakeConfig struct{}
+
+// NewSnowflakeConfig returns nil.
+func NewSnowflakeConfig() *SnowflakeConfig {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,176 @@
+//go:build SNOWFLAKE
+// +build SNOWFLAKE
+
+package writer
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+func testSnowflakeConfig() *SnowflakeConfig {
+	conf := NewSnowflakeConfig()
+	conf.Account = "foo"
+	conf.User = "bar"
+	conf.Password = "baz"
+	conf.Table = "qux"
+	conf.Stage = "quz"
+	conf.StageBucket = "buz"
+	return conf
+}
+
+func TestSnowflakeConfigErrors(t *testing.T) {
+	tests := map[string]func(c *SnowflakeConfig){
+		"no account":   func(c *SnowflakeConfig) { c.Account = "" },
+		"no user":      func(c *SnowflakeConfig) { c.User = "" },
+		"no auth":      func(c *SnowflakeConfig) { c.Password = "" },
+		"both auths":   func(c *SnowflakeConfig) { c.PrivateKeyFile = "foo.pem" },
+		"no table":     func(c *SnowflakeConfig) { c.Table = "" },
+		"no stage":     func(c *SnowflakeConfig) { c.Stage = "" },
+		"no bucket":    func(c *SnowflakeConfig) { c.StageBucket = "" },
+		"bad timeout":  func(c *SnowflakeConfig) { c.Timeout = "nope" },
+		"no timeout":   func(c *SnowflakeConfig) { c.Timeout = "" },
+		"zero timeout": func(c *SnowflakeConfig) { c.Timeout = "0s" },
+		"bad key file": func(c *SnowflakeConfig) {
+			c.Password = ""
+			c.PrivateKeyFile = "/does/not/exist.pem"
+		},
+	}
+
+	for name, fn := range tests {
+		conf := testSnowflakeConfig()
+		fn(conf)
+		if _, err := NewSnowflake(conf, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+
+	if _, err := NewSnowflake(testSnowflakeConfig(), log.Noop(), metrics.Noop()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSnowflakePrivateKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_snowflake_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8Bytes, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := map[string]*pem.Block{
+		"pkcs1.pem": {Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		"pkcs8.pem": {Type: "PRIVATE KEY", Bytes: pkcs8Bytes},
+	}
+	for name, block := range blocks {
+		path := filepath.Join(dir, name)
+		if err = ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		conf := testSnowflakeConfig()
+		conf.Password = ""
+		conf.PrivateKeyFile = path
+
+		s, err := NewSnowflake(conf, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if s.privateKey == nil || s.privateKey.N.Cmp(key.N) != 0 {
+			t.Errorf("%v: wrong private key", name)
+		}
+	}
+
+	badPath := filepath.Join(dir, "bad.pem")
+	if err = ioutil.WriteFile(badPath, pem.EncodeToMemory(&pem.Block{
+		Type: "EC PRIVATE KEY", Bytes: []byte("foo"),
+	}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = readSnowflakePrivateKey(badPath); err == nil {
+		t.Error("expected error from unsupported key type")
+	}
+}
+
+func TestSnowflakeStatements(t *testing.T) {
+	conf := testSnowflakeConfig()
+	conf.StagePrefix = "foo/bar/"
+
+	s, err := NewSnowflake(conf, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, act := "foo/bar/baz.json", s.stageKey("baz.json"); exp != act {
+		t.Errorf("Wrong stage key: %v != %v", act, exp)
+	}
+
+	exp := "COPY INTO qux FROM @quz FILES = ('it''s.json') FILE_FORMAT = (TYPE = JSON) ON_ERROR = SKIP_FILE"
+	if act := s.copyStatement("it's.json"); exp != act {
+		t.Errorf("Wrong statement: %v != %v", act, exp)
+	}
+
+	conf.Stage = "@quz"
+	conf.StagePrefix = ""
+	if s, err = NewSnowflake(conf, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "baz.json", s.stageKey("baz.json"); exp != act {
+		t.Errorf("Wrong stage key: %v != %v", act, exp)
+	}
+	exp = "COPY INTO qux FROM @quz FILES = ('baz.json') FILE_FORMAT = (TYPE = JSON) ON_ERROR = SKIP_FILE"
+	if act := s.copyStatement("baz.json"); exp != act {
+		t.Errorf("Wrong statement: %v != %v", act, exp)
+	}
+}
+
+func TestSnowflakeCopyResultError(t *testing.T) {
+	str := func(s string) sql.NullString {
+		return sql.NullString{String: s, Valid: true}
+	}
+	columns := []string{"file", "status", "rows_parsed", "rows_loaded", "first_error"}
+
+	if err := copyResultError(columns, []sql.NullString{
+		str("s3://foo/bar.json"), str("LOADED"), str("2"), str("2"), {},
+	}); err != nil {
+		t.Error(err)
+	}
+
+	if err := copyResultError([]string{"status"}, []sql.NullString{
+		str("Copy executed with 0 files processed."),
+	}); err != nil {
+		t.Error(err)
+	}
+
+	err := copyResultError(columns, []sql.NullString{
+		str("s3://foo/bar.json"), str("LOAD_FAILED"), str("2"), str("0"), str("Error parsing JSON"),
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !types.IsNonRetriable(err) {
+		t.Errorf("expected non-retriable error: %v", err)
+	}
+	if exp, act := "failed to load file 's3://foo/bar.json' with status LOAD_FAILED: Error parsing JSON", err.Error(); exp != act {
+		t.Errorf("Wrong error: %v != %v", act, exp)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message/msgid"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/processor"
 	"github.com/Jeffail/benthos/v3/lib/types"
@@ -17,19 +18,30 @@ import (
 // threads specified. Processors are executed on each message in the order that
 // they are written.
 //
+// When MessageID is enabled each message part is assigned a unique ID within
+// its metadata before the processors are executed.
+//
+// When AffinityKey is set with more than one thread each batch is processed by
+// a thread chosen by hashing the key, preserving the order of batches that
+// share a key.
+//
 // In order to fully utilise each processing thread you must either have a
 // number of parallel inputs that matches or surpasses the number of pipeline
 // threads, or use a memory buffer.
 type Config struct {
-	Threads    int                `json:"threads" yaml:"threads"`
-	Processors []processor.Config `json:"processors" yaml:"processors"`
+	Threads     int                `json:"threads" yaml:"threads"`
+	AffinityKey string             `json:"affinity_key" yaml:"affinity_key"`
+	MessageID   msgid.Config       `json:"message_id" yaml:"message_id"`
+	Processors  []processor.Config `json:"processors" yaml:"processors"`
 }
 
 // NewConfig returns a configuration struct fully populated with default values.
 func NewConfig() Config {
 	return Config{
-		Threads:    1,
-		Processors: []processor.Config{},
+		Threads:     1,
+		AffinityKey: "",
+		MessageID:   msgid.NewConfig(),
+		Processors:  []processor.Config{},
 	}
 }
 
@@ -56,6 +68,12 @@ func SanitiseConfig(conf Config) (interface{}, error) {
 		procSlice = append(procSlice, procSanitised)
 	}
 	hashMap["processors"] = procSlice
+	if len(conf.AffinityKey) == 0 {
+		delete(hashMap, "affinity_key")
+	}
+	if !conf.MessageID.Enabled {
+		delete(hashMap, "message_id")
+	}
 
 	return hashMap, nil
 }
@@ -70,6 +88,15 @@ func New(
 	stats metrics.Type,
 	processorCtors ...types.ProcessorConstructorFunc,
 ) (Type, error) {
+	var idProc types.Processor
+	if conf.MessageID.Enabled {
+		assigner, err := msgid.New(conf.MessageID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create message ID generator: %v", err)
+		}
+		idProc = &messageIDProcessor{assigner: assigner}
+	}
+
 	procs := 0
 	procCtor := func(i *int) (types.Pipeline, error) {
 		processors := make([]types.Processor, len(conf.Processors)+len(processorCtors))
@@ -89,12 +116,19 @@ func New(
 				return nil, fmt.Errorf("failed to create processor: %v", err)
 			}
 		}
+		if idProc != nil {
+			processors = append([]types.Processor{idProc}, processors...)
+		}
 		return NewProcessor(log, stats, processors...), nil
 	}
 	if conf.Threads <= 1 {
 		return procCtor(&procs)
 	}
-	return NewPool(procCtor, conf.Threads, log, stats)
+	var poolOpts []func(*Pool)
+	if len(conf.AffinityKey) > 0 {
+		poolOpts = append(poolOpts, OptPoolSetAffinityKey(conf.AffinityKey))
+	}
+	return NewPool(procCtor, conf.Threads, log, stats, poolOpts...)
 }
 
 //------------------------------------------------------------------------------
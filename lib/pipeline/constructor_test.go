@@ -161,3 +161,82 @@ func TestProcCtor(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestMessageID(t *testing.T) {
+	conf := NewConfig()
+	conf.MessageID.Enabled = true
+
+	pipe, err := New(
+		conf, nil,
+		log.New(os.Stdout, log.Config{LogLevel: "NONE"}),
+		metrics.DudType{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	if err = pipe.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	msg.Get(1).Metadata().Set("benthos_message_id", "existing")
+
+	select {
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	case tChan <- types.NewTransaction(msg, resChan):
+	}
+
+	var tran types.Transaction
+	select {
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	case tran = <-pipe.TransactionChan():
+	}
+
+	if id := tran.Payload.Get(0).Metadata().Get("benthos_message_id"); len(id) == 0 {
+		t.Error("Expected message ID to be assigned")
+	}
+	if exp, act := "existing", tran.Payload.Get(1).Metadata().Get("benthos_message_id"); exp != act {
+		t.Errorf("Existing message ID was replaced: %v != %v", act, exp)
+	}
+	if id := msg.Get(0).Metadata().Get("benthos_message_id"); len(id) > 0 {
+		t.Error("Original message was modified")
+	}
+
+	go func() {
+		select {
+		case <-time.After(time.Second):
+			t.Error("timed out")
+		case tran.ResponseChan <- response.NewAck():
+		}
+	}()
+
+	select {
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	}
+
+	pipe.CloseAsync()
+	if err = pipe.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMessageIDBadGenerator(t *testing.T) {
+	conf := NewConfig()
+	conf.MessageID.Enabled = true
+	conf.MessageID.Generator = "nope"
+
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad generator")
+	}
+}
@@ -0,0 +1,33 @@
+package pipeline
+
+import (
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/message/msgid"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// messageIDProcessor is a processor that assigns IDs to message parts that do
+// not already have one, and is placed before all configured processors.
+type messageIDProcessor struct {
+	assigner *msgid.Assigner
+}
+
+// ProcessMessage adds IDs to the parts of a copy of a message.
+func (m *messageIDProcessor) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	newMsg := msg.Copy()
+	m.assigner.Assign(newMsg)
+	return []types.Message{newMsg}, nil
+}
+
+// CloseAsync does nothing.
+func (m *messageIDProcessor) CloseAsync() {}
+
+// WaitForClose does nothing.
+func (m *messageIDProcessor) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -1,12 +1,15 @@
 package pipeline
 
 import (
+	"hash/fnv"
 	"sync/atomic"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
 )
 
 //------------------------------------------------------------------------------
@@ -17,7 +20,8 @@ import (
 type Pool struct {
 	running uint32
 
-	workers []types.Pipeline
+	workers     []types.Pipeline
+	affinityKey *text.InterpolatedString
 
 	log   log.Modular
 	stats metrics.Type
@@ -35,6 +39,7 @@ func NewPool(
 	threads int,
 	log log.Modular,
 	stats metrics.Type,
+	opts ...func(*Pool),
 ) (*Pool, error) {
 	p := &Pool{
 		running:     1,
@@ -45,6 +50,9 @@ func NewPool(
 		closeChan:   make(chan struct{}),
 		closed:      make(chan struct{}),
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
 
 	for i := range p.workers {
 		procs := 0
@@ -57,6 +65,55 @@ func NewPool(
 	return p, nil
 }
 
+// OptPoolSetAffinityKey sets an interpolated key that is resolved from the first
+// message part of each batch, batches that resolve to the same key are always
+// processed by the same worker in the order that they arrive.
+func OptPoolSetAffinityKey(key string) func(*Pool) {
+	return func(p *Pool) {
+		p.affinityKey = text.NewInterpolatedString(key)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// workerFor returns the index of the worker that a batch should be dispatched
+// to according to the hash of its affinity key.
+func (p *Pool) workerFor(msg types.Message) int {
+	if msg.Len() == 0 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(p.affinityKey.Get(message.Lock(msg, 0))))
+	return int(h.Sum32() % uint32(len(p.workers)))
+}
+
+// dispatch routes transactions from the input channel to the worker chosen by
+// their affinity key, and closes the worker channels once the input closes.
+func (p *Pool) dispatch(workerChans []chan types.Transaction) {
+	defer func() {
+		for _, c := range workerChans {
+			close(c)
+		}
+	}()
+	for {
+		var t types.Transaction
+		var open bool
+		select {
+		case t, open = <-p.messagesIn:
+			if !open {
+				return
+			}
+		case <-p.closeChan:
+			return
+		}
+		select {
+		case workerChans[p.workerFor(t.Payload)] <- t:
+		case <-p.closeChan:
+			return
+		}
+	}
+}
+
 //------------------------------------------------------------------------------
 
 // loop is the processing loop of this pipeline.
@@ -85,8 +142,22 @@ func (p *Pool) loop() {
 	internalMessages := make(chan types.Transaction)
 	remainingWorkers := int64(len(p.workers))
 
-	for _, worker := range p.workers {
-		if err := worker.Consume(p.messagesIn); err != nil {
+	workerChans := make([]<-chan types.Transaction, len(p.workers))
+	if p.affinityKey != nil {
+		dispatchChans := make([]chan types.Transaction, len(p.workers))
+		for i := range dispatchChans {
+			dispatchChans[i] = make(chan types.Transaction)
+			workerChans[i] = dispatchChans[i]
+		}
+		go p.dispatch(dispatchChans)
+	} else {
+		for i := range workerChans {
+			workerChans[i] = p.messagesIn
+		}
+	}
+
+	for i, worker := range p.workers {
+		if err := worker.Consume(workerChans[i]); err != nil {
 			p.log.Errorf("Failed to start pipeline worker: %v\n", err)
 			atomic.AddInt64(&remainingWorkers, -1)
 			continue
@@ -324,3 +324,98 @@ func TestPoolMultiNaturalClose(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+type workerTagProcessor struct {
+	worker string
+}
+
+func (w *workerTagProcessor) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	newMsg := msg.Copy()
+	newMsg.Iter(func(i int, p types.Part) error {
+		p.Metadata().Set("worker", w.worker)
+		return nil
+	})
+	return []types.Message{newMsg}, nil
+}
+
+func (w *workerTagProcessor) CloseAsync() {}
+
+func (w *workerTagProcessor) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+func TestPoolAffinityKey(t *testing.T) {
+	workers := 0
+	constr := func(i *int) (types.Pipeline, error) {
+		proc := &workerTagProcessor{worker: fmt.Sprintf("%v", workers)}
+		workers++
+		return NewProcessor(log.Noop(), metrics.Noop(), proc), nil
+	}
+
+	proc, err := NewPool(
+		constr, 4, log.Noop(), metrics.Noop(),
+		OptPoolSetAffinityKey("${!metadata:key}"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tChan, resChan := make(chan types.Transaction), make(chan types.Response)
+	if err := proc.Consume(tChan); err != nil {
+		t.Fatal(err)
+	}
+
+	keyWorkers := map[string]string{}
+	usedWorkers := map[string]struct{}{}
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%v", i%10)
+
+		msg := message.New([][]byte{[]byte(fmt.Sprintf("msg%v", i))})
+		msg.Get(0).Metadata().Set("key", key)
+
+		select {
+		case tChan <- types.NewTransaction(msg, resChan):
+		case <-time.After(time.Second * 5):
+			t.Fatal("Timed out")
+		}
+
+		var procT types.Transaction
+		select {
+		case procT = <-proc.TransactionChan():
+		case <-time.After(time.Second * 5):
+			t.Fatal("Timed out")
+		}
+
+		worker := procT.Payload.Get(0).Metadata().Get("worker")
+		if exp, exists := keyWorkers[key]; exists && exp != worker {
+			t.Errorf("Key %v processed by worker %v and %v", key, exp, worker)
+		}
+		keyWorkers[key] = worker
+		usedWorkers[worker] = struct{}{}
+
+		go func() {
+			select {
+			case procT.ResponseChan <- response.NewAck():
+			case <-time.After(time.Second * 5):
+				t.Error("Timed out")
+			}
+		}()
+		select {
+		case res := <-resChan:
+			if res.Error() != nil {
+				t.Error(res.Error())
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("Timed out")
+		}
+	}
+
+	if len(usedWorkers) < 2 {
+		t.Errorf("Expected keys to be spread across workers: %v", keyWorkers)
+	}
+
+	proc.CloseAsync()
+	if err := proc.WaitForClose(time.Second * 5); err != nil {
+		t.Error(err)
+	}
+}
@@ -21,7 +21,7 @@ EXPERIMENTAL: This processor is considered experimental and is therefore subject
 to change outside of major version releases.
 
 Performs Avro based operations on messages based on a schema. Supported encoding
-types are textual, binary and single.
+types are textual, binary, single and schema_registry.
 
 ### Operators
 
@@ -34,7 +34,43 @@ specifies how the source documents are encoded.
 #### ` + "`from_json`" + `
 
 Attempts to convert JSON documents into Avro documents according to the
-specified encoding.`,
+specified encoding.
+
+### Schema Registry
+
+The ` + "`schema_registry`" + ` encoding uses the wire format of the Confluent
+schema registry, where each binary encoded document is prefixed with a zero
+magic byte followed by the four byte big endian ID of its schema. This is the
+format expected by Kafka consumers that use a schema registry deserializer, and
+therefore this processor can be used within the ` + "`processors`" + ` of a
+` + "`kafka`" + ` output in order to encode documents as they are sent:
+
+` + "``` yaml" + `
+output:
+  kafka:
+    addresses: [ localhost:9092 ]
+    topic: users
+  processors:
+  - avro:
+      operator: from_json
+      encoding: schema_registry
+      schema_registry_url: http://localhost:8081
+      subject: users-value
+` + "```" + `
+
+When converting from JSON, if the field ` + "`schema`" + ` is set the schema is
+registered under the ` + "`subject`" + `, which returns the ID of the existing
+schema when it has already been registered, otherwise the latest schema of the
+subject is used. When converting to JSON the schema of each document is fetched
+by the ID within its header and ` + "`subject`" + ` is not required.
+
+Schemas are fetched from the registry once and cached for the lifetime of the
+processor, and therefore changes to the latest schema of a subject are not
+observed until the processor is restarted. Failed registry requests are not
+cached and are attempted again for the next message. Documents that are not
+valid for the schema fail with an error that includes the schema ID and
+subject, and can be handled with
+[processor error handling](/docs/configuration/error_handling).`,
 	}
 }
 
@@ -46,6 +82,9 @@ type AvroConfig struct {
 	Operator string `json:"operator" yaml:"operator"`
 	Encoding string `json:"encoding" yaml:"encoding"`
 	Schema   string `json:"schema" yaml:"schema"`
+
+	SchemaRegistryURL string `json:"schema_registry_url" yaml:"schema_registry_url"`
+	Subject           string `json:"subject" yaml:"subject"`
 }
 
 // NewAvroConfig returns a AvroConfig with default values.
@@ -55,6 +94,9 @@ func NewAvroConfig() AvroConfig {
 		Operator: "to_json",
 		Encoding: "textual",
 		Schema:   "",
+
+		SchemaRegistryURL: "",
+		Subject:           "",
 	}
 }
 
@@ -189,6 +231,14 @@ func NewAvro(
 		mBatchSent: stats.GetCounter("batch.sent"),
 	}
 
+	if conf.Avro.Encoding == "schema_registry" {
+		var err error
+		if a.operator, err = newAvroSchemaRegistryOperator(conf.Avro); err != nil {
+			return nil, err
+		}
+		return a, nil
+	}
+
 	codec, err := goavro.NewCodec(conf.Avro.Schema)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse schema: %v", err)
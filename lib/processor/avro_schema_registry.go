@@ -0,0 +1,242 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/linkedin/goavro/v2"
+)
+
+//------------------------------------------------------------------------------
+
+// schemaRegistryMagicByte is the first byte of each message framed in the
+// wire format of the Confluent schema registry, followed by a four byte big
+// endian schema ID and the binary encoded document.
+const schemaRegistryMagicByte = 0
+
+// schemaRegistryHeaderLen is the length of the header of a framed message.
+const schemaRegistryHeaderLen = 5
+
+// schemaRegistryTimeout is the maximum period to wait for a response from the
+// schema registry.
+const schemaRegistryTimeout = time.Second * 10
+
+type schemaRegistrySchema struct {
+	id    int
+	codec *goavro.Codec
+}
+
+// schemaRegistryClient obtains schemas from a Confluent compatible schema
+// registry and caches them, so that the registry is only consulted once per
+// subject and schema ID.
+type schemaRegistryClient struct {
+	url     *url.URL
+	subject string
+	schema  string
+	client  *http.Client
+
+	mut        sync.Mutex
+	subjectSch *schemaRegistrySchema
+	idCodecs   map[int]*goavro.Codec
+}
+
+func newSchemaRegistryClient(urlStr, subject, schema string, timeout time.Duration) (*schemaRegistryClient, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema_registry_url: %v", err)
+	}
+	if len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return nil, fmt.Errorf("schema_registry_url must be an absolute URL: %v", urlStr)
+	}
+	return &schemaRegistryClient{
+		url:      u,
+		subject:  subject,
+		schema:   schema,
+		client:   &http.Client{Timeout: timeout},
+		idCodecs: map[int]*goavro.Codec{},
+	}, nil
+}
+
+func (c *schemaRegistryClient) do(method string, path string, body interface{}, resObj interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	reqURL := *c.url
+	reqURL.Path = strings.TrimSuffix(reqURL.Path, "/") + path
+
+	req, err := http.NewRequest(method, reqURL.String(), bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("schema registry request failed: %v", err)
+	}
+	defer res.Body.Close()
+
+	resBytes, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read schema registry response: %v", err)
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("schema registry returned status %v: %s", res.StatusCode, bytes.TrimSpace(resBytes))
+	}
+	if err = json.Unmarshal(resBytes, resObj); err != nil {
+		return fmt.Errorf("failed to parse schema registry response: %v", err)
+	}
+	return nil
+}
+
+// subjectSchema returns the schema used to encode documents, which is either
+// the configured schema registered under the subject or, when a schema is not
+// configured, the latest schema of the subject. Failed lookups are not cached
+// and are therefore attempted again by the next document.
+func (c *schemaRegistryClient) subjectSchema() (*schemaRegistrySchema, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.subjectSch != nil {
+		return c.subjectSch, nil
+	}
+
+	var res struct {
+		ID     int    `json:"id"`
+		Schema string `json:"schema"`
+	}
+	schema := c.schema
+	subjectPath := "/subjects/" + url.PathEscape(c.subject) + "/versions"
+	if len(schema) > 0 {
+		if err := c.do("POST", subjectPath, map[string]string{"schema": schema}, &res); err != nil {
+			return nil, fmt.Errorf("failed to register schema of subject '%v': %v", c.subject, err)
+		}
+	} else {
+		if err := c.do("GET", subjectPath+"/latest", nil, &res); err != nil {
+			return nil, fmt.Errorf("failed to fetch latest schema of subject '%v': %v", c.subject, err)
+		}
+		schema = res.Schema
+	}
+
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema of subject '%v': %v", c.subject, err)
+	}
+	c.subjectSch = &schemaRegistrySchema{id: res.ID, codec: codec}
+	c.idCodecs[res.ID] = codec
+	return c.subjectSch, nil
+}
+
+// codecByID returns the codec of a schema by its ID.
+func (c *schemaRegistryClient) codecByID(id int) (*goavro.Codec, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if codec, exists := c.idCodecs[id]; exists {
+		return codec, nil
+	}
+
+	var res struct {
+		Schema string `json:"schema"`
+	}
+	if err := c.do("GET", "/schemas/ids/"+strconv.Itoa(id), nil, &res); err != nil {
+		return nil, fmt.Errorf("failed to fetch schema %v: %v", id, err)
+	}
+	codec, err := goavro.NewCodec(res.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema %v: %v", id, err)
+	}
+	c.idCodecs[id] = codec
+	return codec, nil
+}
+
+//------------------------------------------------------------------------------
+
+func newAvroSchemaRegistryOperator(conf AvroConfig) (avroOperator, error) {
+	if len(conf.SchemaRegistryURL) == 0 {
+		return nil, errors.New("encoding schema_registry requires a schema_registry_url")
+	}
+	if len(conf.Schema) > 0 {
+		if _, err := goavro.NewCodec(conf.Schema); err != nil {
+			return nil, fmt.Errorf("failed to parse schema: %v", err)
+		}
+	}
+	client, err := newSchemaRegistryClient(conf.SchemaRegistryURL, conf.Subject, conf.Schema, schemaRegistryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	switch conf.Operator {
+	case "to_json":
+		return newAvroSchemaRegistryToJSONOperator(client), nil
+	case "from_json":
+		if len(conf.Subject) == 0 {
+			return nil, errors.New("operator from_json with encoding schema_registry requires a subject")
+		}
+		return newAvroSchemaRegistryFromJSONOperator(client), nil
+	}
+	return nil, fmt.Errorf("operator not recognised: %v", conf.Operator)
+}
+
+func newAvroSchemaRegistryToJSONOperator(client *schemaRegistryClient) avroOperator {
+	return func(part types.Part) error {
+		b := part.Get()
+		if len(b) < schemaRegistryHeaderLen || b[0] != schemaRegistryMagicByte {
+			return errors.New("message is not framed with a schema registry header")
+		}
+		id := int(binary.BigEndian.Uint32(b[1:schemaRegistryHeaderLen]))
+		codec, err := client.codecByID(id)
+		if err != nil {
+			return err
+		}
+		jObj, _, err := codec.NativeFromBinary(b[schemaRegistryHeaderLen:])
+		if err != nil {
+			return fmt.Errorf("failed to convert Avro document to JSON: %v", err)
+		}
+		if err = part.SetJSON(jObj); err != nil {
+			return fmt.Errorf("failed to set JSON: %v", err)
+		}
+		return nil
+	}
+}
+
+func newAvroSchemaRegistryFromJSONOperator(client *schemaRegistryClient) avroOperator {
+	return func(part types.Part) error {
+		jObj, err := part.JSON()
+		if err != nil {
+			return fmt.Errorf("failed to parse message as JSON: %v", err)
+		}
+		sch, err := client.subjectSchema()
+		if err != nil {
+			return err
+		}
+		framed := make([]byte, schemaRegistryHeaderLen, schemaRegistryHeaderLen+len(part.Get()))
+		framed[0] = schemaRegistryMagicByte
+		binary.BigEndian.PutUint32(framed[1:], uint32(sch.id))
+		if framed, err = sch.codec.BinaryFromNative(framed, jObj); err != nil {
+			return fmt.Errorf("failed to convert JSON to Avro schema %v of subject '%v': %v", sch.id, client.subject, err)
+		}
+		part.Set(framed)
+		return nil
+	}
+}
+
+//------------------------------------------------------------------------------
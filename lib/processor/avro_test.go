@@ -1,8 +1,13 @@
 package processor
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
@@ -158,3 +163,155 @@ func TestAvroBasic(t *testing.T) {
 		})
 	}
 }
+
+func TestAvroSchemaRegistry(t *testing.T) {
+	schema := `{"type":"record","name":"user","fields":[{"name":"name","type":"string"},{"name":"age","type":"int"}]}`
+
+	var reqMut sync.Mutex
+	reqs := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqMut.Lock()
+		reqs[r.Method+" "+r.URL.Path]++
+		reqMut.Unlock()
+
+		switch r.Method + " " + r.URL.Path {
+		case "POST /subjects/users-value/versions":
+			var body struct {
+				Schema string `json:"schema"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Schema != schema {
+				t.Errorf("Wrong registered schema: %v, %v", body.Schema, err)
+			}
+			w.Write([]byte(`{"id":7}`))
+		case "GET /subjects/latest-value/versions/latest":
+			resBytes, _ := json.Marshal(map[string]interface{}{
+				"subject": "latest-value",
+				"version": 2,
+				"id":      7,
+				"schema":  schema,
+			})
+			w.Write(resBytes)
+		case "GET /schemas/ids/7":
+			resBytes, _ := json.Marshal(map[string]interface{}{
+				"schema": schema,
+			})
+			w.Write(resBytes)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40401,"message":"Subject not found."}`))
+		}
+	}))
+	defer ts.Close()
+
+	newProc := func(operator, subject, schema string) Type {
+		t.Helper()
+		conf := NewConfig()
+		conf.Type = TypeAvro
+		conf.Avro.Operator = operator
+		conf.Avro.Encoding = "schema_registry"
+		conf.Avro.SchemaRegistryURL = ts.URL
+		conf.Avro.Subject = subject
+		conf.Avro.Schema = schema
+		proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return proc
+	}
+
+	expFramed := "\x00\x00\x00\x00\x07\x06foo\x54"
+
+	encoder := newProc("from_json", "users-value", schema)
+	msgs, res := encoder.ProcessMessage(message.New([][]byte{
+		[]byte(`{"name":"foo","age":42}`),
+		[]byte(`{"name":"foo","age":"nope"}`),
+		[]byte(`{"name":"foo","age":42}`),
+	}))
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	for i, expFailed := range []bool{false, true, false} {
+		part := msgs[0].Get(i)
+		if act := HasFailed(part); act != expFailed {
+			t.Errorf("Wrong fail flag of message %v: %v != %v", i, act, expFailed)
+		}
+		if !expFailed {
+			if act := string(part.Get()); act != expFramed {
+				t.Errorf("Wrong framed message %v: %v != %v", i, strconv.Quote(act), strconv.Quote(expFramed))
+			}
+		} else if fail := part.Metadata().Get(FailFlagKey); !strings.Contains(fail, "schema 7 of subject 'users-value'") {
+			t.Errorf("Expected failure to describe the schema: %v", fail)
+		}
+	}
+
+	msgs, _ = newProc("from_json", "latest-value", "").ProcessMessage(message.New([][]byte{
+		[]byte(`{"name":"foo","age":42}`),
+	}))
+	if act := string(msgs[0].Get(0).Get()); act != expFramed {
+		t.Errorf("Wrong framed message with latest schema: %v != %v", strconv.Quote(act), strconv.Quote(expFramed))
+	}
+
+	msgs, _ = newProc("from_json", "unknown-value", "").ProcessMessage(message.New([][]byte{
+		[]byte(`{"name":"foo","age":42}`),
+	}))
+	if fail := msgs[0].Get(0).Metadata().Get(FailFlagKey); !strings.Contains(fail, "404") {
+		t.Errorf("Expected failure from unknown subject: %v", fail)
+	}
+
+	decoder := newProc("to_json", "", "")
+	msgs, _ = decoder.ProcessMessage(message.New([][]byte{
+		[]byte(expFramed),
+		[]byte(expFramed),
+		[]byte(`{"name":"foo","age":42}`),
+	}))
+	for i, expFailed := range []bool{false, false, true} {
+		part := msgs[0].Get(i)
+		if act := HasFailed(part); act != expFailed {
+			t.Errorf("Wrong fail flag of decoded message %v: %v != %v", i, act, expFailed)
+		}
+		if exp, act := `{"age":42,"name":"foo"}`, string(part.Get()); !expFailed && act != exp {
+			t.Errorf("Wrong decoded message %v: %v != %v", i, act, exp)
+		}
+	}
+
+	reqMut.Lock()
+	for k, v := range map[string]int{
+		"POST /subjects/users-value/versions":        1,
+		"GET /subjects/latest-value/versions/latest": 1,
+		"GET /schemas/ids/7":                         1,
+	} {
+		if act := reqs[k]; act != v {
+			t.Errorf("Wrong count of requests '%v': %v != %v", k, act, v)
+		}
+	}
+	reqMut.Unlock()
+}
+
+func TestAvroSchemaRegistryBadConfig(t *testing.T) {
+	tests := map[string]func(*AvroConfig){
+		"no url": func(c *AvroConfig) {
+			c.SchemaRegistryURL = ""
+		},
+		"relative url": func(c *AvroConfig) {
+			c.SchemaRegistryURL = "localhost"
+		},
+		"no subject": func(c *AvroConfig) {
+			c.Subject = ""
+		},
+		"bad schema": func(c *AvroConfig) {
+			c.Schema = "nope"
+		},
+	}
+	for name, fn := range tests {
+		conf := NewConfig()
+		conf.Type = TypeAvro
+		conf.Avro.Operator = "from_json"
+		conf.Avro.Encoding = "schema_registry"
+		conf.Avro.SchemaRegistryURL = "http://localhost:8081"
+		conf.Avro.Subject = "foo"
+		fn(&conf.Avro)
+		if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("%v: Expected error from bad config", name)
+		}
+	}
+}
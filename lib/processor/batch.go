@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/message/batch"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/response"
@@ -109,8 +110,19 @@ func (c *Batch) ProcessMessage(msg types.Message) ([]types.Message, types.Respon
 	if batch {
 		if newMsg := c.policy.Flush(); newMsg != nil {
 			c.mSent.Incr(int64(newMsg.Len()))
-			c.mBatchSent.Incr(1)
-			return []types.Message{newMsg}, nil
+			chunks := c.policy.Chunks(newMsg)
+			c.mBatchSent.Incr(int64(len(chunks)))
+			if len(chunks) == 1 {
+				return []types.Message{newMsg}, nil
+			}
+			msgs := make([]types.Message, len(chunks))
+			for i, chunk := range chunks {
+				msgs[i] = message.New(nil)
+				for _, j := range chunk {
+					msgs[i].Append(newMsg.Get(j))
+				}
+			}
+			return msgs, nil
 		}
 	}
 
@@ -90,6 +90,37 @@ func TestBatchLotsOfParts(t *testing.T) {
 	}
 }
 
+func TestBatchMaxParts(t *testing.T) {
+	conf := NewConfig()
+	conf.Batch.ByteSize = 1
+	conf.Batch.MaxParts = 4
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewBatch(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := [][]byte{
+		[]byte("foo"), []byte("bar"), []byte("bar2"),
+		[]byte("bar3"), []byte("bar4"), []byte("bar5"),
+	}
+
+	msgs, res := proc.ProcessMessage(message.New(input))
+	if res != nil {
+		t.Error("Expected nil res")
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("Wrong count of batches: %v", len(msgs))
+	}
+	if exp, act := input[:4], message.GetAllBytes(msgs[0]); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	if exp, act := input[4:], message.GetAllBytes(msgs[1]); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+}
+
 func TestBatchTwoSingleParts(t *testing.T) {
 	conf := NewConfig()
 	conf.Batch.ByteSize = 5
@@ -103,6 +134,61 @@ func TestBatchTwoSingleParts(t *testing.T) {
 
 	exp := [][]byte{[]byte("foo1"), []byte("bar1")}
 
+	msgs, res := proc.ProcessMessage(message.New([][]byte{exp[0]}))
+	if len(msgs) != 0 {
+		t.Error("Expected fail on one part")
+	}
+	if !res.SkipAck() {
+		t.Error("Expected skip ack")
+	}
+
+	msgs, res = proc.ProcessMessage(message.New([][]byte{exp[1]}))
+	if len(msgs) != 1 {
+		t.Error("Expected success")
+	}
+	if !reflect.DeepEqual(exp, message.GetAllBytes(msgs[0])) {
+		t.Errorf("Wrong result: %s != %s", message.GetAllBytes(msgs[0]), exp)
+	}
+	if res != nil {
+		t.Error("Expected nil res")
+	}
+
+	exp = [][]byte{[]byte("foo2"), []byte("bar2")}
+
+	msgs, res = proc.ProcessMessage(message.New([][]byte{exp[0]}))
+	if len(msgs) != 0 {
+		t.Error("Expected fail on one part")
+	}
+	if !res.SkipAck() {
+		t.Error("Expected skip ack")
+	}
+
+	msgs, res = proc.ProcessMessage(message.New([][]byte{exp[1]}))
+	if len(msgs) != 1 {
+		t.Error("Expected success")
+	}
+	if !reflect.DeepEqual(exp, message.GetAllBytes(msgs[0])) {
+		t.Errorf("Wrong result: %s != %s", message.GetAllBytes(msgs[0]), exp)
+	}
+	if res != nil {
+		t.Error("Expected nil res")
+	}
+}
+
+func TestBatchTwoSinglePartsMetadata(t *testing.T) {
+	conf := NewConfig()
+	// Each part with metadata is 11 bytes, 4 of content and 7 of metadata.
+	conf.Batch.ByteSize = 12
+
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+	proc, err := NewBatch(conf, nil, testLog, metrics.DudType{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	exp := [][]byte{[]byte("foo1"), []byte("bar1")}
+
 	inMsg := message.New([][]byte{exp[0]})
 	inMsg.Get(0).Metadata().Set("foo", "bar1")
 
@@ -119,7 +205,7 @@ func TestBatchTwoSingleParts(t *testing.T) {
 
 	msgs, res = proc.ProcessMessage(inMsg)
 	if len(msgs) != 1 {
-		t.Error("Expected success")
+		t.Fatal("Expected success")
 	}
 	if !reflect.DeepEqual(exp, message.GetAllBytes(msgs[0])) {
 		t.Errorf("Wrong result: %s != %s", message.GetAllBytes(msgs[0]), exp)
@@ -134,6 +220,8 @@ func TestBatchTwoSingleParts(t *testing.T) {
 		t.Error("Expected nil res")
 	}
 
+	// The contents alone are 8 bytes, and so only the metadata of the second
+	// part reaches the byte size.
 	exp = [][]byte{[]byte("foo2"), []byte("bar2")}
 
 	msgs, res = proc.ProcessMessage(message.New([][]byte{exp[0]}))
@@ -144,9 +232,12 @@ func TestBatchTwoSingleParts(t *testing.T) {
 		t.Error("Expected skip ack")
 	}
 
-	msgs, res = proc.ProcessMessage(message.New([][]byte{exp[1]}))
+	inMsg = message.New([][]byte{exp[1]})
+	inMsg.Get(0).Metadata().Set("foo", "bar3")
+
+	msgs, res = proc.ProcessMessage(inMsg)
 	if len(msgs) != 1 {
-		t.Error("Expected success")
+		t.Fatal("Expected success")
 	}
 	if !reflect.DeepEqual(exp, message.GetAllBytes(msgs[0])) {
 		t.Errorf("Wrong result: %s != %s", message.GetAllBytes(msgs[0]), exp)
@@ -1,12 +1,16 @@
 package processor
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/Jeffail/benthos/v3/lib/cache"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
 	"github.com/Jeffail/benthos/v3/lib/util/text"
 	"github.com/opentracing/opentracing-go"
@@ -21,7 +25,7 @@ func init() {
 Performs operations against a [cache resource](/docs/components/caches/about) for each message of a
 batch, allowing you to store or retrieve data within message payloads.
 
-This processor will interpolate functions within the ` + "`key` and `value`" + `
+This processor will interpolate functions within the ` + "`key`, `value` and `ttl`" + `
 fields individually for each message of the batch. This allows you to specify
 dynamic keys and values based on the contents of the message payloads and
 metadata. You can find a list of functions
@@ -34,6 +38,11 @@ metadata. You can find a list of functions
 Set a key in the cache to a value. If the key already exists the contents are
 overridden.
 
+The field ` + "`ttl`" + ` can be set to a duration string such as ` + "`60s`" + `
+in order to override the default TTL of the cache for each key, which is only
+supported by some caches (currently ` + "`memory`" + `). If the field resolves to
+an empty string the default TTL of the cache is used.
+
 #### ` + "`add`" + `
 
 Set a key in the cache to a value. If the key already exists the action fails
@@ -51,6 +60,50 @@ can be detected with [processor error handling](/docs/configuration/error_handli
 Delete a key and its contents from the cache.  If the key does not exist the
 action is a no-op and will not fail with an error.
 
+The field ` + "`ttl`" + ` can be set to a duration string in order to leave a
+tombstone in place of the key for that grace period, during which the
+` + "`add`" + ` operator fails for the key as if it still existed. This is
+useful for preventing late duplicates from being added again immediately after
+a key is deleted, and is only supported by some caches (currently
+` + "`memory`" + `).
+
+#### ` + "`increment`" + `
+
+Atomically add the value to a counter stored at the key and replace the original
+message payload with the new value of the counter. The value must resolve to a
+base 10 integer, which can be negative in order to decrement the counter, and
+defaults to one when the field is empty. If the key does not exist it is created
+with the value of the increment. If the current value of the key is not a base
+10 integer the action fails with an error.
+
+Unlike a ` + "`get`" + ` followed by a ` + "`set`" + `, the increment is
+applied within the cache itself and is therefore safe to use from parallel
+processors. This is only supported by caches that implement counters
+(currently ` + "`memory`" + `), where other caches fail with an error. A cache
+such as Redis is able to support this operator with the ` + "`INCRBY`" + `
+command, which has the same semantics.
+
+### Cache Errors
+
+The field ` + "`on_cache_error`" + ` determines what happens to a message when
+the cache operation fails for a reason other than the presence or absence of a
+key, for example when the cache cannot be reached. When set to ` + "`fail`" + `
+(the default) the message is flagged as failed for
+[processor error handling](/docs/configuration/error_handling), when set to
+` + "`skip`" + ` the message is removed from the batch, and when set to
+` + "`passthrough`" + ` the message continues unchanged without being flagged.
+
+### Metadata
+
+When the field ` + "`add_metadata`" + ` is set to ` + "`true`" + ` the
+metadata field ` + "`cache_duration_ms`" + ` is added to each message with the
+number of milliseconds that the cache operation took. For the ` + "`get`" + `
+and ` + "`add`" + ` operators the metadata field ` + "`cache_hit`" + ` is also
+added, which is ` + "`true`" + ` when the key already existed within the cache
+and ` + "`false`" + ` when it did not, allowing later processors to branch on
+whether the operation was a hit or a miss. The ` + "`cache_hit`" + ` field is
+not added when the operation fails for any other reason.
+
 ### Examples
 
 The ` + "`cache`" + ` processor can be used in combination with other processors
@@ -101,6 +154,11 @@ type CacheConfig struct {
 	Operator string `json:"operator" yaml:"operator"`
 	Key      string `json:"key" yaml:"key"`
 	Value    string `json:"value" yaml:"value"`
+	TTL      string `json:"ttl" yaml:"ttl"`
+
+	OnCacheError string `json:"on_cache_error" yaml:"on_cache_error"`
+
+	AddMetadata bool `json:"add_metadata" yaml:"add_metadata"`
 }
 
 // NewCacheConfig returns a CacheConfig with default values.
@@ -111,6 +169,11 @@ func NewCacheConfig() CacheConfig {
 		Operator: "set",
 		Key:      "",
 		Value:    "",
+		TTL:      "",
+
+		OnCacheError: "fail",
+
+		AddMetadata: false,
 	}
 }
 
@@ -127,13 +190,19 @@ type Cache struct {
 
 	key   *text.InterpolatedString
 	value *text.InterpolatedBytes
+	ttl   *text.InterpolatedString
 
-	cache    types.Cache
-	operator cacheOperator
+	cache     types.Cache
+	operator  cacheOperator
+	errPolicy cacheErrPolicy
+
+	ctx   context.Context
+	close func()
 
 	mCount            metrics.StatCounter
 	mErr              metrics.StatCounter
 	mKeyAlreadyExists metrics.StatCounter
+	mSkipped          metrics.StatCounter
 	mSent             metrics.StatCounter
 	mBatchSent        metrics.StatCounter
 }
@@ -151,8 +220,20 @@ func NewCache(
 	if err != nil {
 		return nil, err
 	}
+	if len(conf.Cache.TTL) > 0 && conf.Cache.Operator != "set" && conf.Cache.Operator != "delete" {
+		return nil, fmt.Errorf("field ttl is not supported by operator: %v", conf.Cache.Operator)
+	}
+
+	errPolicy, err := parseCacheErrPolicy(conf.Cache.OnCacheError, cacheErrPolicyFail)
+	if err != nil {
+		return nil, err
+	}
 
+	ctx, done := context.WithCancel(context.Background())
 	return &Cache{
+		ctx:   ctx,
+		close: done,
+
 		conf:  conf,
 		log:   log,
 		stats: stats,
@@ -161,13 +242,16 @@ func NewCache(
 
 		key:   text.NewInterpolatedString(conf.Cache.Key),
 		value: text.NewInterpolatedBytes([]byte(conf.Cache.Value)),
+		ttl:   text.NewInterpolatedString(conf.Cache.TTL),
 
-		cache:    c,
-		operator: op,
+		cache:     c,
+		operator:  op,
+		errPolicy: errPolicy,
 
 		mCount:            stats.GetCounter("count"),
 		mErr:              stats.GetCounter("error"),
 		mKeyAlreadyExists: stats.GetCounter("key_already_exists"),
+		mSkipped:          stats.GetCounter("skipped"),
 		mSent:             stats.GetCounter("sent"),
 		mBatchSent:        stats.GetCounter("batch.sent"),
 	}, nil
@@ -175,46 +259,79 @@ func NewCache(
 
 //------------------------------------------------------------------------------
 
-type cacheOperator func(key string, value []byte) ([]byte, bool, error)
+// cacheOperator performs an operation against a cache, a ttl of zero results in
+// the default TTL of the cache being used.
+type cacheOperator func(ctx context.Context, key string, value []byte, ttl time.Duration) ([]byte, bool, error)
 
-func newCacheSetOperator(cache types.Cache) cacheOperator {
-	return func(key string, value []byte) ([]byte, bool, error) {
-		err := cache.Set(key, value)
+func newCacheSetOperator(c types.Cache) cacheOperator {
+	return func(ctx context.Context, key string, value []byte, ttl time.Duration) ([]byte, bool, error) {
+		if ttl > 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, false, err
+			}
+			return nil, false, cache.SetWithTTL(c, key, value, ttl)
+		}
+		err := cache.SetWithContext(ctx, c, key, value)
 		return nil, false, err
 	}
 }
 
-func newCacheAddOperator(cache types.Cache) cacheOperator {
-	return func(key string, value []byte) ([]byte, bool, error) {
-		err := cache.Add(key, value)
+func newCacheAddOperator(c types.Cache) cacheOperator {
+	return func(ctx context.Context, key string, value []byte, _ time.Duration) ([]byte, bool, error) {
+		err := c.Add(key, value)
 		return nil, false, err
 	}
 }
 
-func newCacheGetOperator(cache types.Cache) cacheOperator {
-	return func(key string, _ []byte) ([]byte, bool, error) {
-		result, err := cache.Get(key)
+func newCacheGetOperator(c types.Cache) cacheOperator {
+	return func(ctx context.Context, key string, _ []byte, _ time.Duration) ([]byte, bool, error) {
+		result, err := cache.GetWithContext(ctx, c, key)
 		return result, true, err
 	}
 }
 
-func newCacheDeleteOperator(cache types.Cache) cacheOperator {
-	return func(key string, _ []byte) ([]byte, bool, error) {
-		err := cache.Delete(key)
+func newCacheDeleteOperator(c types.Cache) cacheOperator {
+	return func(ctx context.Context, key string, _ []byte, ttl time.Duration) ([]byte, bool, error) {
+		if ttl > 0 {
+			return nil, false, cache.DeleteWithTTL(c, key, ttl)
+		}
+		err := c.Delete(key)
 		return nil, false, err
 	}
 }
 
-func cacheOperatorFromString(operator string, cache types.Cache) (cacheOperator, error) {
+func newCacheIncrementOperator(c types.Cache) cacheOperator {
+	return func(ctx context.Context, key string, value []byte, _ time.Duration) ([]byte, bool, error) {
+		delta := int64(1)
+		if len(value) > 0 {
+			var err error
+			if delta, err = strconv.ParseInt(string(value), 10, 64); err != nil {
+				return nil, false, fmt.Errorf("failed to parse increment value: %v", err)
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+		result, err := cache.Increment(c, key, delta)
+		if err != nil {
+			return nil, false, err
+		}
+		return []byte(strconv.FormatInt(result, 10)), true, nil
+	}
+}
+
+func cacheOperatorFromString(operator string, c types.Cache) (cacheOperator, error) {
 	switch operator {
 	case "set":
-		return newCacheSetOperator(cache), nil
+		return newCacheSetOperator(c), nil
 	case "add":
-		return newCacheAddOperator(cache), nil
+		return newCacheAddOperator(c), nil
 	case "get":
-		return newCacheGetOperator(cache), nil
+		return newCacheGetOperator(c), nil
 	case "delete":
-		return newCacheDeleteOperator(cache), nil
+		return newCacheDeleteOperator(c), nil
+	case "increment":
+		return newCacheIncrementOperator(c), nil
 	}
 	return nil, fmt.Errorf("operator not recognised: %v", operator)
 }
@@ -227,18 +344,49 @@ func (c *Cache) ProcessMessage(msg types.Message) ([]types.Message, types.Respon
 	c.mCount.Incr(1)
 	newMsg := msg.Copy()
 
+	var skipped map[int]struct{}
 	proc := func(index int, span opentracing.Span, part types.Part) error {
 		key := c.key.Get(message.Lock(newMsg, index))
 		value := c.value.Get(message.Lock(newMsg, index))
 
-		result, useResult, err := c.operator(key, value)
-		if err != nil {
-			if err != types.ErrKeyAlreadyExists {
+		var ttl time.Duration
+		if ttlStr := c.ttl.Get(message.Lock(newMsg, index)); len(ttlStr) > 0 {
+			var err error
+			if ttl, err = time.ParseDuration(ttlStr); err != nil {
 				c.mErr.Incr(1)
-				c.log.Debugf("Operator failed for key '%s': %v\n", key, err)
-			} else {
+				c.log.Debugf("Failed to parse ttl '%v' for key '%s': %v\n", ttlStr, key, err)
+				return fmt.Errorf("failed to parse ttl: %v", err)
+			}
+		}
+
+		tStarted := time.Now()
+		result, useResult, err := c.operator(c.ctx, key, value, ttl)
+		if c.conf.Cache.AddMetadata {
+			c.addMetadata(part, time.Since(tStarted), err)
+		}
+		if err != nil {
+			if err == types.ErrKeyAlreadyExists {
 				c.mKeyAlreadyExists.Incr(1)
 				c.log.Debugf("Key already exists: %v\n", key)
+				return err
+			}
+			c.mErr.Incr(1)
+			c.log.Debugf("Operator failed for key '%s': %v\n", key, err)
+			if !isCacheFailure(err) {
+				return err
+			}
+			switch c.errPolicy {
+			case cacheErrPolicySkip:
+				if skipped == nil {
+					skipped = map[int]struct{}{}
+				}
+				if index < 0 {
+					index = newMsg.Len() + index
+				}
+				skipped[index] = struct{}{}
+				return nil
+			case cacheErrPolicyPassthrough:
+				return nil
 			}
 			return err
 		}
@@ -251,14 +399,49 @@ func (c *Cache) ProcessMessage(msg types.Message) ([]types.Message, types.Respon
 
 	IteratePartsWithSpan(TypeCache, c.parts, newMsg, proc)
 
+	if len(skipped) > 0 {
+		c.mSkipped.Incr(int64(len(skipped)))
+		filtered := message.New(nil)
+		newMsg.Iter(func(i int, p types.Part) error {
+			if _, exists := skipped[i]; !exists {
+				filtered.Append(p)
+			}
+			return nil
+		})
+		if filtered.Len() == 0 {
+			return nil, response.NewAck()
+		}
+		newMsg = filtered
+	}
+
 	c.mBatchSent.Incr(1)
 	c.mSent.Incr(int64(newMsg.Len()))
 	msgs := [1]types.Message{newMsg}
 	return msgs[:], nil
 }
 
-// CloseAsync shuts down the processor and stops processing requests.
+// addMetadata adds the duration of a cache operation and, for the get and add
+// operators, whether the key already existed to the metadata of a part.
+func (c *Cache) addMetadata(part types.Part, duration time.Duration, err error) {
+	meta := part.Metadata()
+	meta.Set("cache_duration_ms", strconv.FormatInt(int64(duration/time.Millisecond), 10))
+
+	switch c.conf.Cache.Operator {
+	case "get":
+		if err == nil || err == types.ErrKeyNotFound {
+			meta.Set("cache_hit", strconv.FormatBool(err == nil))
+		}
+	case "add":
+		if err == nil || err == types.ErrKeyAlreadyExists {
+			meta.Set("cache_hit", strconv.FormatBool(err != nil))
+		}
+	}
+}
+
+// CloseAsync shuts down the processor and stops processing requests, cache
+// operations that are still in progress are abandoned.
 func (c *Cache) CloseAsync() {
+	c.close()
 }
 
 // WaitForClose blocks until the processor has closed down.
@@ -1,6 +1,7 @@
 package processor
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 
@@ -68,6 +69,235 @@ func TestCacheSet(t *testing.T) {
 	}
 }
 
+func TestCacheSetTTL(t *testing.T) {
+	memConf := cache.NewConfig()
+	memConf.Memory.TTL = 0
+	memConf.Memory.CompactionInterval = ""
+	memCache, err := cache.NewMemory(memConf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": memCache,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Cache.Key = "${!json_field:key}"
+	conf.Cache.Value = "${!json_field:value}"
+	conf.Cache.TTL = "${!json_field:ttl}"
+	conf.Cache.Cache = "foocache"
+	proc, err := NewCache(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := message.New([][]byte{
+		[]byte(`{"key":"1","value":"foo 1","ttl":"1h"}`),
+		[]byte(`{"key":"2","value":"foo 2","ttl":""}`),
+		[]byte(`{"key":"3","value":"foo 3","ttl":"nope"}`),
+	})
+
+	output, res := proc.ProcessMessage(input)
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if len(output) != 1 {
+		t.Fatalf("Wrong count of result messages: %v", len(output))
+	}
+	for i, exp := range []bool{false, false, true} {
+		if act := HasFailed(output[0].Get(i)); exp != act {
+			t.Errorf("Wrong fail flag of message %v: %v != %v", i, act, exp)
+		}
+	}
+
+	// This should trigger compaction.
+	if err = memCache.Add("4", []byte("foo 4")); err != nil {
+		t.Fatal(err)
+	}
+
+	actBytes, err := memCache.Get("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "foo 1", string(actBytes); exp != act {
+		t.Errorf("Wrong result: %v != %v", act, exp)
+	}
+	for _, k := range []string{"2", "3"} {
+		if _, err = memCache.Get(k); err != types.ErrKeyNotFound {
+			t.Errorf("Wrong error returned for key %v: %v != %v", k, err, types.ErrKeyNotFound)
+		}
+	}
+
+	conf.Cache.Operator = "add"
+	if _, err = NewCache(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from ttl with add operator")
+	}
+}
+
+func TestCacheDeleteTTL(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": memCache,
+		},
+	}
+	for _, k := range []string{"1", "2"} {
+		if err = memCache.Set(k, []byte("foo")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf := NewConfig()
+	conf.Cache.Operator = "delete"
+	conf.Cache.Key = "${!json_field:key}"
+	conf.Cache.TTL = "${!json_field:ttl}"
+	conf.Cache.Cache = "foocache"
+	proc, err := NewCache(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := message.New([][]byte{
+		[]byte(`{"key":"1","ttl":"1h"}`),
+		[]byte(`{"key":"2","ttl":""}`),
+	})
+	output, res := proc.ProcessMessage(input)
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	for i := 0; i < output[0].Len(); i++ {
+		if HasFailed(output[0].Get(i)) {
+			t.Errorf("Unexpected fail flag of message %v", i)
+		}
+	}
+
+	if act := memCache.Add("1", []byte("bar")); act != types.ErrKeyAlreadyExists {
+		t.Errorf("Wrong error returned: %v != %v", act, types.ErrKeyAlreadyExists)
+	}
+	if err = memCache.Add("2", []byte("bar")); err != nil {
+		t.Errorf("Expected add of deleted key without tombstone to succeed: %v", err)
+	}
+}
+
+func TestCacheIncrement(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": memCache,
+		},
+	}
+	if err = memCache.Set("3", []byte("nope")); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := NewConfig()
+	conf.Cache.Operator = "increment"
+	conf.Cache.Key = "${!json_field:key}"
+	conf.Cache.Value = "${!json_field:delta}"
+	conf.Cache.Cache = "foocache"
+	proc, err := NewCache(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := message.New([][]byte{
+		[]byte(`{"key":"1","delta":"5"}`),
+		[]byte(`{"key":"1","delta":""}`),
+		[]byte(`{"key":"2","delta":"-3"}`),
+		[]byte(`{"key":"1","delta":"nope"}`),
+		[]byte(`{"key":"3","delta":"1"}`),
+	})
+	output, res := proc.ProcessMessage(input)
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	exp := [][]byte{
+		[]byte("5"),
+		[]byte("6"),
+		[]byte("-3"),
+		[]byte(`{"key":"1","delta":"nope"}`),
+		[]byte(`{"key":"3","delta":"1"}`),
+	}
+	if act := message.GetAllBytes(output[0]); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	for i, expFailed := range []bool{false, false, false, true, true} {
+		if act := HasFailed(output[0].Get(i)); act != expFailed {
+			t.Errorf("Wrong fail flag of message %v: %v != %v", i, act, expFailed)
+		}
+	}
+
+	if act, err := memCache.Get("1"); err != nil {
+		t.Error(err)
+	} else if string(act) != "6" {
+		t.Errorf("Wrong stored counter: %s != %v", act, 6)
+	}
+}
+
+func TestCacheAddMetadata(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = memCache.Set("1", []byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": memCache,
+		},
+	}
+
+	tests := []struct {
+		operator string
+		addMeta  bool
+		expHits  []string
+	}{
+		{operator: "get", addMeta: false, expHits: []string{"", ""}},
+		{operator: "get", addMeta: true, expHits: []string{"true", "false"}},
+		{operator: "add", addMeta: true, expHits: []string{"true", "false"}},
+		{operator: "set", addMeta: true, expHits: []string{"", ""}},
+	}
+
+	for _, test := range tests {
+		conf := NewConfig()
+		conf.Cache.Cache = "foocache"
+		conf.Cache.Operator = test.operator
+		conf.Cache.Key = "${!content}"
+		conf.Cache.Value = "bar"
+		conf.Cache.AddMetadata = test.addMeta
+		proc, err := NewCache(conf, mgr, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		output, res := proc.ProcessMessage(message.New([][]byte{
+			[]byte("1"),
+			[]byte(test.operator + "_miss"),
+		}))
+		if res != nil {
+			t.Fatal(res.Error())
+		}
+		for i, exp := range test.expHits {
+			meta := output[0].Get(i).Metadata()
+			if act := meta.Get("cache_hit"); act != exp {
+				t.Errorf("%v: Wrong cache_hit of message %v: %v != %v", test.operator, i, act, exp)
+			}
+			if act := meta.Get("cache_duration_ms"); (act != "") != test.addMeta {
+				t.Errorf("%v: Wrong cache_duration_ms of message %v: %v", test.operator, i, act)
+			}
+		}
+	}
+}
+
 func TestCacheSetParts(t *testing.T) {
 	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
 	if err != nil {
@@ -325,3 +555,133 @@ func TestCacheDelete(t *testing.T) {
 		t.Errorf("Wrong result: %v != %v", err, types.ErrKeyNotFound)
 	}
 }
+
+type flakyCache struct {
+	types.Cache
+	failKey string
+}
+
+func (f flakyCache) Get(key string) ([]byte, error) {
+	if key == f.failKey {
+		return nil, errors.New("test err")
+	}
+	return f.Cache.Get(key)
+}
+
+func TestCacheOnCacheErrorPolicy(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": flakyCache{Cache: memCache, failKey: "2"},
+		},
+	}
+
+	memCache.Set("1", []byte("foo 1"))
+	memCache.Set("2", []byte("foo 2"))
+
+	tests := map[string]struct {
+		expParts  [][]byte
+		expFailed []bool
+	}{
+		"fail": {
+			expParts: [][]byte{
+				[]byte(`foo 1`),
+				[]byte(`{"key":"2"}`),
+				[]byte(`{"key":"3"}`),
+			},
+			expFailed: []bool{false, true, true},
+		},
+		"skip": {
+			expParts: [][]byte{
+				[]byte(`foo 1`),
+				[]byte(`{"key":"3"}`),
+			},
+			expFailed: []bool{false, true},
+		},
+		"passthrough": {
+			expParts: [][]byte{
+				[]byte(`foo 1`),
+				[]byte(`{"key":"2"}`),
+				[]byte(`{"key":"3"}`),
+			},
+			expFailed: []bool{false, false, true},
+		},
+	}
+
+	for policy, test := range tests {
+		conf := NewConfig()
+		conf.Cache.Key = "${!json_field:key}"
+		conf.Cache.Cache = "foocache"
+		conf.Cache.Operator = "get"
+		conf.Cache.OnCacheError = policy
+		proc, err := NewCache(conf, mgr, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		input := message.New([][]byte{
+			[]byte(`{"key":"1"}`),
+			[]byte(`{"key":"2"}`),
+			[]byte(`{"key":"3"}`),
+		})
+
+		output, res := proc.ProcessMessage(input)
+		if res != nil {
+			t.Fatal(res.Error())
+		}
+		if len(output) != 1 {
+			t.Fatalf("%v: Wrong count of result messages: %v", policy, len(output))
+		}
+		if exp, act := test.expParts, message.GetAllBytes(output[0]); !reflect.DeepEqual(exp, act) {
+			t.Errorf("%v: Wrong result messages: %s != %s", policy, act, exp)
+		}
+		for i, exp := range test.expFailed {
+			if act := HasFailed(output[0].Get(i)); exp != act {
+				t.Errorf("%v: Wrong fail flag of part %v: %v != %v", policy, i, act, exp)
+			}
+		}
+	}
+
+	conf := NewConfig()
+	conf.Cache.Cache = "foocache"
+	conf.Cache.OnCacheError = "nope"
+	if _, err = NewCache(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from bad policy")
+	}
+}
+
+func TestCacheClosedAbandonsOperations(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": memCache,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Cache.Key = "${!content}"
+	conf.Cache.Value = "bar"
+	conf.Cache.Cache = "foocache"
+	proc, err := NewCache(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc.CloseAsync()
+
+	output, res := proc.ProcessMessage(message.New([][]byte{[]byte("foo")}))
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if exp, act := true, HasFailed(output[0].Get(0)); exp != act {
+		t.Errorf("Wrong fail flag: %v != %v", act, exp)
+	}
+	if _, err = memCache.Get("foo"); err != types.ErrKeyNotFound {
+		t.Errorf("Wrong error returned: %v != %v", err, types.ErrKeyNotFound)
+	}
+}
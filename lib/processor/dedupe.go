@@ -65,7 +65,18 @@ sink followed by a restart of the Benthos instance.
 If you intend to preserve at-least-once delivery guarantees you can avoid this
 problem by using a memory based cache. This is a compromise that can achieve
 effective deduplication but parallel deployments of the pipeline as well as
-service restarts increase the chances of duplicates passing undetected.`,
+service restarts increase the chances of duplicates passing undetected.
+
+### Cache Errors
+
+The field ` + "`on_cache_error`" + ` determines what happens to a batch when
+the cache cannot be reached. When set to ` + "`skip`" + ` the batch is dropped,
+when set to ` + "`passthrough`" + ` the batch continues as if it were unique
+(accepting that duplicates may pass), and when set to ` + "`fail`" + ` the batch
+continues with its messages flagged as failed for
+[processor error handling](/docs/configuration/error_handling). When left empty
+the policy is ` + "`skip`" + ` if ` + "`drop_on_err`" + ` is true and
+` + "`passthrough`" + ` otherwise.`,
 	}
 }
 
@@ -78,6 +89,7 @@ type DedupeConfig struct {
 	Parts          []int  `json:"parts" yaml:"parts"` // message parts to hash
 	Key            string `json:"key" yaml:"key"`
 	DropOnCacheErr bool   `json:"drop_on_err" yaml:"drop_on_err"`
+	OnCacheError   string `json:"on_cache_error" yaml:"on_cache_error"`
 }
 
 // NewDedupeConfig returns a DedupeConfig with default values.
@@ -88,6 +100,7 @@ func NewDedupeConfig() DedupeConfig {
 		Parts:          []int{0}, // only consider the 1st part
 		Key:            "",
 		DropOnCacheErr: true,
+		OnCacheError:   "",
 	}
 }
 
@@ -145,6 +158,7 @@ type Dedupe struct {
 	interpolateKey bool
 
 	cache      types.Cache
+	errPolicy  cacheErrPolicy
 	hasherFunc hasherFunc
 
 	mCount     metrics.StatCounter
@@ -170,6 +184,15 @@ func NewDedupe(
 		return nil, err
 	}
 
+	defaultPolicy := cacheErrPolicyPassthrough
+	if conf.Dedupe.DropOnCacheErr {
+		defaultPolicy = cacheErrPolicySkip
+	}
+	errPolicy, err := parseCacheErrPolicy(conf.Dedupe.OnCacheError, defaultPolicy)
+	if err != nil {
+		return nil, err
+	}
+
 	keyBytes := []byte(conf.Dedupe.Key)
 	interpolateKey := text.ContainsFunctionVariables(keyBytes)
 
@@ -182,6 +205,7 @@ func NewDedupe(
 		interpolateKey: interpolateKey,
 
 		cache:      c,
+		errPolicy:  errPolicy,
 		hasherFunc: hFunc,
 
 		mCount:     stats.GetCounter("count"),
@@ -250,9 +274,16 @@ func (d *Dedupe) ProcessMessage(msg types.Message) ([]types.Message, types.Respo
 					olog.String("type", err.Error()),
 				)
 			}
-			if d.conf.Dedupe.DropOnCacheErr {
+			switch d.errPolicy {
+			case cacheErrPolicySkip:
 				d.mDropped.Incr(1)
 				return nil, response.NewAck()
+			case cacheErrPolicyFail:
+				msg = msg.Copy()
+				msg.Iter(func(i int, p types.Part) error {
+					FlagErr(p, err)
+					return nil
+				})
 			}
 		} else {
 			for _, s := range spans {
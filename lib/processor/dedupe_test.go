@@ -360,6 +360,69 @@ func TestDedupeCacheErrors(t *testing.T) {
 	}
 }
 
+func TestDedupeOnCacheErrorPolicy(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": errCache{},
+		},
+	}
+
+	conf := NewConfig()
+	conf.Dedupe.Cache = "foocache"
+	conf.Dedupe.OnCacheError = "passthrough"
+
+	proc, err := NewDedupe(conf, mgr, testLog, metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, res := proc.ProcessMessage(message.New([][]byte{[]byte("foo"), []byte("bar")}))
+	if res != nil || len(msgs) != 1 {
+		t.Fatalf("Expected message propagate on error: %v - %v", res, len(msgs))
+	}
+	msgs[0].Iter(func(i int, p types.Part) error {
+		if HasFailed(p) {
+			t.Errorf("Part %v was flagged as failed", i)
+		}
+		return nil
+	})
+
+	conf.Dedupe.OnCacheError = "fail"
+	if proc, err = NewDedupe(conf, mgr, testLog, metrics.DudType{}); err != nil {
+		t.Fatal(err)
+	}
+	input := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	msgs, res = proc.ProcessMessage(input)
+	if res != nil || len(msgs) != 1 {
+		t.Fatalf("Expected message propagate on error: %v - %v", res, len(msgs))
+	}
+	msgs[0].Iter(func(i int, p types.Part) error {
+		if !HasFailed(p) {
+			t.Errorf("Part %v was not flagged as failed", i)
+		}
+		return nil
+	})
+	if HasFailed(input.Get(0)) {
+		t.Error("Input message was modified")
+	}
+
+	conf.Dedupe.DropOnCacheErr = false
+	conf.Dedupe.OnCacheError = "skip"
+	if proc, err = NewDedupe(conf, mgr, testLog, metrics.DudType{}); err != nil {
+		t.Fatal(err)
+	}
+	msgs, res = proc.ProcessMessage(message.New([][]byte{[]byte("foo"), []byte("bar")}))
+	if exp := response.NewAck(); !reflect.DeepEqual(exp, res) || len(msgs) > 0 {
+		t.Errorf("Expected message drop on error: %v - %v", res, len(msgs))
+	}
+
+	conf.Dedupe.OnCacheError = "nope"
+	if _, err = NewDedupe(conf, mgr, testLog, metrics.DudType{}); err == nil {
+		t.Error("Expected error from bad policy")
+	}
+}
+
 func TestDedupeBadHash(t *testing.T) {
 	conf := NewConfig()
 	conf.Dedupe.Cache = "foocache"
@@ -1,10 +1,12 @@
 package processor
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
 
+	"github.com/Jeffail/benthos/v3/lib/condition"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
@@ -58,6 +60,27 @@ it will be retried after increasing intervals.
 When a request returns a response code within the ` + "`drop_on`" + ` field it
 will not be reattempted and is immediately considered a failed request.
 
+### Retrying on Response Contents
+
+Some services indicate a transient failure within the body of an otherwise
+successful response. The field ` + "`retry_on`" + ` can be set to a list of
+[conditions](/docs/components/conditions/about) that are checked against the
+response, and when all of them resolve to true the request is retried according
+to the ` + "`retries` and `retry_period`" + ` fields. If the response still
+matches after all retries are exhausted the request is considered failed.
+
+` + "``` yaml" + `
+pipeline:
+  processors:
+  - http:
+      request:
+        url: http://example.com/enrich
+      retry_on:
+      - text:
+          operator: contains
+          arg: '"status":"busy"'
+` + "```" + `
+
 ### Adding Metadata
 
 If the request returns a response code this processor sets a metadata field
@@ -72,6 +95,26 @@ When all retry attempts for a message are exhausted the processor cancels the
 attempt. These failed messages will continue through the pipeline unchanged, but
 can be dropped or placed in a dead letter queue according to your config, you
 can read about these patterns [here](/docs/configuration/error_handling).`,
+		sanitiseConfigFunc: func(conf Config) (interface{}, error) {
+			confBytes, err := json.Marshal(conf.HTTP)
+			if err != nil {
+				return nil, err
+			}
+
+			confMap := map[string]interface{}{}
+			if err = json.Unmarshal(confBytes, &confMap); err != nil {
+				return nil, err
+			}
+
+			condConfs := make([]interface{}, len(conf.HTTP.RetryOn))
+			for i, cConf := range conf.HTTP.RetryOn {
+				if condConfs[i], err = condition.SanitiseConfig(cConf); err != nil {
+					return nil, err
+				}
+			}
+			confMap["retry_on"] = condConfs
+			return confMap, nil
+		},
 	}
 }
 
@@ -79,9 +122,10 @@ can read about these patterns [here](/docs/configuration/error_handling).`,
 
 // HTTPConfig contains configuration fields for the HTTP processor.
 type HTTPConfig struct {
-	Client      client.Config `json:"request" yaml:"request"`
-	Parallel    bool          `json:"parallel" yaml:"parallel"`
-	MaxParallel int           `json:"max_parallel" yaml:"max_parallel"`
+	Client      client.Config      `json:"request" yaml:"request"`
+	Parallel    bool               `json:"parallel" yaml:"parallel"`
+	MaxParallel int                `json:"max_parallel" yaml:"max_parallel"`
+	RetryOn     []condition.Config `json:"retry_on" yaml:"retry_on"`
 }
 
 // NewHTTPConfig returns a HTTPConfig with default values.
@@ -90,6 +134,7 @@ func NewHTTPConfig() HTTPConfig {
 		Client:      client.NewConfig(),
 		Parallel:    false,
 		MaxParallel: 0,
+		RetryOn:     []condition.Config{},
 	}
 }
 
@@ -132,13 +177,33 @@ func NewHTTP(
 		mSent:      stats.GetCounter("sent"),
 		mBatchSent: stats.GetCounter("batch.sent"),
 	}
-	var err error
-	if g.client, err = client.New(
-		conf.HTTP.Client,
+	opts := []func(*client.Type){
 		client.OptSetLogger(g.log),
 		client.OptSetStats(metrics.Namespaced(g.stats, "client")),
 		client.OptSetManager(mgr),
-	); err != nil {
+	}
+	if len(conf.HTTP.RetryOn) > 0 {
+		var conds []types.Condition
+		for i, cconf := range conf.HTTP.RetryOn {
+			prefix := fmt.Sprintf("retry_on.%v", i)
+			cond, err := condition.New(cconf, mgr, log.NewModule("."+prefix), metrics.Namespaced(stats, prefix))
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, cond)
+		}
+		opts = append(opts, client.OptSetRetryOn(func(msg types.Message) bool {
+			for _, c := range conds {
+				if !c.Check(msg) {
+					return false
+				}
+			}
+			return true
+		}))
+	}
+
+	var err error
+	if g.client, err = client.New(conf.HTTP.Client, opts...); err != nil {
 		return nil, err
 	}
 	return g, nil
@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Jeffail/benthos/v3/lib/condition"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
@@ -58,6 +59,71 @@ func TestHTTPClientRetries(t *testing.T) {
 	}
 }
 
+func TestHTTPClientRetryOn(t *testing.T) {
+	var reqCount uint32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddUint32(&reqCount, 1) < 3 {
+			w.Write([]byte(`{"status":"busy"}`))
+			return
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer ts.Close()
+
+	condConf := condition.NewConfig()
+	condConf.Type = condition.TypeText
+	condConf.Text.Operator = "contains"
+	condConf.Text.Arg = `"status":"busy"`
+
+	conf := NewConfig()
+	conf.HTTP.Client.URL = ts.URL + "/testpost"
+	conf.HTTP.Client.Retry = "1ms"
+	conf.HTTP.Client.NumRetries = 3
+	conf.HTTP.RetryOn = []condition.Config{condConf}
+
+	h, err := NewHTTP(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res := h.ProcessMessage(message.New([][]byte{[]byte("test")}))
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if len(msgs) != 1 {
+		t.Fatal("Wrong count of messages")
+	}
+	if exp, act := `{"status":"ok"}`, string(msgs[0].Get(0).Get()); exp != act {
+		t.Errorf("Wrong message contents: %v != %v", act, exp)
+	}
+	if HasFailed(msgs[0].Get(0)) {
+		t.Error("Unexpected failed message part")
+	}
+	if exp, act := uint32(3), atomic.LoadUint32(&reqCount); exp != act {
+		t.Errorf("Wrong count of HTTP attempts: %v != %v", exp, act)
+	}
+
+	conf.HTTP.Client.NumRetries = 1
+	atomic.StoreUint32(&reqCount, 0)
+	if h, err = NewHTTP(conf, nil, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, res = h.ProcessMessage(message.New([][]byte{[]byte("test")}))
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if exp, act := "test", string(msgs[0].Get(0).Get()); exp != act {
+		t.Errorf("Wrong message contents: %v != %v", act, exp)
+	}
+	if !HasFailed(msgs[0].Get(0)) {
+		t.Error("Failed message part not flagged")
+	}
+	if exp, act := uint32(2), atomic.LoadUint32(&reqCount); exp != act {
+		t.Errorf("Wrong count of HTTP attempts: %v != %v", exp, act)
+	}
+}
+
 func TestHTTPClientBasic(t *testing.T) {
 	i := 0
 	expPayloads := []string{"foo", "bar", "baz"}
@@ -0,0 +1,182 @@
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+//------------------------------------------------------------------------------
+
+// ParquetSchemaFieldConfig describes a single field of a Parquet schema.
+type ParquetSchemaFieldConfig struct {
+	Name     string                     `json:"name" yaml:"name"`
+	Type     string                     `json:"type" yaml:"type"`
+	Optional bool                       `json:"optional" yaml:"optional"`
+	Repeated bool                       `json:"repeated" yaml:"repeated"`
+	Fields   []ParquetSchemaFieldConfig `json:"fields" yaml:"fields"`
+}
+
+// parquetTypes contains the supported primitive field types.
+var parquetTypes = map[string]struct{}{
+	"BOOLEAN":    {},
+	"INT32":      {},
+	"INT64":      {},
+	"FLOAT":      {},
+	"DOUBLE":     {},
+	"BYTE_ARRAY": {},
+	"UTF8":       {},
+}
+
+type parquetJSONSchema struct {
+	Tag    string               `json:"Tag"`
+	Fields []*parquetJSONSchema `json:"Fields,omitempty"`
+}
+
+func parquetRepetition(f ParquetSchemaFieldConfig) (string, error) {
+	if f.Optional && f.Repeated {
+		return "", fmt.Errorf("field '%v' cannot be both optional and repeated", f.Name)
+	}
+	if f.Optional {
+		return "OPTIONAL", nil
+	}
+	if f.Repeated {
+		return "REPEATED", nil
+	}
+	return "REQUIRED", nil
+}
+
+func parquetFieldsToJSONSchema(fields []ParquetSchemaFieldConfig) ([]*parquetJSONSchema, error) {
+	if len(fields) == 0 {
+		return nil, errors.New("at least one field must be specified")
+	}
+	seen := map[string]struct{}{}
+	var schemaFields []*parquetJSONSchema
+	for _, f := range fields {
+		if len(f.Name) == 0 {
+			return nil, errors.New("field names must not be empty")
+		}
+		if strings.ContainsAny(f.Name, ",=\t") {
+			return nil, fmt.Errorf("field name '%v' must not contain commas, equals signs or tabs", f.Name)
+		}
+		// Field names are stored by the Parquet library in a normalised form
+		// where the first character is capitalised, and these must not clash.
+		inName := common.StringToVariableName(f.Name)
+		if _, exists := seen[inName]; exists {
+			return nil, fmt.Errorf("field name '%v' clashes with another field", f.Name)
+		}
+		seen[inName] = struct{}{}
+
+		rep, err := parquetRepetition(f)
+		if err != nil {
+			return nil, err
+		}
+		field := &parquetJSONSchema{}
+		if len(f.Fields) > 0 {
+			if len(f.Type) > 0 {
+				return nil, fmt.Errorf("field '%v' has child fields and therefore must not have a type", f.Name)
+			}
+			if field.Fields, err = parquetFieldsToJSONSchema(f.Fields); err != nil {
+				return nil, fmt.Errorf("field '%v': %v", f.Name, err)
+			}
+			field.Tag = fmt.Sprintf("name=%v, repetitiontype=%v", f.Name, rep)
+		} else {
+			typeStr := strings.ToUpper(f.Type)
+			if _, exists := parquetTypes[typeStr]; !exists {
+				return nil, fmt.Errorf("field '%v' has unrecognised type: %v", f.Name, f.Type)
+			}
+			field.Tag = fmt.Sprintf("name=%v, type=%v, repetitiontype=%v", f.Name, typeStr, rep)
+		}
+		schemaFields = append(schemaFields, field)
+	}
+	return schemaFields, nil
+}
+
+// parquetSchemaString converts a list of schema fields into the JSON schema
+// format of the Parquet library.
+func parquetSchemaString(fields []ParquetSchemaFieldConfig) (string, error) {
+	schemaFields, err := parquetFieldsToJSONSchema(fields)
+	if err != nil {
+		return "", err
+	}
+	schemaBytes, err := json.Marshal(parquetJSONSchema{
+		Tag:    "name=parquet_go_root, repetitiontype=REQUIRED",
+		Fields: schemaFields,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(schemaBytes), nil
+}
+
+//------------------------------------------------------------------------------
+
+// parquetBuffer is an in memory implementation of the Parquet library file
+// interface.
+type parquetBuffer struct {
+	data   *[]byte
+	offset int64
+}
+
+func newParquetBuffer(data []byte) *parquetBuffer {
+	return &parquetBuffer{data: &data}
+}
+
+func (b *parquetBuffer) Bytes() []byte {
+	return *b.data
+}
+
+func (b *parquetBuffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += b.offset
+	case io.SeekEnd:
+		offset += int64(len(*b.data))
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	b.offset = offset
+	return offset, nil
+}
+
+func (b *parquetBuffer) Read(p []byte) (int, error) {
+	if b.offset >= int64(len(*b.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, (*b.data)[b.offset:])
+	b.offset += int64(n)
+	return n, nil
+}
+
+func (b *parquetBuffer) Write(p []byte) (int, error) {
+	end := b.offset + int64(len(p))
+	if extra := end - int64(len(*b.data)); extra > 0 {
+		*b.data = append(*b.data, make([]byte, extra)...)
+	}
+	copy((*b.data)[b.offset:end], p)
+	b.offset = end
+	return len(p), nil
+}
+
+func (b *parquetBuffer) Close() error {
+	return nil
+}
+
+func (b *parquetBuffer) Open(string) (source.ParquetFile, error) {
+	return &parquetBuffer{data: b.data}, nil
+}
+
+func (b *parquetBuffer) Create(string) (source.ParquetFile, error) {
+	return newParquetBuffer(nil), nil
+}
+
+//------------------------------------------------------------------------------
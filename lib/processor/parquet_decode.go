@@ -0,0 +1,248 @@
+package processor
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/tracing"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	olog "github.com/opentracing/opentracing-go/log"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/schema"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeParquetDecode] = TypeSpec{
+		constructor: NewParquetDecode,
+		Description: `
+EXPERIMENTAL: This processor is considered experimental and is therefore subject
+to change outside of major version releases.
+
+Decodes [Parquet](https://parquet.apache.org/) files into a batch of JSON
+objects, one for each row of the file. When a message is decoded the new
+messages replace the original message in the batch and adopt its metadata.
+Messages that fail to decode remain unchanged in the batch but are flagged as
+having failed.
+
+The schema and compression of each file are read from the file itself, and
+therefore this processor has no fields. Files without any rows are removed
+from the batch.
+
+Optional fields that are not set within a row are decoded as ` + "`null`" + `,
+and repeated fields that are empty are decoded as empty arrays.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// parquetSchemaNode is an element of a Parquet schema along with its children.
+type parquetSchemaNode struct {
+	inName   string
+	exName   string
+	element  *parquet.SchemaElement
+	children []*parquetSchemaNode
+}
+
+func newParquetSchemaTree(sh *schema.SchemaHandler) *parquetSchemaNode {
+	pos := 0
+	var build func() *parquetSchemaNode
+	build = func() *parquetSchemaNode {
+		n := &parquetSchemaNode{
+			inName:  sh.Infos[pos].InName,
+			exName:  sh.Infos[pos].ExName,
+			element: sh.SchemaElements[pos],
+		}
+		pos++
+		for i := int32(0); i < n.element.GetNumChildren(); i++ {
+			n.children = append(n.children, build())
+		}
+		return n
+	}
+	return build()
+}
+
+// matches returns whether the node has a single child with a single child
+// of a given name, which is how the Parquet library identifies lists and maps.
+func (n *parquetSchemaNode) matches(ct parquet.ConvertedType, child string, grandChildren ...string) bool {
+	if n.element.ConvertedType == nil || *n.element.ConvertedType != ct {
+		return false
+	}
+	if len(n.children) != 1 || n.children[0].inName != child {
+		return false
+	}
+	c := n.children[0]
+	if len(c.children) != len(grandChildren) {
+		return false
+	}
+	for i, name := range grandChildren {
+		if c.children[i].inName != name {
+			return false
+		}
+	}
+	return true
+}
+
+// toJSON converts a value read by the Parquet library into a JSON compatible
+// structure with the field names of the schema.
+func (n *parquetSchemaNode) toJSON(v reflect.Value) interface{} {
+	if n.element.GetRepetitionType() == parquet.FieldRepetitionType_REPEATED {
+		arr := make([]interface{}, v.Len())
+		for i := range arr {
+			arr[i] = n.elementToJSON(v.Index(i))
+		}
+		return arr
+	}
+	return n.elementToJSON(v)
+}
+
+func (n *parquetSchemaNode) elementToJSON(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if len(n.children) == 0 {
+		return v.Interface()
+	}
+
+	if n.matches(parquet.ConvertedType_LIST, "List", "Element") {
+		elementNode := n.children[0].children[0]
+		arr := make([]interface{}, v.Len())
+		for i := range arr {
+			arr[i] = elementNode.elementToJSON(v.Index(i))
+		}
+		return arr
+	}
+	if n.matches(parquet.ConvertedType_MAP, "Key_value", "Key", "Value") {
+		valueNode := n.children[0].children[1]
+		obj := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			obj[fmt.Sprintf("%v", iter.Key().Interface())] = valueNode.elementToJSON(iter.Value())
+		}
+		return obj
+	}
+
+	obj := make(map[string]interface{}, len(n.children))
+	for _, c := range n.children {
+		obj[c.exName] = c.toJSON(v.FieldByName(c.inName))
+	}
+	return obj
+}
+
+func parquetDecode(part types.Part) (parts []types.Part, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("parquet panic: %v", r)
+		}
+	}()
+
+	pr, err := reader.NewParquetReader(newParquetBuffer(part.Get()), nil, 1)
+	if err != nil {
+		return nil, err
+	}
+	defer pr.ReadStop()
+
+	rows, err := pr.ReadByNumber(int(pr.GetNumRows()))
+	if err != nil {
+		return nil, err
+	}
+
+	root := newParquetSchemaTree(pr.SchemaHandler)
+	parts = make([]types.Part, len(rows))
+	for i, row := range rows {
+		newPart := part.Copy()
+		if err = newPart.SetJSON(root.elementToJSON(reflect.ValueOf(row))); err != nil {
+			return nil, fmt.Errorf("failed to marshal row %v: %v", i, err)
+		}
+		parts[i] = newPart
+	}
+	return parts, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ParquetDecode is a processor that decodes Parquet files into a batch of JSON
+// objects.
+type ParquetDecode struct {
+	mCount     metrics.StatCounter
+	mErr       metrics.StatCounter
+	mSent      metrics.StatCounter
+	mBatchSent metrics.StatCounter
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewParquetDecode returns a ParquetDecode processor.
+func NewParquetDecode(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	return &ParquetDecode{
+		log:   log,
+		stats: stats,
+
+		mCount:     stats.GetCounter("count"),
+		mErr:       stats.GetCounter("error"),
+		mSent:      stats.GetCounter("sent"),
+		mBatchSent: stats.GetCounter("batch.sent"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ProcessMessage applies the processor to a message, either creating >0
+// resulting messages or a response to be sent back to the message source.
+func (p *ParquetDecode) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	p.mCount.Incr(1)
+
+	newMsg := message.New(nil)
+	msg.Iter(func(i int, part types.Part) error {
+		span := tracing.CreateChildSpan(TypeParquetDecode, part)
+		defer span.Finish()
+
+		newParts, err := parquetDecode(part)
+		if err == nil {
+			newMsg.Append(newParts...)
+		} else {
+			p.mErr.Incr(1)
+			p.log.Errorf("Failed to decode Parquet file: %v\n", err)
+			newMsg.Append(part)
+			FlagErr(newMsg.Get(-1), err)
+			span.LogFields(
+				olog.String("event", "error"),
+				olog.String("type", err.Error()),
+			)
+		}
+		return nil
+	})
+
+	if newMsg.Len() == 0 {
+		return nil, response.NewAck()
+	}
+
+	p.mBatchSent.Incr(1)
+	p.mSent.Incr(int64(newMsg.Len()))
+	msgs := [1]types.Message{newMsg}
+	return msgs[:], nil
+}
+
+// CloseAsync shuts down the processor and stops processing requests.
+func (p *ParquetDecode) CloseAsync() {
+}
+
+// WaitForClose blocks until the processor has closed down.
+func (p *ParquetDecode) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+)
+
+func TestParquetRoundTrip(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	input := []string{
+		`{"active":true,"events":[{"count":3,"kind":"click"},{"count":null,"kind":"view"}],"id":1,"location":{"label":"home","lat":51.5,"lon":-0.12},"name":"foo","score":1.5,"tags":["a","b"]}`,
+		`{"active":false,"events":[],"id":9007199254740993,"location":{"label":null,"lat":0,"lon":0},"name":null,"score":null,"tags":[]}`,
+		`{"active":true,"events":[{"count":-4,"kind":"buy"}],"id":-3,"location":null,"name":"","score":0,"tags":["c"]}`,
+	}
+	exp := []string{
+		input[0],
+		input[1],
+		input[2],
+		// Optional fields that are missing are decoded as null, repeated
+		// fields as empty arrays, and unknown fields are dropped.
+		`{"active":false,"events":[],"id":4,"location":null,"name":null,"score":null,"tags":[]}`,
+	}
+
+	for _, compression := range []string{"uncompressed", "snappy", "gzip", "zstd"} {
+		encConf := NewConfig()
+		encConf.ParquetEncode.Schema = testParquetSchema()
+		encConf.ParquetEncode.Compression = compression
+
+		enc, err := NewParquetEncode(encConf, nil, testLog, metrics.Noop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec, err := NewParquetDecode(NewConfig(), nil, testLog, metrics.Noop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var parts [][]byte
+		for _, doc := range input {
+			parts = append(parts, []byte(doc))
+		}
+		parts = append(parts, []byte(`{"id":4,"active":false,"unknown":"foo"}`))
+
+		msgs, res := enc.ProcessMessage(message.New(parts))
+		if res != nil {
+			t.Fatal(res.Error())
+		}
+		if len(msgs) != 1 || msgs[0].Len() != 1 {
+			t.Fatalf("%v: wrong encoded result: %v", compression, msgs)
+		}
+		if HasFailed(msgs[0].Get(0)) {
+			t.Fatalf("%v: %v", compression, msgs[0].Get(0).Metadata().Get(FailFlagKey))
+		}
+
+		msgs, res = dec.ProcessMessage(msgs[0])
+		if res != nil {
+			t.Fatal(res.Error())
+		}
+		if len(msgs) != 1 {
+			t.Fatalf("%v: wrong count of messages: %v", compression, len(msgs))
+		}
+		if act := message.GetAllBytes(msgs[0]); !reflect.DeepEqual(message.GetAllBytes(message.New(toBytes(exp))), act) {
+			t.Errorf("%v: wrong result: %s != %s", compression, act, exp)
+		}
+	}
+}
+
+func toBytes(strs []string) [][]byte {
+	var b [][]byte
+	for _, s := range strs {
+		b = append(b, []byte(s))
+	}
+	return b
+}
+
+func TestParquetDecodeBadFile(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	dec, err := NewParquetDecode(NewConfig(), nil, testLog, metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := message.New([][]byte{
+		[]byte(`not a parquet file`),
+		[]byte(`PAR1 still not a parquet file PAR1`),
+	})
+	input.Get(0).Metadata().Set("foo", "bar")
+
+	msgs, res := dec.ProcessMessage(input)
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("Wrong count of messages: %v", len(msgs))
+	}
+	if act, exp := message.GetAllBytes(msgs[0]), message.GetAllBytes(input); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	for i := 0; i < msgs[0].Len(); i++ {
+		if !HasFailed(msgs[0].Get(i)) {
+			t.Errorf("Expected part %v to be flagged", i)
+		}
+	}
+	if exp, act := "bar", msgs[0].Get(0).Metadata().Get("foo"); exp != act {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+}
@@ -0,0 +1,320 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message/tracing"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/x/docs"
+	olog "github.com/opentracing/opentracing-go/log"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeParquetEncode] = TypeSpec{
+		constructor: NewParquetEncode,
+		Description: `
+EXPERIMENTAL: This processor is considered experimental and is therefore subject
+to change outside of major version releases.
+
+Encodes all the messages of a batch as the rows of a single
+[Parquet](https://parquet.apache.org/) file according to a schema, the
+resulting message contains the file and adopts the metadata of the _first_
+message of the batch.
+
+Each message must be a JSON object. Fields of the object that are not part of
+the schema are ignored, and a message that is missing a required field or
+contains a value of the wrong type causes the whole batch to fail.
+
+This pairs well with outputs that write whole messages as files, such as
+` + "[`s3`](/docs/components/outputs/s3)" + ` and
+` + "[`file`](/docs/components/outputs/file)" + `, where the number of rows of
+each file can be controlled with a [batching policy](/docs/configuration/batching).
+
+### Schema
+
+The schema is a list of fields, where each field has a ` + "`name`" + ` and a
+` + "`type`" + `. Supported types are ` + "`BOOLEAN`, `INT32`, `INT64`, `FLOAT`, `DOUBLE`, `BYTE_ARRAY` and `UTF8`" + `,
+where ` + "`UTF8`" + ` is a string and ` + "`BYTE_ARRAY`" + ` a string of raw bytes.
+
+A field that has child ` + "`fields`" + ` is a nested object and must not have a
+type. A field can be marked ` + "`optional`" + `, in which case it can be
+missing or ` + "`null`" + `, or ` + "`repeated`" + `, in which case it must be
+an array, but not both.
+
+` + "```yaml" + `
+parquet_encode:
+  compression: snappy
+  schema:
+    - name: id
+      type: INT64
+    - name: name
+      type: UTF8
+      optional: true
+    - name: tags
+      type: UTF8
+      repeated: true
+    - name: location
+      optional: true
+      fields:
+        - name: lat
+          type: DOUBLE
+        - name: lon
+          type: DOUBLE
+` + "```" + ``,
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("schema", "A list of the fields of each row."),
+			docs.FieldCommon("compression", "The compression codec to use for the columns of the file.").HasOptions("uncompressed", "snappy", "gzip", "zstd"),
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// ParquetEncodeConfig contains configuration fields for the ParquetEncode
+// processor.
+type ParquetEncodeConfig struct {
+	Schema      []ParquetSchemaFieldConfig `json:"schema" yaml:"schema"`
+	Compression string                     `json:"compression" yaml:"compression"`
+}
+
+// NewParquetEncodeConfig returns a ParquetEncodeConfig with default values.
+func NewParquetEncodeConfig() ParquetEncodeConfig {
+	return ParquetEncodeConfig{
+		Schema:      []ParquetSchemaFieldConfig{},
+		Compression: "snappy",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func strToParquetCompression(str string) (parquet.CompressionCodec, error) {
+	switch str {
+	case "uncompressed":
+		return parquet.CompressionCodec_UNCOMPRESSED, nil
+	case "snappy":
+		return parquet.CompressionCodec_SNAPPY, nil
+	case "gzip":
+		return parquet.CompressionCodec_GZIP, nil
+	case "zstd":
+		return parquet.CompressionCodec_ZSTD, nil
+	}
+	return 0, fmt.Errorf("compression codec not recognised: %v", str)
+}
+
+// checkParquetValue returns an error if a JSON value cannot be written to a
+// field of a Parquet schema, since values of the wrong type would otherwise be
+// silently zeroed.
+func checkParquetValue(path string, f ParquetSchemaFieldConfig, v interface{}) error {
+	if len(f.Fields) > 0 {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("field '%v' must be an object", path)
+		}
+		return checkParquetObject(path+".", f.Fields, obj)
+	}
+
+	switch strings.ToUpper(f.Type) {
+	case "BOOLEAN":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("field '%v' must be a boolean", path)
+		}
+	case "INT32", "INT64":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fmt.Errorf("field '%v' must be a number", path)
+		}
+		i, err := n.Int64()
+		if err != nil {
+			return fmt.Errorf("field '%v' must be an integer", path)
+		}
+		if strings.ToUpper(f.Type) == "INT32" && (i < math.MinInt32 || i > math.MaxInt32) {
+			return fmt.Errorf("field '%v' exceeds the range of INT32", path)
+		}
+	case "FLOAT", "DOUBLE":
+		if _, ok := v.(json.Number); !ok {
+			return fmt.Errorf("field '%v' must be a number", path)
+		}
+	default:
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("field '%v' must be a string", path)
+		}
+	}
+	return nil
+}
+
+func checkParquetObject(prefix string, fields []ParquetSchemaFieldConfig, obj map[string]interface{}) error {
+	for _, f := range fields {
+		path := prefix + f.Name
+		v := obj[f.Name]
+		if v == nil {
+			if !f.Optional && !f.Repeated {
+				return fmt.Errorf("field '%v' is required", path)
+			}
+			continue
+		}
+		if !f.Repeated {
+			if err := checkParquetValue(path, f, v); err != nil {
+				return err
+			}
+			continue
+		}
+		arr, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("field '%v' must be an array", path)
+		}
+		for i, e := range arr {
+			if err := checkParquetValue(fmt.Sprintf("%v.%v", path, i), f, e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// ParquetEncode is a processor that encodes the messages of a batch as the
+// rows of a Parquet file.
+type ParquetEncode struct {
+	conf        ParquetEncodeConfig
+	schema      string
+	compression parquet.CompressionCodec
+
+	mCount     metrics.StatCounter
+	mErr       metrics.StatCounter
+	mSucc      metrics.StatCounter
+	mSent      metrics.StatCounter
+	mBatchSent metrics.StatCounter
+
+	log   log.Modular
+	stats metrics.Type
+}
+
+// NewParquetEncode returns a ParquetEncode processor.
+func NewParquetEncode(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	schema, err := parquetSchemaString(conf.ParquetEncode.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %v", err)
+	}
+	compression, err := strToParquetCompression(conf.ParquetEncode.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ParquetEncode{
+		conf:        conf.ParquetEncode,
+		schema:      schema,
+		compression: compression,
+		log:         log,
+		stats:       stats,
+
+		mCount:     stats.GetCounter("count"),
+		mErr:       stats.GetCounter("error"),
+		mSucc:      stats.GetCounter("success"),
+		mSent:      stats.GetCounter("sent"),
+		mBatchSent: stats.GetCounter("batch.sent"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (p *ParquetEncode) encode(msg types.Message) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("parquet panic: %v", r)
+		}
+	}()
+
+	buf := newParquetBuffer(nil)
+	pw, err := writer.NewJSONWriter(p.schema, buf, 1)
+	if err != nil {
+		return nil, err
+	}
+	pw.CompressionType = p.compression
+
+	if err = msg.Iter(func(i int, part types.Part) error {
+		dec := json.NewDecoder(bytes.NewReader(part.Get()))
+		dec.UseNumber()
+
+		var obj map[string]interface{}
+		if jerr := dec.Decode(&obj); jerr != nil {
+			return fmt.Errorf("failed to parse message %v as a JSON object: %v", i, jerr)
+		}
+		if cerr := checkParquetObject("", p.conf.Schema, obj); cerr != nil {
+			return fmt.Errorf("message %v: %v", i, cerr)
+		}
+		return pw.Write(string(part.Get()))
+	}); err != nil {
+		return nil, err
+	}
+	if err = pw.WriteStop(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ProcessMessage applies the processor to a message, either creating >0
+// resulting messages or a response to be sent back to the message source.
+func (p *ParquetEncode) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	p.mCount.Incr(1)
+
+	if msg.Len() == 0 {
+		return nil, response.NewAck()
+	}
+
+	p.mSent.Incr(1)
+	p.mBatchSent.Incr(1)
+
+	newMsg := msg.Copy()
+
+	spans := tracing.CreateChildSpans(TypeParquetEncode, newMsg)
+	data, err := p.encode(msg)
+	if err != nil {
+		newMsg.Iter(func(i int, part types.Part) error {
+			FlagErr(part, err)
+			spans[i].LogFields(
+				olog.String("event", "error"),
+				olog.String("type", err.Error()),
+			)
+			return nil
+		})
+		p.log.Errorf("Failed to encode Parquet file: %v\n", err)
+		p.mErr.Incr(1)
+	} else {
+		p.mSucc.Incr(1)
+		newPart := msg.Get(0).Copy()
+		newPart.Set(data)
+		newMsg.SetAll([]types.Part{newPart})
+	}
+	for _, s := range spans {
+		s.Finish()
+	}
+
+	msgs := [1]types.Message{newMsg}
+	return msgs[:], nil
+}
+
+// CloseAsync shuts down the processor and stops processing requests.
+func (p *ParquetEncode) CloseAsync() {
+}
+
+// WaitForClose blocks until the processor has closed down.
+func (p *ParquetEncode) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
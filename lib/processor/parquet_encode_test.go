@@ -0,0 +1,162 @@
+package processor
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+)
+
+func testParquetSchema() []ParquetSchemaFieldConfig {
+	return []ParquetSchemaFieldConfig{
+		{Name: "id", Type: "INT64"},
+		{Name: "name", Type: "UTF8", Optional: true},
+		{Name: "score", Type: "DOUBLE", Optional: true},
+		{Name: "active", Type: "BOOLEAN"},
+		{Name: "tags", Type: "UTF8", Repeated: true},
+		{Name: "location", Optional: true, Fields: []ParquetSchemaFieldConfig{
+			{Name: "lat", Type: "DOUBLE"},
+			{Name: "lon", Type: "DOUBLE"},
+			{Name: "label", Type: "UTF8", Optional: true},
+		}},
+		{Name: "events", Repeated: true, Fields: []ParquetSchemaFieldConfig{
+			{Name: "kind", Type: "UTF8"},
+			{Name: "count", Type: "INT32", Optional: true},
+		}},
+	}
+}
+
+func TestParquetEncodeBadConfig(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	tests := map[string]func(c *ParquetEncodeConfig){
+		"no fields": func(c *ParquetEncodeConfig) {
+			c.Schema = nil
+		},
+		"bad compression": func(c *ParquetEncodeConfig) {
+			c.Compression = "nope"
+		},
+		"bad type": func(c *ParquetEncodeConfig) {
+			c.Schema[0].Type = "nope"
+		},
+		"no name": func(c *ParquetEncodeConfig) {
+			c.Schema[0].Name = ""
+		},
+		"bad name": func(c *ParquetEncodeConfig) {
+			c.Schema[0].Name = "foo, type=INT32"
+		},
+		"clashing names": func(c *ParquetEncodeConfig) {
+			c.Schema[1].Name = "Id"
+		},
+		"optional and repeated": func(c *ParquetEncodeConfig) {
+			c.Schema[4].Optional = true
+		},
+		"group with type": func(c *ParquetEncodeConfig) {
+			c.Schema[5].Type = "UTF8"
+		},
+		"bad child type": func(c *ParquetEncodeConfig) {
+			c.Schema[5].Fields[0].Type = "nope"
+		},
+	}
+
+	for name, fn := range tests {
+		conf := NewConfig()
+		conf.ParquetEncode.Schema = testParquetSchema()
+		fn(&conf.ParquetEncode)
+		if _, err := NewParquetEncode(conf, nil, testLog, metrics.Noop()); err == nil {
+			t.Errorf("%v: expected error", name)
+		}
+	}
+}
+
+func TestParquetEncodeBadMessages(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.ParquetEncode.Schema = testParquetSchema()
+
+	proc, err := NewParquetEncode(conf, nil, testLog, metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"not json":         `not json`,
+		"not an object":    `[1,2,3]`,
+		"missing required": `{"active":true}`,
+		"null required":    `{"id":null,"active":true}`,
+		"wrong type":       `{"id":"foo","active":true}`,
+		"not an integer":   `{"id":1.5,"active":true}`,
+		"not a boolean":    `{"id":1,"active":"true"}`,
+		"not a string":     `{"id":1,"active":true,"name":5}`,
+		"not an array":     `{"id":1,"active":true,"tags":"foo"}`,
+		"null element":     `{"id":1,"active":true,"tags":["foo",null]}`,
+		"not a group":      `{"id":1,"active":true,"location":"foo"}`,
+		"missing child":    `{"id":1,"active":true,"location":{"lat":1}}`,
+		"int32 overflow":   `{"id":1,"active":true,"events":[{"kind":"foo","count":3000000000}]}`,
+	}
+
+	for name, doc := range tests {
+		input := message.New([][]byte{
+			[]byte(`{"id":1,"active":true}`),
+			[]byte(doc),
+		})
+		msgs, res := proc.ProcessMessage(input)
+		if res != nil {
+			t.Fatalf("%v: %v", name, res.Error())
+		}
+		if len(msgs) != 1 {
+			t.Fatalf("%v: wrong count of messages: %v", name, len(msgs))
+		}
+		if exp, act := 2, msgs[0].Len(); exp != act {
+			t.Errorf("%v: wrong count of parts: %v != %v", name, act, exp)
+		}
+		for i := 0; i < msgs[0].Len(); i++ {
+			if !HasFailed(msgs[0].Get(i)) {
+				t.Errorf("%v: expected part %v to be flagged", name, i)
+			}
+		}
+	}
+}
+
+func TestParquetEncodeMetadata(t *testing.T) {
+	testLog := log.New(os.Stdout, log.Config{LogLevel: "NONE"})
+
+	conf := NewConfig()
+	conf.ParquetEncode.Schema = testParquetSchema()
+
+	proc, err := NewParquetEncode(conf, nil, testLog, metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := message.New([][]byte{
+		[]byte(`{"id":1,"active":true}`),
+		[]byte(`{"id":2,"active":false}`),
+	})
+	input.Get(0).Metadata().Set("foo", "bar")
+	input.Get(1).Metadata().Set("foo", "baz")
+
+	msgs, res := proc.ProcessMessage(input)
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if len(msgs) != 1 || msgs[0].Len() != 1 {
+		t.Fatalf("Wrong result: %v", msgs)
+	}
+	if HasFailed(msgs[0].Get(0)) {
+		t.Fatal(msgs[0].Get(0).Metadata().Get(FailFlagKey))
+	}
+	if exp, act := "bar", msgs[0].Get(0).Metadata().Get("foo"); exp != act {
+		t.Errorf("Wrong metadata: %v != %v", act, exp)
+	}
+	if exp, act := "PAR1", string(msgs[0].Get(0).Get()[:4]); exp != act {
+		t.Errorf("Wrong magic bytes: %v != %v", act, exp)
+	}
+
+	if msgs, res = proc.ProcessMessage(message.New(nil)); res == nil || len(msgs) != 0 {
+		t.Error("Expected empty batch to be acknowledged")
+	}
+}
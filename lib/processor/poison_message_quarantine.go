@@ -0,0 +1,371 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/tracing"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/text"
+	"github.com/Jeffail/benthos/v3/lib/x/docs"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypePoisonMessageQuarantine] = TypeSpec{
+		constructor: NewPoisonMessageQuarantine,
+		Description: `
+Tracks consecutive processing failures of messages per key and routes the
+messages of keys that exceed a threshold to a quarantine pipe.
+
+This processor should be placed after the processors that are being monitored
+for failures. For each message part a key is resolved from the ` + "`key`" + `
+field and, when the part has been
+[flagged as failed](/docs/configuration/error_handling), the consecutive failure
+count of that key is incremented within the configured ` + "`cache`" + `. A part that succeeds resets the count of its key.
+
+Once the count of a key reaches ` + "`max_failures`" + ` that part and all
+subsequent parts of the same key are removed from the batch and sent to the
+` + "[`inproc`](/docs/components/inputs/inproc)" + ` pipe named by
+` + "`quarantine_output`" + `, allowing parts of other keys to continue flowing.
+Consume the quarantined messages with an ` + "`inproc`" + ` input of the same
+name and route them to any output in order to inspect and replay them.
+
+Keys remain quarantined until their entries are removed from the cache, it is
+therefore recommended that you use a cache with a TTL in order to release keys
+automatically after a period.
+
+When the quarantined parts of a batch are not accepted and acknowledged by the
+pipe within ` + "`timeout`" + `, for example when nothing is consuming from it,
+the whole batch fails and is retried by the input.
+
+The field ` + "`on_cache_error`" + ` determines what happens to a part when the
+cache cannot be reached. When set to ` + "`passthrough`" + ` (the default) the
+part continues unchanged, when set to ` + "`skip`" + ` the part is removed from
+the batch, and when set to ` + "`fail`" + ` the part continues flagged as failed.`,
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("key", "An interpolated key identifying the group each message part is tracked under.", "${!metadata:kafka_key}").SupportsInterpolation(false),
+			docs.FieldCommon("max_failures", "The number of consecutive failures of a key before its messages are quarantined."),
+			docs.FieldCommon("cache", "The [`cache` resource](/docs/components/caches/about) to store failure counts in."),
+			docs.FieldCommon("quarantine_output", "The name of an `inproc` pipe to send quarantined messages to."),
+			docs.FieldAdvanced("on_cache_error", "What to do with a message part when the cache cannot be reached.").HasOptions("fail", "skip", "passthrough"),
+			docs.FieldAdvanced("timeout", "The maximum period to wait for quarantined messages to be accepted and acknowledged by the quarantine pipe."),
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// PoisonMessageQuarantineConfig contains configuration fields for the
+// PoisonMessageQuarantine processor.
+type PoisonMessageQuarantineConfig struct {
+	Key              string `json:"key" yaml:"key"`
+	MaxFailures      int    `json:"max_failures" yaml:"max_failures"`
+	Cache            string `json:"cache" yaml:"cache"`
+	QuarantineOutput string `json:"quarantine_output" yaml:"quarantine_output"`
+	OnCacheError     string `json:"on_cache_error" yaml:"on_cache_error"`
+	Timeout          string `json:"timeout" yaml:"timeout"`
+}
+
+// NewPoisonMessageQuarantineConfig returns a PoisonMessageQuarantineConfig with
+// default values.
+func NewPoisonMessageQuarantineConfig() PoisonMessageQuarantineConfig {
+	return PoisonMessageQuarantineConfig{
+		Key:              "",
+		MaxFailures:      3,
+		Cache:            "",
+		QuarantineOutput: "",
+		OnCacheError:     "passthrough",
+		Timeout:          "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// quarantinePipe is a transaction channel registered with a manager that is
+// shared by all processors writing to the same pipe name, since only one
+// producer can be registered under a name at a time.
+type quarantinePipe struct {
+	refs int
+	mgr  types.Manager
+	name string
+	ch   chan types.Transaction
+}
+
+type quarantinePipeKey struct {
+	mgr  types.Manager
+	name string
+}
+
+var (
+	quarantinePipes    = map[quarantinePipeKey]*quarantinePipe{}
+	quarantinePipesMut sync.Mutex
+)
+
+func acquireQuarantinePipe(mgr types.Manager, name string) *quarantinePipe {
+	quarantinePipesMut.Lock()
+	defer quarantinePipesMut.Unlock()
+
+	key := quarantinePipeKey{mgr: mgr, name: name}
+	p, exists := quarantinePipes[key]
+	if !exists {
+		p = &quarantinePipe{
+			mgr:  mgr,
+			name: name,
+			ch:   make(chan types.Transaction),
+		}
+		mgr.SetPipe(name, p.ch)
+		quarantinePipes[key] = p
+	}
+	p.refs++
+	return p
+}
+
+// quarantineCountMuts contains a mutex for each cache that failure counts are
+// stored in, which serialises the updates of counts by processors that share a
+// cache, including the parallel copies of a processor of each pipeline thread.
+var quarantineCountMuts sync.Map
+
+func quarantineCountMut(c types.Cache) *sync.Mutex {
+	mut, _ := quarantineCountMuts.LoadOrStore(c, &sync.Mutex{})
+	return mut.(*sync.Mutex)
+}
+
+func (p *quarantinePipe) release() {
+	quarantinePipesMut.Lock()
+	defer quarantinePipesMut.Unlock()
+
+	if p.refs--; p.refs == 0 {
+		p.mgr.UnsetPipe(p.name, p.ch)
+		delete(quarantinePipes, quarantinePipeKey{mgr: p.mgr, name: p.name})
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// PoisonMessageQuarantine is a processor that counts consecutive failures of
+// message parts by key and diverts the parts of a key to a quarantine pipe
+// after a threshold is reached.
+type PoisonMessageQuarantine struct {
+	log log.Modular
+
+	key         *text.InterpolatedString
+	maxFailures int
+	cache       types.Cache
+	cacheMut    *sync.Mutex
+	errPolicy   cacheErrPolicy
+	pipe        *quarantinePipe
+	timeout     time.Duration
+
+	mCount       metrics.StatCounter
+	mQuarantined metrics.StatCounter
+	mErrCache    metrics.StatCounter
+	mErrSend     metrics.StatCounter
+	mSkipped     metrics.StatCounter
+	mErr         metrics.StatCounter
+	mSent        metrics.StatCounter
+	mBatchSent   metrics.StatCounter
+
+	closeChan chan struct{}
+	closeOnce sync.Once
+}
+
+// NewPoisonMessageQuarantine returns a PoisonMessageQuarantine processor.
+func NewPoisonMessageQuarantine(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	pConf := conf.PoisonMessageQuarantine
+	if len(pConf.Key) == 0 {
+		return nil, errors.New("a key must be specified")
+	}
+	if pConf.MaxFailures < 1 {
+		return nil, errors.New("max_failures must be at least 1")
+	}
+	if len(pConf.QuarantineOutput) == 0 {
+		return nil, errors.New("a quarantine_output must be specified")
+	}
+	errPolicy, err := parseCacheErrPolicy(pConf.OnCacheError, cacheErrPolicyPassthrough)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := time.ParseDuration(pConf.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+	if timeout <= 0 {
+		return nil, errors.New("timeout must be greater than zero")
+	}
+	c, err := mgr.GetCache(pConf.Cache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain cache resource '%v': %v", pConf.Cache, err)
+	}
+	return &PoisonMessageQuarantine{
+		log:         log,
+		key:         text.NewInterpolatedString(pConf.Key),
+		maxFailures: pConf.MaxFailures,
+		cache:       c,
+		cacheMut:    quarantineCountMut(c),
+		errPolicy:   errPolicy,
+		pipe:        acquireQuarantinePipe(mgr, pConf.QuarantineOutput),
+		timeout:     timeout,
+
+		mCount:       stats.GetCounter("count"),
+		mQuarantined: stats.GetCounter("quarantined"),
+		mErrCache:    stats.GetCounter("error.cache"),
+		mErrSend:     stats.GetCounter("error.send"),
+		mSkipped:     stats.GetCounter("skipped"),
+		mErr:         stats.GetCounter("error"),
+		mSent:        stats.GetCounter("sent"),
+		mBatchSent:   stats.GetCounter("batch.sent"),
+
+		closeChan: make(chan struct{}),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// failures returns the current consecutive failure count of a key.
+func (p *PoisonMessageQuarantine) failures(key string) (int, error) {
+	v, err := p.cache.Get(key)
+	if err == types.ErrKeyNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(v))
+}
+
+// quarantined updates the failure count of a key according to the status of a
+// part and returns whether the part should be quarantined.
+func (p *PoisonMessageQuarantine) quarantined(key string, part types.Part) (bool, error) {
+	p.cacheMut.Lock()
+	defer p.cacheMut.Unlock()
+
+	count, err := p.failures(key)
+	if err != nil {
+		return false, err
+	}
+	if count >= p.maxFailures {
+		return true, nil
+	}
+	if !HasFailed(part) {
+		if count > 0 {
+			return false, p.cache.Delete(key)
+		}
+		return false, nil
+	}
+	count++
+	if err = p.cache.Set(key, []byte(strconv.Itoa(count))); err != nil {
+		return false, err
+	}
+	return count >= p.maxFailures, nil
+}
+
+// sendQuarantined writes a batch to the quarantine pipe and awaits its
+// acknowledgement, giving up once the timeout is reached.
+func (p *PoisonMessageQuarantine) sendQuarantined(msg types.Message) error {
+	ctx, done := context.WithTimeout(context.Background(), p.timeout)
+	defer done()
+
+	// The response channel is buffered so that a late acknowledgement does not
+	// block the consumer after the timeout is reached.
+	resChan := make(chan types.Response, 1)
+	select {
+	case p.pipe.ch <- types.NewTransaction(msg, resChan):
+	case <-ctx.Done():
+		return types.ErrTimeout
+	case <-p.closeChan:
+		return types.ErrTypeClosed
+	}
+	select {
+	case res := <-resChan:
+		return res.Error()
+	case <-ctx.Done():
+		return types.ErrTimeout
+	case <-p.closeChan:
+		return types.ErrTypeClosed
+	}
+}
+
+// ProcessMessage applies the processor to a message, either creating >0
+// resulting messages or a response to be sent back to the message source.
+func (p *PoisonMessageQuarantine) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	p.mCount.Incr(1)
+
+	spans := tracing.CreateChildSpans(TypePoisonMessageQuarantine, msg)
+	defer func() {
+		for _, s := range spans {
+			s.Finish()
+		}
+	}()
+
+	passed := message.New(nil)
+	quarantined := message.New(nil)
+
+	msg.Iter(func(i int, part types.Part) error {
+		key := p.key.Get(message.Lock(msg, i))
+		isQuarantined, err := p.quarantined(key, part)
+		if err != nil {
+			p.mErrCache.Incr(1)
+			p.mErr.Incr(1)
+			p.log.Errorf("Cache error for key '%v': %v\n", key, err)
+			switch p.errPolicy {
+			case cacheErrPolicySkip:
+				p.mSkipped.Incr(1)
+				return nil
+			case cacheErrPolicyFail:
+				part = part.Copy()
+				FlagErr(part, err)
+			}
+		}
+		if isQuarantined {
+			quarantined.Append(part)
+		} else {
+			passed.Append(part)
+		}
+		return nil
+	})
+
+	if quarantined.Len() > 0 {
+		if err := p.sendQuarantined(quarantined); err != nil {
+			p.mErrSend.Incr(1)
+			p.mErr.Incr(1)
+			p.log.Errorf("Failed to send quarantined messages: %v\n", err)
+			return nil, response.NewError(err)
+		}
+		p.mQuarantined.Incr(int64(quarantined.Len()))
+	}
+
+	if passed.Len() == 0 {
+		return nil, response.NewAck()
+	}
+
+	p.mBatchSent.Incr(1)
+	p.mSent.Incr(int64(passed.Len()))
+	return []types.Message{passed}, nil
+}
+
+// CloseAsync shuts down the processor and stops processing requests.
+func (p *PoisonMessageQuarantine) CloseAsync() {
+	p.closeOnce.Do(func() {
+		close(p.closeChan)
+		p.pipe.release()
+	})
+}
+
+// WaitForClose blocks until the processor has closed down.
+func (p *PoisonMessageQuarantine) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
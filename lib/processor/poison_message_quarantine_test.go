@@ -0,0 +1,375 @@
+package processor
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/cache"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+type pipeMgr struct {
+	fakeMgr
+	pipes map[string]<-chan types.Transaction
+}
+
+func (p *pipeMgr) GetPipe(name string) (<-chan types.Transaction, error) {
+	if t, exists := p.pipes[name]; exists {
+		return t, nil
+	}
+	return nil, types.ErrPipeNotFound
+}
+
+func (p *pipeMgr) SetPipe(name string, t <-chan types.Transaction) {
+	p.pipes[name] = t
+}
+
+func (p *pipeMgr) UnsetPipe(name string, t <-chan types.Transaction) {
+	if p.pipes[name] == t {
+		delete(p.pipes, name)
+	}
+}
+
+func TestPoisonMessageQuarantine(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &pipeMgr{
+		fakeMgr: fakeMgr{
+			caches: map[string]types.Cache{"foocache": memCache},
+		},
+		pipes: map[string]<-chan types.Transaction{},
+	}
+
+	conf := NewConfig()
+	conf.Type = TypePoisonMessageQuarantine
+	conf.PoisonMessageQuarantine.Key = "${!metadata:key}"
+	conf.PoisonMessageQuarantine.MaxFailures = 2
+	conf.PoisonMessageQuarantine.Cache = "foocache"
+	conf.PoisonMessageQuarantine.QuarantineOutput = "quarantine"
+
+	proc, err := New(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qChan, err := mgr.GetPipe("quarantine")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newPart := func(key, content string, failed bool) types.Part {
+		p := message.NewPart([]byte(content))
+		p.Metadata().Set("key", key)
+		if failed {
+			FlagErr(p, errors.New("nope"))
+		}
+		return p
+	}
+
+	type quarantineRes struct {
+		contents [][]byte
+	}
+	recv := func() <-chan quarantineRes {
+		resChan := make(chan quarantineRes, 1)
+		go func() {
+			select {
+			case ts := <-qChan:
+				resChan <- quarantineRes{contents: message.GetAllBytes(ts.Payload)}
+				ts.ResponseChan <- response.NewAck()
+			case <-time.After(time.Second):
+				close(resChan)
+			}
+		}()
+		return resChan
+	}
+
+	// First failure of foo is tracked but not quarantined.
+	msg := message.New(nil)
+	msg.Append(newPart("foo", "foo1", true))
+	msg.Append(newPart("bar", "bar1", false))
+	msgs, res := proc.ProcessMessage(msg)
+	if res != nil {
+		t.Fatal(res.Error())
+	}
+	if exp, act := [][]byte{[]byte("foo1"), []byte("bar1")}, message.GetAllBytes(msgs[0]); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+
+	// Second failure of foo reaches the threshold.
+	qRes := recv()
+	msg = message.New(nil)
+	msg.Append(newPart("foo", "foo2", true))
+	msg.Append(newPart("bar", "bar2", false))
+	if msgs, res = proc.ProcessMessage(msg); res != nil {
+		t.Fatal(res.Error())
+	}
+	if exp, act := [][]byte{[]byte("bar2")}, message.GetAllBytes(msgs[0]); !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong result: %s != %s", act, exp)
+	}
+	if q, open := <-qRes; !open {
+		t.Fatal("Timed out waiting for quarantined message")
+	} else if exp, act := [][]byte{[]byte("foo2")}, q.contents; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong quarantined result: %s != %s", act, exp)
+	}
+
+	// Subsequent successful messages of foo remain quarantined.
+	qRes = recv()
+	msg = message.New(nil)
+	msg.Append(newPart("foo", "foo3", false))
+	if msgs, res = proc.ProcessMessage(msg); res == nil || res.Error() != nil {
+		t.Fatalf("Expected ack response, received: %v", res)
+	}
+	if len(msgs) != 0 {
+		t.Errorf("Expected no messages, received: %v", len(msgs))
+	}
+	if q, open := <-qRes; !open {
+		t.Fatal("Timed out waiting for quarantined message")
+	} else if exp, act := [][]byte{[]byte("foo3")}, q.contents; !reflect.DeepEqual(exp, act) {
+		t.Errorf("Wrong quarantined result: %s != %s", act, exp)
+	}
+
+	proc.CloseAsync()
+	if err = proc.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+	if _, err = mgr.GetPipe("quarantine"); err != types.ErrPipeNotFound {
+		t.Errorf("Expected pipe to be removed, received: %v", err)
+	}
+}
+
+func TestPoisonMessageQuarantineResetOnSuccess(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &pipeMgr{
+		fakeMgr: fakeMgr{
+			caches: map[string]types.Cache{"foocache": memCache},
+		},
+		pipes: map[string]<-chan types.Transaction{},
+	}
+
+	conf := NewConfig()
+	conf.PoisonMessageQuarantine.Key = "${!metadata:key}"
+	conf.PoisonMessageQuarantine.MaxFailures = 2
+	conf.PoisonMessageQuarantine.Cache = "foocache"
+	conf.PoisonMessageQuarantine.QuarantineOutput = "quarantine"
+
+	proc, err := NewPoisonMessageQuarantine(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.CloseAsync()
+
+	for _, failed := range []bool{true, false, true, false} {
+		part := message.NewPart([]byte("foo"))
+		part.Metadata().Set("key", "foo")
+		if failed {
+			FlagErr(part, errors.New("nope"))
+		}
+		msg := message.New(nil)
+		msg.Append(part)
+		msgs, res := proc.ProcessMessage(msg)
+		if res != nil {
+			t.Fatal(res.Error())
+		}
+		if exp, act := 1, msgs[0].Len(); exp != act {
+			t.Errorf("Wrong count of messages: %v != %v", act, exp)
+		}
+	}
+}
+
+func TestPoisonMessageQuarantineOnCacheError(t *testing.T) {
+	mgr := &pipeMgr{
+		fakeMgr: fakeMgr{
+			caches: map[string]types.Cache{"foocache": errCache{}},
+		},
+		pipes: map[string]<-chan types.Transaction{},
+	}
+
+	tests := map[string]struct {
+		expLen    int
+		expFailed bool
+	}{
+		"passthrough": {expLen: 2, expFailed: false},
+		"fail":        {expLen: 2, expFailed: true},
+		"skip":        {expLen: 0},
+	}
+
+	for policy, test := range tests {
+		conf := NewConfig()
+		conf.PoisonMessageQuarantine.Key = "${!metadata:key}"
+		conf.PoisonMessageQuarantine.Cache = "foocache"
+		conf.PoisonMessageQuarantine.QuarantineOutput = "quarantine"
+		conf.PoisonMessageQuarantine.OnCacheError = policy
+
+		proc, err := NewPoisonMessageQuarantine(conf, mgr, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msgs, res := proc.ProcessMessage(message.New([][]byte{[]byte("foo"), []byte("bar")}))
+		if test.expLen == 0 {
+			if exp := response.NewAck(); !reflect.DeepEqual(exp, res) || len(msgs) > 0 {
+				t.Errorf("%v: Expected message drop on error: %v - %v", policy, res, len(msgs))
+			}
+		} else if len(msgs) != 1 || msgs[0].Len() != test.expLen {
+			t.Errorf("%v: Wrong result: %v - %v", policy, res, msgs)
+		} else {
+			msgs[0].Iter(func(i int, p types.Part) error {
+				if act := HasFailed(p); act != test.expFailed {
+					t.Errorf("%v: Wrong fail flag of part %v: %v != %v", policy, i, act, test.expFailed)
+				}
+				return nil
+			})
+		}
+
+		proc.CloseAsync()
+	}
+}
+
+func TestPoisonMessageQuarantineBadConfig(t *testing.T) {
+	mgr := &pipeMgr{
+		fakeMgr: fakeMgr{
+			caches: map[string]types.Cache{},
+		},
+		pipes: map[string]<-chan types.Transaction{},
+	}
+
+	conf := NewConfig()
+	conf.PoisonMessageQuarantine.Key = "${!metadata:key}"
+	conf.PoisonMessageQuarantine.Cache = "foocache"
+	conf.PoisonMessageQuarantine.QuarantineOutput = "quarantine"
+
+	if _, err := NewPoisonMessageQuarantine(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from missing cache")
+	}
+
+	conf.PoisonMessageQuarantine.MaxFailures = 0
+	if _, err := NewPoisonMessageQuarantine(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from zero max_failures")
+	}
+
+	conf.PoisonMessageQuarantine.MaxFailures = 3
+	conf.PoisonMessageQuarantine.Timeout = "0s"
+	if _, err := NewPoisonMessageQuarantine(conf, mgr, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from zero timeout")
+	}
+}
+
+// slowGetCache delays reads in order to widen the window between reading and
+// writing a failure count.
+type slowGetCache struct {
+	types.Cache
+}
+
+func (s slowGetCache) Get(key string) ([]byte, error) {
+	v, err := s.Cache.Get(key)
+	<-time.After(time.Microsecond * 100)
+	return v, err
+}
+
+func TestPoisonMessageQuarantineParallel(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &pipeMgr{
+		fakeMgr: fakeMgr{
+			caches: map[string]types.Cache{"foocache": slowGetCache{memCache}},
+		},
+		pipes: map[string]<-chan types.Transaction{},
+	}
+
+	conf := NewConfig()
+	conf.PoisonMessageQuarantine.Key = "${!metadata:key}"
+	conf.PoisonMessageQuarantine.MaxFailures = 1000
+	conf.PoisonMessageQuarantine.Cache = "foocache"
+	conf.PoisonMessageQuarantine.QuarantineOutput = "quarantine"
+
+	// Each pipeline thread has its own copy of the processor.
+	procs := make([]Type, 4)
+	for i := range procs {
+		if procs[i], err = NewPoisonMessageQuarantine(conf, mgr, log.Noop(), metrics.Noop()); err != nil {
+			t.Fatal(err)
+		}
+		defer procs[i].CloseAsync()
+	}
+
+	wg := sync.WaitGroup{}
+	for _, proc := range procs {
+		wg.Add(1)
+		go func(proc Type) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				part := message.NewPart([]byte("foo"))
+				part.Metadata().Set("key", "foo")
+				FlagErr(part, errors.New("nope"))
+				msg := message.New(nil)
+				msg.Append(part)
+				if _, res := proc.ProcessMessage(msg); res != nil {
+					t.Error(res.Error())
+				}
+			}
+		}(proc)
+	}
+	wg.Wait()
+
+	v, err := memCache.Get("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "200", string(v); exp != act {
+		t.Errorf("Wrong failure count: %v != %v", act, exp)
+	}
+}
+
+func TestPoisonMessageQuarantineTimeout(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := &pipeMgr{
+		fakeMgr: fakeMgr{
+			caches: map[string]types.Cache{"foocache": memCache},
+		},
+		pipes: map[string]<-chan types.Transaction{},
+	}
+
+	conf := NewConfig()
+	conf.PoisonMessageQuarantine.Key = "${!metadata:key}"
+	conf.PoisonMessageQuarantine.MaxFailures = 1
+	conf.PoisonMessageQuarantine.Cache = "foocache"
+	conf.PoisonMessageQuarantine.QuarantineOutput = "quarantine"
+	conf.PoisonMessageQuarantine.Timeout = "10ms"
+
+	proc, err := NewPoisonMessageQuarantine(conf, mgr, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proc.CloseAsync()
+
+	// Nothing consumes from the quarantine pipe.
+	part := message.NewPart([]byte("foo"))
+	part.Metadata().Set("key", "foo")
+	FlagErr(part, errors.New("nope"))
+	msg := message.New(nil)
+	msg.Append(part)
+
+	msgs, res := proc.ProcessMessage(msg)
+	if len(msgs) != 0 {
+		t.Errorf("Expected no messages, received: %v", len(msgs))
+	}
+	if res == nil || res.Error() != types.ErrTimeout {
+		t.Errorf("Expected timeout error, received: %v", res)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	bredis "github.com/Jeffail/benthos/v3/lib/util/redis"
 	"github.com/Jeffail/benthos/v3/lib/util/text"
 	"github.com/go-redis/redis"
 	"github.com/opentracing/opentracing-go"
@@ -66,6 +67,8 @@ type RedisConfig struct {
 	Key         string `json:"key" yaml:"key"`
 	Retries     int    `json:"retries" yaml:"retries"`
 	RetryPeriod string `json:"retry_period" yaml:"retry_period"`
+
+	bredis.PoolConfig `json:",inline" yaml:",inline"`
 }
 
 // NewRedisConfig returns a RedisConfig with default values.
@@ -77,6 +80,8 @@ func NewRedisConfig() RedisConfig {
 		Key:         "",
 		Retries:     3,
 		RetryPeriod: "500ms",
+
+		PoolConfig: bredis.NewPoolConfig(),
 	}
 }
 
@@ -118,15 +123,10 @@ func NewRedis(
 		return nil, err
 	}
 
-	var pass string
-	if uri.User != nil {
-		pass, _ = uri.User.Password()
+	client, err := conf.Redis.PoolConfig.Client(uri)
+	if err != nil {
+		return nil, err
 	}
-	client := redis.NewClient(&redis.Options{
-		Addr:     uri.Host,
-		Network:  uri.Scheme,
-		Password: pass,
-	})
 
 	r := &Redis{
 		parts: conf.Redis.Parts,
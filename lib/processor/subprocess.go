@@ -5,8 +5,10 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os/exec"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,6 +18,8 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/retries"
+	"github.com/cenkalti/backoff"
 	olog "github.com/opentracing/opentracing-go/log"
 )
 
@@ -50,7 +54,45 @@ running. If the process exits early it will be restarted.
 
 If a message contains line breaks each line of the message is piped to the
 subprocess and flushed, and a response is expected from the subprocess before
-another line is fed in.`,
+another line is fed in.
+
+#### Exit codes
+
+When the field ` + "`expose_exit_code`" + ` is set to ` + "`true`" + ` a
+message that is being processed when the subprocess exits is not marked as
+failed. Instead it continues unchanged with the exit code of the subprocess
+added to the metadata field ` + "`subprocess_exit_code`" + `, along with any
+stderr output written before the exit within ` + "`subprocess_stderr`" + `.
+This allows downstream processors to branch on exit codes that a subprocess
+uses to signal categories of messages. An exit code of ` + "`-1`" + ` means the
+subprocess was terminated by a signal.
+
+#### Retrying exit codes
+
+Exit codes that signal a transient failure, such as momentary resource
+exhaustion, can be listed within ` + "`retryable_exit_codes`" + `. When the
+subprocess exits with one of these codes whilst handling a line, the line is
+sent again to the restarted subprocess after waiting for a period determined by
+the ` + "`backoff`" + ` fields, up to ` + "`max_retries`" + ` times. Setting
+` + "`max_retries`" + ` to zero retries until ` + "`backoff.max_elapsed_time`" + `
+is reached, and one of the two must be set in order to bound the retries. Once
+the retries are exhausted the exit is handled as normal, either marking the
+message as failed or, when ` + "`expose_exit_code`" + ` is enabled, adding the
+exit code to its metadata.
+
+` + "``` yaml" + `
+subprocess:
+  name: ./lookup.sh
+  retryable_exit_codes: [ 3 ]
+  max_retries: 3
+  backoff:
+    initial_interval: 100ms
+    max_interval: 1s
+` + "```" + `
+
+The exit codes ` + "`0`" + ` (success) and ` + "`1`" + ` (not found) are never
+retried and cannot be listed. Each retry increments the metric
+` + "`retry`" + `.`,
 	}
 }
 
@@ -62,15 +104,29 @@ type SubprocessConfig struct {
 	Name      string   `json:"name" yaml:"name"`
 	Args      []string `json:"args" yaml:"args"`
 	MaxBuffer int      `json:"max_buffer" yaml:"max_buffer"`
+
+	ExposeExitCode bool `json:"expose_exit_code" yaml:"expose_exit_code"`
+
+	RetryableExitCodes []int `json:"retryable_exit_codes" yaml:"retryable_exit_codes"`
+	retries.Config     `json:",inline" yaml:",inline"`
 }
 
 // NewSubprocessConfig returns a SubprocessConfig with default values.
 func NewSubprocessConfig() SubprocessConfig {
+	rConf := retries.NewConfig()
+	rConf.MaxRetries = 3
+	rConf.Backoff.InitialInterval = "100ms"
+	rConf.Backoff.MaxInterval = "1s"
 	return SubprocessConfig{
 		Parts:     []int{},
 		Name:      "cat",
 		Args:      []string{},
 		MaxBuffer: bufio.MaxScanTokenSize,
+
+		ExposeExitCode: false,
+
+		RetryableExitCodes: []int{},
+		Config:             rConf,
 	}
 }
 
@@ -86,10 +142,14 @@ type Subprocess struct {
 	conf    SubprocessConfig
 	subproc *subprocWrapper
 
+	retryCodes  map[int]struct{}
+	backoffCtor func() backoff.BackOff
+
 	mut sync.Mutex
 
 	mCount     metrics.StatCounter
 	mErr       metrics.StatCounter
+	mRetry     metrics.StatCounter
 	mSent      metrics.StatCounter
 	mBatchSent metrics.StatCounter
 }
@@ -104,10 +164,26 @@ func NewSubprocess(
 		conf:       conf.Subprocess,
 		mCount:     stats.GetCounter("count"),
 		mErr:       stats.GetCounter("error"),
+		mRetry:     stats.GetCounter("retry"),
 		mSent:      stats.GetCounter("sent"),
 		mBatchSent: stats.GetCounter("batch.sent"),
 	}
+	if len(conf.Subprocess.RetryableExitCodes) > 0 {
+		if conf.Subprocess.MaxRetries == 0 && durationIsZero(conf.Subprocess.Backoff.MaxElapsedTime) {
+			return nil, errors.New("retryable_exit_codes requires either max_retries or backoff.max_elapsed_time to be set")
+		}
+		e.retryCodes = map[int]struct{}{}
+		for _, code := range conf.Subprocess.RetryableExitCodes {
+			if code == 0 || code == 1 {
+				return nil, fmt.Errorf("exit code %v cannot be retried", code)
+			}
+			e.retryCodes[code] = struct{}{}
+		}
+	}
 	var err error
+	if e.backoffCtor, err = conf.Subprocess.Config.GetCtor(); err != nil {
+		return nil, err
+	}
 	if e.subproc, err = newSubprocWrapper(conf.Subprocess.Name, conf.Subprocess.Args, e.conf.MaxBuffer, log); err != nil {
 		return nil, err
 	}
@@ -116,6 +192,27 @@ func NewSubprocess(
 
 //------------------------------------------------------------------------------
 
+// subprocExit records the exit code of a run of a subprocess, which is
+// available once done is closed.
+type subprocExit struct {
+	done chan struct{}
+	code int
+}
+
+// subprocExitError is returned when a subprocess exits before responding to a
+// line, and carries its exit code and any stderr output written beforehand.
+type subprocExitError struct {
+	code   int
+	stderr []byte
+}
+
+func (e *subprocExitError) Error() string {
+	if len(e.stderr) > 0 {
+		return fmt.Sprintf("subprocess exited with code %v: %s", e.code, e.stderr)
+	}
+	return fmt.Sprintf("subprocess exited with code %v", e.code)
+}
+
 type subprocWrapper struct {
 	name   string
 	args   []string
@@ -131,6 +228,7 @@ type subprocWrapper struct {
 	cmd         *exec.Cmd
 	cmdStdin    io.WriteCloser
 	cmdCancelFn func()
+	cmdExit     *subprocExit
 
 	closeChan  chan struct{}
 	closedChan chan struct{}
@@ -215,6 +313,7 @@ func (s *subprocWrapper) start() error {
 	s.cmd = cmd
 	s.cmdStdin = cmdStdin
 	s.cmdCancelFn = cmdCancelFn
+	s.cmdExit = &subprocExit{done: make(chan struct{})}
 
 	cmdExitChan := make(chan struct{})
 	stdoutChan := make(chan []byte)
@@ -278,6 +377,8 @@ func (s *subprocWrapper) stop() error {
 	if s.cmd != nil {
 		s.cmdCancelFn()
 		err = s.cmd.Wait()
+		s.cmdExit.code = s.cmd.ProcessState.ExitCode()
+		close(s.cmdExit.done)
 		s.cmd = nil
 		s.cmdStdin = nil
 		s.cmdCancelFn = func() {}
@@ -291,6 +392,7 @@ func (s *subprocWrapper) Send(line []byte) ([]byte, error) {
 	stdin := s.cmdStdin
 	outChan := s.stdoutChan
 	errChan := s.stderrChan
+	exit := s.cmdExit
 	s.cmdMut.Unlock()
 
 	if stdin == nil {
@@ -324,6 +426,11 @@ func (s *subprocWrapper) Send(line []byte) ([]byte, error) {
 	}
 
 	if !open {
+		select {
+		case <-exit.done:
+			return nil, &subprocExitError{code: exit.code, stderr: errBytes}
+		case <-time.After(time.Second):
+		}
 		return nil, types.ErrTypeClosed
 	}
 	if len(errBytes) > 0 {
@@ -334,6 +441,45 @@ func (s *subprocWrapper) Send(line []byte) ([]byte, error) {
 
 //------------------------------------------------------------------------------
 
+// durationIsZero returns true if a duration string is empty or parses to zero.
+func durationIsZero(str string) bool {
+	if len(str) == 0 {
+		return true
+	}
+	d, err := time.ParseDuration(str)
+	return err == nil && d == 0
+}
+
+// send pipes a line to the subprocess, sending it again after a backoff when
+// the subprocess exits with a retryable exit code whilst handling it.
+func (e *Subprocess) send(line []byte) ([]byte, error) {
+	var boff backoff.BackOff
+	for {
+		res, err := e.subproc.Send(line)
+		exitErr, ok := err.(*subprocExitError)
+		if !ok {
+			return res, err
+		}
+		if _, retry := e.retryCodes[exitErr.code]; !retry {
+			return res, err
+		}
+		if boff == nil {
+			boff = e.backoffCtor()
+		}
+		wait := boff.NextBackOff()
+		if wait == backoff.Stop {
+			return res, err
+		}
+		e.mRetry.Incr(1)
+		e.log.Warnf("Retrying line after subprocess exited with code %v\n", exitErr.code)
+		select {
+		case <-time.After(wait):
+		case <-e.subproc.closeChan:
+			return res, err
+		}
+	}
+}
+
 // ProcessMessage logs an event and returns the message unchanged.
 func (e *Subprocess) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
 	e.mCount.Incr(1)
@@ -353,7 +499,16 @@ func (e *Subprocess) ProcessMessage(msg types.Message) ([]types.Message, types.R
 				results = append(results, []byte(""))
 				continue
 			}
-			res, err := e.subproc.Send(p)
+			res, err := e.send(p)
+			if exitErr, ok := err.(*subprocExitError); ok && e.conf.ExposeExitCode {
+				meta := result.Get(i).Metadata()
+				meta.Set("subprocess_exit_code", strconv.Itoa(exitErr.code))
+				if len(exitErr.stderr) > 0 {
+					meta.Set("subprocess_stderr", string(exitErr.stderr))
+				}
+				results = append(results, p)
+				continue
+			}
 			if err != nil {
 				e.log.Errorf("Failed to send message to subprocess: %v\n", err)
 				e.mErr.Incr(1)
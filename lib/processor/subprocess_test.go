@@ -1,6 +1,9 @@
 package processor
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -162,3 +165,181 @@ func TestSubprocessWithErrors(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestSubprocessExposeExitCode(t *testing.T) {
+	tests := []struct {
+		script    string
+		expCode   string
+		expStderr string
+	}{
+		{script: "read l; exit 0", expCode: "0"},
+		{script: "read l; exit 3", expCode: "3"},
+		{script: "read l; exit 7", expCode: "7"},
+		{script: "read l; echo \"bad $l\" 1>&2; sleep 0.1; exit 5", expCode: "5", expStderr: "bad foo"},
+	}
+
+	for _, test := range tests {
+		conf := NewConfig()
+		conf.Type = TypeSubprocess
+		conf.Subprocess.Name = "sh"
+		conf.Subprocess.Args = []string{"-c", test.script}
+		conf.Subprocess.ExposeExitCode = true
+
+		proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+		if err != nil {
+			t.Skipf("Not sure if this is due to missing executable: %v", err)
+		}
+
+		msgs, res := proc.ProcessMessage(message.New([][]byte{[]byte(`foo`)}))
+		if res != nil {
+			t.Fatalf("Non-nil result: %v", res.Error())
+		}
+		part := msgs[0].Get(0)
+		if HasFailed(part) {
+			t.Errorf("Unexpected failure of '%v'", test.script)
+		}
+		if act := part.Metadata().Get("subprocess_exit_code"); act != test.expCode {
+			t.Errorf("Wrong exit code of '%v': %v != %v", test.script, act, test.expCode)
+		}
+		if act := part.Metadata().Get("subprocess_stderr"); act != test.expStderr {
+			t.Errorf("Wrong stderr of '%v': %v != %v", test.script, act, test.expStderr)
+		}
+		if exp, act := "foo", string(part.Get()); exp != act {
+			t.Errorf("Wrong contents of '%v': %v != %v", test.script, act, exp)
+		}
+
+		proc.CloseAsync()
+		if err := proc.WaitForClose(time.Second); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestSubprocessExitCodeNotExposed(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeSubprocess
+	conf.Subprocess.Name = "sh"
+	conf.Subprocess.Args = []string{"-c", "read l; exit 3"}
+
+	proc, err := New(conf, nil, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Skipf("Not sure if this is due to missing executable: %v", err)
+	}
+
+	msgs, _ := proc.ProcessMessage(message.New([][]byte{[]byte(`foo`)}))
+	part := msgs[0].Get(0)
+	if !HasFailed(part) {
+		t.Error("Expected subprocessor to fail")
+	}
+	if act := part.Metadata().Get("subprocess_exit_code"); act != "" {
+		t.Errorf("Unexpected exit code metadata: %v", act)
+	}
+
+	proc.CloseAsync()
+	if err := proc.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSubprocessRetryableExitCodes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_subprocess_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	countFile := filepath.Join(dir, "count")
+
+	conf := NewConfig()
+	conf.Type = TypeSubprocess
+	conf.Subprocess.Name = "sh"
+	conf.Subprocess.Args = []string{"-c", `
+while read l; do
+	n=$(cat "$0" 2>/dev/null || echo 0)
+	if [ "$n" -lt 2 ]; then
+		echo $((n+1)) > "$0"
+		exit 3
+	fi
+	echo "got $l"
+done`, countFile}
+	conf.Subprocess.RetryableExitCodes = []int{3}
+	conf.Subprocess.Backoff.InitialInterval = "10ms"
+	conf.Subprocess.Backoff.MaxInterval = "50ms"
+
+	stats := metrics.NewLocal()
+	proc, err := New(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Skipf("Not sure if this is due to missing executable: %v", err)
+	}
+
+	msgs, res := proc.ProcessMessage(message.New([][]byte{[]byte(`foo`)}))
+	if res != nil {
+		t.Fatalf("Non-nil result: %v", res.Error())
+	}
+	part := msgs[0].Get(0)
+	if HasFailed(part) {
+		t.Errorf("Unexpected failure: %v", part.Metadata().Get(FailFlagKey))
+	}
+	if exp, act := "got foo", string(part.Get()); exp != act {
+		t.Errorf("Wrong contents: %v != %v", act, exp)
+	}
+	if exp, act := int64(2), stats.GetCounters()["retry"]; exp != act {
+		t.Errorf("Wrong count of retries: %v != %v", act, exp)
+	}
+
+	proc.CloseAsync()
+	if err := proc.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSubprocessRetryableExitCodesExhausted(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeSubprocess
+	conf.Subprocess.Name = "sh"
+	conf.Subprocess.Args = []string{"-c", "read l; exit 3"}
+	conf.Subprocess.RetryableExitCodes = []int{3}
+	conf.Subprocess.MaxRetries = 2
+	conf.Subprocess.Backoff.InitialInterval = "10ms"
+	conf.Subprocess.Backoff.MaxInterval = "50ms"
+	conf.Subprocess.ExposeExitCode = true
+
+	stats := metrics.NewLocal()
+	proc, err := New(conf, nil, log.Noop(), stats)
+	if err != nil {
+		t.Skipf("Not sure if this is due to missing executable: %v", err)
+	}
+
+	msgs, _ := proc.ProcessMessage(message.New([][]byte{[]byte(`foo`)}))
+	if act := msgs[0].Get(0).Metadata().Get("subprocess_exit_code"); act != "3" {
+		t.Errorf("Wrong exit code: %v != %v", act, "3")
+	}
+	if exp, act := int64(2), stats.GetCounters()["retry"]; exp != act {
+		t.Errorf("Wrong count of retries: %v != %v", act, exp)
+	}
+
+	proc.CloseAsync()
+	if err := proc.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSubprocessRetryableExitCodesBadConfig(t *testing.T) {
+	for _, codes := range [][]int{{0}, {1}, {3, 1}} {
+		conf := NewConfig()
+		conf.Type = TypeSubprocess
+		conf.Subprocess.Name = "cat"
+		conf.Subprocess.RetryableExitCodes = codes
+		if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+			t.Errorf("Expected error from retryable exit codes %v", codes)
+		}
+	}
+
+	conf := NewConfig()
+	conf.Type = TypeSubprocess
+	conf.Subprocess.Name = "cat"
+	conf.Subprocess.RetryableExitCodes = []int{3}
+	conf.Subprocess.MaxRetries = 0
+	if _, err := New(conf, nil, log.Noop(), metrics.Noop()); err == nil {
+		t.Error("Expected error from unbounded retries")
+	}
+}
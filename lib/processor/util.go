@@ -1,6 +1,8 @@
 package processor
 
 import (
+	"fmt"
+
 	"github.com/Jeffail/benthos/v3/lib/message/tracing"
 	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
@@ -188,3 +190,39 @@ func IteratePartsWithSpan(
 }
 
 //------------------------------------------------------------------------------
+
+// cacheErrPolicy determines how a processor reacts to a cache operation that
+// fails for reasons other than the presence or absence of a key.
+type cacheErrPolicy int
+
+// Cache error policies.
+const (
+	cacheErrPolicyFail cacheErrPolicy = iota
+	cacheErrPolicySkip
+	cacheErrPolicyPassthrough
+)
+
+// parseCacheErrPolicy parses an on_cache_error field value, returning the
+// provided default when the value is empty.
+func parseCacheErrPolicy(str string, def cacheErrPolicy) (cacheErrPolicy, error) {
+	switch str {
+	case "":
+		return def, nil
+	case "fail":
+		return cacheErrPolicyFail, nil
+	case "skip":
+		return cacheErrPolicySkip, nil
+	case "passthrough":
+		return cacheErrPolicyPassthrough, nil
+	}
+	return def, fmt.Errorf("on_cache_error policy not recognised: %v", str)
+}
+
+// isCacheFailure returns true if an error returned by a cache indicates that the
+// operation failed, as opposed to the typed errors that describe whether a key
+// exists.
+func isCacheFailure(err error) bool {
+	return err != nil && err != types.ErrKeyNotFound && err != types.ErrKeyAlreadyExists
+}
+
+//------------------------------------------------------------------------------
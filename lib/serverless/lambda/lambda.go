@@ -7,7 +7,6 @@ import (
 
 	"github.com/Jeffail/benthos/v3/lib/config"
 	"github.com/Jeffail/benthos/v3/lib/serverless"
-	"github.com/Jeffail/benthos/v3/lib/util/text"
 	"github.com/aws/aws-lambda-go/lambda"
 	"gopkg.in/yaml.v3"
 )
@@ -28,8 +27,11 @@ func Run() {
 	conf.Output.Type = serverless.ServerlessResponseType
 
 	if confStr := os.Getenv("BENTHOS_CONFIG"); len(confStr) > 0 {
-		confBytes := text.ReplaceEnvVariables([]byte(confStr))
-		if err := yaml.Unmarshal(confBytes, &conf); err != nil {
+		confBytes, err := config.ReplaceEnvVariables([]byte(confStr))
+		if err == nil {
+			err = yaml.Unmarshal(confBytes, &conf)
+		}
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Configuration file read error: %v\n", err)
 			os.Exit(1)
 		}
@@ -0,0 +1,110 @@
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/manager"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// ErrCacheOperation is returned by Cache when the operation was executed but
+// the cache returned an error, which has already been printed.
+var ErrCacheOperation = errors.New("cache operation failed")
+
+// ClassifyCacheError returns the name of a well known cache error, or an empty
+// string if the error is not recognised.
+func ClassifyCacheError(err error) string {
+	switch {
+	case errors.Is(err, types.ErrKeyNotFound):
+		return "ErrKeyNotFound"
+	case errors.Is(err, types.ErrKeyAlreadyExists):
+		return "ErrKeyAlreadyExists"
+	case errors.Is(err, types.ErrCASConflict):
+		return "ErrCASConflict"
+	case errors.Is(err, types.ErrTimeout):
+		return "ErrTimeout"
+	case errors.Is(err, types.ErrNotConnected):
+		return "ErrNotConnected"
+	case types.IsNonRetriable(err):
+		return "NonRetriableError"
+	}
+	return ""
+}
+
+// Cache constructs the cache resources of a config and executes a single
+// operation against the cache of a name, printing the result to w. The
+// arguments are the operation, which is one of get, set, add or delete,
+// followed by a key and, for set and add, a value.
+//
+// Only the cache resources of the config are constructed, other resources and
+// the stream itself are ignored.
+func Cache(conf manager.Config, name string, args []string, w io.Writer) error {
+	if len(args) < 2 {
+		return errors.New("expected arguments of the form: <get|set|add|delete> <key> [value]")
+	}
+	op, key := args[0], args[1]
+
+	var value []byte
+	switch op {
+	case "get", "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("operation %v expects a key only", op)
+		}
+	case "set", "add":
+		if len(args) != 3 {
+			return fmt.Errorf("operation %v expects a key and a value", op)
+		}
+		value = []byte(args[2])
+	default:
+		return fmt.Errorf("operation not recognised: %v", op)
+	}
+
+	cacheConf := manager.NewConfig()
+	cacheConf.Caches = conf.Caches
+	mgr, err := manager.New(cacheConf, types.NoopMgr(), log.Noop(), metrics.Noop())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		mgr.CloseAsync()
+		mgr.WaitForClose(time.Second * 5)
+	}()
+
+	c, err := mgr.GetCache(name)
+	if err != nil {
+		return fmt.Errorf("cache resource '%v' was not found", name)
+	}
+
+	switch op {
+	case "get":
+		if value, err = c.Get(key); err == nil {
+			fmt.Fprintf(w, "%s\n", value)
+			return nil
+		}
+	case "set":
+		err = c.Set(key, value)
+	case "add":
+		err = c.Add(key, value)
+	case "delete":
+		err = c.Delete(key)
+	}
+	if err != nil {
+		if class := ClassifyCacheError(err); len(class) > 0 {
+			fmt.Fprintf(w, "Error (%v): %v\n", class, err)
+		} else {
+			fmt.Fprintf(w, "Error: %v\n", err)
+		}
+		return ErrCacheOperation
+	}
+	fmt.Fprintln(w, "OK")
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,73 @@
+package probe
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/cache"
+	"github.com/Jeffail/benthos/v3/lib/manager"
+)
+
+func TestCacheOperations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_cache_probe_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cConf := cache.NewConfig()
+	cConf.Type = cache.TypeFile
+	cConf.File.Directory = dir
+
+	conf := manager.NewConfig()
+	conf.Caches["foo"] = cConf
+
+	tests := []struct {
+		args   []string
+		output string
+		err    error
+	}{
+		{args: []string{"get", "bar"}, output: "Error (ErrKeyNotFound): key does not exist\n", err: ErrCacheOperation},
+		{args: []string{"set", "bar", "baz"}, output: "OK\n"},
+		{args: []string{"get", "bar"}, output: "baz\n"},
+		{args: []string{"add", "bar", "qux"}, output: "Error (ErrKeyAlreadyExists): key already exists\n", err: ErrCacheOperation},
+		{args: []string{"delete", "bar"}, output: "OK\n"},
+		{args: []string{"get", "bar"}, output: "Error (ErrKeyNotFound): key does not exist\n", err: ErrCacheOperation},
+	}
+
+	for i, test := range tests {
+		var buf bytes.Buffer
+		if err := Cache(conf, "foo", test.args, &buf); err != test.err {
+			t.Errorf("Wrong error returned by test %v: %v != %v", i, err, test.err)
+		}
+		if exp, act := test.output, buf.String(); exp != act {
+			t.Errorf("Wrong output of test %v: %q != %q", i, act, exp)
+		}
+	}
+}
+
+func TestCacheBadArgs(t *testing.T) {
+	conf := manager.NewConfig()
+	conf.Caches["foo"] = cache.NewConfig()
+
+	tests := map[string][]string{
+		"no args":        {},
+		"no key":         {"get"},
+		"unknown op":     {"nope", "bar"},
+		"missing value":  {"set", "bar"},
+		"too many args":  {"get", "bar", "baz"},
+		"unknown target": nil,
+	}
+
+	for name, args := range tests {
+		target := "foo"
+		if args == nil {
+			target, args = "bar", []string{"get", "bar"}
+		}
+		if err := Cache(conf, target, args, ioutil.Discard); err == nil {
+			t.Errorf("%v: Expected error", name)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+// Package probe implements the Benthos service commands for exercising
+// resources outside of a running pipeline.
+package probe
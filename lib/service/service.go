@@ -25,6 +25,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/output"
 	"github.com/Jeffail/benthos/v3/lib/processor"
 	"github.com/Jeffail/benthos/v3/lib/ratelimit"
+	"github.com/Jeffail/benthos/v3/lib/service/probe"
 	"github.com/Jeffail/benthos/v3/lib/service/test"
 	"github.com/Jeffail/benthos/v3/lib/stream"
 	strmmgr "github.com/Jeffail/benthos/v3/lib/stream/manager"
@@ -85,6 +86,16 @@ Generate unit test definition files for Benthos configs, then exit. The argument
 may point to a config file or directory and supports '...' wildcards, e.g.
 './foo/...' would generate tests for all Benthos configs found under the
 directory 'foo'.`[1:],
+	)
+	probeCache = flag.String(
+		"probe-cache", "",
+		`
+EXPERIMENTAL: This flag is subject to change outside of major version releases.
+
+Construct the cache resources of the config and execute an operation against the
+cache of the provided name, then exit. The operation is given by the remaining
+arguments, which are one of 'get <key>', 'set <key> <value>', 'add <key> <value>'
+or 'delete <key>', e.g. 'benthos -c ./config.yaml --probe-cache foo get bar'.`[1:],
 	)
 	strictConfig = flag.Bool(
 		"strict", false,
@@ -305,6 +316,16 @@ func bootstrap() (config.Type, []string) {
 			}
 		}
 	}
+	if len(*probeCache) > 0 {
+		if err := probe.Cache(conf.Manager, *probeCache, flag.Args(), os.Stdout); err != nil {
+			if err != probe.ErrCacheOperation {
+				fmt.Fprintf(os.Stderr, "Failed to probe cache: %v\n", err)
+			}
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	if *lintConfig {
 		if len(lints) > 0 {
 			for _, l := range lints {
@@ -488,8 +509,11 @@ func Run() {
 		<-time.After(time.Second)
 		stats, err = metrics.New(config.Metrics, metrics.OptSetLogger(logger))
 	}
+	var exitTimeout time.Duration
 	defer func() {
-		if sCloseErr := stats.Close(); sCloseErr != nil {
+		// Flush pending metrics before exiting, this runs after the streams
+		// are stopped and so captures all metrics of the final messages.
+		if sCloseErr := metrics.WaitForClose(stats, exitTimeout); sCloseErr != nil {
 			logger.Errorf("Failed to cleanly close metrics aggregator: %v\n", sCloseErr)
 		}
 	}()
@@ -583,7 +607,6 @@ func Run() {
 		close(httpServerClosedChan)
 	}()
 
-	var exitTimeout time.Duration
 	if tout := config.SystemCloseTimeout; len(tout) > 0 {
 		var err error
 		if exitTimeout, err = time.ParseDuration(tout); err != nil {
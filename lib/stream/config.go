@@ -16,6 +16,8 @@ type Config struct {
 	Buffer   buffer.Config   `json:"buffer" yaml:"buffer"`
 	Pipeline pipeline.Config `json:"pipeline" yaml:"pipeline"`
 	Output   output.Config   `json:"output" yaml:"output"`
+
+	MaxInFlightMessages int `json:"max_in_flight_messages" yaml:"max_in_flight_messages"`
 }
 
 // NewConfig returns a new configuration with default values.
@@ -25,6 +27,8 @@ func NewConfig() Config {
 		Buffer:   buffer.NewConfig(),
 		Pipeline: pipeline.NewConfig(),
 		Output:   output.NewConfig(),
+
+		MaxInFlightMessages: 0,
 	}
 }
 
@@ -60,11 +64,15 @@ func (c Config) Sanitised() (interface{}, error) {
 		Buffer   interface{} `json:"buffer" yaml:"buffer"`
 		Pipeline interface{} `json:"pipeline" yaml:"pipeline"`
 		Output   interface{} `json:"output" yaml:"output"`
+
+		MaxInFlightMessages int `json:"max_in_flight_messages" yaml:"max_in_flight_messages"`
 	}{
 		Input:    inConf,
 		Buffer:   bufConf,
 		Pipeline: pipeConf,
 		Output:   outConf,
+
+		MaxInFlightMessages: c.MaxInFlightMessages,
 	}, nil
 }
 
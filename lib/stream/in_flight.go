@@ -0,0 +1,184 @@
+package stream
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// inFlightLimiter sits between the input layer of a stream and the next layer,
+// counting the message parts that have been ingested but not yet acknowledged
+// and blocking the input whilst that count has reached a limit.
+type inFlightLimiter struct {
+	running int32
+
+	limit int
+
+	count int
+	cond  *sync.Cond
+
+	pending sync.WaitGroup
+
+	mInFlight metrics.StatGauge
+	mBlocked  metrics.StatCounter
+
+	transactionsIn  <-chan types.Transaction
+	transactionsOut chan types.Transaction
+
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+// newInFlightLimiter creates a new inFlightLimiter that applies a limit to the
+// number of message parts in flight.
+func newInFlightLimiter(limit int, stats metrics.Type) *inFlightLimiter {
+	return &inFlightLimiter{
+		running:         1,
+		limit:           limit,
+		cond:            sync.NewCond(&sync.Mutex{}),
+		mInFlight:       stats.GetGauge("in_flight"),
+		mBlocked:        stats.GetCounter("in_flight.blocked"),
+		transactionsOut: make(chan types.Transaction),
+		closeChan:       make(chan struct{}),
+		closedChan:      make(chan struct{}),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// acquire blocks until n message parts can be added to the in flight count
+// without exceeding the limit, and returns false if the limiter was closed
+// whilst waiting. A batch larger than the limit is admitted once no other
+// parts are in flight.
+func (l *inFlightLimiter) acquire(n int) bool {
+	l.cond.L.Lock()
+	defer l.cond.L.Unlock()
+
+	if l.count > 0 && l.count+n > l.limit {
+		l.mBlocked.Incr(1)
+	}
+	for l.count > 0 && l.count+n > l.limit {
+		if atomic.LoadInt32(&l.running) != 1 {
+			return false
+		}
+		l.cond.Wait()
+	}
+	if atomic.LoadInt32(&l.running) != 1 {
+		return false
+	}
+	l.count += n
+	l.mInFlight.Set(int64(l.count))
+	return true
+}
+
+// release removes n message parts from the in flight count.
+func (l *inFlightLimiter) release(n int) {
+	l.cond.L.Lock()
+	l.count -= n
+	l.mInFlight.Set(int64(l.count))
+	l.cond.L.Unlock()
+	l.cond.Broadcast()
+}
+
+// await forwards the response of a transaction back to its origin once it is
+// received, releasing its message parts from the in flight count.
+func (l *inFlightLimiter) await(n int, resChan <-chan types.Response, origin chan<- types.Response) {
+	defer l.pending.Done()
+
+	var res types.Response
+	select {
+	case res = <-resChan:
+	case <-l.closeChan:
+		l.release(n)
+		return
+	}
+	l.release(n)
+
+	select {
+	case origin <- res:
+	case <-l.closeChan:
+	}
+}
+
+func (l *inFlightLimiter) loop() {
+	defer func() {
+		close(l.transactionsOut)
+		l.pending.Wait()
+		close(l.closedChan)
+	}()
+
+	for atomic.LoadInt32(&l.running) == 1 {
+		var ts types.Transaction
+		var open bool
+		select {
+		case ts, open = <-l.transactionsIn:
+			if !open {
+				return
+			}
+		case <-l.closeChan:
+			return
+		}
+
+		n := ts.Payload.Len()
+		if !l.acquire(n) {
+			return
+		}
+
+		resChan := make(chan types.Response)
+		select {
+		case l.transactionsOut <- types.NewTransaction(ts.Payload, resChan):
+		case <-l.closeChan:
+			l.release(n)
+			return
+		}
+
+		l.pending.Add(1)
+		go l.await(n, resChan, ts.ResponseChan)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Consume assigns a transactions channel for the limiter to read.
+func (l *inFlightLimiter) Consume(ts <-chan types.Transaction) error {
+	if l.transactionsIn != nil {
+		return types.ErrAlreadyStarted
+	}
+	l.transactionsIn = ts
+	go l.loop()
+	return nil
+}
+
+// TransactionChan returns the channel used for consuming transactions from
+// this limiter.
+func (l *inFlightLimiter) TransactionChan() <-chan types.Transaction {
+	return l.transactionsOut
+}
+
+// CloseAsync shuts down the limiter, abandoning any transactions that are in
+// flight.
+func (l *inFlightLimiter) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&l.running, 1, 0) {
+		close(l.closeChan)
+		l.cond.L.Lock()
+		l.cond.Broadcast()
+		l.cond.L.Unlock()
+	}
+}
+
+// WaitForClose blocks until the limiter has closed down.
+func (l *inFlightLimiter) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-l.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
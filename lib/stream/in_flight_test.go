@@ -0,0 +1,122 @@
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+func TestInFlightLimiterBlocks(t *testing.T) {
+	tranChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	l := newInFlightLimiter(3, metrics.Noop())
+	if err := l.Consume(tranChan); err != nil {
+		t.Fatal(err)
+	}
+
+	sendTran := func(parts ...string) {
+		t.Helper()
+		var raw [][]byte
+		for _, p := range parts {
+			raw = append(raw, []byte(p))
+		}
+		select {
+		case tranChan <- types.NewTransaction(message.New(raw), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	var pending []types.Transaction
+	receiveTran := func() {
+		t.Helper()
+		select {
+		case ts := <-l.TransactionChan():
+			pending = append(pending, ts)
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+	}
+
+	sendTran("foo", "bar")
+	receiveTran()
+	sendTran("baz")
+	receiveTran()
+
+	// The limit has been reached and so the next transaction is accepted from
+	// the input but not forwarded until parts are acknowledged.
+	sendTran("qux")
+	select {
+	case <-l.TransactionChan():
+		t.Fatal("expected input to be blocked")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	select {
+	case pending[1].ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	select {
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	receiveTran()
+	if exp, act := "qux", string(pending[2].Payload.Get(0).Get()); exp != act {
+		t.Errorf("Wrong message: %v != %v", act, exp)
+	}
+
+	l.CloseAsync()
+	if err := l.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInFlightLimiterLargeBatch(t *testing.T) {
+	tranChan := make(chan types.Transaction)
+	resChan := make(chan types.Response)
+
+	l := newInFlightLimiter(1, metrics.Noop())
+	if err := l.Consume(tranChan); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case tranChan <- types.NewTransaction(message.New([][]byte{[]byte("foo"), []byte("bar")}), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	var ts types.Transaction
+	select {
+	case ts = <-l.TransactionChan():
+	case <-time.After(time.Second):
+		t.Fatal("expected batch larger than limit to be forwarded")
+	}
+
+	select {
+	case ts.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	select {
+	case <-resChan:
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	close(tranChan)
+	if err := l.WaitForClose(time.Second); err != nil {
+		t.Error(err)
+	}
+}
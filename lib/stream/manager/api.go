@@ -16,7 +16,6 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/output"
 	"github.com/Jeffail/benthos/v3/lib/pipeline"
 	"github.com/Jeffail/benthos/v3/lib/stream"
-	"github.com/Jeffail/benthos/v3/lib/util/text"
 	"github.com/Jeffail/gabs/v2"
 	"github.com/gorilla/mux"
 	yaml "gopkg.in/yaml.v3"
@@ -215,8 +214,13 @@ func (m *Type) HandleStreamCRUD(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		var resolvedBytes []byte
+		if resolvedBytes, err = config.ReplaceEnvVariables(confBytes); err != nil {
+			return
+		}
+
 		confOut = stream.NewConfig()
-		err = yaml.Unmarshal(text.ReplaceEnvVariables(confBytes), &confOut)
+		err = yaml.Unmarshal(resolvedBytes, &confOut)
 		if err == nil {
 			lConfig := config.New()
 			lConfig.Config = confOut
@@ -3,11 +3,13 @@ package manager
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,6 +17,7 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/stream"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/secrets"
 	"github.com/Jeffail/gabs/v2"
 	"github.com/gorilla/mux"
 	yaml "gopkg.in/yaml.v3"
@@ -418,6 +421,62 @@ func TestTypeAPIBasicOperationsYAML(t *testing.T) {
 	}
 }
 
+func TestTypeAPISecretRefs(t *testing.T) {
+	secrets.RegisterProvider("apitest", secrets.ProviderFunc(func(ref string) (string, error) {
+		if ref == "missing" {
+			return "", errors.New("not found")
+		}
+		return "/secret/" + ref, nil
+	}))
+
+	mgr := New(
+		OptSetLogger(log.Noop()),
+		OptSetStats(metrics.Noop()),
+		OptSetManager(types.NoopMgr()),
+		OptSetAPITimeout(time.Second),
+	)
+
+	r := router(mgr)
+	conf := harmlessConf()
+	conf.Input.HTTPServer.Path = "${secret:apitest:foo}"
+
+	request := genYAMLRequest("POST", "/streams/foo", conf)
+	response := httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	if exp, act := http.StatusOK, response.Code; exp != act {
+		t.Fatalf("Unexpected result: %v != %v: %s", act, exp, response.Body.String())
+	}
+
+	request = genYAMLRequest("GET", "/streams/foo", nil)
+	response = httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	if exp, act := http.StatusOK, response.Code; exp != act {
+		t.Errorf("Unexpected result: %v != %v", act, exp)
+	}
+	info := parseGetBody(response.Body)
+	if exp, act := "/secret/foo", info.Config.Input.HTTPServer.Path; exp != act {
+		t.Errorf("Unexpected path: %v != %v", act, exp)
+	}
+
+	conf.Input.HTTPServer.Path = "${secret:apitest:missing}"
+	request = genYAMLRequest("PUT", "/streams/foo", conf)
+	response = httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	if exp, act := http.StatusBadRequest, response.Code; exp != act {
+		t.Errorf("Unexpected result: %v != %v", act, exp)
+	}
+	if exp, act := "input.http_server.path", response.Body.String(); !strings.Contains(act, exp) {
+		t.Errorf("Expected error '%v' to contain '%v'", act, exp)
+	}
+
+	request = genYAMLRequest("DELETE", "/streams/foo", nil)
+	response = httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	if exp, act := http.StatusOK, response.Code; exp != act {
+		t.Errorf("Unexpected result: %v != %v", act, exp)
+	}
+}
+
 func TestTypeAPIList(t *testing.T) {
 	mgr := New(
 		OptSetLogger(log.New(os.Stdout, log.Config{LogLevel: "NONE"})),
@@ -2,6 +2,7 @@ package manager
 
 import (
 	"errors"
+	"io"
 	"net/http"
 	"path"
 
@@ -50,6 +51,41 @@ func (n *NamespacedManager) GetProcessor(name string) (types.Processor, error) {
 	return nil, errors.New("wrapped manager does not support processor resources")
 }
 
+// AcquireShared returns a resource shared between components under a key,
+// creating it with ctor when it does not yet exist.
+func (n *NamespacedManager) AcquireShared(key string, ctor func() (io.Closer, error)) (io.Closer, error) {
+	// TODO: V4 Simplify this.
+	if sharedProv, ok := n.mgr.(interface {
+		AcquireShared(key string, ctor func() (io.Closer, error)) (io.Closer, error)
+	}); ok {
+		return sharedProv.AcquireShared(key, ctor)
+	}
+	return nil, errors.New("wrapped manager does not support shared resources")
+}
+
+// ReleaseShared gives up a hold of a resource shared under a key.
+func (n *NamespacedManager) ReleaseShared(key string, res io.Closer) error {
+	// TODO: V4 Simplify this.
+	if sharedProv, ok := n.mgr.(interface {
+		ReleaseShared(key string, res io.Closer) error
+	}); ok {
+		return sharedProv.ReleaseShared(key, res)
+	}
+	return errors.New("wrapped manager does not support shared resources")
+}
+
+// InvalidateShared stops sharing a resource under a key, such that the next
+// acquisition creates a fresh resource.
+func (n *NamespacedManager) InvalidateShared(key string, res io.Closer) error {
+	// TODO: V4 Simplify this.
+	if sharedProv, ok := n.mgr.(interface {
+		InvalidateShared(key string, res io.Closer) error
+	}); ok {
+		return sharedProv.InvalidateShared(key, res)
+	}
+	return errors.New("wrapped manager does not support shared resources")
+}
+
 // GetRateLimit attempts to find a service wide rate limit by its name.
 func (n *NamespacedManager) GetRateLimit(name string) (types.RateLimit, error) {
 	return n.mgr.GetRateLimit(name)
@@ -22,6 +22,7 @@ type Type struct {
 	conf Config
 
 	inputLayer    input.Type
+	inFlightLayer *inFlightLimiter
 	bufferLayer   buffer.Type
 	pipelineLayer pipeline.Type
 	outputLayer   output.Type
@@ -143,7 +144,7 @@ func (t *Type) start() (err error) {
 			return
 		}
 	}
-	if tLen := len(t.complementaryProcs) + len(t.conf.Pipeline.Processors); tLen > 0 {
+	if tLen := len(t.complementaryProcs) + len(t.conf.Pipeline.Processors); tLen > 0 || t.conf.Pipeline.MessageID.Enabled {
 		if t.pipelineLayer, err = pipeline.New(
 			t.conf.Pipeline, t.manager,
 			t.logger.NewModule(".pipeline"), metrics.Namespaced(t.stats, "pipeline"),
@@ -163,6 +164,13 @@ func (t *Type) start() (err error) {
 	var nextTranChan <-chan types.Transaction
 
 	nextTranChan = t.inputLayer.TransactionChan()
+	if t.conf.MaxInFlightMessages > 0 {
+		t.inFlightLayer = newInFlightLimiter(t.conf.MaxInFlightMessages, metrics.Namespaced(t.stats, "stream"))
+		if err = t.inFlightLayer.Consume(nextTranChan); err != nil {
+			return
+		}
+		nextTranChan = t.inFlightLayer.TransactionChan()
+	}
 	if t.bufferLayer != nil {
 		if err = t.bufferLayer.Consume(nextTranChan); err != nil {
 			return
@@ -238,6 +246,9 @@ func (t *Type) stopGracefully(timeout time.Duration) (err error) {
 		return
 	}
 
+	if t.inFlightLayer != nil {
+		t.inFlightLayer.CloseAsync()
+	}
 	return nil
 }
 
@@ -285,6 +296,9 @@ func (t *Type) stopOrdered(timeout time.Duration) (err error) {
 		return
 	}
 
+	if t.inFlightLayer != nil {
+		t.inFlightLayer.CloseAsync()
+	}
 	return nil
 }
 
@@ -293,6 +307,9 @@ func (t *Type) stopOrdered(timeout time.Duration) (err error) {
 // should only be attempted if both stopGracefully and stopOrdered failed.
 func (t *Type) stopUnordered(timeout time.Duration) (err error) {
 	t.inputLayer.CloseAsync()
+	if t.inFlightLayer != nil {
+		t.inFlightLayer.CloseAsync()
+	}
 	if t.bufferLayer != nil {
 		t.bufferLayer.CloseAsync()
 	}
@@ -1,15 +1,19 @@
 package stream
 
 import (
+	"net/http"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/input"
 	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/manager"
+	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/output"
 	"github.com/Jeffail/benthos/v3/lib/processor"
+	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/types"
 )
 
@@ -182,3 +186,77 @@ func TestTypeCloseUnordered(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+type noopAPIReg struct{}
+
+func (noopAPIReg) RegisterEndpoint(path, desc string, h http.HandlerFunc) {}
+
+func TestTypeMessageIDWithoutProcessors(t *testing.T) {
+	mgr, err := manager.New(manager.NewConfig(), noopAPIReg{}, log.Noop(), metrics.Noop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inChan := make(chan types.Transaction)
+	mgr.SetPipe("in", inChan)
+
+	conf := NewConfig()
+	conf.Input.Type = input.TypeInproc
+	conf.Input.Inproc = "in"
+	conf.Output.Type = output.TypeInproc
+	conf.Output.Inproc = "out"
+	conf.Pipeline.MessageID.Enabled = true
+
+	strm, err := New(conf, OptSetManager(mgr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := strm.Stop(time.Second); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// The output registers its pipe asynchronously.
+	var outChan <-chan types.Transaction
+	for i := 0; i < 100; i++ {
+		if outChan, err = mgr.GetPipe("out"); err == nil {
+			break
+		}
+		<-time.After(time.Millisecond * 10)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resChan := make(chan types.Response, 1)
+	select {
+	case inChan <- types.NewTransaction(message.New([][]byte{[]byte("foo")}), resChan):
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	var tran types.Transaction
+	select {
+	case tran = <-outChan:
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	if id := tran.Payload.Get(0).Metadata().Get("benthos_message_id"); len(id) == 0 {
+		t.Error("Expected message ID to be assigned")
+	}
+
+	select {
+	case tran.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	select {
+	case res := <-resChan:
+		if res.Error() != nil {
+			t.Error(res.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+}
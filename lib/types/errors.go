@@ -41,14 +41,25 @@ var (
 
 // Manager errors
 var (
-	ErrCacheNotFound     = errors.New("cache not found")
-	ErrConditionNotFound = errors.New("condition not found")
-	ErrProcessorNotFound = errors.New("processor not found")
-	ErrRateLimitNotFound = errors.New("rate limit not found")
-	ErrPluginNotFound    = errors.New("plugin not found")
-	ErrKeyAlreadyExists  = errors.New("key already exists")
-	ErrKeyNotFound       = errors.New("key does not exist")
-	ErrPipeNotFound      = errors.New("pipe was not found")
+	ErrCacheNotFound      = errors.New("cache not found")
+	ErrConditionNotFound  = errors.New("condition not found")
+	ErrProcessorNotFound  = errors.New("processor not found")
+	ErrRateLimitNotFound  = errors.New("rate limit not found")
+	ErrPluginNotFound     = errors.New("plugin not found")
+	ErrKeyAlreadyExists   = errors.New("key already exists")
+	ErrKeyNotFound        = errors.New("key does not exist")
+	ErrCASConflict        = errors.New("key value does not match the expected value")
+	ErrCASNotSupported    = errors.New("cache does not support compare and set")
+	ErrTransactionAborted = errors.New("transaction was aborted and no keys were written")
+	ErrTTLNotSupported    = errors.New("cache does not support per-key TTLs")
+	ErrPipeNotFound       = errors.New("pipe was not found")
+
+	ErrTombstoneNotSupported = errors.New("cache does not support delete tombstones")
+
+	ErrCounterNotSupported = errors.New("cache does not support counters")
+	ErrCounterNotNumeric   = errors.New("cached value is not a valid counter")
+
+	ErrSharedResourceNotFound = errors.New("shared resource not found")
 )
 
 //------------------------------------------------------------------------------
@@ -60,6 +71,20 @@ var (
 
 //------------------------------------------------------------------------------
 
+// Output errors
+var (
+	// ErrTopicAuthorization is returned when a sink rejects writes to a topic
+	// that the client is not authorized to access, which is resolved by
+	// changing permissions rather than by retrying.
+	ErrTopicAuthorization = errors.New("not authorized to write to topic")
+
+	// ErrTopicNotFound is returned when a sink rejects writes to a topic that
+	// does not exist.
+	ErrTopicNotFound = errors.New("topic does not exist")
+)
+
+//------------------------------------------------------------------------------
+
 // ErrUnexpectedHTTPRes is an error returned when an HTTP request returned an
 // unexpected response.
 type ErrUnexpectedHTTPRes struct {
@@ -73,3 +98,29 @@ func (e ErrUnexpectedHTTPRes) Error() string {
 }
 
 //------------------------------------------------------------------------------
+
+// NonRetriableError wraps an error that will not be resolved by retrying the
+// same action, such as a message that is too large to ever be accepted by its
+// target. Retry mechanisms should give up immediately upon encountering it.
+type NonRetriableError struct {
+	Err error
+}
+
+// Error returns the Error string.
+func (e NonRetriableError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e NonRetriableError) Unwrap() error {
+	return e.Err
+}
+
+// IsNonRetriable returns true if an error, or any error that it wraps, is a
+// NonRetriableError.
+func IsNonRetriable(err error) bool {
+	var nErr NonRetriableError
+	return errors.As(err, &nErr)
+}
+
+//------------------------------------------------------------------------------
@@ -1,6 +1,10 @@
 package types
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
 
 func TestHTTPError(t *testing.T) {
 	err := ErrUnexpectedHTTPRes{
@@ -13,3 +17,26 @@ func TestHTTPError(t *testing.T) {
 		t.Errorf("Wrong Error() from ErrUnexpectedHTTPRes: %v != %v", exp, act)
 	}
 }
+
+func TestNonRetriableError(t *testing.T) {
+	inner := errors.New("message too large")
+	err := NonRetriableError{Err: inner}
+
+	if exp, act := "message too large", err.Error(); exp != act {
+		t.Errorf("Wrong Error() from NonRetriableError: %v != %v", exp, act)
+	}
+	if !IsNonRetriable(err) {
+		t.Error("Expected NonRetriableError to be non-retriable")
+	}
+	if !IsNonRetriable(fmt.Errorf("failed to send: %w", err)) {
+		t.Error("Expected wrapped NonRetriableError to be non-retriable")
+	}
+	if !errors.Is(err, inner) {
+		t.Error("Expected NonRetriableError to unwrap to its cause")
+	}
+	for _, e := range []error{nil, inner, ErrTimeout, ErrNotConnected, ErrTypeClosed} {
+		if IsNonRetriable(e) {
+			t.Errorf("Expected error to be retriable: %v", e)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"context"
+	"io"
 	"net/http"
 	"time"
 )
@@ -33,6 +35,77 @@ type Cache interface {
 	Closable
 }
 
+// CacheStreamer is an optional interface implemented by caches that are able
+// to read values incrementally rather than loading them entirely into memory.
+type CacheStreamer interface {
+	// GetStream attempts to locate a cached value by its key and returns a
+	// reader of its contents, returns an error if the key does not exist or if
+	// the command fails. The returned reader must be closed by the caller.
+	GetStream(key string) (io.ReadCloser, error)
+}
+
+// CASCache is an optional interface implemented by caches that are able to
+// atomically update a key based on its current value.
+type CASCache interface {
+	// CompareAndSet attempts to set the value of a key only if its current
+	// value matches old, where a nil old value matches a key that does not
+	// exist. Returns ErrCASConflict if the current value does not match, or
+	// an error if the command fails.
+	CompareAndSet(key string, old, new []byte) error
+}
+
+// TransactionalCache is an optional interface implemented by caches that are
+// able to set multiple keys atomically, where either all of the keys of a call
+// to SetMulti are written or none of them are.
+type TransactionalCache interface {
+	// Transactional returns whether calls to SetMulti are atomic.
+	Transactional() bool
+}
+
+// ContextCache is an optional interface implemented by caches that are able to
+// abandon operations when a context is cancelled, which prevents a slow cache
+// from blocking a graceful shutdown.
+type ContextCache interface {
+	// GetWithContext attempts to locate and return a cached value by its key,
+	// returns an error if the key does not exist, if the command fails or if
+	// the context is cancelled.
+	GetWithContext(ctx context.Context, key string) ([]byte, error)
+
+	// SetWithContext attempts to set the value of a key, returns an error if
+	// the command fails or if the context is cancelled.
+	SetWithContext(ctx context.Context, key string, value []byte) error
+}
+
+// TTLCache is an optional interface implemented by caches that are able to
+// override their default TTL for individual keys.
+type TTLCache interface {
+	// SetWithTTL attempts to set the value of a key with a TTL that overrides
+	// the default of the cache, returns an error if the command fails.
+	SetWithTTL(key string, value []byte, ttl time.Duration) error
+}
+
+// TombstoneCache is an optional interface implemented by caches that are able
+// to leave a tombstone in place of a deleted key, which prevents the key from
+// being added again until a grace period has passed.
+type TombstoneCache interface {
+	// DeleteWithTTL attempts to remove a key and leaves a tombstone for the
+	// duration of the grace period, during which attempts to Add the key
+	// return ErrKeyAlreadyExists.
+	DeleteWithTTL(key string, grace time.Duration) error
+}
+
+// CounterCache is an optional interface implemented by caches that are able to
+// atomically increment a numeric value, such as the INCRBY command of Redis.
+// Values are stored as base 10 integer strings, and therefore a counter can
+// also be read with Get or reset with Set.
+type CounterCache interface {
+	// Increment atomically adds delta to the value of a key and returns the
+	// new value. If the key does not exist it is created with the value of
+	// delta. Returns ErrCounterNotNumeric if the current value is not a base
+	// 10 integer, or an error if the command fails.
+	Increment(key string, delta int64) (int64, error)
+}
+
 //------------------------------------------------------------------------------
 
 // RateLimit is a strategy for limiting access to a shared resource, this
@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
@@ -70,6 +71,12 @@ func NewConfig() Config {
 
 //------------------------------------------------------------------------------
 
+// ErrRetryOnResponse is returned when a response continued to match the retry
+// check of a client after all retries were exhausted.
+var ErrRetryOnResponse = errors.New("response body indicated a failure after all retries")
+
+//------------------------------------------------------------------------------
+
 // Type is an output type that pushes messages to Type.
 type Type struct {
 	client http.Client
@@ -85,6 +92,7 @@ type Type struct {
 	conf          Config
 	retryThrottle *throttle.Type
 	rateLimit     types.RateLimit
+	retryOn       func(types.Message) bool
 
 	log   log.Modular
 	stats metrics.Type
@@ -95,6 +103,7 @@ type Type struct {
 	mErrReq        metrics.StatCounter
 	mErrReqTimeout metrics.StatCounter
 	mErrRes        metrics.StatCounter
+	mErrResRetryOn metrics.StatCounter
 	mLimited       metrics.StatCounter
 	mLimitFor      metrics.StatCounter
 	mLimitErr      metrics.StatCounter
@@ -166,6 +175,7 @@ func New(conf Config, opts ...func(*Type)) (*Type, error) {
 	h.mErrReq = h.stats.GetCounter("error.request")
 	h.mErrReqTimeout = h.stats.GetCounter("request_timeout")
 	h.mErrRes = h.stats.GetCounter("error.response")
+	h.mErrResRetryOn = h.stats.GetCounter("error.response.retry_on")
 	h.mLimited = h.stats.GetCounter("rate_limit.count")
 	h.mLimitFor = h.stats.GetCounter("rate_limit.total_ms")
 	h.mLimitErr = h.stats.GetCounter("rate_limit.error")
@@ -235,6 +245,15 @@ func OptSetManager(mgr types.Manager) func(*Type) {
 	}
 }
 
+// OptSetRetryOn sets a check that is performed on the parsed response of a
+// successful request sent with Send, and if the check returns true the request
+// is retried as if it had failed.
+func OptSetRetryOn(check func(types.Message) bool) func(*Type) {
+	return func(t *Type) {
+		t.retryOn = check
+	}
+}
+
 // OptSetHTTPTransport sets the HTTP Transport to use. NOTE: This setting will
 // override any configured TLS options.
 func OptSetHTTPTransport(transport *http.Transport) func(*Type) {
@@ -473,6 +492,15 @@ func (h *Type) checkStatus(code int) (succeeded bool, retStrat retryStrategy) {
 // This attempt may include retries, and if all retries fail an error is
 // returned.
 func (h *Type) Do(msg types.Message) (res *http.Response, err error) {
+	res, _, err = h.do(msg, false)
+	return
+}
+
+// do performs an HTTP request from a message payload with retries. When
+// checkBody is true the response of a successful request is parsed and checked
+// against the retry_on check of the client, and the request is retried if the
+// check passes, in which case the parsed response is also returned.
+func (h *Type) do(msg types.Message, checkBody bool) (res *http.Response, resMsg types.Message, err error) {
 	h.mCount.Incr(1)
 
 	var spans []opentracing.Span
@@ -502,34 +530,43 @@ func (h *Type) Do(msg types.Message) (res *http.Response, err error) {
 		h.mErrReq.Incr(1)
 		h.mErr.Incr(1)
 		logErr(err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	startedAt := time.Now()
 
 	if !h.waitForAccess() {
-		return nil, types.ErrTypeClosed
+		return nil, nil, types.ErrTypeClosed
 	}
 
+	var parseErr error
 	rateLimited := false
-	numRetries := h.conf.NumRetries
-	if res, err = h.client.Do(req); err == nil {
-		h.incrCode(res.StatusCode)
-		if resolved, retryStrat := h.checkStatus(res.StatusCode); !resolved {
-			rateLimited = retryStrat == retryBackoff
-			if retryStrat == noRetry {
-				numRetries = 0
-			}
-			err = types.ErrUnexpectedHTTPRes{Code: res.StatusCode, S: res.Status}
-			if res.Body != nil {
-				res.Body.Close()
+	i, j := 0, h.conf.NumRetries
+	attempt := func() {
+		rateLimited = false
+		if res, err = h.client.Do(req); err == nil {
+			h.incrCode(res.StatusCode)
+			if resolved, retryStrat := h.checkStatus(res.StatusCode); !resolved {
+				rateLimited = retryStrat == retryBackoff
+				if retryStrat == noRetry {
+					j = 0
+				}
+				err = types.ErrUnexpectedHTTPRes{Code: res.StatusCode, S: res.Status}
+				if res.Body != nil {
+					res.Body.Close()
+				}
+			} else if checkBody {
+				if resMsg, parseErr = h.ParseResponse(res); parseErr == nil && resMsg != nil && h.retryOn(resMsg) {
+					h.mErrResRetryOn.Incr(1)
+					resMsg, err = nil, ErrRetryOnResponse
+				}
 			}
+		} else if err, ok := err.(net.Error); ok && err.Timeout() {
+			h.mErrReqTimeout.Incr(1)
 		}
-	} else if err, ok := err.(net.Error); ok && err.Timeout() {
-		h.mErrReqTimeout.Incr(1)
 	}
 
-	i, j := 0, numRetries
+	attempt()
 	for i < j && err != nil {
 		h.mErrRes.Incr(1)
 		h.mErr.Incr(1)
@@ -544,32 +581,17 @@ func (h *Type) Do(msg types.Message) (res *http.Response, err error) {
 		}
 		if rateLimited {
 			if !h.retryThrottle.ExponentialRetry() {
-				return nil, types.ErrTypeClosed
+				return nil, nil, types.ErrTypeClosed
 			}
 		} else {
 			if !h.retryThrottle.Retry() {
-				return nil, types.ErrTypeClosed
+				return nil, nil, types.ErrTypeClosed
 			}
 		}
 		if !h.waitForAccess() {
-			return nil, types.ErrTypeClosed
-		}
-		rateLimited = false
-		if res, err = h.client.Do(req); err == nil {
-			h.incrCode(res.StatusCode)
-			if resolved, retryStrat := h.checkStatus(res.StatusCode); !resolved {
-				rateLimited = retryStrat == retryBackoff
-				if retryStrat == noRetry {
-					j = 0
-				}
-				err = types.ErrUnexpectedHTTPRes{Code: res.StatusCode, S: res.Status}
-				if res.Body != nil {
-					res.Body.Close()
-				}
-			}
-		} else if err, ok := err.(net.Error); ok && err.Timeout() {
-			h.mErrReqTimeout.Incr(1)
+			return nil, nil, types.ErrTypeClosed
 		}
+		attempt()
 		i++
 	}
 
@@ -577,13 +599,13 @@ func (h *Type) Do(msg types.Message) (res *http.Response, err error) {
 		h.mErrRes.Incr(1)
 		h.mErr.Incr(1)
 		logErr(err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	h.mLatency.Timing(int64(time.Since(startedAt)))
 	h.mSucc.Incr(1)
 	h.retryThrottle.Reset()
-	return res, nil
+	return res, resMsg, parseErr
 }
 
 // Send attempts to send a message to an HTTP server, this attempt may include
@@ -593,6 +615,10 @@ func (h *Type) Do(msg types.Message) (res *http.Response, err error) {
 //
 // If the response body is empty the message returned is nil.
 func (h *Type) Send(msg types.Message) (types.Message, error) {
+	if h.retryOn != nil {
+		_, resMsg, err := h.do(msg, true)
+		return resMsg, err
+	}
 	res, err := h.Do(msg)
 	if err != nil {
 		return nil, err
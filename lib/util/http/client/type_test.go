@@ -52,6 +52,100 @@ func TestHTTPClientRetries(t *testing.T) {
 	}
 }
 
+func TestHTTPClientRetryOn(t *testing.T) {
+	var reqCount uint32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddUint32(&reqCount, 1) < 3 {
+			w.Write([]byte(`{"status":"busy"}`))
+			return
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.URL = ts.URL + "/testpost"
+	conf.Retry = "1ms"
+	conf.NumRetries = 3
+
+	h, err := New(conf, OptSetRetryOn(func(msg types.Message) bool {
+		return string(msg.Get(0).Get()) == `{"status":"busy"}`
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resMsg, err := h.Send(message.New([][]byte{[]byte("test")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := `{"status":"ok"}`, string(resMsg.Get(0).Get()); exp != act {
+		t.Errorf("Wrong response: %v != %v", act, exp)
+	}
+	if exp, act := uint32(3), atomic.LoadUint32(&reqCount); exp != act {
+		t.Errorf("Wrong count of HTTP attempts: %v != %v", exp, act)
+	}
+}
+
+func TestHTTPClientRetryOnExhausted(t *testing.T) {
+	var reqCount uint32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint32(&reqCount, 1)
+		w.Write([]byte(`{"status":"busy"}`))
+	}))
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.URL = ts.URL + "/testpost"
+	conf.Retry = "1ms"
+	conf.NumRetries = 2
+
+	h, err := New(conf, OptSetRetryOn(func(msg types.Message) bool {
+		return true
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = h.Send(message.New([][]byte{[]byte("test")})); err != ErrRetryOnResponse {
+		t.Errorf("Wrong error returned: %v != %v", err, ErrRetryOnResponse)
+	}
+	if exp, act := uint32(3), atomic.LoadUint32(&reqCount); exp != act {
+		t.Errorf("Wrong count of HTTP attempts: %v != %v", exp, act)
+	}
+}
+
+func TestHTTPClientRetryOnSharesRetries(t *testing.T) {
+	var reqCount uint32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddUint32(&reqCount, 1)%2 == 0 {
+			http.Error(w, "nope", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"status":"busy"}`))
+	}))
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.URL = ts.URL + "/testpost"
+	conf.Retry = "1ms"
+	conf.NumRetries = 3
+
+	h, err := New(conf, OptSetRetryOn(func(msg types.Message) bool {
+		return string(msg.Get(0).Get()) == `{"status":"busy"}`
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = h.Send(message.New([][]byte{[]byte("test")})); err == nil {
+		t.Error("Expected error")
+	}
+	if exp, act := uint32(4), atomic.LoadUint32(&reqCount); exp != act {
+		t.Errorf("Wrong count of HTTP attempts: %v != %v", exp, act)
+	}
+}
+
 func TestHTTPClientBadRequest(t *testing.T) {
 	conf := NewConfig()
 	conf.URL = "htp://notvalid:1111"
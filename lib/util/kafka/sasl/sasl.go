@@ -1,11 +1,19 @@
 package sasl
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/Jeffail/benthos/v3/lib/types"
 	"github.com/Jeffail/benthos/v3/lib/x/docs"
 	"github.com/Shopify/sarama"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
 )
 
 // SASL specific error types.
@@ -22,11 +30,29 @@ type Config struct {
 	AccessToken string `json:"access_token" yaml:"access_token"`
 	TokenCache  string `json:"token_cache" yaml:"token_cache"`
 	TokenKey    string `json:"token_key" yaml:"token_key"`
+
+	UserFile     string `json:"user_file" yaml:"user_file"`
+	PasswordFile string `json:"password_file" yaml:"password_file"`
+
+	OAuth OAuthConfig `json:"oauth" yaml:"oauth"`
+}
+
+// OAuthConfig contains configuration for fetching SASL OAUTHBEARER access
+// tokens from an OAuth2 client credentials endpoint.
+type OAuthConfig struct {
+	TokenURL     string   `json:"token_url" yaml:"token_url"`
+	ClientID     string   `json:"client_id" yaml:"client_id"`
+	ClientSecret string   `json:"client_secret" yaml:"client_secret"`
+	Scopes       []string `json:"scopes" yaml:"scopes"`
 }
 
 // NewConfig returns a new SASL config for Kafka with default values.
 func NewConfig() Config {
-	return Config{} // Well, that seemed pointless.
+	return Config{
+		OAuth: OAuthConfig{
+			Scopes: []string{},
+		},
+	}
 }
 
 // FieldSpec returns specs for SASL fields.
@@ -34,21 +60,73 @@ func FieldSpec() docs.FieldSpec {
 	return docs.FieldAdvanced("sasl", "Enables SASL authentication.").WithChildren(
 		docs.FieldDeprecated("enabled"),
 		docs.FieldCommon("mechanism", "The SASL authentication mechanism, if left empty SASL authentication is not used. Warning: SCRAM based methods within Benthos have not received a security audit.").HasOptions(sarama.SASLTypePlaintext, sarama.SASLTypeOAuth, sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512),
-		docs.FieldCommon("user", "A `"+sarama.SASLTypePlaintext+"` username. It is recommended that you use environment variables to populate this field.", "${USER}"),
-		docs.FieldCommon("password", "A `"+sarama.SASLTypePlaintext+"` password. It is recommended that you use environment variables to populate this field.", "${PASSWORD}"),
+		docs.FieldCommon("user", "A `"+sarama.SASLTypePlaintext+"` or SCRAM username. It is recommended that you use environment variables to populate this field.", "${USER}"),
+		docs.FieldCommon("password", "A `"+sarama.SASLTypePlaintext+"` or SCRAM password. It is recommended that you use environment variables to populate this field.", "${PASSWORD}"),
 		docs.FieldAdvanced("access_token", "A static `"+sarama.SASLTypeOAuth+"` access token"),
 		docs.FieldAdvanced("token_cache", "Instead of using a static `access_token` allows you to query a [`cache`](/docs/components/caches/about) resource to fetch `"+sarama.SASLTypeOAuth+"` tokens from"),
 		docs.FieldAdvanced("token_key", "Required when using a `token_cache`, the key to query the cache with for tokens."),
+		docs.FieldAdvanced("user_file", "A path to a file containing the username, which is read each time a connection is established in order to support credential rotation. Ignored when `user` is set.", "/etc/kafka/user"),
+		docs.FieldAdvanced("password_file", "A path to a file containing the password, which is read each time a connection is established in order to support credential rotation. Ignored when `password` is set.", "/etc/kafka/password"),
+		docs.FieldAdvanced("oauth", "Instead of using a static `access_token` allows you to fetch `"+sarama.SASLTypeOAuth+"` tokens from an OAuth2 client credentials endpoint. Tokens are cached and refreshed shortly before they expire, and a failure to fetch a token fails the connection attempt, which is then retried. Ignored when `token_cache` is set.").WithChildren(
+			docs.FieldCommon("token_url", "The URL of the token endpoint, if left empty tokens are not fetched.", "https://auth.example.com/oauth2/token"),
+			docs.FieldCommon("client_id", "The client ID to authenticate with."),
+			docs.FieldCommon("client_secret", "The client secret to authenticate with. It is recommended that you use environment variables to populate this field.", "${CLIENT_SECRET}"),
+			docs.FieldCommon("scopes", "An optional list of scopes to request."),
+		),
+	)
+}
+
+// Validate returns an error if the configured mechanism is not supported.
+func (s Config) Validate() error {
+	if s.OAuth.TokenURL != "" && s.OAuth.ClientID == "" {
+		return errors.New("oauth client_id must be set when a token_url is set")
+	}
+	switch s.Mechanism {
+	case "",
+		sarama.SASLTypePlaintext,
+		sarama.SASLTypeOAuth,
+		sarama.SASLTypeSCRAMSHA256,
+		sarama.SASLTypeSCRAMSHA512:
+		return nil
+	}
+	return fmt.Errorf(
+		"%v '%v', expected one of: %v, %v, %v, %v", ErrUnsupportedSASLMechanism, s.Mechanism,
+		sarama.SASLTypePlaintext, sarama.SASLTypeOAuth, sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512,
 	)
 }
 
-// Apply applies the SASL authentication configuration to a Sarama config object.
+// readCredential returns an inline credential when set, otherwise the contents
+// of a credential file without trailing line breaks.
+func readCredential(inline, path string) (string, error) {
+	if len(inline) > 0 || len(path) == 0 {
+		return inline, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read credential file: %v", err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// Apply applies the SASL authentication configuration to a Sarama config
+// object. Credential files are read each time it is called, and therefore it
+// should be called for each new connection.
 func (s Config) Apply(mgr types.Manager, conf *sarama.Config) error {
 	conf.Net.TLS.Enable = true
 	if s.Enabled && len(s.Mechanism) == 0 {
 		s.Mechanism = sarama.SASLTypePlaintext
 	}
 	switch s.Mechanism {
+	case sarama.SASLTypePlaintext, sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512:
+		var err error
+		if s.User, err = readCredential(s.User, s.UserFile); err != nil {
+			return err
+		}
+		if s.Password, err = readCredential(s.Password, s.PasswordFile); err != nil {
+			return err
+		}
+	}
+	switch s.Mechanism {
 	case sarama.SASLTypeOAuth:
 		var tp sarama.AccessTokenProvider
 		var err error
@@ -58,6 +136,8 @@ func (s Config) Apply(mgr types.Manager, conf *sarama.Config) error {
 			if err != nil {
 				return err
 			}
+		} else if s.OAuth.TokenURL != "" {
+			tp = newOAuthAccessTokenProvider(s.OAuth)
 		} else {
 			tp, err = newStaticAccessTokenProvider(s.AccessToken)
 			if err != nil {
@@ -69,10 +149,14 @@ func (s Config) Apply(mgr types.Manager, conf *sarama.Config) error {
 		conf.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
 			return &XDGSCRAMClient{HashGeneratorFcn: SHA256}
 		}
+		conf.Net.SASL.User = s.User
+		conf.Net.SASL.Password = s.Password
 	case sarama.SASLTypeSCRAMSHA512:
 		conf.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
 			return &XDGSCRAMClient{HashGeneratorFcn: SHA512}
 		}
+		conf.Net.SASL.User = s.User
+		conf.Net.SASL.Password = s.Password
 	case sarama.SASLTypePlaintext:
 		conf.Net.SASL.User = s.User
 		conf.Net.SASL.Password = s.Password
@@ -116,6 +200,42 @@ func (c *cacheAccessTokenProvider) Token() (*sarama.AccessToken, error) {
 
 //------------------------------------------------------------------------------
 
+// oauthTokenTimeout is the maximum period of time to wait for a token endpoint
+// to respond.
+var oauthTokenTimeout = time.Second * 10
+
+// oauthAccessTokenProvider fetches SASL OAUTHBEARER access tokens from an
+// OAuth2 client credentials endpoint. Tokens are cached until shortly before
+// they expire.
+type oauthAccessTokenProvider struct {
+	source oauth2.TokenSource
+}
+
+func newOAuthAccessTokenProvider(conf OAuthConfig) *oauthAccessTokenProvider {
+	ccConf := clientcredentials.Config{
+		ClientID:     conf.ClientID,
+		ClientSecret: conf.ClientSecret,
+		TokenURL:     conf.TokenURL,
+		Scopes:       conf.Scopes,
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
+		Timeout: oauthTokenTimeout,
+	})
+	return &oauthAccessTokenProvider{
+		source: ccConf.TokenSource(ctx),
+	}
+}
+
+func (o *oauthAccessTokenProvider) Token() (*sarama.AccessToken, error) {
+	tok, err := o.source.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch oauth token: %v", err)
+	}
+	return &sarama.AccessToken{Token: tok.AccessToken}, nil
+}
+
+//------------------------------------------------------------------------------
+
 // staticAccessTokenProvider provides a static SASL OAUTHBEARER access token.
 type staticAccessTokenProvider struct {
 	token string
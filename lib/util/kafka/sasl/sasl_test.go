@@ -1,6 +1,14 @@
 package sasl
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/Jeffail/benthos/v3/lib/types"
@@ -174,6 +182,92 @@ func TestApplyOAuthBearerCacheProvider(t *testing.T) {
 	}
 }
 
+func TestApplyOAuthBearerOAuthProvider(t *testing.T) {
+	var reqs int32
+	var expiresIn int32 = 3600
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&reqs, 1)
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if exp, act := "client_credentials", r.PostForm.Get("grant_type"); exp != act {
+			t.Errorf("Wrong grant type: %v != %v", act, exp)
+		}
+		if exp, act := "foo bar", r.PostForm.Get("scope"); exp != act {
+			t.Errorf("Wrong scope: %v != %v", act, exp)
+		}
+		if user, pass, _ := r.BasicAuth(); user != "id" || pass != "secret" {
+			t.Errorf("Wrong client credentials: %v:%v", user, pass)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token%v","token_type":"bearer","expires_in":%v}`, n, atomic.LoadInt32(&expiresIn))
+	}))
+	defer ts.Close()
+
+	saslConf := NewConfig()
+	saslConf.Mechanism = sarama.SASLTypeOAuth
+	saslConf.OAuth.TokenURL = ts.URL
+	saslConf.OAuth.ClientID = "id"
+	saslConf.OAuth.ClientSecret = "secret"
+	saslConf.OAuth.Scopes = []string{"foo", "bar"}
+
+	conf := &sarama.Config{}
+	if err := saslConf.Apply(types.NoopMgr(), conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Net.SASL.Mechanism != sarama.SASLTypeOAuth {
+		t.Errorf("Wrong SASL mechanism: %v != %v", conf.Net.SASL.Mechanism, sarama.SASLTypeOAuth)
+	}
+
+	// Tokens are cached until they are close to expiring.
+	for i := 0; i < 2; i++ {
+		token, err := conf.Net.SASL.TokenProvider.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exp, act := "token1", token.Token; exp != act {
+			t.Errorf("Wrong SASL token: %v != %v", act, exp)
+		}
+	}
+
+	// Tokens that expire shortly are refreshed each time.
+	atomic.StoreInt32(&expiresIn, 1)
+	conf = &sarama.Config{}
+	if err := saslConf.Apply(types.NoopMgr(), conf); err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{"token2", "token3"} {
+		token, err := conf.Net.SASL.TokenProvider.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if act := token.Token; exp != act {
+			t.Errorf("Wrong SASL token: %v != %v", act, exp)
+		}
+	}
+}
+
+func TestApplyOAuthBearerOAuthProviderFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	saslConf := NewConfig()
+	saslConf.Mechanism = sarama.SASLTypeOAuth
+	saslConf.OAuth.TokenURL = ts.URL
+	saslConf.OAuth.ClientID = "id"
+
+	conf := &sarama.Config{}
+	if err := saslConf.Apply(types.NoopMgr(), conf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conf.Net.SASL.TokenProvider.Token(); err == nil {
+		t.Error("Expected failure to get token")
+	}
+}
+
 func TestApplyUnknownMechanism(t *testing.T) {
 	conf := &sarama.Config{}
 
@@ -187,4 +281,124 @@ func TestApplyUnknownMechanism(t *testing.T) {
 	}
 }
 
+func TestApplySCRAM(t *testing.T) {
+	for _, mechanism := range []string{sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512} {
+		conf := &sarama.Config{}
+
+		saslConf := Config{
+			Mechanism: mechanism,
+			User:      "foo",
+			Password:  "bar",
+		}
+
+		if err := saslConf.Apply(types.NoopMgr(), conf); err != nil {
+			t.Fatal(err)
+		}
+
+		if !conf.Net.SASL.Enable {
+			t.Errorf("SASL not enabled")
+		}
+		if exp, act := sarama.SASLMechanism(mechanism), conf.Net.SASL.Mechanism; exp != act {
+			t.Errorf("Wrong SASL mechanism: %v != %v", act, exp)
+		}
+		if conf.Net.SASL.User != "foo" {
+			t.Errorf("Wrong SASL user: %v != %v", conf.Net.SASL.User, "foo")
+		}
+		if conf.Net.SASL.Password != "bar" {
+			t.Errorf("Wrong SASL password: %v != %v", conf.Net.SASL.Password, "bar")
+		}
+		if conf.Net.SASL.SCRAMClientGeneratorFunc == nil {
+			t.Fatal("SCRAM client generator not set")
+		}
+		if err := conf.Net.SASL.SCRAMClientGeneratorFunc().Begin("foo", "bar", ""); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, mechanism := range []string{
+		"",
+		sarama.SASLTypePlaintext,
+		sarama.SASLTypeOAuth,
+		sarama.SASLTypeSCRAMSHA256,
+		sarama.SASLTypeSCRAMSHA512,
+	} {
+		if err := (Config{Mechanism: mechanism}).Validate(); err != nil {
+			t.Errorf("Unexpected error for mechanism '%v': %v", mechanism, err)
+		}
+	}
+
+	oauthConf := Config{Mechanism: sarama.SASLTypeOAuth}
+	oauthConf.OAuth.TokenURL = "http://localhost"
+	if err := oauthConf.Validate(); err == nil {
+		t.Error("Expected error from missing oauth client_id")
+	}
+
+	err := (Config{Mechanism: "SCRAM-SHA-1"}).Validate()
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	if exp := "unsupported SASL mechanism 'SCRAM-SHA-1'"; !strings.Contains(err.Error(), exp) {
+		t.Errorf("Expected error '%v' to contain '%v'", err, exp)
+	}
+}
+
 //------------------------------------------------------------------------------
+
+func TestApplyCredentialFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_sasl_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	userPath, passPath := filepath.Join(dir, "user"), filepath.Join(dir, "password")
+	writeCreds := func(user, pass string) {
+		t.Helper()
+		if err := ioutil.WriteFile(userPath, []byte(user+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(passPath, []byte(pass+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	saslConf := Config{
+		Mechanism:    string(sarama.SASLTypeSCRAMSHA256),
+		UserFile:     userPath,
+		PasswordFile: passPath,
+	}
+
+	for _, creds := range [][2]string{{"foo", "bar"}, {"baz", "qux"}} {
+		writeCreds(creds[0], creds[1])
+
+		conf := &sarama.Config{}
+		if err := saslConf.Apply(types.NoopMgr(), conf); err != nil {
+			t.Fatal(err)
+		}
+		if conf.Net.SASL.User != creds[0] {
+			t.Errorf("Wrong SASL user: %v != %v", conf.Net.SASL.User, creds[0])
+		}
+		if conf.Net.SASL.Password != creds[1] {
+			t.Errorf("Wrong SASL password: %v != %v", conf.Net.SASL.Password, creds[1])
+		}
+	}
+
+	saslConf.User = "inline"
+	conf := &sarama.Config{}
+	if err := saslConf.Apply(types.NoopMgr(), conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Net.SASL.User != "inline" {
+		t.Errorf("Wrong SASL user: %v != %v", conf.Net.SASL.User, "inline")
+	}
+	if conf.Net.SASL.Password != "qux" {
+		t.Errorf("Wrong SASL password: %v != %v", conf.Net.SASL.Password, "qux")
+	}
+
+	saslConf.PasswordFile = filepath.Join(dir, "does_not_exist")
+	if err := saslConf.Apply(types.NoopMgr(), &sarama.Config{}); err == nil {
+		t.Error("Expected error from missing password file")
+	}
+}
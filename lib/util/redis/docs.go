@@ -0,0 +1,12 @@
+package redis
+
+import "github.com/Jeffail/benthos/v3/lib/x/docs"
+
+// FieldSpecs returns documentation specs for the connection pool fields.
+func FieldSpecs() docs.FieldSpecs {
+	return docs.FieldSpecs{
+		docs.FieldAdvanced("pool_size", "The maximum number of connections to keep open to the Redis server. If set to zero the default of ten connections per available CPU is used."),
+		docs.FieldAdvanced("min_idle_conns", "The minimum number of idle connections to keep open, which is useful when establishing new connections is slow."),
+		docs.FieldAdvanced("max_conn_age", "The maximum age of a connection after which it is closed and replaced. If empty connections are not closed due to their age.", "30m"),
+	}
+}
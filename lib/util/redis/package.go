@@ -0,0 +1,3 @@
+// Package redis provides Benthos configuration fields for pooled connections to
+// a Redis server, shared by all Redis based components.
+package redis
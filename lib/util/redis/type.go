@@ -0,0 +1,87 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//------------------------------------------------------------------------------
+
+// PoolConfig contains configuration fields for pooling connections to a Redis
+// server.
+type PoolConfig struct {
+	PoolSize     int    `json:"pool_size" yaml:"pool_size"`
+	MinIdleConns int    `json:"min_idle_conns" yaml:"min_idle_conns"`
+	MaxConnAge   string `json:"max_conn_age" yaml:"max_conn_age"`
+}
+
+// NewPoolConfig creates a new PoolConfig with default values.
+func NewPoolConfig() PoolConfig {
+	return PoolConfig{
+		PoolSize:     0,
+		MinIdleConns: 0,
+		MaxConnAge:   "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Validate returns an error if the pool configuration is invalid.
+func (c PoolConfig) Validate() error {
+	_, err := c.maxConnAge()
+	return err
+}
+
+func (c PoolConfig) maxConnAge() (time.Duration, error) {
+	if c.PoolSize < 0 {
+		return 0, errors.New("pool_size must not be negative")
+	}
+	if c.MinIdleConns < 0 {
+		return 0, errors.New("min_idle_conns must not be negative")
+	}
+	if len(c.MaxConnAge) == 0 {
+		return 0, nil
+	}
+	age, err := time.ParseDuration(c.MaxConnAge)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse max_conn_age duration string: %v", err)
+	}
+	return age, nil
+}
+
+// Options returns the go-redis client options for a server URL using the pool
+// configuration.
+func (c PoolConfig) Options(u *url.URL) (*redis.Options, error) {
+	age, err := c.maxConnAge()
+	if err != nil {
+		return nil, err
+	}
+	var pass string
+	if u.User != nil {
+		pass, _ = u.User.Password()
+	}
+	return &redis.Options{
+		Addr:         u.Host,
+		Network:      u.Scheme,
+		Password:     pass,
+		PoolSize:     c.PoolSize,
+		MinIdleConns: c.MinIdleConns,
+		MaxConnAge:   age,
+	}, nil
+}
+
+// Client returns a go-redis client for a server URL using the pool
+// configuration.
+func (c PoolConfig) Client(u *url.URL) (*redis.Client, error) {
+	opts, err := c.Options(u)
+	if err != nil {
+		return nil, err
+	}
+	return redis.NewClient(opts), nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,57 @@
+package redis
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPoolConfigOptions(t *testing.T) {
+	u, err := url.Parse("tcp://:foopass@localhost:6379")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := NewPoolConfig()
+	conf.PoolSize = 50
+	conf.MinIdleConns = 5
+	conf.MaxConnAge = "30m"
+
+	opts, err := conf.Options(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := "localhost:6379", opts.Addr; exp != act {
+		t.Errorf("Wrong addr: %v != %v", act, exp)
+	}
+	if exp, act := "tcp", opts.Network; exp != act {
+		t.Errorf("Wrong network: %v != %v", act, exp)
+	}
+	if exp, act := "foopass", opts.Password; exp != act {
+		t.Errorf("Wrong password: %v != %v", act, exp)
+	}
+	if exp, act := 50, opts.PoolSize; exp != act {
+		t.Errorf("Wrong pool size: %v != %v", act, exp)
+	}
+	if exp, act := 5, opts.MinIdleConns; exp != act {
+		t.Errorf("Wrong min idle conns: %v != %v", act, exp)
+	}
+	if exp, act := time.Minute*30, opts.MaxConnAge; exp != act {
+		t.Errorf("Wrong max conn age: %v != %v", act, exp)
+	}
+}
+
+func TestPoolConfigBad(t *testing.T) {
+	tests := map[string]func(*PoolConfig){
+		"bad max conn age":   func(c *PoolConfig) { c.MaxConnAge = "nope" },
+		"negative pool size": func(c *PoolConfig) { c.PoolSize = -1 },
+		"negative min idle":  func(c *PoolConfig) { c.MinIdleConns = -1 },
+	}
+	for name, mod := range tests {
+		conf := NewPoolConfig()
+		mod(&conf)
+		if err := conf.Validate(); err == nil {
+			t.Errorf("%v: Expected error", name)
+		}
+	}
+}
@@ -5,7 +5,7 @@ import "github.com/Jeffail/benthos/v3/lib/x/docs"
 // FieldSpecs returns documentation specs for retry fields.
 func FieldSpecs() docs.FieldSpecs {
 	return docs.FieldSpecs{
-		docs.FieldAdvanced("max_retries", "The maximum number of retries before giving up on the request. If set to zero there is no discrete limit."),
+		docs.FieldAdvanced("max_retries", "The maximum number of retries before giving up on the request. If set to zero the request is retried forever, or until `max_elapsed_time` is reached when it is set. Negative values are rejected."),
 		docs.FieldAdvanced("backoff", "Control time intervals between retry attempts.").WithChildren(
 			docs.FieldAdvanced("initial_interval", "The initial period to wait between retry attempts."),
 			docs.FieldAdvanced("max_interval", "The maximum period to wait between retry attempts."),
@@ -2,8 +2,10 @@ package retries
 
 import (
 	"fmt"
+	"math/rand"
 	"time"
 
+	"github.com/Jeffail/benthos/v3/lib/types"
 	"github.com/cenkalti/backoff"
 )
 
@@ -18,6 +20,11 @@ type Backoff struct {
 }
 
 // Config contains configuration params for a retries mechanism.
+//
+// MaxRetries is the number of retries allowed after the first attempt, where
+// zero means that attempts are retried forever unless stopped by the max
+// elapsed time of the backoff. The field is unsigned and therefore negative
+// values are rejected when a config is parsed.
 type Config struct {
 	MaxRetries uint64  `json:"max_retries" yaml:"max_retries"`
 	Backoff    Backoff `json:"backoff" yaml:"backoff"`
@@ -50,6 +57,19 @@ func (c *Config) Get() (backoff.BackOff, error) {
 // GetCtor returns a constructor for a backoff.Backoff based on the
 // configuration values of Config.
 func (c *Config) GetCtor() (func() backoff.BackOff, error) {
+	return c.getCtor(false)
+}
+
+// GetFullJitterCtor returns a constructor for a backoff.Backoff based on the
+// configuration values of Config, where each period is chosen at random between
+// zero and the exponential period of the attempt. This spreads out the retries
+// of many clients that failed at the same time, such as when reconnecting to a
+// restarted server.
+func (c *Config) GetFullJitterCtor() (func() backoff.BackOff, error) {
+	return c.getCtor(true)
+}
+
+func (c *Config) getCtor(fullJitter bool) (func() backoff.BackOff, error) {
 	var initInterval, maxInterval, maxElapsed time.Duration
 	var err error
 	if c.Backoff.InitialInterval != "" {
@@ -75,11 +95,52 @@ func (c *Config) GetCtor() (func() backoff.BackOff, error) {
 		boff.MaxInterval = maxInterval
 		boff.MaxElapsedTime = maxElapsed
 
+		var b backoff.BackOff = boff
+		if fullJitter {
+			boff.RandomizationFactor = 0
+			b = &fullJitterBackOff{
+				b:    boff,
+				rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+			}
+		}
 		if c.MaxRetries > 0 {
-			return backoff.WithMaxRetries(boff, c.MaxRetries)
+			return backoff.WithMaxRetries(b, c.MaxRetries)
 		}
-		return boff
+		return b
 	}, nil
 }
 
 //------------------------------------------------------------------------------
+
+// fullJitterBackOff wraps a backoff and returns periods chosen at random
+// between zero and the period of the wrapped backoff.
+type fullJitterBackOff struct {
+	b    backoff.BackOff
+	rand *rand.Rand
+}
+
+func (f *fullJitterBackOff) NextBackOff() time.Duration {
+	next := f.b.NextBackOff()
+	if next == backoff.Stop || next <= 0 {
+		return next
+	}
+	return time.Duration(f.rand.Int63n(int64(next) + 1))
+}
+
+func (f *fullJitterBackOff) Reset() {
+	f.b.Reset()
+}
+
+//------------------------------------------------------------------------------
+
+// NextBackOff returns the duration to wait before retrying an action that
+// failed with an error, or backoff.Stop if the error is non-retriable or the
+// backoff has been exhausted.
+func NextBackOff(boff backoff.BackOff, err error) time.Duration {
+	if types.IsNonRetriable(err) {
+		return backoff.Stop
+	}
+	return boff.NextBackOff()
+}
+
+//------------------------------------------------------------------------------
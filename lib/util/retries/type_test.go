@@ -0,0 +1,155 @@
+package retries
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestFullJitterBackOff(t *testing.T) {
+	conf := NewConfig()
+	conf.Backoff.InitialInterval = "100ms"
+	conf.Backoff.MaxInterval = "1s"
+	conf.Backoff.MaxElapsedTime = "0s"
+
+	ctor, err := conf.GetFullJitterCtor()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boff := ctor()
+	var shortest time.Duration = -1
+	for i := 0; i < 100; i++ {
+		next := boff.NextBackOff()
+		if next < 0 || next > time.Second {
+			t.Fatalf("Backoff period out of bounds: %v", next)
+		}
+		if shortest < 0 || next < shortest {
+			shortest = next
+		}
+	}
+	if shortest >= 100*time.Millisecond {
+		t.Errorf("Expected jittered periods below the initial interval, shortest: %v", shortest)
+	}
+}
+
+func TestFullJitterBackOffMaxRetries(t *testing.T) {
+	conf := NewConfig()
+	conf.MaxRetries = 3
+	conf.Backoff.InitialInterval = "1ms"
+
+	ctor, err := conf.GetFullJitterCtor()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boff := ctor()
+	for i := 0; i < 3; i++ {
+		if next := boff.NextBackOff(); next == backoff.Stop {
+			t.Fatalf("Unexpected stop at attempt %v", i)
+		}
+	}
+	if next := boff.NextBackOff(); next != backoff.Stop {
+		t.Errorf("Expected stop after max retries, got: %v", next)
+	}
+
+	boff.Reset()
+	if next := boff.NextBackOff(); next == backoff.Stop {
+		t.Error("Expected backoff to continue after reset")
+	}
+}
+
+func TestFullJitterBackOffMaxElapsed(t *testing.T) {
+	conf := NewConfig()
+	conf.Backoff.InitialInterval = "1ms"
+	conf.Backoff.MaxElapsedTime = "1ms"
+
+	ctor, err := conf.GetFullJitterCtor()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boff := ctor()
+	<-time.After(time.Millisecond * 5)
+	if next := boff.NextBackOff(); next != backoff.Stop {
+		t.Errorf("Expected stop after max elapsed time, got: %v", next)
+	}
+}
+
+func TestBadBackOffConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.Backoff.InitialInterval = "not a duration"
+	if _, err := conf.GetFullJitterCtor(); err == nil {
+		t.Error("Expected error from bad initial interval")
+	}
+}
+
+// retryUntil calls fn until it succeeds or the backoff stops, returning the
+// number of attempts and the last error.
+func retryUntil(boff backoff.BackOff, fn func() error) (int, error) {
+	attempts := 1
+	err := fn()
+	for err != nil {
+		next := NextBackOff(boff, err)
+		if next == backoff.Stop {
+			break
+		}
+		<-time.After(next)
+		attempts++
+		err = fn()
+	}
+	return attempts, err
+}
+
+func TestMaxRetriesZeroRetriesForever(t *testing.T) {
+	conf := NewConfig()
+	conf.MaxRetries = 0
+	conf.Backoff.InitialInterval = "1us"
+	conf.Backoff.MaxInterval = "1us"
+	conf.Backoff.MaxElapsedTime = "0s"
+
+	boff, err := conf.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		if boff.NextBackOff() == backoff.Stop {
+			t.Fatalf("Expected retries to continue, stopped after: %v", i)
+		}
+	}
+}
+
+func TestMaxRetriesStopsWithLastError(t *testing.T) {
+	conf := NewConfig()
+	conf.MaxRetries = 3
+	conf.Backoff.InitialInterval = "1us"
+	conf.Backoff.MaxInterval = "1us"
+
+	boff, err := conf.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	attempts, err := retryUntil(boff, func() error {
+		calls++
+		return errors.New("failed " + string(rune('0'+calls)))
+	})
+	if exp, act := 4, attempts; exp != act {
+		t.Errorf("Wrong count of attempts: %v != %v", act, exp)
+	}
+	if exp, act := "failed 4", err.Error(); exp != act {
+		t.Errorf("Wrong error returned: %v != %v", act, exp)
+	}
+}
+
+func TestMaxRetriesNegative(t *testing.T) {
+	conf := NewConfig()
+	if err := yaml.Unmarshal([]byte(`max_retries: -1`), &conf); err == nil {
+		t.Error("Expected error from negative max_retries")
+	}
+}
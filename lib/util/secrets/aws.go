@@ -0,0 +1,64 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	RegisterProvider("aws", NewAWS())
+}
+
+// NewAWS returns a Provider that resolves references of the form `name` or
+// `name#field` from AWS Secrets Manager, where the optional field extracts a
+// value from a secret stored as a JSON object. The AWS session is created
+// lazily from the environment of the process.
+func NewAWS() Provider {
+	return ProviderFunc(func(ref string) (string, error) {
+		name, field := ref, ""
+		if hashIndex := strings.LastIndex(ref, "#"); hashIndex != -1 {
+			name, field = ref[:hashIndex], ref[hashIndex+1:]
+		}
+
+		sess, err := session.NewSessionWithOptions(session.Options{
+			SharedConfigState: session.SharedConfigEnable,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		out, err := secretsmanager.New(sess).GetSecretValue(&secretsmanager.GetSecretValueInput{
+			SecretId: aws.String(name),
+		})
+		if err != nil {
+			return "", err
+		}
+
+		value := aws.StringValue(out.SecretString)
+		if len(field) == 0 {
+			return value, nil
+		}
+
+		var obj map[string]interface{}
+		if err = json.Unmarshal([]byte(value), &obj); err != nil {
+			return "", fmt.Errorf("failed to parse secret as a JSON object: %v", err)
+		}
+		fieldValue, exists := obj[field]
+		if !exists {
+			return "", fmt.Errorf("field '%v' was not found", field)
+		}
+		if str, ok := fieldValue.(string); ok {
+			return str, nil
+		}
+		return fmt.Sprintf("%v", fieldValue), nil
+	})
+}
+
+//------------------------------------------------------------------------------
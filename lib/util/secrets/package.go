@@ -0,0 +1,3 @@
+// Package secrets implements the resolution of secret references within
+// configuration values from pluggable secret providers.
+package secrets
@@ -0,0 +1,158 @@
+package secrets
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//------------------------------------------------------------------------------
+
+// Provider is a store of secrets that is able to resolve a reference into a
+// secret value.
+type Provider interface {
+	// Resolve returns the secret value identified by a provider specific
+	// reference.
+	Resolve(ref string) (string, error)
+}
+
+// ProviderFunc is a closure that implements Provider.
+type ProviderFunc func(ref string) (string, error)
+
+// Resolve returns the secret value identified by a reference.
+func (f ProviderFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+//------------------------------------------------------------------------------
+
+var (
+	providers    = map[string]Provider{}
+	providersMut sync.RWMutex
+)
+
+// RegisterProvider adds a secret provider under a name, which is then used to
+// resolve references of the form `${secret:<name>:<ref>}`. Registering a
+// provider with an existing name replaces it.
+func RegisterProvider(name string, p Provider) {
+	providersMut.Lock()
+	providers[name] = p
+	providersMut.Unlock()
+}
+
+func getProvider(name string) (Provider, bool) {
+	providersMut.RLock()
+	p, exists := providers[name]
+	providersMut.RUnlock()
+	return p, exists
+}
+
+//------------------------------------------------------------------------------
+
+var secretRegex = regexp.MustCompile(`\${secret:([0-9A-Za-z_]+):([^}]+)}`)
+
+// ContainsReferences returns true if a string contains secret references.
+func ContainsReferences(str string) bool {
+	return secretRegex.MatchString(str)
+}
+
+// ReplaceReferences resolves all secret references within a string and
+// returns the result.
+func ReplaceReferences(str string) (string, error) {
+	var err error
+	res := secretRegex.ReplaceAllStringFunc(str, func(content string) string {
+		if err != nil {
+			return ""
+		}
+		groups := secretRegex.FindStringSubmatch(content)
+		p, exists := getProvider(groups[1])
+		if !exists {
+			err = fmt.Errorf("secret provider '%v' not recognised", groups[1])
+			return ""
+		}
+		var value string
+		if value, err = p.Resolve(groups[2]); err != nil {
+			err = fmt.Errorf("provider '%v' failed to resolve secret '%v': %v", groups[1], groups[2], err)
+		}
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	return res, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Walk traverses a generic structure parsed from a config and replaces the
+// secret references of all string values in place. Returns a boolean indicating
+// whether any references were found, and an error naming the field of a
+// reference that failed to resolve.
+func Walk(obj interface{}) (bool, error) {
+	return walk(nil, obj, func(string) {})
+}
+
+func fieldPath(path []string) string {
+	return strings.Replace(strings.Join(path, "."), ".[", "[", -1)
+}
+
+func walk(path []string, obj interface{}, set func(string)) (found bool, err error) {
+	resolve := func(childPath []string, v interface{}, childSet func(string)) error {
+		rFound, rErr := walk(childPath, v, childSet)
+		if rFound {
+			found = true
+		}
+		return rErr
+	}
+
+	switch x := obj.(type) {
+	case string:
+		if !ContainsReferences(x) {
+			return false, nil
+		}
+		res, rErr := ReplaceReferences(x)
+		if rErr != nil {
+			return true, fmt.Errorf("field '%v': %v", fieldPath(path), rErr)
+		}
+		set(res)
+		return true, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			key := k
+			if err = resolve(append(path, key), x[key], func(v string) {
+				x[key] = v
+			}); err != nil {
+				return
+			}
+		}
+	case map[interface{}]interface{}:
+		for k, v := range x {
+			key := k
+			if err = resolve(append(path, fmt.Sprintf("%v", key)), v, func(v string) {
+				x[key] = v
+			}); err != nil {
+				return
+			}
+		}
+	case []interface{}:
+		for i, v := range x {
+			index := i
+			if err = resolve(append(path, "["+strconv.Itoa(index)+"]"), v, func(v string) {
+				x[index] = v
+			}); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,126 @@
+package secrets
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	RegisterProvider("test", ProviderFunc(func(ref string) (string, error) {
+		if ref == "bad" {
+			return "", errors.New("nope")
+		}
+		return "resolved_" + ref, nil
+	}))
+}
+
+func TestReplaceReferences(t *testing.T) {
+	tests := map[string]struct {
+		input  string
+		output string
+		err    string
+	}{
+		"no refs": {
+			input:  "foo ${BAR} baz",
+			output: "foo ${BAR} baz",
+		},
+		"single ref": {
+			input:  "${secret:test:foo}",
+			output: "resolved_foo",
+		},
+		"multiple refs": {
+			input:  "${secret:test:foo}:${secret:test:path/to#field}",
+			output: "resolved_foo:resolved_path/to#field",
+		},
+		"unknown provider": {
+			input: "${secret:nope:foo}",
+			err:   "secret provider 'nope' not recognised",
+		},
+		"failed resolve": {
+			input: "${secret:test:bad}",
+			err:   "provider 'test' failed to resolve secret 'bad': nope",
+		},
+	}
+
+	for name, test := range tests {
+		res, err := ReplaceReferences(test.input)
+		if len(test.err) > 0 {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%v: Wrong error: %v != %v", name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+		} else if res != test.output {
+			t.Errorf("%v: Wrong result: %v != %v", name, res, test.output)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	obj := map[string]interface{}{
+		"output": map[string]interface{}{
+			"kafka": map[string]interface{}{
+				"addresses": []interface{}{"${secret:test:addr}", "localhost:9092"},
+				"sasl": map[string]interface{}{
+					"password": "${secret:test:pass}",
+				},
+			},
+		},
+		"count": 10,
+	}
+
+	found, err := Walk(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Error("Expected references to be found")
+	}
+
+	exp := map[string]interface{}{
+		"output": map[string]interface{}{
+			"kafka": map[string]interface{}{
+				"addresses": []interface{}{"resolved_addr", "localhost:9092"},
+				"sasl": map[string]interface{}{
+					"password": "resolved_pass",
+				},
+			},
+		},
+		"count": 10,
+	}
+	if !reflect.DeepEqual(exp, obj) {
+		t.Errorf("Wrong result: %v != %v", obj, exp)
+	}
+
+	if found, err = Walk(exp); err != nil {
+		t.Fatal(err)
+	} else if found {
+		t.Error("Expected no references to be found")
+	}
+}
+
+func TestWalkErrorNamesField(t *testing.T) {
+	obj := map[string]interface{}{
+		"output": map[string]interface{}{
+			"kafka": map[string]interface{}{
+				"addresses": []interface{}{"localhost:9092", "${secret:test:bad}"},
+			},
+		},
+	}
+
+	_, err := Walk(obj)
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	if exp := "field 'output.kafka.addresses[1]'"; !strings.Contains(err.Error(), exp) {
+		t.Errorf("Expected error '%v' to contain '%v'", err, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
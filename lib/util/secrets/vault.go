@@ -0,0 +1,78 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	RegisterProvider("vault", NewVault(os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")))
+}
+
+// NewVault returns a Provider that resolves references of the form
+// `path#field` by reading the secret at a path from the HTTP API of a Vault
+// server. Secrets of both version 1 and version 2 key/value engines are
+// supported.
+func NewVault(addr, token string) Provider {
+	client := &http.Client{Timeout: time.Second * 10}
+	return ProviderFunc(func(ref string) (string, error) {
+		if len(addr) == 0 {
+			return "", errors.New("vault address is not set, use the VAULT_ADDR environment variable")
+		}
+		hashIndex := strings.LastIndex(ref, "#")
+		if hashIndex == -1 {
+			return "", errors.New("reference must be of the form path#field")
+		}
+		path, field := ref[:hashIndex], ref[hashIndex+1:]
+
+		req, err := http.NewRequest("GET", strings.TrimSuffix(addr, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("X-Vault-Token", token)
+
+		res, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+
+		resBytes, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return "", err
+		}
+		if res.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("unexpected response code (%v): %s", res.StatusCode, resBytes)
+		}
+
+		var body struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err = json.Unmarshal(resBytes, &body); err != nil {
+			return "", fmt.Errorf("failed to parse response: %v", err)
+		}
+
+		data := body.Data
+		if nested, ok := data["data"].(map[string]interface{}); ok {
+			data = nested
+		}
+		value, exists := data[field]
+		if !exists {
+			return "", fmt.Errorf("field '%v' was not found", field)
+		}
+		if str, ok := value.(string); ok {
+			return str, nil
+		}
+		return fmt.Sprintf("%v", value), nil
+	})
+}
+
+//------------------------------------------------------------------------------
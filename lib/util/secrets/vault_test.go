@@ -0,0 +1,56 @@
+package secrets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+//------------------------------------------------------------------------------
+
+func TestVault(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exp, act := "footoken", r.Header.Get("X-Vault-Token"); exp != act {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/kafka":
+			w.Write([]byte(`{"data":{"data":{"password":"foopass"},"metadata":{"version":1}}}`))
+		case "/v1/kv/kafka":
+			w.Write([]byte(`{"data":{"password":"barpass"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	p := NewVault(ts.URL, "footoken")
+
+	if v, err := p.Resolve("secret/data/kafka#password"); err != nil {
+		t.Error(err)
+	} else if exp := "foopass"; v != exp {
+		t.Errorf("Wrong result: %v != %v", v, exp)
+	}
+
+	if v, err := p.Resolve("kv/kafka#password"); err != nil {
+		t.Error(err)
+	} else if exp := "barpass"; v != exp {
+		t.Errorf("Wrong result: %v != %v", v, exp)
+	}
+
+	if _, err := p.Resolve("kv/kafka#user"); err == nil {
+		t.Error("Expected error from missing field")
+	}
+	if _, err := p.Resolve("kv/nope#password"); err == nil {
+		t.Error("Expected error from missing path")
+	}
+	if _, err := p.Resolve("kv/kafka"); err == nil {
+		t.Error("Expected error from missing field separator")
+	}
+	if _, err := NewVault(ts.URL, "bartoken").Resolve("kv/kafka#password"); err == nil {
+		t.Error("Expected error from bad token")
+	}
+}
+
+//------------------------------------------------------------------------------
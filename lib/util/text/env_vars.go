@@ -36,6 +36,9 @@ func ContainsEnvVariables(inBytes []byte) bool {
 // respective environment variable will be read and will replace the pattern. If
 // the environment variable is empty or does not exist then either the default
 // value is used or the field will be left empty.
+//
+// Patterns of the form `${secret:provider:ref}` are secret references and are
+// left unchanged.
 func ReplaceEnvVariables(inBytes []byte) []byte {
 	replaced := envRegex.ReplaceAllFunc(inBytes, func(content []byte) []byte {
 		var value string
@@ -45,6 +48,9 @@ func ReplaceEnvVariables(inBytes []byte) []byte {
 			} else {
 				targetVar := content[2:colonIndex]
 				defaultVal := content[colonIndex+1 : len(content)-1]
+				if string(targetVar) == "secret" {
+					return content
+				}
 
 				value = os.Getenv(string(targetVar))
 				if len(value) == 0 {
@@ -49,6 +49,7 @@ func TestEnvSwapping(t *testing.T) {
 		"foo ${BENTHOS_TEST_FOO:${!count:foo}-${!timestamp_unix_nano}.tar.gz} baz": "foo ${!count:foo}-${!timestamp_unix_nano}.tar.gz baz",
 		"foo ${{BENTHOS_TEST_FOO:bar}} baz":                                        "foo ${BENTHOS_TEST_FOO:bar} baz",
 		"foo ${{BENTHOS_TEST_FOO}} baz":                                            "foo ${BENTHOS_TEST_FOO} baz",
+		"foo ${secret:vault:secret/data/foo#bar} baz":                              "foo ${secret:vault:secret/data/foo#bar} baz",
 	}
 
 	for in, exp := range tests {
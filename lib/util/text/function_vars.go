@@ -3,6 +3,7 @@ package text
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"os"
 	"regexp"
 	"strconv"
@@ -27,6 +28,11 @@ type Message interface {
 //------------------------------------------------------------------------------
 
 func jsonFieldFunction(msg Message, arg string) []byte {
+	b, _ := jsonFieldFunctionChecked(msg, arg)
+	return b
+}
+
+func jsonFieldFunctionChecked(msg Message, arg string) ([]byte, error) {
 	args := strings.Split(arg, ",")
 	part := 0
 	if len(args) == 2 {
@@ -37,7 +43,7 @@ func jsonFieldFunction(msg Message, arg string) []byte {
 	}
 	jPart, err := msg.Get(part).JSON()
 	if err != nil {
-		return []byte("null")
+		return []byte("null"), fmt.Errorf("json_field: failed to parse message as JSON: %v", err)
 	}
 	gPart := gabs.Wrap(jPart)
 	if len(args) > 0 {
@@ -45,11 +51,11 @@ func jsonFieldFunction(msg Message, arg string) []byte {
 	}
 	switch t := gPart.Data().(type) {
 	case string:
-		return []byte(t)
+		return []byte(t), nil
 	case nil:
-		return []byte(`null`)
+		return []byte(`null`), nil
 	}
-	return gPart.Bytes()
+	return gPart.Bytes(), nil
 }
 
 func metadataFunction(msg Message, arg string) []byte {
@@ -197,6 +203,13 @@ var functionVars = map[string]func(msg Message, arg string) []byte{
 	},
 }
 
+// checkedFunctionVars are variants of functions that report an error when they
+// are unable to resolve a value from a message, along with the same value that
+// the unchecked function would have given.
+var checkedFunctionVars = map[string]func(msg Message, arg string) ([]byte, error){
+	"json_field": jsonFieldFunctionChecked,
+}
+
 // ContainsFunctionVariables returns true if inBytes contains function variable
 // replace patterns.
 func ContainsFunctionVariables(inBytes []byte) bool {
@@ -238,32 +251,52 @@ func ReplaceFunctionVariablesEscaped(msg Message, inBytes []byte) []byte {
 	return replaceFunctionVariables(msg, true, inBytes)
 }
 
+// ReplaceFunctionVariablesChecked behaves the same as ReplaceFunctionVariables
+// but also returns the first error encountered by a function that was unable to
+// resolve its value from the message.
+func ReplaceFunctionVariablesChecked(msg Message, inBytes []byte) ([]byte, error) {
+	return replaceFunctionVariablesChecked(msg, false, inBytes)
+}
+
 func replaceFunctionVariables(msg Message, escape bool, inBytes []byte) []byte {
+	replaced, _ := replaceFunctionVariablesChecked(msg, escape, inBytes)
+	return replaced
+}
+
+func callFunction(msg Message, name, arg string) ([]byte, bool, error) {
+	if ftor, exists := checkedFunctionVars[name]; exists {
+		res, err := ftor(msg, arg)
+		return res, true, err
+	}
+	if ftor, exists := functionVars[name]; exists {
+		return ftor(msg, arg), true, nil
+	}
+	return nil, false, nil
+}
+
+func replaceFunctionVariablesChecked(msg Message, escape bool, inBytes []byte) ([]byte, error) {
+	var firstErr error
 	replaced := functionRegex.ReplaceAllFunc(inBytes, func(content []byte) []byte {
 		if len(content) > 4 {
-			if colonIndex := bytes.IndexByte(content, ':'); colonIndex == -1 {
-				targetFunc := string(content[3 : len(content)-1])
-				if ftor, exists := functionVars[targetFunc]; exists {
-					if escape {
-						return escapeBytes(ftor(msg, ""))
-					}
-					return ftor(msg, "")
+			targetFunc, argVal := string(content[3:len(content)-1]), ""
+			if colonIndex := bytes.IndexByte(content, ':'); colonIndex != -1 {
+				targetFunc = string(content[3:colonIndex])
+				argVal = string(content[colonIndex+1 : len(content)-1])
+			}
+			if res, exists, err := callFunction(msg, targetFunc, argVal); exists {
+				if err != nil && firstErr == nil {
+					firstErr = err
 				}
-			} else {
-				targetFunc := string(content[3:colonIndex])
-				argVal := string(content[colonIndex+1 : len(content)-1])
-				if ftor, exists := functionVars[targetFunc]; exists {
-					if escape {
-						return escapeBytes(ftor(msg, argVal))
-					}
-					return ftor(msg, argVal)
+				if escape {
+					return escapeBytes(res)
 				}
+				return res
 			}
 		}
 		return content
 	})
 	replaced = escapedFunctionRegex.ReplaceAll(replaced, []byte(`$$$1`))
-	return replaced
+	return replaced, firstErr
 }
 
 //------------------------------------------------------------------------------
@@ -19,6 +19,17 @@ func (i *InterpolatedString) Get(msg Message) string {
 	return string(ReplaceFunctionVariables(msg, i.strBytes))
 }
 
+// GetChecked evaluates functions within the original string and returns the
+// result along with the first error encountered by a function that was unable
+// to resolve its value, in which case the result is the same as Get.
+func (i *InterpolatedString) GetChecked(msg Message) (string, error) {
+	if !i.interpolate {
+		return i.str, nil
+	}
+	res, err := ReplaceFunctionVariablesChecked(msg, i.strBytes)
+	return string(res), err
+}
+
 // NewInterpolatedString returns a type that evaluates function interpolations
 // on a provided string each time Get is called.
 func NewInterpolatedString(str string) *InterpolatedString {
@@ -50,6 +61,16 @@ func (i *InterpolatedBytes) Get(msg Message) []byte {
 	return ReplaceFunctionVariables(msg, i.v)
 }
 
+// GetChecked evaluates functions within the byte slice and returns the result
+// along with the first error encountered by a function that was unable to
+// resolve its value, in which case the result is the same as Get.
+func (i *InterpolatedBytes) GetChecked(msg Message) ([]byte, error) {
+	if !i.interpolate {
+		return i.v, nil
+	}
+	return ReplaceFunctionVariablesChecked(msg, i.v)
+}
+
 // NewInterpolatedBytes returns a type that evaluates function interpolations
 // on a provided byte slice each time Get is called.
 func NewInterpolatedBytes(v []byte) *InterpolatedBytes {
@@ -59,3 +59,40 @@ func TestInterpolatedBytes(t *testing.T) {
 		t.Errorf("Wrong result: %v != %v", act, exp)
 	}
 }
+
+func TestInterpolatedChecked(t *testing.T) {
+	str := NewInterpolatedString("foo${!json_field:foo.bar}bar")
+	act, err := str.GetChecked(message.New([][]byte{[]byte(`{"foo":{"bar":"baz"}}`)}))
+	if err != nil {
+		t.Error(err)
+	}
+	if exp := "foobazbar"; exp != act {
+		t.Errorf("Wrong result: %v != %v", act, exp)
+	}
+
+	act, err = str.GetChecked(message.New([][]byte{[]byte(`not json`)}))
+	if err == nil {
+		t.Error("Expected error from invalid JSON")
+	}
+	if exp := "foonullbar"; exp != act {
+		t.Errorf("Wrong result: %v != %v", act, exp)
+	}
+
+	b := NewInterpolatedBytes([]byte("${!json_field:foo}-${!metadata:bar}"))
+	msg := message.New([][]byte{[]byte(`not json`)})
+	msg.Get(0).Metadata().Set("bar", "baz")
+	bAct, err := b.GetChecked(msg)
+	if err == nil {
+		t.Error("Expected error from invalid JSON")
+	}
+	if exp := "null-baz"; exp != string(bAct) {
+		t.Errorf("Wrong result: %s != %v", bAct, exp)
+	}
+
+	str = NewInterpolatedString("static")
+	if act, err = str.GetChecked(message.New([][]byte{[]byte(`not json`)})); err != nil {
+		t.Error(err)
+	} else if exp := "static"; exp != act {
+		t.Errorf("Wrong result: %v != %v", act, exp)
+	}
+}
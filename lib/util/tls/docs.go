@@ -11,7 +11,8 @@ providing a collection of root certificate authorities, providing a list of
 client certificates to use for client verification and skipping certificate
 verification.
 
-Client certificates can either be added by file or by raw contents.`,
+Client certificates can either be added by file or by raw contents, and
+encrypted private keys can be decrypted with a ` + "`password`" + `.`,
 		Advanced: true,
 		Examples: []interface{}{
 			map[string]interface{}{
@@ -20,6 +21,7 @@ Client certificates can either be added by file or by raw contents.`,
 					map[string]interface{}{
 						"cert_file": "./example.pem",
 						"key_file":  "./example.key",
+						"password":  "${KEY_PASSWORD}",
 					},
 				},
 			},
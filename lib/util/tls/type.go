@@ -3,6 +3,9 @@ package tls
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"io/ioutil"
 )
 
@@ -25,6 +28,18 @@ client_certs:
     key_file: ./example.key
   - cert: foo
     key: bar
+` + "```" + `
+
+A client certificate must be specified either by file or by raw contents, but
+not both. When the private key is encrypted the ` + "`password`" + ` field can
+be used to decrypt it:
+
+` + "``` yaml" + `
+enabled: true
+client_certs:
+  - cert_file: ./example.pem
+    key_file: ./example.key
+    password: ${KEY_PASSWORD}
 ` + "```" + ``
 
 //------------------------------------------------------------------------------
@@ -35,6 +50,7 @@ type ClientCertConfig struct {
 	KeyFile  string `json:"key_file" yaml:"key_file"`
 	Cert     string `json:"cert" yaml:"cert"`
 	Key      string `json:"key" yaml:"key"`
+	Password string `json:"password" yaml:"password"`
 }
 
 // Config contains configuration params for TLS.
@@ -90,10 +106,53 @@ func (c *Config) Get() (*tls.Config, error) {
 // Load returns a TLS certificate, based on either file paths in the
 // config or the raw certs as strings.
 func (c *ClientCertConfig) Load() (tls.Certificate, error) {
-	if c.CertFile != "" && c.KeyFile != "" {
-		return tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	fromFiles := c.CertFile != "" || c.KeyFile != ""
+	if fromFiles && (c.Cert != "" || c.Key != "") {
+		return tls.Certificate{}, errors.New("client certificate must be specified by either cert_file and key_file or cert and key, not both")
+	}
+
+	certBytes, keyBytes := []byte(c.Cert), []byte(c.Key)
+	if fromFiles {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return tls.Certificate{}, errors.New("client certificate requires both cert_file and key_file")
+		}
+		var err error
+		if certBytes, err = ioutil.ReadFile(c.CertFile); err != nil {
+			return tls.Certificate{}, err
+		}
+		if keyBytes, err = ioutil.ReadFile(c.KeyFile); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+
+	if c.Password != "" {
+		var err error
+		if keyBytes, err = decryptKey(keyBytes, c.Password); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+	return tls.X509KeyPair(certBytes, keyBytes)
+}
+
+// decryptKey decrypts a PEM encoded private key that has been encrypted with a
+// password, and returns it PEM encoded without encryption. Keys that are not
+// encrypted are returned unchanged.
+func decryptKey(keyBytes []byte, password string) ([]byte, error) {
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM private key")
+	}
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		return nil, errors.New("encrypted PKCS#8 private keys are not supported, the key must be encrypted with a PEM encryption header")
+	}
+	if !x509.IsEncryptedPEMBlock(block) {
+		return keyBytes, nil
+	}
+	der, err := x509.DecryptPEMBlock(block, []byte(password))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private key: %v", err)
 	}
-	return tls.X509KeyPair([]byte(c.Cert), []byte(c.Key))
+	return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}), nil
 }
 
 //------------------------------------------------------------------------------
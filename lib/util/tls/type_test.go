@@ -0,0 +1,175 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+
+func createCertificates(t *testing.T) (certPem, keyPem []byte, key *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "benthos"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPem = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	return certPem, keyPem, key
+}
+
+func encryptKey(t *testing.T, key *ecdsa.PrivateKey, password string) []byte {
+	t.Helper()
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", keyDer, []byte(password), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(block)
+}
+
+//------------------------------------------------------------------------------
+
+func TestClientCertInline(t *testing.T) {
+	certPem, keyPem, _ := createCertificates(t)
+
+	conf := ClientCertConfig{
+		Cert: string(certPem),
+		Key:  string(keyPem),
+	}
+	if _, err := conf.Load(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClientCertFiles(t *testing.T) {
+	certPem, keyPem, _ := createCertificates(t)
+
+	dir, err := ioutil.TempDir("", "benthos_tls_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certPath, keyPath := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err = ioutil.WriteFile(certPath, certPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(keyPath, keyPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := ClientCertConfig{
+		CertFile: certPath,
+		KeyFile:  keyPath,
+	}
+	if _, err = conf.Load(); err != nil {
+		t.Error(err)
+	}
+
+	conf.KeyFile = ""
+	if _, err = conf.Load(); err == nil {
+		t.Error("Expected error from missing key_file")
+	}
+}
+
+func TestClientCertBothSources(t *testing.T) {
+	certPem, keyPem, _ := createCertificates(t)
+
+	conf := ClientCertConfig{
+		CertFile: "./cert.pem",
+		KeyFile:  "./key.pem",
+		Cert:     string(certPem),
+		Key:      string(keyPem),
+	}
+	if _, err := conf.Load(); err == nil {
+		t.Error("Expected error from both files and contents")
+	}
+}
+
+func TestClientCertEncryptedKey(t *testing.T) {
+	certPem, _, key := createCertificates(t)
+	encKeyPem := encryptKey(t, key, "foo")
+
+	conf := ClientCertConfig{
+		Cert: string(certPem),
+		Key:  string(encKeyPem),
+	}
+	if _, err := conf.Load(); err == nil {
+		t.Error("Expected error from encrypted key without password")
+	}
+
+	conf.Password = "bar"
+	if _, err := conf.Load(); err == nil {
+		t.Error("Expected error from wrong password")
+	}
+
+	conf.Password = "foo"
+	if _, err := conf.Load(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClientCertPasswordUnencryptedKey(t *testing.T) {
+	certPem, keyPem, _ := createCertificates(t)
+
+	conf := ClientCertConfig{
+		Cert:     string(certPem),
+		Key:      string(keyPem),
+		Password: "foo",
+	}
+	if _, err := conf.Load(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConfigGetClientCerts(t *testing.T) {
+	certPem, _, key := createCertificates(t)
+
+	conf := NewConfig()
+	conf.Enabled = true
+	conf.ClientCertificates = append(conf.ClientCertificates, ClientCertConfig{
+		Cert:     string(certPem),
+		Key:      string(encryptKey(t, key, "foo")),
+		Password: "foo",
+	})
+
+	tlsConf, err := conf.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp, act := 1, len(tlsConf.Certificates); exp != act {
+		t.Errorf("Wrong count of certificates: %v != %v", act, exp)
+	}
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,71 @@
+package docs
+
+import (
+	"strings"
+	"testing"
+)
+
+//------------------------------------------------------------------------------
+
+func TestComponentMarkdownNestedFields(t *testing.T) {
+	spec := ComponentSpec{
+		Name: "foo",
+		Type: "input",
+		Fields: FieldSpecs{
+			FieldCommon("a", "First level.").WithChildren(
+				FieldCommon("b", "Second level.").WithChildren(
+					FieldCommon("c", "Third level."),
+				),
+			),
+		},
+	}
+	conf := map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": map[string]interface{}{
+				"c": "bar",
+			},
+		},
+	}
+
+	mdBytes, err := spec.AsMarkdown(true, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{"### `a`", "### `a.b`", "### `a.b.c`"} {
+		if !strings.Contains(string(mdBytes), exp) {
+			t.Errorf("Expected markdown to contain %v", exp)
+		}
+	}
+}
+
+func TestComponentMarkdownNestedMissingFields(t *testing.T) {
+	spec := ComponentSpec{
+		Name: "foo",
+		Type: "input",
+		Fields: FieldSpecs{
+			FieldCommon("a", "First level.").WithChildren(
+				FieldCommon("b", "Second level.").WithChildren(
+					FieldCommon("c", "Third level."),
+				),
+			),
+		},
+	}
+	conf := map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": map[string]interface{}{
+				"c": "bar",
+				"d": "baz",
+			},
+		},
+	}
+
+	_, err := spec.AsMarkdown(true, conf)
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	if exp, act := "spec missing fields: [a.b.d]", err.Error(); exp != act {
+		t.Errorf("Wrong error: %v != %v", act, exp)
+	}
+}
+
+//------------------------------------------------------------------------------